@@ -0,0 +1,246 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+
+static inline AggState* ToAggState(uintptr_t aggstate) {
+	return (AggState*)aggstate;
+}
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// Aggregate runs an aggregate whose transition and final functions belong to an extension, which mirrors the fields of
+// an AggregateDefinition.
+type Aggregate struct {
+	// Transition is called with the state and the arguments of each row, and returns the new state.
+	Transition Function
+	// Final computes the result from the state. When it's nil, the state is the result.
+	Final *Function
+	// FinalExtra gives the final function a NULL for each of the aggregate's arguments after the state.
+	FinalExtra bool
+	// NumArgs is the number of arguments that are aggregated, which is only needed when FinalExtra is set.
+	NumArgs int
+	// StateType describes how the state is stored. By-reference states are kept within the group's memory context.
+	StateType ResultType
+	// InitialState is the state that each group begins with, which is NULL for aggregates without an initial condition.
+	// By-reference states are copied into each group, so the Datum must only remain valid until Begin returns.
+	InitialState NullableDatum
+}
+
+// AggregateGroup accumulates the rows of a single group of an aggregate. A group may be used by one call at a time,
+// and must be closed once it is no longer needed.
+type AggregateGroup struct {
+	mutex *sync.Mutex
+	agg   Aggregate
+	state *C.AggState
+	// transition and final are the FmgrInfo of each function, which persist for the life of the group so that the
+	// functions may cache state within them.
+	transition *C.FmgrInfo
+	final      *C.FmgrInfo
+	// value is the transition value, which is NULL until the first row for aggregates without an initial state.
+	value    NullableDatum
+	finished bool
+}
+
+var (
+	shimAggBegin      = newShimProc("pgext_agg_begin")
+	shimAggCallBegin  = newShimProc("pgext_agg_call_begin")
+	shimAggCallEnd    = newShimProc("pgext_agg_call_end")
+	shimAggTransition = newShimProc("pgext_agg_transition")
+	shimAggEnd        = newShimProc("pgext_agg_end")
+)
+
+// Begin starts a new group of the aggregate.
+func (agg Aggregate) Begin() (*AggregateGroup, error) {
+	statePtr, err := shimAggBegin.Call()
+	if err != nil {
+		return nil, err
+	}
+	if statePtr == 0 {
+		return nil, errors.New("out of memory while creating the memory context of an aggregate")
+	}
+	group := &AggregateGroup{
+		mutex: &sync.Mutex{},
+		agg:   agg,
+		state: C.ToAggState(C.uintptr_t(statePtr)),
+		value: NullableDatum{IsNull: true},
+	}
+	group.transition = group.newFmgrInfo(agg.Transition)
+	if agg.Final != nil {
+		group.final = group.newFmgrInfo(*agg.Final)
+	}
+	if !agg.InitialState.IsNull {
+		if err = group.setValue(agg.InitialState); err != nil {
+			_ = group.Close()
+			return nil, err
+		}
+	}
+	return group, nil
+}
+
+// Add accumulates a single row into the group. Rows with a NULL argument are skipped when the transition function is
+// STRICT, and the first row becomes the state when the state begins as NULL, as Postgres does.
+func (group *AggregateGroup) Add(args ...NullableDatum) error {
+	unlock := lockBackend()
+	defer unlock()
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+	if group.state == nil {
+		return errors.New("cannot add a row to an aggregate group that has been closed")
+	}
+	if group.finished {
+		return errors.New("cannot add a row to an aggregate group that has been finished")
+	}
+	if group.agg.Transition.Strict {
+		if hasNullArg(args) {
+			return nil
+		}
+		if group.value.IsNull {
+			if len(args) == 0 {
+				return nil
+			}
+			return group.setValue(args[0])
+		}
+	}
+	transArgs := make([]NullableDatum, 0, len(args)+1)
+	transArgs = append(transArgs, group.value)
+	transArgs = append(transArgs, args...)
+	// The current memory context belongs to the state of the current thread, so we must remain on the same thread until
+	// the call has ended
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	result, isNull, err := group.call(group.agg.Transition, group.transition, transArgs)
+	if err != nil {
+		return err
+	}
+	return group.setValue(NullableDatum{Value: result, IsNull: isNull})
+}
+
+// Finish returns the result of the group, which is computed by the final function if the aggregate has one. A STRICT
+// final function is not called when the state is NULL, and the result is NULL instead. By-reference results remain
+// valid until the group is closed, and a group cannot have more rows added once it has finished.
+func (group *AggregateGroup) Finish() (result Datum, isNotNull bool, err error) {
+	unlock := lockBackend()
+	defer unlock()
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+	if group.state == nil {
+		return 0, false, errors.New("cannot finish an aggregate group that has been closed")
+	}
+	group.finished = true
+	if group.agg.Final == nil {
+		return group.value.Value, !group.value.IsNull, nil
+	}
+	if group.agg.Final.Strict && group.value.IsNull {
+		return 0, false, nil
+	}
+	finalArgs := []NullableDatum{group.value}
+	if group.agg.FinalExtra {
+		for i := 0; i < group.agg.NumArgs; i++ {
+			finalArgs = append(finalArgs, NullableDatum{IsNull: true})
+		}
+	}
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	result, isNull, err := group.call(*group.agg.Final, group.final, finalArgs)
+	if err != nil {
+		return 0, false, err
+	}
+	return result, !isNull && result != 0, nil
+}
+
+// Close frees the group, along with its state and result. Any callbacks that the functions registered through
+// AggRegisterCallback are run, and the first error that they raise is returned.
+func (group *AggregateGroup) Close() error {
+	unlock := lockBackend()
+	defer unlock()
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+	if group.state == nil {
+		return nil
+	}
+	edata, err := shimAggEnd.Call(uintptr(unsafe.Pointer(group.state)))
+	group.state = nil
+	Free(group.transition)
+	Free(group.final)
+	if err != nil {
+		return err
+	}
+	if edata != 0 {
+		return newCallError(edata)
+	}
+	return nil
+}
+
+// call calls the function with the group's state in the call info's context, within the group's temporary memory
+// context. The caller must be locked to its thread.
+func (group *AggregateGroup) call(fn Function, fi *C.FmgrInfo, args []NullableDatum) (Datum, bool, error) {
+	statePtr := uintptr(unsafe.Pointer(group.state))
+	if _, err := shimAggCallBegin.Call(statePtr); err != nil {
+		return 0, false, err
+	}
+	defer shimAggCallEnd.MustCall(statePtr)
+	// The strictness of the functions is handled by the group, as the extra arguments of a final function are NULL
+	fn.Strict = false
+	result, isNull, _, err := fn.callWithInfo(fi, callNodes{context: unsafe.Pointer(group.state)}, 0, args...)
+	return result, isNull, err
+}
+
+// setValue sets the transition value. By-reference values are moved into the group's memory context, and the previous
+// value is freed.
+func (group *AggregateGroup) setValue(value NullableDatum) error {
+	if group.agg.StateType.ByValue {
+		group.value = value
+		return nil
+	}
+	var newValue, oldValue Datum
+	if !value.IsNull {
+		newValue = value.Value
+	}
+	if !group.value.IsNull {
+		oldValue = group.value.Value
+	}
+	copied, err := shimAggTransition.Call(uintptr(unsafe.Pointer(group.state)), uintptr(newValue), uintptr(oldValue),
+		uintptr(group.agg.StateType.Length))
+	if err != nil {
+		return err
+	}
+	// The previous value is kept when the new value could not be copied
+	if copied == 0 && newValue != 0 {
+		return fmt.Errorf("out of memory while storing the state of `%s`", group.agg.Transition.Name)
+	}
+	group.value = NullableDatum{Value: Datum(copied), IsNull: copied == 0}
+	return nil
+}
+
+// newFmgrInfo returns an FmgrInfo for the function, which is freed when the group is closed. Anything that the
+// function caches within fn_extra is allocated within the group's memory context.
+func (group *AggregateGroup) newFmgrInfo(fn Function) *C.FmgrInfo {
+	fi := Malloc[C.FmgrInfo]()
+	ZeroMemory(fi)
+	fillFmgrInfo(fi, fn)
+	fi.fn_mcxt = unsafe.Pointer(group.state.aggcontext)
+	return fi
+}
@@ -0,0 +1,21 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !amd64 && !arm64
+
+package pg_extension
+
+// The shim emulates a 64-bit Postgres on little-endian hardware, and has only been built and tested on amd64 and arm64.
+// This stops other architectures from compiling rather than failing at runtime.
+var _ = pg_extension_only_supports_amd64_and_arm64
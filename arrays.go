@@ -0,0 +1,368 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+const (
+	// arrayHeaderSize is the size of the ArrayType struct, which precedes the dimensions of an array.
+	arrayHeaderSize = 16
+	// arrayMaxElements is the largest number of elements that an array may contain.
+	arrayMaxElements = 0x7FFFFFF
+)
+
+// arrayElementType describes how the elements of an array are stored, which mirrors the typlen, typbyval, and typalign
+// columns of pg_type.
+type arrayElementType struct {
+	oid     uint32
+	length  int
+	byValue bool
+	align   byte
+}
+
+// arrayElementTypes are the element types that arrays may be converted from, keyed by their OID.
+var arrayElementTypes = map[uint32]arrayElementType{
+	16:   {oid: 16, length: 1, byValue: true, align: 'c'},
+	17:   {oid: 17, length: -1, byValue: false, align: 'i'},
+	20:   {oid: 20, length: 8, byValue: true, align: 'd'},
+	21:   {oid: 21, length: 2, byValue: true, align: 's'},
+	23:   {oid: 23, length: 4, byValue: true, align: 'i'},
+	25:   {oid: 25, length: -1, byValue: false, align: 'i'},
+	26:   {oid: 26, length: 4, byValue: true, align: 'i'},
+	700:  {oid: 700, length: 4, byValue: true, align: 'i'},
+	701:  {oid: 701, length: 8, byValue: true, align: 'd'},
+	1042: {oid: 1042, length: -1, byValue: false, align: 'i'},
+	1043: {oid: 1043, length: -1, byValue: false, align: 'i'},
+}
+
+// Int32ArrayToDatum returns an int4[] Datum containing the given values, which is allocated within the current memory
+// context. Elements whose entry in nulls is true are NULL, and nulls may be nil when there are no NULL elements.
+func Int32ArrayToDatum(values []int32, nulls []bool) (Datum, error) {
+	elements := make([][]byte, len(values))
+	for i, value := range values {
+		elements[i] = binary.LittleEndian.AppendUint32(nil, uint32(value))
+	}
+	return arrayToDatum(arrayElementTypes[23], elements, nulls)
+}
+
+// Int64ArrayToDatum returns an int8[] Datum containing the given values, which is allocated within the current memory
+// context. Elements whose entry in nulls is true are NULL, and nulls may be nil when there are no NULL elements.
+func Int64ArrayToDatum(values []int64, nulls []bool) (Datum, error) {
+	elements := make([][]byte, len(values))
+	for i, value := range values {
+		elements[i] = binary.LittleEndian.AppendUint64(nil, uint64(value))
+	}
+	return arrayToDatum(arrayElementTypes[20], elements, nulls)
+}
+
+// Float64ArrayToDatum returns a float8[] Datum containing the given values, which is allocated within the current
+// memory context. Elements whose entry in nulls is true are NULL, and nulls may be nil when there are no NULL elements.
+func Float64ArrayToDatum(values []float64, nulls []bool) (Datum, error) {
+	elements := make([][]byte, len(values))
+	for i, value := range values {
+		elements[i] = binary.LittleEndian.AppendUint64(nil, math.Float64bits(value))
+	}
+	return arrayToDatum(arrayElementTypes[701], elements, nulls)
+}
+
+// StringArrayToDatum returns a text[] Datum containing the given values, which is allocated within the current memory
+// context. Elements whose entry in nulls is true are NULL, and nulls may be nil when there are no NULL elements.
+func StringArrayToDatum(values []string, nulls []bool) (Datum, error) {
+	elements := make([][]byte, len(values))
+	for i, value := range values {
+		elements[i] = NewVarlena([]byte(value))
+	}
+	return arrayToDatum(arrayElementTypes[25], elements, nulls)
+}
+
+// BytesArrayToDatum returns a bytea[] Datum containing the given values, which is allocated within the current memory
+// context. Elements whose entry in nulls is true are NULL, and nulls may be nil when there are no NULL elements.
+func BytesArrayToDatum(values [][]byte, nulls []bool) (Datum, error) {
+	elements := make([][]byte, len(values))
+	for i, value := range values {
+		elements[i] = NewVarlena(value)
+	}
+	return arrayToDatum(arrayElementTypes[17], elements, nulls)
+}
+
+// DatumToInt32Array returns the elements of the int2[] or int4[] that the Datum points to, along with whether each
+// element is NULL. The nulls are nil when no element is NULL. Multidimensional arrays are flattened in row-major order.
+func DatumToInt32Array(d Datum) ([]int32, []bool, error) {
+	elemType, elements, nulls, err := datumToArray(d)
+	if err != nil {
+		return nil, nil, err
+	}
+	values := make([]int32, len(elements))
+	for i, element := range elements {
+		switch {
+		case nulls != nil && nulls[i]:
+		case elemType.oid == 21:
+			values[i] = int32(int16(binary.LittleEndian.Uint16(element)))
+		case elemType.oid == 23:
+			values[i] = int32(binary.LittleEndian.Uint32(element))
+		default:
+			return nil, nil, fmt.Errorf("cannot convert an array of type %d to []int32", elemType.oid)
+		}
+	}
+	return values, nulls, nil
+}
+
+// DatumToInt64Array returns the elements of the int2[], int4[], or int8[] that the Datum points to, along with whether
+// each element is NULL. The nulls are nil when no element is NULL. Multidimensional arrays are flattened in row-major
+// order.
+func DatumToInt64Array(d Datum) ([]int64, []bool, error) {
+	elemType, elements, nulls, err := datumToArray(d)
+	if err != nil {
+		return nil, nil, err
+	}
+	values := make([]int64, len(elements))
+	for i, element := range elements {
+		switch {
+		case nulls != nil && nulls[i]:
+		case elemType.oid == 21:
+			values[i] = int64(int16(binary.LittleEndian.Uint16(element)))
+		case elemType.oid == 23:
+			values[i] = int64(int32(binary.LittleEndian.Uint32(element)))
+		case elemType.oid == 20:
+			values[i] = int64(binary.LittleEndian.Uint64(element))
+		default:
+			return nil, nil, fmt.Errorf("cannot convert an array of type %d to []int64", elemType.oid)
+		}
+	}
+	return values, nulls, nil
+}
+
+// DatumToFloat64Array returns the elements of the float4[] or float8[] that the Datum points to, along with whether
+// each element is NULL. The nulls are nil when no element is NULL. Multidimensional arrays are flattened in row-major
+// order.
+func DatumToFloat64Array(d Datum) ([]float64, []bool, error) {
+	elemType, elements, nulls, err := datumToArray(d)
+	if err != nil {
+		return nil, nil, err
+	}
+	values := make([]float64, len(elements))
+	for i, element := range elements {
+		switch {
+		case nulls != nil && nulls[i]:
+		case elemType.oid == 700:
+			values[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(element)))
+		case elemType.oid == 701:
+			values[i] = math.Float64frombits(binary.LittleEndian.Uint64(element))
+		default:
+			return nil, nil, fmt.Errorf("cannot convert an array of type %d to []float64", elemType.oid)
+		}
+	}
+	return values, nulls, nil
+}
+
+// DatumToStringArray returns the elements of the text[], varchar[], or bpchar[] that the Datum points to, along with
+// whether each element is NULL. The nulls are nil when no element is NULL. Multidimensional arrays are flattened in
+// row-major order.
+func DatumToStringArray(d Datum) ([]string, []bool, error) {
+	elemType, elements, nulls, err := datumToArray(d)
+	if err != nil {
+		return nil, nil, err
+	}
+	if elemType.oid != 25 && elemType.oid != 1042 && elemType.oid != 1043 {
+		return nil, nil, fmt.Errorf("cannot convert an array of type %d to []string", elemType.oid)
+	}
+	values := make([]string, len(elements))
+	for i, element := range elements {
+		if nulls != nil && nulls[i] {
+			continue
+		}
+		data, err := VarlenaData(element)
+		if err != nil {
+			return nil, nil, err
+		}
+		values[i] = string(data)
+	}
+	return values, nulls, nil
+}
+
+// DatumToBytesArray returns the elements of the bytea[] that the Datum points to, along with whether each element is
+// NULL. The nulls are nil when no element is NULL. Multidimensional arrays are flattened in row-major order.
+func DatumToBytesArray(d Datum) ([][]byte, []bool, error) {
+	elemType, elements, nulls, err := datumToArray(d)
+	if err != nil {
+		return nil, nil, err
+	}
+	if elemType.oid != 17 {
+		return nil, nil, fmt.Errorf("cannot convert an array of type %d to [][]byte", elemType.oid)
+	}
+	values := make([][]byte, len(elements))
+	for i, element := range elements {
+		if nulls != nil && nulls[i] {
+			continue
+		}
+		if values[i], err = VarlenaData(element); err != nil {
+			return nil, nil, err
+		}
+	}
+	return values, nulls, nil
+}
+
+// arrayToDatum returns a one-dimensional array of the given elements, which is allocated within the current memory
+// context. Elements that are stored by value are given as their little-endian bytes, while varlenas are given with a
+// 4-byte header. This produces the same layout as construct_md_array.
+func arrayToDatum(elemType arrayElementType, elements [][]byte, nulls []bool) (Datum, error) {
+	if nulls != nil && len(nulls) != len(elements) {
+		return 0, fmt.Errorf("array has %d elements, but %d nulls", len(elements), len(nulls))
+	}
+	if len(elements) > arrayMaxElements {
+		return 0, fmt.Errorf("array size exceeds the maximum allowed (%d)", arrayMaxElements)
+	}
+	header := make([]byte, arrayHeaderSize)
+	binary.LittleEndian.PutUint32(header[12:], elemType.oid)
+	if len(elements) == 0 {
+		binary.LittleEndian.PutUint32(header, arrayHeaderSize<<2)
+		return pallocCopy(header)
+	}
+	hasNulls := false
+	for _, isNull := range nulls {
+		hasNulls = hasNulls || isNull
+	}
+	// The dimensions and lower bound of the only dimension
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(elements)))
+	header = binary.LittleEndian.AppendUint32(header, 1)
+	if hasNulls {
+		bitmap := make([]byte, (len(elements)+7)/8)
+		for i := range elements {
+			if !nulls[i] {
+				bitmap[i>>3] |= 1 << (i & 0x07)
+			}
+		}
+		header = append(header, bitmap...)
+	}
+	dataOffset := (len(header) + 7) &^ 7
+	array := make([]byte, dataOffset, dataOffset+len(elements)*8)
+	copy(array, header)
+	for i, element := range elements {
+		if hasNulls && nulls[i] {
+			continue
+		}
+		for (len(array)-dataOffset)%alignmentOf(elemType.align) != 0 {
+			array = append(array, 0)
+		}
+		array = append(array, element...)
+	}
+	if len(array) > maxVarlenaSize {
+		return 0, fmt.Errorf("invalid memory alloc request size %d", len(array))
+	}
+	binary.LittleEndian.PutUint32(array, uint32(len(array))<<2)
+	binary.LittleEndian.PutUint32(array[4:], 1)
+	if hasNulls {
+		binary.LittleEndian.PutUint32(array[8:], uint32(dataOffset))
+	}
+	return pallocCopy(array)
+}
+
+// datumToArray returns the element type of the array that the Datum points to, along with a copy of each element and
+// whether each is NULL. Elements that are stored by value are returned as their little-endian bytes, while varlenas
+// are returned with their header. The nulls are nil when no element is NULL.
+func datumToArray(d Datum) (arrayElementType, [][]byte, []bool, error) {
+	data, err := DatumVarlenaData(d)
+	if err != nil {
+		return arrayElementType{}, nil, nil, err
+	}
+	// The offsets within an array are relative to its 4-byte header, which may have been packed
+	array := NewVarlena(data)
+	if len(array) < arrayHeaderSize {
+		return arrayElementType{}, nil, nil, fmt.Errorf("array header is truncated")
+	}
+	ndim := int(int32(binary.LittleEndian.Uint32(array[4:])))
+	dataOffset := int(int32(binary.LittleEndian.Uint32(array[8:])))
+	elemOID := binary.LittleEndian.Uint32(array[12:])
+	elemType, ok := arrayElementTypes[elemOID]
+	if !ok {
+		return arrayElementType{}, nil, nil, fmt.Errorf("arrays of type %d are not supported", elemOID)
+	}
+	if ndim == 0 {
+		return elemType, nil, nil, nil
+	}
+	if ndim < 0 || ndim > 6 || len(array) < arrayHeaderSize+8*ndim {
+		return arrayElementType{}, nil, nil, fmt.Errorf("array has an invalid number of dimensions: %d", ndim)
+	}
+	count := 1
+	for i := 0; i < ndim; i++ {
+		dim := int(int32(binary.LittleEndian.Uint32(array[arrayHeaderSize+4*i:])))
+		if dim < 0 || count*dim > arrayMaxElements {
+			return arrayElementType{}, nil, nil, fmt.Errorf("array size exceeds the maximum allowed (%d)",
+				arrayMaxElements)
+		}
+		count *= dim
+	}
+	var bitmap []byte
+	if dataOffset != 0 {
+		bitmapStart := arrayHeaderSize + 8*ndim
+		if bitmapStart+(count+7)/8 > len(array) {
+			return arrayElementType{}, nil, nil, fmt.Errorf("array null bitmap is truncated")
+		}
+		bitmap = array[bitmapStart : bitmapStart+(count+7)/8]
+	} else {
+		dataOffset = (arrayHeaderSize + 8*ndim + 7) &^ 7
+	}
+	elements := make([][]byte, count)
+	var nulls []bool
+	offset := dataOffset
+	for i := 0; i < count; i++ {
+		if bitmap != nil && bitmap[i>>3]&(1<<(i&0x07)) == 0 {
+			if nulls == nil {
+				nulls = make([]bool, count)
+			}
+			nulls[i] = true
+			continue
+		}
+		// A short varlena begins with a nonzero byte, while the padding before an aligned element is always zero
+		if elemType.length != -1 || (offset < len(array) && array[offset] == 0) {
+			for (offset-dataOffset)%alignmentOf(elemType.align) != 0 {
+				offset++
+			}
+		}
+		size := elemType.length
+		if size == -1 {
+			if offset >= len(array) {
+				return arrayElementType{}, nil, nil, fmt.Errorf("array data is truncated")
+			}
+			if size, _, err = varlenaHeader(array[offset:]); err != nil {
+				return arrayElementType{}, nil, nil, err
+			}
+		}
+		if offset+size > len(array) {
+			return arrayElementType{}, nil, nil, fmt.Errorf("array data is truncated")
+		}
+		elements[i] = array[offset : offset+size]
+		offset += size
+	}
+	return elemType, elements, nulls, nil
+}
+
+// alignmentOf returns the number of bytes that the given typalign aligns to.
+func alignmentOf(align byte) int {
+	switch align {
+	case 's':
+		return 2
+	case 'i':
+		return 4
+	case 'd':
+		return 8
+	default:
+		return 1
+	}
+}
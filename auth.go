@@ -0,0 +1,131 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+
+extern bool pgextHostIsSuperuser(uint32_t roleid);
+extern bool pgextHostHasPrivsOfRole(uint32_t member, uint32_t role);
+extern bool pgextHostRoleName(uint32_t roleid, char* name);
+extern uint32_t pgextHostRoleOID(char* name);
+
+static inline PgExtAuthProvider* NewHostAuthProvider() {
+	PgExtAuthProvider* provider = (PgExtAuthProvider*)malloc(sizeof(PgExtAuthProvider));
+	provider->is_superuser = (bool (*)(Oid))pgextHostIsSuperuser;
+	provider->has_privs_of_role = (bool (*)(Oid, Oid))pgextHostHasPrivsOfRole;
+	provider->role_name = (bool (*)(Oid, char*))pgextHostRoleName;
+	provider->role_oid = (Oid (*)(const char*))pgextHostRoleOID;
+	return provider;
+}
+*/
+import "C"
+import (
+	"sync"
+	"unsafe"
+)
+
+// BootstrapSuperuserID is the OID of the role that initializes a Postgres cluster. Sessions run as this role until the
+// host sets their role, and it's the only role that exists without an AuthProvider.
+const BootstrapSuperuserID uint32 = C.BOOTSTRAP_SUPERUSERID
+
+// AuthProvider describes the host's roles to extensions, which check privileges through functions such as superuser,
+// has_privs_of_role, and GetUserNameFromId. The role of each session is set through Session.SetUserID, while the
+// provider answers questions about any role.
+type AuthProvider interface {
+	// IsSuperuser returns whether the role is a superuser. Roles that do not exist are not superusers.
+	IsSuperuser(roleID uint32) bool
+	// HasPrivilegesOfRole returns whether the member has the privileges of the role, either directly or through the
+	// roles that it inherits from. This is not called for superusers, or when both roles are the same.
+	HasPrivilegesOfRole(memberID uint32, roleID uint32) bool
+	// RoleName returns the name of the role, or false if it does not exist.
+	RoleName(roleID uint32) (string, bool)
+	// RoleID returns the OID of the role with the given name, or false if it does not exist.
+	RoleID(name string) (uint32, bool)
+}
+
+var (
+	// currentAuthProvider describes the host's roles, or is nil if the bootstrap superuser is the only role.
+	currentAuthProvider AuthProvider
+	// authProviderMutex gates access to the provider.
+	authProviderMutex = &sync.RWMutex{}
+	// hostAuthProvider is the C struct that forwards to the Go provider.
+	hostAuthProvider = sync.OnceValue(func() *C.PgExtAuthProvider {
+		return C.NewHostAuthProvider()
+	})
+	shimSetAuthProvider     = newShimProc("pgext_set_auth_provider")
+	shimBackendStateSetUser = newShimProc("pgext_backend_state_set_user")
+)
+
+// SetAuthProvider sets the provider that extensions check privileges through. Setting nil leaves the bootstrap
+// superuser, named "postgres", as the only role, so every session is a superuser unless its role is set to another.
+func SetAuthProvider(provider AuthProvider) error {
+	authProviderMutex.Lock()
+	currentAuthProvider = provider
+	authProviderMutex.Unlock()
+	var providerPtr uintptr
+	if provider != nil {
+		providerPtr = uintptr(unsafe.Pointer(hostAuthProvider()))
+	}
+	_, err := shimSetAuthProvider.Call(providerPtr)
+	return err
+}
+
+// getAuthProvider returns the current auth provider.
+func getAuthProvider() AuthProvider {
+	authProviderMutex.RLock()
+	defer authProviderMutex.RUnlock()
+	return currentAuthProvider
+}
+
+//export pgextHostIsSuperuser
+func pgextHostIsSuperuser(roleID C.uint32_t) C.bool {
+	provider := getAuthProvider()
+	return C.bool(provider != nil && provider.IsSuperuser(uint32(roleID)))
+}
+
+//export pgextHostHasPrivsOfRole
+func pgextHostHasPrivsOfRole(memberID C.uint32_t, roleID C.uint32_t) C.bool {
+	provider := getAuthProvider()
+	return C.bool(provider != nil && provider.HasPrivilegesOfRole(uint32(memberID), uint32(roleID)))
+}
+
+//export pgextHostRoleName
+func pgextHostRoleName(roleID C.uint32_t, name *C.char) C.bool {
+	provider := getAuthProvider()
+	if provider == nil {
+		return false
+	}
+	roleName, ok := provider.RoleName(uint32(roleID))
+	if !ok {
+		return false
+	}
+	copyName((*[C.NAMEDATALEN]C.char)(unsafe.Pointer(name)), roleName)
+	return true
+}
+
+//export pgextHostRoleOID
+func pgextHostRoleOID(name *C.char) C.uint32_t {
+	provider := getAuthProvider()
+	if provider == nil {
+		return C.InvalidOid
+	}
+	roleID, ok := provider.RoleID(C.GoString(name))
+	if !ok {
+		return C.InvalidOid
+	}
+	return C.uint32_t(roleID)
+}
@@ -0,0 +1,116 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"maps"
+	"slices"
+)
+
+// AvailableExtension describes an extension that may be installed, which holds the data of a row of
+// pg_available_extensions.
+type AvailableExtension struct {
+	Name           string
+	DefaultVersion string
+	// InstalledVersion is always empty, as only the host knows which extensions are installed. The host should set it
+	// for each extension that is installed.
+	InstalledVersion string
+	Comment          string
+	// Versions are the versions of the extension that may be installed, sorted by version.
+	Versions []AvailableExtensionVersion
+}
+
+// AvailableExtensionVersion describes a version of an extension that may be installed, which holds the data of a row
+// of pg_available_extension_versions. The settings come from the control file as merged with the secondary control
+// file of the version.
+type AvailableExtensionVersion struct {
+	Name    string
+	Version string
+	// Installed is always false, as only the host knows which extensions are installed. The host should set it for the
+	// installed version of each extension.
+	Installed   bool
+	Superuser   bool
+	Trusted     bool
+	Relocatable bool
+	Schema      string
+	Requires    []string
+	Comment     string
+}
+
+// AvailableExtensions returns the catalog data of every given extension, sorted by name.
+func AvailableExtensions(extensions map[string]*ExtensionFiles) ([]AvailableExtension, error) {
+	available := make([]AvailableExtension, 0, len(extensions))
+	for _, name := range slices.Sorted(maps.Keys(extensions)) {
+		extension, err := extensions[name].Available()
+		if err != nil {
+			return nil, err
+		}
+		available = append(available, extension)
+	}
+	return available, nil
+}
+
+// Available returns the catalog data of the extension. As in Postgres, the available versions are those with an
+// install script, along with those that may be reached by updating an installable version.
+func (extFile *ExtensionFiles) Available() (AvailableExtension, error) {
+	control, err := extFile.LoadControl()
+	if err != nil {
+		return AvailableExtension{}, err
+	}
+	graph, err := extFile.loadUpdateGraph()
+	if err != nil {
+		return AvailableExtension{}, err
+	}
+	extension := AvailableExtension{
+		Name:           extFile.Name,
+		DefaultVersion: control.DefaultVersion,
+		Comment:        control.Comment,
+	}
+	for _, version := range graph.availableVersions() {
+		versionControl, err := extFile.LoadControlForVersion(version)
+		if err != nil {
+			return AvailableExtension{}, err
+		}
+		extension.Versions = append(extension.Versions, AvailableExtensionVersion{
+			Name:        extFile.Name,
+			Version:     version,
+			Superuser:   versionControl.Superuser,
+			Trusted:     versionControl.Trusted,
+			Relocatable: versionControl.Relocatable,
+			Schema:      versionControl.Schema,
+			Requires:    versionControl.Requires,
+			Comment:     versionControl.Comment,
+		})
+	}
+	return extension, nil
+}
+
+// availableVersions returns the versions that have an install script, or that may be reached through updates from a
+// version that has one, sorted by version.
+func (graph *updateGraph) availableVersions() []string {
+	available := maps.Clone(graph.installable)
+	queue := slices.Collect(maps.Keys(graph.installable))
+	for len(queue) > 0 {
+		version := queue[0]
+		queue = queue[1:]
+		for next := range graph.edges[version] {
+			if _, ok := available[next]; !ok {
+				available[next] = struct{}{}
+				queue = append(queue, next)
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(available))
+}
@@ -0,0 +1,176 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// BackendState is the state that Postgres would keep per backend process, such as the error state of the current
+// call. Postgres runs each session in its own process, so extensions freely use such state as though it were global.
+// Hosts run many sessions within a single process, so each session should have its own BackendState, and all calls
+// made on behalf of a session should be run through its BackendState.
+type BackendState struct {
+	mutex sync.Mutex
+	// cancelMutex gates running, along with the handle against being freed while Cancel uses it, as Cancel does not
+	// wait for runs.
+	cancelMutex sync.Mutex
+	running     bool
+	handle      uintptr
+}
+
+var (
+	shimBackendStateCreate      = newUnlockedShimProc("pgext_backend_state_create")
+	shimBackendStateDestroy     = newShimProc("pgext_backend_state_destroy")
+	shimBackendStateBind        = newShimProc("pgext_backend_state_bind")
+	shimBackendStateCancel      = newUnlockedShimProc("pgext_backend_state_cancel")
+	shimBackendStateClearCancel = newUnlockedShimProc("pgext_backend_state_clear_cancel")
+	shimBackendLockAcquire      = newUnlockedShimProc("pgext_backend_lock_acquire")
+	shimBackendLockRelease      = newUnlockedShimProc("pgext_backend_lock_release")
+	shimBackendLockYield        = newUnlockedShimProc("pgext_backend_lock_yield")
+	shimBackendLockResume       = newUnlockedShimProc("pgext_backend_lock_resume")
+)
+
+// NewBackendState returns a new BackendState. Close must be called once the state is no longer needed.
+func NewBackendState() (*BackendState, error) {
+	handle, err := shimBackendStateCreate.Call()
+	if err != nil {
+		return nil, err
+	}
+	if handle == 0 {
+		return nil, fmt.Errorf("out of memory while creating backend state")
+	}
+	return &BackendState{handle: handle}, nil
+}
+
+// Run binds the state to the current thread for the duration of the given function. Calls into extensions that are
+// made within the function will see this state. Extensions access globals such as CurrentMemoryContext directly, which
+// are shared by every thread, so only one session runs at a time: Run blocks until no other state is bound, and the
+// state only yields to others while its session waits, such as on a latch, an LWLock, or a background worker. Calls
+// made outside of Run use the thread's default state, and each one waits for the runs of other goroutines the same
+// way. The function must therefore not wait on another goroutine's Run, or on another goroutine's call into an
+// extension, as neither can begin until this run has ended.
+func (bs *BackendState) Run(f func()) error {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	if bs.handle == 0 {
+		return fmt.Errorf("backend state has been closed")
+	}
+	bs.setRunning(true)
+	defer bs.setRunning(false)
+	// The state is bound to the thread, so we must remain on the same thread until it has been unbound
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	previous := shimBackendStateBind.MustCall(bs.handle)
+	defer shimBackendStateBind.MustCall(previous)
+	f()
+	return nil
+}
+
+// lockBackend acquires the backend lock that Run holds, returning the function that releases it. The lock is reentrant
+// for the thread that holds it, so this may be called from within Run on the same goroutine, but it blocks until the
+// runs of other goroutines wait or end. Every call into the shim also takes the lock, so a mutex that's held across such
+// calls must be locked after this. When the shim cannot be loaded there is nothing to serialize, and the returned
+// function does nothing.
+func lockBackend() (unlock func()) {
+	if _, err := shimBackendLockAcquire.addr(); err != nil {
+		return func() {}
+	}
+	// The lock is held by the thread, so we must remain on the same thread until it has been released
+	runtime.LockOSThread()
+	shimBackendLockAcquire.MustCall()
+	return func() {
+		shimBackendLockRelease.MustCall()
+		runtime.UnlockOSThread()
+	}
+}
+
+// RunContext is the same as Run, except that the run is canceled once the context is done, as Cancel does. Returns the
+// context's error without running the function if the context is already done.
+func (bs *BackendState) RunContext(ctx context.Context, f func()) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return bs.Run(func() {
+		stop := context.AfterFunc(ctx, func() {
+			_ = bs.Cancel()
+		})
+		defer stop()
+		f()
+	})
+}
+
+// Cancel cancels the run that is in progress, which may be called from any goroutine. The extension that is running
+// raises an error with the SQLSTATE 57014 (query_canceled) once it next checks for interrupts through
+// CHECK_FOR_INTERRUPTS, or once it next waits on its latch and checks afterward, the same as when a Postgres backend
+// is sent a cancel request. Extensions that never check for interrupts run until they return. Canceling a state that
+// is not running has no effect, and a cancellation that the run ends before processing is discarded.
+func (bs *BackendState) Cancel() error {
+	bs.cancelMutex.Lock()
+	defer bs.cancelMutex.Unlock()
+	if bs.handle == 0 {
+		return fmt.Errorf("backend state has been closed")
+	}
+	if !bs.running {
+		return nil
+	}
+	_, err := shimBackendStateCancel.Call(bs.handle)
+	return err
+}
+
+// setRunning records whether a run is in progress. A cancellation only applies to the run that it was sent during, so
+// one that the run ended before processing is discarded.
+func (bs *BackendState) setRunning(running bool) {
+	bs.cancelMutex.Lock()
+	defer bs.cancelMutex.Unlock()
+	bs.running = running
+	if !running {
+		shimBackendStateClearCancel.MustCall(bs.handle)
+	}
+}
+
+// SetUserID sets the role that the session runs as, which extensions see through GetUserId and superuser. This is
+// called once the session has authenticated, and again whenever the host changes the session's role, which undoes any
+// change to the current role that an extension made. Sessions run as the bootstrap superuser until this is called.
+// This may not be called from within Run.
+func (bs *BackendState) SetUserID(roleID uint32) error {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	if bs.handle == 0 {
+		return fmt.Errorf("backend state has been closed")
+	}
+	_, err := shimBackendStateSetUser.Call(bs.handle, uintptr(roleID))
+	return err
+}
+
+// Close frees the state. The state may not be used afterward.
+func (bs *BackendState) Close() error {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	if bs.handle == 0 {
+		return nil
+	}
+	// The handle is cleared before it's freed so that Cancel does not use it, as freeing waits for the backend lock, which
+	// a run may hold while it cancels another state
+	bs.cancelMutex.Lock()
+	handle := bs.handle
+	bs.handle = 0
+	bs.cancelMutex.Unlock()
+	_, err := shimBackendStateDestroy.Call(handle)
+	return err
+}
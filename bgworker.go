@@ -0,0 +1,574 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+
+extern uint64_t pgextHostRegisterBackgroundWorker(BackgroundWorker* worker, bool dynamic);
+extern int pgextHostBackgroundWorkerStatus(uint64_t id, int wait, pid_t* pid);
+extern void pgextHostTerminateBackgroundWorker(uint64_t id);
+
+static inline PgExtBackgroundWorkerManager* NewHostBackgroundWorkerManager() {
+	PgExtBackgroundWorkerManager* manager = (PgExtBackgroundWorkerManager*)malloc(sizeof(PgExtBackgroundWorkerManager));
+	manager->register_worker = (uint64_t (*)(const BackgroundWorker*, bool))pgextHostRegisterBackgroundWorker;
+	manager->status = pgextHostBackgroundWorkerStatus;
+	manager->terminate = pgextHostTerminateBackgroundWorker;
+	return manager;
+}
+*/
+import "C"
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// BackgroundWorkerStartTime is the point of the server's startup at which Postgres would start a worker. The host
+// decides when static workers start through StartBackgroundWorkers, so this is only informational.
+type BackgroundWorkerStartTime int32
+
+const (
+	BackgroundWorkerStartPostmasterStart BackgroundWorkerStartTime = iota
+	BackgroundWorkerStartConsistentState
+	BackgroundWorkerStartRecoveryFinished
+)
+
+// BackgroundWorker describes a background worker that an extension registered. Static workers are registered through
+// RegisterBackgroundWorker from the _PG_init of a library that was loaded with WithSharedPreload, while dynamic workers
+// are registered through RegisterDynamicBackgroundWorker at any time.
+type BackgroundWorker struct {
+	// ID identifies the worker to the functions of this package.
+	ID   uint64
+	Name string
+	Type string
+	// LibraryName is the library that contains the worker's main function, as the extension named it, such as
+	// "pg_cron" or "$libdir/pg_cron". The library must be loaded whenever the worker is started.
+	LibraryName  string
+	FunctionName string
+	Flags        int32
+	StartTime    BackgroundWorkerStartTime
+	// RestartInterval is how long the worker waits to be restarted after it fails, which is negative for workers that
+	// are never restarted.
+	RestartInterval time.Duration
+	MainArg         Datum
+	Extra           []byte
+	Dynamic         bool
+}
+
+// BackgroundWorkerState is the state of a background worker.
+type BackgroundWorkerState uint8
+
+const (
+	// BackgroundWorkerRegistered is a static worker that has not yet been started.
+	BackgroundWorkerRegistered BackgroundWorkerState = iota
+	BackgroundWorkerRunning
+	// BackgroundWorkerRestarting is a worker that has exited and is waiting to be started again.
+	BackgroundWorkerRestarting
+	BackgroundWorkerStopped
+)
+
+// String returns the name of the state.
+func (state BackgroundWorkerState) String() string {
+	switch state {
+	case BackgroundWorkerRegistered:
+		return "registered"
+	case BackgroundWorkerRunning:
+		return "running"
+	case BackgroundWorkerRestarting:
+		return "restarting"
+	case BackgroundWorkerStopped:
+		return "stopped"
+	default:
+		return fmt.Sprintf("BackgroundWorkerState(%d)", uint8(state))
+	}
+}
+
+// BackgroundWorkerStatus is the status of a background worker at some point in time.
+type BackgroundWorkerStatus struct {
+	BackgroundWorker
+	State BackgroundWorkerState
+	// PID identifies the current run of the worker, which extensions see in place of the worker's process ID. It is
+	// zero while the worker is not running.
+	PID int32
+	// Starts is the number of times that the worker has been started.
+	Starts int
+	// ExitCode is the code that the most recent run exited with, which is 1 when it raised an error.
+	ExitCode int
+	// Err is the error that ended the most recent run, which is nil when the worker returned or called proc_exit.
+	Err error
+}
+
+// BackgroundWorkerRestartPolicy decides whether a worker that has exited is started again, and how long it waits
+// beforehand. It is not consulted for workers that were stopped, either by the host or by an extension through
+// TerminateBackgroundWorker.
+type BackgroundWorkerRestartPolicy func(status BackgroundWorkerStatus) (restart bool, delay time.Duration)
+
+// DefaultBackgroundWorkerRestartPolicy restarts workers the same as Postgres. A worker that exits with code 0 is done,
+// while a worker that exits with any other code, or raises an error, is restarted once its restart interval has passed,
+// unless it's never restarted.
+func DefaultBackgroundWorkerRestartPolicy(status BackgroundWorkerStatus) (bool, time.Duration) {
+	if (status.ExitCode == 0 && status.Err == nil) || status.RestartInterval < 0 {
+		return false, 0
+	}
+	return true, status.RestartInterval
+}
+
+// backgroundWorker is a worker that is managed by the host.
+type backgroundWorker struct {
+	info BackgroundWorker
+	// entry is the worker as it was registered, which is given to the worker as MyBgworkerEntry.
+	entry *C.BackgroundWorker
+	// The following are gated by backgroundWorkersMutex.
+	state    BackgroundWorkerState
+	pid      int32
+	starts   int
+	exitCode int
+	err      error
+	// libraryPath is the path of the library that the worker's main function was last found within.
+	libraryPath string
+	// backend is the state of the current run, which is nil while the worker is not running.
+	backend *BackendState
+	// runDone is closed once the current run has ended.
+	runDone chan struct{}
+	// active is set from the time that the worker is started until its goroutine has returned.
+	active   bool
+	stopping bool
+	// stop is closed once the worker is stopped, and done is closed once its goroutine has returned.
+	stop chan struct{}
+	done chan struct{}
+}
+
+var (
+	// backgroundWorkers contains every worker that has been registered and not removed, keyed by their ID. Dynamic
+	// workers are removed once they've stopped, as Postgres unregisters them.
+	backgroundWorkers = make(map[uint64]*backgroundWorker)
+	// backgroundWorkersMutex gates access to the workers, as they're registered and supervised from any goroutine.
+	backgroundWorkersMutex = &sync.Mutex{}
+	// backgroundWorkersCond is broadcast whenever the state of a worker changes.
+	backgroundWorkersCond = sync.NewCond(backgroundWorkersMutex)
+	// backgroundWorkersStarted is set once the host has called StartBackgroundWorkers, after which static workers start
+	// as soon as they're registered.
+	backgroundWorkersStarted bool
+	// nextBackgroundWorkerID is the ID of the next worker to be registered.
+	nextBackgroundWorkerID uint64 = 1
+	// nextBackgroundWorkerPID is the PID of the next run of any worker.
+	nextBackgroundWorkerPID int32 = 1
+	// backgroundWorkerRestartPolicy decides whether workers are restarted, and backgroundWorkerObserver is told of every
+	// change to a worker's state. Both are gated by backgroundWorkerHooksMutex.
+	backgroundWorkerRestartPolicy BackgroundWorkerRestartPolicy = DefaultBackgroundWorkerRestartPolicy
+	backgroundWorkerObserver      func(status BackgroundWorkerStatus)
+	backgroundWorkerHooksMutex    = &sync.RWMutex{}
+	// hostBackgroundWorkerManager is the C struct that forwards to the Go manager.
+	hostBackgroundWorkerManager = sync.OnceValue(func() *C.PgExtBackgroundWorkerManager {
+		return C.NewHostBackgroundWorkerManager()
+	})
+	// registerBackgroundWorkerManager gives the manager to the shim, which must be done before any library is
+	// initialized, as libraries register their workers within _PG_init.
+	registerBackgroundWorkerManager = sync.OnceValue(func() error {
+		_, err := shimSetBackgroundWorkerManager.Call(uintptr(unsafe.Pointer(hostBackgroundWorkerManager())))
+		return err
+	})
+	shimSetBackgroundWorkerManager = newShimProc("pgext_set_bgworker_manager")
+	shimSetSharedPreload           = newShimProc("pgext_set_shared_preload")
+	shimBackgroundWorkerMain       = newShimProc("pgext_bgworker_main")
+	shimSignalBackend              = newUnlockedShimProc("pgext_signal_backend")
+)
+
+// BackgroundWorkers returns the status of every worker that is registered, ordered by their IDs.
+func BackgroundWorkers() []BackgroundWorkerStatus {
+	backgroundWorkersMutex.Lock()
+	defer backgroundWorkersMutex.Unlock()
+	statuses := make([]BackgroundWorkerStatus, 0, len(backgroundWorkers))
+	for _, w := range backgroundWorkers {
+		statuses = append(statuses, w.status())
+	}
+	slices.SortFunc(statuses, func(a, b BackgroundWorkerStatus) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return statuses
+}
+
+// StartBackgroundWorkers starts every static worker that has not yet been started, which the host calls once it's
+// ready to serve the sessions that workers run alongside of, the same as the postmaster once it has started. Static
+// workers that are registered afterward are started immediately. Dynamic workers are always started as soon as
+// they're registered.
+func StartBackgroundWorkers() {
+	backgroundWorkersMutex.Lock()
+	defer backgroundWorkersMutex.Unlock()
+	backgroundWorkersStarted = true
+	for _, w := range backgroundWorkers {
+		if w.state == BackgroundWorkerRegistered && !w.active {
+			w.start()
+		}
+	}
+}
+
+// StartBackgroundWorker starts the worker with the given ID, which may be a static worker that has stopped. Returns an
+// error if the worker does not exist or is already active.
+func StartBackgroundWorker(id uint64) error {
+	backgroundWorkersMutex.Lock()
+	defer backgroundWorkersMutex.Unlock()
+	w, ok := backgroundWorkers[id]
+	if !ok {
+		return fmt.Errorf("background worker `%d` does not exist", id)
+	}
+	if w.active {
+		return fmt.Errorf("background worker `%s` is already running", w.info.Name)
+	}
+	w.start()
+	return nil
+}
+
+// StopBackgroundWorker stops the worker with the given ID, waiting until it has exited. The worker is sent SIGTERM,
+// which it sees the next time that it waits on its latch, so a worker that never waits is never stopped. The worker is
+// not restarted afterward. Returns an error if the worker does not exist.
+func StopBackgroundWorker(id uint64) error {
+	backgroundWorkersMutex.Lock()
+	w, ok := backgroundWorkers[id]
+	if !ok {
+		backgroundWorkersMutex.Unlock()
+		return fmt.Errorf("background worker `%d` does not exist", id)
+	}
+	done := w.stopLocked()
+	backgroundWorkersMutex.Unlock()
+	if done != nil {
+		<-done
+	}
+	return nil
+}
+
+// StopBackgroundWorkers stops every worker, waiting until they've all exited, which the host should call before it
+// exits. Static workers remain registered, and are not started again by StartBackgroundWorkers.
+func StopBackgroundWorkers() {
+	backgroundWorkersMutex.Lock()
+	var dones []chan struct{}
+	for _, w := range backgroundWorkers {
+		if done := w.stopLocked(); done != nil {
+			dones = append(dones, done)
+		}
+	}
+	backgroundWorkersMutex.Unlock()
+	for _, done := range dones {
+		<-done
+	}
+}
+
+// SetBackgroundWorkerRestartPolicy sets the policy that decides whether workers are restarted after they exit. Setting
+// nil restores DefaultBackgroundWorkerRestartPolicy.
+func SetBackgroundWorkerRestartPolicy(policy BackgroundWorkerRestartPolicy) {
+	if policy == nil {
+		policy = DefaultBackgroundWorkerRestartPolicy
+	}
+	backgroundWorkerHooksMutex.Lock()
+	defer backgroundWorkerHooksMutex.Unlock()
+	backgroundWorkerRestartPolicy = policy
+}
+
+// SetBackgroundWorkerObserver sets the function that is called whenever a worker is started, exits, or stops, so that
+// the host may supervise its workers, such as by logging the errors that they exit with. The observer is called from
+// the goroutine of the worker, and must not block. Setting nil removes the observer.
+func SetBackgroundWorkerObserver(observer func(status BackgroundWorkerStatus)) {
+	backgroundWorkerHooksMutex.Lock()
+	defer backgroundWorkerHooksMutex.Unlock()
+	backgroundWorkerObserver = observer
+}
+
+// notifyBackgroundWorkerObserver gives the status to the observer, if there is one.
+func notifyBackgroundWorkerObserver(status BackgroundWorkerStatus) {
+	backgroundWorkerHooksMutex.RLock()
+	observer := backgroundWorkerObserver
+	backgroundWorkerHooksMutex.RUnlock()
+	if observer != nil {
+		observer(status)
+	}
+}
+
+// status returns the worker's status. The backgroundWorkersMutex must be held.
+func (w *backgroundWorker) status() BackgroundWorkerStatus {
+	return BackgroundWorkerStatus{
+		BackgroundWorker: w.info,
+		State:            w.state,
+		PID:              w.pid,
+		Starts:           w.starts,
+		ExitCode:         w.exitCode,
+		Err:              w.err,
+	}
+}
+
+// setState changes the state of the worker, returning its new status. The backgroundWorkersMutex must be held.
+func (w *backgroundWorker) setState(state BackgroundWorkerState) BackgroundWorkerStatus {
+	w.state = state
+	backgroundWorkersCond.Broadcast()
+	return w.status()
+}
+
+// start starts the worker on its own goroutine. The backgroundWorkersMutex must be held.
+func (w *backgroundWorker) start() {
+	w.active = true
+	w.stopping = false
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.run(w.stop, w.done)
+}
+
+// stopLocked stops the worker, returning the channel that is closed once it has exited, or nil if it was not active.
+// The backgroundWorkersMutex must be held.
+func (w *backgroundWorker) stopLocked() chan struct{} {
+	if !w.active {
+		return nil
+	}
+	if !w.stopping {
+		w.stopping = true
+		close(w.stop)
+		if w.backend != nil {
+			_, _ = shimSignalBackend.Call(w.backend.handle, uintptr(C.SIGTERM))
+		}
+	}
+	return w.done
+}
+
+// run runs the worker until it's stopped, or until it exits without being restarted.
+func (w *backgroundWorker) run(stop chan struct{}, done chan struct{}) {
+	defer close(done)
+	for {
+		exitCode, err := w.runOnce()
+		backgroundWorkersMutex.Lock()
+		w.pid = 0
+		w.exitCode = exitCode
+		w.err = err
+		stopping := w.stopping
+		// Until the policy has decided, the worker is treated as though it will be restarted, so that extensions that
+		// wait on its shutdown do not see it stop only to start again
+		status := w.setState(BackgroundWorkerRestarting)
+		backgroundWorkersMutex.Unlock()
+
+		restart, delay := false, time.Duration(0)
+		if !stopping {
+			backgroundWorkerHooksMutex.RLock()
+			policy := backgroundWorkerRestartPolicy
+			backgroundWorkerHooksMutex.RUnlock()
+			restart, delay = policy(status)
+		}
+		if restart {
+			notifyBackgroundWorkerObserver(status)
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+				continue
+			case <-stop:
+				timer.Stop()
+			}
+		}
+		w.finish()
+		return
+	}
+}
+
+// finish marks the worker as stopped, which removes dynamic workers.
+func (w *backgroundWorker) finish() {
+	backgroundWorkersMutex.Lock()
+	w.active = false
+	status := w.setState(BackgroundWorkerStopped)
+	if w.info.Dynamic {
+		delete(backgroundWorkers, w.info.ID)
+		C.free(unsafe.Pointer(w.entry))
+		w.entry = nil
+	}
+	backgroundWorkersMutex.Unlock()
+	notifyBackgroundWorkerObserver(status)
+}
+
+// runOnce runs the worker's main function within a backend state of its own, returning the code that it exited with.
+// The run takes the backend lock the same as any session's, which the worker yields whenever it waits. Workers use a
+// BackendState rather than a Session, so they see the host-wide configuration values.
+func (w *backgroundWorker) runOnce() (exitCode int, err error) {
+	mainPtr, lib, err := findBackgroundWorkerMain(w.info.LibraryName, w.info.FunctionName)
+	if err != nil {
+		return 1, err
+	}
+	backend, err := NewBackendState()
+	if err != nil {
+		return 1, err
+	}
+	defer func() {
+		err = errors.Join(err, backend.Close())
+	}()
+	cExitCode := (*C.int)(C.malloc(C.sizeof_int))
+	defer C.free(unsafe.Pointer(cExitCode))
+	*cExitCode = 0
+
+	backgroundWorkersMutex.Lock()
+	if w.stopping {
+		backgroundWorkersMutex.Unlock()
+		return 0, nil
+	}
+	w.libraryPath = lib.path
+	w.backend = backend
+	w.runDone = make(chan struct{})
+	w.pid = nextBackgroundWorkerPID
+	nextBackgroundWorkerPID++
+	w.starts++
+	pid := w.pid
+	status := w.setState(BackgroundWorkerRunning)
+	backgroundWorkersMutex.Unlock()
+	notifyBackgroundWorkerObserver(status)
+
+	lib.accounting.addBackgroundWorkers(1)
+	defer lib.accounting.addBackgroundWorkers(-1)
+	// The worker reports the same process ID to itself as its handle reports to others
+	runErr := backend.SetSessionInfo(SessionInfo{ProcessID: pid})
+	if runErr == nil {
+		runErr = backend.Run(func() {
+			var edata uintptr
+			edata, err = shimBackgroundWorkerMain.Call(uintptr(unsafe.Pointer(w.entry)), mainPtr,
+				uintptr(unsafe.Pointer(cExitCode)))
+			if err == nil && edata != 0 {
+				err = newCallError(edata)
+			}
+		})
+	}
+	backgroundWorkersMutex.Lock()
+	w.backend = nil
+	close(w.runDone)
+	backgroundWorkersMutex.Unlock()
+	if runErr != nil {
+		return 1, runErr
+	}
+	if err != nil {
+		return 1, err
+	}
+	return int(*cExitCode), nil
+}
+
+// findBackgroundWorkerMain returns the address of the worker's main function, along with the library that contains it.
+// The library must already be loaded.
+func findBackgroundWorkerMain(libName string, funcName string) (uintptr, *Library, error) {
+	loadedLibrariesMutex.Lock()
+	defer loadedLibrariesMutex.Unlock()
+	if libName == "postgres" {
+		return 0, nil, fmt.Errorf("background worker function `%s` is internal to Postgres, which is not supported",
+			funcName)
+	}
+	name := libraryName(strings.TrimPrefix(libName, "$libdir/"))
+	for path, lib := range loadedLibraries {
+		if path != libName && libraryName(path) != name {
+			continue
+		}
+		if lib.wasm != nil {
+			return 0, nil, fmt.Errorf("library `%s` was compiled to WebAssembly, which cannot run background workers",
+				libName)
+		}
+		mainPtr, err := lib.internal.Lookup(funcName)
+		if err != nil {
+			return 0, nil, fmt.Errorf("background worker function `%s` was not found in library `%s`", funcName,
+				libName)
+		}
+		return mainPtr, lib, nil
+	}
+	return 0, nil, fmt.Errorf("background worker library `%s` has not been loaded", libName)
+}
+
+// stopLibraryBackgroundWorkers stops the workers that are running the library at the given path, waiting until their
+// current runs have ended, so that the library may be unloaded. This expects the library mutex to be held, so workers
+// that are waiting on the mutex to find their library are only stopped, as they fail to find it once it's unloaded.
+func stopLibraryBackgroundWorkers(path string) {
+	backgroundWorkersMutex.Lock()
+	var runs []chan struct{}
+	for _, w := range backgroundWorkers {
+		if w.libraryPath != path || !w.active {
+			continue
+		}
+		w.stopLocked()
+		if w.backend != nil {
+			runs = append(runs, w.runDone)
+		}
+	}
+	backgroundWorkersMutex.Unlock()
+	for _, run := range runs {
+		<-run
+	}
+}
+
+//export pgextHostRegisterBackgroundWorker
+func pgextHostRegisterBackgroundWorker(worker *C.BackgroundWorker, dynamic C.bool) C.uint64_t {
+	entry := (*C.BackgroundWorker)(C.malloc(C.sizeof_BackgroundWorker))
+	*entry = *worker
+	restartInterval := time.Duration(entry.bgw_restart_time) * time.Second
+	if entry.bgw_restart_time == C.BGW_NEVER_RESTART {
+		restartInterval = -1
+	}
+	backgroundWorkersMutex.Lock()
+	defer backgroundWorkersMutex.Unlock()
+	w := &backgroundWorker{
+		info: BackgroundWorker{
+			ID:              nextBackgroundWorkerID,
+			Name:            C.GoString(&entry.bgw_name[0]),
+			Type:            C.GoString(&entry.bgw_type[0]),
+			LibraryName:     C.GoString(&entry.bgw_library_name[0]),
+			FunctionName:    C.GoString(&entry.bgw_function_name[0]),
+			Flags:           int32(entry.bgw_flags),
+			StartTime:       BackgroundWorkerStartTime(entry.bgw_start_time),
+			RestartInterval: restartInterval,
+			MainArg:         Datum(entry.bgw_main_arg),
+			Extra:           C.GoBytes(unsafe.Pointer(&entry.bgw_extra[0]), C.BGW_EXTRALEN),
+			Dynamic:         bool(dynamic),
+		},
+		entry: entry,
+	}
+	nextBackgroundWorkerID++
+	backgroundWorkers[w.info.ID] = w
+	if w.info.Dynamic || backgroundWorkersStarted {
+		w.start()
+	}
+	return C.uint64_t(w.info.ID)
+}
+
+//export pgextHostBackgroundWorkerStatus
+func pgextHostBackgroundWorkerStatus(id C.uint64_t, wait C.int, pid *C.pid_t) C.int {
+	backgroundWorkersMutex.Lock()
+	defer backgroundWorkersMutex.Unlock()
+	for {
+		// Dynamic workers are removed once they've stopped
+		w, ok := backgroundWorkers[uint64(id)]
+		if !ok || w.state == BackgroundWorkerStopped {
+			return C.BGWH_STOPPED
+		}
+		if w.state == BackgroundWorkerRunning {
+			if wait != C.PGEXT_BGWORKER_WAIT_SHUTDOWN {
+				*pid = C.pid_t(w.pid)
+				return C.BGWH_STARTED
+			}
+		} else if wait == C.PGEXT_BGWORKER_NO_WAIT {
+			return C.BGWH_NOT_YET_STARTED
+		}
+		backgroundWorkersCond.Wait()
+	}
+}
+
+//export pgextHostTerminateBackgroundWorker
+func pgextHostTerminateBackgroundWorker(id C.uint64_t) {
+	backgroundWorkersMutex.Lock()
+	defer backgroundWorkersMutex.Unlock()
+	if w, ok := backgroundWorkers[uint64(id)]; ok {
+		w.stopLocked()
+	}
+}
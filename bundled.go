@@ -0,0 +1,169 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// bundledSource is a set of extensions that have been embedded into the host binary.
+type bundledSource struct {
+	controlFS fs.FS
+	libraryFS fs.FS
+}
+
+var (
+	// bundledSources contains all of the registered bundled extension sources, in the order they were registered.
+	bundledSources []bundledSource
+	// bundledSourcesMutex gates access to the bundled extension sources.
+	bundledSourcesMutex = &sync.Mutex{}
+)
+
+// RegisterBundledExtensions registers extensions that have been embedded into the host binary (generally through
+// go:embed), so that they're available even when there is no local Postgres installation. The control and SQL files
+// should be at the root of controlFS. Libraries may either be at the root of libraryFS, or within a directory named
+// after the platform (such as "linux_amd64"), in which case the directory matching the current platform is used.
+// libraryFS may be nil for extensions that are only made up of SQL. Extensions from a local installation take
+// precedence over bundled extensions with the same name.
+func RegisterBundledExtensions(controlFS fs.FS, libraryFS fs.FS) error {
+	if controlFS == nil {
+		return fmt.Errorf("bundled extensions must have a control filesystem")
+	}
+	if libraryFS == nil {
+		libraryFS = emptyFS{}
+	} else if platformFS, err := fs.Sub(libraryFS, runtime.GOOS+"_"+runtime.GOARCH); err == nil {
+		if _, err = fs.Stat(platformFS, "."); err == nil {
+			libraryFS = platformFS
+		}
+	}
+	bundledSourcesMutex.Lock()
+	defer bundledSourcesMutex.Unlock()
+	bundledSources = append(bundledSources, bundledSource{
+		controlFS: controlFS,
+		libraryFS: libraryFS,
+	})
+	return nil
+}
+
+// loadBundledExtensions loads information for all registered bundled extensions. Sources that were registered earlier
+// take precedence over later sources.
+func loadBundledExtensions() (map[string]*ExtensionFiles, error) {
+	bundledSourcesMutex.Lock()
+	defer bundledSourcesMutex.Unlock()
+	extensionFiles := make(map[string]*ExtensionFiles)
+	for i := len(bundledSources) - 1; i >= 0; i-- {
+		sourceFiles, err := LoadExtensionsFS(bundledSources[i].controlFS, bundledSources[i].libraryFS)
+		if err != nil {
+			return nil, err
+		}
+		for name, extFile := range sourceFiles {
+			extensionFiles[name] = extFile
+		}
+	}
+	return extensionFiles, nil
+}
+
+// loadBundledExtension loads information for the named extension from the first registered bundled source that
+// contains it. Returns a LoadError with ErrExtensionNotAvailable when no source contains it.
+func loadBundledExtension(name string) (*ExtensionFiles, error) {
+	bundledSourcesMutex.Lock()
+	defer bundledSourcesMutex.Unlock()
+	for _, source := range bundledSources {
+		extFile, err := LoadExtensionFS(name, source.controlFS, source.libraryFS)
+		if !errors.Is(err, ErrExtensionNotAvailable) {
+			return extFile, err
+		}
+	}
+	return nil, &LoadError{
+		Kind:      ErrExtensionNotAvailable,
+		Extension: name,
+		File:      name + ".control",
+	}
+}
+
+// extractLibrary writes the library from the given filesystem to the local cache directory, since the
+// operating system can only load libraries that exist on the local filesystem. The directory is named after the hash of
+// the library's contents, so it's only written once per unique library, and is written again if the file on disk no
+// longer matches the hash. Returns the directory of the library.
+func extractLibrary(libraryFS fs.FS, libName string) (string, error) {
+	data, err := fs.ReadFile(libraryFS, libName)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	libDir := filepath.Join(cacheDirectory(), hex.EncodeToString(hash[:8]))
+	libPath := filepath.Join(libDir, filepath.Base(libName))
+	// The existing library is verified against the checksum, since a partially-written or modified library could crash
+	// the process once loaded
+	if existing, err := os.ReadFile(libPath); err == nil && sha256.Sum256(existing) == hash {
+		return libDir, nil
+	}
+	if err = os.MkdirAll(libDir, 0755); err != nil {
+		return "", err
+	}
+	// We write to a temporary file first so that a concurrent process never sees a partially-written library
+	tempFile, err := os.CreateTemp(libDir, filepath.Base(libName)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	_, err = tempFile.Write(data)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFile.Name(), libPath)
+	}
+	if err != nil {
+		_ = os.Remove(tempFile.Name())
+		return "", err
+	}
+	return libDir, nil
+}
+
+// cacheDirectory returns the directory that holds the files that are cached by this package, which is within the user's
+// cache directory when there is one.
+func cacheDirectory() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	return filepath.Join(cacheDir, "pg_extension")
+}
+
+// emptyFS is a filesystem that contains nothing.
+type emptyFS struct{}
+
+var _ fs.ReadDirFS = emptyFS{}
+
+// Open implements the interface fs.FS.
+func (emptyFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir implements the interface fs.ReadDirFS.
+func (emptyFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == "." {
+		return nil, nil
+	}
+	return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+}
@@ -0,0 +1,145 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+)
+
+// ResultType describes how a function's result is stored, so that it may be copied out of the call's memory context.
+// This mirrors the typbyval and typlen columns of pg_type.
+type ResultType struct {
+	// ByValue is true for types that are stored within the Datum itself.
+	ByValue bool
+	// Length is the size of types that are stored by reference. A length of -1 is a varlena, while -2 is a
+	// null-terminated C string.
+	Length int16
+}
+
+var (
+	// ResultTypeByValue is the result type of functions that return integers, booleans, and other types that fit within a
+	// Datum.
+	ResultTypeByValue = ResultType{ByValue: true}
+	// ResultTypeVarlena is the result type of functions that return text, bytea, and other variable-length types.
+	ResultTypeVarlena = ResultType{Length: -1}
+	// ResultTypeCString is the result type of functions that return cstring, such as type output functions.
+	ResultTypeCString = ResultType{Length: -2}
+)
+
+// CallResult is the result of a call that was made within its own memory context. The result is owned by Go, and
+// therefore remains valid after the memory context has been freed.
+type CallResult struct {
+	// Value is the result of functions that return their type by value.
+	Value Datum
+	// Data is a copy of the result of functions that return their type by reference. Varlena results include their
+	// header, while C string results exclude their terminator.
+	Data   []byte
+	IsNull bool
+}
+
+var (
+	shimCallContextBegin = newShimProc("pgext_call_context_begin")
+	shimCallContextEnd   = newShimProc("pgext_call_context_end")
+)
+
+// CallFmgrFunctionScoped calls the given function within its own memory context, which is freed once the result has
+// been copied out of it. Functions freely allocate memory that they expect to be freed along with the context, so hosts
+// that make many calls should prefer this over CallFmgrFunction.
+func CallFmgrFunctionScoped(fn uintptr, resultType ResultType, args ...NullableDatum) (CallResult, error) {
+	return callInContext(resultType, func() (Datum, bool, error) {
+		return callFmgrFunction(fn, 0, args...)
+	})
+}
+
+// CallScoped is the same as CallFmgrFunctionScoped, except that the call is recorded against the resource usage of the
+// function's library.
+func (f Function) CallScoped(resultType ResultType, args ...NullableDatum) (CallResult, error) {
+	return f.CallScopedColl(0, resultType, args...)
+}
+
+// CallScopedColl is the same as CallScoped, except that the function is called with the given collation.
+func (f Function) CallScopedColl(collation uint32, resultType ResultType, args ...NullableDatum) (CallResult, error) {
+	var allocated int64
+	result, err := callInContext(resultType, func() (Datum, bool, error) {
+		result, isNull, callAllocated, err := f.call(collation, args...)
+		allocated = callAllocated
+		return result, isNull, err
+	})
+	// Everything that the call allocated was freed along with its context
+	if f.library != nil {
+		f.library.accounting.addPallocBytes(-allocated)
+	}
+	return result, err
+}
+
+// callInContext runs the given call within its own memory context, copying its result out before the context is freed.
+func callInContext(resultType ResultType, call func() (Datum, bool, error)) (CallResult, error) {
+	// The current memory context belongs to the state of the current thread, so we must remain on the same thread until
+	// the context has ended
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	callContext, err := shimCallContextBegin.Call()
+	if err != nil {
+		return CallResult{}, err
+	}
+	if callContext == 0 {
+		return CallResult{}, fmt.Errorf("out of memory while creating the memory context for a call")
+	}
+	defer shimCallContextEnd.MustCall(callContext)
+	result, isNull, err := call()
+	if err != nil {
+		return CallResult{}, err
+	}
+	return copyCallResult(result, isNull, resultType)
+}
+
+// copyCallResult copies the result of a call into Go memory.
+func copyCallResult(result Datum, isNull bool, resultType ResultType) (CallResult, error) {
+	if resultType.ByValue {
+		return CallResult{Value: result, IsNull: isNull}, nil
+	}
+	if isNull || result == 0 {
+		return CallResult{IsNull: true}, nil
+	}
+	var length int
+	switch {
+	case resultType.Length > 0:
+		length = int(resultType.Length)
+	case resultType.Length == -1:
+		var err error
+		if length, err = datumVarSize(result); err != nil {
+			return CallResult{}, err
+		}
+	case resultType.Length == -2:
+		length = datumCStringLen(result)
+	default:
+		return CallResult{}, fmt.Errorf("invalid result type length: %d", resultType.Length)
+	}
+	return CallResult{Data: copyFromDatum(result, length)}, nil
+}
+
+// clone returns a copy of the result that does not share its data.
+func (r CallResult) clone() CallResult {
+	r.Data = bytes.Clone(r.Data)
+	return r
+}
+
+// Text returns the text of a varlena result, such as from a function that returns text.
+func (r CallResult) Text() (string, error) {
+	data, err := VarlenaData(r.Data)
+	return string(data), err
+}
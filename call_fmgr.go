@@ -12,45 +12,343 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package pg_extension
 
 /*
 #cgo CFLAGS: "-I${SRCDIR}/library"
+#include <string.h>
 #include "exports.h"
 
-static inline Datum CallFmgrFunctionC(FunctionCallInfo fcinfo) {
-    return ((PGFunction)fcinfo->flinfo->fn_addr)(fcinfo);
+static inline void SetFmgrInfoAddr(FmgrInfo* finfo, uintptr_t fn) {
+	finfo->fn_addr = (void*)fn;
+}
+
+static inline void CopyErrorData(uintptr_t src, PgExtErrorData* dst) {
+	memcpy(dst, (const void*)src, sizeof(PgExtErrorData));
+}
+
+// PgExtCallFrame holds everything that the host passes to the shim for a single call, with room for FuncMaxArgs
+// arguments.
+typedef struct PgExtCallFrame {
+	FmgrInfo flinfo;
+	Datum    result;
+	union {
+		FunctionCallInfoBaseData fcinfo;
+		char                     data[SizeForFunctionCallInfo(100)];
+	} call;
+} PgExtCallFrame;
+
+// ResetCallFrame clears the call info of the frame, along with the given number of its arguments.
+static inline void ResetCallFrame(PgExtCallFrame* frame, int nargs) {
+	memset(&frame->call, 0, SizeForFunctionCallInfo(nargs));
+	frame->result = 0;
+}
+
+static inline FunctionCallInfo CallFrameInfo(PgExtCallFrame* frame) {
+	return &frame->call.fcinfo;
 }
 */
 import "C"
-import "unsafe"
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+)
 
 // Datum is a C pointer to some data. Depending on the function being called, it may not be a pointer that should be
 // freed, as some functions return pointers to static memory.
 type Datum uintptr
 
+// fmgrInfo is the C struct that describes a function to the function itself. It's aliased so that files which do not
+// use cgo may refer to it.
+type fmgrInfo = C.FmgrInfo
+
+// callNodes are the nodes that are given to a function through its call info, which describe how the function is being
+// called. Both are nil for ordinary calls.
+type callNodes struct {
+	// context is given to trigger functions.
+	context unsafe.Pointer
+	// resultInfo is given to set-returning functions, and to functions that return composites.
+	resultInfo *C.ReturnSetInfo
+}
+
 // NullableDatum is used for arguments to Fmgr function calls.
 type NullableDatum struct {
 	Value  Datum
 	IsNull bool
 }
 
-// CallFmgrFunction calls the given function and forwards the arguments.
-func CallFmgrFunction(fn uintptr, args ...NullableDatum) (result Datum, isNotNull bool) {
-	fi := Malloc[C.FmgrInfo]()
-	defer Free(fi)
+var (
+	// shimFmgrCall calls a function such that any error that it raises unwinds back to the shim.
+	shimFmgrCall = newShimProc("pgext_fmgr_call")
+	// shimFmgrCallBatch calls a function for each row of a batch, within a single call into the shim.
+	shimFmgrCallBatch = newShimProc("pgext_fmgr_call_batch")
+)
+
+// FuncMaxArgs is the maximum number of arguments that may be given to a function, which matches Postgres.
+const FuncMaxArgs = 100
+
+// CallFmgrFunction calls the given function and forwards the arguments. If the function raises an error, then it is
+// returned as a PostgresError. The function is always called, even when an argument is NULL, so callers must check for
+// NULL arguments themselves when calling STRICT functions.
+func CallFmgrFunction(fn uintptr, args ...NullableDatum) (result Datum, isNotNull bool, err error) {
+	return CallFmgrFunctionColl(fn, 0, args...)
+}
+
+// CallFmgrFunctionColl is the same as CallFmgrFunction, except that the function is called with the given collation,
+// which is read by functions that compare or transform text.
+func CallFmgrFunctionColl(fn uintptr, collation uint32, args ...NullableDatum) (result Datum, isNotNull bool, err error) {
+	result, isNull, err := callFmgrFunction(fn, collation, args...)
+	if err != nil {
+		return 0, false, err
+	}
+	return result, !isNull && result != 0, nil
+}
+
+// CallFmgrFunction1 calls the given function with a single argument.
+func CallFmgrFunction1(fn uintptr, arg1 NullableDatum) (result Datum, isNotNull bool, err error) {
+	return CallFmgrFunctionColl(fn, 0, arg1)
+}
+
+// CallFmgrFunction2 calls the given function with two arguments.
+func CallFmgrFunction2(fn uintptr, arg1, arg2 NullableDatum) (result Datum, isNotNull bool, err error) {
+	return CallFmgrFunctionColl(fn, 0, arg1, arg2)
+}
+
+// CallFmgrFunction3 calls the given function with three arguments.
+func CallFmgrFunction3(fn uintptr, arg1, arg2, arg3 NullableDatum) (result Datum, isNotNull bool, err error) {
+	return CallFmgrFunctionColl(fn, 0, arg1, arg2, arg3)
+}
+
+// BatchResult is the result of calling a function for a single row of a batch.
+type BatchResult struct {
+	Value  Datum
+	IsNull bool
+	// Err is the error that the function raised for the row, in which case Value is zero.
+	Err error
+}
+
+// CallFmgrFunctionBatch calls the given function once for each row of arguments, crossing into the shim only once for
+// the entire batch, which is much faster than calling the function for each row when the function is cheap. Every row
+// must have the same number of arguments. The function may cache state within fn_extra across the rows of the batch.
+// An error that is raised for a row is returned within that row's result, while a crash stops the batch and is
+// returned as the error. The same as CallFmgrFunction, the function is called even when an argument is NULL.
+func CallFmgrFunctionBatch(fn uintptr, collation uint32, rows [][]NullableDatum) ([]BatchResult, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	nargs := len(rows[0])
+	if nargs > FuncMaxArgs {
+		return nil, fmt.Errorf("cannot pass more than %d arguments to a function", FuncMaxArgs)
+	}
+	for i, row := range rows {
+		if len(row) != nargs {
+			return nil, fmt.Errorf("row %d of the batch has %d arguments, while the first row has %d", i, len(row), nargs)
+		}
+	}
+	cf, err := getCallFrame()
+	if err != nil {
+		return nil, err
+	}
+	defer putCallFrame(cf)
+	fi := &cf.frame.flinfo
 	ZeroMemory(fi)
-	fc := Malloc[C.FunctionCallInfoBaseData]()
-	defer Free(fc)
-	ZeroMemory(fc)
-	fi.fn_addr = unsafe.Pointer(fn)
+	C.SetFmgrInfoAddr(fi, C.uintptr_t(fn))
+	fi.fn_nargs = C.short(nargs)
+	C.ResetCallFrame(cf.frame, C.int(nargs))
+	fc := C.CallFrameInfo(cf.frame)
 	fc.flinfo = fi
-	fc.nargs = C.int16_t(len(args))
+	fc.fncollation = C.uint32_t(collation)
+	fc.nargs = C.int16_t(nargs)
 
+	batch := Malloc[C.PgExtBatchCall]()
+	if batch == nil {
+		return nil, fmt.Errorf("out of memory while calling a function")
+	}
+	defer Free(batch)
+	batch.fcinfo = fc
+	batch.nrows = C.int(len(rows))
+	batch.args = (*C.NullableDatum)(C.calloc(C.size_t(max(len(rows)*nargs, 1)), C.sizeof_NullableDatum))
+	batch.results = (*C.Datum)(C.calloc(C.size_t(len(rows)), C.sizeof_Datum))
+	batch.isnull = (*C.bool)(C.calloc(C.size_t(len(rows)), C.sizeof_bool))
+	batch.errors = (**C.PgExtErrorData)(C.calloc(C.size_t(len(rows)), C.size_t(unsafe.Sizeof(uintptr(0)))))
+	defer func() {
+		Free(batch.args)
+		Free(batch.results)
+		Free(batch.isnull)
+		Free(batch.errors)
+	}()
+	if batch.args == nil || batch.results == nil || batch.isnull == nil || batch.errors == nil {
+		return nil, fmt.Errorf("out of memory while calling a function")
+	}
+	batchArgs := unsafe.Slice(batch.args, max(len(rows)*nargs, 1))
+	for i, row := range rows {
+		for j, arg := range row {
+			batchArgs[i*nargs+j].value = C.Datum(arg.Value)
+			batchArgs[i*nargs+j].isnull = C.bool(arg.IsNull)
+		}
+	}
+
+	finished, err := shimFmgrCallBatch.Call(uintptr(unsafe.Pointer(batch)))
+	if err != nil {
+		return nil, err
+	}
+	results := make([]BatchResult, len(rows))
+	values := unsafe.Slice(batch.results, len(rows))
+	nulls := unsafe.Slice(batch.isnull, len(rows))
+	errs := unsafe.Slice(batch.errors, len(rows))
+	for i := 0; i < int(finished); i++ {
+		if errs[i] != nil {
+			results[i].Err = newCallError(uintptr(unsafe.Pointer(errs[i])))
+			Free(errs[i])
+			continue
+		}
+		results[i].Value = Datum(values[i])
+		results[i].IsNull = bool(nulls[i])
+	}
+	if int(finished) < len(rows) {
+		if finished > 0 {
+			if crashErr, ok := results[finished-1].Err.(CrashError); ok {
+				return results[:finished], crashErr
+			}
+		}
+		return results[:finished], fmt.Errorf("out of memory while calling a function")
+	}
+	return results, nil
+}
+
+// NewNullableDatum returns a NullableDatum that holds the given value.
+func NewNullableDatum(value Datum) NullableDatum {
+	return NullableDatum{Value: value}
+}
+
+// NullDatum returns a NullableDatum that represents NULL.
+func NullDatum() NullableDatum {
+	return NullableDatum{IsNull: true}
+}
+
+// hasNullArg returns whether any of the arguments are NULL.
+func hasNullArg(args []NullableDatum) bool {
+	for _, arg := range args {
+		if arg.IsNull {
+			return true
+		}
+	}
+	return false
+}
+
+// callFrame is a pooled allocation of the memory that a call passes to the shim, so that functions which are called
+// for every row do not allocate for each call. The memory is freed once the pool drops the frame.
+type callFrame struct {
+	frame *C.PgExtCallFrame
+}
+
+// callFramePool holds the frames that are not in use by any call.
+var callFramePool = sync.Pool{
+	New: func() any {
+		frame := (*C.PgExtCallFrame)(C.malloc(C.sizeof_PgExtCallFrame))
+		if frame == nil {
+			return nil
+		}
+		cf := &callFrame{frame: frame}
+		runtime.AddCleanup(cf, func(frame *C.PgExtCallFrame) {
+			C.free(unsafe.Pointer(frame))
+		}, frame)
+		return cf
+	},
+}
+
+// getCallFrame returns a frame from the pool, which must be returned with putCallFrame once the call has finished.
+func getCallFrame() (*callFrame, error) {
+	cf, _ := callFramePool.Get().(*callFrame)
+	if cf == nil {
+		return nil, fmt.Errorf("out of memory while calling a function")
+	}
+	return cf, nil
+}
+
+// putCallFrame returns the frame to the pool.
+func putCallFrame(cf *callFrame) {
+	callFramePool.Put(cf)
+}
+
+// callFmgrFunction is the same as CallFmgrFunctionColl, except that it returns whether the function set its result to
+// null, which is distinct from returning a zero Datum for types that are passed by value.
+func callFmgrFunction(fn uintptr, collation uint32, args ...NullableDatum) (result Datum, isNull bool, err error) {
+	cf, err := getCallFrame()
+	if err != nil {
+		return 0, false, err
+	}
+	defer putCallFrame(cf)
+	fi := &cf.frame.flinfo
+	ZeroMemory(fi)
+	C.SetFmgrInfoAddr(fi, C.uintptr_t(fn))
+	fi.fn_nargs = C.short(len(args))
+	return cf.call(fi, callNodes{}, collation, args...)
+}
+
+// callFmgrInfo calls the function that is described by the given FmgrInfo, which may be reused across calls so that
+// the function may cache state within it.
+func callFmgrInfo(fi *C.FmgrInfo, nodes callNodes, collation uint32,
+	args ...NullableDatum) (result Datum, isNull bool, err error) {
+	cf, err := getCallFrame()
+	if err != nil {
+		return 0, false, err
+	}
+	defer putCallFrame(cf)
+	return cf.call(fi, nodes, collation, args...)
+}
+
+// call calls the function that is described by the given FmgrInfo, using the frame for the call info and result.
+func (cf *callFrame) call(fi *C.FmgrInfo, nodes callNodes, collation uint32,
+	args ...NullableDatum) (result Datum, isNull bool, err error) {
+	if len(args) > FuncMaxArgs {
+		return 0, false, fmt.Errorf("cannot pass more than %d arguments to a function", FuncMaxArgs)
+	}
+	C.ResetCallFrame(cf.frame, C.int(len(args)))
+	fc := C.CallFrameInfo(cf.frame)
+	fc.flinfo = fi
+	fc.context = nodes.context
+	fc.resultinfo = unsafe.Pointer(nodes.resultInfo)
+	fc.fncollation = C.uint32_t(collation)
+	fc.nargs = C.int16_t(len(args))
+	fcArgs := unsafe.Slice(&fc.args[0], max(len(args), 1))
 	for i, arg := range args {
-		fc.args[i].value = C.Datum(arg.Value)
-		fc.args[i].isnull = C.bool(arg.IsNull)
+		fcArgs[i].value = C.Datum(arg.Value)
+		fcArgs[i].isnull = C.bool(arg.IsNull)
+	}
+	edata, err := shimFmgrCall.Call(uintptr(unsafe.Pointer(fc)), uintptr(unsafe.Pointer(&cf.frame.result)))
+	if err != nil {
+		return 0, false, err
+	}
+	if edata != 0 {
+		return 0, false, newCallError(edata)
+	}
+	return Datum(cf.frame.result), bool(fc.isnull), nil
+}
+
+// newCallError returns the error for the error data that was returned by the shim, which is either a PostgresError or a
+// CrashError.
+func newCallError(edata uintptr) error {
+	var data C.PgExtErrorData
+	C.CopyErrorData(C.uintptr_t(edata), &data)
+	if data.signal != 0 {
+		return CrashError{
+			Signal:  int(data.signal),
+			Address: uintptr(data.address),
+			Stack:   crashBacktrace(),
+		}
+	}
+	return PostgresError{
+		Severity: elevelName(int(data.elevel)),
+		Code:     decodeSQLState(int(data.sqlerrcode)),
+		Message:  C.GoString(&data.message[0]),
+		Detail:   C.GoString(&data.detail[0]),
+		Hint:     C.GoString(&data.hint[0]),
+		Context:  C.GoString(&data.context[0]),
+		File:     C.GoString(data.filename),
+		Line:     int(data.lineno),
+		Function: C.GoString(data.funcname),
 	}
-	result = Datum(C.CallFmgrFunctionC(fc))
-	return result, !bool(fc.isnull) && result != 0
 }
@@ -0,0 +1,89 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// TimeoutError is returned from calls that did not return within their timeout.
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+// Error implements the error interface.
+func (te TimeoutError) Error() string {
+	return fmt.Sprintf("extension function did not return within %s", te.Timeout)
+}
+
+// shimBackendStateCurrent returns the state that is bound to the current thread.
+var shimBackendStateCurrent = newUnlockedShimProc("pgext_backend_state_current")
+
+// CallFmgrFunctionTimeout is the same as CallFmgrFunction, except that the call is canceled once it has run for the
+// given duration, in which case a TimeoutError is returned. The call is canceled through the state that is bound to the
+// current thread, the same as BackendState.Cancel, so the function only stops once it next checks for interrupts.
+// Functions that never check run until they return, and may only be stopped by calling them within a sandbox (see
+// WithSandbox and WithCallTimeout). A timeout of zero or less is the same as CallFmgrFunction.
+func CallFmgrFunctionTimeout(fn uintptr, timeout time.Duration, args ...NullableDatum) (result Datum, isNotNull bool, err error) {
+	result, isNull, err := callWithTimeout(timeout, func() (Datum, bool, error) {
+		return callFmgrFunction(fn, 0, args...)
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return result, !isNull && result != 0, nil
+}
+
+// callWithTimeout makes the call, canceling it through the state that is bound to the current thread once the timeout
+// has elapsed. The error that the cancellation raises is returned as a TimeoutError. The call is made without a
+// timeout when the timeout is zero or less.
+func callWithTimeout(timeout time.Duration, call func() (Datum, bool, error)) (Datum, bool, error) {
+	if timeout <= 0 {
+		return call()
+	}
+	// The state is bound per thread, so we must remain on the same thread until the call has returned
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	state := shimBackendStateCurrent.MustCall()
+	var mutex sync.Mutex
+	var returned, timedOut bool
+	timer := time.AfterFunc(timeout, func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if !returned {
+			timedOut = true
+			shimBackendStateCancel.MustCall(state)
+		}
+	})
+	result, isNull, err := call()
+	timer.Stop()
+	mutex.Lock()
+	returned = true
+	mutex.Unlock()
+	if !timedOut {
+		return result, isNull, err
+	}
+	// A cancellation that the call returned before processing would otherwise cancel the next call
+	shimBackendStateClearCancel.MustCall(state)
+	var postgresErr PostgresError
+	if errors.As(err, &postgresErr) && postgresErr.Code == "57014" {
+		return 0, false, TimeoutError{Timeout: timeout}
+	}
+	return result, isNull, err
+}
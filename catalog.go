@@ -0,0 +1,178 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+
+extern bool pgextHostLookupType(uint32_t oid, PgExtTypeInfo* info);
+
+static inline PgExtCatalogProvider* NewHostCatalogProvider() {
+	PgExtCatalogProvider* provider = (PgExtCatalogProvider*)malloc(sizeof(PgExtCatalogProvider));
+	provider->lookup_type = (bool (*)(Oid, PgExtTypeInfo*))pgextHostLookupType;
+	return provider;
+}
+*/
+import "C"
+import (
+	"cmp"
+	"sync"
+	"unsafe"
+)
+
+// CatalogProvider describes the types of the host's catalog to extensions, which look them up by OID through
+// functions such as lookup_type_cache, get_typlenbyvalalign, getTypeOutputInfo, and format_type_be. Extensions that
+// accept arguments of any type, or that create their own types, rely on these to find how values are stored and which
+// functions convert them to and from text. The functions are called through fmgr_info, so their OIDs must be known to
+// RegisterFunctionOID or the BuiltinResolver.
+type CatalogProvider interface {
+	// LookupType returns the type with the given OID, or false if it does not exist, in which case the built-in types
+	// of the shim are used.
+	LookupType(typeID uint32) (TypeInfo, bool)
+}
+
+// TypeInfo describes a type, mirroring the columns of pg_type that extensions read. OIDs are zero when the type does
+// not have the function or operator, and the zero values of Kind, Align, Storage, and Delimiter are replaced by those of
+// an ordinary base type.
+type TypeInfo struct {
+	// Name is the type's name, and Schema is the schema that contains it, which format_type_be_qualified includes.
+	Name   string
+	Schema string
+	// Length is the size of the type in bytes, which is -1 for varlena types and -2 for C strings.
+	Length  int16
+	ByValue bool
+	// Align is the alignment of the type's values, which is 'c', 's', 'i', or 'd', and defaults to 'i'.
+	Align byte
+	// Storage is how varlena values are stored, which is 'p', 'e', 'm', or 'x', and defaults to 'p'.
+	Storage byte
+	// Kind is the kind of type, which is 'b' for base types, 'c' for composite types, 'd' for domains, 'e' for enums,
+	// 'p' for pseudo-types, 'r' for ranges, or 'm' for multiranges, and defaults to 'b'.
+	Kind      byte
+	Category  byte
+	Delimiter byte
+	Collation uint32
+	// ElementType is the type of the elements of an array type, and ArrayType is the array type whose elements are of
+	// this type.
+	ElementType uint32
+	ArrayType   uint32
+	// RelationID is the relation of a composite type.
+	RelationID uint32
+	// BaseType is the type that a domain is over, and DomainTypmod is the typmod that the domain applies, where -1
+	// applies none.
+	BaseType     uint32
+	DomainTypmod int32
+	// These are the OIDs of the type's I/O functions.
+	InputFunction        uint32
+	OutputFunction       uint32
+	ReceiveFunction      uint32
+	SendFunction         uint32
+	TypmodOutputFunction uint32
+	// These are the OIDs of the type's default B-tree operators, with the function of the equality operator and the
+	// B-tree comparison function, along with its default hash function.
+	EqualityOperator    uint32
+	LessThanOperator    uint32
+	GreaterThanOperator uint32
+	EqualityFunction    uint32
+	CompareFunction     uint32
+	HashFunction        uint32
+}
+
+var (
+	// currentCatalogProvider describes the host's types, or is nil if only the built-in types exist.
+	currentCatalogProvider CatalogProvider
+	// catalogProviderMutex gates access to the provider.
+	catalogProviderMutex = &sync.RWMutex{}
+	// hostCatalogProvider is the C struct that forwards to the Go provider.
+	hostCatalogProvider = sync.OnceValue(func() *C.PgExtCatalogProvider {
+		return C.NewHostCatalogProvider()
+	})
+	shimSetCatalogProvider  = newShimProc("pgext_set_catalog_provider")
+	shimInvalidateTypeCache = newShimProc("pgext_invalidate_type_cache")
+)
+
+// SetCatalogProvider sets the provider that extensions look types up through. Setting nil leaves only the built-in
+// types, which describe how values are stored, but have no functions or operators, as those belong to the host.
+func SetCatalogProvider(provider CatalogProvider) error {
+	catalogProviderMutex.Lock()
+	currentCatalogProvider = provider
+	catalogProviderMutex.Unlock()
+	var providerPtr uintptr
+	if provider != nil {
+		providerPtr = uintptr(unsafe.Pointer(hostCatalogProvider()))
+	}
+	_, err := shimSetCatalogProvider.Call(providerPtr)
+	return err
+}
+
+// InvalidateTypeCache causes every session to look up its cached types again, which the host calls after a type that
+// the provider describes has changed. Sessions keep the entries of lookup_type_cache for their entire life otherwise,
+// the same as a Postgres backend until it's told of a change to the catalog.
+func InvalidateTypeCache() error {
+	_, err := shimInvalidateTypeCache.Call()
+	return err
+}
+
+// getCatalogProvider returns the current catalog provider.
+func getCatalogProvider() CatalogProvider {
+	catalogProviderMutex.RLock()
+	defer catalogProviderMutex.RUnlock()
+	return currentCatalogProvider
+}
+
+// copyName copies the name into the C array, truncating it to fit along with its terminator.
+func copyName(dst *[C.NAMEDATALEN]C.char, name string) {
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&dst[0])), len(dst))
+	buf[copy(buf[:len(buf)-1], name)] = 0
+}
+
+//export pgextHostLookupType
+func pgextHostLookupType(oid C.uint32_t, info *C.PgExtTypeInfo) C.bool {
+	provider := getCatalogProvider()
+	if provider == nil {
+		return false
+	}
+	typ, ok := provider.LookupType(uint32(oid))
+	if !ok {
+		return false
+	}
+	copyName(&info.typname, typ.Name)
+	copyName(&info.nspname, typ.Schema)
+	info.typlen = C.int16_t(typ.Length)
+	info.typbyval = C.bool(typ.ByValue)
+	info.typalign = C.char(cmp.Or(typ.Align, 'i'))
+	info.typstorage = C.char(cmp.Or(typ.Storage, 'p'))
+	info.typtype = C.char(cmp.Or(typ.Kind, 'b'))
+	info.typcategory = C.char(typ.Category)
+	info.typdelim = C.char(cmp.Or(typ.Delimiter, ','))
+	info.typcollation = C.Oid(typ.Collation)
+	info.typelem = C.Oid(typ.ElementType)
+	info.typarray = C.Oid(typ.ArrayType)
+	info.typrelid = C.Oid(typ.RelationID)
+	info.typbasetype = C.Oid(typ.BaseType)
+	info.typtypmod = C.int32_t(typ.DomainTypmod)
+	info.typinput = C.Oid(typ.InputFunction)
+	info.typoutput = C.Oid(typ.OutputFunction)
+	info.typreceive = C.Oid(typ.ReceiveFunction)
+	info.typsend = C.Oid(typ.SendFunction)
+	info.typmodout = C.Oid(typ.TypmodOutputFunction)
+	info.eq_opr = C.Oid(typ.EqualityOperator)
+	info.lt_opr = C.Oid(typ.LessThanOperator)
+	info.gt_opr = C.Oid(typ.GreaterThanOperator)
+	info.eq_proc = C.Oid(typ.EqualityFunction)
+	info.cmp_proc = C.Oid(typ.CompareFunction)
+	info.hash_proc = C.Oid(typ.HashFunction)
+	return true
+}
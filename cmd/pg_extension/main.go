@@ -0,0 +1,70 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command pg_extension loads the uuid-ossp extension from the local Postgres installation, and calls one of its
+// functions. It demonstrates the use of the package, and verifies that the shim works on the current platform.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dolthub/pg_extension"
+)
+
+func main() {
+	extensionFiles, err := pg_extension.LoadExtensions()
+	var report *pg_extension.LoadReport
+	if errors.As(err, &report) {
+		fmt.Printf("%s\n", report.Error())
+	} else if err != nil {
+		fmt.Printf("%s\n", err.Error())
+		os.Exit(1)
+	}
+	extFile, ok := extensionFiles["uuid-ossp"]
+	if !ok {
+		fmt.Printf("uuid-ossp is not installed\n")
+		os.Exit(1)
+	}
+	lib, err := extFile.LoadLibrary()
+	if err != nil {
+		fmt.Printf("%s\n", err.Error())
+		os.Exit(1)
+	}
+	defer func() {
+		_ = lib.Close()
+	}()
+	magic := lib.Magic()
+	fmt.Printf("Pg_magic_func:\n  version=%d  maxArgs=%d  nameDataLen=%d\n",
+		magic.Version, magic.FuncMaxArgs, magic.NameDataLen)
+	fn, ok := lib.Function("uuid_generate_v4")
+	if !ok {
+		fmt.Printf("uuid_generate_v4 was not loaded\n")
+		os.Exit(1)
+	}
+	datum, isNotNull, err := fn.Call()
+	if err != nil {
+		fmt.Printf("%s\n", err.Error())
+		os.Exit(1)
+	}
+	if isNotNull {
+		// A uuid is passed by reference as its 16 bytes
+		val := *pg_extension.FromDatum[[16]byte](datum)
+		pg_extension.FreeDatum(datum)
+		fmt.Printf("uuid_generate_v4:\n  %x-%x-%x-%x-%x\n", val[0:4], val[4:6], val[6:8], val[8:10], val[10:16])
+	} else {
+		fmt.Printf("uuid_generate_v4:\n  null\n")
+	}
+}
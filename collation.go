@@ -0,0 +1,174 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+
+extern bool pgextHostDescribeCollation(uint32_t collid, bool* collate_is_c, bool* ctype_is_c, bool* deterministic);
+extern char* pgextHostChangeCase(uint32_t collid, int kind, char* str, size_t len, size_t* result_len, int* sqlerrcode,
+	char** message);
+extern int pgextHostCompareStrings(uint32_t collid, char* arg1, size_t len1, char* arg2, size_t len2, int* sqlerrcode,
+	char** message);
+
+static inline PgExtCollationProvider* NewHostCollationProvider() {
+	PgExtCollationProvider* provider = (PgExtCollationProvider*)malloc(sizeof(PgExtCollationProvider));
+	provider->describe = (bool (*)(Oid, bool*, bool*, bool*))pgextHostDescribeCollation;
+	provider->change_case = (char* (*)(Oid, int, const char*, size_t, size_t*, int*, char**))pgextHostChangeCase;
+	provider->compare = (int (*)(Oid, const char*, size_t, const char*, size_t, int*, char**))pgextHostCompareStrings;
+	return provider;
+}
+*/
+import "C"
+import (
+	"errors"
+	"sync"
+	"unsafe"
+)
+
+// CollationProvider describes the host's collations to extensions, and changes the case of and compares strings under
+// them. Extensions such as citext rely on these to behave according to the collation of each call.
+type CollationProvider interface {
+	// DescribeCollation returns the details of the collation with the given OID, or false if it does not exist. The C
+	// and POSIX collations are always bytewise, so they're never described by the provider.
+	DescribeCollation(collationID uint32) (CollationInfo, bool)
+	// ChangeCase returns the string with its case changed under the collation. This is only called for collations whose
+	// ctype is not C, as those only change the case of ASCII letters.
+	ChangeCase(collationID uint32, str string, change CaseChange) (string, error)
+	// CompareStrings returns a negative number, zero, or a positive number when the first string sorts before, the same
+	// as, or after the second. This is only called for collations that are not C, and for strings that are not
+	// identical.
+	CompareStrings(collationID uint32, str1 string, str2 string) (int, error)
+}
+
+// CollationInfo contains the details of a collation.
+type CollationInfo struct {
+	// CollateIsC is true when the collation sorts strings bytewise.
+	CollateIsC bool
+	// CtypeIsC is true when the collation only changes the case of ASCII letters.
+	CtypeIsC bool
+	// Deterministic is true when only identical strings compare as equal.
+	Deterministic bool
+}
+
+// CaseChange is the change that is made to the case of a string.
+type CaseChange int
+
+const (
+	CaseLower   CaseChange = C.PGEXT_CASE_LOWER
+	CaseUpper   CaseChange = C.PGEXT_CASE_UPPER
+	CaseInitcap CaseChange = C.PGEXT_CASE_INITCAP
+)
+
+var (
+	// currentCollationProvider describes the host's collations, or is nil if the host only has the built-in collations.
+	currentCollationProvider CollationProvider
+	// collationProviderMutex gates access to the provider.
+	collationProviderMutex = &sync.RWMutex{}
+	// hostCollationProvider is the C struct that forwards to the Go provider.
+	hostCollationProvider = sync.OnceValue(func() *C.PgExtCollationProvider {
+		return C.NewHostCollationProvider()
+	})
+	shimSetCollationProvider = newShimProc("pgext_set_collation_provider")
+)
+
+// SetCollationProvider sets the provider that extensions use for collations. Setting nil leaves only the default, C,
+// and POSIX collations, where the default collation sorts bytewise and changes case according to Unicode, which is the
+// default.
+func SetCollationProvider(provider CollationProvider) error {
+	collationProviderMutex.Lock()
+	currentCollationProvider = provider
+	collationProviderMutex.Unlock()
+	var providerPtr uintptr
+	if provider != nil {
+		providerPtr = uintptr(unsafe.Pointer(hostCollationProvider()))
+	}
+	_, err := shimSetCollationProvider.Call(providerPtr)
+	return err
+}
+
+// getCollationProvider returns the current collation provider.
+func getCollationProvider() CollationProvider {
+	collationProviderMutex.RLock()
+	defer collationProviderMutex.RUnlock()
+	return currentCollationProvider
+}
+
+//export pgextHostDescribeCollation
+func pgextHostDescribeCollation(collid C.uint32_t, collateIsC *C.bool, ctypeIsC *C.bool, deterministic *C.bool) C.bool {
+	provider := getCollationProvider()
+	if provider == nil {
+		return false
+	}
+	info, ok := provider.DescribeCollation(uint32(collid))
+	if !ok {
+		return false
+	}
+	*collateIsC = C.bool(info.CollateIsC)
+	*ctypeIsC = C.bool(info.CtypeIsC)
+	*deterministic = C.bool(info.Deterministic)
+	return true
+}
+
+//export pgextHostChangeCase
+func pgextHostChangeCase(collid C.uint32_t, kind C.int, str *C.char, length C.size_t, resultLen *C.size_t, sqlerrcode *C.int, message **C.char) *C.char {
+	provider := getCollationProvider()
+	if provider == nil {
+		*message = C.CString("the collation provider has been removed")
+		return nil
+	}
+	result, err := provider.ChangeCase(uint32(collid), C.GoStringN(str, C.int(length)), CaseChange(kind))
+	if err != nil {
+		var pgErr PostgresError
+		if errors.As(err, &pgErr) {
+			*sqlerrcode = C.int(encodeSQLState(pgErr.Code))
+			*message = C.CString(pgErr.Message)
+		} else {
+			*message = C.CString(err.Error())
+		}
+		return nil
+	}
+	*resultLen = C.size_t(len(result))
+	return C.CString(result)
+}
+
+//export pgextHostCompareStrings
+func pgextHostCompareStrings(collid C.uint32_t, arg1 *C.char, len1 C.size_t, arg2 *C.char, len2 C.size_t, sqlerrcode *C.int, message **C.char) C.int {
+	provider := getCollationProvider()
+	if provider == nil {
+		*message = C.CString("the collation provider has been removed")
+		return 0
+	}
+	cmp, err := provider.CompareStrings(uint32(collid), C.GoStringN(arg1, C.int(len1)), C.GoStringN(arg2, C.int(len2)))
+	if err != nil {
+		var pgErr PostgresError
+		if errors.As(err, &pgErr) {
+			*sqlerrcode = C.int(encodeSQLState(pgErr.Code))
+			*message = C.CString(pgErr.Message)
+		} else {
+			*message = C.CString(err.Error())
+		}
+		return 0
+	}
+	switch {
+	case cmp < 0:
+		return -1
+	case cmp > 0:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,403 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+
+static inline void SetFmgrInfoAddr(FmgrInfo* finfo, uintptr_t fn) {
+	finfo->fn_addr = (void*)fn;
+}
+
+static inline ReturnSetInfo* ToReturnSetInfo(uintptr_t rsi) {
+	return (ReturnSetInfo*)rsi;
+}
+
+static inline TupleDesc ToTupleDesc(uintptr_t desc) {
+	return (TupleDesc)desc;
+}
+
+static inline Oid CompositeTypeID(uintptr_t d) {
+	return ((HeapTupleHeader)d)->t_choice.t_datum.datum_typeid;
+}
+
+static inline int32_t CompositeTypmod(uintptr_t d) {
+	return ((HeapTupleHeader)d)->t_choice.t_datum.datum_typmod;
+}
+
+static inline TupleDesc NewTupleDesc(int natts) {
+	TupleDesc desc = (TupleDesc)calloc(1, TupleDescSize(natts));
+	if (desc != NULL) {
+		desc->natts = natts;
+		desc->tdtypeid = RECORDOID;
+		desc->tdtypmod = -1;
+		desc->tdrefcount = -1;
+	}
+	return desc;
+}
+
+static inline void InitTupleDescAttr(TupleDesc desc, int i, const char* name, Oid typid, int32_t typmod, int16_t len,
+	bool byval, char align) {
+	Form_pg_attribute att = TupleDescAttr(desc, i);
+	strncpy(att->attname.data, name, NAMEDATALEN - 1);
+	att->atttypid = typid;
+	att->atttypmod = typmod;
+	att->attlen = len;
+	att->attnum = (int16_t)(i + 1);
+	att->attcacheoff = -1;
+	att->attbyval = byval;
+	att->attalign = align;
+	att->attstorage = len == -1 ? 'x' : 'p';
+	att->attislocal = true;
+}
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"unsafe"
+)
+
+var (
+	shimRecordTupdesc   = newShimProc("pgext_record_tupdesc")
+	shimDeformComposite = newShimProc("pgext_deform_composite")
+	shimFormComposite   = newShimProc("pgext_form_composite")
+	datumType           = reflect.TypeFor[Datum]()
+	nullableDatumType   = reflect.TypeFor[NullableDatum]()
+	errNullComposite    = errors.New("cannot decode a composite value from a null pointer")
+)
+
+// CallFmgrCompositeFunction calls the given function, which returns a composite value with the given columns, and
+// decodes the result. The columns are given to the function as its expected result, which functions that return an
+// anonymous record use to build their result. By-reference values point into the result, which is allocated within the
+// current memory context.
+func CallFmgrCompositeFunction(fn uintptr, columns []Column,
+	args ...NullableDatum) (row []NullableDatum, isNotNull bool, err error) {
+	return callCompositeFunction(fn, columns, func(fi *C.FmgrInfo, rsi *C.ReturnSetInfo) (Datum, bool, error) {
+		fi.fn_nargs = C.short(len(args))
+		return callFmgrInfo(fi, callNodes{resultInfo: rsi}, 0, args...)
+	})
+}
+
+// CallComposite is the same as CallFmgrCompositeFunction, except that the call is recorded against the resource usage
+// of the function's library. STRICT functions return NULL without being called when any argument is NULL.
+func (f Function) CallComposite(columns []Column,
+	args ...NullableDatum) (row []NullableDatum, isNotNull bool, err error) {
+	return callCompositeFunction(f.Ptr, columns, func(fi *C.FmgrInfo, rsi *C.ReturnSetInfo) (Datum, bool, error) {
+		fillFmgrInfo(fi, f)
+		result, isNull, _, err := f.callWithInfo(fi, callNodes{resultInfo: rsi}, 0, args...)
+		return result, isNull, err
+	})
+}
+
+// callCompositeFunction makes the given call with a result info that holds the expected columns, and decodes the
+// result.
+func callCompositeFunction(fn uintptr, columns []Column, call setFunctionCall) ([]NullableDatum, bool, error) {
+	fi := Malloc[C.FmgrInfo]()
+	defer Free(fi)
+	ZeroMemory(fi)
+	C.SetFmgrInfoAddr(fi, C.uintptr_t(fn))
+	// The result info doesn't allow any set modes, so set-returning functions will reject the call
+	rsiPtr, err := shimSrfBegin.Call(0, uintptr(len(columns)))
+	if err != nil {
+		return nil, false, err
+	}
+	if rsiPtr == 0 {
+		return nil, false, errors.New("out of memory while calling a function")
+	}
+	rsi := C.ToReturnSetInfo(C.uintptr_t(rsiPtr))
+	initTupleDesc(rsi.expectedDesc, columns)
+	result, isNull, err := call(fi, rsi)
+	edata, endErr := shimSrfEnd.Call(rsiPtr)
+	if err != nil {
+		return nil, false, err
+	}
+	if endErr != nil {
+		return nil, false, endErr
+	}
+	if edata != 0 {
+		return nil, false, newCallError(edata)
+	}
+	if isNull || result == 0 {
+		return nil, false, nil
+	}
+	// Anonymous records that were blessed by the function are decoded using their own descriptor
+	if blessed, err := CompositeColumns(result); err == nil {
+		columns = blessed
+	}
+	row, err := DecodeComposite(result, columns)
+	return row, err == nil, err
+}
+
+// CompositeColumns returns the columns of a composite Datum whose type is an anonymous record that was blessed by the
+// extension. The columns of named composite types are not known to the shim, and must be supplied by the host.
+func CompositeColumns(d Datum) ([]Column, error) {
+	if d == 0 {
+		return nil, errNullComposite
+	}
+	typeID := uint32(C.CompositeTypeID(C.uintptr_t(d)))
+	typmod := int32(C.CompositeTypmod(C.uintptr_t(d)))
+	if typeID != C.RECORDOID || typmod < 0 {
+		return nil, fmt.Errorf("composite type %d is not an anonymous record", typeID)
+	}
+	desc, err := shimRecordTupdesc.Call(uintptr(typmod))
+	if err != nil {
+		return nil, err
+	}
+	if desc == 0 {
+		return nil, fmt.Errorf("record type with typmod %d has not been registered", typmod)
+	}
+	return tupleDescColumns(C.ToTupleDesc(C.uintptr_t(desc))), nil
+}
+
+// DecodeComposite decodes a composite Datum into the values of its attributes, using the given columns. If no columns
+// are given, then the Datum must be an anonymous record whose columns are found using CompositeColumns. By-reference
+// values point into the composite value, and remain valid for as long as it does.
+func DecodeComposite(d Datum, columns []Column) ([]NullableDatum, error) {
+	if d == 0 {
+		return nil, errNullComposite
+	}
+	if len(columns) == 0 {
+		var err error
+		if columns, err = CompositeColumns(d); err != nil {
+			return nil, err
+		}
+	}
+	desc := newTupleDesc(columns)
+	if desc == nil {
+		return nil, errors.New("out of memory while decoding a composite value")
+	}
+	defer Free(desc)
+	natts := max(len(columns), 1)
+	values := (*C.Datum)(C.calloc(C.size_t(natts), C.size_t(unsafe.Sizeof(C.Datum(0)))))
+	defer Free(values)
+	isnull := (*C.bool)(C.calloc(C.size_t(natts), C.size_t(unsafe.Sizeof(C.bool(false)))))
+	defer Free(isnull)
+	_, err := shimDeformComposite.Call(uintptr(d), uintptr(unsafe.Pointer(desc)), uintptr(unsafe.Pointer(values)),
+		uintptr(unsafe.Pointer(isnull)))
+	if err != nil {
+		return nil, err
+	}
+	valuesSlice := unsafe.Slice(values, natts)
+	isnullSlice := unsafe.Slice(isnull, natts)
+	row := make([]NullableDatum, len(columns))
+	for i := range row {
+		row[i] = NullableDatum{Value: Datum(valuesSlice[i]), IsNull: bool(isnullSlice[i])}
+	}
+	return row, nil
+}
+
+// EncodeComposite returns a composite Datum holding the values, which are described by the given columns, for passing
+// to functions that take a row-typed argument. The Datum is an anonymous record whose descriptor is blessed, so
+// extensions find its attributes through GetAttributeByName and GetAttributeByNum, and CompositeColumns returns the
+// columns. By-reference values are copied into the Datum, which is allocated within the current memory context.
+func EncodeComposite(values []NullableDatum, columns []Column) (Datum, error) {
+	if len(values) != len(columns) {
+		return 0, fmt.Errorf("composite value has %d values but %d columns", len(values), len(columns))
+	}
+	desc := newTupleDesc(columns)
+	if desc == nil {
+		return 0, errors.New("out of memory while encoding a composite value")
+	}
+	defer Free(desc)
+	natts := max(len(columns), 1)
+	cValues := (*C.Datum)(C.calloc(C.size_t(natts), C.size_t(unsafe.Sizeof(C.Datum(0)))))
+	defer Free(cValues)
+	cIsnull := (*C.bool)(C.calloc(C.size_t(natts), C.size_t(unsafe.Sizeof(C.bool(false)))))
+	defer Free(cIsnull)
+	valuesSlice := unsafe.Slice(cValues, natts)
+	isnullSlice := unsafe.Slice(cIsnull, natts)
+	for i, value := range values {
+		valuesSlice[i] = C.Datum(value.Value)
+		isnullSlice[i] = C.bool(value.IsNull)
+	}
+	d, err := shimFormComposite.Call(uintptr(unsafe.Pointer(desc)), uintptr(unsafe.Pointer(cValues)),
+		uintptr(unsafe.Pointer(cIsnull)))
+	if err != nil {
+		return 0, err
+	}
+	return Datum(d), nil
+}
+
+// ScanComposite decodes a composite Datum into the fields of the struct that dest points to. Each attribute is stored
+// in the field whose `pg` tag matches the attribute's name, or otherwise the field whose name matches it regardless of
+// case. Fields may be integers, floats, booleans, strings, byte slices, Datums, NullableDatums, or pointers to any of
+// these, which are set to nil for NULL attributes. The columns are handled the same as they are for DecodeComposite.
+func ScanComposite(d Datum, columns []Column, dest any) error {
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Pointer || destValue.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("composite values may only be scanned into a pointer to a struct, not %T", dest)
+	}
+	if len(columns) == 0 {
+		var err error
+		if columns, err = CompositeColumns(d); err != nil {
+			return err
+		}
+	}
+	row, err := DecodeComposite(d, columns)
+	if err != nil {
+		return err
+	}
+	structValue := destValue.Elem()
+	structType := structValue.Type()
+	for i, column := range columns {
+		for fieldIdx := 0; fieldIdx < structType.NumField(); fieldIdx++ {
+			field := structType.Field(fieldIdx)
+			if !field.IsExported() {
+				continue
+			}
+			name, ok := field.Tag.Lookup("pg")
+			if !ok {
+				name = field.Name
+			}
+			if !strings.EqualFold(name, column.Name) {
+				continue
+			}
+			if err = scanDatum(row[i], column, structValue.Field(fieldIdx)); err != nil {
+				return fmt.Errorf("cannot scan attribute `%s` into field `%s`: %w", column.Name, field.Name, err)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// scanDatum stores the value into the given field, converting it according to the column's type.
+func scanDatum(value NullableDatum, column Column, field reflect.Value) error {
+	switch field.Type() {
+	case nullableDatumType:
+		field.Set(reflect.ValueOf(value))
+		return nil
+	case datumType:
+		field.Set(reflect.ValueOf(value.Value))
+		return nil
+	}
+	if field.Kind() == reflect.Pointer {
+		if value.IsNull {
+			field.SetZero()
+			return nil
+		}
+		ptr := reflect.New(field.Type().Elem())
+		if err := scanDatum(value, column, ptr.Elem()); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+	if value.IsNull {
+		field.SetZero()
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.Bool:
+		field.SetBool(value.Value != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		field.SetInt(signExtendDatum(value.Value, column.Len))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		field.SetUint(uint64(value.Value))
+	case reflect.Float32:
+		field.SetFloat(float64(math.Float32frombits(uint32(value.Value))))
+	case reflect.Float64:
+		field.SetFloat(math.Float64frombits(uint64(value.Value)))
+	case reflect.String:
+		data, err := datumBytes(value.Value, column)
+		if err != nil {
+			return err
+		}
+		field.SetString(string(data))
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported field type %s", field.Type())
+		}
+		data, err := datumBytes(value.Value, column)
+		if err != nil {
+			return err
+		}
+		field.SetBytes(data)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// signExtendDatum returns the integer that is held by a Datum of the given length.
+func signExtendDatum(d Datum, length int16) int64 {
+	switch length {
+	case 1:
+		return int64(int8(d))
+	case 2:
+		return int64(int16(d))
+	case 4:
+		return int64(int32(d))
+	default:
+		return int64(d)
+	}
+}
+
+// datumBytes returns a copy of the data of a by-reference Datum. Varlenas are returned without their header, while C
+// strings are returned without their terminator.
+func datumBytes(d Datum, column Column) ([]byte, error) {
+	switch {
+	case column.ByVal:
+		return nil, fmt.Errorf("type %d is passed by value", column.TypeOID)
+	case column.Len == -1:
+		return DatumVarlenaData(d)
+	case column.Len == -2:
+		return copyFromDatum(d, datumCStringLen(d)), nil
+	default:
+		return copyFromDatum(d, int(column.Len)), nil
+	}
+}
+
+// initTupleDesc fills the attributes of the descriptor from the given columns.
+func initTupleDesc(desc *C.TupleDescData, columns []Column) {
+	for i, column := range columns {
+		name := C.CString(column.Name)
+		C.InitTupleDescAttr(desc, C.int(i), name, C.Oid(column.TypeOID), C.int32_t(column.TypMod), C.int16_t(column.Len),
+			C.bool(column.ByVal), C.char(column.Align))
+		Free(name)
+	}
+}
+
+// newTupleDesc returns a descriptor of the columns, which is allocated by the host and must be freed by the caller.
+// Returns nil when out of memory.
+func newTupleDesc(columns []Column) *C.TupleDescData {
+	desc := C.NewTupleDesc(C.int(len(columns)))
+	if desc != nil {
+		initTupleDesc(desc, columns)
+	}
+	return desc
+}
+
+// tupleDescColumns returns the columns that are described by the descriptor.
+func tupleDescColumns(desc *C.TupleDescData) []Column {
+	attrs := unsafe.Slice(&desc.attrs[0], int(desc.natts))
+	columns := make([]Column, len(attrs))
+	for i, att := range attrs {
+		columns[i] = Column{
+			Name:    C.GoString(&att.attname.data[0]),
+			TypeOID: uint32(att.atttypid),
+			TypMod:  int32(att.atttypmod),
+			Len:     int16(att.attlen),
+			ByVal:   bool(att.attbyval),
+			Align:   byte(att.attalign),
+		}
+	}
+	return columns
+}
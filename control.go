@@ -0,0 +1,184 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Control contains the contents of an extension's control file.
+type Control struct {
+	Directory      string
+	DefaultVersion string
+	ModulePathname string
+	Comment        string
+	Encoding       string
+	Schema         string
+	Requires       []string
+	NoRelocate     []string
+	Relocatable    bool
+	Superuser      bool
+	Trusted        bool
+}
+
+// ParseControl parses the contents of a control file. The file name is only used for error messages.
+func ParseControl(fileName string, contents string) (*Control, error) {
+	control := &Control{
+		// Extensions require a superuser to install unless stated otherwise
+		Superuser: true,
+	}
+	if err := control.apply(fileName, contents, false); err != nil {
+		return nil, &LoadError{
+			Kind: ErrControlParse,
+			File: fileName,
+			Err:  err,
+		}
+	}
+	return control, nil
+}
+
+// WithSecondary returns a copy of the control, with the contents of the given secondary control file merged over it.
+// Secondary control files are named `extension--version.control`, and override the primary control file for that
+// version. The file name is only used for error messages.
+func (control *Control) WithSecondary(fileName string, contents string) (*Control, error) {
+	merged := *control
+	merged.Requires = slices.Clone(control.Requires)
+	merged.NoRelocate = slices.Clone(control.NoRelocate)
+	if err := merged.apply(fileName, contents, true); err != nil {
+		return nil, &LoadError{
+			Kind: ErrControlParse,
+			File: fileName,
+			Err:  err,
+		}
+	}
+	return &merged, nil
+}
+
+// apply parses the contents of a control file, overwriting any fields that are set within the file.
+func (control *Control) apply(fileName string, contents string, isSecondary bool) error {
+	for lineNum, line := range strings.Split(contents, "\n") {
+		name, value, ok, err := parseControlLine(line)
+		if err != nil {
+			return fmt.Errorf("syntax error in file \"%s\" line %d: %s", fileName, lineNum+1, err.Error())
+		}
+		if !ok {
+			continue
+		}
+		if isSecondary && (name == "directory" || name == "default_version") {
+			return fmt.Errorf(`parameter "%s" cannot be set in a secondary extension control file`, name)
+		}
+		switch name {
+		case "directory":
+			control.Directory = value
+		case "default_version":
+			control.DefaultVersion = value
+		case "module_pathname":
+			control.ModulePathname = value
+		case "comment":
+			control.Comment = value
+		case "encoding":
+			control.Encoding = value
+		case "schema":
+			control.Schema = value
+		case "requires":
+			control.Requires = splitControlList(value)
+		case "no_relocate":
+			control.NoRelocate = splitControlList(value)
+		case "relocatable", "superuser", "trusted":
+			boolValue, ok := parseConfigBool(value)
+			if !ok {
+				return fmt.Errorf(`parameter "%s" requires a Boolean value`, name)
+			}
+			switch name {
+			case "relocatable":
+				control.Relocatable = boolValue
+			case "superuser":
+				control.Superuser = boolValue
+			case "trusted":
+				control.Trusted = boolValue
+			}
+		default:
+			return fmt.Errorf(`unrecognized parameter "%s" in file "%s"`, name, fileName)
+		}
+	}
+	if control.Relocatable && len(control.Schema) > 0 {
+		return fmt.Errorf(`parameter "schema" cannot be specified when "relocatable" is true`)
+	}
+	return nil
+}
+
+// parseControlLine parses a single line of a control file, which uses the same syntax as postgresql.conf. Returns false
+// if the line does not contain a setting.
+func parseControlLine(line string) (name string, value string, ok bool, err error) {
+	line = strings.TrimSpace(line)
+	if len(line) == 0 || line[0] == '#' {
+		return "", "", false, nil
+	}
+	nameEnd := strings.IndexFunc(line, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '='
+	})
+	if nameEnd <= 0 {
+		return "", "", false, fmt.Errorf("missing value for `%s`", line)
+	}
+	name = strings.ToLower(line[:nameEnd])
+	rest := strings.TrimSpace(line[nameEnd:])
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, "="))
+	if len(rest) == 0 {
+		return "", "", false, fmt.Errorf("missing value for `%s`", name)
+	}
+	if rest[0] != '\'' {
+		// Unquoted values end at whitespace or a comment
+		valueEnd := strings.IndexAny(rest, " \t#")
+		if valueEnd == -1 {
+			valueEnd = len(rest)
+		}
+		if trailing := strings.TrimSpace(rest[valueEnd:]); len(trailing) > 0 && trailing[0] != '#' {
+			return "", "", false, fmt.Errorf("unexpected text after value of `%s`", name)
+		}
+		return name, rest[:valueEnd], true, nil
+	}
+	var sb strings.Builder
+	for i := 1; i < len(rest); i++ {
+		switch c := rest[i]; {
+		case c == '\\' && i+1 < len(rest):
+			i++
+			sb.WriteByte(rest[i])
+		case c == '\'' && i+1 < len(rest) && rest[i+1] == '\'':
+			i++
+			sb.WriteByte('\'')
+		case c == '\'':
+			if trailing := strings.TrimSpace(rest[i+1:]); len(trailing) > 0 && trailing[0] != '#' {
+				return "", "", false, fmt.Errorf("unexpected text after value of `%s`", name)
+			}
+			return name, sb.String(), true, nil
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", "", false, fmt.Errorf("unterminated quoted string for `%s`", name)
+}
+
+// splitControlList splits a comma-separated list from a control file.
+func splitControlList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,32 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux || darwin
+
+package pg_extension
+
+/*
+#include <time.h>
+*/
+import "C"
+import "time"
+
+// threadCPUTime returns the CPU time that has been consumed by the current thread.
+func threadCPUTime() time.Duration {
+	var ts C.struct_timespec
+	if C.clock_gettime(C.CLOCK_THREAD_CPUTIME_ID, &ts) != 0 {
+		return 0
+	}
+	return time.Duration(ts.tv_sec)*time.Second + time.Duration(ts.tv_nsec)
+}
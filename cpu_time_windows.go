@@ -0,0 +1,46 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package pg_extension
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	procGetCurrentThread = syscall.NewLazyDLL("kernel32.dll").NewProc("GetCurrentThread")
+	procGetThreadTimes   = syscall.NewLazyDLL("kernel32.dll").NewProc("GetThreadTimes")
+)
+
+// threadCPUTime returns the CPU time that has been consumed by the current thread.
+func threadCPUTime() time.Duration {
+	thread, _, _ := procGetCurrentThread.Call()
+	var creation, exit, kernel, user syscall.Filetime
+	ret, _, _ := procGetThreadTimes.Call(thread,
+		uintptr(unsafe.Pointer(&creation)),
+		uintptr(unsafe.Pointer(&exit)),
+		uintptr(unsafe.Pointer(&kernel)),
+		uintptr(unsafe.Pointer(&user)))
+	if ret == 0 {
+		return 0
+	}
+	// Filetime values are in 100 nanosecond intervals
+	ticks := (int64(kernel.HighDateTime)<<32 | int64(kernel.LowDateTime)) +
+		(int64(user.HighDateTime)<<32 | int64(user.LowDateTime))
+	return time.Duration(ticks * 100)
+}
@@ -0,0 +1,137 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// The conversions in this file follow the GetDatum and DatumGet macros of Postgres for a 64-bit build, where every type
+// of eight bytes or fewer is passed by value. Values that are passed by reference are allocated within the current
+// memory context, and may be freed with FreeDatum.
+
+// DatumToBool returns the bool that is stored within the Datum.
+func DatumToBool(d Datum) bool {
+	return d&0xFF != 0
+}
+
+// BoolToDatum returns a Datum that stores the bool.
+func BoolToDatum(v bool) Datum {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// DatumToInt16 returns the int2 that is stored within the Datum.
+func DatumToInt16(d Datum) int16 {
+	return int16(d)
+}
+
+// Int16ToDatum returns a Datum that stores the int2.
+func Int16ToDatum(v int16) Datum {
+	return Datum(int64(v))
+}
+
+// DatumToInt32 returns the int4 that is stored within the Datum.
+func DatumToInt32(d Datum) int32 {
+	return int32(d)
+}
+
+// Int32ToDatum returns a Datum that stores the int4.
+func Int32ToDatum(v int32) Datum {
+	return Datum(int64(v))
+}
+
+// DatumToInt64 returns the int8 that is stored within the Datum.
+func DatumToInt64(d Datum) int64 {
+	return int64(d)
+}
+
+// Int64ToDatum returns a Datum that stores the int8.
+func Int64ToDatum(v int64) Datum {
+	return Datum(v)
+}
+
+// DatumToOid returns the oid that is stored within the Datum.
+func DatumToOid(d Datum) uint32 {
+	return uint32(d)
+}
+
+// OidToDatum returns a Datum that stores the oid.
+func OidToDatum(v uint32) Datum {
+	return Datum(v)
+}
+
+// DatumToFloat4 returns the float4 that is stored within the Datum.
+func DatumToFloat4(d Datum) float32 {
+	return math.Float32frombits(uint32(d))
+}
+
+// Float4ToDatum returns a Datum that stores the float4.
+func Float4ToDatum(v float32) Datum {
+	return Datum(math.Float32bits(v))
+}
+
+// DatumToFloat8 returns the float8 that is stored within the Datum.
+func DatumToFloat8(d Datum) float64 {
+	return math.Float64frombits(uint64(d))
+}
+
+// Float8ToDatum returns a Datum that stores the float8.
+func Float8ToDatum(v float64) Datum {
+	return Datum(math.Float64bits(v))
+}
+
+// DatumToGoString returns a copy of the text that the Datum points to, which also applies to varchar and bpchar.
+func DatumToGoString(d Datum) (string, error) {
+	data, err := DatumVarlenaData(d)
+	return string(data), err
+}
+
+// GoStringToDatum returns a text Datum containing the given string, which is the same as TextDatum.
+func GoStringToDatum(s string) (Datum, error) {
+	return TextDatum(s)
+}
+
+// DatumToGoBytes returns a copy of the bytea that the Datum points to.
+func DatumToGoBytes(d Datum) ([]byte, error) {
+	return DatumVarlenaData(d)
+}
+
+// GoBytesToDatum returns a bytea Datum containing the given bytes, which is the same as VarlenaDatum.
+func GoBytesToDatum(b []byte) (Datum, error) {
+	return VarlenaDatum(b)
+}
+
+// DatumToCString returns a copy of the null-terminated C string that the Datum points to, which is how cstring values
+// such as the results of type output functions are passed.
+func DatumToCString(d Datum) (string, error) {
+	if d == 0 {
+		return "", fmt.Errorf("cannot read a C string from a null pointer")
+	}
+	return string(copyFromDatum(d, datumCStringLen(d))), nil
+}
+
+// CStringToDatum returns a null-terminated C string Datum containing the given string, such as for the input of a type
+// input function. The string cannot contain a null byte, as the C string would end there.
+func CStringToDatum(s string) (Datum, error) {
+	if strings.IndexByte(s, 0) >= 0 {
+		return 0, fmt.Errorf("invalid byte sequence: C strings cannot contain 0x00")
+	}
+	return pallocCopy(append([]byte(s), 0))
+}
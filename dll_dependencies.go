@@ -0,0 +1,72 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"debug/pe"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// missingDLL is a DLL that could not be found, along with the DLL that depends on it.
+type missingDLL struct {
+	name       string
+	requiredBy string
+}
+
+// findMissingDLL searches the given directories for every DLL that the DLL at the given path depends on, directly or
+// through its other dependencies, returning the first that cannot be found. API sets, which Windows resolves without
+// any file, are skipped. Returns false if every dependency was found, or if the dependencies could not be read.
+func findMissingDLL(path string, dirs []string) (missingDLL, bool) {
+	visited := map[string]struct{}{strings.ToLower(filepath.Base(path)): {}}
+	pending := []string{path}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+		f, err := pe.Open(current)
+		if err != nil {
+			continue
+		}
+		needed, err := f.ImportedLibraries()
+		_ = f.Close()
+		if err != nil {
+			continue
+		}
+		for _, name := range needed {
+			lowerName := strings.ToLower(name)
+			if _, ok := visited[lowerName]; ok {
+				continue
+			}
+			visited[lowerName] = struct{}{}
+			if strings.HasPrefix(lowerName, "api-ms-") || strings.HasPrefix(lowerName, "ext-ms-") {
+				continue
+			}
+			found := ""
+			for _, dir := range dirs {
+				candidate := filepath.Join(dir, name)
+				if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+					found = candidate
+					break
+				}
+			}
+			if len(found) == 0 {
+				return missingDLL{name: name, requiredBy: filepath.Base(current)}, true
+			}
+			pending = append(pending, found)
+		}
+	}
+	return missingDLL{}, false
+}
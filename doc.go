@@ -0,0 +1,96 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pg_extension loads Postgres extensions into a Go process, so that their C functions may be called without a
+// Postgres server. The Postgres functions that extensions import are provided by a shim library (see the library
+// directory), which is embedded into every binary that imports this package.
+//
+// # Finding extensions
+//
+// LoadExtensions finds the extensions of the local Postgres installation, while LoadExtensionsWithOptions and
+// LoadExtension control where they're found. Each extension is described by its ExtensionFiles, which parse its control
+// and SQL files on demand. RegisterBundledExtensions adds extensions that are embedded into the host binary.
+//
+// # Loading libraries
+//
+// ExtensionFiles.LoadLibrary loads an extension's library, along with the functions that its SQL files declare, while
+// LoadLibrary loads any library directly. A Library is shared by everything that loads it, and must be closed once for
+// each time that it was loaded. Failures are reported as a *LoadError, whose Kind may be compared through errors.Is.
+//
+// # Calling functions
+//
+// A Function of a Library is called through its Call methods, such as Function.Call for scalar functions and
+// Function.CallSet for set-returning functions. The CallFmgr functions call a function pointer directly. Arguments and
+// results are Datums, which are built and read through helpers such as TextDatum, FromDatum, EncodeComposite, and
+// DecodeComposite. CallFmgrFunctionTimeout and WithCallTimeout bound how long a call may run, returning a TimeoutError
+// once it has run too long. Errors that a call raises are returned as a PostgresError, while the notices, warnings,
+// and log messages that it reports are given to the Logger that the host sets through SetLogger.
+//
+// Library.ResourceUsage reports what each library has consumed in total. SetFunctionMetrics additionally records the
+// calls, latency, and allocations of each function, which Library.FunctionMetrics and AllFunctionMetrics return as
+// snapshots that hosts may publish through expvar or Prometheus. SetTracer gives each call a span of its own, whose
+// children are the spans of the functions that it calls in turn.
+//
+// # Cryptography
+//
+// The shim draws strong randomness (pg_strong_random, gen_random_uuid) from Go's crypto/rand, and computes the hashes
+// and HMACs of Postgres's own crypto API (pg_cryptohash, pg_hmac) with Go's crypto packages. It does not include
+// OpenSSL. Extensions that were built against OpenSSL, such as pgcrypto, link the system's libcrypto themselves, so
+// libcrypto must be installed wherever they're loaded, or loading fails with ErrLibraryNotFound naming libcrypto.
+// Their ciphers and digests then run within libcrypto, while the shim only provides the server functions around them.
+//
+// # Background workers
+//
+// Extensions register background workers from _PG_init, which the host runs on goroutines of their own rather than as
+// processes. Static workers are only accepted from libraries that were loaded with WithSharedPreload, and start once
+// the host calls StartBackgroundWorkers. BackgroundWorkers, StopBackgroundWorker, and SetBackgroundWorkerObserver let
+// the host supervise them, while SetBackgroundWorkerRestartPolicy decides which workers are restarted after they exit.
+// Signals that are sent to a worker, such as the SIGTERM that stops it, are delivered the next time it waits on its
+// latch. Workers share the backend lock with sessions (see BackendState.Run), so a worker only lets sessions and other
+// workers run while it waits, such as on its latch, and a worker that loops without waiting stalls every session.
+//
+// # Shared memory
+//
+// Extensions that coordinate through shared memory request it from their shmem_request_hook, and create their structs
+// from their shmem_startup_hook, which InitializeSharedMemory runs once the shared preload libraries are loaded. As
+// every session runs within the same process, shared memory is ordinary memory that is never freed, and LWLocks are
+// backed by Go locks. The LWLocks that a session holds are released when an error unwinds the call that acquired them.
+//
+// # Sessions and roles
+//
+// Each Session runs as the role that the host gives to Session.SetUserID, and as the bootstrap superuser until then.
+// Extensions that gate behavior on privileges call superuser and has_privs_of_role, which are answered by the
+// AuthProvider. Without one, the bootstrap superuser is the only role, so extensions treat every session as a
+// superuser. Session.SetSessionInfo gives the details that extensions embed in their logs, such as the process ID that
+// pg_backend_pid returns, the session's database, and its application_name. Session.RunContext and Session.Cancel
+// cancel the calls of a session, which raise query_canceled once the extension next checks CHECK_FOR_INTERRUPTS.
+//
+// Extensions keep their state in C globals, which every session of the process shares, so sessions do not run
+// concurrently. Session.Run may be called from any number of goroutines, but only one run holds the backend lock at a
+// time, and it yields the lock only while its session waits, such as on a latch, an LWLock, or a background worker.
+// Hosts that serve many connections therefore gain no parallelism from extension calls, and a call that runs for a long
+// time without waiting delays every other session.
+//
+// # Stability
+//
+// The exported identifiers of this package follow semantic versioning: within a major version, they are neither
+// removed nor changed incompatibly, and new options are added as functional options or new fields. The exceptions are
+// InternalLoadedLibrary, which is exported only so that each platform may implement it, and the stand-ins for C structs
+// such as PgMagicStruct, which follow the layout of the Postgres version that the shim emulates. The C functions that
+// the shim exports are an implementation detail, and may change in any release.
+//
+// The package is a single package rather than a set of subpackages, since discovery, loading, and calling all share the
+// state of the shim across the cgo boundary, and the callbacks that the shim makes into Go must be exported from the
+// package that includes its headers.
+package pg_extension
@@ -0,0 +1,46 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+var shimSetDatabaseEncoding = newShimProc("pgext_set_database_encoding")
+
+// SetDatabaseEncoding sets the encoding of the database, which extensions read through GetDatabaseEncoding and use to
+// verify and convert strings. The name may be any name that Postgres accepts for an encoding, such as UTF8 or LATIN1,
+// but it must be an encoding that a database may use. The default is UTF8. Strings are converted between UTF8 and
+// LATIN1 or WIN1252, as well as from SQL_ASCII, while other conversions raise an error within the extension.
+func SetDatabaseEncoding(name string) error {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	result, err := shimSetDatabaseEncoding.Call(uintptr(unsafe.Pointer(cName)))
+	if err != nil {
+		return err
+	}
+	switch result {
+	case 0:
+		return fmt.Errorf("`%s` is not a valid encoding name", name)
+	case 2:
+		return fmt.Errorf("`%s` is not a valid server encoding", name)
+	}
+	return nil
+}
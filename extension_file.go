@@ -12,110 +12,250 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package pg_extension
 
 import (
 	"cmp"
+	"errors"
 	"fmt"
+	"io/fs"
 	"maps"
-	"os"
-	"regexp"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
 )
 
-// sqlFunctionCapture is a regex to capture the function name as defined in the library. We'll eventually replace this
-// and use the nodes from the parser, but this is good enough for the default extensions.
-var sqlFunctionCapture = regexp.MustCompile(`(?is)create\s+(?:or\s+replace\s+)?function\s+(.*?)\s*\(.*?\)\s+(?:.*?language c.*?as\s+'.*?'\s*,\s*'(.*?)'.*?;|.*?as\s+'.*?'\s*,\s*'(.*?)'.*?language c.*?;|.*?language c.*?;)`)
-
-// createFunctionStart is a regex to find the beginning of a CREATE FUNCTION statement.
-var createFunctionStart = regexp.MustCompile(`(?is)create\s+(?:or\s+replace\s+)?function`)
-
 // ExtensionFiles contains all of the files that are related to or used by an extension.
 type ExtensionFiles struct {
 	Name            string
 	ControlFileName string
-	SQLFileNames    []string
-	LibraryFileName string
-	ControlFileDir  string
-	LibraryFileDir  string
+	// SecondaryControlFileNames maps versions to their secondary control files, which override the primary control file
+	// for that version.
+	SecondaryControlFileNames map[string]string
+	SQLFileNames              []string
+	LibraryFileName           string
+	// ControlFileDir is the location of the control and SQL files on the local filesystem. This is empty when the files
+	// do not come from the local filesystem.
+	ControlFileDir string
+	// LibraryFileDir is the location of the libraries on the local filesystem. This is empty when the libraries do not
+	// come from the local filesystem.
+	LibraryFileDir string
+	// ControlFS contains the control and SQL files, which are at the root of the filesystem.
+	ControlFS fs.FS
+	// LibraryFS contains the extension's library, which is at the root of the filesystem. The libraries of other
+	// extensions are also found here, since functions may reference them through $libdir.
+	LibraryFS fs.FS
 }
 
-// LoadExtensions loads information for all extensions that are in the extensions directory of a local Postgres installation.
+// LoadExtensions loads information for all extensions that are in the extensions directory of a local Postgres
+// installation, along with all bundled extensions. If there is no local installation, then only the bundled extensions
+// are returned. This is the same as calling LoadExtensionsWithOptions without any options, so the environment variables
+// ExtensionControlPathEnv and DynamicLibraryPathEnv are respected, and extensions that fail to load are reported in a
+// LoadReport alongside those that loaded successfully.
 func LoadExtensions() (map[string]*ExtensionFiles, error) {
-	libDir, extDir, err := PostgresDirectories()
-	if err != nil {
-		return nil, err
-	}
-	dirEntries, err := os.ReadDir(extDir)
+	return LoadExtensionsWithOptions()
+}
+
+// LoadExtensionsFS loads information for all extensions that are in the given filesystems. The control and SQL files
+// should be at the root of controlFS, while the libraries should be at the root of libraryFS. This allows extensions to
+// come from embedded filesystems, archives, etc. rather than only a local Postgres installation.
+func LoadExtensionsFS(controlFS fs.FS, libraryFS fs.FS) (map[string]*ExtensionFiles, error) {
+	dirEntries, err := fs.ReadDir(controlFS, ".")
 	if err != nil {
 		return nil, err
 	}
-	libEntries, err := os.ReadDir(libDir)
+	libEntries, err := fs.ReadDir(libraryFS, ".")
 	if err != nil {
 		return nil, err
 	}
+	controlFileNames := fileNames(dirEntries)
+	libraryFileNames := fileNames(libEntries)
 	extensionFiles := make(map[string]*ExtensionFiles)
 	// Look for the control files first
-	for _, dirEntry := range dirEntries {
-		fileName := dirEntry.Name()
-		if !dirEntry.IsDir() && strings.HasSuffix(fileName, ".control") {
+	for _, fileName := range controlFileNames {
+		// Secondary control files contain the version after the name, so they're associated after the primary files
+		if strings.HasSuffix(fileName, ".control") && !strings.Contains(fileName, "--") {
 			extensionName := strings.TrimSuffix(fileName, ".control")
 			extensionFiles[extensionName] = &ExtensionFiles{
 				Name:            extensionName,
 				ControlFileName: fileName,
-				ControlFileDir:  extDir,
+				ControlFS:       controlFS,
+				LibraryFS:       libraryFS,
 			}
 		}
 	}
-	// Associate the SQL files and libraries
-	for _, extFile := range extensionFiles {
-		for _, dirEntry := range dirEntries {
-			fileName := dirEntry.Name()
-			if !dirEntry.IsDir() && strings.HasPrefix(fileName, extFile.Name+"--") && strings.HasSuffix(fileName, ".sql") {
-				extFile.SQLFileNames = append(extFile.SQLFileNames, fileName)
+	// Associate the SQL files, secondary control files, and libraries
+	extFiles := slices.Collect(maps.Values(extensionFiles))
+	forEachParallel(len(extFiles), scanWorkers(), func(i int) {
+		extFiles[i].associateFiles(controlFileNames, libraryFileNames)
+	})
+	return extensionFiles, nil
+}
+
+// LoadExtensionFS loads information for the named extension from the given filesystems, which are laid out in the same
+// way as for LoadExtensionsFS. Only the files that begin with the extension's name are read. Returns a LoadError with
+// ErrExtensionNotAvailable when the control file does not exist.
+func LoadExtensionFS(name string, controlFS fs.FS, libraryFS fs.FS) (*ExtensionFiles, error) {
+	// These are the same restrictions that Postgres places on extension names
+	if len(name) == 0 || strings.ContainsAny(name, "/\\") || strings.Contains(name, "--") ||
+		strings.HasPrefix(name, "-") || strings.HasSuffix(name, "-") {
+		return nil, fmt.Errorf("invalid extension name: `%s`", name)
+	}
+	controlFileName := name + ".control"
+	if info, err := fs.Stat(controlFS, controlFileName); err != nil || info.IsDir() {
+		if err == nil || errors.Is(err, fs.ErrNotExist) {
+			return nil, &LoadError{
+				Kind:      ErrExtensionNotAvailable,
+				Extension: name,
+				File:      controlFileName,
 			}
 		}
-		for _, libEntry := range libEntries {
-			fileName := libEntry.Name()
-			if !libEntry.IsDir() && strings.HasPrefix(fileName, extFile.Name+".") {
-				extFile.LibraryFileName = fileName
-				extFile.LibraryFileDir = libDir
+		return nil, err
+	}
+	controlFileNames, err := prefixedFileNames(controlFS, name+"--")
+	if err != nil {
+		return nil, err
+	}
+	libraryFileNames, err := prefixedFileNames(libraryFS, name+".")
+	if err != nil {
+		return nil, err
+	}
+	extFile := &ExtensionFiles{
+		Name:            name,
+		ControlFileName: controlFileName,
+		ControlFS:       controlFS,
+		LibraryFS:       libraryFS,
+	}
+	extFile.associateFiles(controlFileNames, libraryFileNames)
+	return extFile, nil
+}
+
+// associateFiles finds the extension's SQL files and secondary control files from the names of the files within the
+// control filesystem, along with its library from the names of the files within the library filesystem.
+func (extFile *ExtensionFiles) associateFiles(controlFileNames []string, libraryFileNames []string) {
+	for _, fileName := range controlFileNames {
+		if !strings.HasPrefix(fileName, extFile.Name+"--") {
+			continue
+		}
+		if strings.HasSuffix(fileName, ".sql") {
+			extFile.SQLFileNames = append(extFile.SQLFileNames, fileName)
+		} else if strings.HasSuffix(fileName, ".control") {
+			version := strings.TrimSuffix(fileName[len(extFile.Name)+2:], ".control")
+			if extFile.SecondaryControlFileNames == nil {
+				extFile.SecondaryControlFileNames = make(map[string]string)
 			}
+			extFile.SecondaryControlFileNames[version] = fileName
+		}
+	}
+	for _, fileName := range libraryFileNames {
+		if !strings.HasPrefix(fileName, extFile.Name+".") {
+			continue
+		}
+		// Some installations contain libraries with multiple suffixes, so we prefer the one native to the platform
+		if len(extFile.LibraryFileName) == 0 || strings.HasSuffix(fileName, sharedLibrarySuffix) {
+			extFile.LibraryFileName = fileName
 		}
-		slices.SortFunc(extFile.SQLFileNames, func(aStr, bStr string) int {
-			a := sqlFileToVersions(extFile.Name, aStr)
-			b := sqlFileToVersions(extFile.Name, bStr)
-			return cmp.Or(
-				cmp.Compare(a[0], b[0]),
-				cmp.Compare(a[1], b[1]),
-			)
-		})
-		// Some SQL files are old migration files that won't apply to us, so we can remove them by starting at the first
-		// non-migration file.
-		for nextLoop := true; nextLoop; {
-			nextLoop = false
-			for i := 1; i < len(extFile.SQLFileNames); i++ {
-				if strings.Count(extFile.SQLFileNames[i], "--") == 1 {
-					extFile.SQLFileNames = extFile.SQLFileNames[i:]
-					nextLoop = true
-					break
-				}
+	}
+	slices.SortFunc(extFile.SQLFileNames, func(aStr, bStr string) int {
+		a := sqlFileToVersions(extFile.Name, aStr)
+		b := sqlFileToVersions(extFile.Name, bStr)
+		return cmp.Or(
+			cmp.Compare(a[0], b[0]),
+			cmp.Compare(a[1], b[1]),
+		)
+	})
+	// Some SQL files are old migration files that won't apply to us, so we can remove them by starting at the first
+	// non-migration file.
+	for nextLoop := true; nextLoop; {
+		nextLoop = false
+		for i := 1; i < len(extFile.SQLFileNames); i++ {
+			if strings.Count(extFile.SQLFileNames[i], "--") == 1 {
+				extFile.SQLFileNames = extFile.SQLFileNames[i:]
+				nextLoop = true
+				break
 			}
 		}
 	}
-	return extensionFiles, nil
+}
+
+// fileNames returns the names of the entries that are not directories.
+func fileNames(dirEntries []fs.DirEntry) []string {
+	names := make([]string, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() {
+			names = append(names, dirEntry.Name())
+		}
+	}
+	return names
+}
+
+// globEscaper escapes the characters that have a special meaning within glob patterns.
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`)
+
+// prefixedFileNames returns the names of the files at the root of the filesystem that begin with the prefix.
+func prefixedFileNames(fsys fs.FS, prefix string) ([]string, error) {
+	matches, err := fs.Glob(fsys, globEscaper.Replace(prefix)+"*")
+	if err != nil {
+		return nil, err
+	}
+	names := matches[:0]
+	for _, match := range matches {
+		if info, err := fs.Stat(fsys, match); err == nil && !info.IsDir() {
+			names = append(names, match)
+		}
+	}
+	return names, nil
 }
 
 // LoadControl loads the control file of an extension.
-func (extFile *ExtensionFiles) LoadControl() (string, error) {
-	data, err := os.ReadFile(fmt.Sprintf("%s/%s", extFile.ControlFileDir, extFile.ControlFileName))
+func (extFile *ExtensionFiles) LoadControl() (*Control, error) {
+	defer flushMetadataCache()
+	return extFile.loadControl()
+}
+
+// loadControl loads the control file of an extension, without writing the metadata cache.
+func (extFile *ExtensionFiles) loadControl() (*Control, error) {
+	cacheKey, stamp, cacheable := extFile.cacheStamp(extFile.ControlFileName)
+	if cacheable {
+		if control, ok := cachedControlFile(cacheKey, stamp); ok {
+			return control, nil
+		}
+	}
+	data, err := fs.ReadFile(extFile.ControlFS, extFile.ControlFileName)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	// TODO: create a Control struct and read the contents into that
-	return string(data), nil
+	control, err := ParseControl(extFile.ControlFileName, string(data))
+	if err != nil {
+		return nil, withExtension(err, extFile.Name)
+	}
+	if cacheable {
+		cacheControlFile(cacheKey, stamp, control)
+	}
+	return control, nil
+}
+
+// LoadControlForVersion loads the control file of an extension for the given version. If the version has a secondary
+// control file, then its settings are merged over the primary control file.
+func (extFile *ExtensionFiles) LoadControlForVersion(version string) (*Control, error) {
+	control, err := extFile.LoadControl()
+	if err != nil {
+		return nil, err
+	}
+	secondaryFileName, ok := extFile.SecondaryControlFileNames[version]
+	if !ok {
+		return control, nil
+	}
+	data, err := fs.ReadFile(extFile.ControlFS, secondaryFileName)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := control.WithSecondary(secondaryFileName, string(data))
+	if err != nil {
+		return nil, withExtension(err, extFile.Name)
+	}
+	return merged, nil
 }
 
 // LoadSQLFiles loads the contents of the SQL files used by the extension. These will be in the order that they need to
@@ -123,7 +263,7 @@ func (extFile *ExtensionFiles) LoadControl() (string, error) {
 func (extFile *ExtensionFiles) LoadSQLFiles() ([]string, error) {
 	sqlFiles := make([]string, len(extFile.SQLFileNames))
 	for i, sqlFileName := range extFile.SQLFileNames {
-		data, err := os.ReadFile(fmt.Sprintf("%s/%s", extFile.ControlFileDir, sqlFileName))
+		data, err := fs.ReadFile(extFile.ControlFS, sqlFileName)
 		if err != nil {
 			return nil, err
 		}
@@ -134,58 +274,189 @@ func (extFile *ExtensionFiles) LoadSQLFiles() ([]string, error) {
 
 // LoadSQLFunctionNames loads all of the library function names that are used by the extension.
 func (extFile *ExtensionFiles) LoadSQLFunctionNames() ([]string, error) {
+	defer flushMetadataCache()
+	scripts, err := extFile.loadAllScriptFunctions(scanWorkers())
+	if err != nil {
+		return nil, err
+	}
 	funcNames := make(map[string]struct{})
-	for _, sqlFileName := range extFile.SQLFileNames {
-		data, err := os.ReadFile(fmt.Sprintf("%s/%s", extFile.ControlFileDir, sqlFileName))
-		if err != nil {
-			return nil, err
-		}
-		fileRemaining := string(data)
-		for {
-			// We want to advance the file to the start of the next CREATE FUNCTION if one is present
-			startIdx := createFunctionStart.FindStringIndex(fileRemaining)
-			if startIdx == nil {
-				break
+	for _, fns := range scripts {
+		for _, fn := range fns {
+			if fn.Language == "c" {
+				funcNames[fn.Symbol()] = struct{}{}
 			}
-			fileRemaining = fileRemaining[startIdx[0]:]
-			// We capture the ending semicolon so the regex doesn't match beyond the function definition's boundaries.
-			endIdx := strings.IndexRune(fileRemaining, ';')
-			if endIdx == -1 {
-				break
-			}
-			matches := sqlFunctionCapture.FindStringSubmatch(fileRemaining[:endIdx+1])
-			switch len(matches) {
-			case 0:
-				break
-			case 4:
-				if len(matches[2]) > 0 {
-					funcNames[matches[2]] = struct{}{}
-				} else if len(matches[3]) > 0 {
-					funcNames[matches[3]] = struct{}{}
-				} else {
-					funcNames[matches[1]] = struct{}{}
-				}
-			default:
-				return nil, fmt.Errorf("invalid CREATE FUNCTION string: %s", string(data))
-			}
-			// We nudge it forward to guarantee that our next CREATE FUNCTION search will grab the next one
-			fileRemaining = fileRemaining[6:]
 		}
 	}
 	sortedFuncNames := slices.Sorted(maps.Keys(funcNames))
 	return sortedFuncNames, nil
 }
 
-// LoadLibrary loads the extension as a library.
+// LoadLibrary loads the extension's own library, along with any other libraries that its functions reference.
 func (extFile *ExtensionFiles) LoadLibrary() (*Library, error) {
 	if len(extFile.LibraryFileName) == 0 {
 		return nil, fmt.Errorf("extension `%s` does not reference a library", extFile.Name)
 	}
-	funcNames, err := extFile.LoadSQLFunctionNames()
+	libs, err := extFile.LoadLibraries()
 	if err != nil {
 		return nil, err
 	}
-	return LoadLibrary(fmt.Sprintf("%s/%s", extFile.LibraryFileDir, extFile.LibraryFileName), funcNames)
+	if lib, ok := libs[extFile.LibraryFileName]; ok {
+		return lib, nil
+	}
+	// None of the functions reference the extension's own library, but it's still loaded so that its initialization runs
+	libPath, err := extFile.libraryPath(extFile.LibraryFileName)
+	if err != nil {
+		return nil, err
+	}
+	lib, err := LoadLibrary(libPath, nil)
+	if err != nil {
+		return nil, withExtension(err, extFile.Name)
+	}
+	return lib, nil
+}
+
+// LoadLibraries loads every library that is referenced by the extension's C functions, which may include libraries that
+// belong to other extensions. The returned map is keyed by the library of each FunctionDefinition.
+func (extFile *ExtensionFiles) LoadLibraries() (map[string]*Library, error) {
+	return extFile.LoadLibrariesWithPolicy(nil)
+}
+
+// LoadLibrariesWithPolicy is the same as LoadLibraries, except that an error is returned without loading anything if
+// the given policy does not allow the extension. The policy should describe the user of the session that is loading
+// the extension. A nil policy allows every extension.
+func (extFile *ExtensionFiles) LoadLibrariesWithPolicy(policy ExtensionPolicy) (map[string]*Library, error) {
+	if err := extFile.checkPolicy(policy); err != nil {
+		return nil, err
+	}
+	definitions, err := extFile.LoadSQLFunctionDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	// A symbol may be shared by multiple SQL functions, so it's only as reusable as the most volatile of them, and only
+	// strict if all of them are strict
+	attributes := make(map[string]map[string]functionAttributes)
+	for _, definition := range definitions {
+		if definition.Language != "c" {
+			continue
+		}
+		libAttributes, ok := attributes[definition.Library]
+		if !ok {
+			libAttributes = make(map[string]functionAttributes)
+			attributes[definition.Library] = libAttributes
+		}
+		symbol := definition.Symbol()
+		if existing, ok := libAttributes[symbol]; ok {
+			libAttributes[symbol] = functionAttributes{
+				volatility:    min(existing.volatility, definition.Volatility),
+				strict:        existing.strict && definition.Strict,
+				referenceType: cmp.Or(existing.referenceType, wasmReferenceType(definition)),
+			}
+		} else {
+			libAttributes[symbol] = functionAttributes{
+				volatility:    definition.Volatility,
+				strict:        definition.Strict,
+				referenceType: wasmReferenceType(definition),
+			}
+		}
+	}
+	libs := make(map[string]*Library)
+	for _, libName := range slices.Sorted(maps.Keys(attributes)) {
+		libPath, err := extFile.libraryPath(libName)
+		if err != nil {
+			return nil, err
+		}
+		funcNames := slices.Sorted(maps.Keys(attributes[libName]))
+		lib, err := loadLibrary(libPath, funcNames, attributes[libName], nil)
+		if err != nil {
+			return nil, withExtension(err, extFile.Name)
+		}
+		libs[libName] = lib
+	}
+	return libs, nil
+}
+
+// cLibraryPaths returns the paths on the local filesystem of the libraries that contain the extension's C functions.
+func (extFile *ExtensionFiles) cLibraryPaths() ([]string, error) {
+	definitions, err := extFile.LoadSQLFunctionDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	var libNames []string
+	for _, definition := range definitions {
+		if definition.Language == "c" && !slices.Contains(libNames, definition.Library) {
+			libNames = append(libNames, definition.Library)
+		}
+	}
+	slices.Sort(libNames)
+	paths := make([]string, len(libNames))
+	for i, libName := range libNames {
+		if paths[i], err = extFile.libraryPath(libName); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// resolveObjFile returns the library that is referenced by the obj_file of a C function, which is the first string of
+// its AS clause. MODULE_PATHNAME and $libdir are expanded, and the platform's library suffix is added when the file
+// name omits it. Libraries that are within the library filesystem are returned as file names, while all others are
+// returned as given.
+func (extFile *ExtensionFiles) resolveObjFile(objFile string, control *Control) string {
+	if objFile == "MODULE_PATHNAME" {
+		if len(control.ModulePathname) == 0 {
+			return extFile.LibraryFileName
+		}
+		objFile = control.ModulePathname
+	}
+	if filepath.IsAbs(objFile) {
+		return objFile
+	}
+	name := strings.TrimPrefix(objFile, "$libdir/")
+	if extFile.LibraryFS == nil {
+		return name
+	}
+	for _, candidate := range []string{name, name + sharedLibrarySuffix, name + ".so", name + wasmLibrarySuffix} {
+		if info, err := fs.Stat(extFile.LibraryFS, candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+	}
+	return name
+}
+
+// libraryPath returns the path on the local filesystem of a library that was returned by resolveObjFile. Libraries must
+// be loaded by the operating system, so those within a library filesystem that is not on the local filesystem are
+// extracted first.
+func (extFile *ExtensionFiles) libraryPath(libName string) (string, error) {
+	if filepath.IsAbs(libName) {
+		return libName, nil
+	}
+	if extFile.LibraryFS == nil {
+		return "", &LoadError{
+			Kind:      ErrLibraryNotFound,
+			Extension: extFile.Name,
+			File:      "$libdir/" + libName,
+			Err:       errors.New("no library directory"),
+		}
+	}
+	if _, err := fs.Stat(extFile.LibraryFS, libName); err != nil {
+		return "", &LoadError{
+			Kind:      ErrLibraryNotFound,
+			Extension: extFile.Name,
+			File:      "$libdir/" + libName,
+			Err:       err,
+		}
+	}
+	if dirs, ok := extFile.LibraryFS.(dirListFS); ok {
+		return dirs.localPath(libName)
+	}
+	if len(extFile.LibraryFileDir) > 0 {
+		return filepath.Join(extFile.LibraryFileDir, filepath.FromSlash(libName)), nil
+	}
+	libDir, err := extractLibrary(extFile.LibraryFS, libName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(libDir, filepath.Base(libName)), nil
 }
 
 // sqlFileToVersions decodes the version information within the SQL file name.
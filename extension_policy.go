@@ -0,0 +1,76 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"fmt"
+)
+
+// ExtensionPolicy is implemented by the host to describe the user on whose behalf an extension's libraries are loaded,
+// such as the user that ran CREATE EXTENSION. The host gives each load the policy of its own session, through
+// WithExtensionPolicy, LoadLibrariesWithPolicy, or LoadLibraryCascadeWithPolicy, and it's consulted with the
+// extension's control file before any of the extension's libraries are loaded. Extensions are allowed through every
+// other way of loading them, which the host only uses for extensions that it trusts.
+type ExtensionPolicy interface {
+	// ExtensionPrivileges returns the privileges of the user that is loading the named extension.
+	ExtensionPrivileges(extension string, control *Control) ExtensionPrivileges
+}
+
+// ExtensionPrivileges are the privileges of a user that decide which extensions they may create.
+type ExtensionPrivileges struct {
+	// Superuser is true when the user is a superuser, who may create any extension.
+	Superuser bool
+	// CreateOnDatabase is true when the user has the CREATE privilege on the current database, which allows them to
+	// create trusted extensions.
+	CreateOnDatabase bool
+}
+
+// CheckExtensionPrivileges returns an error if a user with the given privileges may not create the named extension,
+// which has the given control file. Extensions that are not marked superuser = false require a superuser, unless they
+// are marked trusted = true, in which case a user with the CREATE privilege on the current database may create them.
+// The error is a PostgresError with the same SQLSTATE, message, and hint as Postgres.
+func CheckExtensionPrivileges(extension string, control *Control, privileges ExtensionPrivileges) error {
+	if !control.Superuser || privileges.Superuser {
+		return nil
+	}
+	hint := "Must be superuser to create this extension."
+	if control.Trusted {
+		if privileges.CreateOnDatabase {
+			return nil
+		}
+		hint = "Must have CREATE privilege on current database to create this extension."
+	}
+	return PostgresError{
+		Severity: "ERROR",
+		// insufficient_privilege
+		Code:    "42501",
+		Message: fmt.Sprintf(`permission denied to create extension "%s"`, extension),
+		Hint:    hint,
+	}
+}
+
+// checkPolicy returns an error if the given policy does not allow the extension to be loaded, which rejects extensions
+// that the user may not create in the same way as Postgres (see CheckExtensionPrivileges). A nil policy allows every
+// extension.
+func (extFile *ExtensionFiles) checkPolicy(policy ExtensionPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	control, err := extFile.loadControl()
+	if err != nil {
+		return err
+	}
+	return CheckExtensionPrivileges(extFile.Name, control, policy.ExtensionPrivileges(extFile.Name, control))
+}
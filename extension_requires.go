@@ -0,0 +1,173 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"maps"
+	"slices"
+)
+
+// ResolveRequires returns the named extensions along with every extension that they require, either directly or
+// through their prerequisites, from the given set of available extensions. The extensions are ordered so that each
+// comes after all of its prerequisites, as CREATE EXTENSION ... CASCADE installs them. Every prerequisite that is not
+// available is reported together in a LoadError with ErrMissingPrerequisite, and prerequisites that require each other
+// return a LoadError with ErrDependencyCycle.
+func ResolveRequires(available map[string]*ExtensionFiles, names ...string) ([]*ExtensionFiles, error) {
+	resolver := &requiresResolver{
+		available: available,
+		states:    make(map[string]requiresState),
+		missing:   make(map[string]struct{}),
+	}
+	for _, name := range names {
+		if err := resolver.visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	if len(resolver.missing) > 0 {
+		err := &LoadError{
+			Kind:          ErrMissingPrerequisite,
+			Prerequisites: slices.Sorted(maps.Keys(resolver.missing)),
+		}
+		if len(names) == 1 {
+			err.Extension = names[0]
+		}
+		return nil, err
+	}
+	return resolver.order, nil
+}
+
+// LoadLibraryCascade loads the libraries of the extension and of every extension that it requires, with the libraries
+// of prerequisites loaded first, so that libraries which reference the symbols of their prerequisites may find them.
+// The libraries are returned in the order that they were loaded, and each must be closed by the caller. If any library
+// fails to load, then the libraries that were already loaded are closed.
+func (extFile *ExtensionFiles) LoadLibraryCascade(available map[string]*ExtensionFiles) ([]*Library, error) {
+	return extFile.LoadLibraryCascadeWithPolicy(available, nil)
+}
+
+// LoadLibraryCascadeWithPolicy is the same as LoadLibraryCascade, except that the given policy must allow the extension
+// and each of its prerequisites, the same as CREATE EXTENSION ... CASCADE. Nothing is loaded when any of them is not
+// allowed. A nil policy allows every extension.
+func (extFile *ExtensionFiles) LoadLibraryCascadeWithPolicy(available map[string]*ExtensionFiles, policy ExtensionPolicy) ([]*Library, error) {
+	if _, ok := available[extFile.Name]; !ok {
+		available = maps.Clone(available)
+		if available == nil {
+			available = make(map[string]*ExtensionFiles)
+		}
+		available[extFile.Name] = extFile
+	}
+	order, err := ResolveRequires(available, extFile.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, ext := range order {
+		if err = ext.checkPolicy(policy); err != nil {
+			return nil, err
+		}
+	}
+	var loaded []*Library
+	for _, ext := range order {
+		libs, err := ext.loadAllLibraries()
+		if err != nil {
+			for i := len(loaded) - 1; i >= 0; i-- {
+				_ = loaded[i].Close()
+			}
+			return nil, err
+		}
+		loaded = append(loaded, libs...)
+	}
+	return loaded, nil
+}
+
+// loadAllLibraries loads the extension's own library, if it has one, along with any other libraries that its functions
+// reference. Extensions without any libraries return an empty slice.
+func (extFile *ExtensionFiles) loadAllLibraries() ([]*Library, error) {
+	libs, err := extFile.LoadLibraries()
+	if err != nil {
+		return nil, err
+	}
+	loaded := make([]*Library, 0, len(libs)+1)
+	for _, libName := range slices.Sorted(maps.Keys(libs)) {
+		loaded = append(loaded, libs[libName])
+	}
+	if _, ok := libs[extFile.LibraryFileName]; ok || len(extFile.LibraryFileName) == 0 {
+		return loaded, nil
+	}
+	// The extension's own library is loaded even when none of its functions reference it, so that its _PG_init runs
+	libPath, err := extFile.libraryPath(extFile.LibraryFileName)
+	if err == nil {
+		var lib *Library
+		if lib, err = LoadLibrary(libPath, nil); err == nil {
+			return append(loaded, lib), nil
+		}
+	}
+	for _, lib := range loaded {
+		_ = lib.Close()
+	}
+	return nil, withExtension(err, extFile.Name)
+}
+
+// requiresState is the progress of the resolver through a single extension.
+type requiresState uint8
+
+const (
+	requiresUnvisited requiresState = iota
+	requiresVisiting
+	requiresVisited
+)
+
+// requiresResolver orders extensions by their prerequisites using a depth-first search.
+type requiresResolver struct {
+	available map[string]*ExtensionFiles
+	states    map[string]requiresState
+	// missing contains every prerequisite that is not available.
+	missing map[string]struct{}
+	// order contains the extensions that have been visited, with each after its prerequisites.
+	order []*ExtensionFiles
+}
+
+// visit adds the extension to the order after all of its prerequisites. The path contains the extensions that are
+// currently being visited, which lead to this one.
+func (resolver *requiresResolver) visit(name string, path []string) error {
+	switch resolver.states[name] {
+	case requiresVisited:
+		return nil
+	case requiresVisiting:
+		cycle := append(slices.Clone(path[slices.Index(path, name):]), name)
+		return &LoadError{
+			Kind:          ErrDependencyCycle,
+			Extension:     name,
+			Prerequisites: cycle,
+		}
+	}
+	extFile, ok := resolver.available[name]
+	if !ok {
+		resolver.missing[name] = struct{}{}
+		return nil
+	}
+	control, err := extFile.LoadControl()
+	if err != nil {
+		return err
+	}
+	resolver.states[name] = requiresVisiting
+	path = append(path, name)
+	for _, prerequisite := range control.Requires {
+		if err = resolver.visit(prerequisite, path); err != nil {
+			return err
+		}
+	}
+	resolver.states[name] = requiresVisited
+	resolver.order = append(resolver.order, extFile)
+	return nil
+}
@@ -0,0 +1,449 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"cmp"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	// ExtensionControlPathEnv is the environment variable that, when set, replaces the directories that are searched for
+	// control files. It has the same format as WithExtensionControlPath.
+	ExtensionControlPathEnv = "PGEXT_EXTENSION_CONTROL_PATH"
+	// DynamicLibraryPathEnv is the environment variable that, when set, replaces the directories that are searched for
+	// libraries. It has the same format as WithDynamicLibraryPath.
+	DynamicLibraryPathEnv = "PGEXT_DYNAMIC_LIBRARY_PATH"
+)
+
+const (
+	// systemControlDir stands for the extension directory of the local Postgres installation.
+	systemControlDir = "$system"
+	// systemLibraryDir stands for the library directory of the local Postgres installation.
+	systemLibraryDir = "$libdir"
+)
+
+// LoadOption changes where LoadExtensionsWithOptions looks for extensions.
+type LoadOption func(*loadOptions)
+
+// loadOptions are the settings of LoadExtensionsWithOptions.
+type loadOptions struct {
+	// controlDirs are the directories that contain control files, which may include systemControlDir.
+	controlDirs []string
+	// libraryDirs are the directories that contain libraries, which may include systemLibraryDir.
+	libraryDirs      []string
+	ignoreEnv        bool
+	excludeBundled   bool
+	excludeInstalled bool
+	// majorVersion is the installed version of Postgres that "$system" and "$libdir" refer to, or zero for the one that
+	// is found through pg_config.
+	majorVersion int
+}
+
+// WithControlDirs adds directories that directly contain control and SQL files. Directories are searched in the order
+// that they're given, and an extension is taken from the first directory that contains its control file.
+func WithControlDirs(dirs ...string) LoadOption {
+	return func(opts *loadOptions) {
+		opts.controlDirs = append(opts.controlDirs, dirs...)
+	}
+}
+
+// WithLibraryDirs adds directories that contain libraries. Directories are searched in the order that they're given,
+// and a library is taken from the first directory that contains it.
+func WithLibraryDirs(dirs ...string) LoadOption {
+	return func(opts *loadOptions) {
+		opts.libraryDirs = append(opts.libraryDirs, dirs...)
+	}
+}
+
+// WithExtensionControlPath adds directories in the same format as Postgres' extension_control_path, which is a list of
+// directories separated by the platform's path list separator. Each directory is a share directory, with the control
+// files in its "extension" subdirectory. The entry "$system" refers to the extension directory of the local Postgres
+// installation.
+func WithExtensionControlPath(path string) LoadOption {
+	return func(opts *loadOptions) {
+		opts.controlDirs = append(opts.controlDirs, parseControlPath(path)...)
+	}
+}
+
+// WithDynamicLibraryPath adds directories in the same format as Postgres' dynamic_library_path, which is a list of
+// directories separated by the platform's path list separator. The entry "$libdir" refers to the library directory of
+// the local Postgres installation.
+func WithDynamicLibraryPath(path string) LoadOption {
+	return func(opts *loadOptions) {
+		opts.libraryDirs = append(opts.libraryDirs, splitSearchPath(path)...)
+	}
+}
+
+// WithoutEnvironment ignores ExtensionControlPathEnv and DynamicLibraryPathEnv.
+func WithoutEnvironment() LoadOption {
+	return func(opts *loadOptions) {
+		opts.ignoreEnv = true
+	}
+}
+
+// WithoutBundled excludes the extensions that were registered through RegisterBundledExtensions.
+func WithoutBundled() LoadOption {
+	return func(opts *loadOptions) {
+		opts.excludeBundled = true
+	}
+}
+
+// WithoutInstallation stops the local Postgres installation from being searched when no directories are given. The
+// "$system" and "$libdir" entries are also skipped.
+func WithoutInstallation() LoadOption {
+	return func(opts *loadOptions) {
+		opts.excludeInstalled = true
+	}
+}
+
+// WithPostgresVersion chooses the installed version of Postgres that "$system" and "$libdir" refer to, for systems that
+// install multiple versions side by side (see PostgresInstallations). Without this option, the installation is found
+// through pg_config, falling back to the newest installed version.
+func WithPostgresVersion(majorVersion int) LoadOption {
+	return func(opts *loadOptions) {
+		opts.majorVersion = majorVersion
+	}
+}
+
+// LoadExtensionsWithOptions loads information for all extensions that are in the configured directories, along with
+// all bundled extensions. Without any options, this searches the local Postgres installation in the same way as
+// LoadExtensions. When the environment variables ExtensionControlPathEnv or DynamicLibraryPathEnv are set, they replace
+// the control or library directories that were given. Directories that do not exist are skipped, and extensions from
+// a directory take precedence over bundled extensions with the same name.
+//
+// Every control file is parsed, and extensions whose control files are invalid are left out. Such extensions, along
+// with directories that cannot be read, are returned in a LoadReport together with all of the extensions that loaded
+// successfully, so callers may choose to only log the report.
+func LoadExtensionsWithOptions(options ...LoadOption) (map[string]*ExtensionFiles, error) {
+	opts := newLoadOptions(options)
+	extensionFiles := make(map[string]*ExtensionFiles)
+	report := &LoadReport{}
+	if !opts.excludeBundled {
+		bundledFiles, err := loadBundledExtensions()
+		if err != nil {
+			report.Failures = append(report.Failures, LoadFailure{Err: err})
+		} else {
+			extensionFiles = bundledFiles
+		}
+	}
+	controlDirs, libraryDirs, systemErr, err := opts.resolveDirs()
+	if err != nil {
+		return nil, err
+	}
+	if len(controlDirs) == 0 && len(extensionFiles) == 0 && len(report.Failures) == 0 && systemErr != nil {
+		return nil, systemErr
+	}
+	libraryFS := dirListFS(libraryDirs)
+	dirFiles := make([]map[string]*ExtensionFiles, len(controlDirs))
+	dirErrs := make([]error, len(controlDirs))
+	forEachParallel(len(controlDirs), scanWorkers(), func(i int) {
+		dirFiles[i], dirErrs[i] = LoadExtensionsFS(os.DirFS(controlDirs[i]), libraryFS)
+	})
+	found := make(map[string]struct{})
+	for i, controlDir := range controlDirs {
+		if dirErrs[i] != nil {
+			report.Failures = append(report.Failures, LoadFailure{Dir: controlDir, Err: dirErrs[i]})
+			continue
+		}
+		for name, extFile := range dirFiles[i] {
+			if _, ok := found[name]; ok {
+				continue
+			}
+			found[name] = struct{}{}
+			setLocalDirs(extFile, controlDir, libraryDirs)
+			extensionFiles[name] = extFile
+		}
+	}
+	report.Failures = append(report.Failures, validateControlFiles(extensionFiles)...)
+	if len(report.Failures) > 0 {
+		slices.SortFunc(report.Failures, func(a, b LoadFailure) int {
+			return cmp.Or(strings.Compare(a.Dir, b.Dir), strings.Compare(a.Extension, b.Extension))
+		})
+		return extensionFiles, report
+	}
+	return extensionFiles, nil
+}
+
+// validateControlFiles parses the control file of every extension concurrently, removing the extensions whose control
+// files cannot be parsed. Returns a failure for each extension that was removed.
+func validateControlFiles(extensionFiles map[string]*ExtensionFiles) []LoadFailure {
+	defer flushMetadataCache()
+	extFiles := slices.Collect(maps.Values(extensionFiles))
+	errs := make([]error, len(extFiles))
+	forEachParallel(len(extFiles), scanWorkers(), func(i int) {
+		_, errs[i] = extFiles[i].loadControl()
+	})
+	var failures []LoadFailure
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, LoadFailure{
+				Extension: extFiles[i].Name,
+				Dir:       extFiles[i].ControlFileDir,
+				Err:       err,
+			})
+			delete(extensionFiles, extFiles[i].Name)
+		}
+	}
+	return failures
+}
+
+// LoadExtension loads information for a single extension, searching the same directories as LoadExtensionsWithOptions
+// with the same options. Only the files that belong to the extension are read, so this is faster than loading every
+// extension when only one is needed, such as for CREATE EXTENSION. Returns a LoadError with ErrExtensionNotAvailable
+// when the extension cannot be found.
+func LoadExtension(name string, options ...LoadOption) (*ExtensionFiles, error) {
+	opts := newLoadOptions(options)
+	controlDirs, libraryDirs, systemErr, err := opts.resolveDirs()
+	if err != nil {
+		return nil, err
+	}
+	libraryFS := dirListFS(libraryDirs)
+	for _, controlDir := range controlDirs {
+		extFile, err := LoadExtensionFS(name, os.DirFS(controlDir), libraryFS)
+		if errors.Is(err, ErrExtensionNotAvailable) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		setLocalDirs(extFile, controlDir, libraryDirs)
+		return extFile, nil
+	}
+	if !opts.excludeBundled {
+		extFile, err := loadBundledExtension(name)
+		if !errors.Is(err, ErrExtensionNotAvailable) {
+			return extFile, err
+		}
+	}
+	return nil, &LoadError{
+		Kind:      ErrExtensionNotAvailable,
+		Extension: name,
+		File:      name + ".control",
+		Err:       systemErr,
+	}
+}
+
+// newLoadOptions applies the options, followed by the environment variables and the defaults.
+func newLoadOptions(options []LoadOption) *loadOptions {
+	opts := &loadOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+	if !opts.ignoreEnv {
+		if path := os.Getenv(ExtensionControlPathEnv); len(path) > 0 {
+			opts.controlDirs = parseControlPath(path)
+		}
+		if path := os.Getenv(DynamicLibraryPathEnv); len(path) > 0 {
+			opts.libraryDirs = splitSearchPath(path)
+		}
+	}
+	if opts.controlDirs == nil {
+		opts.controlDirs = []string{systemControlDir}
+	}
+	if opts.libraryDirs == nil {
+		opts.libraryDirs = []string{systemLibraryDir}
+	}
+	return opts
+}
+
+// resolveDirs returns the control and library directories that exist, with "$system" and "$libdir" replaced by the
+// directories of the Postgres installation. If the installation could not be found, then its directories are skipped
+// and systemErr describes why.
+func (opts *loadOptions) resolveDirs() (controlDirs []string, libraryDirs []string, systemErr error, err error) {
+	controlDirs, libraryDirs, systemErr = opts.expandDirs()
+	if controlDirs, err = existingDirs(controlDirs); err != nil {
+		return nil, nil, nil, err
+	}
+	if libraryDirs, err = existingDirs(libraryDirs); err != nil {
+		return nil, nil, nil, err
+	}
+	return controlDirs, libraryDirs, systemErr, nil
+}
+
+// expandDirs returns the control and library directories with "$system" and "$libdir" replaced by the directories of
+// the Postgres installation, which are skipped if the installation could not be found.
+func (opts *loadOptions) expandDirs() (controlDirs []string, libraryDirs []string, systemErr error) {
+	var systemLibDir, systemExtDir string
+	if !opts.excludeInstalled &&
+		(slices.Contains(opts.controlDirs, systemControlDir) || slices.Contains(opts.libraryDirs, systemLibraryDir)) {
+		systemLibDir, systemExtDir, systemErr = opts.systemDirectories()
+	}
+	return expandDirs(opts.controlDirs, systemControlDir, systemExtDir),
+		expandDirs(opts.libraryDirs, systemLibraryDir, systemLibDir), systemErr
+}
+
+// setLocalDirs sets the local directories of an extension that was found within the control directory.
+func setLocalDirs(extFile *ExtensionFiles, controlDir string, libraryDirs []string) {
+	extFile.ControlFileDir = controlDir
+	if len(libraryDirs) == 1 {
+		extFile.LibraryFileDir = libraryDirs[0]
+	}
+}
+
+// systemDirectories returns the library and extension directories of the chosen Postgres installation. When no version
+// was chosen, pg_config is preferred, unless the directories that it reports do not exist. This happens on
+// Debian-family systems when pg_config comes from a different version than the installed servers.
+func (opts *loadOptions) systemDirectories() (libDir string, extensionDir string, err error) {
+	if opts.majorVersion == 0 {
+		libDir, extensionDir, err = PostgresDirectories()
+		if err == nil && isDir(extensionDir) {
+			return libDir, extensionDir, nil
+		}
+	}
+	installation, installErr := FindPostgresInstallation(opts.majorVersion)
+	if installErr != nil {
+		if opts.majorVersion == 0 {
+			return libDir, extensionDir, err
+		}
+		return "", "", installErr
+	}
+	return installation.LibraryDir, installation.ExtensionDir, nil
+}
+
+// parseControlPath splits an extension_control_path into the directories that directly contain the control files.
+func parseControlPath(path string) []string {
+	dirs := splitSearchPath(path)
+	for i, dir := range dirs {
+		if dir != systemControlDir {
+			dirs[i] = filepath.Join(dir, "extension")
+		}
+	}
+	return dirs
+}
+
+// splitSearchPath splits the list of directories, ignoring empty entries.
+func splitSearchPath(path string) []string {
+	var dirs []string
+	for _, dir := range filepath.SplitList(path) {
+		if dir = strings.TrimSpace(dir); len(dir) > 0 {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// expandDirs returns the directories with the placeholder replaced by the given system directory. The placeholder is
+// skipped when the system directory is empty.
+func expandDirs(dirs []string, placeholder string, systemDir string) []string {
+	expanded := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		if dir != placeholder {
+			expanded = append(expanded, dir)
+		} else if len(systemDir) > 0 {
+			expanded = append(expanded, systemDir)
+		}
+	}
+	return expanded
+}
+
+// existingDirs returns the directories that exist, with duplicate directories only returned once.
+func existingDirs(dirs []string) ([]string, error) {
+	var existing []string
+	for _, dir := range dirs {
+		info, err := os.Stat(dir)
+		if errors.Is(err, fs.ErrNotExist) || (err == nil && !info.IsDir()) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(existing, dir) {
+			existing = append(existing, dir)
+		}
+	}
+	return existing, nil
+}
+
+// dirListFS is a filesystem made up of directories on the local filesystem, where each file is taken from the first
+// directory that contains it.
+type dirListFS []string
+
+var _ fs.ReadDirFS = dirListFS{}
+var _ fs.StatFS = dirListFS{}
+
+// Open implements the interface fs.FS.
+func (dirs dirListFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	for _, dir := range dirs {
+		file, err := os.DirFS(dir).Open(name)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return file, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir implements the interface fs.ReadDirFS. The entries of every directory are combined, with entries from earlier
+// directories hiding those of the same name from later directories.
+func (dirs dirListFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	var entries []fs.DirEntry
+	seen := make(map[string]struct{})
+	for _, dir := range dirs {
+		dirEntries, err := fs.ReadDir(os.DirFS(dir), name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, entry := range dirEntries {
+			if _, ok := seen[entry.Name()]; !ok {
+				seen[entry.Name()] = struct{}{}
+				entries = append(entries, entry)
+			}
+		}
+	}
+	if len(entries) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
+// Stat implements the interface fs.StatFS.
+func (dirs dirListFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	for _, dir := range dirs {
+		info, err := fs.Stat(os.DirFS(dir), name)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return info, err
+		}
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// localPath returns the path on the local filesystem of the file, from the first directory that contains it.
+func (dirs dirListFS) localPath(name string) (string, error) {
+	for _, dir := range dirs {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
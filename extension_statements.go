@@ -0,0 +1,187 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// StatementKind is the broad category of a statement within an extension's scripts, which hosts use to decide whether
+// to execute a statement themselves or to intercept it.
+type StatementKind uint8
+
+const (
+	// StatementOther is any statement that does not fall into another category, such as SET or DO.
+	StatementOther StatementKind = iota
+	// StatementFunction creates or modifies a function, procedure, or aggregate.
+	StatementFunction
+	// StatementType creates or modifies a type or domain.
+	StatementType
+	// StatementOperator creates or modifies an operator, operator class, or operator family.
+	StatementOperator
+	StatementCast
+	// StatementTable creates or modifies a table, view, sequence, or index.
+	StatementTable
+	StatementComment
+	// StatementGrant is a GRANT or REVOKE.
+	StatementGrant
+)
+
+// ExtensionStatement is a single statement from an extension's scripts.
+type ExtensionStatement struct {
+	// Script is the name of the SQL file that the statement came from.
+	Script string
+	Kind   StatementKind
+	// Action is the leading command of the statement in uppercase, such as CREATE, ALTER, or GRANT.
+	Action string
+	// ObjectKind is the kind of object that the statement acts on in uppercase, such as FUNCTION or OPERATOR CLASS. This
+	// is empty for statements that do not act on an object.
+	ObjectKind string
+	// ObjectName is the name of the object that the statement acts on, which may be schema-qualified.
+	ObjectName string
+	// Text is the statement without its terminating semicolon, with @extschema@ and MODULE_PATHNAME replaced.
+	Text string
+}
+
+// String returns the name of the statement kind.
+func (kind StatementKind) String() string {
+	switch kind {
+	case StatementFunction:
+		return "function"
+	case StatementType:
+		return "type"
+	case StatementOperator:
+		return "operator"
+	case StatementCast:
+		return "cast"
+	case StatementTable:
+		return "table"
+	case StatementComment:
+		return "comment"
+	case StatementGrant:
+		return "grant"
+	default:
+		return "other"
+	}
+}
+
+// LoadSQLStatements returns the statements of the extension's scripts in the order that they'd be executed to install
+// the extension into the given schema. Each statement is tagged with its kind, so that the host may execute the
+// statements that it supports itself and intercept the rest. The schema is chosen the same as PrepareScripts, but the
+// statements that set the search path and create a fixed schema are not included. psql meta-commands are omitted, as
+// they're never executed by CREATE EXTENSION.
+func (extFile *ExtensionFiles) LoadSQLStatements(schema string) ([]ExtensionStatement, error) {
+	control, schema, _, err := extFile.installSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	var statements []ExtensionStatement
+	for _, sqlFileName := range extFile.SQLFileNames {
+		data, err := fs.ReadFile(extFile.ControlFS, sqlFileName)
+		if err != nil {
+			return nil, err
+		}
+		script := prepareScriptText(string(data), control, schema)
+		for _, stmt := range splitSQLStatements(script) {
+			if stmt.Tokens[0].Kind == sqlTokenMetaCommand {
+				continue
+			}
+			action, kind, name, _ := classifySQLStatement(stmt.Tokens)
+			statements = append(statements, ExtensionStatement{
+				Script:     sqlFileName,
+				Kind:       classifyStatementKind(action, kind),
+				Action:     action,
+				ObjectKind: kind,
+				ObjectName: name,
+				Text:       stmt.Text,
+			})
+		}
+	}
+	return statements, nil
+}
+
+// classifyStatementKind returns the kind of a statement from its action and the kind of object that it acts on.
+func classifyStatementKind(action string, objectKind string) StatementKind {
+	switch action {
+	case "COMMENT":
+		return StatementComment
+	case "GRANT", "REVOKE":
+		return StatementGrant
+	case "CREATE", "ALTER", "DROP":
+	default:
+		return StatementOther
+	}
+	switch objectKind {
+	case "FUNCTION", "PROCEDURE", "AGGREGATE", "ROUTINE":
+		return StatementFunction
+	case "TYPE", "DOMAIN":
+		return StatementType
+	case "OPERATOR", "OPERATOR CLASS", "OPERATOR FAMILY":
+		return StatementOperator
+	case "CAST":
+		return StatementCast
+	case "TABLE", "FOREIGN TABLE", "VIEW", "MATERIALIZED VIEW", "SEQUENCE", "INDEX":
+		return StatementTable
+	default:
+		return StatementOther
+	}
+}
+
+// installSchema returns the schema that the extension is installed into when the given schema is requested, along with
+// whether the schema must be created because the extension declares it. Extensions that declare a fixed schema must be
+// installed into that schema, which an empty schema selects.
+func (extFile *ExtensionFiles) installSchema(schema string) (*Control, string, bool, error) {
+	control, err := extFile.LoadControl()
+	if err != nil {
+		return nil, "", false, err
+	}
+	if len(control.Schema) > 0 {
+		if len(schema) > 0 && schema != control.Schema {
+			return nil, "", false, fmt.Errorf(`extension "%s" must be installed in schema "%s"`, extFile.Name,
+				control.Schema)
+		}
+		return control, control.Schema, true, nil
+	}
+	if len(schema) == 0 {
+		return nil, "", false, fmt.Errorf("no schema has been selected to create in")
+	}
+	return control, schema, false, nil
+}
+
+// prepareScriptText prepares the text of a script the same as CREATE EXTENSION does before executing it. Lines that
+// begin with \echo are removed, as they form the psql guard that stops scripts from being run directly, which ends
+// with \quit on the same line. Then the variables are replaced, although @extschema@ is only replaced for extensions
+// that are not relocatable, as relocatable extensions cannot know their schema.
+func prepareScriptText(script string, control *Control, schema string) string {
+	if strings.Contains(script, "\\echo") {
+		lines := strings.SplitAfter(script, "\n")
+		for i, line := range lines {
+			if strings.HasPrefix(line, "\\echo") {
+				// The line break is kept so that the lines of errors still match the file
+				lines[i] = line[len(strings.TrimRight(line, "\r\n")):]
+			}
+		}
+		script = strings.Join(lines, "")
+	}
+	if !control.Relocatable {
+		script = strings.ReplaceAll(script, "@extschema@", quoteSQLIdentifier(schema))
+	}
+	if len(control.ModulePathname) > 0 {
+		script = strings.ReplaceAll(script, "MODULE_PATHNAME", control.ModulePathname)
+	}
+	return script
+}
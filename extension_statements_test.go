@@ -0,0 +1,78 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import "testing"
+
+func TestPrepareScriptText(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		control  Control
+		expected string
+	}{
+		{
+			name: "psql guard",
+			script: "-- complain if script is sourced in psql\n" +
+				"\\echo Use \"CREATE EXTENSION ext\" to load this file. \\quit\n" +
+				"SELECT 1;\n",
+			control:  Control{Relocatable: true},
+			expected: "-- complain if script is sourced in psql\n\nSELECT 1;\n",
+		},
+		{
+			name:     "psql guard with CRLF line endings",
+			script:   "\\echo Use \"CREATE EXTENSION ext\" to load this file. \\quit\r\nSELECT 1;\r\n",
+			control:  Control{Relocatable: true},
+			expected: "\r\nSELECT 1;\r\n",
+		},
+		{
+			name:     "psql guard on the last line",
+			script:   "SELECT 1;\n\\echo done",
+			control:  Control{Relocatable: true},
+			expected: "SELECT 1;\n",
+		},
+		{
+			name:     "echo that does not begin a line",
+			script:   "SELECT '\\echo';\n",
+			control:  Control{Relocatable: true},
+			expected: "SELECT '\\echo';\n",
+		},
+		{
+			name:     "module pathname",
+			script:   "CREATE FUNCTION f() RETURNS int AS 'MODULE_PATHNAME', 'f' LANGUAGE c;\n",
+			control:  Control{Relocatable: true, ModulePathname: "$libdir/ext"},
+			expected: "CREATE FUNCTION f() RETURNS int AS '$libdir/ext', 'f' LANGUAGE c;\n",
+		},
+		{
+			name:     "schema of a relocatable extension",
+			script:   "CREATE TABLE @extschema@.t ();\n",
+			control:  Control{Relocatable: true},
+			expected: "CREATE TABLE @extschema@.t ();\n",
+		},
+		{
+			name:     "schema of an extension that is not relocatable",
+			script:   "CREATE TABLE @extschema@.t ();\n",
+			control:  Control{},
+			expected: "CREATE TABLE \"my schema\".t ();\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := prepareScriptText(test.script, &test.control, "my schema"); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
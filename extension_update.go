@@ -0,0 +1,163 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// UpdatePath is the shortest chain of update scripts that updates an extension from one version to another, which
+// mirrors a row of pg_extension_update_paths.
+type UpdatePath struct {
+	Source string
+	Target string
+	// Versions are the versions that the extension passes through, beginning with the source and ending with the target.
+	// This is nil when there is no path between the versions.
+	Versions []string
+	// Scripts are the names of the update scripts that are run, in order.
+	Scripts []string
+}
+
+// updateGraph contains the versions of an extension, along with the update scripts that lead from each version.
+type updateGraph struct {
+	versions map[string]struct{}
+	// installable contains the versions that have an install script.
+	installable map[string]struct{}
+	// edges maps each version to the versions that it may be updated to, along with the script of each update.
+	edges map[string]map[string]string
+}
+
+// UpdatePaths returns the shortest update path between every pair of distinct versions of the extension, in the same
+// manner as pg_extension_update_paths. Versions are found from the names of both the install and update scripts.
+func (extFile *ExtensionFiles) UpdatePaths() ([]UpdatePath, error) {
+	graph, err := extFile.loadUpdateGraph()
+	if err != nil {
+		return nil, err
+	}
+	versions := slices.Sorted(maps.Keys(graph.versions))
+	var paths []UpdatePath
+	for _, source := range versions {
+		for _, target := range versions {
+			if source != target {
+				paths = append(paths, graph.shortestPath(source, target))
+			}
+		}
+	}
+	return paths, nil
+}
+
+// UpdateScripts returns the names of the update scripts that update the extension from one version to another, in the
+// order that they must be run, as ALTER EXTENSION ... UPDATE does. When the target is empty, the default version of the
+// control file is used. No scripts are returned when the versions are the same.
+func (extFile *ExtensionFiles) UpdateScripts(from string, to string) ([]string, error) {
+	if len(to) == 0 {
+		control, err := extFile.LoadControl()
+		if err != nil {
+			return nil, err
+		}
+		to = control.DefaultVersion
+		if len(to) == 0 {
+			return nil, fmt.Errorf("extension `%s` does not specify a default version to update to", extFile.Name)
+		}
+	}
+	if from == to {
+		return nil, nil
+	}
+	graph, err := extFile.loadUpdateGraph()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := graph.versions[to]; !ok {
+		return nil, fmt.Errorf("extension `%s` has no installation script nor update path for version `%s`",
+			extFile.Name, to)
+	}
+	path := graph.shortestPath(from, to)
+	if path.Versions == nil {
+		return nil, fmt.Errorf("extension `%s` has no update path from version `%s` to version `%s`", extFile.Name,
+			from, to)
+	}
+	return path.Scripts, nil
+}
+
+// loadUpdateGraph reads the names of the extension's scripts to find its versions and updates. Every script in the
+// control filesystem is read, including the old update scripts that are omitted from SQLFileNames.
+func (extFile *ExtensionFiles) loadUpdateGraph() (*updateGraph, error) {
+	dirEntries, err := fs.ReadDir(extFile.ControlFS, ".")
+	if err != nil {
+		return nil, err
+	}
+	graph := &updateGraph{
+		versions:    make(map[string]struct{}),
+		installable: make(map[string]struct{}),
+		edges:       make(map[string]map[string]string),
+	}
+	prefix := extFile.Name + "--"
+	for _, dirEntry := range dirEntries {
+		fileName := dirEntry.Name()
+		if dirEntry.IsDir() || !strings.HasPrefix(fileName, prefix) || !strings.HasSuffix(fileName, ".sql") {
+			continue
+		}
+		parts := strings.Split(strings.TrimSuffix(fileName[len(prefix):], ".sql"), "--")
+		switch {
+		case len(parts) == 1 && len(parts[0]) > 0:
+			graph.versions[parts[0]] = struct{}{}
+			graph.installable[parts[0]] = struct{}{}
+		case len(parts) == 2 && len(parts[0]) > 0 && len(parts[1]) > 0:
+			graph.versions[parts[0]] = struct{}{}
+			graph.versions[parts[1]] = struct{}{}
+			if graph.edges[parts[0]] == nil {
+				graph.edges[parts[0]] = make(map[string]string)
+			}
+			graph.edges[parts[0]][parts[1]] = fileName
+		}
+	}
+	return graph, nil
+}
+
+// shortestPath returns the path with the fewest updates between the versions. Updates are explored in the order of
+// their target versions, so that the same path is always chosen among those of equal length.
+func (graph *updateGraph) shortestPath(source string, target string) UpdatePath {
+	path := UpdatePath{Source: source, Target: target}
+	previous := map[string]string{source: ""}
+	queue := []string{source}
+	for len(queue) > 0 {
+		version := queue[0]
+		queue = queue[1:]
+		if version == target {
+			break
+		}
+		for _, next := range slices.Sorted(maps.Keys(graph.edges[version])) {
+			if _, ok := previous[next]; !ok {
+				previous[next] = version
+				queue = append(queue, next)
+			}
+		}
+	}
+	if _, ok := previous[target]; !ok {
+		return path
+	}
+	for version := target; version != source; version = previous[version] {
+		path.Versions = append(path.Versions, version)
+		path.Scripts = append(path.Scripts, graph.edges[previous[version]][version])
+	}
+	path.Versions = append(path.Versions, source)
+	slices.Reverse(path.Versions)
+	slices.Reverse(path.Scripts)
+	return path
+}
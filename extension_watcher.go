@@ -0,0 +1,283 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ExtensionChange describes how the available extensions changed, which is given to the callback of an
+// ExtensionWatcher.
+type ExtensionChange struct {
+	// Extensions are all of the extensions that are now available, as returned by LoadExtensionsWithOptions.
+	Extensions map[string]*ExtensionFiles
+	// Added are the names of the extensions that were not available before, sorted by name.
+	Added []string
+	// Removed are the names of the extensions that are no longer available, sorted by name.
+	Removed []string
+	// Changed are the names of the extensions whose control, SQL, or library files were added, removed, or modified,
+	// sorted by name. This includes extensions that gained a new version.
+	Changed []string
+	// Invalidated are the paths of the loaded libraries whose files were added, removed, or modified, sorted. These are no
+	// longer shared with later loads, which open the changed file instead.
+	Invalidated []string
+	// Err is the error from loading the extensions, which is generally a LoadReport.
+	Err error
+}
+
+// ExtensionWatcher watches the directories that extensions are loaded from, reloading the extensions whenever their
+// files change. Changes are found through filesystem notifications, and the directories are also polled, since
+// notifications are unreliable on network and container filesystems, and are unavailable on some platforms.
+type ExtensionWatcher struct {
+	options  []LoadOption
+	onChange func(ExtensionChange)
+	// notifier reports changes within the directories that exist, which is nil when notifications are unavailable.
+	notifier *fsnotify.Watcher
+	// extensions are the extensions from the most recent load, and fingerprints identify the files of each.
+	extensions   map[string]*ExtensionFiles
+	fingerprints map[string]map[string]fileStamp
+	// dirs are the control and library directories, which may not exist yet. dirStamps identify the entries of every
+	// directory, which are compared on each poll.
+	dirs      []string
+	dirStamps map[string]fileStamp
+	mutex     sync.Mutex
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// WatchExtensions loads the extensions with the given options, and then checks their directories for changes whenever
+// the filesystem reports one, along with on every interval. When a change is found, the extensions are loaded again and
+// onChange is called from the watcher's goroutine with the differences, which the host may use to refresh data such as
+// pg_available_extensions. The parsed contents of changed files are invalidated through the metadata cache, since its
+// entries are tied to each file's size and modification time. Loaded libraries whose files changed are invalidated, so
+// that later loads open the changed file, while the references that were already returned continue to use the old
+// library until they're closed. The watcher must be closed once it's no longer needed.
+func WatchExtensions(interval time.Duration, onChange func(ExtensionChange),
+	options ...LoadOption) (*ExtensionWatcher, error) {
+	if interval <= 0 {
+		return nil, errors.New("extension watcher interval must be positive")
+	}
+	watcher := &ExtensionWatcher{
+		options:  slices.Clone(options),
+		onChange: onChange,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	controlDirs, libraryDirs, _ := newLoadOptions(watcher.options).expandDirs()
+	watcher.dirs = append(controlDirs, libraryDirs...)
+	if notifier, err := fsnotify.NewWatcher(); err == nil {
+		watcher.notifier = notifier
+		watcher.watchDirs()
+	}
+	watcher.dirStamps = watcher.snapshotDirs()
+	extensions, err := LoadExtensionsWithOptions(watcher.options...)
+	var report *LoadReport
+	if err != nil && !errors.As(err, &report) {
+		if watcher.notifier != nil {
+			_ = watcher.notifier.Close()
+		}
+		return nil, err
+	}
+	watcher.extensions = extensions
+	watcher.fingerprints = fingerprintExtensions(extensions)
+	go watcher.run(interval)
+	return watcher, nil
+}
+
+// Extensions returns the extensions from the most recent load. The returned map must not be modified.
+func (watcher *ExtensionWatcher) Extensions() map[string]*ExtensionFiles {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+	return watcher.extensions
+}
+
+// Close stops the watcher, waiting for any callback that is running to return.
+func (watcher *ExtensionWatcher) Close() error {
+	watcher.closeOnce.Do(func() {
+		close(watcher.stop)
+	})
+	<-watcher.done
+	return nil
+}
+
+// watcherSettleDelay is how long the watcher waits after a notification before checking the directories. A file is
+// usually written through several events, such as a create followed by writes, which are handled by a single check.
+const watcherSettleDelay = 100 * time.Millisecond
+
+// run checks for changes on every interval and after each notification until the watcher is closed.
+func (watcher *ExtensionWatcher) run(interval time.Duration) {
+	defer close(watcher.done)
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if watcher.notifier != nil {
+		defer watcher.notifier.Close()
+		events = watcher.notifier.Events
+		errs = watcher.notifier.Errors
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var settle <-chan time.Time
+	for {
+		select {
+		case <-watcher.stop:
+			return
+		case <-ticker.C:
+			watcher.check()
+		case _, ok := <-events:
+			if !ok {
+				events = nil
+			} else if settle == nil {
+				settle = time.After(watcherSettleDelay)
+			}
+		case _, ok := <-errs:
+			// Changes that a notification was dropped for are found by the next poll
+			if !ok {
+				errs = nil
+			}
+		case <-settle:
+			settle = nil
+			watcher.check()
+		}
+	}
+}
+
+// check reloads the extensions if anything changed, calling onChange with the differences.
+func (watcher *ExtensionWatcher) check() {
+	watcher.watchDirs()
+	if change, ok := watcher.poll(); ok && watcher.onChange != nil {
+		watcher.onChange(change)
+	}
+}
+
+// watchDirs adds the directories that are not yet watched to the notifier. Directories that do not exist are added once
+// they're created, which is found by a later poll.
+func (watcher *ExtensionWatcher) watchDirs() {
+	if watcher.notifier == nil {
+		return
+	}
+	watched := watcher.notifier.WatchList()
+	for _, dir := range watcher.dirs {
+		if !slices.Contains(watched, filepath.Clean(dir)) {
+			_ = watcher.notifier.Add(dir)
+		}
+	}
+}
+
+// poll reloads the extensions if any of the watched directories have changed. Returns false if nothing changed.
+func (watcher *ExtensionWatcher) poll() (ExtensionChange, bool) {
+	dirStamps := watcher.snapshotDirs()
+	if maps.Equal(dirStamps, watcher.dirStamps) {
+		return ExtensionChange{}, false
+	}
+	var changedPaths []string
+	for path, stamp := range dirStamps {
+		if oldStamp, ok := watcher.dirStamps[path]; !ok || oldStamp != stamp {
+			changedPaths = append(changedPaths, path)
+		}
+	}
+	for path := range watcher.dirStamps {
+		if _, ok := dirStamps[path]; !ok {
+			changedPaths = append(changedPaths, path)
+		}
+	}
+	invalidated := invalidateLibraries(changedPaths)
+	watcher.dirStamps = dirStamps
+	extensions, err := LoadExtensionsWithOptions(watcher.options...)
+	var report *LoadReport
+	if err != nil && !errors.As(err, &report) {
+		// The directories are checked again on the next poll, since they may be in the middle of changing
+		watcher.dirStamps = nil
+		return ExtensionChange{Invalidated: invalidated, Err: err}, true
+	}
+	fingerprints := fingerprintExtensions(extensions)
+	change := ExtensionChange{Extensions: extensions, Invalidated: invalidated, Err: err}
+	for _, name := range slices.Sorted(maps.Keys(fingerprints)) {
+		if oldFingerprint, ok := watcher.fingerprints[name]; !ok {
+			change.Added = append(change.Added, name)
+		} else if !maps.Equal(oldFingerprint, fingerprints[name]) {
+			change.Changed = append(change.Changed, name)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(watcher.fingerprints)) {
+		if _, ok := fingerprints[name]; !ok {
+			change.Removed = append(change.Removed, name)
+		}
+	}
+	watcher.mutex.Lock()
+	watcher.extensions = extensions
+	watcher.mutex.Unlock()
+	watcher.fingerprints = fingerprints
+	if len(change.Added) == 0 && len(change.Removed) == 0 && len(change.Changed) == 0 && len(change.Invalidated) == 0 &&
+		err == nil {
+		return ExtensionChange{}, false
+	}
+	return change, true
+}
+
+// snapshotDirs returns the stamps of every entry within the control and library directories, keyed by path. Directories
+// that do not exist are skipped.
+func (watcher *ExtensionWatcher) snapshotDirs() map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	for _, dir := range watcher.dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if info, err := entry.Info(); err == nil {
+				stamps[filepath.Join(dir, entry.Name())] = fileStamp{
+					Size:    info.Size(),
+					ModTime: info.ModTime().UnixNano(),
+				}
+			}
+		}
+	}
+	return stamps
+}
+
+// fingerprintExtensions returns the stamps of the files that belong to each extension, keyed by extension and then by
+// file name.
+func fingerprintExtensions(extensions map[string]*ExtensionFiles) map[string]map[string]fileStamp {
+	fingerprints := make(map[string]map[string]fileStamp, len(extensions))
+	for name, extFile := range extensions {
+		fingerprint := make(map[string]fileStamp)
+		fileNames, _ := prefixedFileNames(extFile.ControlFS, extFile.Name+"--")
+		for _, fileName := range append(fileNames, extFile.ControlFileName) {
+			if info, err := fs.Stat(extFile.ControlFS, fileName); err == nil {
+				fingerprint[fileName] = fileStamp{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+			}
+		}
+		if len(extFile.LibraryFileName) > 0 && extFile.LibraryFS != nil {
+			if info, err := fs.Stat(extFile.LibraryFS, extFile.LibraryFileName); err == nil {
+				fingerprint["$libdir/"+extFile.LibraryFileName] = fileStamp{
+					Size:    info.Size(),
+					ModTime: info.ModTime().UnixNano(),
+				}
+			}
+		}
+		fingerprints[name] = fingerprint
+	}
+	return fingerprints
+}
@@ -12,29 +12,98 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package pg_extension
 
 import (
 	"bytes"
+	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 )
 
 // PostgresDirectories returns the installation directories of a local Postgres instance.
+// PgConfigEnv is the environment variable that names the pg_config of the Postgres installation to use, which is
+// either a path or the name of an executable on the PATH. This takes precedence over any pg_config on the PATH.
+const PgConfigEnv = "PG_CONFIG"
+
+// PostgresDirectories returns the library and extension directories of the local Postgres installation, as reported by
+// its pg_config. The library directory is the package library directory that $libdir refers to, while the extension
+// directory is within the shared directory. These are queried separately since some distributions place them under
+// different roots.
 func PostgresDirectories() (libDir string, extensionDir string, err error) {
-	var buffer bytes.Buffer
-	cmd := exec.Command("pg_config", "--pkglibdir")
-	cmd.Stdout = &buffer
-	if err := cmd.Run(); err != nil {
+	pgConfig, err := findPgConfig()
+	if err != nil {
 		return "", "", err
 	}
-	libDir = strings.TrimSpace(buffer.String())
-	buffer.Reset()
-	cmd = exec.Command("pg_config", "--sharedir")
-	cmd.Stdout = &buffer
-	if err := cmd.Run(); err != nil {
-		return "", "", err
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(pgConfig, "--pkglibdir", "--sharedir")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); len(msg) > 0 {
+			return "", "", fmt.Errorf("`%s` failed: %s", pgConfig, msg)
+		}
+		return "", "", fmt.Errorf("`%s` failed: %w", pgConfig, err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 {
+		return "", "", fmt.Errorf("`%s` returned unexpected output: %q", pgConfig, stdout.String())
 	}
-	extensionDir = strings.TrimSpace(buffer.String()) + "/extension"
+	libDir = strings.TrimSpace(lines[0])
+	extensionDir = filepath.Join(strings.TrimSpace(lines[1]), "extension")
 	return libDir, extensionDir, nil
 }
+
+// findPgConfig returns the path of pg_config. The one named by PgConfigEnv is used when set, followed by the one on the
+// PATH, and then those within the common installation directories of the platform.
+func findPgConfig() (string, error) {
+	if name := os.Getenv(PgConfigEnv); len(name) > 0 {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			return "", fmt.Errorf("cannot use the pg_config named by %s: %w", PgConfigEnv, err)
+		}
+		return path, nil
+	}
+	if path, err := exec.LookPath("pg_config"); err == nil {
+		return path, nil
+	}
+	for _, pattern := range pgConfigSearchPaths {
+		matches, err := filepath.Glob(filepath.Join(os.ExpandEnv(pattern), "pg_config"))
+		if err != nil {
+			continue
+		}
+		// We prefer the newest version when multiple versions are installed side by side
+		slices.SortFunc(matches, compareVersionedPaths)
+		for i := len(matches) - 1; i >= 0; i-- {
+			if info, err := os.Stat(matches[i]); err == nil && !info.IsDir() {
+				return matches[i], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("cannot find pg_config on the PATH or in any common installation directory")
+}
+
+func compareVersionedPaths(a string, b string) int {
+	for len(a) > 0 && len(b) > 0 {
+		aDigits := len(a) - len(strings.TrimLeft(a, "0123456789"))
+		bDigits := len(b) - len(strings.TrimLeft(b, "0123456789"))
+		if aDigits > 0 && bDigits > 0 {
+			aNum, _ := strconv.Atoi(a[:aDigits])
+			bNum, _ := strconv.Atoi(b[:bDigits])
+			if aNum != bNum {
+				return aNum - bNum
+			}
+			a, b = a[aDigits:], b[bDigits:]
+			continue
+		}
+		if a[0] != b[0] {
+			return int(a[0]) - int(b[0])
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) - len(b)
+}
@@ -0,0 +1,31 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build darwin
+
+package pg_extension
+
+// pgConfigSearchPaths are the directories that are searched for pg_config when it is not on the PATH. These cover
+// Postgres.app and Homebrew on both Apple Silicon and Intel machines. Each path may contain glob patterns.
+var pgConfigSearchPaths = []string{
+	"/Applications/Postgres.app/Contents/Versions/latest/bin",
+	"$HOME/Applications/Postgres.app/Contents/Versions/latest/bin",
+	"/Applications/Postgres.app/Contents/Versions/*/bin",
+	"/opt/homebrew/opt/postgresql@*/bin",
+	"/opt/homebrew/opt/postgresql/bin",
+	"/usr/local/opt/postgresql@*/bin",
+	"/usr/local/opt/postgresql/bin",
+	"/opt/homebrew/bin",
+	"/usr/local/bin",
+}
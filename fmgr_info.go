@@ -0,0 +1,262 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+
+extern bool pgextHostLookupFunction(uint32_t oid, FmgrInfo* finfo);
+extern Datum pgextHostCallBuiltin(FunctionCallInfo fcinfo, int* sqlerrcode, char** message);
+
+static inline PgExtFunctionLookup* NewHostFunctionLookup() {
+	PgExtFunctionLookup* lookup = (PgExtFunctionLookup*)malloc(sizeof(PgExtFunctionLookup));
+	lookup->lookup = (bool (*)(Oid, FmgrInfo*))pgextHostLookupFunction;
+	lookup->call = pgextHostCallBuiltin;
+	return lookup;
+}
+
+static inline void SetFmgrInfoAddr(FmgrInfo* finfo, uintptr_t fn) {
+	finfo->fn_addr = (void*)fn;
+}
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// FmgrInfo is a reusable handle for calling a function. Functions may cache state in the handle between calls, such as
+// within fn_extra, which persists until the handle is closed. A handle should be used for every call to the same
+// function within a statement or session, and may be used by one call at a time.
+type FmgrInfo struct {
+	mutex *sync.Mutex
+	fn    Function
+	info  *C.FmgrInfo
+}
+
+// BuiltinFunction is a function of the host that extensions call by OID, such as through OidFunctionCall1 or
+// fmgr_info. The function is either implemented in C, in which case Ptr is the address of a function that follows the
+// version 1 calling convention, or in Go, in which case Call is set.
+type BuiltinFunction struct {
+	Name    string
+	NumArgs int
+	// Strict is true for functions that return NULL without being called when any argument is NULL. Callers are
+	// responsible for checking this, as the function is still given every call.
+	Strict bool
+	Ptr    uintptr
+	// Call is given the collation and arguments of each call. By-reference results must be allocated within the
+	// current memory context, such as with Palloc, as the extension takes ownership of them. Errors are raised within
+	// the extension, keeping the SQLSTATE of a PostgresError.
+	Call func(collation uint32, args []NullableDatum) (result Datum, isNull bool, err error)
+}
+
+// BuiltinResolver resolves the OIDs of functions that extensions call without having defined them, which are usually
+// the builtin functions of the host. Functions that are registered with RegisterFunctionOID take precedence.
+type BuiltinResolver interface {
+	// ResolveBuiltin returns the function with the given OID, or false if the host has no such function.
+	ResolveBuiltin(oid uint32) (BuiltinFunction, bool)
+}
+
+var (
+	// registeredFunctions contains the functions that extensions may look up by OID through fmgr_info.
+	registeredFunctions = make(map[uint32]Function)
+	// registeredFunctionsMutex gates access to the registered functions.
+	registeredFunctionsMutex = &sync.RWMutex{}
+	// currentBuiltinResolver resolves the OIDs that have not been registered, or is nil if there is no resolver.
+	currentBuiltinResolver BuiltinResolver
+	// resolvedBuiltins contains the Go functions that have been resolved, so that calls do not resolve them again.
+	resolvedBuiltins = make(map[uint32]BuiltinFunction)
+	// hostFunctionLookup is the C struct that forwards to the registered functions.
+	hostFunctionLookup = sync.OnceValue(func() *C.PgExtFunctionLookup {
+		return C.NewHostFunctionLookup()
+	})
+	shimSetFunctionLookup = newShimProc("pgext_set_function_lookup")
+	shimBuiltinCall       = newShimProc("pgext_builtin_call")
+	shimFmgrInfoInit      = newShimProc("pgext_fmgr_info_init")
+	shimFmgrInfoFree      = newShimProc("pgext_fmgr_info_free")
+)
+
+// NewFmgrInfo returns a reusable handle for calling the given function, which reports the given OID to the function.
+// The handle must be closed once it is no longer needed.
+func NewFmgrInfo(fn Function, oid uint32) (*FmgrInfo, error) {
+	info := Malloc[C.FmgrInfo]()
+	ZeroMemory(info)
+	fillFmgrInfo(info, fn)
+	info.fn_oid = C.uint32_t(oid)
+	ok, err := shimFmgrInfoInit.Call(uintptr(unsafe.Pointer(info)))
+	if err != nil {
+		Free(info)
+		return nil, err
+	}
+	if ok == 0 {
+		Free(info)
+		return nil, fmt.Errorf("out of memory while creating the call handle of `%s`", fn.Name)
+	}
+	return &FmgrInfo{
+		mutex: &sync.Mutex{},
+		fn:    fn,
+		info:  info,
+	}, nil
+}
+
+// Function returns the function that the handle calls.
+func (fi *FmgrInfo) Function() Function {
+	return fi.fn
+}
+
+// Call calls the function through the handle, recording the call against the resource usage of its library. STRICT
+// functions return NULL without being called when any argument is NULL.
+func (fi *FmgrInfo) Call(args ...NullableDatum) (result Datum, isNotNull bool, err error) {
+	return fi.CallColl(0, args...)
+}
+
+// CallColl is the same as Call, except that the function is called with the given collation.
+func (fi *FmgrInfo) CallColl(collation uint32, args ...NullableDatum) (result Datum, isNotNull bool, err error) {
+	unlock := lockBackend()
+	defer unlock()
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	if fi.info == nil {
+		return 0, false, fmt.Errorf("the call handle of `%s` has been closed", fi.fn.Name)
+	}
+	result, isNull, _, err := fi.fn.callWithInfo(fi.info, callNodes{}, collation, args...)
+	if err != nil {
+		return 0, false, err
+	}
+	return result, !isNull && result != 0, nil
+}
+
+// Close frees the handle, along with all state that the function cached within it.
+func (fi *FmgrInfo) Close() error {
+	unlock := lockBackend()
+	defer unlock()
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	if fi.info == nil {
+		return nil
+	}
+	_, err := shimFmgrInfoFree.Call(uintptr(unsafe.Pointer(fi.info)))
+	Free(fi.info)
+	fi.info = nil
+	return err
+}
+
+// RegisterFunctionOID registers the function under the given OID, so that extensions may look it up through fmgr_info.
+func RegisterFunctionOID(oid uint32, fn Function) error {
+	registeredFunctionsMutex.Lock()
+	first := len(registeredFunctions) == 0
+	registeredFunctions[oid] = fn
+	registeredFunctionsMutex.Unlock()
+	if first {
+		_, err := shimSetFunctionLookup.Call(uintptr(unsafe.Pointer(hostFunctionLookup())))
+		return err
+	}
+	return nil
+}
+
+// UnregisterFunctionOID removes the function that was registered under the given OID.
+func UnregisterFunctionOID(oid uint32) {
+	registeredFunctionsMutex.Lock()
+	defer registeredFunctionsMutex.Unlock()
+	delete(registeredFunctions, oid)
+}
+
+// SetBuiltinResolver sets the resolver that looks up the functions that extensions call by OID, when they have not been
+// registered with RegisterFunctionOID. Setting nil causes such calls to raise an error, which is the default.
+func SetBuiltinResolver(resolver BuiltinResolver) error {
+	registeredFunctionsMutex.Lock()
+	currentBuiltinResolver = resolver
+	clear(resolvedBuiltins)
+	registeredFunctionsMutex.Unlock()
+	_, err := shimSetFunctionLookup.Call(uintptr(unsafe.Pointer(hostFunctionLookup())))
+	return err
+}
+
+// fillFmgrInfo sets the fields of the FmgrInfo that describe the function.
+func fillFmgrInfo(info *C.FmgrInfo, fn Function) {
+	C.SetFmgrInfoAddr(info, C.uintptr_t(fn.Ptr))
+	info.fn_nargs = C.short(len(fn.Args))
+	info.fn_strict = C.bool(fn.Strict)
+}
+
+//export pgextHostLookupFunction
+func pgextHostLookupFunction(oid C.uint32_t, finfo *C.FmgrInfo) C.bool {
+	registeredFunctionsMutex.RLock()
+	fn, ok := registeredFunctions[uint32(oid)]
+	resolver := currentBuiltinResolver
+	registeredFunctionsMutex.RUnlock()
+	if ok {
+		fillFmgrInfo(finfo, fn)
+		return true
+	}
+	if resolver == nil {
+		return false
+	}
+	builtin, ok := resolver.ResolveBuiltin(uint32(oid))
+	if !ok {
+		return false
+	}
+	ptr := builtin.Ptr
+	if builtin.Call != nil {
+		var err error
+		if ptr, err = shimBuiltinCall.addr(); err != nil {
+			return false
+		}
+		registeredFunctionsMutex.Lock()
+		resolvedBuiltins[uint32(oid)] = builtin
+		registeredFunctionsMutex.Unlock()
+	}
+	if ptr == 0 {
+		return false
+	}
+	C.SetFmgrInfoAddr(finfo, C.uintptr_t(ptr))
+	finfo.fn_nargs = C.short(builtin.NumArgs)
+	finfo.fn_strict = C.bool(builtin.Strict)
+	return true
+}
+
+//export pgextHostCallBuiltin
+func pgextHostCallBuiltin(fcinfo C.FunctionCallInfo, sqlerrcode *C.int, message **C.char) C.Datum {
+	oid := uint32(fcinfo.flinfo.fn_oid)
+	registeredFunctionsMutex.RLock()
+	builtin, ok := resolvedBuiltins[oid]
+	registeredFunctionsMutex.RUnlock()
+	if !ok {
+		*message = C.CString(fmt.Sprintf("function %d is not implemented by the host", oid))
+		return 0
+	}
+	args := make([]NullableDatum, int(fcinfo.nargs))
+	if len(args) > 0 {
+		for i, arg := range unsafe.Slice(&fcinfo.args[0], len(args)) {
+			args[i] = NullableDatum{Value: Datum(arg.value), IsNull: bool(arg.isnull)}
+		}
+	}
+	result, isNull, err := builtin.Call(uint32(fcinfo.fncollation), args)
+	if err != nil {
+		var pgErr PostgresError
+		if errors.As(err, &pgErr) {
+			*sqlerrcode = C.int(encodeSQLState(pgErr.Code))
+			*message = C.CString(pgErr.Message)
+		} else {
+			*message = C.CString(err.Error())
+		}
+		return 0
+	}
+	fcinfo.isnull = C.bool(isNull)
+	return C.Datum(result)
+}
@@ -0,0 +1,179 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FunctionMetrics is a snapshot of the calls that were made to a single function of a library while function metrics
+// were enabled. Snapshots contain only exported fields of basic types, so hosts may publish them through expvar.Func,
+// or export them as Prometheus counters and histograms.
+type FunctionMetrics struct {
+	Function string
+	Calls    uint64
+	// Errors is the number of calls that returned an error, including those that crashed or timed out.
+	Errors uint64
+	// Latency is the wall-clock duration of the calls, whose count is Calls.
+	Latency LatencyHistogram
+	// AllocatedBytes is the total of the bytes that the calls had allocated through palloc by the time that they
+	// returned, which includes memory that was freed afterward along with the call's memory context.
+	AllocatedBytes int64
+}
+
+// LatencyHistogram is a histogram of call durations, whose buckets are cumulative, the same as a Prometheus histogram.
+type LatencyHistogram struct {
+	// Buckets hold the number of calls that took at most each bound, in increasing order of their bounds. Calls that
+	// took longer than the last bound are only counted by the total.
+	Buckets []LatencyBucket
+	Sum     time.Duration
+}
+
+// LatencyBucket is a single bucket of a LatencyHistogram.
+type LatencyBucket struct {
+	UpperBound time.Duration
+	Count      uint64
+}
+
+// latencyBounds are the upper bounds of the buckets of every latency histogram. Most extension functions return within
+// microseconds, so the buckets begin far below those that Prometheus uses by default.
+var latencyBounds = [...]time.Duration{
+	time.Microsecond,
+	5 * time.Microsecond,
+	10 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// functionMetricsEnabled is set while calls are recorded. Recording reads the clock around every call, so it's
+// disabled by default.
+var functionMetricsEnabled atomic.Bool
+
+// functionMetricsTable holds the metrics of each function of a library that has been called, keyed by the function's
+// name.
+type functionMetricsTable struct {
+	functions sync.Map
+}
+
+// functionCounters are the metrics of a single function. All fields are updated atomically, so they may be modified
+// from any thread.
+type functionCounters struct {
+	calls          atomic.Uint64
+	errors         atomic.Uint64
+	latencyNanos   atomic.Int64
+	buckets        [len(latencyBounds)]atomic.Uint64
+	allocatedBytes atomic.Int64
+}
+
+// SetFunctionMetrics enables or disables the recording of per-function metrics, which are disabled by default. Metrics
+// that were recorded are kept while recording is disabled, and are read through Library.FunctionMetrics and
+// AllFunctionMetrics.
+func SetFunctionMetrics(enabled bool) {
+	functionMetricsEnabled.Store(enabled)
+}
+
+// FunctionMetrics returns the metrics of each function of the library that has been called while function metrics were
+// enabled, ordered by the function's name.
+func (lib *Library) FunctionMetrics() []FunctionMetrics {
+	return lib.metrics.snapshot()
+}
+
+// AllFunctionMetrics returns the metrics of the functions of every loaded library, keyed by the library's path.
+// Libraries whose functions have not been called while function metrics were enabled are omitted.
+func AllFunctionMetrics() map[string][]FunctionMetrics {
+	loadedLibrariesMutex.Lock()
+	defer loadedLibrariesMutex.Unlock()
+	metrics := make(map[string][]FunctionMetrics, len(loadedLibraries))
+	for path, lib := range loadedLibraries {
+		if snapshot := lib.metrics.snapshot(); len(snapshot) > 0 {
+			metrics[path] = snapshot
+		}
+	}
+	return metrics
+}
+
+// start returns the time at which a call started, or the zero time when function metrics are disabled, in which case
+// the call is not recorded.
+func (table *functionMetricsTable) start() time.Time {
+	if !functionMetricsEnabled.Load() {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// record records a call of the named function that started at the given time.
+func (table *functionMetricsTable) record(name string, start time.Time, allocated int64, err error) {
+	if start.IsZero() {
+		return
+	}
+	latency := time.Since(start)
+	value, ok := table.functions.Load(name)
+	if !ok {
+		value, _ = table.functions.LoadOrStore(name, &functionCounters{})
+	}
+	counters := value.(*functionCounters)
+	counters.calls.Add(1)
+	if err != nil {
+		counters.errors.Add(1)
+	}
+	counters.latencyNanos.Add(int64(latency))
+	if i, _ := slices.BinarySearch(latencyBounds[:], latency); i < len(latencyBounds) {
+		counters.buckets[i].Add(1)
+	}
+	counters.allocatedBytes.Add(allocated)
+}
+
+// snapshot returns the current values of the metrics, ordered by the function's name.
+func (table *functionMetricsTable) snapshot() []FunctionMetrics {
+	var metrics []FunctionMetrics
+	table.functions.Range(func(key, value any) bool {
+		counters := value.(*functionCounters)
+		histogram := LatencyHistogram{
+			Buckets: make([]LatencyBucket, len(latencyBounds)),
+			Sum:     time.Duration(counters.latencyNanos.Load()),
+		}
+		var cumulative uint64
+		for i, bound := range latencyBounds {
+			cumulative += counters.buckets[i].Load()
+			histogram.Buckets[i] = LatencyBucket{UpperBound: bound, Count: cumulative}
+		}
+		metrics = append(metrics, FunctionMetrics{
+			Function:       key.(string),
+			Calls:          counters.calls.Load(),
+			Errors:         counters.errors.Load(),
+			Latency:        histogram,
+			AllocatedBytes: counters.allocatedBytes.Load(),
+		})
+		return true
+	})
+	slices.SortFunc(metrics, func(a, b FunctionMetrics) int {
+		return strings.Compare(a.Function, b.Function)
+	})
+	return metrics
+}
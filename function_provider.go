@@ -0,0 +1,495 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/big"
+	"runtime"
+	"slices"
+	"time"
+)
+
+// ProvidedFunction is a C function of an extension, along with its signature, which a SQL engine may register as one of
+// its own functions. Arguments and results are Go values, which are converted to and from Datums according to the
+// function's types.
+type ProvidedFunction struct {
+	Definition *FunctionDefinition
+	// Function is the C function that is called, which is zero when the function is called within a sandbox worker.
+	Function Function
+	// ParameterTypes are the types of the function's input parameters, in the order that they're given.
+	ParameterTypes []PostgresType
+	ReturnType     PostgresType
+	// sandbox is the worker that calls the function, which is nil when the function is called directly.
+	sandbox *sandboxWorker
+	// sandboxIndex is the position of the function among the C functions that were loaded by the worker.
+	sandboxIndex int
+}
+
+// UnsupportedFunction is a function of an extension that a FunctionProvider cannot provide.
+type UnsupportedFunction struct {
+	Definition *FunctionDefinition
+	Err        error
+}
+
+// FunctionProvider provides the C functions of an extension to a SQL engine, such as Doltgres. The engine wraps each
+// ProvidedFunction within its own function interface, using the types to resolve overloads and the OIDs to identify
+// the types, and calls it with the values of its arguments. The provider holds a reference to each of the extension's
+// libraries, so it must be closed once its functions are no longer registered.
+type FunctionProvider struct {
+	// Functions are the functions that may be called, in the order that the extension's scripts create them.
+	Functions []*ProvidedFunction
+	// Unsupported are the C functions whose types or calling conventions cannot be provided, such as set-returning
+	// functions and those that take types without a Go conversion.
+	Unsupported []UnsupportedFunction
+	libraries   []*Library
+	sandbox     *sandboxWorker
+}
+
+// NewFunctionProvider loads the libraries of the extension, and returns a provider for its C functions. Functions that
+// are not written in C, such as those written in SQL, are ignored, as the engine runs them itself. The libraries are
+// loaded within a worker process instead when WithSandbox is given, so each extension may choose whether it's trusted.
+func NewFunctionProvider(extFile *ExtensionFiles, options ...ProviderOption) (*FunctionProvider, error) {
+	var opts providerOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	definitions, err := extFile.LoadSQLFunctionDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	if opts.sandbox {
+		return newSandboxedFunctionProvider(extFile, definitions, opts)
+	}
+	libs, err := extFile.LoadLibrariesWithPolicy(opts.policy)
+	if err != nil {
+		return nil, err
+	}
+	provider := &FunctionProvider{}
+	for _, libName := range slices.Sorted(maps.Keys(libs)) {
+		provider.libraries = append(provider.libraries, libs[libName])
+	}
+	for _, definition := range definitions {
+		if definition.Language != "c" {
+			continue
+		}
+		fn, err := newProvidedFunction(definition, libs[definition.Library])
+		if err != nil {
+			provider.Unsupported = append(provider.Unsupported, UnsupportedFunction{Definition: definition, Err: err})
+		} else {
+			fn.Function.timeout = opts.callTimeout
+			provider.Functions = append(provider.Functions, fn)
+		}
+	}
+	return provider, nil
+}
+
+// newSandboxedFunctionProvider returns a provider whose functions are called within a worker process, which loads the
+// extension's libraries in place of the host.
+func newSandboxedFunctionProvider(extFile *ExtensionFiles, definitions []*FunctionDefinition, opts providerOptions) (*FunctionProvider, error) {
+	// The worker loads the libraries without a policy of its own, so the host's policy is checked here
+	if err := extFile.checkPolicy(opts.policy); err != nil {
+		return nil, err
+	}
+	worker, unsupported, err := newSandboxWorker(extFile, opts.callTimeout)
+	if err != nil {
+		return nil, err
+	}
+	provider := &FunctionProvider{sandbox: worker}
+	index := 0
+	for _, definition := range definitions {
+		if definition.Language != "c" {
+			continue
+		}
+		if index >= len(unsupported) {
+			worker.close()
+			return nil, fmt.Errorf("sandbox worker of `%s` loaded different functions than the host", extFile.Name)
+		}
+		fn, err := newProvidedSignature(definition)
+		if err == nil && len(unsupported[index]) > 0 {
+			err = errors.New(unsupported[index])
+		}
+		if err != nil {
+			provider.Unsupported = append(provider.Unsupported, UnsupportedFunction{Definition: definition, Err: err})
+		} else {
+			fn.sandbox = worker
+			fn.sandboxIndex = index
+			provider.Functions = append(provider.Functions, fn)
+		}
+		index++
+	}
+	return provider, nil
+}
+
+// Close releases the provider's references to the extension's libraries, and stops its sandbox worker. None of its
+// functions may be called afterward.
+func (provider *FunctionProvider) Close() error {
+	if provider.sandbox != nil {
+		provider.sandbox.close()
+	}
+	var errs []error
+	for _, lib := range provider.libraries {
+		errs = append(errs, lib.Close())
+	}
+	provider.libraries = nil
+	return errors.Join(errs...)
+}
+
+// Lookup returns the function with the given name whose parameters have the given type OIDs. Returns false if there
+// is no such function.
+func (provider *FunctionProvider) Lookup(name string, parameterOIDs ...uint32) (*ProvidedFunction, bool) {
+	for _, fn := range provider.Functions {
+		if fn.Definition.Name != name || len(fn.ParameterTypes) != len(parameterOIDs) {
+			continue
+		}
+		matches := true
+		for i, typ := range fn.ParameterTypes {
+			if typ.OID != parameterOIDs[i] {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return fn, true
+		}
+	}
+	return nil, false
+}
+
+// newProvidedFunction returns the function of the definition, which was loaded from the given library. Returns an
+// error if the function cannot be provided.
+func newProvidedFunction(definition *FunctionDefinition, lib *Library) (*ProvidedFunction, error) {
+	fn, err := newProvidedSignature(definition)
+	if err != nil {
+		return nil, err
+	}
+	if lib == nil {
+		return nil, fmt.Errorf("library `%s` was not loaded", definition.Library)
+	}
+	var ok bool
+	if fn.Function, ok = lib.Function(definition.Symbol()); !ok {
+		return nil, fmt.Errorf("symbol `%s` was not loaded from `%s`", definition.Symbol(), definition.Library)
+	}
+	return fn, nil
+}
+
+// newProvidedSignature returns the function of the definition with its types, but without the C function that it calls.
+// Returns an error if the function's signature cannot be provided.
+func newProvidedSignature(definition *FunctionDefinition) (*ProvidedFunction, error) {
+	switch {
+	case definition.ReturnsSet:
+		return nil, errors.New("set-returning functions cannot be provided")
+	case definition.Window:
+		return nil, errors.New("window functions cannot be provided")
+	}
+	fn := &ProvidedFunction{Definition: definition}
+	for _, param := range definition.Parameters {
+		switch param.Mode {
+		case "out":
+			continue
+		case "variadic":
+			return nil, errors.New("variadic functions cannot be provided")
+		}
+		typ, err := providedType(param.Type)
+		if err != nil {
+			return nil, err
+		}
+		fn.ParameterTypes = append(fn.ParameterTypes, typ)
+	}
+	var err error
+	if fn.ReturnType, err = providedType(definition.ReturnType); err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
+
+// providedType returns the type with the given name, which must have a conversion to and from Go values.
+func providedType(name string) (PostgresType, error) {
+	typ, ok := LookupPostgresType(name)
+	if !ok {
+		return PostgresType{}, fmt.Errorf("type `%s` is not a built-in type", name)
+	}
+	switch typ.Name {
+	case "bool", "int2", "int4", "int8", "oid", "float4", "float8", "numeric", "text", "varchar", "bpchar", "bytea",
+		"date", "timestamp", "timestamptz", "interval", "json", "jsonb", "void":
+		return typ, nil
+	default:
+		return PostgresType{}, fmt.Errorf("type `%s` cannot be converted to a Go value", name)
+	}
+}
+
+// Call calls the function with the given arguments, returning its result. Each argument must be nil for NULL, or else
+// have the Go type of its parameter: bool for bool, int16, int32, or int64 for the integer types (or any other integer
+// type whose value fits), uint32 for oid, float32 or float64 for the floating-point types, string or *big.Rat for
+// numeric, string for the text types, []byte for bytea, time.Time for the date and timestamp types, Interval for
+// interval, and json.RawMessage for json and jsonb. Results use the same types, and are nil for NULL and void, while
+// numeric results are strings so that NaN and the infinities may be returned.
+func (fn *ProvidedFunction) Call(args ...any) (any, error) {
+	if len(args) != len(fn.ParameterTypes) {
+		return nil, fmt.Errorf("function %s expects %d arguments, but %d were given",
+			fn.Definition.Name, len(fn.ParameterTypes), len(args))
+	}
+	if fn.sandbox != nil {
+		return fn.sandbox.call(fn.sandboxIndex, args)
+	}
+	// Arguments are allocated within the current memory context, which belongs to the state of the current thread
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	datums := make([]NullableDatum, len(args))
+	defer func() {
+		for i, datum := range datums {
+			if !datum.IsNull && !fn.ParameterTypes[i].Storage.ByValue {
+				FreeDatum(datum.Value)
+			}
+		}
+	}()
+	for i, arg := range args {
+		datum, err := goToDatum(arg, fn.ParameterTypes[i])
+		if err != nil {
+			return nil, fmt.Errorf("argument %d of function %s: %w", i+1, fn.Definition.Name, err)
+		}
+		datums[i] = datum
+	}
+	result, err := fn.Function.CallScoped(fn.ReturnType.Storage, datums...)
+	if err != nil {
+		return nil, err
+	}
+	if result.IsNull || fn.ReturnType.Name == "void" {
+		return nil, nil
+	}
+	return datumToGo(result, fn.ReturnType)
+}
+
+// goToDatum converts the Go value to a Datum of the given type. Values that are stored by reference are allocated within
+// the current memory context.
+func goToDatum(value any, typ PostgresType) (NullableDatum, error) {
+	if value == nil {
+		return NullDatum(), nil
+	}
+	switch typ.Name {
+	case "bool":
+		if v, ok := value.(bool); ok {
+			return NewNullableDatum(BoolToDatum(v)), nil
+		}
+	case "int2", "int4", "int8":
+		if v, ok := goInteger(value); ok {
+			var lo, hi int64
+			switch typ.Name {
+			case "int2":
+				lo, hi = math.MinInt16, math.MaxInt16
+			case "int4":
+				lo, hi = math.MinInt32, math.MaxInt32
+			default:
+				lo, hi = math.MinInt64, math.MaxInt64
+			}
+			if v < lo || v > hi {
+				return NullableDatum{}, fmt.Errorf("%d is out of range for type %s", v, typ.Name)
+			}
+			return NewNullableDatum(Int64ToDatum(v)), nil
+		}
+	case "oid":
+		if v, ok := value.(uint32); ok {
+			return NewNullableDatum(OidToDatum(v)), nil
+		}
+	case "float4":
+		switch v := value.(type) {
+		case float32:
+			return NewNullableDatum(Float4ToDatum(v)), nil
+		case float64:
+			return NewNullableDatum(Float4ToDatum(float32(v))), nil
+		}
+	case "float8":
+		switch v := value.(type) {
+		case float32:
+			return NewNullableDatum(Float8ToDatum(float64(v))), nil
+		case float64:
+			return NewNullableDatum(Float8ToDatum(v)), nil
+		}
+	case "numeric":
+		var datum Datum
+		var err error
+		switch v := value.(type) {
+		case string:
+			datum, err = NumericToDatum(v)
+		case *big.Rat:
+			scale, ok := exactDecimalScale(v)
+			if !ok {
+				return NullableDatum{}, fmt.Errorf("%s has no exact decimal representation", v.RatString())
+			}
+			datum, err = RatToNumericDatum(v, scale)
+		default:
+			return NullableDatum{}, fmt.Errorf("cannot convert %T to type %s", value, typ.Name)
+		}
+		if err != nil {
+			return NullableDatum{}, err
+		}
+		return NewNullableDatum(datum), nil
+	case "date", "timestamp", "timestamptz":
+		v, ok := value.(time.Time)
+		if !ok {
+			break
+		}
+		var datum Datum
+		var err error
+		switch typ.Name {
+		case "date":
+			datum, err = DateToDatum(v)
+		case "timestamp":
+			datum, err = TimestampToDatum(v)
+		default:
+			datum, err = TimestampTzToDatum(v)
+		}
+		if err != nil {
+			return NullableDatum{}, err
+		}
+		return NewNullableDatum(datum), nil
+	case "interval":
+		if v, ok := value.(Interval); ok {
+			datum, err := IntervalToDatum(v)
+			if err != nil {
+				return NullableDatum{}, err
+			}
+			return NewNullableDatum(datum), nil
+		}
+	case "jsonb":
+		if v, ok := value.(json.RawMessage); ok {
+			datum, err := JSONBToDatum(v)
+			if err != nil {
+				return NullableDatum{}, err
+			}
+			return NewNullableDatum(datum), nil
+		}
+	case "json":
+		// The json type stores its text as given, so it's only checked for validity
+		if v, ok := value.(json.RawMessage); ok {
+			if !json.Valid(v) {
+				return NullableDatum{}, fmt.Errorf("invalid input syntax for type json")
+			}
+			datum, err := VarlenaDatum(v)
+			if err != nil {
+				return NullableDatum{}, err
+			}
+			return NewNullableDatum(datum), nil
+		}
+	case "text", "varchar", "bpchar", "bytea":
+		var data []byte
+		switch v := value.(type) {
+		case string:
+			data = []byte(v)
+		case []byte:
+			data = v
+		default:
+			return NullableDatum{}, fmt.Errorf("cannot convert %T to type %s", value, typ.Name)
+		}
+		datum, err := VarlenaDatum(data)
+		if err != nil {
+			return NullableDatum{}, err
+		}
+		return NewNullableDatum(datum), nil
+	}
+	return NullableDatum{}, fmt.Errorf("cannot convert %T to type %s", value, typ.Name)
+}
+
+// goInteger returns the value of any Go integer type as an int64. Returns false if the value is not an integer, or is
+// an unsigned integer that does not fit.
+func goInteger(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint:
+		return int64(v), v <= math.MaxInt64
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	default:
+		return 0, false
+	}
+}
+
+// datumToGo converts the result of a call to the Go value of the given type.
+func datumToGo(result CallResult, typ PostgresType) (any, error) {
+	switch typ.Name {
+	case "bool":
+		return DatumToBool(result.Value), nil
+	case "int2":
+		return DatumToInt16(result.Value), nil
+	case "int4":
+		return DatumToInt32(result.Value), nil
+	case "int8":
+		return DatumToInt64(result.Value), nil
+	case "oid":
+		return DatumToOid(result.Value), nil
+	case "float4":
+		return DatumToFloat4(result.Value), nil
+	case "float8":
+		return DatumToFloat8(result.Value), nil
+	case "numeric":
+		// The result was copied into Go memory, so it's copied back for numeric_out to read
+		datum, err := pallocCopy(result.Data)
+		if err != nil {
+			return nil, err
+		}
+		defer FreeDatum(datum)
+		return DatumToNumeric(datum)
+	case "date":
+		return DatumToDate(result.Value)
+	case "timestamp":
+		return DatumToTimestamp(result.Value)
+	case "timestamptz":
+		return DatumToTimestampTz(result.Value)
+	case "interval":
+		return decodeInterval(result.Data), nil
+	case "jsonb":
+		datum, err := pallocCopy(result.Data)
+		if err != nil {
+			return nil, err
+		}
+		defer FreeDatum(datum)
+		return DatumToJSONB(datum)
+	case "json":
+		text, err := result.Text()
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(text), nil
+	case "text", "varchar", "bpchar":
+		return result.Text()
+	case "bytea":
+		data, err := VarlenaData(result.Data)
+		if err != nil {
+			return nil, err
+		}
+		return slices.Clone(data), nil
+	default:
+		return nil, fmt.Errorf("cannot convert type %s to a Go value", typ.Name)
+	}
+}
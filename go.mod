@@ -1,3 +1,10 @@
 module github.com/dolthub/pg_extension
 
 go 1.24
+
+require (
+	github.com/fsnotify/fsnotify v1.10.1
+	github.com/tetratelabs/wazero v1.10.1
+)
+
+require golang.org/x/sys v0.13.0 // indirect
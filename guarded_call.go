@@ -0,0 +1,111 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+
+static inline void FreeCrashBacktrace(uintptr_t backtrace) {
+	free((void*)backtrace);
+}
+*/
+import "C"
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// CrashError is returned from calls that crashed while guarded calls were enabled. The extension's state may have been
+// corrupted by the crash, so hosts should generally stop using the extension once this has been returned.
+type CrashError struct {
+	// Signal is the signal that was raised by the crash, such as SIGSEGV.
+	Signal int
+	// Address is the memory address that caused the crash, if the signal has one.
+	Address uintptr
+	// Stack contains the frames of the stack at the time of the crash, from innermost to outermost. This is empty on
+	// platforms where the stack cannot be recorded.
+	Stack []string
+}
+
+var (
+	shimSetGuardedCalls = newUnlockedShimProc("pgext_set_guarded_calls")
+	shimCrashBacktrace  = newUnlockedShimProc("pgext_crash_backtrace")
+	guardedCallsMutex   = &sync.Mutex{}
+)
+
+// SetGuardedCalls enables or disables guarded calls, which are disabled by default. When enabled, a crash within an
+// extension function returns a CrashError rather than terminating the process. Guarded calls install handlers for
+// SIGSEGV, SIGBUS, SIGFPE, and SIGILL, which forward all signals that do not come from an extension to the Go runtime.
+// Guarded calls are not supported on Windows.
+func SetGuardedCalls(enabled bool) error {
+	guardedCallsMutex.Lock()
+	defer guardedCallsMutex.Unlock()
+	var enabledArg uintptr
+	if enabled {
+		enabledArg = 1
+	}
+	supported, err := shimSetGuardedCalls.Call(enabledArg)
+	if err != nil {
+		return err
+	}
+	if supported == 0 {
+		return fmt.Errorf("guarded calls are not supported on this platform")
+	}
+	return nil
+}
+
+// Error implements the error interface.
+func (ce CrashError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("extension function crashed with %s", signalName(ce.Signal)))
+	if ce.Address != 0 {
+		sb.WriteString(fmt.Sprintf(" at address 0x%x", ce.Address))
+	}
+	for _, frame := range ce.Stack {
+		sb.WriteString("\n    ")
+		sb.WriteString(frame)
+	}
+	return sb.String()
+}
+
+// crashBacktrace returns the stack of the most recent guarded call that crashed on the current thread.
+func crashBacktrace() []string {
+	backtrace, err := shimCrashBacktrace.Call()
+	if err != nil || backtrace == 0 {
+		return nil
+	}
+	defer C.FreeCrashBacktrace(C.uintptr_t(backtrace))
+	frames := string(copyFromDatum(Datum(backtrace), datumCStringLen(Datum(backtrace))))
+	return strings.Split(strings.TrimSuffix(frames, "\n"), "\n")
+}
+
+// signalName returns the name of the given signal, using the values that are shared by Linux and macOS.
+func signalName(signal int) string {
+	switch signal {
+	case 4:
+		return "SIGILL"
+	case 8:
+		return "SIGFPE"
+	case 11:
+		return "SIGSEGV"
+	case 7, 10:
+		// SIGBUS is 7 on Linux and 10 on macOS
+		return "SIGBUS"
+	default:
+		return fmt.Sprintf("signal %d", signal)
+	}
+}
@@ -0,0 +1,730 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+
+extern int pgextHostConfigDefine(PgExtConfigVariable* variable, char** message);
+extern int pgextHostConfigSet(char* name, char* value, int context, int source, char** message);
+extern bool pgextHostConfigGet(char* name, char** value);
+extern void pgextHostConfigReservePrefix(char* prefix);
+extern void pgextHostConfigLoadSession(PgExtBackendState* state);
+
+static inline PgExtConfigRegistry* NewHostConfigRegistry() {
+	PgExtConfigRegistry* registry = (PgExtConfigRegistry*)malloc(sizeof(PgExtConfigRegistry));
+	registry->define = (int (*)(const PgExtConfigVariable*, char**))pgextHostConfigDefine;
+	registry->set = (int (*)(const char*, const char*, int, int, char**))pgextHostConfigSet;
+	registry->get = (bool (*)(const char*, const char**))pgextHostConfigGet;
+	registry->reserve_prefix = (void (*)(const char*))pgextHostConfigReservePrefix;
+	registry->load_session = pgextHostConfigLoadSession;
+	return registry;
+}
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// ConfigContext mirrors Postgres' GucContext, which determines when a configuration variable may be changed.
+type ConfigContext int32
+
+const (
+	ConfigContextInternal ConfigContext = iota
+	ConfigContextPostmaster
+	ConfigContextSighup
+	ConfigContextSuBackend
+	ConfigContextBackend
+	ConfigContextSuset
+	ConfigContextUserset
+)
+
+// ConfigType is the data type of a configuration variable.
+type ConfigType int32
+
+const (
+	ConfigTypeBool ConfigType = iota
+	ConfigTypeInt
+	ConfigTypeReal
+	ConfigTypeString
+	ConfigTypeEnum
+)
+
+// ConfigSourceKind mirrors Postgres' GucSource, and is passed to check hooks so they know where a value came from.
+type ConfigSourceKind int32
+
+const (
+	ConfigSourceKindDefault ConfigSourceKind = iota
+	ConfigSourceKindDynamicDefault
+	ConfigSourceKindEnvVar
+	ConfigSourceKindFile
+	ConfigSourceKindArgv
+	ConfigSourceKindGlobal
+	ConfigSourceKindDatabase
+	ConfigSourceKindUser
+	ConfigSourceKindDatabaseUser
+	ConfigSourceKindClient
+	ConfigSourceKindOverride
+	ConfigSourceKindInteractive
+	ConfigSourceKindTest
+	ConfigSourceKindSession
+)
+
+// ConfigEnumOption is a single allowed value for an enum configuration variable.
+type ConfigEnumOption struct {
+	Name   string
+	Value  int32
+	Hidden bool
+}
+
+// ConfigSource is the source of configuration values that are read during a reload. This is the analogue of
+// postgresql.conf, and is supplied by the host.
+type ConfigSource interface {
+	// Lookup returns the value for the given configuration variable, and whether the source contains a value for it.
+	Lookup(name string) (string, bool)
+}
+
+// ConfigSourceMap is a ConfigSource that is backed by a map. Keys should be lowercase.
+type ConfigSourceMap map[string]string
+
+var _ ConfigSource = ConfigSourceMap(nil)
+
+// ConfigVariable is a configuration variable (GUC) that has been defined by an extension.
+type ConfigVariable struct {
+	Name      string
+	ShortDesc string
+	LongDesc  string
+	Type      ConfigType
+	Context   ConfigContext
+	// Flags are the GUC_ flags that the extension gave, which include the unit of numeric variables.
+	Flags       int32
+	BootValue   string
+	MinValue    float64
+	MaxValue    float64
+	EnumOptions []ConfigEnumOption
+	// valueAddr is the address of the extension's C variable that holds the current value.
+	valueAddr unsafe.Pointer
+	// checkHook is the C check hook, or nil if the extension did not provide one.
+	checkHook unsafe.Pointer
+	// assignHook is the C assign hook, or nil if the extension did not provide one.
+	assignHook unsafe.Pointer
+	// current is the textual form of the current value.
+	current string
+	// source is where the current value came from.
+	source ConfigSourceKind
+}
+
+var (
+	// configVariables contains all of the defined configuration variables, keyed by their lowercase name.
+	configVariables = make(map[string]*ConfigVariable)
+	// configVariablesMutex gates access to the configuration variables, along with their placeholders and reserved
+	// prefixes.
+	configVariablesMutex = &sync.Mutex{}
+	// configPlaceholders contains the values that were set for variables that have not yet been defined, keyed by their
+	// lowercase name. Each is given to its variable once an extension defines it.
+	configPlaceholders = make(map[string]configPlaceholder)
+	// configReservedPrefixes contains the lowercase prefixes that extensions have reserved, beneath which only defined
+	// variables may be set.
+	configReservedPrefixes = make(map[string]struct{})
+	// configValues holds the current value of each variable and placeholder as a C string, keyed by their lowercase name.
+	// This is read by extensions without taking the registry mutex, as hooks may read variables during an assignment.
+	configValues = &sync.Map{}
+	// configStrings contains every value that has been given to extensions, which must remain allocated as extensions may
+	// retain them.
+	configStrings = make(map[string]*C.char)
+	// configStringsMutex gates access to the C strings of the values.
+	configStringsMutex = &sync.Mutex{}
+	// hostConfigRegistry is the C struct that forwards to the Go registry.
+	hostConfigRegistry = sync.OnceValue(func() *C.PgExtConfigRegistry {
+		return C.NewHostConfigRegistry()
+	})
+	// registerConfigRegistry gives the registry to the shim, which must be done before any extension defines a variable.
+	registerConfigRegistry = sync.OnceValue(func() error {
+		_, err := shimSetConfigRegistry.Call(uintptr(unsafe.Pointer(hostConfigRegistry())))
+		return err
+	})
+	shimSetConfigRegistry = newShimProc("pgext_set_config_registry")
+	shimConfigCallHook    = newShimProc("pgext_config_call_hook")
+)
+
+// configPlaceholder is the value of a variable that was set before it was defined.
+type configPlaceholder struct {
+	value  string
+	source ConfigSourceKind
+}
+
+const (
+	// configUnitMemory masks the flags that give the memory unit of a numeric variable.
+	configUnitMemory = 0xF000
+	// configUnitTime masks the flags that give the time unit of a numeric variable.
+	configUnitTime = 0xF0000
+)
+
+// configMemoryUnits are the sizes in bytes of the memory units that may be given to numeric variables.
+var configMemoryUnits = map[string]float64{"B": 1, "kB": 1024, "MB": 1024 * 1024, "GB": 1024 * 1024 * 1024,
+	"TB": 1024 * 1024 * 1024 * 1024}
+
+// configMemoryFlagUnits are the sizes in bytes of each memory unit flag.
+var configMemoryFlagUnits = map[int32]float64{0x1000: 1024, 0x2000: 8192, 0x3000: 8192, 0x4000: 1024 * 1024, 0x8000: 1}
+
+// configTimeUnits are the lengths in milliseconds of the time units that may be given to numeric variables.
+var configTimeUnits = map[string]float64{"us": 0.001, "ms": 1, "s": 1000, "min": 60 * 1000, "h": 60 * 60 * 1000,
+	"d": 24 * 60 * 60 * 1000}
+
+// configTimeFlagUnits are the lengths in milliseconds of each time unit flag.
+var configTimeFlagUnits = map[int32]float64{0x10000: 1, 0x20000: 1000, 0x30000: 60 * 1000}
+
+// Lookup implements the interface ConfigSource.
+func (m ConfigSourceMap) Lookup(name string) (string, bool) {
+	val, ok := m[strings.ToLower(name)]
+	return val, ok
+}
+
+// Value returns the textual form of the variable's current value.
+func (v *ConfigVariable) Value() string {
+	configVariablesMutex.Lock()
+	defer configVariablesMutex.Unlock()
+	return v.current
+}
+
+// Source returns where the variable's current value came from.
+func (v *ConfigVariable) Source() ConfigSourceKind {
+	configVariablesMutex.Lock()
+	defer configVariablesMutex.Unlock()
+	return v.source
+}
+
+// ConfigVariables returns all defined configuration variables, sorted by name.
+func ConfigVariables() []*ConfigVariable {
+	configVariablesMutex.Lock()
+	defer configVariablesMutex.Unlock()
+	names := slices.Sorted(maps.Keys(configVariables))
+	vars := make([]*ConfigVariable, len(names))
+	for i, name := range names {
+		vars[i] = configVariables[name]
+	}
+	return vars
+}
+
+// GetConfigVariable returns the configuration variable with the given name.
+func GetConfigVariable(name string) (*ConfigVariable, bool) {
+	configVariablesMutex.Lock()
+	defer configVariablesMutex.Unlock()
+	v, ok := configVariables[strings.ToLower(name)]
+	return v, ok
+}
+
+// ReloadConfig is the analogue of a SIGHUP. Every variable that may be changed after startup is re-evaluated against the
+// given source, firing check and assign hooks for any that have changed. Variables that previously came from the source
+// but are no longer present revert to their boot values. As in Postgres, variables whose values came from a source that
+// outranks the file, such as SetConfigVariable, keep those values. Invalid values do not prevent the remaining
+// variables from being applied, and all such errors are returned together. Variables that a session's values have
+// replaced keep those values, and the host-wide values that they return to afterward are reloaded instead.
+func ReloadConfig(source ConfigSource) error {
+	unlock := lockBackend()
+	defer unlock()
+	sessionConfigMutex.Lock()
+	defer sessionConfigMutex.Unlock()
+	configVariablesMutex.Lock()
+	defer configVariablesMutex.Unlock()
+
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(configVariables)) {
+		v := configVariables[name]
+		current, currentSource := v.current, v.source
+		saved, replaced := savedConfigValues[name]
+		if replaced {
+			current, currentSource = saved.value, saved.source
+		}
+		if currentSource > ConfigSourceKindFile {
+			continue
+		}
+		newVal, ok := source.Lookup(v.Name)
+		if !ok {
+			if currentSource != ConfigSourceKindFile {
+				continue
+			}
+			// The setting was removed from the source, so we revert to the default
+			newVal = v.BootValue
+		}
+		if v.Context <= ConfigContextPostmaster {
+			if ok && newVal != current {
+				errs = append(errs, fmt.Errorf(`parameter "%s" cannot be changed without restarting the server`, v.Name))
+			}
+			continue
+		}
+		newSource := ConfigSourceKindFile
+		if !ok {
+			newSource = ConfigSourceKindDefault
+		}
+		switch {
+		case replaced:
+			savedConfigValues[name] = savedConfigValue{value: newVal, source: newSource}
+		case newVal == current:
+			v.source = newSource
+		default:
+			if err := v.assign(newVal, newSource); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SetConfigVariable sets the variable for the session, as SET does. The context is that of the user that is making the
+// change, which is ConfigContextSuset for superusers and ConfigContextUserset otherwise. As in Postgres, a variable
+// whose name has a prefix may be set before an extension defines it, unless the prefix has been reserved, and the value
+// is given to the variable once it's defined. The value is host-wide, so a session whose own value replaces it keeps
+// that value.
+func SetConfigVariable(name string, value string, context ConfigContext) error {
+	return setHostConfigVariable(name, &value, context, ConfigSourceKindSession)
+}
+
+// ResetConfigVariable reverts the variable to its boot value, as RESET does. The context is the same as the one given
+// to SetConfigVariable.
+func ResetConfigVariable(name string, context ConfigContext) error {
+	return setHostConfigVariable(name, nil, context, ConfigSourceKindDefault)
+}
+
+// ShowConfigVariable returns the host-wide value of the variable, or of its placeholder if it has not been defined, as
+// SHOW does.
+func ShowConfigVariable(name string) (string, error) {
+	sessionConfigMutex.Lock()
+	defer sessionConfigMutex.Unlock()
+	return showHostConfigVariable(name)
+}
+
+// setHostConfigVariable sets the host-wide value of the variable, or resets it when the value is nil. A variable whose
+// value has been replaced by the assigned session only has its saved value changed, which it's given again once the
+// session's values are removed.
+func setHostConfigVariable(name string, value *string, context ConfigContext, source ConfigSourceKind) error {
+	unlock := lockBackend()
+	defer unlock()
+	sessionConfigMutex.Lock()
+	defer sessionConfigMutex.Unlock()
+	configVariablesMutex.Lock()
+	defer configVariablesMutex.Unlock()
+
+	lowerName := strings.ToLower(name)
+	if _, ok := savedConfigValues[lowerName]; !ok {
+		return setConfigVariable(name, value, context, source)
+	}
+	v := configVariables[lowerName]
+	if err := v.checkContext(context); err != nil {
+		return err
+	}
+	if value == nil {
+		savedConfigValues[lowerName] = savedConfigValue{value: v.BootValue, source: ConfigSourceKindDefault}
+	} else {
+		savedConfigValues[lowerName] = savedConfigValue{value: *value, source: source}
+	}
+	return nil
+}
+
+// showHostConfigVariable returns the host-wide value of the variable, the same as ShowConfigVariable. This expects
+// sessionConfigMutex to be held.
+func showHostConfigVariable(name string) (string, error) {
+	configVariablesMutex.Lock()
+	defer configVariablesMutex.Unlock()
+	lowerName := strings.ToLower(name)
+	if saved, ok := savedConfigValues[lowerName]; ok {
+		return saved.value, nil
+	}
+	if v, ok := configVariables[lowerName]; ok {
+		return v.current, nil
+	}
+	if placeholder, ok := configPlaceholders[lowerName]; ok {
+		return placeholder.value, nil
+	}
+	return "", newConfigError("42704", `unrecognized configuration parameter "%s"`, name)
+}
+
+// registerConfigVariable adds the variable to the registry, and assigns its boot value. If a placeholder was set for
+// the variable, then its value is assigned afterward, and an invalid placeholder leaves the boot value in place.
+func registerConfigVariable(v *ConfigVariable) error {
+	configVariablesMutex.Lock()
+	defer configVariablesMutex.Unlock()
+
+	name := strings.ToLower(v.Name)
+	if _, ok := configVariables[name]; ok {
+		return fmt.Errorf(`attempt to redefine parameter "%s"`, v.Name)
+	}
+	if err := v.assign(v.BootValue, ConfigSourceKindDefault); err != nil {
+		return err
+	}
+	// The registry mutex was released while the hooks ran, so the variable may have been defined in the meantime
+	if _, ok := configVariables[name]; ok {
+		return fmt.Errorf(`attempt to redefine parameter "%s"`, v.Name)
+	}
+	configVariables[name] = v
+	if placeholder, ok := configPlaceholders[name]; ok {
+		delete(configPlaceholders, name)
+		// Postgres only warns about an invalid placeholder, so the error is not returned
+		_ = v.assign(placeholder.value, placeholder.source)
+	}
+	return nil
+}
+
+// setConfigVariable sets the variable from within the given context, or resets it when the value is nil. Variables
+// that have not been defined are set as placeholders. This expects the registry mutex to be held.
+func setConfigVariable(name string, value *string, context ConfigContext, source ConfigSourceKind) error {
+	lowerName := strings.ToLower(name)
+	v, ok := configVariables[lowerName]
+	if !ok {
+		return setConfigPlaceholder(name, value, source)
+	}
+	if err := v.checkContext(context); err != nil {
+		return err
+	}
+	if value == nil {
+		return v.assign(v.BootValue, ConfigSourceKindDefault)
+	}
+	return v.assign(*value, source)
+}
+
+// setConfigPlaceholder sets the placeholder of a variable that has not been defined, or removes it when the value is
+// nil. Only names with a prefix that has not been reserved may have a placeholder. This expects the registry mutex to
+// be held.
+func setConfigPlaceholder(name string, value *string, source ConfigSourceKind) error {
+	lowerName := strings.ToLower(name)
+	if err := checkConfigPlaceholderName(name); err != nil {
+		return err
+	}
+	if value == nil {
+		delete(configPlaceholders, lowerName)
+		configValues.Delete(lowerName)
+		return nil
+	}
+	configPlaceholders[lowerName] = configPlaceholder{value: *value, source: source}
+	publishConfigValue(lowerName, *value)
+	return nil
+}
+
+// checkConfigPlaceholderName returns an error if a variable that has not been defined may not be set with the given
+// name, which must have a prefix that has not been reserved. This expects the registry mutex to be held.
+func checkConfigPlaceholderName(name string) error {
+	lowerName := strings.ToLower(name)
+	prefix, rest, ok := strings.Cut(lowerName, ".")
+	if !ok || len(prefix) == 0 || len(rest) == 0 {
+		return newConfigError("42704", `unrecognized configuration parameter "%s"`, name)
+	}
+	for reserved := range configReservedPrefixes {
+		if strings.HasPrefix(lowerName, reserved+".") {
+			return PostgresError{
+				Severity: "ERROR",
+				Code:     "42602",
+				Message:  fmt.Sprintf(`invalid configuration parameter name "%s"`, name),
+				Detail:   fmt.Sprintf(`"%s" is a reserved prefix.`, reserved),
+			}
+		}
+	}
+	return nil
+}
+
+// reserveConfigPrefix reserves the prefix for the extension that defined it, removing every placeholder beneath it.
+func reserveConfigPrefix(prefix string) {
+	configVariablesMutex.Lock()
+	defer configVariablesMutex.Unlock()
+
+	prefix = strings.ToLower(prefix)
+	configReservedPrefixes[prefix] = struct{}{}
+	for name := range configPlaceholders {
+		if strings.HasPrefix(name, prefix+".") {
+			delete(configPlaceholders, name)
+			configValues.Delete(name)
+		}
+	}
+}
+
+// checkContext returns an error if the variable cannot be changed from within the given context.
+func (v *ConfigVariable) checkContext(context ConfigContext) error {
+	if v.Context >= context {
+		return nil
+	}
+	switch v.Context {
+	case ConfigContextInternal:
+		return newConfigError("55P02", `parameter "%s" cannot be changed`, v.Name)
+	case ConfigContextPostmaster:
+		return newConfigError("55P02", `parameter "%s" cannot be changed without restarting the server`, v.Name)
+	case ConfigContextSighup:
+		return newConfigError("55P02", `parameter "%s" cannot be changed now`, v.Name)
+	case ConfigContextSuBackend, ConfigContextBackend:
+		return newConfigError("55P02", `parameter "%s" cannot be set after connection start`, v.Name)
+	default:
+		return newConfigError("42501", `permission denied to set parameter "%s"`, v.Name)
+	}
+}
+
+// newConfigError returns an error with the given SQLSTATE.
+func newConfigError(code string, format string, args ...any) error {
+	return PostgresError{
+		Severity: "ERROR",
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// publishConfigValue makes the value of the variable with the given lowercase name visible to extensions.
+func publishConfigValue(lowerName string, value string) {
+	configValues.Store(lowerName, internConfigString(value))
+}
+
+// internConfigString returns the C string for the given value, allocating it only the first time that the value is
+// seen. The returned string is never freed.
+func internConfigString(value string) *C.char {
+	configStringsMutex.Lock()
+	defer configStringsMutex.Unlock()
+	cValue, ok := configStrings[value]
+	if !ok {
+		cValue = C.CString(value)
+		configStrings[value] = cValue
+	}
+	return cValue
+}
+
+// assign parses the given value, runs the check hook, writes the value into the extension's variable, and then fires
+// the assign hook. This expects the registry mutex to be held, which is released while the hooks run.
+func (v *ConfigVariable) assign(val string, source ConfigSourceKind) error {
+	invalidErr := fmt.Errorf(`invalid value for parameter "%s": "%s"`, v.Name, val)
+	call := (*C.PgExtConfigHookCall)(C.calloc(1, C.sizeof_PgExtConfigHookCall))
+	defer C.free(unsafe.Pointer(call))
+	call._type = C.int(v.Type)
+	call.source = C.int(source)
+	switch v.Type {
+	case ConfigTypeBool:
+		parsed, ok := parseConfigBool(val)
+		if !ok {
+			return invalidErr
+		}
+		call.bool_value = C.bool(parsed)
+	case ConfigTypeInt:
+		parsed, ok := parseConfigNumber(val, v.Flags)
+		// Postgres rounds fractional values of integer variables, such as those that result from a unit conversion
+		parsed = math.Round(parsed)
+		if !ok || parsed < v.MinValue || parsed > v.MaxValue {
+			return invalidErr
+		}
+		call.int_value = C.int(parsed)
+	case ConfigTypeReal:
+		parsed, ok := parseConfigNumber(val, v.Flags)
+		if !ok || math.IsNaN(parsed) || parsed < v.MinValue || parsed > v.MaxValue {
+			return invalidErr
+		}
+		call.real_value = C.double(parsed)
+	case ConfigTypeString:
+		// The extension retains the string, so it's shared with the published values rather than allocated per call.
+		// Check hooks may free the string when replacing it though, so they're given their own copy.
+		if v.checkHook != nil {
+			call.string_value = C.CString(val)
+		} else {
+			call.string_value = internConfigString(val)
+		}
+	case ConfigTypeEnum:
+		idx := slices.IndexFunc(v.EnumOptions, func(opt ConfigEnumOption) bool {
+			return strings.EqualFold(opt.Name, strings.TrimSpace(val))
+		})
+		if idx == -1 {
+			return invalidErr
+		}
+		val = v.EnumOptions[idx].Name
+		call.int_value = C.int(v.EnumOptions[idx].Value)
+	default:
+		return fmt.Errorf(`parameter "%s" has an unknown type`, v.Name)
+	}
+	if v.checkHook != nil {
+		checked := call.string_value
+		err := callConfigHook(call, v.checkHook, false)
+		if checked != nil && call.string_value == checked {
+			// The hook kept the copy that it was given, so the shared string is used in its place
+			C.free(unsafe.Pointer(checked))
+			call.string_value = internConfigString(val)
+		}
+		if err != nil {
+			return err
+		}
+		if !call.valid {
+			return invalidErr
+		}
+	}
+	if v.valueAddr != nil {
+		switch v.Type {
+		case ConfigTypeBool:
+			*(*C.bool)(v.valueAddr) = call.bool_value
+		case ConfigTypeInt, ConfigTypeEnum:
+			*(*C.int)(v.valueAddr) = call.int_value
+		case ConfigTypeReal:
+			*(*C.double)(v.valueAddr) = call.real_value
+		case ConfigTypeString:
+			*(**C.char)(v.valueAddr) = call.string_value
+		}
+	}
+	v.current = val
+	v.source = source
+	publishConfigValue(strings.ToLower(v.Name), val)
+	if v.assignHook != nil {
+		// The value has already been written, so it remains even when the assign hook raises an error
+		return callConfigHook(call, v.assignHook, true)
+	}
+	return nil
+}
+
+// callConfigHook calls the check or assign hook through the shim, which catches the errors that it raises, returning
+// them as a PostgresError. The registry mutex is released while the hook runs, as hooks may set other variables
+// through SetConfigOption.
+func callConfigHook(call *C.PgExtConfigHookCall, hook unsafe.Pointer, assign bool) error {
+	call.hook = hook
+	call.assign = C.bool(assign)
+	configVariablesMutex.Unlock()
+	defer configVariablesMutex.Lock()
+	edata, err := shimConfigCallHook.Call(uintptr(unsafe.Pointer(call)))
+	if err != nil {
+		return err
+	}
+	if edata != 0 {
+		return newCallError(edata)
+	}
+	return nil
+}
+
+// parseConfigBool parses a boolean in the same manner as Postgres, which accepts unique prefixes of the boolean words.
+func parseConfigBool(val string) (bool, bool) {
+	val = strings.ToLower(strings.TrimSpace(val))
+	if len(val) == 0 {
+		return false, false
+	}
+	switch {
+	case strings.HasPrefix("true", val), strings.HasPrefix("yes", val), val == "on", val == "1":
+		return true, true
+	case strings.HasPrefix("false", val), strings.HasPrefix("no", val), val == "off", val == "of", val == "0":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// parseConfigNumber parses the value of a numeric variable, which may be followed by a unit when the flags give the
+// variable a unit. Values with a unit are converted into the unit of the variable.
+func parseConfigNumber(val string, flags int32) (float64, bool) {
+	val = strings.TrimSpace(val)
+	if parsed, err := strconv.ParseInt(val, 0, 64); err == nil {
+		return float64(parsed), true
+	}
+	numberEnd := strings.IndexFunc(val, func(r rune) bool {
+		return !strings.ContainsRune("0123456789.+-eE", r)
+	})
+	if numberEnd == -1 {
+		parsed, err := strconv.ParseFloat(val, 64)
+		return parsed, err == nil
+	}
+	parsed, err := strconv.ParseFloat(val[:numberEnd], 64)
+	if err != nil {
+		return 0, false
+	}
+	unit := strings.TrimSpace(val[numberEnd:])
+	var unitSize, flagSize float64
+	var ok bool
+	if memoryFlag := flags & configUnitMemory; memoryFlag != 0 {
+		unitSize, ok = configMemoryUnits[unit]
+		flagSize = configMemoryFlagUnits[memoryFlag]
+	} else if timeFlag := flags & configUnitTime; timeFlag != 0 {
+		unitSize, ok = configTimeUnits[unit]
+		flagSize = configTimeFlagUnits[timeFlag]
+	}
+	if !ok || flagSize == 0 {
+		return 0, false
+	}
+	return parsed * unitSize / flagSize, true
+}
+
+//export pgextHostConfigDefine
+func pgextHostConfigDefine(variable *C.PgExtConfigVariable, message **C.char) C.int {
+	v := &ConfigVariable{
+		Name:       C.GoString(variable.name),
+		ShortDesc:  C.GoString(variable.short_desc),
+		LongDesc:   C.GoString(variable.long_desc),
+		Type:       ConfigType(variable._type),
+		Context:    ConfigContext(variable.context),
+		Flags:      int32(variable.flags),
+		BootValue:  C.GoString(variable.boot_value),
+		MinValue:   float64(variable.min_value),
+		MaxValue:   float64(variable.max_value),
+		valueAddr:  variable.value_addr,
+		checkHook:  variable.check_hook,
+		assignHook: variable.assign_hook,
+	}
+	for option := variable.options; option != nil && option.name != nil; option = (*C.struct_config_enum_entry)(
+		unsafe.Add(unsafe.Pointer(option), unsafe.Sizeof(*option))) {
+		v.EnumOptions = append(v.EnumOptions, ConfigEnumOption{
+			Name:   C.GoString(option.name),
+			Value:  int32(option.val),
+			Hidden: bool(option.hidden),
+		})
+	}
+	return configErrorCode(registerConfigVariable(v), "XX000", message)
+}
+
+//export pgextHostConfigSet
+func pgextHostConfigSet(name *C.char, value *C.char, context C.int, source C.int, message **C.char) C.int {
+	var newValue *string
+	if value != nil {
+		str := C.GoString(value)
+		newValue = &str
+	}
+	configVariablesMutex.Lock()
+	defer configVariablesMutex.Unlock()
+	err := setConfigVariable(C.GoString(name), newValue, ConfigContext(context), ConfigSourceKind(source))
+	return configErrorCode(err, "22023", message)
+}
+
+//export pgextHostConfigGet
+func pgextHostConfigGet(name *C.char, value **C.char) C.bool {
+	cValue, ok := configValues.Load(strings.ToLower(C.GoString(name)))
+	if !ok {
+		return false
+	}
+	*value = cValue.(*C.char)
+	return true
+}
+
+//export pgextHostConfigReservePrefix
+func pgextHostConfigReservePrefix(prefix *C.char) {
+	reserveConfigPrefix(C.GoString(prefix))
+}
+
+// configErrorCode returns the SQLSTATE of the error as created by MAKE_SQLSTATE, along with its message, which the
+// shim frees. Errors without a SQLSTATE use the given default. Returns zero if there is no error.
+func configErrorCode(err error, defaultCode string, message **C.char) C.int {
+	if err == nil {
+		return 0
+	}
+	code := defaultCode
+	msg := err.Error()
+	var pgErr PostgresError
+	if errors.As(err, &pgErr) {
+		code = pgErr.Code
+		msg = pgErr.Message
+	}
+	*message = C.CString(msg)
+	return C.int(encodeSQLState(code))
+}
@@ -0,0 +1,104 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"fmt"
+	"testing"
+)
+
+// The unit flags of Postgres 15, from guc.h.
+const (
+	testUnitKB     = 0x1000
+	testUnitBlocks = 0x2000
+	testUnitMB     = 0x4000
+	testUnitByte   = 0x8000
+	testUnitMS     = 0x10000
+	testUnitS      = 0x20000
+	testUnitMin    = 0x30000
+)
+
+func TestParseConfigNumber(t *testing.T) {
+	tests := []struct {
+		val      string
+		flags    int32
+		expected float64
+		ok       bool
+	}{
+		{val: "100", expected: 100, ok: true},
+		{val: " -7 ", expected: -7, ok: true},
+		{val: "0x10", expected: 16, ok: true},
+		{val: "1.5", expected: 1.5, ok: true},
+		{val: "1e3", expected: 1000, ok: true},
+		{val: "", ok: false},
+		{val: "abc", ok: false},
+		{val: "10MB", ok: false},
+		{val: "64MB", flags: testUnitKB, expected: 64 * 1024, ok: true},
+		{val: "64 MB", flags: testUnitKB, expected: 64 * 1024, ok: true},
+		{val: "100", flags: testUnitKB, expected: 100, ok: true},
+		{val: "1GB", flags: testUnitBlocks, expected: 131072, ok: true},
+		{val: "1TB", flags: testUnitMB, expected: 1024 * 1024, ok: true},
+		{val: "8kB", flags: testUnitByte, expected: 8192, ok: true},
+		{val: "2B", flags: testUnitByte, expected: 2, ok: true},
+		{val: "64mb", flags: testUnitKB, ok: false},
+		{val: "10ms", flags: testUnitKB, ok: false},
+		{val: "10s", flags: testUnitMS, expected: 10000, ok: true},
+		{val: "500us", flags: testUnitMS, expected: 0.5, ok: true},
+		{val: "1min", flags: testUnitS, expected: 60, ok: true},
+		{val: "1.5h", flags: testUnitMin, expected: 90, ok: true},
+		{val: "1d", flags: testUnitMin, expected: 1440, ok: true},
+		{val: "10kB", flags: testUnitMS, ok: false},
+		{val: "10 fortnights", flags: testUnitS, ok: false},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s/%#x", test.val, test.flags), func(t *testing.T) {
+			actual, ok := parseConfigNumber(test.val, test.flags)
+			if ok != test.ok {
+				t.Fatalf("expected ok to be %t, got %t", test.ok, ok)
+			}
+			if ok && actual != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseConfigBool(t *testing.T) {
+	tests := []struct {
+		val      string
+		expected bool
+		ok       bool
+	}{
+		{val: "on", expected: true, ok: true},
+		{val: "OFF", expected: false, ok: true},
+		{val: "t", expected: true, ok: true},
+		{val: "fal", expected: false, ok: true},
+		{val: " yes ", expected: true, ok: true},
+		{val: "n", expected: false, ok: true},
+		{val: "1", expected: true, ok: true},
+		{val: "0", expected: false, ok: true},
+		{val: "o", ok: false},
+		{val: "", ok: false},
+		{val: "2", ok: false},
+	}
+	for _, test := range tests {
+		t.Run(test.val, func(t *testing.T) {
+			actual, ok := parseConfigBool(test.val)
+			if ok != test.ok || actual != test.expected {
+				t.Errorf("expected (%t, %t), got (%t, %t)", test.expected, test.ok, actual, ok)
+			}
+		})
+	}
+}
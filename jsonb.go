@@ -0,0 +1,72 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+var (
+	shimJsonbIn  = newShimProc("jsonb_in")
+	shimJsonbOut = newShimProc("jsonb_out")
+)
+
+// JSONBToDatum returns a jsonb Datum of the value, which is first marshaled with encoding/json. A json.RawMessage is
+// used as given, so that text that is already JSON is not quoted as a string. The Datum is allocated within the current
+// memory context.
+func JSONBToDatum(value any) (Datum, error) {
+	text, ok := value.(json.RawMessage)
+	if !ok {
+		var err error
+		if text, err = json.Marshal(value); err != nil {
+			return 0, err
+		}
+	}
+	fn, err := shimJsonbIn.addr()
+	if err != nil {
+		return 0, err
+	}
+	str, err := CStringToDatum(string(text))
+	if err != nil {
+		return 0, err
+	}
+	defer FreeDatum(str)
+	result, _, err := CallFmgrFunction(fn, NewNullableDatum(str))
+	return result, err
+}
+
+// DatumToJSONB returns the text of the jsonb that the Datum points to, which is formatted the same as jsonb_out. Object
+// keys are sorted by length and then by their bytes, and duplicate keys have already been removed. The result may be
+// passed to json.Unmarshal, although numbers should be decoded with json.Decoder.UseNumber to keep their precision.
+func DatumToJSONB(d Datum) (json.RawMessage, error) {
+	if d == 0 {
+		return nil, fmt.Errorf("cannot read a jsonb from a null pointer")
+	}
+	fn, err := shimJsonbOut.addr()
+	if err != nil {
+		return nil, err
+	}
+	result, _, err := CallFmgrFunction(fn, NewNullableDatum(d))
+	if err != nil {
+		return nil, err
+	}
+	defer FreeDatum(result)
+	text, err := DatumToCString(result)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(text), nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// libcFlavor is the C library that an ELF file was linked against.
+type libcFlavor string
+
+const (
+	libcUnknown libcFlavor = ""
+	libcGlibc   libcFlavor = "glibc"
+	libcMusl    libcFlavor = "musl"
+)
+
+// hostLibc is the C library of the current process, which is unknown on platforms other than Linux.
+var hostLibc = sync.OnceValue(func() libcFlavor {
+	flavor, _ := elfLibc("/proc/self/exe")
+	return flavor
+})
+
+// elfLibc returns the C library that the ELF file at the given path was linked against. Executables are identified by
+// their dynamic loader, while libraries are identified by the C library that they depend on, or by the symbol versions
+// that only glibc uses. Returns libcUnknown when the file does not show which C library it expects.
+func elfLibc(path string) (libcFlavor, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return libcUnknown, err
+	}
+	defer f.Close()
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+		data, err := io.ReadAll(prog.Open())
+		if err != nil {
+			return libcUnknown, err
+		}
+		interpreter := string(bytes.TrimRight(data, "\x00"))
+		switch {
+		case strings.Contains(interpreter, "ld-musl"):
+			return libcMusl, nil
+		case strings.Contains(interpreter, "ld-linux"):
+			return libcGlibc, nil
+		}
+	}
+	needed, err := f.ImportedLibraries()
+	if err != nil {
+		return libcUnknown, err
+	}
+	for _, lib := range needed {
+		switch {
+		case lib == "libc.so.6":
+			return libcGlibc, nil
+		case lib == "libc.so" || strings.HasPrefix(lib, "libc.musl-"):
+			return libcMusl, nil
+		}
+	}
+	symbols, err := f.ImportedSymbols()
+	if err != nil {
+		return libcUnknown, err
+	}
+	for _, symbol := range symbols {
+		if strings.HasPrefix(symbol.Version, "GLIBC_") {
+			return libcGlibc, nil
+		}
+	}
+	return libcUnknown, nil
+}
+
+// checkLibraryLibc returns an error if the library at the given path was linked against a different C library than the
+// current process. The dynamic loader would otherwise fail with a message about a missing libc.so.6 or missing symbol
+// versions, or would load the library only for it to crash. Libraries are allowed when either C library is unknown.
+func checkLibraryLibc(path string) error {
+	host := hostLibc()
+	if host == libcUnknown {
+		return nil
+	}
+	lib, err := elfLibc(path)
+	if err != nil || lib == libcUnknown || lib == host {
+		return nil
+	}
+	hint := "rebuild the extension against musl, or use an image that is based on glibc"
+	if host == libcGlibc {
+		hint = "rebuild the extension against glibc, or use an image that is based on musl such as Alpine"
+	}
+	return &LoadError{
+		Kind: ErrIncompatibleLibrary,
+		File: path,
+		Err:  fmt.Errorf("library was built against %s, but this process uses %s (%s)", lib, host, hint),
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// agg_state returns the aggregate state of the call, or NULL if the function is not being called as an aggregate.
+static AggState* agg_state(FunctionCallInfo fcinfo) {
+	if (fcinfo->context != NULL && IsA(fcinfo->context, AggState)) {
+		return (AggState*)fcinfo->context;
+	}
+	return NULL;
+}
+
+DLLEXPORT int AggCheckCallContext(FunctionCallInfo fcinfo, MemoryContext* aggcontext) {
+	AggState* aggstate = agg_state(fcinfo);
+	if (aggstate != NULL) {
+		if (aggcontext != NULL) {
+			*aggcontext = aggstate->aggcontext;
+		}
+		return AGG_CONTEXT_AGGREGATE;
+	}
+	if (aggcontext != NULL) {
+		*aggcontext = NULL;
+	}
+	return 0;
+}
+
+DLLEXPORT MemoryContext AggGetTempMemoryContext(FunctionCallInfo fcinfo) {
+	AggState* aggstate = agg_state(fcinfo);
+	if (aggstate == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "aggregate function called in non-aggregate context");
+	}
+	return aggstate->tmpcontext;
+}
+
+// AggStateIsShared returns whether the transition value may be shared with other aggregates, in which case a final
+// function must not modify it. Each group of the host has its own transition value, so it's never shared.
+DLLEXPORT bool AggStateIsShared(FunctionCallInfo fcinfo) {
+	return agg_state(fcinfo) == NULL;
+}
+
+DLLEXPORT void AggRegisterCallback(FunctionCallInfo fcinfo, ExprContextCallbackFunction func, Datum arg) {
+	AggState* aggstate = agg_state(fcinfo);
+	if (aggstate == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "aggregate function cannot register a callback in this context");
+	}
+	RegisterExprContextCallback(aggstate->econtext, func, arg);
+}
+
+// pgext_agg_begin returns the state of a new aggregate group, whose memory contexts are freed by pgext_agg_end. Returns
+// NULL when out of memory.
+DLLEXPORT AggState* pgext_agg_begin(void) {
+	MemoryContext aggcontext = AllocSetContextCreateInternal(NULL, "AggContext", 0, 0, 0);
+	if (aggcontext == NULL) {
+		return NULL;
+	}
+	MemoryContext tmpcontext = AllocSetContextCreateInternal(aggcontext, "AggTupleContext", 0, 0, 0);
+	AggState* aggstate = (AggState*)MemoryContextAllocExtended(aggcontext, sizeof(AggState),
+		MCXT_ALLOC_NO_OOM | MCXT_ALLOC_ZERO);
+	ExprContext* econtext = (ExprContext*)MemoryContextAllocExtended(aggcontext, sizeof(ExprContext),
+		MCXT_ALLOC_NO_OOM | MCXT_ALLOC_ZERO);
+	if (tmpcontext == NULL || aggstate == NULL || econtext == NULL) {
+		MemoryContextDelete(aggcontext);
+		return NULL;
+	}
+	econtext->type = T_ExprContext;
+	econtext->ecxt_per_query_memory = aggcontext;
+	econtext->ecxt_per_tuple_memory = tmpcontext;
+	aggstate->type = T_AggState;
+	aggstate->aggcontext = aggcontext;
+	aggstate->tmpcontext = tmpcontext;
+	aggstate->econtext = econtext;
+	return aggstate;
+}
+
+// pgext_agg_call_begin resets the temporary memory context of the group, which frees everything that the previous call
+// allocated, and then makes it current for the next call.
+DLLEXPORT uintptr_t pgext_agg_call_begin(AggState* aggstate) {
+	MemoryContextReset(aggstate->tmpcontext);
+	aggstate->savedcontext = MemoryContextSwitchTo(aggstate->tmpcontext);
+	return 0;
+}
+
+// pgext_agg_call_end restores the memory context that was current before pgext_agg_call_begin.
+DLLEXPORT uintptr_t pgext_agg_call_end(AggState* aggstate) {
+	CurrentMemoryContext = aggstate->savedcontext;
+	aggstate->savedcontext = NULL;
+	return 0;
+}
+
+// pgext_agg_transition moves a by-reference transition value into the group's memory context, so that it survives the
+// reset of the temporary context, and frees the previous transition value. Either value is zero when it's NULL.
+// Returns the new transition value, or zero when out of memory.
+DLLEXPORT Datum pgext_agg_transition(AggState* aggstate, Datum newValue, Datum oldValue, uintptr_t typlen) {
+	if (newValue == oldValue) {
+		return newValue;
+	}
+	Datum copy = 0;
+	if (newValue != 0) {
+		int16_t len = (int16_t)typlen;
+		size_t size;
+		if (len > 0) {
+			size = (size_t)len;
+		} else if (len == -1) {
+			size = VARSIZE_ANY(newValue);
+		} else {
+			size = strlen((const char*)newValue) + 1;
+		}
+		void* data = MemoryContextAllocExtended(aggstate->aggcontext, size, MCXT_ALLOC_NO_OOM);
+		if (data == NULL) {
+			return 0;
+		}
+		memcpy(data, (const void*)newValue, size);
+		copy = (Datum)data;
+	}
+	if (oldValue != 0) {
+		pfree((void*)oldValue);
+	}
+	return copy;
+}
+
+// pgext_agg_end runs the callbacks that were registered with the group, and then frees its memory contexts. Returns the
+// error that a callback raised, or NULL.
+DLLEXPORT PgExtErrorData* pgext_agg_end(AggState* aggstate) {
+	Datum ignored;
+	PgExtErrorData* edata = pgext_catch_errors(pgext_shutdown_econtext, aggstate->econtext, &ignored);
+	MemoryContextDelete(aggstate->aggcontext);
+	return edata;
+}
@@ -0,0 +1,277 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// array_element_size returns the size of the element as it is stored in an array. Varlenas must have a 4-byte header.
+static size_t array_element_size(Datum value, int elmlen) {
+	if (elmlen > 0) {
+		return (size_t)elmlen;
+	} else if (elmlen == -1) {
+		return VARSIZE_4B(value);
+	}
+	return strlen((const char*)value) + 1;
+}
+
+// builtin_type_storage sets the storage of the built-in element type, as construct_array_builtin and
+// deconstruct_array_builtin only accept the types whose storage they know. Returns false for other types.
+static bool builtin_type_storage(Oid elmtype, int* elmlen, bool* elmbyval, char* elmalign) {
+	switch (elmtype) {
+	case 16: // bool
+	case 18: // char
+		*elmlen = 1;
+		*elmbyval = true;
+		*elmalign = 'c';
+		return true;
+	case 19: // name
+		*elmlen = 64;
+		*elmbyval = false;
+		*elmalign = 'c';
+		return true;
+	case 21: // int2
+		*elmlen = 2;
+		*elmbyval = true;
+		*elmalign = 's';
+		return true;
+	case 23: // int4
+	case 26: // oid
+	case 700: // float4
+		*elmlen = 4;
+		*elmbyval = true;
+		*elmalign = 'i';
+		return true;
+	case 20: // int8
+	case 701: // float8
+	case 1184: // timestamptz
+		*elmlen = 8;
+		*elmbyval = true;
+		*elmalign = 'd';
+		return true;
+	case 25: // text
+	case 1043: // varchar
+	case 1042: // bpchar
+		*elmlen = -1;
+		*elmbyval = false;
+		*elmalign = 'i';
+		return true;
+	case 2275: // cstring
+		*elmlen = -2;
+		*elmbyval = false;
+		*elmalign = 'c';
+		return true;
+	default:
+		pgext_raise_error(ERROR, ERRCODE_FEATURE_NOT_SUPPORTED, "type %u not supported by array functions", elmtype);
+		return false;
+	}
+}
+
+DLLEXPORT int ArrayGetNItems(int ndim, const int* dims) {
+	if (ndim <= 0) {
+		return 0;
+	}
+	int64_t count = 1;
+	for (int i = 0; i < ndim; i++) {
+		if (dims[i] < 0) {
+			pgext_raise_error(ERROR, ERRCODE_PROGRAM_LIMIT_EXCEEDED, "array size exceeds the maximum allowed");
+			return 0;
+		}
+		count *= dims[i];
+		if (count > 0x7FFFFFF) {
+			pgext_raise_error(ERROR, ERRCODE_PROGRAM_LIMIT_EXCEEDED, "array size exceeds the maximum allowed");
+			return 0;
+		}
+	}
+	return (int)count;
+}
+
+DLLEXPORT ArrayType* construct_empty_array(Oid elmtype) {
+	ArrayType* result = (ArrayType*)palloc0(sizeof(ArrayType));
+	if (result == NULL) {
+		return NULL;
+	}
+	SET_VARSIZE(result, sizeof(ArrayType));
+	result->elemtype = elmtype;
+	return result;
+}
+
+DLLEXPORT ArrayType* construct_md_array(Datum* elems, bool* nulls, int ndims, int* dims, int* lbs, Oid elmtype,
+	int elmlen, bool elmbyval, char elmalign) {
+	if (ndims < 0 || ndims > MAXDIM) {
+		pgext_raise_error(ERROR, ERRCODE_PROGRAM_LIMIT_EXCEEDED,
+			"number of array dimensions (%d) exceeds the maximum allowed (%d)", ndims, MAXDIM);
+		return NULL;
+	}
+	int nelems = ArrayGetNItems(ndims, dims);
+	if (nelems == 0) {
+		return construct_empty_array(elmtype);
+	}
+	// Elements are stored with a 4-byte header, so packed varlenas are expanded first
+	bool hasnulls = false;
+	size_t nbytes = 0;
+	for (int i = 0; i < nelems; i++) {
+		if (nulls != NULL && nulls[i]) {
+			hasnulls = true;
+			continue;
+		}
+		if (elmlen == -1) {
+			elems[i] = (Datum)pg_detoast_datum((varlena*)elems[i]);
+		}
+		nbytes = att_align(nbytes, elmalign) + array_element_size(elems[i], elmlen);
+	}
+	size_t dataoffset = hasnulls ? ARR_OVERHEAD_WITHNULLS(ndims, nelems) : ARR_OVERHEAD_NONULLS(ndims);
+	ArrayType* result = (ArrayType*)palloc0(dataoffset + nbytes);
+	if (result == NULL) {
+		return NULL;
+	}
+	SET_VARSIZE(result, dataoffset + nbytes);
+	result->ndim = ndims;
+	result->dataoffset = hasnulls ? (int32_t)dataoffset : 0;
+	result->elemtype = elmtype;
+	memcpy(ARR_DIMS(result), dims, ndims * sizeof(int));
+	memcpy(ARR_LBOUND(result), lbs, ndims * sizeof(int));
+	// The data was zeroed, so the padding before each element and the bits of null elements are already zero
+	uint8_t* bitmap = ARR_NULLBITMAP(result);
+	char* data = ARR_DATA_PTR(result);
+	size_t offset = 0;
+	for (int i = 0; i < nelems; i++) {
+		if (nulls != NULL && nulls[i]) {
+			continue;
+		}
+		if (bitmap != NULL) {
+			bitmap[i >> 3] |= (uint8_t)(1 << (i & 0x07));
+		}
+		offset = att_align(offset, elmalign);
+		if (elmbyval) {
+			switch (elmlen) {
+			case 1:
+				*(uint8_t*)(data + offset) = (uint8_t)elems[i];
+				break;
+			case 2:
+				*(uint16_t*)(data + offset) = (uint16_t)elems[i];
+				break;
+			case 4:
+				*(uint32_t*)(data + offset) = (uint32_t)elems[i];
+				break;
+			default:
+				*(uint64_t*)(data + offset) = (uint64_t)elems[i];
+				break;
+			}
+			offset += (size_t)elmlen;
+		} else {
+			size_t size = array_element_size(elems[i], elmlen);
+			memcpy(data + offset, (const void*)elems[i], size);
+			offset += size;
+		}
+	}
+	return result;
+}
+
+DLLEXPORT ArrayType* construct_array(Datum* elems, int nelems, Oid elmtype, int elmlen, bool elmbyval, char elmalign) {
+	int dims[1] = {nelems};
+	int lbs[1] = {1};
+	return construct_md_array(elems, NULL, 1, dims, lbs, elmtype, elmlen, elmbyval, elmalign);
+}
+
+DLLEXPORT ArrayType* construct_array_builtin(Datum* elems, int nelems, Oid elmtype) {
+	int elmlen;
+	bool elmbyval;
+	char elmalign;
+	if (!builtin_type_storage(elmtype, &elmlen, &elmbyval, &elmalign)) {
+		return NULL;
+	}
+	return construct_array(elems, nelems, elmtype, elmlen, elmbyval, elmalign);
+}
+
+DLLEXPORT bool array_contains_nulls(ArrayType* array) {
+	if (!ARR_HASNULL(array)) {
+		return false;
+	}
+	int nelems = ArrayGetNItems(ARR_NDIM(array), ARR_DIMS(array));
+	uint8_t* bitmap = ARR_NULLBITMAP(array);
+	for (int i = 0; i < nelems; i++) {
+		if ((bitmap[i >> 3] & (1 << (i & 0x07))) == 0) {
+			return true;
+		}
+	}
+	return false;
+}
+
+DLLEXPORT void deconstruct_array(ArrayType* array, Oid elmtype, int elmlen, bool elmbyval, char elmalign,
+	Datum** elemsp, bool** nullsp, int* nelemsp) {
+	if (ARR_ELEMTYPE(array) != elmtype) {
+		pgext_raise_error(ERROR, ERRCODE_DATATYPE_MISMATCH, "cannot deconstruct an array of type %u as type %u",
+			ARR_ELEMTYPE(array), elmtype);
+		return;
+	}
+	int nelems = ArrayGetNItems(ARR_NDIM(array), ARR_DIMS(array));
+	Datum* elems = (Datum*)palloc(nelems * sizeof(Datum) + 1);
+	bool* nulls = nullsp != NULL ? (bool*)palloc0(nelems * sizeof(bool) + 1) : NULL;
+	uint8_t* bitmap = ARR_NULLBITMAP(array);
+	char* data = ARR_DATA_PTR(array);
+	size_t offset = 0;
+	for (int i = 0; i < nelems; i++) {
+		if (bitmap != NULL && (bitmap[i >> 3] & (1 << (i & 0x07))) == 0) {
+			if (nulls == NULL) {
+				pgext_raise_error(ERROR, ERRCODE_NULL_VALUE_NOT_ALLOWED, "null array element not allowed in this context");
+				return;
+			}
+			elems[i] = 0;
+			nulls[i] = true;
+			continue;
+		}
+		// A short varlena begins with a nonzero byte, while the padding before an aligned element is always zero
+		if (elmlen != -1 || *(uint8_t*)(data + offset) == 0) {
+			offset = att_align(offset, elmalign);
+		}
+		char* ptr = data + offset;
+		if (elmbyval) {
+			switch (elmlen) {
+			case 1:
+				elems[i] = (Datum)*(uint8_t*)ptr;
+				break;
+			case 2:
+				elems[i] = (Datum)*(int16_t*)ptr;
+				break;
+			case 4:
+				elems[i] = (Datum)*(int32_t*)ptr;
+				break;
+			default:
+				elems[i] = (Datum)*(uint64_t*)ptr;
+				break;
+			}
+			offset += (size_t)elmlen;
+		} else {
+			elems[i] = (Datum)ptr;
+			if (elmlen == -1) {
+				offset += VARSIZE_ANY(ptr);
+			} else {
+				offset += array_element_size((Datum)ptr, elmlen);
+			}
+		}
+	}
+	*elemsp = elems;
+	if (nullsp != NULL) {
+		*nullsp = nulls;
+	}
+	*nelemsp = nelems;
+}
+
+DLLEXPORT void deconstruct_array_builtin(ArrayType* array, Oid elmtype, Datum** elemsp, bool** nullsp, int* nelemsp) {
+	int elmlen;
+	bool elmbyval;
+	char elmalign;
+	if (builtin_type_storage(elmtype, &elmlen, &elmbyval, &elmalign)) {
+		deconstruct_array(array, elmtype, elmlen, elmbyval, elmalign, elemsp, nullsp, nelemsp);
+	}
+}
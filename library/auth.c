@@ -0,0 +1,142 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// auth_provider is the host's auth provider, or NULL if the bootstrap superuser is the only role.
+static PgExtAuthProvider* auth_provider;
+
+// pgext_set_auth_provider sets the host's auth provider. Setting NULL leaves only the bootstrap superuser.
+DLLEXPORT uintptr_t pgext_set_auth_provider(PgExtAuthProvider* provider) {
+	auth_provider = provider;
+	return 0;
+}
+
+// pgext_backend_state_set_user sets the role of the session, as the host does once a connection has authenticated. Any
+// change to the current role that an extension made is undone.
+DLLEXPORT uintptr_t pgext_backend_state_set_user(PgExtBackendState* state, uintptr_t roleid) {
+	state->session_user_id = (Oid)roleid;
+	state->user_id = InvalidOid;
+	state->sec_context = 0;
+	return 0;
+}
+
+DLLEXPORT Oid GetSessionUserId(void) {
+	PgExtBackendState* state = pgext_backend_state();
+	return state->session_user_id != InvalidOid ? state->session_user_id : BOOTSTRAP_SUPERUSERID;
+}
+
+DLLEXPORT Oid GetUserId(void) {
+	PgExtBackendState* state = pgext_backend_state();
+	return state->user_id != InvalidOid ? state->user_id : GetSessionUserId();
+}
+
+// GetOuterUserId returns the session's role, as the host handles SET ROLE itself by changing the session's role.
+DLLEXPORT Oid GetOuterUserId(void) {
+	return GetSessionUserId();
+}
+
+DLLEXPORT Oid GetAuthenticatedUserId(void) {
+	return GetSessionUserId();
+}
+
+DLLEXPORT void GetUserIdAndSecContext(Oid* userid, int* sec_context) {
+	*userid = GetUserId();
+	*sec_context = pgext_backend_state()->sec_context;
+}
+
+// SetUserIdAndSecContext changes the current role, which extensions do while running code on behalf of another role,
+// and restore afterward with the values that they saved from GetUserIdAndSecContext.
+DLLEXPORT void SetUserIdAndSecContext(Oid userid, int sec_context) {
+	PgExtBackendState* state = pgext_backend_state();
+	state->user_id = userid;
+	state->sec_context = sec_context;
+}
+
+DLLEXPORT bool InLocalUserIdChange(void) {
+	return (pgext_backend_state()->sec_context & SECURITY_LOCAL_USERID_CHANGE) != 0;
+}
+
+DLLEXPORT bool InSecurityRestrictedOperation(void) {
+	return (pgext_backend_state()->sec_context & SECURITY_RESTRICTED_OPERATION) != 0;
+}
+
+DLLEXPORT bool InNoForceRLSOperation(void) {
+	return (pgext_backend_state()->sec_context & SECURITY_NOFORCE_RLS) != 0;
+}
+
+DLLEXPORT bool superuser_arg(Oid roleid) {
+	PgExtAuthProvider* provider = auth_provider;
+	if (provider == NULL) {
+		return roleid == BOOTSTRAP_SUPERUSERID;
+	}
+	return provider->is_superuser(roleid);
+}
+
+DLLEXPORT bool superuser(void) {
+	return superuser_arg(GetUserId());
+}
+
+// has_privs_of_role returns whether the member has the privileges of the role, which is always the case for the role
+// itself and for superusers, so the provider is only asked about other roles.
+DLLEXPORT bool has_privs_of_role(Oid member, Oid role) {
+	if (member == role || superuser_arg(member)) {
+		return true;
+	}
+	PgExtAuthProvider* provider = auth_provider;
+	return provider != NULL && provider->has_privs_of_role(member, role);
+}
+
+// lookup_role_name writes the name of the role to the buffer, returning false if the role does not exist.
+static bool lookup_role_name(Oid roleid, char* name) {
+	memset(name, 0, NAMEDATALEN);
+	PgExtAuthProvider* provider = auth_provider;
+	if (provider == NULL) {
+		if (roleid != BOOTSTRAP_SUPERUSERID) {
+			return false;
+		}
+		strcpy(name, "postgres");
+		return true;
+	}
+	if (!provider->role_name(roleid, name)) {
+		return false;
+	}
+	name[NAMEDATALEN - 1] = '\0';
+	return true;
+}
+
+DLLEXPORT char* GetUserNameFromId(Oid roleid, bool noerr) {
+	char name[NAMEDATALEN];
+	if (!lookup_role_name(roleid, name)) {
+		if (!noerr) {
+			pgext_raise_error(ERROR, ERRCODE_UNDEFINED_OBJECT, "invalid role OID: %u", roleid);
+		}
+		return NULL;
+	}
+	return pstrdup(name);
+}
+
+DLLEXPORT Oid get_role_oid(const char* rolname, bool missing_ok) {
+	PgExtAuthProvider* provider = auth_provider;
+	Oid roleid = InvalidOid;
+	if (provider != NULL) {
+		roleid = provider->role_oid(rolname);
+	} else if (strcmp(rolname, "postgres") == 0) {
+		roleid = BOOTSTRAP_SUPERUSERID;
+	}
+	if (roleid == InvalidOid && !missing_ok) {
+		pgext_raise_error(ERROR, ERRCODE_UNDEFINED_OBJECT, "role \"%s\" does not exist", rolname);
+	}
+	return roleid;
+}
@@ -0,0 +1,234 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+extern void pgext_latch_forget(PgExtBackendState* state);
+extern void pgext_backend_lock(void);
+extern void pgext_backend_unlock(void);
+
+// thread_default_state is used by threads that do not have a session's state bound to them. It's allocated on first use,
+// as the state is too large for the static TLS block that the library is limited to when loaded by dlopen.
+static _Thread_local PgExtBackendState* thread_default_state;
+// fallback_state is used when a thread's default state cannot be allocated.
+static PgExtBackendState fallback_state;
+// thread_bound_state is the session state that is bound to the current thread, or NULL if none is bound.
+static _Thread_local PgExtBackendState* thread_bound_state;
+// backend_lock_depth is the number of times that the current thread has acquired the backend lock, which is held for as
+// long as the thread has a session's state bound, and for every call that the host makes into the shim, as every thread
+// shares the globals that extensions access directly.
+static _Thread_local int backend_lock_depth;
+
+static void save_globals(PgExtBackendState* state);
+static void load_globals(PgExtBackendState* state);
+
+// acquire_backend acquires the backend lock for the current thread, which may already hold it. The globals belong to the
+// thread that holds the lock, so they're loaded from the thread's state once it's acquired. Threads without a session's
+// state bound each have their own default state, so their memory contexts and error handling are kept per thread.
+static void acquire_backend(void) {
+	if (backend_lock_depth++ == 0) {
+		pgext_backend_lock();
+		load_globals(pgext_backend_state());
+	}
+}
+
+// release_backend releases one hold of the backend lock that the current thread acquired, saving the globals to the
+// thread's state once the last hold is released.
+static void release_backend(void) {
+	if (--backend_lock_depth == 0) {
+		save_globals(pgext_backend_state());
+		pgext_backend_unlock();
+	}
+}
+
+// pgext_backend_state returns the state for the session that is executing on the current thread.
+PgExtBackendState* pgext_backend_state(void) {
+	if (thread_bound_state != NULL) {
+		return thread_bound_state;
+	}
+	if (thread_default_state == NULL) {
+		thread_default_state = (PgExtBackendState*)calloc(1, sizeof(PgExtBackendState));
+		if (thread_default_state == NULL) {
+			return &fallback_state;
+		}
+		thread_default_state->my_latch = &thread_default_state->latch;
+		thread_default_state->signal_handlers[SIGINT] = StatementCancelHandler;
+	}
+	return thread_default_state;
+}
+
+// pgext_backend_state_create allocates a new, empty state for a session.
+DLLEXPORT PgExtBackendState* pgext_backend_state_create(void) {
+	PgExtBackendState* state = (PgExtBackendState*)calloc(1, sizeof(PgExtBackendState));
+	if (state != NULL) {
+		state->owner_data.name = "Session";
+		state->resource_owner = &state->owner_data;
+		state->my_latch = &state->latch;
+		state->signal_handlers[SIGINT] = StatementCancelHandler;
+	}
+	return state;
+}
+
+// pgext_backend_state_destroy frees a state that was returned from pgext_backend_state_create, along with all of the
+// memory that was allocated on behalf of the session.
+DLLEXPORT uintptr_t pgext_backend_state_destroy(PgExtBackendState* state) {
+	acquire_backend();
+	if (state == thread_bound_state) {
+		pgext_backend_state_bind(NULL);
+	}
+	pgext_release_resources(state);
+	pgext_lwlock_unwind(state, 0);
+	pgext_latch_forget(state);
+	pgext_discard_signals(state, ~(uint64_t)0);
+	pgext_spi_free(state);
+	if (state->top_memory_context != NULL) {
+		// Deletion operates on the globals, so we preserve those of the state that is currently bound
+		MemoryContext top = TopMemoryContext;
+		MemoryContext current = CurrentMemoryContext;
+		TopMemoryContext = state->top_memory_context;
+		CurrentMemoryContext = state->current_memory_context;
+		MemoryContextDelete(state->top_memory_context);
+		TopMemoryContext = top;
+		CurrentMemoryContext = current;
+	}
+	free(state);
+	release_backend();
+	return 0;
+}
+
+// save_globals saves the globals that extensions access directly to the state.
+static void save_globals(PgExtBackendState* state) {
+	state->top_memory_context = TopMemoryContext;
+	state->current_memory_context = CurrentMemoryContext;
+	state->exception_stack = PG_exception_stack;
+	state->context_stack = error_context_stack;
+	state->spi_processed = SPI_processed;
+	state->spi_tuptable = SPI_tuptable;
+	state->spi_result = SPI_result;
+	state->resource_owner = CurrentResourceOwner;
+	state->my_latch = MyLatch;
+	state->config_reload_pending = ConfigReloadPending;
+	state->shutdown_request_pending = ShutdownRequestPending;
+	state->interrupt_holdoff_count = InterruptHoldoffCount;
+	state->query_cancel_holdoff_count = QueryCancelHoldoffCount;
+	state->crit_section_count = CritSectionCount;
+}
+
+// load_globals loads the globals that extensions access directly from the state.
+static void load_globals(PgExtBackendState* state) {
+	TopMemoryContext = state->top_memory_context;
+	CurrentMemoryContext = state->current_memory_context;
+	PG_exception_stack = state->exception_stack;
+	error_context_stack = state->context_stack;
+	SPI_processed = state->spi_processed;
+	SPI_tuptable = state->spi_tuptable;
+	SPI_result = state->spi_result;
+	CurrentResourceOwner = state->resource_owner;
+	MyLatch = state->my_latch;
+	ConfigReloadPending = state->config_reload_pending;
+	ShutdownRequestPending = state->shutdown_request_pending;
+	MyBgworkerEntry = state->bgworker_entry;
+	QueryCancelPending = state->query_cancel_pending;
+	ProcDiePending = state->proc_die_pending;
+	InterruptHoldoffCount = state->interrupt_holdoff_count;
+	QueryCancelHoldoffCount = state->query_cancel_holdoff_count;
+	CritSectionCount = state->crit_section_count;
+	// InterruptPending is shared by every session, so it's only ever set here, and cleared by ProcessInterrupts
+	if (state->query_cancel_pending || state->proc_die_pending) {
+		__atomic_store_n(&InterruptPending, true, __ATOMIC_SEQ_CST);
+	}
+	pgext_load_session_info(state);
+}
+
+// pgext_backend_state_bind binds the given state to the current thread, returning the previously-bound state. Binding
+// NULL restores the thread's default state. The memory context and error globals are saved to the previous state and
+// loaded from the new state, since extensions access them directly. The same goes for the SPI globals, the current
+// resource owner, the globals of background workers, and those that describe the session. Those globals are shared by
+// every thread, so binding a state to a thread that had none blocks until no other thread has a state bound, and
+// unbinding it lets the next thread proceed. Only one session runs at a time as a result.
+DLLEXPORT PgExtBackendState* pgext_backend_state_bind(PgExtBackendState* state) {
+	PgExtBackendState* previous = thread_bound_state;
+	if (previous == NULL && state != NULL) {
+		acquire_backend();
+	}
+	save_globals(pgext_backend_state());
+	thread_bound_state = state;
+	load_globals(pgext_backend_state());
+	if (state != NULL) {
+		pgext_load_session_config(state);
+	} else if (previous != NULL) {
+		release_backend();
+	}
+	return previous;
+}
+
+// pgext_backend_lock_acquire acquires the backend lock for the current thread, which may already hold it, so that the
+// host may change what every session sees, such as the values of configuration variables, without a session running
+// in the meantime. Each call must be paired with pgext_backend_lock_release on the same thread.
+DLLEXPORT uintptr_t pgext_backend_lock_acquire(void) {
+	acquire_backend();
+	return 0;
+}
+
+DLLEXPORT uintptr_t pgext_backend_lock_release(void) {
+	release_backend();
+	return 0;
+}
+
+// pgext_backend_lock_yield releases every hold of the backend lock that the current thread has, returning how many
+// there were, so that another thread may call into the shim on this thread's behalf, such as the dedicated thread of a
+// library. The thread must not have a session's state bound. pgext_backend_lock_resume acquires the holds again.
+DLLEXPORT uintptr_t pgext_backend_lock_yield(void) {
+	int depth = backend_lock_depth;
+	if (depth > 0) {
+		backend_lock_depth = 1;
+		release_backend();
+	}
+	return depth;
+}
+
+DLLEXPORT uintptr_t pgext_backend_lock_resume(uintptr_t depth) {
+	if (depth > 0) {
+		acquire_backend();
+		backend_lock_depth = (int)depth;
+	}
+	return 0;
+}
+
+// pgext_backend_state_current returns the state that is bound to the current thread, which is the thread's default
+// state when no session's state is bound, so that the host may cancel the call that the thread is about to make.
+DLLEXPORT PgExtBackendState* pgext_backend_state_current(void) {
+	return pgext_backend_state();
+}
+
+// pgext_backend_state_suspend saves the globals of the state that is bound to the current thread before the thread
+// blocks, such as a background worker that waits on its latch, and releases the backend lock so that other threads may
+// bind their own states in the meantime. pgext_backend_state_resume reacquires the lock and loads the globals again
+// once the thread has woken.
+void pgext_backend_state_suspend(void) {
+	save_globals(pgext_backend_state());
+	if (backend_lock_depth > 0) {
+		pgext_backend_unlock();
+	}
+}
+
+void pgext_backend_state_resume(void) {
+	if (backend_lock_depth > 0) {
+		pgext_backend_lock();
+	}
+	load_globals(pgext_backend_state());
+	if (thread_bound_state != NULL) {
+		pgext_load_session_config(thread_bound_state);
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extension_cgo
+
+import "C"
+import "sync"
+
+// backendMutex is held by the thread that has a session's state bound to it. Extensions access globals such as
+// CurrentMemoryContext and PG_exception_stack directly, and those are shared by every thread, so only one session may
+// run at a time. The mutex is released while a session blocks, so that others may run in the meantime.
+var backendMutex = &sync.Mutex{}
+
+// pgext_backend_lock blocks until the current thread may run a session. The lock is not reentrant, so the shim tracks
+// the depth of each thread's hold.
+//
+//export pgext_backend_lock
+func pgext_backend_lock() {
+	backendMutex.Lock()
+}
+
+// pgext_backend_unlock allows another thread to run a session.
+//
+//export pgext_backend_unlock
+func pgext_backend_unlock() {
+	backendMutex.Unlock()
+}
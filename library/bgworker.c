@@ -0,0 +1,361 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+extern long pgext_latch_wait(PgExtBackendState* state, Latch* latch, long timeout);
+extern void pgext_latch_wake(Latch* latch);
+extern void pgext_state_wake(PgExtBackendState* state);
+
+DLLEXPORT BackgroundWorker* MyBgworkerEntry = NULL;
+DLLEXPORT struct Latch* MyLatch = NULL;
+DLLEXPORT volatile sig_atomic_t ConfigReloadPending = false;
+DLLEXPORT volatile sig_atomic_t ShutdownRequestPending = false;
+// process_shared_preload_libraries_in_progress is only set while the host initializes a library that it loaded with
+// WithSharedPreload, which is the only time that static background workers may be registered.
+DLLEXPORT bool process_shared_preload_libraries_in_progress = false;
+
+// signalled_sessions is the number of sessions with signals that have not yet been delivered, which keeps
+// InterruptPending set until each of them has checked for interrupts.
+static int signalled_sessions = 0;
+
+// manager runs the background workers, and is set by the host before any library is initialized.
+static PgExtBackgroundWorkerManager* manager = NULL;
+
+// pgext_set_bgworker_manager sets the host's background worker manager. Setting NULL causes workers to be rejected.
+DLLEXPORT uintptr_t pgext_set_bgworker_manager(PgExtBackgroundWorkerManager* new_manager) {
+	manager = new_manager;
+	return 0;
+}
+
+// pgext_set_shared_preload sets whether the library that is being initialized was loaded as a shared preload library.
+DLLEXPORT uintptr_t pgext_set_shared_preload(uintptr_t in_progress) {
+	process_shared_preload_libraries_in_progress = in_progress != 0;
+	return 0;
+}
+
+// check_bgworker raises an error for a worker that Postgres would reject.
+static bool check_bgworker(const BackgroundWorker* worker, int elevel) {
+	if ((worker->bgw_flags & BGWORKER_BACKEND_DATABASE_CONNECTION) != 0 &&
+		(worker->bgw_flags & BGWORKER_SHMEM_ACCESS) == 0) {
+		pgext_raise_error(elevel, ERRCODE_INVALID_PARAMETER_VALUE,
+			"background worker \"%s\": must attach to shared memory in order to request a database connection",
+			worker->bgw_name);
+		return false;
+	}
+	if (worker->bgw_restart_time < BGW_NEVER_RESTART || worker->bgw_restart_time > 60 * 60 * 24 * 1000) {
+		pgext_raise_error(elevel, ERRCODE_INVALID_PARAMETER_VALUE, "background worker \"%s\": invalid restart interval",
+			worker->bgw_name);
+		return false;
+	}
+	return true;
+}
+
+// register_bgworker gives a copy of the worker to the manager, with its type defaulting to its name, returning the ID
+// that the manager gave it, or zero if it was not accepted.
+static uint64_t register_bgworker(const BackgroundWorker* worker, bool dynamic) {
+	if (manager == NULL) {
+		return 0;
+	}
+	BackgroundWorker copy = *worker;
+	if (copy.bgw_type[0] == '\0') {
+		snprintf(copy.bgw_type, BGW_MAXLEN, "%s", copy.bgw_name);
+	}
+	return manager->register_worker(&copy, dynamic);
+}
+
+DLLEXPORT void RegisterBackgroundWorker(BackgroundWorker* worker) {
+	if (!process_shared_preload_libraries_in_progress && strcmp(worker->bgw_library_name, "postgres") != 0) {
+		pgext_raise_error(LOG, ERRCODE_OBJECT_NOT_IN_PREREQUISITE_STATE,
+			"background worker \"%s\": must be registered in shared_preload_libraries", worker->bgw_name);
+		return;
+	}
+	if (!check_bgworker(worker, LOG)) {
+		return;
+	}
+	if (register_bgworker(worker, false) == 0) {
+		pgext_raise_error(LOG, ERRCODE_FEATURE_NOT_SUPPORTED, "background worker \"%s\" was not accepted by the host",
+			worker->bgw_name);
+	}
+}
+
+DLLEXPORT bool RegisterDynamicBackgroundWorker(BackgroundWorker* worker, BackgroundWorkerHandle** handle) {
+	if (!check_bgworker(worker, ERROR)) {
+		return false;
+	}
+	uint64_t id = register_bgworker(worker, true);
+	if (id == 0) {
+		return false;
+	}
+	if (handle != NULL) {
+		*handle = (BackgroundWorkerHandle*)palloc(sizeof(BackgroundWorkerHandle));
+		(*handle)->id = id;
+	}
+	return true;
+}
+
+// bgworker_status returns the status of the worker after waiting on it as requested. The session is suspended while it
+// waits, as the worker cannot start or stop until it may bind its own state.
+static BgwHandleStatus bgworker_status(BackgroundWorkerHandle* handle, int wait, pid_t* pidp) {
+	pid_t pid = 0;
+	BgwHandleStatus status = BGWH_STOPPED;
+	if (manager != NULL) {
+		if (wait != PGEXT_BGWORKER_NO_WAIT) {
+			pgext_backend_state_suspend();
+		}
+		status = (BgwHandleStatus)manager->status(handle->id, wait, &pid);
+		if (wait != PGEXT_BGWORKER_NO_WAIT) {
+			pgext_backend_state_resume();
+		}
+	}
+	if (pidp != NULL) {
+		*pidp = pid;
+	}
+	return status;
+}
+
+DLLEXPORT BgwHandleStatus GetBackgroundWorkerPid(BackgroundWorkerHandle* handle, pid_t* pidp) {
+	return bgworker_status(handle, PGEXT_BGWORKER_NO_WAIT, pidp);
+}
+
+DLLEXPORT BgwHandleStatus WaitForBackgroundWorkerStartup(BackgroundWorkerHandle* handle, pid_t* pidp) {
+	return bgworker_status(handle, PGEXT_BGWORKER_WAIT_STARTUP, pidp);
+}
+
+DLLEXPORT BgwHandleStatus WaitForBackgroundWorkerShutdown(BackgroundWorkerHandle* handle) {
+	return bgworker_status(handle, PGEXT_BGWORKER_WAIT_SHUTDOWN, NULL);
+}
+
+DLLEXPORT void TerminateBackgroundWorker(BackgroundWorkerHandle* handle) {
+	if (manager != NULL) {
+		manager->terminate(handle->id);
+	}
+}
+
+// Workers share the process with every session, so signals are never blocked or unblocked for real. They're instead
+// delivered on the worker's own thread through pgext_deliver_signals.
+DLLEXPORT void BackgroundWorkerBlockSignals(void) {
+}
+
+DLLEXPORT void BackgroundWorkerUnblockSignals(void) {
+}
+
+// Workers run SPI against the host, which has no notion of connecting to a particular database, so connecting only
+// sets the worker's role, which is the bootstrap superuser when none is given, and the database that it reports.
+DLLEXPORT void BackgroundWorkerInitializeConnection(const char* dbname, const char* username, uint32_t flags) {
+	PgExtBackendState* state = pgext_backend_state();
+	if (username != NULL) {
+		state->session_user_id = get_role_oid(username, false);
+	}
+	if (dbname != NULL) {
+		snprintf(state->database_name, NAMEDATALEN, "%s", dbname);
+	}
+}
+
+DLLEXPORT void BackgroundWorkerInitializeConnectionByOid(Oid dboid, Oid useroid, uint32_t flags) {
+	PgExtBackendState* state = pgext_backend_state();
+	if (useroid != InvalidOid) {
+		state->session_user_id = useroid;
+	}
+	if (dboid != InvalidOid) {
+		state->database_id = dboid;
+		MyDatabaseId = dboid;
+	}
+}
+
+// Configuration variables are held by the host, which applies changes as they're made, so there is no file to reread.
+DLLEXPORT void ProcessConfigFile(int context) {
+}
+
+// pqsignal records the handler for the session rather than installing it, as the handlers of the process belong to the
+// Go runtime.
+DLLEXPORT pqsigfunc pqsignal(int signo, pqsigfunc func) {
+	if (signo <= 0 || signo >= PGEXT_NSIG) {
+		return (pqsigfunc)SIG_ERR;
+	}
+	PgExtBackendState* state = pgext_backend_state();
+	pqsigfunc previous = state->signal_handlers[signo];
+	state->signal_handlers[signo] = func;
+	return previous != NULL ? previous : (pqsigfunc)SIG_DFL;
+}
+
+// pgext_signal_backend sends the signal to the session, which is delivered once the session next waits on a latch or
+// checks for interrupts. The session's own latch is set, the same as Postgres's signal handlers do, and InterruptPending
+// is set so that the session's next CHECK_FOR_INTERRUPTS delivers it. The state may be bound to another thread.
+DLLEXPORT uintptr_t pgext_signal_backend(PgExtBackendState* state, uintptr_t signo) {
+	if (signo == 0 || signo >= PGEXT_NSIG) {
+		return 0;
+	}
+	uint64_t previous = __atomic_fetch_or(&state->pending_signals, (uint64_t)1 << signo, __ATOMIC_SEQ_CST);
+	if (previous == 0) {
+		__atomic_fetch_add(&signalled_sessions, 1, __ATOMIC_SEQ_CST);
+	}
+	__atomic_store_n(&InterruptPending, true, __ATOMIC_SEQ_CST);
+	__atomic_store_n(&state->latch.is_set, true, __ATOMIC_SEQ_CST);
+	pgext_latch_wake(&state->latch);
+	pgext_state_wake(state);
+	return 1;
+}
+
+// pgext_deliver_signals calls the handlers of the signals that were sent to the session that is bound to the current
+// thread. Signals without a handler of the session's own are dropped, as the default action of most would end the
+// process.
+void pgext_deliver_signals(void) {
+	PgExtBackendState* state = pgext_backend_state();
+	uint64_t pending = __atomic_exchange_n(&state->pending_signals, 0, __ATOMIC_SEQ_CST);
+	if (pending != 0) {
+		__atomic_fetch_sub(&signalled_sessions, 1, __ATOMIC_SEQ_CST);
+	}
+	for (int signo = 1; pending != 0 && signo < PGEXT_NSIG; signo++) {
+		if ((pending & ((uint64_t)1 << signo)) == 0) {
+			continue;
+		}
+		pending &= ~((uint64_t)1 << signo);
+		pqsigfunc handler = state->signal_handlers[signo];
+		if (handler != NULL && handler != (pqsigfunc)SIG_DFL && handler != (pqsigfunc)SIG_IGN) {
+			handler(signo);
+		}
+	}
+}
+
+// pgext_discard_signals removes the signals in the mask from those that were sent to the session without delivering
+// them.
+void pgext_discard_signals(PgExtBackendState* state, uint64_t mask) {
+	uint64_t previous = __atomic_fetch_and(&state->pending_signals, ~mask, __ATOMIC_SEQ_CST);
+	if (previous != 0 && (previous & ~mask) == 0) {
+		__atomic_fetch_sub(&signalled_sessions, 1, __ATOMIC_SEQ_CST);
+	}
+}
+
+// pgext_signals_pending returns whether any session has been sent signals that have not yet been delivered.
+bool pgext_signals_pending(void) {
+	return __atomic_load_n(&signalled_sessions, __ATOMIC_SEQ_CST) > 0;
+}
+
+DLLEXPORT void SignalHandlerForConfigReload(SIGNAL_ARGS) {
+	ConfigReloadPending = true;
+	SetLatch(MyLatch);
+}
+
+DLLEXPORT void SignalHandlerForShutdownRequest(SIGNAL_ARGS) {
+	ShutdownRequestPending = true;
+	SetLatch(MyLatch);
+}
+
+// bgworker_die is the handler of SIGTERM that every worker begins with, which is the same as Postgres.
+static void bgworker_die(SIGNAL_ARGS) {
+	BackgroundWorker* entry = pgext_backend_state()->bgworker_entry;
+	pgext_raise_error(FATAL, ERRCODE_ADMIN_SHUTDOWN, "terminating background worker \"%s\" due to administrator command",
+		entry != NULL ? entry->bgw_type : "unknown");
+}
+
+DLLEXPORT void InitLatch(Latch* latch) {
+	latch->is_set = false;
+	latch->maybe_sleeping = false;
+	latch->is_shared = false;
+	latch->owner_pid = 0;
+}
+
+DLLEXPORT void InitSharedLatch(Latch* latch) {
+	InitLatch(latch);
+	latch->is_shared = true;
+}
+
+DLLEXPORT void OwnLatch(Latch* latch) {
+}
+
+DLLEXPORT void DisownLatch(Latch* latch) {
+}
+
+DLLEXPORT void SetLatch(Latch* latch) {
+	if (latch == NULL || __atomic_load_n(&latch->is_set, __ATOMIC_SEQ_CST)) {
+		return;
+	}
+	__atomic_store_n(&latch->is_set, true, __ATOMIC_SEQ_CST);
+	pgext_latch_wake(latch);
+}
+
+DLLEXPORT void ResetLatch(Latch* latch) {
+	__atomic_store_n(&latch->is_set, false, __ATOMIC_SEQ_CST);
+}
+
+// WaitLatch waits until the latch is set or the timeout elapses, delivering the signals that the session is sent in the
+// meantime. Sockets and the death of the postmaster are never reported, as the host does not die before its workers.
+// The session's globals are reloaded after every wait, as other sessions may have replaced them.
+DLLEXPORT int WaitLatch(Latch* latch, int wakeEvents, long timeout, uint32_t wait_event_info) {
+	PgExtBackendState* state = pgext_backend_state();
+	bool wait_latch = (wakeEvents & WL_LATCH_SET) != 0 && latch != NULL;
+	long remaining = (wakeEvents & WL_TIMEOUT) != 0 ? timeout : -1;
+	for (;;) {
+		pgext_deliver_signals();
+		if (wait_latch && __atomic_load_n(&latch->is_set, __ATOMIC_SEQ_CST)) {
+			return WL_LATCH_SET;
+		}
+		if (remaining == 0) {
+			return WL_TIMEOUT;
+		}
+		pgext_backend_state_suspend();
+		remaining = pgext_latch_wait(state, wait_latch ? latch : NULL, remaining);
+		pgext_backend_state_resume();
+	}
+}
+
+DLLEXPORT int WaitLatchOrSocket(Latch* latch, int wakeEvents, int sock, long timeout, uint32_t wait_event_info) {
+	return WaitLatch(latch, wakeEvents & ~(WL_SOCKET_READABLE | WL_SOCKET_WRITEABLE), timeout, wait_event_info);
+}
+
+// proc_exit ends the background worker that is running on the current thread, by raising an error that unwinds to
+// pgext_bgworker_main, which reports the exit code rather than the error.
+DLLEXPORT void proc_exit(int code) {
+	PgExtBackendState* state = pgext_backend_state();
+	if (state->bgworker_entry == NULL) {
+		pgext_raise_error(FATAL, ERRCODE_FEATURE_NOT_SUPPORTED, "proc_exit(%d) may only be called by a background worker",
+			code);
+		return;
+	}
+	state->exit_requested = true;
+	state->exit_code = code;
+	pgext_raise_error(FATAL, ERRCODE_ADMIN_SHUTDOWN, "background worker \"%s\" exited with exit code %d",
+		state->bgworker_entry->bgw_type, code);
+}
+
+// call_bgworker_main calls the main function of the worker that is running on the current thread.
+static Datum call_bgworker_main(void* arg) {
+	((void (*)(Datum))arg)(pgext_backend_state()->bgworker_entry->bgw_main_arg);
+	return 0;
+}
+
+// pgext_bgworker_main runs the main function of the worker within the session that is bound to the current thread,
+// writing the code that the worker exited with, which is 1 when the worker raised an error. Returns the error that the
+// worker raised, or NULL if it returned or called proc_exit.
+DLLEXPORT PgExtErrorData* pgext_bgworker_main(BackgroundWorker* entry, void (*fn)(Datum), int* exit_code) {
+	PgExtBackendState* state = pgext_backend_state();
+	state->bgworker_entry = entry;
+	state->exit_requested = false;
+	state->exit_code = 0;
+	MyBgworkerEntry = entry;
+	memset(state->signal_handlers, 0, sizeof(state->signal_handlers));
+	state->signal_handlers[SIGTERM] = bgworker_die;
+	Datum result;
+	PgExtErrorData* edata = pgext_catch_errors(call_bgworker_main, (void*)fn, &result);
+	if (state->exit_requested) {
+		edata = NULL;
+		*exit_code = state->exit_code;
+	} else {
+		*exit_code = edata != NULL ? 1 : 0;
+	}
+	state->bgworker_entry = NULL;
+	MyBgworkerEntry = NULL;
+	return edata;
+}
@@ -28,3 +28,15 @@ printf "module github.com/dolthub/pg_extension\n\ngo 1.24" > ./temp_lib/go.mod
     cd temp_lib
     CGO_ENABLED=1 go build -buildmode=c-shared -o "../../output/pg_extension.${ext}" .
 )
+
+# The shim is embedded into binaries that import this package, so it's copied to the directory of the current platform.
+# MacOS links the exported functions into the binary instead, so it does not embed a shim. Shims that are built against
+# musl (such as on Alpine) cannot be loaded by glibc processes and vice versa, so they have their own directory.
+if [ "$(go env GOOS)" != "darwin" ]; then
+    platform_dir="../shim/$(go env GOOS)_$(go env GOARCH)"
+    if [ "$(go env GOOS)" = "linux" ] && ldd --version 2>&1 | grep -qi musl; then
+        platform_dir="${platform_dir}_musl"
+    fi
+    mkdir -p "$platform_dir"
+    cp "../output/pg_extension.${ext}" "$platform_dir/"
+fi
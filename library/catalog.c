@@ -0,0 +1,560 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// catalog_provider is the host's catalog provider, or NULL if only the built-in types exist.
+static PgExtCatalogProvider* catalog_provider;
+// catalog_generation is advanced whenever the catalog may have changed, which refreshes the type cache of each session
+// the next time that it's used.
+static uint64_t catalog_generation = 1;
+
+// PgExtTypeCacheNode is an entry of a session's type cache. The entry comes first, as extensions are given its address.
+typedef struct PgExtTypeCacheNode {
+	TypeCacheEntry             entry;
+	uint64_t                   generation;
+	struct PgExtTypeCacheNode* next;
+} PgExtTypeCacheNode;
+
+#define BASE_TYPE(type_oid, len, byval, align, storage, category, collation, array_oid, name) \
+	{.oid = type_oid, .typlen = len, .typbyval = byval, .typalign = align, .typstorage = storage, \
+	 .typtype = TYPTYPE_BASE, .typcategory = category, .typdelim = ',', .typcollation = collation, \
+	 .typarray = array_oid, .typname = name, .nspname = "pg_catalog"}
+#define PSEUDO_TYPE(type_oid, len, align, storage, array_oid, name) \
+	{.oid = type_oid, .typlen = len, .typbyval = false, .typalign = align, .typstorage = storage, \
+	 .typtype = TYPTYPE_PSEUDO, .typcategory = 'P', .typdelim = ',', .typarray = array_oid, .typname = name, \
+	 .nspname = "pg_catalog"}
+#define ARRAY_TYPE(type_oid, elem_oid, align, collation, name) \
+	{.oid = type_oid, .typlen = -1, .typbyval = false, .typalign = align, .typstorage = 'x', \
+	 .typtype = TYPTYPE_BASE, .typcategory = 'A', .typdelim = ',', .typcollation = collation, .typelem = elem_oid, \
+	 .typname = name, .nspname = "pg_catalog"}
+
+// builtin_types are the types that exist without a catalog provider, which only describe the storage and names of the
+// types, as their functions belong to the host.
+static const PgExtTypeInfo builtin_types[] = {
+	BASE_TYPE(16, 1, true, 'c', 'p', 'B', 0, 1000, "bool"),
+	BASE_TYPE(17, -1, false, 'i', 'x', 'U', 0, 1001, "bytea"),
+	BASE_TYPE(18, 1, true, 'c', 'p', 'Z', 0, 1002, "char"),
+	BASE_TYPE(19, NAMEDATALEN, false, 'c', 'p', 'S', C_COLLATION_OID, 1003, "name"),
+	BASE_TYPE(20, 8, true, 'd', 'p', 'N', 0, 1016, "int8"),
+	BASE_TYPE(21, 2, true, 's', 'p', 'N', 0, 1005, "int2"),
+	BASE_TYPE(23, 4, true, 'i', 'p', 'N', 0, 1007, "int4"),
+	BASE_TYPE(25, -1, false, 'i', 'x', 'S', DEFAULT_COLLATION_OID, 1009, "text"),
+	BASE_TYPE(26, 4, true, 'i', 'p', 'N', 0, 1028, "oid"),
+	BASE_TYPE(114, -1, false, 'i', 'x', 'U', 0, 199, "json"),
+	BASE_TYPE(700, 4, true, 'i', 'p', 'N', 0, 1021, "float4"),
+	BASE_TYPE(701, 8, true, 'd', 'p', 'N', 0, 1022, "float8"),
+	BASE_TYPE(869, -1, false, 'i', 'm', 'I', 0, 1041, "inet"),
+	BASE_TYPE(1042, -1, false, 'i', 'x', 'S', DEFAULT_COLLATION_OID, 1014, "bpchar"),
+	BASE_TYPE(1043, -1, false, 'i', 'x', 'S', DEFAULT_COLLATION_OID, 1015, "varchar"),
+	BASE_TYPE(1082, 4, true, 'i', 'p', 'D', 0, 1182, "date"),
+	BASE_TYPE(1083, 8, true, 'd', 'p', 'D', 0, 1183, "time"),
+	BASE_TYPE(1114, 8, true, 'd', 'p', 'D', 0, 1115, "timestamp"),
+	BASE_TYPE(1184, 8, true, 'd', 'p', 'D', 0, 1185, "timestamptz"),
+	BASE_TYPE(1186, 16, false, 'd', 'p', 'T', 0, 1187, "interval"),
+	BASE_TYPE(1266, 12, false, 'd', 'p', 'D', 0, 1270, "timetz"),
+	BASE_TYPE(1700, -1, false, 'i', 'm', 'N', 0, 1231, "numeric"),
+	PSEUDO_TYPE(2249, -1, 'd', 'x', 2287, "record"),
+	PSEUDO_TYPE(2275, -2, 'c', 'p', 1263, "cstring"),
+	BASE_TYPE(2950, 16, false, 'c', 'p', 'U', 0, 2951, "uuid"),
+	BASE_TYPE(3802, -1, false, 'i', 'x', 'U', 0, 3807, "jsonb"),
+	ARRAY_TYPE(1000, 16, 'i', 0, "_bool"),
+	ARRAY_TYPE(1001, 17, 'i', 0, "_bytea"),
+	ARRAY_TYPE(1002, 18, 'i', 0, "_char"),
+	ARRAY_TYPE(1003, 19, 'i', C_COLLATION_OID, "_name"),
+	ARRAY_TYPE(1016, 20, 'd', 0, "_int8"),
+	ARRAY_TYPE(1005, 21, 'i', 0, "_int2"),
+	ARRAY_TYPE(1007, 23, 'i', 0, "_int4"),
+	ARRAY_TYPE(1009, 25, 'i', DEFAULT_COLLATION_OID, "_text"),
+	ARRAY_TYPE(1028, 26, 'i', 0, "_oid"),
+	ARRAY_TYPE(199, 114, 'i', 0, "_json"),
+	ARRAY_TYPE(1021, 700, 'i', 0, "_float4"),
+	ARRAY_TYPE(1022, 701, 'd', 0, "_float8"),
+	ARRAY_TYPE(1041, 869, 'i', 0, "_inet"),
+	ARRAY_TYPE(1014, 1042, 'i', DEFAULT_COLLATION_OID, "_bpchar"),
+	ARRAY_TYPE(1015, 1043, 'i', DEFAULT_COLLATION_OID, "_varchar"),
+	ARRAY_TYPE(1182, 1082, 'i', 0, "_date"),
+	ARRAY_TYPE(1183, 1083, 'd', 0, "_time"),
+	ARRAY_TYPE(1115, 1114, 'd', 0, "_timestamp"),
+	ARRAY_TYPE(1185, 1184, 'd', 0, "_timestamptz"),
+	ARRAY_TYPE(1187, 1186, 'd', 0, "_interval"),
+	ARRAY_TYPE(1270, 1266, 'd', 0, "_timetz"),
+	ARRAY_TYPE(1231, 1700, 'i', 0, "_numeric"),
+	ARRAY_TYPE(2287, 2249, 'd', 0, "_record"),
+	ARRAY_TYPE(1263, 2275, 'i', 0, "_cstring"),
+	ARRAY_TYPE(2951, 2950, 'i', 0, "_uuid"),
+	ARRAY_TYPE(3807, 3802, 'i', 0, "_jsonb"),
+};
+
+// pgext_set_catalog_provider sets the host's catalog provider. Setting NULL leaves only the built-in types.
+DLLEXPORT uintptr_t pgext_set_catalog_provider(PgExtCatalogProvider* provider) {
+	catalog_provider = provider;
+	__atomic_fetch_add(&catalog_generation, 1, __ATOMIC_SEQ_CST);
+	return 0;
+}
+
+// pgext_invalidate_type_cache causes every session to look its cached types up again, such as after the host has
+// altered a type.
+DLLEXPORT uintptr_t pgext_invalidate_type_cache(void) {
+	__atomic_fetch_add(&catalog_generation, 1, __ATOMIC_SEQ_CST);
+	return 0;
+}
+
+// pgext_lookup_type writes the type with the given OID to the info, asking the catalog provider before the built-in
+// types. Returns false if the type does not exist.
+bool pgext_lookup_type(Oid oid, PgExtTypeInfo* info) {
+	memset(info, 0, sizeof(PgExtTypeInfo));
+	PgExtCatalogProvider* provider = catalog_provider;
+	if (provider != NULL && provider->lookup_type(oid, info)) {
+		info->oid = oid;
+		info->typname[NAMEDATALEN - 1] = '\0';
+		info->nspname[NAMEDATALEN - 1] = '\0';
+		return true;
+	}
+	for (size_t i = 0; i < sizeof(builtin_types) / sizeof(builtin_types[0]); i++) {
+		if (builtin_types[i].oid == oid) {
+			*info = builtin_types[i];
+			return true;
+		}
+	}
+	memset(info, 0, sizeof(PgExtTypeInfo));
+	return false;
+}
+
+// require_type looks up the type, raising an error if it does not exist. Returns whether the type was found.
+static bool require_type(Oid typid, PgExtTypeInfo* info) {
+	if (!pgext_lookup_type(typid, info)) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "cache lookup failed for type %u", typid);
+		return false;
+	}
+	return true;
+}
+
+// is_true_array returns whether the type is an array of its element type, rather than a fixed-length type that may
+// be subscripted, such as name.
+static bool is_true_array(const PgExtTypeInfo* info) {
+	return info->typelem != 0 && info->typlen == -1;
+}
+
+// fill_type_cache_entry sets the fields of the entry that describe the type, and clears those that were computed from
+// an earlier version of the type.
+static void fill_type_cache_entry(TypeCacheEntry* entry, const PgExtTypeInfo* info) {
+	memset(entry, 0, sizeof(TypeCacheEntry));
+	entry->type_id = info->oid;
+	entry->type_id_hash = info->oid;
+	entry->typlen = info->typlen;
+	entry->typbyval = info->typbyval;
+	entry->typalign = info->typalign;
+	entry->typstorage = info->typstorage;
+	entry->typtype = info->typtype;
+	entry->typrelid = info->typrelid;
+	entry->typelem = info->typelem;
+	entry->typcollation = info->typcollation;
+}
+
+// fill_finfo looks up the function for the entry's FmgrInfo the first time that it's requested, within the top memory
+// context, as entries last for the life of the session.
+static void fill_finfo(FmgrInfo* finfo, Oid proc) {
+	if (proc != 0 && finfo->fn_oid == 0) {
+		fmgr_info_cxt(proc, finfo, TopMemoryContext);
+	}
+}
+
+// lookup_type_cache returns the session's entry for the type, filling in the fields that the flags request. Operators
+// and their functions come from the catalog provider, so they're left invalid for types that it does not describe.
+// Descriptors are only filled in for composite types that are blessed records, as the shim has no relations.
+DLLEXPORT TypeCacheEntry* lookup_type_cache(Oid type_id, int flags) {
+	PgExtBackendState* state = pgext_backend_state();
+	uint64_t generation = __atomic_load_n(&catalog_generation, __ATOMIC_SEQ_CST);
+	PgExtTypeCacheNode* node = state->type_cache;
+	while (node != NULL && node->entry.type_id != type_id) {
+		node = node->next;
+	}
+	PgExtTypeInfo info;
+	if (node == NULL || node->generation != generation || flags != 0) {
+		if (!require_type(type_id, &info)) {
+			return NULL;
+		}
+	}
+	if (node == NULL) {
+		pgext_current_context();
+		node = (PgExtTypeCacheNode*)MemoryContextAllocZero(TopMemoryContext, sizeof(PgExtTypeCacheNode));
+		fill_type_cache_entry(&node->entry, &info);
+		node->generation = generation;
+		node->next = state->type_cache;
+		state->type_cache = node;
+	} else if (node->generation != generation) {
+		fill_type_cache_entry(&node->entry, &info);
+		node->generation = generation;
+	}
+	TypeCacheEntry* entry = &node->entry;
+	if ((flags & (TYPECACHE_EQ_OPR | TYPECACHE_EQ_OPR_FINFO)) != 0) {
+		entry->eq_opr = info.eq_opr;
+	}
+	if ((flags & TYPECACHE_LT_OPR) != 0) {
+		entry->lt_opr = info.lt_opr;
+	}
+	if ((flags & TYPECACHE_GT_OPR) != 0) {
+		entry->gt_opr = info.gt_opr;
+	}
+	if ((flags & (TYPECACHE_CMP_PROC | TYPECACHE_CMP_PROC_FINFO)) != 0) {
+		entry->cmp_proc = info.cmp_proc;
+	}
+	if ((flags & (TYPECACHE_HASH_PROC | TYPECACHE_HASH_PROC_FINFO)) != 0) {
+		entry->hash_proc = info.hash_proc;
+	}
+	if ((flags & TYPECACHE_EQ_OPR_FINFO) != 0 && entry->eq_opr != 0) {
+		fill_finfo(&entry->eq_opr_finfo, info.eq_proc);
+	}
+	if ((flags & TYPECACHE_CMP_PROC_FINFO) != 0) {
+		fill_finfo(&entry->cmp_proc_finfo, entry->cmp_proc);
+	}
+	if ((flags & TYPECACHE_HASH_PROC_FINFO) != 0) {
+		fill_finfo(&entry->hash_proc_finfo, entry->hash_proc);
+	}
+	if ((flags & TYPECACHE_DOMAIN_BASE_INFO) != 0 && entry->typtype == TYPTYPE_DOMAIN &&
+		entry->domainBaseType == 0) {
+		entry->domainBaseTypmod = -1;
+		entry->domainBaseType = getBaseTypeAndTypmod(type_id, &entry->domainBaseTypmod);
+	}
+	return entry;
+}
+
+DLLEXPORT int16_t get_typlen(Oid typid) {
+	PgExtTypeInfo info;
+	return pgext_lookup_type(typid, &info) ? info.typlen : 0;
+}
+
+DLLEXPORT bool get_typbyval(Oid typid) {
+	PgExtTypeInfo info;
+	return pgext_lookup_type(typid, &info) && info.typbyval;
+}
+
+DLLEXPORT void get_typlenbyval(Oid typid, int16_t* typlen, bool* typbyval) {
+	PgExtTypeInfo info;
+	if (require_type(typid, &info)) {
+		*typlen = info.typlen;
+		*typbyval = info.typbyval;
+	}
+}
+
+DLLEXPORT void get_typlenbyvalalign(Oid typid, int16_t* typlen, bool* typbyval, char* typalign) {
+	PgExtTypeInfo info;
+	if (require_type(typid, &info)) {
+		*typlen = info.typlen;
+		*typbyval = info.typbyval;
+		*typalign = info.typalign;
+	}
+}
+
+DLLEXPORT char get_typstorage(Oid typid) {
+	PgExtTypeInfo info;
+	return pgext_lookup_type(typid, &info) ? info.typstorage : 'p';
+}
+
+DLLEXPORT char get_typtype(Oid typid) {
+	PgExtTypeInfo info;
+	return pgext_lookup_type(typid, &info) ? info.typtype : '\0';
+}
+
+DLLEXPORT Oid get_typcollation(Oid typid) {
+	PgExtTypeInfo info;
+	return pgext_lookup_type(typid, &info) ? info.typcollation : 0;
+}
+
+DLLEXPORT Oid get_typ_typrelid(Oid typid) {
+	PgExtTypeInfo info;
+	return pgext_lookup_type(typid, &info) ? info.typrelid : 0;
+}
+
+DLLEXPORT bool type_is_rowtype(Oid typid) {
+	if (typid == RECORDOID) {
+		return true;
+	}
+	switch (get_typtype(typid)) {
+	case TYPTYPE_COMPOSITE:
+		return true;
+	case TYPTYPE_DOMAIN:
+		return get_typtype(getBaseType(typid)) == TYPTYPE_COMPOSITE;
+	default:
+		return false;
+	}
+}
+
+DLLEXPORT Oid get_element_type(Oid typid) {
+	PgExtTypeInfo info;
+	return pgext_lookup_type(typid, &info) && is_true_array(&info) ? info.typelem : 0;
+}
+
+DLLEXPORT Oid get_array_type(Oid typid) {
+	PgExtTypeInfo info;
+	return pgext_lookup_type(typid, &info) ? info.typarray : 0;
+}
+
+// get_base_element_type returns the element type of an array, or of the array that a domain is over.
+DLLEXPORT Oid get_base_element_type(Oid typid) {
+	PgExtTypeInfo info;
+	while (pgext_lookup_type(typid, &info)) {
+		if (info.typtype != TYPTYPE_DOMAIN) {
+			return is_true_array(&info) ? info.typelem : 0;
+		}
+		typid = info.typbasetype;
+	}
+	return 0;
+}
+
+DLLEXPORT Oid getBaseType(Oid typid) {
+	int32_t typmod = -1;
+	return getBaseTypeAndTypmod(typid, &typmod);
+}
+
+// getBaseTypeAndTypmod returns the type that a domain is over, following domains over other domains, along with the
+// typmod that the domain applies. Types that are not domains are returned as they are, keeping the given typmod.
+DLLEXPORT Oid getBaseTypeAndTypmod(Oid typid, int32_t* typmod) {
+	PgExtTypeInfo info;
+	for (;;) {
+		if (!require_type(typid, &info)) {
+			return 0;
+		}
+		if (info.typtype != TYPTYPE_DOMAIN) {
+			return typid;
+		}
+		typid = info.typbasetype;
+		*typmod = info.typtypmod;
+	}
+}
+
+// type_io_param returns the parameter that the type's I/O functions are given, which is the element type for arrays,
+// and the type itself otherwise.
+static Oid type_io_param(const PgExtTypeInfo* info) {
+	return info->typelem != 0 ? info->typelem : info->oid;
+}
+
+DLLEXPORT void get_type_io_data(Oid typid, IOFuncSelector which_func, int16_t* typlen, bool* typbyval, char* typalign,
+	char* typdelim, Oid* typioparam, Oid* func) {
+	PgExtTypeInfo info;
+	if (!require_type(typid, &info)) {
+		return;
+	}
+	*typlen = info.typlen;
+	*typbyval = info.typbyval;
+	*typalign = info.typalign;
+	*typdelim = info.typdelim;
+	*typioparam = type_io_param(&info);
+	switch (which_func) {
+	case IOFunc_input:
+		*func = info.typinput;
+		break;
+	case IOFunc_output:
+		*func = info.typoutput;
+		break;
+	case IOFunc_receive:
+		*func = info.typreceive;
+		break;
+	case IOFunc_send:
+		*func = info.typsend;
+		break;
+	}
+}
+
+// require_io_function raises an error when the type does not have the I/O function, which is described by the kind,
+// such as "input" or "binary output". Returns whether the type has the function.
+static bool require_io_function(const PgExtTypeInfo* info, Oid func, const char* kind) {
+	if (func == 0) {
+		pgext_raise_error(ERROR, ERRCODE_UNDEFINED_FUNCTION, "no %s function available for type %s", kind,
+			format_type_be(info->oid));
+		return false;
+	}
+	return true;
+}
+
+DLLEXPORT void getTypeInputInfo(Oid type, Oid* typInput, Oid* typIOParam) {
+	PgExtTypeInfo info;
+	if (require_type(type, &info) && require_io_function(&info, info.typinput, "input")) {
+		*typInput = info.typinput;
+		*typIOParam = type_io_param(&info);
+	}
+}
+
+DLLEXPORT void getTypeOutputInfo(Oid type, Oid* typOutput, bool* typIsVarlena) {
+	PgExtTypeInfo info;
+	if (require_type(type, &info) && require_io_function(&info, info.typoutput, "output")) {
+		*typOutput = info.typoutput;
+		*typIsVarlena = !info.typbyval && info.typlen == -1;
+	}
+}
+
+DLLEXPORT void getTypeBinaryInputInfo(Oid type, Oid* typReceive, Oid* typIOParam) {
+	PgExtTypeInfo info;
+	if (require_type(type, &info) && require_io_function(&info, info.typreceive, "binary input")) {
+		*typReceive = info.typreceive;
+		*typIOParam = type_io_param(&info);
+	}
+}
+
+DLLEXPORT void getTypeBinaryOutputInfo(Oid type, Oid* typSend, bool* typIsVarlena) {
+	PgExtTypeInfo info;
+	if (require_type(type, &info) && require_io_function(&info, info.typsend, "binary output")) {
+		*typSend = info.typsend;
+		*typIsVarlena = !info.typbyval && info.typlen == -1;
+	}
+}
+
+// append_identifier appends the identifier, quoting it unless it only contains lowercase letters, digits, and
+// underscores, and does not begin with a digit.
+static void append_identifier(StringInfo buf, const char* ident) {
+	bool safe = (ident[0] >= 'a' && ident[0] <= 'z') || ident[0] == '_';
+	for (const char* c = ident; *c != '\0' && safe; c++) {
+		safe = (*c >= 'a' && *c <= 'z') || (*c >= '0' && *c <= '9') || *c == '_';
+	}
+	if (safe) {
+		appendStringInfoString(buf, ident);
+		return;
+	}
+	appendStringInfoChar(buf, '"');
+	for (const char* c = ident; *c != '\0'; c++) {
+		if (*c == '"') {
+			appendStringInfoChar(buf, '"');
+		}
+		appendStringInfoChar(buf, *c);
+	}
+	appendStringInfoChar(buf, '"');
+}
+
+// append_typmod appends the type modifier as the type's typmodout function would. The built-in types that take a
+// modifier are formatted by the shim, except that the fields of an interval are not named.
+static void append_typmod(StringInfo buf, const PgExtTypeInfo* info, int32_t typemod) {
+	switch (info->oid) {
+	case 1042: // bpchar
+	case 1043: // varchar
+		if (typemod > VARHDRSZ) {
+			appendStringInfo(buf, "(%d)", typemod - VARHDRSZ);
+		}
+		return;
+	case 1700: { // numeric
+		int32_t tmp = typemod - VARHDRSZ;
+		appendStringInfo(buf, "(%d,%d)", (tmp >> 16) & 0xffff, ((tmp & 0x7ff) ^ 1024) - 1024);
+		return;
+	}
+	case 1083: // time
+	case 1114: // timestamp
+	case 1184: // timestamptz
+	case 1266: // timetz
+		appendStringInfo(buf, "(%d)", typemod);
+		return;
+	case 1186: // interval
+		if ((typemod & 0xffff) != 0xffff) {
+			appendStringInfo(buf, "(%d)", typemod & 0xffff);
+		}
+		return;
+	}
+	if (info->typmodout != 0) {
+		appendStringInfoString(buf, (char*)OidFunctionCall1(info->typmodout, (Datum)typemod));
+	} else {
+		appendStringInfo(buf, "(%d)", typemod);
+	}
+}
+
+// format_type formats the type the same as Postgres, where the built-in types that SQL names differently are given
+// their SQL names, and other types are given their own names, qualified by their schema when requested. A typmod that
+// is not negative is included.
+static char* format_type(Oid type_oid, int32_t typemod, bool typemod_given, bool qualify) {
+	PgExtTypeInfo info;
+	if (!require_type(type_oid, &info)) {
+		return NULL;
+	}
+	bool is_array = is_true_array(&info);
+	if (is_array && !require_type(info.typelem, &info)) {
+		return NULL;
+	}
+	bool with_typemod = typemod_given && typemod >= 0;
+	const char* sql_name = NULL;
+	const char* suffix = "";
+	switch (info.oid) {
+	case 16: // bool
+		sql_name = "boolean";
+		break;
+	case 1042: // bpchar
+		sql_name = with_typemod || !typemod_given ? "character" : "bpchar";
+		break;
+	case 700: // float4
+		sql_name = "real";
+		break;
+	case 701: // float8
+		sql_name = "double precision";
+		break;
+	case 21: // int2
+		sql_name = "smallint";
+		break;
+	case 23: // int4
+		sql_name = "integer";
+		break;
+	case 20: // int8
+		sql_name = "bigint";
+		break;
+	case 1186: // interval
+		sql_name = "interval";
+		break;
+	case 1083: // time
+		sql_name = "time";
+		suffix = " without time zone";
+		break;
+	case 1266: // timetz
+		sql_name = "time";
+		suffix = " with time zone";
+		break;
+	case 1114: // timestamp
+		sql_name = "timestamp";
+		suffix = " without time zone";
+		break;
+	case 1184: // timestamptz
+		sql_name = "timestamp";
+		suffix = " with time zone";
+		break;
+	case 1043: // varchar
+		sql_name = "character varying";
+		break;
+	}
+	StringInfoData buf;
+	initStringInfo(&buf);
+	if (sql_name != NULL) {
+		appendStringInfoString(&buf, sql_name);
+	} else {
+		if (qualify && info.nspname[0] != '\0') {
+			append_identifier(&buf, info.nspname);
+			appendStringInfoChar(&buf, '.');
+		}
+		append_identifier(&buf, info.typname);
+	}
+	if (with_typemod) {
+		append_typmod(&buf, &info, typemod);
+	}
+	appendStringInfoString(&buf, suffix);
+	if (is_array) {
+		appendStringInfoString(&buf, "[]");
+	}
+	return buf.data;
+}
+
+DLLEXPORT char* format_type_be(Oid type_oid) {
+	return format_type(type_oid, -1, false, false);
+}
+
+DLLEXPORT char* format_type_be_qualified(Oid type_oid) {
+	return format_type(type_oid, -1, false, true);
+}
+
+DLLEXPORT char* format_type_with_typemod(Oid type_oid, int32_t typemod) {
+	return format_type(type_oid, typemod, true, false);
+}
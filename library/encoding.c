@@ -0,0 +1,676 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+#include <ctype.h>
+
+// database_encoding is the encoding of the database, which is set by the host.
+static int database_encoding = PG_UTF8;
+
+#define SS2 0x8e
+#define SS3 0x8f
+
+// encoding_info describes an encoding, along with the function that returns the length of its characters.
+typedef struct encoding_info {
+	const char* name;
+	int         max_length;
+	int         (*mblen)(const unsigned char* s);
+} encoding_info;
+
+static int single_mblen(const unsigned char* s) {
+	return 1;
+}
+
+static int utf8_mblen(const unsigned char* s) {
+	if ((*s & 0x80) == 0) {
+		return 1;
+	} else if ((*s & 0xe0) == 0xc0) {
+		return 2;
+	} else if ((*s & 0xf0) == 0xe0) {
+		return 3;
+	} else if ((*s & 0xf8) == 0xf0) {
+		return 4;
+	}
+	return 1;
+}
+
+static int euc_mblen(const unsigned char* s) {
+	if (*s == SS2) {
+		return 2;
+	} else if (*s == SS3) {
+		return 3;
+	}
+	return IS_HIGHBIT_SET(*s) ? 2 : 1;
+}
+
+static int euctw_mblen(const unsigned char* s) {
+	if (*s == SS2) {
+		return 4;
+	} else if (*s == SS3) {
+		return 3;
+	}
+	return IS_HIGHBIT_SET(*s) ? 2 : 1;
+}
+
+static int double_mblen(const unsigned char* s) {
+	return IS_HIGHBIT_SET(*s) ? 2 : 1;
+}
+
+static int mule_mblen(const unsigned char* s) {
+	if (*s >= 0x81 && *s <= 0x8d) {
+		return 2;
+	} else if (*s == 0x9a || *s == 0x9b) {
+		return 3;
+	} else if (*s >= 0x90 && *s <= 0x99) {
+		return 3;
+	} else if (*s == 0x9c || *s == 0x9d) {
+		return 4;
+	}
+	return 1;
+}
+
+static int sjis_mblen(const unsigned char* s) {
+	// Half-width katakana are a single byte
+	if (*s >= 0xa1 && *s <= 0xdf) {
+		return 1;
+	}
+	return IS_HIGHBIT_SET(*s) ? 2 : 1;
+}
+
+static int gb18030_mblen(const unsigned char* s) {
+	if (!IS_HIGHBIT_SET(*s)) {
+		return 1;
+	}
+	return (s[1] >= 0x30 && s[1] <= 0x39) ? 4 : 2;
+}
+
+// encodings is indexed by pg_enc.
+static const encoding_info encodings[_PG_LAST_ENCODING_] = {
+	{"SQL_ASCII", 1, single_mblen},
+	{"EUC_JP", 3, euc_mblen},
+	{"EUC_CN", 2, double_mblen},
+	{"EUC_KR", 3, euc_mblen},
+	{"EUC_TW", 4, euctw_mblen},
+	{"EUC_JIS_2004", 3, euc_mblen},
+	{"UTF8", 4, utf8_mblen},
+	{"MULE_INTERNAL", 4, mule_mblen},
+	{"LATIN1", 1, single_mblen},
+	{"LATIN2", 1, single_mblen},
+	{"LATIN3", 1, single_mblen},
+	{"LATIN4", 1, single_mblen},
+	{"LATIN5", 1, single_mblen},
+	{"LATIN6", 1, single_mblen},
+	{"LATIN7", 1, single_mblen},
+	{"LATIN8", 1, single_mblen},
+	{"LATIN9", 1, single_mblen},
+	{"LATIN10", 1, single_mblen},
+	{"WIN1256", 1, single_mblen},
+	{"WIN1258", 1, single_mblen},
+	{"WIN866", 1, single_mblen},
+	{"WIN874", 1, single_mblen},
+	{"KOI8R", 1, single_mblen},
+	{"WIN1251", 1, single_mblen},
+	{"WIN1252", 1, single_mblen},
+	{"ISO_8859_5", 1, single_mblen},
+	{"ISO_8859_6", 1, single_mblen},
+	{"ISO_8859_7", 1, single_mblen},
+	{"ISO_8859_8", 1, single_mblen},
+	{"WIN1250", 1, single_mblen},
+	{"WIN1253", 1, single_mblen},
+	{"WIN1254", 1, single_mblen},
+	{"WIN1255", 1, single_mblen},
+	{"WIN1257", 1, single_mblen},
+	{"KOI8U", 1, single_mblen},
+	{"SJIS", 2, sjis_mblen},
+	{"BIG5", 2, double_mblen},
+	{"GBK", 2, double_mblen},
+	{"UHC", 2, double_mblen},
+	{"GB18030", 4, gb18030_mblen},
+	{"JOHAB", 3, euc_mblen},
+	{"SHIFT_JIS_2004", 2, sjis_mblen},
+};
+
+// encoding_alias maps another name of an encoding to the encoding. Names are compared after they're cleaned.
+typedef struct encoding_alias {
+	const char* name;
+	int         encoding;
+} encoding_alias;
+
+static const encoding_alias encoding_aliases[] = {
+	{"unicode", PG_UTF8},
+	{"iso88591", PG_LATIN1},
+	{"iso88592", PG_LATIN2},
+	{"iso88593", PG_LATIN3},
+	{"iso88594", PG_LATIN4},
+	{"iso88599", PG_LATIN5},
+	{"iso885910", PG_LATIN6},
+	{"iso885913", PG_LATIN7},
+	{"iso885914", PG_LATIN8},
+	{"iso885915", PG_LATIN9},
+	{"iso885916", PG_LATIN10},
+	{"iso88595", PG_ISO_8859_5},
+	{"iso88596", PG_ISO_8859_6},
+	{"iso88597", PG_ISO_8859_7},
+	{"iso88598", PG_ISO_8859_8},
+	{"koi8", PG_KOI8R},
+	{"alt", PG_WIN866},
+	{"tcvn", PG_WIN1258},
+	{"tcvn5712", PG_WIN1258},
+	{"vscii", PG_WIN1258},
+	{"abc", PG_WIN1258},
+	{"mskanji", PG_SJIS},
+	{"shiftjis", PG_SJIS},
+	{"shiftjis2004", PG_SHIFT_JIS_2004},
+	{"windows932", PG_SJIS},
+	{"windows936", PG_GBK},
+	{"windows949", PG_UHC},
+	{"windows950", PG_BIG5},
+};
+
+// clean_encoding_name lowercases the name and removes everything but letters and digits, writing it to the buffer.
+// Returns false if the name does not fit.
+static bool clean_encoding_name(const char* name, char* buffer, size_t buffer_len) {
+	size_t len = 0;
+	for (const char* p = name; *p != '\0'; p++) {
+		if (isalnum((unsigned char)*p)) {
+			if (len + 1 >= buffer_len) {
+				return false;
+			}
+			buffer[len++] = (char)tolower((unsigned char)*p);
+		}
+	}
+	buffer[len] = '\0';
+	return true;
+}
+
+// pgext_set_database_encoding sets the encoding of the database by its name. Returns 1 if the encoding was set, 0 if
+// the name is not an encoding, or 2 if the encoding may only be used by clients.
+DLLEXPORT uintptr_t pgext_set_database_encoding(const char* name) {
+	int encoding = pg_char_to_encoding(name);
+	if (encoding < 0) {
+		return 0;
+	}
+	if (!PG_VALID_BE_ENCODING(encoding)) {
+		return 2;
+	}
+	database_encoding = encoding;
+	return 1;
+}
+
+DLLEXPORT int GetDatabaseEncoding(void) {
+	return database_encoding;
+}
+
+DLLEXPORT const char* GetDatabaseEncodingName(void) {
+	return encodings[database_encoding].name;
+}
+
+DLLEXPORT int pg_get_client_encoding(void) {
+	return database_encoding;
+}
+
+DLLEXPORT const char* pg_get_client_encoding_name(void) {
+	return encodings[database_encoding].name;
+}
+
+DLLEXPORT int pg_database_encoding_max_length(void) {
+	return encodings[database_encoding].max_length;
+}
+
+DLLEXPORT const char* pg_encoding_to_char(int encoding) {
+	if (!PG_VALID_ENCODING(encoding)) {
+		return "";
+	}
+	return encodings[encoding].name;
+}
+
+DLLEXPORT int pg_char_to_encoding(const char* name) {
+	char cleaned[32];
+	char candidate[32];
+	if (name == NULL || !clean_encoding_name(name, cleaned, sizeof(cleaned))) {
+		return -1;
+	}
+	for (int i = 0; i < _PG_LAST_ENCODING_; i++) {
+		clean_encoding_name(encodings[i].name, candidate, sizeof(candidate));
+		if (strcmp(cleaned, candidate) == 0) {
+			return i;
+		}
+	}
+	// Windows code pages may be written with their full name, such as windows1252 for WIN1252
+	if (strncmp(cleaned, "windows", 7) == 0) {
+		snprintf(candidate, sizeof(candidate), "win%s", cleaned + 7);
+		for (int i = 0; i <= PG_ENCODING_BE_LAST; i++) {
+			char encoding_name[32];
+			clean_encoding_name(encodings[i].name, encoding_name, sizeof(encoding_name));
+			if (strcmp(candidate, encoding_name) == 0) {
+				return i;
+			}
+		}
+	}
+	for (size_t i = 0; i < sizeof(encoding_aliases) / sizeof(encoding_aliases[0]); i++) {
+		if (strcmp(cleaned, encoding_aliases[i].name) == 0) {
+			return encoding_aliases[i].encoding;
+		}
+	}
+	return -1;
+}
+
+DLLEXPORT bool pg_valid_server_encoding_id(int encoding) {
+	return PG_VALID_BE_ENCODING(encoding);
+}
+
+DLLEXPORT int pg_encoding_max_length(int encoding) {
+	return PG_VALID_ENCODING(encoding) ? encodings[encoding].max_length : encodings[PG_SQL_ASCII].max_length;
+}
+
+DLLEXPORT int pg_encoding_mblen(int encoding, const char* mbstr) {
+	const encoding_info* info = PG_VALID_ENCODING(encoding) ? &encodings[encoding] : &encodings[PG_SQL_ASCII];
+	return info->mblen((const unsigned char*)mbstr);
+}
+
+DLLEXPORT int pg_mblen(const char* mbstr) {
+	return pg_encoding_mblen(database_encoding, mbstr);
+}
+
+DLLEXPORT int pg_mbstrlen(const char* mbstr) {
+	int len = 0;
+	// Single-byte encodings have one character per byte
+	if (encodings[database_encoding].max_length == 1) {
+		return (int)strlen(mbstr);
+	}
+	while (*mbstr) {
+		mbstr += pg_mblen(mbstr);
+		len++;
+	}
+	return len;
+}
+
+DLLEXPORT int pg_mbstrlen_with_len(const char* mbstr, int limit) {
+	int len = 0;
+	if (encodings[database_encoding].max_length == 1) {
+		return limit;
+	}
+	while (limit > 0 && *mbstr) {
+		int l = pg_mblen(mbstr);
+		limit -= l;
+		mbstr += l;
+		len++;
+	}
+	return len;
+}
+
+DLLEXPORT int pg_encoding_mbcliplen(int encoding, const char* mbstr, int len, int limit) {
+	int clen = 0;
+	if (pg_encoding_max_length(encoding) == 1) {
+		// Single-byte strings are clipped at the limit, or at a NUL byte if one comes first
+		if (len > limit) {
+			len = limit;
+		}
+		while (clen < len && mbstr[clen]) {
+			clen++;
+		}
+		return clen;
+	}
+	while (len > 0 && *mbstr) {
+		int l = pg_encoding_mblen(encoding, mbstr);
+		if (clen + l > limit) {
+			break;
+		}
+		clen += l;
+		if (clen == limit) {
+			break;
+		}
+		len -= l;
+		mbstr += l;
+	}
+	return clen;
+}
+
+DLLEXPORT int pg_mbcliplen(const char* mbstr, int len, int limit) {
+	return pg_encoding_mbcliplen(database_encoding, mbstr, len, limit);
+}
+
+// utf8_islegal returns whether the bytes are a valid UTF-8 sequence of the given length.
+static bool utf8_islegal(const unsigned char* source, int length) {
+	unsigned char a;
+	switch (length) {
+	default:
+		return false;
+	case 4:
+		a = source[3];
+		if (a < 0x80 || a > 0xBF) {
+			return false;
+		}
+		// fall through
+	case 3:
+		a = source[2];
+		if (a < 0x80 || a > 0xBF) {
+			return false;
+		}
+		// fall through
+	case 2:
+		// The second byte excludes overlong forms, surrogates, and code points beyond U+10FFFF
+		a = source[1];
+		switch (*source) {
+		case 0xE0:
+			if (a < 0xA0 || a > 0xBF) {
+				return false;
+			}
+			break;
+		case 0xED:
+			if (a < 0x80 || a > 0x9F) {
+				return false;
+			}
+			break;
+		case 0xF0:
+			if (a < 0x90 || a > 0xBF) {
+				return false;
+			}
+			break;
+		case 0xF4:
+			if (a < 0x80 || a > 0x8F) {
+				return false;
+			}
+			break;
+		default:
+			if (a < 0x80 || a > 0xBF) {
+				return false;
+			}
+			break;
+		}
+		// fall through
+	case 1:
+		a = *source;
+		if (a >= 0x80 && a < 0xC2) {
+			return false;
+		}
+		if (a > 0xF4) {
+			return false;
+		}
+		break;
+	}
+	return true;
+}
+
+// verify_char returns the length of the character at the start of the string, which begins with a byte that has its
+// high bit set, or -1 if the character is not valid within the encoding. UTF8 is fully validated, while characters of
+// the other multibyte encodings are only checked for their length and for NUL bytes.
+static int verify_char(int encoding, const unsigned char* s, int len) {
+	int l = pg_encoding_mblen(encoding, (const char*)s);
+	if (len < l) {
+		return -1;
+	}
+	if (encoding == PG_UTF8) {
+		return utf8_islegal(s, l) ? l : -1;
+	}
+	for (int i = 1; i < l; i++) {
+		if (s[i] == '\0') {
+			return -1;
+		}
+	}
+	return l;
+}
+
+DLLEXPORT int pg_verify_mbstr_len(int encoding, const char* mbstr, int len, bool noError) {
+	const unsigned char* s = (const unsigned char*)mbstr;
+	int mb_len = 0;
+	bool single_byte = pg_encoding_max_length(encoding) == 1;
+	while (len > 0) {
+		// Every encoding may contain ASCII, except for NUL, which is never valid
+		if (!IS_HIGHBIT_SET(*s) || single_byte) {
+			if (*s != '\0') {
+				mb_len++;
+				s++;
+				len--;
+				continue;
+			}
+			if (noError) {
+				return -1;
+			}
+			report_invalid_encoding(encoding, (const char*)s, len);
+			return -1;
+		}
+		int l = verify_char(encoding, s, len);
+		if (l < 0) {
+			if (noError) {
+				return -1;
+			}
+			report_invalid_encoding(encoding, (const char*)s, len);
+			return -1;
+		}
+		s += l;
+		len -= l;
+		mb_len++;
+	}
+	return mb_len;
+}
+
+DLLEXPORT bool pg_verify_mbstr(int encoding, const char* mbstr, int len, bool noError) {
+	return pg_verify_mbstr_len(encoding, mbstr, len, noError) >= 0;
+}
+
+DLLEXPORT bool pg_verifymbstr(const char* mbstr, int len, bool noError) {
+	return pg_verify_mbstr(database_encoding, mbstr, len, noError);
+}
+
+// format_bytes writes the bytes of the character at the start of the string to the buffer, in the form that Postgres
+// uses for encoding errors, such as "0xc3 0x28".
+static void format_bytes(int encoding, const char* mbstr, int len, char* buf, size_t buf_len) {
+	int l = pg_encoding_mblen(encoding, mbstr);
+	if (l > len) {
+		l = len;
+	}
+	char* p = buf;
+	buf[0] = '\0';
+	for (int i = 0; i < l; i++) {
+		int written = snprintf(p, buf_len - (p - buf), i == 0 ? "0x%02x" : " 0x%02x", (unsigned char)mbstr[i]);
+		if (written < 0 || (size_t)written >= buf_len - (p - buf)) {
+			break;
+		}
+		p += written;
+	}
+}
+
+DLLEXPORT void report_invalid_encoding(int encoding, const char* mbstr, int len) {
+	char buf[8 * 5 + 1];
+	format_bytes(encoding, mbstr, len, buf, sizeof(buf));
+	pgext_raise_error(ERROR, ERRCODE_CHARACTER_NOT_IN_REPERTOIRE, "invalid byte sequence for encoding \"%s\": %s",
+		pg_encoding_to_char(encoding), buf);
+}
+
+DLLEXPORT void report_untranslatable_char(int src_encoding, int dest_encoding, const char* mbstr, int len) {
+	char buf[8 * 5 + 1];
+	format_bytes(src_encoding, mbstr, len, buf, sizeof(buf));
+	pgext_raise_error(ERROR, ERRCODE_UNTRANSLATABLE_CHARACTER,
+		"character with byte sequence %s in encoding \"%s\" has no equivalent in encoding \"%s\"", buf,
+		pg_encoding_to_char(src_encoding), pg_encoding_to_char(dest_encoding));
+}
+
+// win1252_high maps the bytes 0x80 through 0x9F of WIN1252 to their code points, where zero is a byte without a
+// character. The other bytes are the same as LATIN1.
+static const uint16_t win1252_high[32] = {
+	0x20AC, 0, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021, 0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0, 0x017D, 0,
+	0, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014, 0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0, 0x017E, 0x0178,
+};
+
+// single_byte_to_code_point returns the code point of the byte within the encoding, or -1 if it has none.
+static int32_t single_byte_to_code_point(int encoding, unsigned char c) {
+	if (encoding == PG_WIN1252 && c >= 0x80 && c <= 0x9F) {
+		return win1252_high[c - 0x80] != 0 ? win1252_high[c - 0x80] : -1;
+	}
+	return c;
+}
+
+// code_point_to_single_byte returns the byte of the code point within the encoding, or -1 if it has none.
+static int code_point_to_single_byte(int encoding, uint32_t code_point) {
+	if (encoding == PG_WIN1252) {
+		if (code_point >= 0x80 && code_point <= 0x9F) {
+			return -1;
+		}
+		for (int i = 0; i < 32; i++) {
+			if (win1252_high[i] != 0 && win1252_high[i] == code_point) {
+				return 0x80 + i;
+			}
+		}
+	}
+	return code_point <= 0xFF ? (int)code_point : -1;
+}
+
+// convert_single_byte_to_utf8 converts a string from LATIN1 or WIN1252, writing a terminated string to dest.
+static void convert_single_byte_to_utf8(int encoding, const unsigned char* src, int len, unsigned char* dest) {
+	for (int i = 0; i < len; i++) {
+		if (src[i] == '\0') {
+			report_invalid_encoding(encoding, (const char*)&src[i], len - i);
+			break;
+		}
+		int32_t code_point = single_byte_to_code_point(encoding, src[i]);
+		if (code_point < 0) {
+			report_untranslatable_char(encoding, PG_UTF8, (const char*)&src[i], len - i);
+			break;
+		}
+		if (code_point < 0x80) {
+			*dest++ = (unsigned char)code_point;
+		} else if (code_point < 0x800) {
+			*dest++ = (unsigned char)(0xC0 | (code_point >> 6));
+			*dest++ = (unsigned char)(0x80 | (code_point & 0x3F));
+		} else {
+			*dest++ = (unsigned char)(0xE0 | (code_point >> 12));
+			*dest++ = (unsigned char)(0x80 | ((code_point >> 6) & 0x3F));
+			*dest++ = (unsigned char)(0x80 | (code_point & 0x3F));
+		}
+	}
+	*dest = '\0';
+}
+
+// convert_utf8_to_single_byte converts a string to LATIN1 or WIN1252, writing a terminated string to dest.
+static void convert_utf8_to_single_byte(int encoding, const unsigned char* src, int len, unsigned char* dest) {
+	while (len > 0) {
+		if (*src == '\0') {
+			report_invalid_encoding(PG_UTF8, (const char*)src, len);
+			break;
+		}
+		int l = utf8_mblen(src);
+		if (l > len || !utf8_islegal(src, l)) {
+			report_invalid_encoding(PG_UTF8, (const char*)src, len);
+			break;
+		}
+		uint32_t code_point;
+		switch (l) {
+		case 1:
+			code_point = src[0];
+			break;
+		case 2:
+			code_point = ((uint32_t)(src[0] & 0x1F) << 6) | (src[1] & 0x3F);
+			break;
+		case 3:
+			code_point = ((uint32_t)(src[0] & 0x0F) << 12) | ((uint32_t)(src[1] & 0x3F) << 6) | (src[2] & 0x3F);
+			break;
+		default:
+			code_point = ((uint32_t)(src[0] & 0x07) << 18) | ((uint32_t)(src[1] & 0x3F) << 12) |
+				((uint32_t)(src[2] & 0x3F) << 6) | (src[3] & 0x3F);
+			break;
+		}
+		int c = code_point_to_single_byte(encoding, code_point);
+		if (c < 0) {
+			report_untranslatable_char(PG_UTF8, encoding, (const char*)src, len);
+			break;
+		}
+		*dest++ = (unsigned char)c;
+		src += l;
+		len -= l;
+	}
+	*dest = '\0';
+}
+
+// is_converted_single_byte returns whether the shim converts between the encoding and UTF8.
+static bool is_converted_single_byte(int encoding) {
+	return encoding == PG_LATIN1 || encoding == PG_WIN1252;
+}
+
+DLLEXPORT unsigned char* pg_do_encoding_conversion(unsigned char* src, int len, int src_encoding, int dest_encoding) {
+	if (len <= 0 || src_encoding == dest_encoding || dest_encoding == PG_SQL_ASCII) {
+		return src;
+	}
+	// Strings in SQL_ASCII may hold any bytes, so they're only checked against the encoding that they're given as
+	if (src_encoding == PG_SQL_ASCII) {
+		pg_verify_mbstr(dest_encoding, (const char*)src, len, false);
+		return src;
+	}
+	if (src_encoding == PG_UTF8 && is_converted_single_byte(dest_encoding)) {
+		unsigned char* result = (unsigned char*)palloc((size_t)len + 1);
+		if (result != NULL) {
+			convert_utf8_to_single_byte(dest_encoding, src, len, result);
+		}
+		return result;
+	}
+	if (is_converted_single_byte(src_encoding) && dest_encoding == PG_UTF8) {
+		unsigned char* result = (unsigned char*)palloc((size_t)len * 3 + 1);
+		if (result != NULL) {
+			convert_single_byte_to_utf8(src_encoding, src, len, result);
+		}
+		return result;
+	}
+	pgext_raise_error(ERROR, ERRCODE_UNDEFINED_FUNCTION,
+		"default conversion function for encoding \"%s\" to \"%s\" does not exist",
+		pg_encoding_to_char(src_encoding), pg_encoding_to_char(dest_encoding));
+	return src;
+}
+
+DLLEXPORT char* pg_server_to_any(const char* s, int len, int encoding) {
+	if (len <= 0 || encoding == database_encoding || encoding == PG_SQL_ASCII) {
+		return (char*)s;
+	}
+	if (database_encoding == PG_SQL_ASCII) {
+		pg_verify_mbstr(encoding, s, len, false);
+		return (char*)s;
+	}
+	return (char*)pg_do_encoding_conversion((unsigned char*)s, len, database_encoding, encoding);
+}
+
+DLLEXPORT char* pg_any_to_server(const char* s, int len, int encoding) {
+	if (len <= 0) {
+		return (char*)s;
+	}
+	if (encoding == database_encoding || encoding == PG_SQL_ASCII) {
+		pg_verify_mbstr(database_encoding, s, len, false);
+		return (char*)s;
+	}
+	if (database_encoding == PG_SQL_ASCII) {
+		// Nothing is converted into SQL_ASCII, so client-only encodings, whose bytes may look like ASCII within a
+		// multibyte character, are limited to ASCII
+		if (PG_VALID_BE_ENCODING(encoding)) {
+			pg_verify_mbstr(encoding, s, len, false);
+			return (char*)s;
+		}
+		for (int i = 0; i < len; i++) {
+			if (s[i] == '\0' || IS_HIGHBIT_SET(s[i])) {
+				pgext_raise_error(ERROR, ERRCODE_CHARACTER_NOT_IN_REPERTOIRE,
+					"invalid byte value for encoding \"%s\": 0x%02x", pg_encoding_to_char(PG_SQL_ASCII),
+					(unsigned char)s[i]);
+				break;
+			}
+		}
+		return (char*)s;
+	}
+	return (char*)pg_do_encoding_conversion((unsigned char*)s, len, encoding, database_encoding);
+}
+
+DLLEXPORT char* pg_server_to_client(const char* s, int len) {
+	return pg_server_to_any(s, len, pg_get_client_encoding());
+}
+
+DLLEXPORT char* pg_client_to_server(const char* s, int len) {
+	return pg_any_to_server(s, len, pg_get_client_encoding());
+}
@@ -12,46 +12,326 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-#include <stdarg.h>
-#include <stdio.h>
-#include <stdbool.h>
+#include "exports.h"
 
-#if defined(_WIN32) || defined(_WIN64)
-#define DLLEXPORT __declspec(dllexport)
-#else
-#define DLLEXPORT __attribute__((visibility("default")))
-#endif
+// These are assigned directly by extensions within PG_TRY and when adding error context callbacks. They're swapped with
+// the values of each session's state whenever a state is bound to a thread.
+DLLEXPORT sigjmp_buf* PG_exception_stack = NULL;
+DLLEXPORT ErrorContextCallback* error_context_stack = NULL;
 
-static char last_error[512];
+// logger is the host's logger, or NULL if messages are written to stderr. Messages below logger_min_level are skipped
+// before they're formatted, which is LOG without a logger.
+static PgExtLogger* logger;
+static int logger_min_level = LOG;
+
+// pgext_set_logger sets the host's logger, along with the lowest level of the messages that it receives. Setting NULL
+// writes messages of LOG and above to stderr.
+DLLEXPORT uintptr_t pgext_set_logger(PgExtLogger* new_logger, uintptr_t min_level) {
+	logger_min_level = new_logger != NULL ? (int)min_level : LOG;
+	logger = new_logger;
+	return 0;
+}
+
+// current_error returns the report that is currently being built, or NULL if there is none.
+static PgExtErrorData* current_error(void) {
+	PgExtBackendState* state = pgext_backend_state();
+	if (state->errordata_depth == 0) {
+		return NULL;
+	}
+	return &state->errordata[state->errordata_depth - 1];
+}
+
+// elevel_name returns the name of the given error level, as it's displayed in messages.
+static const char* elevel_name(int elevel) {
+	if (elevel >= PANIC) {
+		return "PANIC";
+	} else if (elevel >= FATAL) {
+		return "FATAL";
+	} else if (elevel >= ERROR) {
+		return "ERROR";
+	} else if (elevel >= WARNING) {
+		return "WARNING";
+	} else if (elevel >= NOTICE) {
+		return "NOTICE";
+	} else if (elevel >= INFO) {
+		return "INFO";
+	}
+	return "LOG";
+}
+
+// append_message appends the formatted message to the given buffer, separating it from any existing contents with a
+// newline.
+static void append_message(char* buffer, size_t size, const char* fmt, va_list ap) {
+	size_t len = strlen(buffer);
+	if (len > 0 && len + 1 < size) {
+		buffer[len++] = '\n';
+		buffer[len] = '\0';
+	}
+	if (len < size) {
+		vsnprintf(buffer + len, size - len, fmt, ap);
+	}
+}
 
 DLLEXPORT bool errstart(int elevel, const char* domain) {
-	last_error[0] = '\0';
-	return 1;
+	// Messages below the minimum level are never emitted, so we tell the caller to skip the report entirely
+	if (elevel < ERROR && elevel < logger_min_level) {
+		return false;
+	}
+	PgExtBackendState* state = pgext_backend_state();
+	if (state->errordata_depth >= ERRORDATA_STACK_SIZE) {
+		// Postgres treats this as a PANIC, but we'd rather replace the innermost report than take down the process
+		state->errordata_depth = ERRORDATA_STACK_SIZE - 1;
+	}
+	PgExtErrorData* edata = &state->errordata[state->errordata_depth++];
+	memset(edata, 0, sizeof(PgExtErrorData));
+	edata->elevel = elevel;
+	edata->domain = domain;
+	edata->context_domain = domain;
+	if (elevel >= ERROR) {
+		edata->sqlerrcode = ERRCODE_INTERNAL_ERROR;
+	} else if (elevel >= WARNING) {
+		edata->sqlerrcode = ERRCODE_WARNING;
+	} else {
+		edata->sqlerrcode = ERRCODE_SUCCESSFUL_COMPLETION;
+	}
+	return true;
 }
 
-DLLEXPORT bool errstart_cold(int elevel, const char *domain) {
+DLLEXPORT bool errstart_cold(int elevel, const char* domain) {
 	return errstart(elevel, domain);
 }
 
-DLLEXPORT int errmsg(const char *fmt, ...) {
+DLLEXPORT void errfinish(const char* filename, int lineno, const char* funcname) {
+	PgExtBackendState* state = pgext_backend_state();
+	PgExtErrorData* edata = current_error();
+	if (edata == NULL) {
+		return;
+	}
+	edata->filename = filename;
+	edata->lineno = lineno;
+	edata->funcname = funcname;
+	// Context callbacks add their context to the report through errcontext
+	for (ErrorContextCallback* econtext = error_context_stack; econtext != NULL; econtext = econtext->previous) {
+		econtext->callback(econtext->arg);
+	}
+	int elevel = edata->elevel;
+	if (elevel < ERROR) {
+		PgExtLogger* current_logger = logger;
+		if (current_logger != NULL) {
+			current_logger->log(edata, pgext_session_pid(state));
+		} else {
+			fprintf(stderr, "Postgres %s: %s\n", elevel_name(elevel), edata->message);
+		}
+		state->errordata_depth--;
+		return;
+	}
+	// Interrupts that were held off are allowed again, the same as Postgres does before unwinding
+	InterruptHoldoffCount = 0;
+	QueryCancelHoldoffCount = 0;
+	CritSectionCount = 0;
+	// The report is moved out of the stack, as the stack is unwound along with the call
+	memcpy(&state->caught, edata, sizeof(PgExtErrorData));
+	state->errordata_depth = 0;
+	if (PG_exception_stack != NULL) {
+		siglongjmp(*PG_exception_stack, 1);
+	}
+	// Errors that are raised outside of a call come from the host calling into the shim directly, so the error is kept
+	// until the host takes it once the shim function returns
+	state->uncaught = true;
+}
+
+DLLEXPORT int errcode(int sqlerrcode) {
+	PgExtErrorData* edata = current_error();
+	if (edata != NULL) {
+		edata->sqlerrcode = sqlerrcode;
+	}
+	return 0;
+}
+
+DLLEXPORT int errmsg(const char* fmt, ...) {
+	PgExtErrorData* edata = current_error();
+	if (edata == NULL) {
+		return 0;
+	}
+	va_list ap;
+	va_start(ap, fmt);
+	vsnprintf(edata->message, sizeof(edata->message), pgext_translate(edata->domain, fmt), ap);
+	va_end(ap);
+	return 0;
+}
+
+DLLEXPORT int errmsg_internal(const char* fmt, ...) {
+	PgExtErrorData* edata = current_error();
+	if (edata == NULL) {
+		return 0;
+	}
+	va_list ap;
+	va_start(ap, fmt);
+	vsnprintf(edata->message, sizeof(edata->message), fmt, ap);
+	va_end(ap);
+	return 0;
+}
+
+DLLEXPORT int errmsg_plural(const char* fmt_singular, const char* fmt_plural, unsigned long n, ...) {
+	PgExtErrorData* edata = current_error();
+	if (edata == NULL) {
+		return 0;
+	}
+	va_list ap;
+	va_start(ap, n);
+	vsnprintf(edata->message, sizeof(edata->message),
+		pgext_translate_plural(edata->domain, fmt_singular, fmt_plural, n), ap);
+	va_end(ap);
+	return 0;
+}
+
+DLLEXPORT int errdetail(const char* fmt, ...) {
+	PgExtErrorData* edata = current_error();
+	if (edata == NULL) {
+		return 0;
+	}
 	va_list ap;
 	va_start(ap, fmt);
-	vsnprintf(last_error, sizeof(last_error), fmt, ap);
+	vsnprintf(edata->detail, sizeof(edata->detail), pgext_translate(edata->domain, fmt), ap);
 	va_end(ap);
 	return 0;
 }
 
-DLLEXPORT int errmsg_internal(const char *fmt, ...) {
+DLLEXPORT int errdetail_internal(const char* fmt, ...) {
+	PgExtErrorData* edata = current_error();
+	if (edata == NULL) {
+		return 0;
+	}
 	va_list ap;
 	va_start(ap, fmt);
-	vsnprintf(last_error, sizeof(last_error), fmt, ap);
+	vsnprintf(edata->detail, sizeof(edata->detail), fmt, ap);
 	va_end(ap);
 	return 0;
 }
 
-DLLEXPORT int errfinish(int dummy, ...) {
-	if (last_error[0]) {
-		fprintf(stderr, "Postgres ERROR: %s\n", last_error);
+DLLEXPORT int errdetail_log(const char* fmt, ...) {
+	PgExtErrorData* edata = current_error();
+	if (edata == NULL) {
+		return 0;
 	}
+	va_list ap;
+	va_start(ap, fmt);
+	vsnprintf(edata->detail, sizeof(edata->detail), pgext_translate(edata->domain, fmt), ap);
+	va_end(ap);
 	return 0;
 }
+
+DLLEXPORT int errdetail_plural(const char* fmt_singular, const char* fmt_plural, unsigned long n, ...) {
+	PgExtErrorData* edata = current_error();
+	if (edata == NULL) {
+		return 0;
+	}
+	va_list ap;
+	va_start(ap, n);
+	vsnprintf(edata->detail, sizeof(edata->detail),
+		pgext_translate_plural(edata->domain, fmt_singular, fmt_plural, n), ap);
+	va_end(ap);
+	return 0;
+}
+
+DLLEXPORT int errhint(const char* fmt, ...) {
+	PgExtErrorData* edata = current_error();
+	if (edata == NULL) {
+		return 0;
+	}
+	va_list ap;
+	va_start(ap, fmt);
+	vsnprintf(edata->hint, sizeof(edata->hint), pgext_translate(edata->domain, fmt), ap);
+	va_end(ap);
+	return 0;
+}
+
+DLLEXPORT int set_errcontext_domain(const char* domain) {
+	PgExtErrorData* edata = current_error();
+	if (edata != NULL) {
+		edata->context_domain = domain;
+	}
+	return 0;
+}
+
+DLLEXPORT int errcontext_msg(const char* fmt, ...) {
+	PgExtErrorData* edata = current_error();
+	if (edata == NULL) {
+		return 0;
+	}
+	va_list ap;
+	va_start(ap, fmt);
+	append_message(edata->context, sizeof(edata->context), pgext_translate(edata->context_domain, fmt), ap);
+	va_end(ap);
+	return 0;
+}
+
+DLLEXPORT int errposition(int cursorpos) {
+	return 0;
+}
+
+DLLEXPORT int errhidestmt(bool hide_stmt) {
+	return 0;
+}
+
+DLLEXPORT int errhidecontext(bool hide_ctx) {
+	return 0;
+}
+
+DLLEXPORT int geterrcode(void) {
+	PgExtErrorData* edata = current_error();
+	if (edata == NULL) {
+		return pgext_backend_state()->caught.sqlerrcode;
+	}
+	return edata->sqlerrcode;
+}
+
+DLLEXPORT void pg_re_throw(void) {
+	if (PG_exception_stack != NULL) {
+		siglongjmp(*PG_exception_stack, 1);
+	}
+	pgext_backend_state()->uncaught = true;
+}
+
+DLLEXPORT void FlushErrorState(void) {
+	PgExtBackendState* state = pgext_backend_state();
+	state->errordata_depth = 0;
+	memset(&state->caught, 0, sizeof(PgExtErrorData));
+	state->uncaught = false;
+}
+
+// pgext_take_error returns the error that was raised outside of any call since the last time that it was taken, or
+// NULL if there is no such error. The host checks this after each call that it makes into the shim directly, on the
+// same thread, as the error belongs to the session that is bound to the thread.
+DLLEXPORT PgExtErrorData* pgext_take_error(void) {
+	PgExtBackendState* state = pgext_backend_state();
+	if (!state->uncaught) {
+		return NULL;
+	}
+	state->uncaught = false;
+	return &state->caught;
+}
+
+// pgext_raise_error raises an error from within the shim itself, such as when an allocation fails. This only returns
+// when there is no call to unwind, which is the case when the host calls into the shim directly.
+void pgext_raise_error(int elevel, int sqlerrcode, const char* fmt, ...) {
+	if (!errstart(elevel, NULL)) {
+		return;
+	}
+	PgExtErrorData* edata = current_error();
+	edata->sqlerrcode = sqlerrcode;
+	va_list ap;
+	va_start(ap, fmt);
+	vsnprintf(edata->message, sizeof(edata->message), fmt, ap);
+	va_end(ap);
+	errfinish(__FILE__, __LINE__, NULL);
+}
+
+// pgext_raise_host_error raises an error that was returned by a callback of the host, whose message was allocated by the
+// host with malloc. The message is freed before raising, as raising does not return.
+void pgext_raise_host_error(int sqlerrcode, char* message) {
+	char copy[1024];
+	snprintf(copy, sizeof(copy), "%s", message);
+	free(message);
+	pgext_raise_error(ERROR, sqlerrcode != 0 ? sqlerrcode : ERRCODE_INTERNAL_ERROR, "%s", copy);
+}
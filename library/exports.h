@@ -21,11 +21,32 @@
 #include <stdarg.h>
 #include <stdio.h>
 #include <stdbool.h>
+#include <stddef.h>
+#include <setjmp.h>
+#include <signal.h>
+#include <sys/types.h>
+
+#if defined(_WIN32) || defined(_WIN64)
+#define DLLEXPORT __declspec(dllexport)
+#else
+#define DLLEXPORT __attribute__((visibility("default")))
+#endif
+
+// Windows does not have sigsetjmp, so Postgres uses setjmp instead
+#if defined(_WIN32) || defined(_WIN64)
+#define sigjmp_buf jmp_buf
+#define sigsetjmp(x, y) setjmp(x)
+#define siglongjmp longjmp
+#endif
 
 // This doesn't compile unless it has a value, but Postgres defines this as an empty value intentionally
 #define FLEXIBLE_ARRAY_MEMBER 8
 
 typedef uintptr_t Datum;
+typedef uint32_t Oid;
+#define InvalidOid ((Oid)0)
+#define OidIsValid(objectId) ((bool)((objectId) != InvalidOid))
+#define NAMEDATALEN 64
 typedef struct FunctionCallInfoBaseData* FunctionCallInfo;
 typedef Datum (*PGFunction) (FunctionCallInfo fcinfo);
 
@@ -56,12 +77,2212 @@ typedef struct FunctionCallInfoBaseData {
 	NullableDatum args[FLEXIBLE_ARRAY_MEMBER];
 } FunctionCallInfoBaseData;
 
+#define SizeForFunctionCallInfo(nargs) (offsetof(FunctionCallInfoBaseData, args) + sizeof(NullableDatum) * (nargs))
+
+#define InitFunctionCallInfoData(Fcinfo, Flinfo, Nargs, Collation, Context, Resultinfo) \
+	do { \
+		(Fcinfo).flinfo = (Flinfo); \
+		(Fcinfo).context = (Context); \
+		(Fcinfo).resultinfo = (Resultinfo); \
+		(Fcinfo).fncollation = (Collation); \
+		(Fcinfo).isnull = false; \
+		(Fcinfo).nargs = (Nargs); \
+	} while (0)
+
+#define FunctionCallInvoke(fcinfo) (((PGFunction)(fcinfo)->flinfo->fn_addr)(fcinfo))
+
+// LOCAL_FCINFO declares call info on the stack with room for the given number of arguments.
+#define LOCAL_FCINFO(name, nargs) \
+	union { \
+		FunctionCallInfoBaseData fcinfo; \
+		char fcinfo_data[SizeForFunctionCallInfo(nargs)]; \
+	} name##data; \
+	FunctionCallInfo name = &name##data.fcinfo
+
 enum {
 	SZ_FMGRINFO = sizeof(FmgrInfo),
 	SZ_FCINFO   = sizeof(FunctionCallInfoBaseData)
 };
 
+// MemoryContextCallback is registered by extensions to be called when a memory context is reset or deleted.
+typedef struct MemoryContextCallback {
+	void                          (*func)(void* arg);
+	void*                         arg;
+	struct MemoryContextCallback* next;
+} MemoryContextCallback;
+
+// PgExtChunk is the header that precedes every allocation within a memory context. The context must be the last field,
+// as extensions that were built against older Postgres headers find a chunk's context immediately before the chunk.
+typedef struct PgExtChunk {
+	struct PgExtChunk*        prev;
+	struct PgExtChunk*        next;
+	size_t                    size;
+	struct MemoryContextData* context;
+} PgExtChunk;
+
+// MemoryContextData is our own implementation of a memory context, which is opaque to extensions. Every allocation is
+// tracked by its context, so that deleting or resetting a context frees all of its allocations.
+typedef struct MemoryContextData {
+	int                       type;
+	struct MemoryContextData* parent;
+	struct MemoryContextData* firstchild;
+	struct MemoryContextData* prevchild;
+	struct MemoryContextData* nextchild;
+	const char*               name;
+	const char*               ident;
+	MemoryContextCallback*    reset_cbs;
+	PgExtChunk*               chunks;
+	size_t                    mem_allocated;
+} MemoryContextData;
+
+typedef MemoryContextData* MemoryContext;
+
+#define MaxAllocSize       ((size_t)0x3fffffff)
+#define MCXT_ALLOC_HUGE    0x01
+#define MCXT_ALLOC_NO_OOM  0x02
+#define MCXT_ALLOC_ZERO    0x04
+
+extern DLLEXPORT MemoryContext TopMemoryContext;
+extern DLLEXPORT MemoryContext CurrentMemoryContext;
+
+MemoryContext AllocSetContextCreateInternal(MemoryContext parent, const char* name, size_t minContextSize,
+	size_t initBlockSize, size_t maxBlockSize);
+void MemoryContextSetParent(MemoryContext context, MemoryContext new_parent);
+void MemoryContextReset(MemoryContext context);
+void MemoryContextDelete(MemoryContext context);
+MemoryContext MemoryContextSwitchTo(MemoryContext context);
+void* MemoryContextAlloc(MemoryContext context, size_t size);
+void* MemoryContextAllocZero(MemoryContext context, size_t size);
+void* MemoryContextAllocExtended(MemoryContext context, size_t size, int flags);
+void MemoryContextRegisterResetCallback(MemoryContext context, MemoryContextCallback* cb);
+MemoryContext GetMemoryChunkContext(void* pointer);
+MemoryContext pgext_current_context(void);
+void* palloc(size_t size);
+void* palloc0(size_t size);
+void* palloc_extended(size_t size, int flags);
+void pfree(void* pointer);
+void* repalloc(void* pointer, size_t size);
+char* MemoryContextStrdup(MemoryContext context, const char* string);
+char* pstrdup(const char* in);
+char* pnstrdup(const char* in, size_t len);
+char* pchomp(const char* in);
+size_t pvsnprintf(char* buf, size_t len, const char* fmt, va_list args);
+char* psprintf(const char* fmt, ...);
+
+// varlena is the header of all variable-length types. The header is either 4 bytes, or a single byte for short values
+// that have been packed. These macros assume a little-endian machine.
+typedef struct varlena {
+	char vl_len_[4];
+	char vl_dat[FLEXIBLE_ARRAY_MEMBER];
+} varlena;
+
+typedef varlena text;
+typedef varlena bytea;
+
+#define VARHDRSZ                ((int32_t)sizeof(int32_t))
+#define VARHDRSZ_SHORT          1
+#define VARATT_IS_4B_U(PTR)     ((((const uint8_t*)(PTR))[0] & 0x03) == 0x00)
+#define VARATT_IS_4B_C(PTR)     ((((const uint8_t*)(PTR))[0] & 0x03) == 0x02)
+#define VARATT_IS_1B(PTR)       ((((const uint8_t*)(PTR))[0] & 0x01) == 0x01)
+#define VARATT_IS_1B_E(PTR)     (((const uint8_t*)(PTR))[0] == 0x01)
+#define VARSIZE_4B(PTR)         ((*(const uint32_t*)(PTR) >> 2) & 0x3FFFFFFF)
+#define VARSIZE_1B(PTR)         ((((const uint8_t*)(PTR))[0] >> 1) & 0x7F)
+#define SET_VARSIZE(PTR, len)   (*(uint32_t*)(PTR) = (((uint32_t)(len)) << 2))
+#define VARSIZE_ANY(PTR) \
+	(VARATT_IS_1B_E(PTR) ? VARSIZE_EXTERNAL(PTR) : (VARATT_IS_1B(PTR) ? VARSIZE_1B(PTR) : VARSIZE_4B(PTR)))
+#define VARSIZE_ANY_EXHDR(PTR)  (VARATT_IS_1B(PTR) ? VARSIZE_1B(PTR) - VARHDRSZ_SHORT : VARSIZE_4B(PTR) - VARHDRSZ)
+#define VARDATA_ANY(PTR)        (VARATT_IS_1B(PTR) ? ((char*)(PTR)) + VARHDRSZ_SHORT : ((char*)(PTR)) + VARHDRSZ)
+
+// TOAST moves large values out of line, or compresses them, which is marked within the varlena header. Such values are
+// only valid once they have been detoasted, which is done by the PG_GETARG macros of the types that may be toasted.
+#define VARATT_IS_COMPRESSED(PTR)    VARATT_IS_4B_C(PTR)
+#define VARATT_IS_EXTERNAL(PTR)      VARATT_IS_1B_E(PTR)
+#define VARATT_IS_EXTENDED(PTR)      (!VARATT_IS_4B_U(PTR))
+#define VARATT_IS_SHORT(PTR)         (VARATT_IS_1B(PTR) && !VARATT_IS_1B_E(PTR))
+#define SET_VARSIZE_COMPRESSED(PTR, len) (*(uint32_t*)(PTR) = (((uint32_t)(len)) << 2) | 0x02)
+
+// varatt_external is the TOAST pointer of a value that was stored within a TOAST table. The pointer follows a 1-byte
+// header and a tag within the varlena, so it's not aligned, and must be copied out before it's read.
+typedef struct varatt_external {
+	int32_t  va_rawsize;
+	uint32_t va_extinfo;
+	Oid      va_valueid;
+	Oid      va_toastrelid;
+} varatt_external;
+
+// varatt_indirect points to a varlena within memory, which may itself be toasted.
+typedef struct varatt_indirect {
+	varlena* pointer;
+} varatt_indirect;
+
+typedef struct varattrib_1b_e {
+	uint8_t va_header;
+	uint8_t va_tag;
+	char    va_data[FLEXIBLE_ARRAY_MEMBER];
+} varattrib_1b_e;
+
+typedef enum vartag_external {
+	VARTAG_INDIRECT    = 1,
+	VARTAG_EXPANDED_RO = 2,
+	VARTAG_EXPANDED_RW = 3,
+	VARTAG_ONDISK      = 18
+} vartag_external;
+
+// ToastCompressionId is the method that compressed a value, which is stored within the top two bits of its size.
+typedef enum ToastCompressionId {
+	TOAST_PGLZ_COMPRESSION_ID    = 0,
+	TOAST_LZ4_COMPRESSION_ID     = 1,
+	TOAST_INVALID_COMPRESSION_ID = 2
+} ToastCompressionId;
+
+#define VARHDRSZ_EXTERNAL   offsetof(varattrib_1b_e, va_data)
+#define VARHDRSZ_COMPRESSED (2 * (int32_t)sizeof(uint32_t))
+#define VARLENA_EXTSIZE_BITS 30
+#define VARLENA_EXTSIZE_MASK ((1U << VARLENA_EXTSIZE_BITS) - 1)
+#define VARTAG_EXTERNAL(PTR) (((const varattrib_1b_e*)(PTR))->va_tag)
+#define VARTAG_SIZE(tag) \
+	((tag) == VARTAG_ONDISK ? sizeof(varatt_external) : sizeof(varatt_indirect))
+#define VARSIZE_EXTERNAL(PTR) (VARHDRSZ_EXTERNAL + VARTAG_SIZE(VARTAG_EXTERNAL(PTR)))
+#define VARDATA_EXTERNAL(PTR) (((varattrib_1b_e*)(PTR))->va_data)
+#define VARATT_IS_EXTERNAL_ONDISK(PTR)   (VARATT_IS_EXTERNAL(PTR) && VARTAG_EXTERNAL(PTR) == VARTAG_ONDISK)
+#define VARATT_IS_EXTERNAL_INDIRECT(PTR) (VARATT_IS_EXTERNAL(PTR) && VARTAG_EXTERNAL(PTR) == VARTAG_INDIRECT)
+#define VARDATA_COMPRESSED_GET_EXTSIZE(PTR) (((const uint32_t*)(PTR))[1] & VARLENA_EXTSIZE_MASK)
+#define VARDATA_COMPRESSED_GET_COMPRESS_METHOD(PTR) (((const uint32_t*)(PTR))[1] >> VARLENA_EXTSIZE_BITS)
+#define VARATT_EXTERNAL_GET_EXTSIZE(toast_pointer) ((toast_pointer).va_extinfo & VARLENA_EXTSIZE_MASK)
+#define VARATT_EXTERNAL_GET_COMPRESS_METHOD(toast_pointer) ((toast_pointer).va_extinfo >> VARLENA_EXTSIZE_BITS)
+#define VARATT_EXTERNAL_IS_COMPRESSED(toast_pointer) \
+	(VARATT_EXTERNAL_GET_EXTSIZE(toast_pointer) < (uint32_t)((toast_pointer).va_rawsize - VARHDRSZ))
+
+// PgExtToastFetcher is registered by the host to fetch the values that its storage layer has moved into TOAST tables.
+// The fetch writes exactly size bytes of the stored value to dest, which are still compressed if the value was
+// compressed. The fetch sets the message when it fails, which the shim frees after raising it.
+typedef struct PgExtToastFetcher {
+	void (*fetch)(Oid toastrelid, Oid valueid, char* dest, int32_t size, int* sqlerrcode, char** message);
+} PgExtToastFetcher;
+
+varlena* pg_detoast_datum(varlena* datum);
+varlena* pg_detoast_datum_copy(varlena* datum);
+varlena* pg_detoast_datum_slice(varlena* datum, int32_t first, int32_t count);
+varlena* pg_detoast_datum_packed(varlena* datum);
+varlena* detoast_attr(varlena* attr);
+varlena* detoast_attr_slice(varlena* attr, int32_t sliceoffset, int32_t slicelength);
+varlena* detoast_external_attr(varlena* attr);
+int32_t pglz_decompress(const char* source, int32_t slen, char* dest, int32_t rawsize, bool check_complete);
+int32_t pgext_lz4_decompress(const char* source, int32_t slen, char* dest, int32_t rawsize);
+
+// StringInfoData is a buffer of bytes that is always followed by a terminator. Receive functions read the binary form
+// of their type from it, advancing the cursor past the bytes that they consume.
+typedef struct StringInfoData {
+	char* data;
+	int   len;
+	int   maxlen;
+	int   cursor;
+} StringInfoData;
+
+typedef StringInfoData* StringInfo;
+
+StringInfo makeStringInfo(void);
+void initStringInfo(StringInfo str);
+void resetStringInfo(StringInfo str);
+void enlargeStringInfo(StringInfo str, int needed);
+int appendStringInfoVA(StringInfo str, const char* fmt, va_list args);
+void appendStringInfo(StringInfo str, const char* fmt, ...);
+void appendStringInfoString(StringInfo str, const char* s);
+void appendStringInfoChar(StringInfo str, char ch);
+void appendStringInfoSpaces(StringInfo str, int count);
+void appendBinaryStringInfo(StringInfo str, const void* data, int datalen);
+void appendBinaryStringInfoNT(StringInfo str, const void* data, int datalen);
+
+#define appendStringInfoCharMacro(str, ch) \
+	(((str)->len + 1 >= (str)->maxlen) ? \
+	 appendStringInfoChar(str, ch) : \
+	 (void)((str)->data[(str)->len] = (ch), (str)->data[++(str)->len] = '\0'))
+
+// These compute the same hashes as Postgres, which are stored within hash indexes, so the values must never change.
+// Postgres 12 and later define hash_any and hash_uint32 inline through hash_bytes, but older extensions call them
+// directly, so they're exported as functions.
+uint32_t hash_bytes(const unsigned char* k, int keylen);
+uint64_t hash_bytes_extended(const unsigned char* k, int keylen, uint64_t seed);
+uint32_t hash_bytes_uint32(uint32_t k);
+uint64_t hash_bytes_uint32_extended(uint32_t k, uint64_t seed);
+Datum hash_any(const unsigned char* k, int keylen);
+Datum hash_any_extended(const unsigned char* k, int keylen, uint64_t seed);
+Datum hash_uint32(uint32_t k);
+Datum hash_uint32_extended(uint32_t k, uint64_t seed);
+uint32_t string_hash(const void* key, size_t keysize);
+uint32_t tag_hash(const void* key, size_t keysize);
+uint32_t uint32_hash(const void* key, size_t keysize);
+
+static inline uint32_t hash_combine(uint32_t a, uint32_t b) {
+	a ^= b + 0x9e3779b9 + (a << 6) + (a >> 2);
+	return a;
+}
+
+static inline uint64_t hash_combine64(uint64_t a, uint64_t b) {
+	a ^= b + UINT64_C(0x49a0f4dd15e5a8e3) + (a << 54) + (a >> 7);
+	return a;
+}
+
+static inline uint32_t murmurhash32(uint32_t data) {
+	uint32_t h = data;
+	h ^= h >> 16;
+	h *= 0x85ebca6b;
+	h ^= h >> 13;
+	h *= 0xc2b2ae35;
+	h ^= h >> 16;
+	return h;
+}
+
+// pg_enc identifies a character encoding, numbered the same as Postgres. Encodings up to PG_ENCODING_BE_LAST may be
+// used by databases, while those after it may only be used by clients.
+typedef enum pg_enc {
+	PG_SQL_ASCII = 0,
+	PG_EUC_JP,
+	PG_EUC_CN,
+	PG_EUC_KR,
+	PG_EUC_TW,
+	PG_EUC_JIS_2004,
+	PG_UTF8,
+	PG_MULE_INTERNAL,
+	PG_LATIN1,
+	PG_LATIN2,
+	PG_LATIN3,
+	PG_LATIN4,
+	PG_LATIN5,
+	PG_LATIN6,
+	PG_LATIN7,
+	PG_LATIN8,
+	PG_LATIN9,
+	PG_LATIN10,
+	PG_WIN1256,
+	PG_WIN1258,
+	PG_WIN866,
+	PG_WIN874,
+	PG_KOI8R,
+	PG_WIN1251,
+	PG_WIN1252,
+	PG_ISO_8859_5,
+	PG_ISO_8859_6,
+	PG_ISO_8859_7,
+	PG_ISO_8859_8,
+	PG_WIN1250,
+	PG_WIN1253,
+	PG_WIN1254,
+	PG_WIN1255,
+	PG_WIN1257,
+	PG_KOI8U,
+	PG_SJIS,
+	PG_BIG5,
+	PG_GBK,
+	PG_UHC,
+	PG_GB18030,
+	PG_JOHAB,
+	PG_SHIFT_JIS_2004,
+	_PG_LAST_ENCODING_
+} pg_enc;
+
+#define PG_ENCODING_BE_LAST PG_KOI8U
+#define PG_VALID_ENCODING(_enc) ((_enc) >= 0 && (_enc) < _PG_LAST_ENCODING_)
+#define PG_VALID_BE_ENCODING(_enc) ((_enc) >= 0 && (_enc) <= PG_ENCODING_BE_LAST)
+#define PG_ENCODING_IS_CLIENT_ONLY(_enc) ((_enc) > PG_ENCODING_BE_LAST && (_enc) < _PG_LAST_ENCODING_)
+#define IS_HIGHBIT_SET(ch) ((unsigned char)(ch) & 0x80)
+
+// The database encoding is set by the host, and is UTF8 unless the host says otherwise. The shim does not model the
+// encoding of clients, so the client encoding is always the same as the database encoding.
+int GetDatabaseEncoding(void);
+const char* GetDatabaseEncodingName(void);
+int pg_get_client_encoding(void);
+const char* pg_get_client_encoding_name(void);
+int pg_database_encoding_max_length(void);
+const char* pg_encoding_to_char(int encoding);
+int pg_char_to_encoding(const char* name);
+bool pg_valid_server_encoding_id(int encoding);
+int pg_encoding_max_length(int encoding);
+int pg_encoding_mblen(int encoding, const char* mbstr);
+int pg_mblen(const char* mbstr);
+int pg_mbstrlen(const char* mbstr);
+int pg_mbstrlen_with_len(const char* mbstr, int len);
+int pg_mbcliplen(const char* mbstr, int len, int limit);
+int pg_encoding_mbcliplen(int encoding, const char* mbstr, int len, int limit);
+bool pg_verifymbstr(const char* mbstr, int len, bool noError);
+bool pg_verify_mbstr(int encoding, const char* mbstr, int len, bool noError);
+int pg_verify_mbstr_len(int encoding, const char* mbstr, int len, bool noError);
+unsigned char* pg_do_encoding_conversion(unsigned char* src, int len, int src_encoding, int dest_encoding);
+char* pg_server_to_any(const char* s, int len, int encoding);
+char* pg_any_to_server(const char* s, int len, int encoding);
+char* pg_server_to_client(const char* s, int len);
+char* pg_client_to_server(const char* s, int len);
+void report_invalid_encoding(int encoding, const char* mbstr, int len);
+void report_untranslatable_char(int src_encoding, int dest_encoding, const char* mbstr, int len);
+
+#define COLLPROVIDER_DEFAULT 'd'
+#define COLLPROVIDER_ICU     'i'
+#define COLLPROVIDER_LIBC    'c'
+
+// pg_locale_struct describes a collation other than the default. The shim does not open locales, so the info is always
+// empty, and extensions must go through functions such as str_tolower and varstr_cmp rather than reading it.
+typedef struct pg_locale_struct {
+	char provider;
+	bool deterministic;
+	union {
+		void* lt;
+		int   dummy;
+	} info;
+} pg_locale_struct;
+
+typedef pg_locale_struct* pg_locale_t;
+
+// The kinds of case conversion that are given to the collation provider.
+#define PGEXT_CASE_LOWER   0
+#define PGEXT_CASE_UPPER   1
+#define PGEXT_CASE_INITCAP 2
+
+// PgExtCollationProvider is registered by the host to describe its collations, and to change the case of and compare
+// strings under them. The describe function returns false for collations that do not exist. The change_case function
+// returns the converted string allocated with malloc, writing its length to result_len. The change_case and compare
+// functions set the message when they fail, which the shim frees after raising it.
+typedef struct PgExtCollationProvider {
+	bool  (*describe)(Oid collid, bool* collate_is_c, bool* ctype_is_c, bool* deterministic);
+	char* (*change_case)(Oid collid, int kind, const char* str, size_t len, size_t* result_len, int* sqlerrcode,
+		char** message);
+	int   (*compare)(Oid collid, const char* arg1, size_t len1, const char* arg2, size_t len2, int* sqlerrcode,
+		char** message);
+} PgExtCollationProvider;
+
+// Without a provider, the default collation sorts bytewise and changes case according to Unicode, the same as the
+// C.UTF-8 locale, and collations other than the default, C, and POSIX do not exist.
+bool lc_collate_is_c(Oid collation);
+bool lc_ctype_is_c(Oid collation);
+pg_locale_t pg_newlocale_from_collation(Oid collid);
+char* str_tolower(const char* buff, size_t nbytes, Oid collid);
+char* str_toupper(const char* buff, size_t nbytes, Oid collid);
+char* str_initcap(const char* buff, size_t nbytes, Oid collid);
+char* asc_tolower(const char* buff, size_t nbytes);
+char* asc_toupper(const char* buff, size_t nbytes);
+char* asc_initcap(const char* buff, size_t nbytes);
+int varstr_cmp(const char* arg1, int len1, const char* arg2, int len2, Oid collid);
+unsigned char pg_toupper(unsigned char ch);
+unsigned char pg_tolower(unsigned char ch);
+unsigned char pg_ascii_toupper(unsigned char ch);
+unsigned char pg_ascii_tolower(unsigned char ch);
+int pg_strcasecmp(const char* s1, const char* s2);
+int pg_strncasecmp(const char* s1, const char* s2, size_t n);
+
+// These call the I/O functions of a type, which convert between Datums and their text and binary forms.
+Datum InputFunctionCall(FmgrInfo* flinfo, char* str, Oid typioparam, int32_t typmod);
+char* OutputFunctionCall(FmgrInfo* flinfo, Datum val);
+Datum ReceiveFunctionCall(FmgrInfo* flinfo, StringInfo buf, Oid typioparam, int32_t typmod);
+bytea* SendFunctionCall(FmgrInfo* flinfo, Datum val);
+Datum OidInputFunctionCall(Oid functionId, char* str, Oid typioparam, int32_t typmod);
+char* OidOutputFunctionCall(Oid functionId, Datum val);
+Datum OidReceiveFunctionCall(Oid functionId, StringInfo buf, Oid typioparam, int32_t typmod);
+bytea* OidSendFunctionCall(Oid functionId, Datum val);
+
+// These call functions directly, without an FmgrInfo. NULL results raise an error.
+Datum DirectFunctionCall1Coll(PGFunction func, Oid collation, Datum arg1);
+Datum DirectFunctionCall2Coll(PGFunction func, Oid collation, Datum arg1, Datum arg2);
+Datum DirectFunctionCall3Coll(PGFunction func, Oid collation, Datum arg1, Datum arg2, Datum arg3);
+Datum DirectFunctionCall4Coll(PGFunction func, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4);
+Datum DirectFunctionCall5Coll(PGFunction func, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5);
+Datum DirectFunctionCall6Coll(PGFunction func, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5, Datum arg6);
+Datum DirectFunctionCall7Coll(PGFunction func, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5, Datum arg6, Datum arg7);
+Datum DirectFunctionCall8Coll(PGFunction func, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5, Datum arg6, Datum arg7, Datum arg8);
+Datum DirectFunctionCall9Coll(PGFunction func, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5, Datum arg6, Datum arg7, Datum arg8, Datum arg9);
+
+#define DirectFunctionCall1(func, arg1) DirectFunctionCall1Coll(func, 0, arg1)
+#define DirectFunctionCall2(func, arg1, arg2) DirectFunctionCall2Coll(func, 0, arg1, arg2)
+#define DirectFunctionCall3(func, arg1, arg2, arg3) DirectFunctionCall3Coll(func, 0, arg1, arg2, arg3)
+#define DirectFunctionCall4(func, arg1, arg2, arg3, arg4) DirectFunctionCall4Coll(func, 0, arg1, arg2, arg3, arg4)
+#define DirectFunctionCall5(func, arg1, arg2, arg3, arg4, arg5) \
+	DirectFunctionCall5Coll(func, 0, arg1, arg2, arg3, arg4, arg5)
+#define DirectFunctionCall6(func, arg1, arg2, arg3, arg4, arg5, arg6) \
+	DirectFunctionCall6Coll(func, 0, arg1, arg2, arg3, arg4, arg5, arg6)
+#define DirectFunctionCall7(func, arg1, arg2, arg3, arg4, arg5, arg6, arg7) \
+	DirectFunctionCall7Coll(func, 0, arg1, arg2, arg3, arg4, arg5, arg6, arg7)
+#define DirectFunctionCall8(func, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8) \
+	DirectFunctionCall8Coll(func, 0, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8)
+#define DirectFunctionCall9(func, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9) \
+	DirectFunctionCall9Coll(func, 0, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9)
+
+void fmgr_info(Oid functionId, FmgrInfo* finfo);
+void fmgr_info_cxt(Oid functionId, FmgrInfo* finfo, MemoryContext mcxt);
+void fmgr_info_copy(FmgrInfo* dstinfo, FmgrInfo* srcinfo, MemoryContext destcxt);
+
+// These call functions through an FmgrInfo that was set up by fmgr_info. NULL results raise an error.
+Datum FunctionCall0Coll(FmgrInfo* flinfo, Oid collation);
+Datum FunctionCall1Coll(FmgrInfo* flinfo, Oid collation, Datum arg1);
+Datum FunctionCall2Coll(FmgrInfo* flinfo, Oid collation, Datum arg1, Datum arg2);
+Datum FunctionCall3Coll(FmgrInfo* flinfo, Oid collation, Datum arg1, Datum arg2, Datum arg3);
+Datum FunctionCall4Coll(FmgrInfo* flinfo, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4);
+Datum FunctionCall5Coll(FmgrInfo* flinfo, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4, Datum arg5);
+Datum FunctionCall6Coll(FmgrInfo* flinfo, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4, Datum arg5,
+	Datum arg6);
+Datum FunctionCall7Coll(FmgrInfo* flinfo, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4, Datum arg5,
+	Datum arg6, Datum arg7);
+Datum FunctionCall8Coll(FmgrInfo* flinfo, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4, Datum arg5,
+	Datum arg6, Datum arg7, Datum arg8);
+Datum FunctionCall9Coll(FmgrInfo* flinfo, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4, Datum arg5,
+	Datum arg6, Datum arg7, Datum arg8, Datum arg9);
+
+#define FunctionCall0(flinfo) FunctionCall0Coll(flinfo, 0)
+#define FunctionCall1(flinfo, arg1) FunctionCall1Coll(flinfo, 0, arg1)
+#define FunctionCall2(flinfo, arg1, arg2) FunctionCall2Coll(flinfo, 0, arg1, arg2)
+#define FunctionCall3(flinfo, arg1, arg2, arg3) FunctionCall3Coll(flinfo, 0, arg1, arg2, arg3)
+#define FunctionCall4(flinfo, arg1, arg2, arg3, arg4) FunctionCall4Coll(flinfo, 0, arg1, arg2, arg3, arg4)
+#define FunctionCall5(flinfo, arg1, arg2, arg3, arg4, arg5) FunctionCall5Coll(flinfo, 0, arg1, arg2, arg3, arg4, arg5)
+#define FunctionCall6(flinfo, arg1, arg2, arg3, arg4, arg5, arg6) \
+	FunctionCall6Coll(flinfo, 0, arg1, arg2, arg3, arg4, arg5, arg6)
+#define FunctionCall7(flinfo, arg1, arg2, arg3, arg4, arg5, arg6, arg7) \
+	FunctionCall7Coll(flinfo, 0, arg1, arg2, arg3, arg4, arg5, arg6, arg7)
+#define FunctionCall8(flinfo, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8) \
+	FunctionCall8Coll(flinfo, 0, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8)
+#define FunctionCall9(flinfo, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9) \
+	FunctionCall9Coll(flinfo, 0, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9)
+
+// These call functions by OID, which are found through the host's lookup. NULL results raise an error.
+Datum OidFunctionCall0Coll(Oid functionId, Oid collation);
+Datum OidFunctionCall1Coll(Oid functionId, Oid collation, Datum arg1);
+Datum OidFunctionCall2Coll(Oid functionId, Oid collation, Datum arg1, Datum arg2);
+Datum OidFunctionCall3Coll(Oid functionId, Oid collation, Datum arg1, Datum arg2, Datum arg3);
+Datum OidFunctionCall4Coll(Oid functionId, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4);
+Datum OidFunctionCall5Coll(Oid functionId, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4, Datum arg5);
+Datum OidFunctionCall6Coll(Oid functionId, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4, Datum arg5,
+	Datum arg6);
+Datum OidFunctionCall7Coll(Oid functionId, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4, Datum arg5,
+	Datum arg6, Datum arg7);
+Datum OidFunctionCall8Coll(Oid functionId, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4, Datum arg5,
+	Datum arg6, Datum arg7, Datum arg8);
+Datum OidFunctionCall9Coll(Oid functionId, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4, Datum arg5,
+	Datum arg6, Datum arg7, Datum arg8, Datum arg9);
+
+#define OidFunctionCall0(functionId) OidFunctionCall0Coll(functionId, 0)
+#define OidFunctionCall1(functionId, arg1) OidFunctionCall1Coll(functionId, 0, arg1)
+#define OidFunctionCall2(functionId, arg1, arg2) OidFunctionCall2Coll(functionId, 0, arg1, arg2)
+#define OidFunctionCall3(functionId, arg1, arg2, arg3) OidFunctionCall3Coll(functionId, 0, arg1, arg2, arg3)
+#define OidFunctionCall4(functionId, arg1, arg2, arg3, arg4) OidFunctionCall4Coll(functionId, 0, arg1, arg2, arg3, arg4)
+#define OidFunctionCall5(functionId, arg1, arg2, arg3, arg4, arg5) \
+	OidFunctionCall5Coll(functionId, 0, arg1, arg2, arg3, arg4, arg5)
+#define OidFunctionCall6(functionId, arg1, arg2, arg3, arg4, arg5, arg6) \
+	OidFunctionCall6Coll(functionId, 0, arg1, arg2, arg3, arg4, arg5, arg6)
+#define OidFunctionCall7(functionId, arg1, arg2, arg3, arg4, arg5, arg6, arg7) \
+	OidFunctionCall7Coll(functionId, 0, arg1, arg2, arg3, arg4, arg5, arg6, arg7)
+#define OidFunctionCall8(functionId, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8) \
+	OidFunctionCall8Coll(functionId, 0, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8)
+#define OidFunctionCall9(functionId, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9) \
+	OidFunctionCall9Coll(functionId, 0, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9)
+
 typedef const char pgext_const_char;
 typedef const uint8_t pgext_const_uint8;
 
+// These are the error levels of Postgres 14 and later.
+#define DEBUG5  10
+#define DEBUG4  11
+#define DEBUG3  12
+#define DEBUG2  13
+#define DEBUG1  14
+#define LOG     15
+#define INFO    17
+#define NOTICE  18
+#define WARNING 19
+#define ERROR   21
+#define FATAL   22
+#define PANIC   23
+
+#define PGSIXBIT(ch)                          (((ch) - '0') & 0x3F)
+#define MAKE_SQLSTATE(ch1, ch2, ch3, ch4, ch5) \
+	(PGSIXBIT(ch1) + (PGSIXBIT(ch2) << 6) + (PGSIXBIT(ch3) << 12) + (PGSIXBIT(ch4) << 18) + (PGSIXBIT(ch5) << 24))
+
+#define ERRCODE_SUCCESSFUL_COMPLETION         MAKE_SQLSTATE('0','0','0','0','0')
+#define ERRCODE_WARNING                       MAKE_SQLSTATE('0','1','0','0','0')
+#define ERRCODE_FEATURE_NOT_SUPPORTED         MAKE_SQLSTATE('0','A','0','0','0')
+#define ERRCODE_NUMERIC_VALUE_OUT_OF_RANGE    MAKE_SQLSTATE('2','2','0','0','3')
+#define ERRCODE_NULL_VALUE_NOT_ALLOWED        MAKE_SQLSTATE('2','2','0','0','4')
+#define ERRCODE_INVALID_DATETIME_FORMAT       MAKE_SQLSTATE('2','2','0','0','7')
+#define ERRCODE_DATETIME_VALUE_OUT_OF_RANGE   MAKE_SQLSTATE('2','2','0','0','8')
+#define ERRCODE_CHARACTER_NOT_IN_REPERTOIRE   MAKE_SQLSTATE('2','2','0','2','1')
+#define ERRCODE_INVALID_PARAMETER_VALUE       MAKE_SQLSTATE('2','2','0','2','3')
+#define ERRCODE_INVALID_TEXT_REPRESENTATION   MAKE_SQLSTATE('2','2','P','0','2')
+#define ERRCODE_INVALID_BINARY_REPRESENTATION MAKE_SQLSTATE('2','2','P','0','3')
+#define ERRCODE_UNTRANSLATABLE_CHARACTER      MAKE_SQLSTATE('2','2','P','0','5')
+#define ERRCODE_SYNTAX_ERROR                  MAKE_SQLSTATE('4','2','6','0','1')
+#define ERRCODE_NAME_TOO_LONG                 MAKE_SQLSTATE('4','2','6','2','2')
+#define ERRCODE_DATATYPE_MISMATCH             MAKE_SQLSTATE('4','2','8','0','4')
+#define ERRCODE_INDETERMINATE_COLLATION       MAKE_SQLSTATE('4','2','P','2','2')
+#define ERRCODE_UNDEFINED_FUNCTION            MAKE_SQLSTATE('4','2','8','8','3')
+#define ERRCODE_UNDEFINED_OBJECT              MAKE_SQLSTATE('4','2','7','0','4')
+#define ERRCODE_UNDEFINED_COLUMN              MAKE_SQLSTATE('4','2','7','0','3')
+#define ERRCODE_UNDEFINED_DATABASE            MAKE_SQLSTATE('3','D','0','0','0')
+#define ERRCODE_OUT_OF_MEMORY                 MAKE_SQLSTATE('5','3','2','0','0')
+#define ERRCODE_PROGRAM_LIMIT_EXCEEDED        MAKE_SQLSTATE('5','4','0','0','0')
+#define ERRCODE_OBJECT_NOT_IN_PREREQUISITE_STATE MAKE_SQLSTATE('5','5','0','0','0')
+#define ERRCODE_QUERY_CANCELED                MAKE_SQLSTATE('5','7','0','1','4')
+#define ERRCODE_ADMIN_SHUTDOWN                MAKE_SQLSTATE('5','7','P','0','1')
+#define ERRCODE_INTERNAL_ERROR                MAKE_SQLSTATE('X','X','0','0','0')
+#define ERRCODE_DATA_CORRUPTED                MAKE_SQLSTATE('X','X','0','0','1')
+
+// ErrorContextCallback is pushed onto error_context_stack by extensions, so that they may add context to errors.
+typedef struct ErrorContextCallback {
+	struct ErrorContextCallback* previous;
+	void                         (*callback)(void* arg);
+	void*                        arg;
+} ErrorContextCallback;
+
+extern DLLEXPORT sigjmp_buf* PG_exception_stack;
+extern DLLEXPORT ErrorContextCallback* error_context_stack;
+
+// PgExtErrorData is an error or message that has been raised through ereport or elog.
+typedef struct PgExtErrorData {
+	int         elevel;
+	int         sqlerrcode;
+	const char* domain;
+	const char* context_domain;
+	const char* filename;
+	int         lineno;
+	const char* funcname;
+	char        message[1024];
+	char        detail[1024];
+	char        hint[512];
+	char        context[1024];
+	// signal is the signal that was raised when a guarded call crashed, and is zero for all other errors.
+	int         signal;
+	void*       address;
+} PgExtErrorData;
+
+// ERRORDATA_STACK_SIZE is the number of reports that may be in progress at once, as a report's context callbacks may
+// raise reports of their own.
+#define ERRORDATA_STACK_SIZE 5
+
+// PGEXT_CRASH_FRAMES is the maximum number of stack frames that are recorded when a guarded call crashes.
+#define PGEXT_CRASH_FRAMES 64
+// PGEXT_CRASH_JUMP is the value given to siglongjmp when unwinding from a crash, to distinguish it from an error.
+#define PGEXT_CRASH_JUMP 2
+
+// PgExtGuard is set on the thread that is making a guarded call, so that a crash may unwind back to the call.
+typedef struct PgExtGuard {
+	sigjmp_buf*               jump;
+	struct PgExtBackendState* state;
+} PgExtGuard;
+
+typedef enum ResourceReleasePhase {
+	RESOURCE_RELEASE_BEFORE_LOCKS,
+	RESOURCE_RELEASE_LOCKS,
+	RESOURCE_RELEASE_AFTER_LOCKS
+} ResourceReleasePhase;
+
+typedef void (*ResourceReleaseCallback)(ResourceReleasePhase phase, bool isCommit, bool isTopLevel, void* arg);
+
+// ResourceOwnerData is the resource owner of a session. Extensions only compare owners and register callbacks, which
+// are called with the session's owner as the current owner when the session is destroyed, so that extensions may free
+// whatever the session leaked through errors.
+typedef struct ResourceOwnerData {
+	const char* name;
+} ResourceOwnerData;
+
+typedef ResourceOwnerData* ResourceOwner;
+
+extern DLLEXPORT ResourceOwner CurrentResourceOwner;
+
+void RegisterResourceReleaseCallback(ResourceReleaseCallback callback, void* arg);
+void UnregisterResourceReleaseCallback(ResourceReleaseCallback callback, void* arg);
+
+#define BGWORKER_SHMEM_ACCESS               0x0001
+#define BGWORKER_BACKEND_DATABASE_CONNECTION 0x0002
+#define BGW_DEFAULT_RESTART_INTERVAL        60
+#define BGW_NEVER_RESTART                   -1
+#define BGW_MAXLEN                          96
+#define BGW_EXTRALEN                        128
+
+typedef enum BgWorkerStartTime {
+	BgWorkerStart_PostmasterStart,
+	BgWorkerStart_ConsistentState,
+	BgWorkerStart_RecoveryFinished
+} BgWorkerStartTime;
+
+// BackgroundWorker describes a background worker that an extension registers, which matches the layout of Postgres 15.
+typedef struct BackgroundWorker {
+	char              bgw_name[BGW_MAXLEN];
+	char              bgw_type[BGW_MAXLEN];
+	int               bgw_flags;
+	BgWorkerStartTime bgw_start_time;
+	int               bgw_restart_time;
+	char              bgw_library_name[BGW_MAXLEN];
+	char              bgw_function_name[BGW_MAXLEN];
+	Datum             bgw_main_arg;
+	char              bgw_extra[BGW_EXTRALEN];
+	pid_t             bgw_notify_pid;
+} BackgroundWorker;
+
+typedef enum BgwHandleStatus {
+	BGWH_STARTED,
+	BGWH_NOT_YET_STARTED,
+	BGWH_STOPPED,
+	BGWH_POSTMASTER_DIED
+} BgwHandleStatus;
+
+// BackgroundWorkerHandle refers to a dynamic background worker through the ID that the host's worker manager gave it.
+typedef struct BackgroundWorkerHandle {
+	uint64_t id;
+} BackgroundWorkerHandle;
+
+// The ways in which PgExtBackgroundWorkerManager.status may wait on a worker.
+#define PGEXT_BGWORKER_NO_WAIT       0
+#define PGEXT_BGWORKER_WAIT_STARTUP  1
+#define PGEXT_BGWORKER_WAIT_SHUTDOWN 2
+
+// PgExtBackgroundWorkerManager is registered by the host to run the background workers that extensions register. The
+// register_worker function copies the worker, returning the ID that it was given, or zero if it was not accepted. The
+// status function returns a BgwHandleStatus, after waiting on the worker as requested, and writes the ID of the
+// worker's process while it's running.
+typedef struct PgExtBackgroundWorkerManager {
+	uint64_t (*register_worker)(const BackgroundWorker* worker, bool dynamic);
+	int      (*status)(uint64_t id, int wait, pid_t* pid);
+	void     (*terminate)(uint64_t id);
+} PgExtBackgroundWorkerManager;
+
+#define WL_LATCH_SET         (1 << 0)
+#define WL_SOCKET_READABLE   (1 << 1)
+#define WL_SOCKET_WRITEABLE  (1 << 2)
+#define WL_TIMEOUT           (1 << 3)
+#define WL_POSTMASTER_DEATH  (1 << 4)
+#define WL_EXIT_ON_PM_DEATH  (1 << 5)
+
+// Latch matches the layout of Postgres 15 on platforms other than Windows, as extensions embed latches within their own
+// shared structs. The owner_pid is unused, since latches are woken through the sessions that are waiting on them.
+typedef struct Latch {
+	sig_atomic_t is_set;
+	sig_atomic_t maybe_sleeping;
+	bool         is_shared;
+	int          owner_pid;
+} Latch;
+
+#define SIGNAL_ARGS int postgres_signal_arg
+typedef void (*pqsigfunc)(int signo);
+
+// PGEXT_NSIG is the number of signals that a session records handlers for.
+#define PGEXT_NSIG 64
+
+extern DLLEXPORT BackgroundWorker* MyBgworkerEntry;
+extern DLLEXPORT struct Latch* MyLatch;
+extern DLLEXPORT volatile sig_atomic_t ConfigReloadPending;
+extern DLLEXPORT volatile sig_atomic_t ShutdownRequestPending;
+extern DLLEXPORT bool process_shared_preload_libraries_in_progress;
+extern DLLEXPORT volatile sig_atomic_t InterruptPending;
+extern DLLEXPORT volatile sig_atomic_t QueryCancelPending;
+extern DLLEXPORT volatile sig_atomic_t ProcDiePending;
+extern DLLEXPORT volatile uint32_t InterruptHoldoffCount;
+extern DLLEXPORT volatile uint32_t QueryCancelHoldoffCount;
+extern DLLEXPORT volatile uint32_t CritSectionCount;
+extern DLLEXPORT int MyProcPid;
+extern DLLEXPORT Oid MyDatabaseId;
+extern DLLEXPORT char* application_name;
+
+void ProcessInterrupts(void);
+void StatementCancelHandler(SIGNAL_ARGS);
+void die(SIGNAL_ARGS);
+
+#define CHECK_FOR_INTERRUPTS() \
+	do { \
+		if (InterruptPending) { \
+			ProcessInterrupts(); \
+		} \
+	} while (0)
+
+void RegisterBackgroundWorker(BackgroundWorker* worker);
+bool RegisterDynamicBackgroundWorker(BackgroundWorker* worker, BackgroundWorkerHandle** handle);
+BgwHandleStatus GetBackgroundWorkerPid(BackgroundWorkerHandle* handle, pid_t* pidp);
+BgwHandleStatus WaitForBackgroundWorkerStartup(BackgroundWorkerHandle* handle, pid_t* pidp);
+BgwHandleStatus WaitForBackgroundWorkerShutdown(BackgroundWorkerHandle* handle);
+void TerminateBackgroundWorker(BackgroundWorkerHandle* handle);
+void BackgroundWorkerBlockSignals(void);
+void BackgroundWorkerUnblockSignals(void);
+void BackgroundWorkerInitializeConnection(const char* dbname, const char* username, uint32_t flags);
+void BackgroundWorkerInitializeConnectionByOid(Oid dboid, Oid useroid, uint32_t flags);
+void ProcessConfigFile(int context);
+pqsigfunc pqsignal(int signo, pqsigfunc func);
+void SignalHandlerForConfigReload(SIGNAL_ARGS);
+void SignalHandlerForShutdownRequest(SIGNAL_ARGS);
+void InitLatch(Latch* latch);
+void InitSharedLatch(Latch* latch);
+void OwnLatch(Latch* latch);
+void DisownLatch(Latch* latch);
+void SetLatch(Latch* latch);
+void ResetLatch(Latch* latch);
+int WaitLatch(Latch* latch, int wakeEvents, long timeout, uint32_t wait_event_info);
+int WaitLatchOrSocket(Latch* latch, int wakeEvents, int sock, long timeout, uint32_t wait_event_info);
+void proc_exit(int code);
+
+typedef void (*shmem_request_hook_type)(void);
+typedef void (*shmem_startup_hook_type)(void);
+
+// LWLock matches the layout of Postgres 15, as extensions embed locks within their own shared structs. The state and
+// waiters are unused, since each lock is backed by a lock that the shim keeps for it.
+typedef struct LWLock {
+	uint16_t tranche;
+	uint32_t state;
+	struct {
+		int head;
+		int tail;
+	} waiters;
+} LWLock;
+
+#define LWLOCK_PADDED_SIZE 128
+
+typedef union LWLockPadded {
+	LWLock lock;
+	char   pad[LWLOCK_PADDED_SIZE];
+} LWLockPadded;
+
+typedef enum LWLockMode {
+	LW_EXCLUSIVE,
+	LW_SHARED,
+	LW_WAIT_UNTIL_FREE,
+} LWLockMode;
+
+// These match the main locks of Postgres 15, of which extensions only use AddinShmemInitLock.
+#define NUM_INDIVIDUAL_LWLOCKS       48
+#define NUM_FIXED_LWLOCKS            (NUM_INDIVIDUAL_LWLOCKS + 128 + 16 + 16)
+#define AddinShmemInitLock           (&MainLWLockArray[21].lock)
+#define LWTRANCHE_FIRST_USER_DEFINED 75
+// PGEXT_MAX_SIMUL_LWLOCKS is the number of locks that a session may hold at once, which matches Postgres.
+#define PGEXT_MAX_SIMUL_LWLOCKS      200
+// SHMEM_INDEX_KEYSIZE is the length that the names of shared structs are truncated to, including the terminator.
+#define SHMEM_INDEX_KEYSIZE          48
+
+// PgExtHeldLWLock is a lock that a session holds, along with the mode that it was acquired in.
+typedef struct PgExtHeldLWLock {
+	LWLock*    lock;
+	LWLockMode mode;
+} PgExtHeldLWLock;
+
+extern DLLEXPORT shmem_request_hook_type shmem_request_hook;
+extern DLLEXPORT shmem_startup_hook_type shmem_startup_hook;
+extern DLLEXPORT bool process_shmem_requests_in_progress;
+extern DLLEXPORT LWLockPadded* MainLWLockArray;
+
+void RequestAddinShmemSpace(size_t size);
+void* ShmemAlloc(size_t size);
+void* ShmemAllocNoError(size_t size);
+void* ShmemInitStruct(const char* name, size_t size, bool* foundPtr);
+void RequestNamedLWLockTranche(const char* tranche_name, int num_lwlocks);
+LWLockPadded* GetNamedLWLockTranche(const char* tranche_name);
+int LWLockNewTrancheId(void);
+void LWLockRegisterTranche(int tranche_id, const char* tranche_name);
+void LWLockInitialize(LWLock* lock, int tranche_id);
+bool LWLockAcquire(LWLock* lock, LWLockMode mode);
+bool LWLockConditionalAcquire(LWLock* lock, LWLockMode mode);
+bool LWLockAcquireOrWait(LWLock* lock, LWLockMode mode);
+void LWLockRelease(LWLock* lock);
+void LWLockReleaseAll(void);
+bool LWLockHeldByMe(LWLock* lock);
+bool LWLockHeldByMeInMode(LWLock* lock, LWLockMode mode);
+
+// PgExtBackendState contains the state that Postgres keeps per backend process. As many sessions may use extensions
+// concurrently, each session has its own state, which is bound to the thread that is executing on behalf of the session.
+typedef struct PgExtBackendState {
+	PgExtErrorData             errordata[ERRORDATA_STACK_SIZE];
+	int                        errordata_depth;
+	// caught is the most recent error, which is kept after unwinding so that it may be returned to the host.
+	PgExtErrorData             caught;
+	// uncaught is set when caught holds an error that was raised outside of any call, such as when the host calls into
+	// the shim directly, which the host has not yet taken.
+	bool                       uncaught;
+	MemoryContext              top_memory_context;
+	MemoryContext              current_memory_context;
+	sigjmp_buf*                exception_stack;
+	ErrorContextCallback*      context_stack;
+	// crash_frames is the stack of the most recent guarded call that crashed.
+	void*                      crash_frames[PGEXT_CRASH_FRAMES];
+	int                        crash_frame_count;
+	// spi_connection is the innermost SPI connection, or NULL when the session is not connected to SPI.
+	struct PgExtSPIConnection* spi_connection;
+	// These hold the SPI globals of the session while it is not bound to a thread.
+	uint64_t                   spi_processed;
+	struct SPITupleTable*      spi_tuptable;
+	int                        spi_result;
+	// resource_owner is the current resource owner of the session while it is not bound to a thread, which is usually
+	// owner_data.
+	ResourceOwner              resource_owner;
+	ResourceOwnerData          owner_data;
+	// bgworker_entry is the background worker that the session is running, or NULL when it is not a worker.
+	BackgroundWorker*          bgworker_entry;
+	// latch is the session's own latch, which is woken when the session is signaled.
+	Latch                      latch;
+	// The following hold the latch and signal globals of the session while it is not bound to a thread.
+	Latch*                     my_latch;
+	sig_atomic_t               config_reload_pending;
+	sig_atomic_t               shutdown_request_pending;
+	// signal_handlers are the handlers that were given to pqsignal, which are called on the session's own thread when
+	// a signal is delivered, rather than being installed as handlers of the process.
+	pqsigfunc                  signal_handlers[PGEXT_NSIG];
+	// pending_signals is a mask of the signals that were sent to the session and not yet delivered.
+	uint64_t                   pending_signals;
+	// query_cancel_pending and proc_die_pending are the interrupts that the session has yet to process. These are
+	// kept here rather than only within the globals of the same name, which are shared with sessions that are bound
+	// to other threads, so that one session never processes the interrupts of another.
+	sig_atomic_t               query_cancel_pending;
+	sig_atomic_t               proc_die_pending;
+	// These hold the interrupt globals of the session while it is not bound to a thread.
+	uint32_t                   interrupt_holdoff_count;
+	uint32_t                   query_cancel_holdoff_count;
+	uint32_t                   crit_section_count;
+	// exit_requested is set when a background worker calls proc_exit, along with the code that it exited with.
+	bool                       exit_requested;
+	int                        exit_code;
+	// held_lwlocks are the locks that the session holds, in the order that they were acquired.
+	PgExtHeldLWLock            held_lwlocks[PGEXT_MAX_SIMUL_LWLOCKS];
+	int                        num_held_lwlocks;
+	// type_cache contains the entries of lookup_type_cache, which are allocated within the top memory context.
+	struct PgExtTypeCacheNode* type_cache;
+	// session_user_id is the role that the host set for the session, which is the bootstrap superuser when it's
+	// InvalidOid. user_id is the current role when an extension has changed it, and is otherwise InvalidOid.
+	Oid                        session_user_id;
+	Oid                        user_id;
+	int                        sec_context;
+	// The following are the details of the session that the host gave through pgext_backend_state_set_info, which are
+	// loaded into the globals of the same name. proc_pid is the ID that the session reports as its process ID, which
+	// is the ID of the host's process when it's zero.
+	int                        proc_pid;
+	Oid                        database_id;
+	char                       database_name[NAMEDATALEN];
+	char                       application_name[NAMEDATALEN];
+	// trace_span is the host's handle to the span of the call that the session is running, or zero if the call is not
+	// traced.
+	uintptr_t                  trace_span;
+} PgExtBackendState;
+
+PgExtBackendState* pgext_backend_state(void);
+PgExtBackendState* pgext_backend_state_bind(PgExtBackendState* state);
+void pgext_backend_state_suspend(void);
+void pgext_backend_state_resume(void);
+void pgext_raise_error(int elevel, int sqlerrcode, const char* fmt, ...);
+void pgext_raise_host_error(int sqlerrcode, char* message);
+PgExtErrorData* pgext_take_error(void);
+PgExtErrorData* pgext_catch_errors(Datum (*fn)(void* arg), void* arg, Datum* result);
+PgExtErrorData* pgext_fmgr_call(FunctionCallInfo fcinfo, Datum* result);
+uintptr_t pgext_signal_backend(PgExtBackendState* state, uintptr_t signo);
+void pgext_deliver_signals(void);
+void pgext_discard_signals(PgExtBackendState* state, uint64_t mask);
+bool pgext_signals_pending(void);
+void pgext_lwlock_unwind(PgExtBackendState* state, int num_held);
+
+// PgExtBatchCall describes a function that is called once for each row of arguments, so that the host only crosses into
+// the shim once for the entire batch. The call info describes the function, and its arguments are replaced by those of
+// each row, which are laid out one row after another. Each row that raises an error is given a copy of the error that
+// was allocated with malloc, which the host frees.
+typedef struct PgExtBatchCall {
+	FunctionCallInfo fcinfo;
+	int              nrows;
+	NullableDatum*   args;
+	Datum*           results;
+	bool*            isnull;
+	PgExtErrorData** errors;
+} PgExtBatchCall;
+
+uintptr_t pgext_fmgr_call_batch(PgExtBatchCall* batch);
+bool pgext_guarded_calls(void);
+PgExtGuard* pgext_guard_set(PgExtGuard* guard);
+bool errstart(int elevel, const char* domain);
+void errfinish(const char* filename, int lineno, const char* funcname);
+void pg_re_throw(void);
+int errcode(int sqlerrcode);
+int errmsg_internal(const char* fmt, ...);
+int errdetail_internal(const char* fmt, ...);
+int errhint(const char* fmt, ...);
+int errcontext_msg(const char* fmt, ...);
+
+// MAXPGPATH is the size of the buffers that hold file paths.
+#define MAXPGPATH 1024
+
+// The character classes that the text search functions test for.
+#define PGEXT_CHAR_DIGIT 0
+#define PGEXT_CHAR_SPACE 1
+#define PGEXT_CHAR_ALPHA 2
+#define PGEXT_CHAR_ALNUM 3
+#define PGEXT_CHAR_PRINT 4
+
+#define TOUCHAR(x)     (*((const unsigned char*)(x)))
+#define t_iseq(x, c)   (TOUCHAR(x) == (unsigned char)(c))
+#define COPYCHAR(d, s) memcpy(d, s, pg_mblen(s))
+
+// tsearch_readline_state is the state of reading a text search configuration file, which has the same layout as
+// Postgres 16 since extensions declare it on their own stacks.
+typedef struct tsearch_readline_state {
+	FILE*                fp;
+	const char*          filename;
+	int                  lineno;
+	StringInfoData       buf;
+	char*                curline;
+	ErrorContextCallback cb;
+} tsearch_readline_state;
+
+// TSLexeme is a lexeme that is returned by the lexize function of a text search dictionary, where the array of lexemes
+// ends with one whose lexeme is NULL.
+typedef struct TSLexeme {
+	uint16_t nvariant;
+	uint16_t flags;
+	char*    lexeme;
+} TSLexeme;
+
+#define TSL_ADDPOS 0x01
+#define TSL_PREFIX 0x02
+#define TSL_FILTER 0x04
+
+// Characters are classified according to the ctype of the default collation. Characters outside of ASCII are classified
+// according to Unicode when the database encoding is UTF8, and are otherwise never within a class.
+int t_isdigit(const char* ptr);
+int t_isspace(const char* ptr);
+int t_isalpha(const char* ptr);
+int t_isalnum(const char* ptr);
+int t_isprint(const char* ptr);
+char* lowerstr(const char* str);
+char* lowerstr_with_len(const char* str, int len);
+void get_share_path(const char* my_exec_path, char* ret_path);
+char* get_tsearch_config_filename(const char* basename, const char* extension);
+bool tsearch_readline_begin(tsearch_readline_state* stp, const char* filename);
+char* tsearch_readline(tsearch_readline_state* stp);
+void tsearch_readline_end(tsearch_readline_state* stp);
+
+typedef struct nameData {
+	char data[NAMEDATALEN];
+} NameData;
+
+// These fold identifiers the same as the Postgres parser, which lowers ASCII letters, along with the letters of
+// single-byte encodings, and truncates identifiers to fit within NAMEDATALEN.
+char* downcase_truncate_identifier(const char* ident, int len, bool warn);
+char* downcase_identifier(const char* ident, int len, bool warn, bool truncate);
+void truncate_identifier(char* ident, int len, bool warn);
+
+#define UUID_LEN 16
+
+// pg_uuid_t is the value of a uuid, which is passed by reference and holds its bytes in network order.
+typedef struct pg_uuid_t {
+	unsigned char data[UUID_LEN];
+} pg_uuid_t;
+
+#define DatumGetUUIDP(X) ((pg_uuid_t*)(X))
+#define UUIDPGetDatum(X) ((Datum)(X))
+
+bool pg_strong_random(void* buf, size_t len);
+
+// FormData_pg_attribute describes a single attribute of a tuple. The layout matches the fixed part of pg_attribute as of
+// Postgres 15, as extensions read attributes from tuple descriptors directly.
+typedef struct FormData_pg_attribute {
+	Oid      attrelid;
+	NameData attname;
+	Oid      atttypid;
+	int32_t  attstattarget;
+	int16_t  attlen;
+	int16_t  attnum;
+	int32_t  attndims;
+	int32_t  attcacheoff;
+	int32_t  atttypmod;
+	bool     attbyval;
+	char     attalign;
+	char     attstorage;
+	char     attcompression;
+	bool     attnotnull;
+	bool     atthasdef;
+	bool     atthasmissing;
+	char     attidentity;
+	char     attgenerated;
+	bool     attisdropped;
+	bool     attislocal;
+	int32_t  attinhcount;
+	Oid      attcollation;
+} FormData_pg_attribute;
+
+typedef FormData_pg_attribute* Form_pg_attribute;
+
+// TupleDescData describes the attributes of a tuple. Attributes are allocated with the descriptor, so it must be sized
+// with TupleDescSize rather than sizeof.
+typedef struct TupleDescData {
+	int                   natts;
+	Oid                   tdtypeid;
+	int32_t               tdtypmod;
+	int                   tdrefcount;
+	struct TupleConstr*   constr;
+	FormData_pg_attribute attrs[FLEXIBLE_ARRAY_MEMBER];
+} TupleDescData;
+
+typedef TupleDescData* TupleDesc;
+
+#define TupleDescSize(natts)      (offsetof(TupleDescData, attrs) + (natts) * sizeof(FormData_pg_attribute))
+#define TupleDescAttr(tupdesc, i) (&(tupdesc)->attrs[(i)])
+
+TupleDesc CreateTemplateTupleDesc(int natts);
+TupleDesc CreateTupleDescCopy(TupleDesc tupdesc);
+TupleDesc BlessTupleDesc(TupleDesc tupdesc);
+TupleDesc lookup_rowtype_tupdesc(Oid type_id, int32_t typmod);
+
+#define RECORDOID               2249
+#define DEFAULT_COLLATION_OID   100
+#define C_COLLATION_OID         950
+#define POSIX_COLLATION_OID     951
+
+#define MAXALIGN(LEN)           (((uintptr_t)(LEN) + 7) & ~((uintptr_t)7))
+#define BITMAPLEN(NATTS)        (((int)(NATTS) + 7) / 8)
+
+// ItemPointerData identifies a tuple by its block and offset. Tuples that are formed in memory have an invalid pointer.
+typedef struct ItemPointerData {
+	uint16_t bi_hi;
+	uint16_t bi_lo;
+	uint16_t ip_posid;
+} ItemPointerData;
+
+// DatumTupleFields are the header fields of a tuple that is being used as a composite Datum.
+typedef struct DatumTupleFields {
+	int32_t datum_len_;
+	int32_t datum_typmod;
+	Oid     datum_typeid;
+} DatumTupleFields;
+
+// HeapTupleHeaderData is the header of a tuple, which is followed by its null bitmap and its data. The transaction
+// fields that precede t_ctid in Postgres are only used for tuples on disk, so only the Datum fields are defined here.
+typedef struct HeapTupleHeaderData {
+	union {
+		uint32_t         t_heap[3];
+		DatumTupleFields t_datum;
+	} t_choice;
+	ItemPointerData t_ctid;
+	uint16_t        t_infomask2;
+	uint16_t        t_infomask;
+	uint8_t         t_hoff;
+	uint8_t         t_bits[FLEXIBLE_ARRAY_MEMBER];
+} HeapTupleHeaderData;
+
+typedef HeapTupleHeaderData* HeapTupleHeader;
+
+#define SizeofHeapTupleHeader offsetof(HeapTupleHeaderData, t_bits)
+
+// att_align returns the offset aligned to the given typalign.
+static inline size_t att_align(size_t offset, char align) {
+	switch (align) {
+	case 's':
+		return (offset + 1) & ~((size_t)1);
+	case 'i':
+		return (offset + 3) & ~((size_t)3);
+	case 'd':
+		return (offset + 7) & ~((size_t)7);
+	default:
+		return offset;
+	}
+}
+
+// ArrayType is the header of an array, which is followed by the length and lower bound of each dimension, then the null
+// bitmap when dataoffset is nonzero, and then the elements. Elements are aligned to their type's alignment relative to
+// the start of the array.
+typedef struct ArrayType {
+	int32_t vl_len_;
+	int     ndim;
+	int32_t dataoffset;
+	Oid     elemtype;
+} ArrayType;
+
+#define MAXDIM 6
+
+#define ARR_SIZE(a)             VARSIZE_4B(a)
+#define ARR_NDIM(a)             ((a)->ndim)
+#define ARR_HASNULL(a)          ((a)->dataoffset != 0)
+#define ARR_ELEMTYPE(a)         ((a)->elemtype)
+#define ARR_DIMS(a)             ((int*)(((char*)(a)) + sizeof(ArrayType)))
+#define ARR_LBOUND(a)           ((int*)(((char*)(a)) + sizeof(ArrayType) + sizeof(int) * ARR_NDIM(a)))
+#define ARR_NULLBITMAP(a) \
+	(ARR_HASNULL(a) ? (uint8_t*)(((char*)(a)) + sizeof(ArrayType) + 2 * sizeof(int) * ARR_NDIM(a)) : (uint8_t*)NULL)
+#define ARR_OVERHEAD_NONULLS(ndims) MAXALIGN(sizeof(ArrayType) + 2 * sizeof(int) * (ndims))
+#define ARR_OVERHEAD_WITHNULLS(ndims, nitems) \
+	MAXALIGN(sizeof(ArrayType) + 2 * sizeof(int) * (ndims) + ((nitems) + 7) / 8)
+#define ARR_DATA_OFFSET(a)      (ARR_HASNULL(a) ? (size_t)(a)->dataoffset : ARR_OVERHEAD_NONULLS(ARR_NDIM(a)))
+#define ARR_DATA_PTR(a)         (((char*)(a)) + ARR_DATA_OFFSET(a))
+
+ArrayType* construct_array(Datum* elems, int nelems, Oid elmtype, int elmlen, bool elmbyval, char elmalign);
+ArrayType* construct_md_array(Datum* elems, bool* nulls, int ndims, int* dims, int* lbs, Oid elmtype, int elmlen,
+	bool elmbyval, char elmalign);
+ArrayType* construct_array_builtin(Datum* elems, int nelems, Oid elmtype);
+ArrayType* construct_empty_array(Oid elmtype);
+void deconstruct_array(ArrayType* array, Oid elmtype, int elmlen, bool elmbyval, char elmalign, Datum** elemsp,
+	bool** nullsp, int* nelemsp);
+void deconstruct_array_builtin(ArrayType* array, Oid elmtype, Datum** elemsp, bool** nullsp, int* nelemsp);
+int ArrayGetNItems(int ndim, const int* dims);
+bool array_contains_nulls(ArrayType* array);
+
+// Numeric is a numeric value, which is a varlena whose digits are stored in base 10000. Its layout matches Postgres, as
+// extensions may inspect it through the macros of numeric.h, but it is only built and read by the shim's Go code.
+typedef struct NumericData* Numeric;
+
+Numeric int64_to_numeric(int64_t v);
+bool numeric_is_nan(Numeric num);
+bool numeric_is_inf(Numeric num);
+
+// Timestamps are microseconds since midnight on January 1, 2000 UTC, while dates are days since the same epoch.
+typedef int64_t Timestamp;
+typedef int64_t TimestampTz;
+typedef int64_t TimeOffset;
+typedef int32_t DateADT;
+typedef int64_t pg_time_t;
+
+// Interval is a span of time, whose months and days are kept apart from its time as their lengths vary.
+typedef struct Interval {
+	TimeOffset time;
+	int32_t    day;
+	int32_t    month;
+} Interval;
+
+#define DT_NOBEGIN           INT64_MIN
+#define DT_NOEND             INT64_MAX
+#define POSTGRES_EPOCH_JDATE 2451545
+#define UNIX_EPOCH_JDATE     2440588
+#define SECS_PER_DAY         86400
+#define USECS_PER_SEC        INT64_C(1000000)
+
+TimestampTz GetCurrentTimestamp(void);
+pg_time_t timestamptz_to_time_t(TimestampTz t);
+TimestampTz time_t_to_timestamptz(pg_time_t tm);
+const char* timestamptz_to_str(TimestampTz t);
+void TimestampDifference(TimestampTz start_time, TimestampTz stop_time, long* secs, int* microsecs);
+bool TimestampDifferenceExceeds(TimestampTz start_time, TimestampTz stop_time, int msec);
+
+// JEntry describes a child of a jsonb container: its type, and either its length or the end offset of its data.
+typedef uint32_t JEntry;
+
+#define JENTRY_OFFLENMASK    0x0FFFFFFF
+#define JENTRY_TYPEMASK      0x70000000
+#define JENTRY_HAS_OFF       0x80000000
+#define JENTRY_ISSTRING      0x00000000
+#define JENTRY_ISNUMERIC     0x10000000
+#define JENTRY_ISBOOL_FALSE  0x20000000
+#define JENTRY_ISBOOL_TRUE   0x30000000
+#define JENTRY_ISNULL        0x40000000
+#define JENTRY_ISCONTAINER   0x50000000
+#define JBE_OFFLENFLD(je_)   ((je_) & JENTRY_OFFLENMASK)
+#define JBE_HAS_OFF(je_)     (((je_) & JENTRY_HAS_OFF) != 0)
+
+// JsonbContainer is an array or object within a jsonb. Objects hold an entry for each key, followed by an entry for
+// each value, while a scalar at the top level is held within an array of one element.
+typedef struct JsonbContainer {
+	uint32_t header;
+	JEntry   children[FLEXIBLE_ARRAY_MEMBER];
+} JsonbContainer;
+
+#define JB_CMASK   0x0FFFFFFF
+#define JB_FSCALAR 0x10000000
+#define JB_FOBJECT 0x20000000
+#define JB_FARRAY  0x40000000
+
+#define JsonContainerSize(jc)     ((jc)->header & JB_CMASK)
+#define JsonContainerIsScalar(jc) (((jc)->header & JB_FSCALAR) != 0)
+#define JsonContainerIsObject(jc) (((jc)->header & JB_FOBJECT) != 0)
+#define JsonContainerIsArray(jc)  (((jc)->header & JB_FARRAY) != 0)
+
+typedef struct Jsonb {
+	int32_t        vl_len_;
+	JsonbContainer root;
+} Jsonb;
+
+#define JB_ROOT_COUNT(jbp_)     (*(uint32_t*)VARDATA_ANY(jbp_) & JB_CMASK)
+#define JB_ROOT_IS_SCALAR(jbp_) ((*(uint32_t*)VARDATA_ANY(jbp_) & JB_FSCALAR) != 0)
+#define JB_ROOT_IS_OBJECT(jbp_) ((*(uint32_t*)VARDATA_ANY(jbp_) & JB_FOBJECT) != 0)
+#define JB_ROOT_IS_ARRAY(jbp_)  ((*(uint32_t*)VARDATA_ANY(jbp_) & JB_FARRAY) != 0)
+
+enum jbvType {
+	jbvNull = 0x0,
+	jbvString,
+	jbvNumeric,
+	jbvBool,
+	jbvArray = 0x10,
+	jbvObject,
+	jbvBinary,
+	jbvDatetime = 0x20,
+};
+
+#define IsAJsonbScalar(jsonbval) \
+	(((jsonbval)->type >= jbvNull && (jsonbval)->type <= jbvBool) || (jsonbval)->type == jbvDatetime)
+
+typedef struct JsonbPair JsonbPair;
+
+// JsonbValue is a jsonb value that has been read from a container. Nested arrays and objects are read as jbvBinary,
+// which points to their container.
+typedef struct JsonbValue {
+	enum jbvType type;
+	union {
+		Numeric numeric;
+		bool    boolean;
+		struct {
+			int   len;
+			char* val;
+		} string;
+		struct {
+			int                nElems;
+			struct JsonbValue* elems;
+			bool               rawScalar;
+		} array;
+		struct {
+			int        nPairs;
+			JsonbPair* pairs;
+		} object;
+		struct {
+			int             len;
+			JsonbContainer* data;
+		} binary;
+		struct {
+			Datum   value;
+			Oid     typid;
+			int32_t typmod;
+			int     tz;
+		} datetime;
+	} val;
+} JsonbValue;
+
+struct JsonbPair {
+	JsonbValue key;
+	JsonbValue value;
+	uint32_t   order;
+};
+
+typedef enum {
+	WJB_DONE,
+	WJB_KEY,
+	WJB_VALUE,
+	WJB_ELEM,
+	WJB_BEGIN_ARRAY,
+	WJB_END_ARRAY,
+	WJB_BEGIN_OBJECT,
+	WJB_END_OBJECT,
+} JsonbIteratorToken;
+
+typedef enum {
+	JBI_ARRAY_START,
+	JBI_ARRAY_ELEM,
+	JBI_OBJECT_START,
+	JBI_OBJECT_KEY,
+	JBI_OBJECT_VALUE,
+} JsonbIterState;
+
+// JsonbIterator walks the values of a container, along with those of its nested containers unless they're skipped.
+typedef struct JsonbIterator {
+	JsonbContainer*       container;
+	uint32_t              nElems;
+	bool                  isScalar;
+	JEntry*               children;
+	char*                 dataProper;
+	int                   curIndex;
+	uint32_t              curDataOffset;
+	uint32_t              curValueOffset;
+	JsonbIterState        state;
+	struct JsonbIterator* parent;
+} JsonbIterator;
+
+JsonbValue* findJsonbValueFromContainer(JsonbContainer* container, uint32_t flags, JsonbValue* key);
+JsonbValue* getKeyJsonValueFromContainer(JsonbContainer* container, const char* keyVal, int keyLen, JsonbValue* res);
+JsonbValue* getIthJsonbValueFromContainer(JsonbContainer* container, uint32_t i);
+bool JsonbExtractScalar(JsonbContainer* jbc, JsonbValue* res);
+JsonbIterator* JsonbIteratorInit(JsonbContainer* container);
+JsonbIteratorToken JsonbIteratorNext(JsonbIterator** it, JsonbValue* val, bool skipNested);
+#define HEAP_HASNULL          0x0001
+#define HEAP_HASVARWIDTH      0x0002
+#define HEAP_NATTS_MASK       0x07FF
+
+// HeapTupleData points to a tuple, and is allocated immediately before the tuple by heap_form_tuple.
+typedef struct HeapTupleData {
+	uint32_t        t_len;
+	ItemPointerData t_self;
+	Oid             t_tableOid;
+	HeapTupleHeader t_data;
+} HeapTupleData;
+
+typedef HeapTupleData* HeapTuple;
+
+#define HEAPTUPLESIZE MAXALIGN(sizeof(HeapTupleData))
+
+void heap_deform_tuple(HeapTuple tuple, TupleDesc tupleDesc, Datum* values, bool* isnull);
+
+// NodeTag identifies the type of a node. Postgres numbers its tags by the order in which nodes are defined, which changes
+// between versions, so only the nodes that the shim gives to extensions are defined here.
+typedef int NodeTag;
+
+#define T_ExprContext      400
+#define T_ReturnSetInfo    401
+#define T_TupleTableSlot   402
+#define T_TriggerData      403
+#define T_AggState         404
+#define T_WindowObjectData 405
+#define T_List             406
+#define T_IntList          407
+#define T_OidList          408
+#define T_XidList          409
+#define IsA(nodeptr, _type_) (((const NodeTag*)(nodeptr))[0] == T_##_type_)
+
+typedef uint32_t TransactionId;
+
+// ListCell is a single element of a List, which holds a pointer, an integer, an Oid, or a TransactionId depending on
+// the tag of the List.
+typedef union ListCell {
+	void*         ptr_value;
+	int           int_value;
+	Oid           oid_value;
+	TransactionId xid_value;
+} ListCell;
+
+// List matches the array-based layout of Postgres 13 and later, as the macros that extensions use to traverse a List
+// are compiled into the extension. The cells start within the same allocation as the header, and are moved to their
+// own allocation within the same memory context once the List outgrows them. An empty List is always NIL.
+typedef struct List {
+	NodeTag   type;
+	int       length;
+	int       max_length;
+	ListCell* elements;
+	ListCell  initial_elements[FLEXIBLE_ARRAY_MEMBER];
+} List;
+
+#define NIL ((List*)NULL)
+
+static inline int list_length(const List* l) {
+	return l ? l->length : 0;
+}
+
+static inline ListCell* list_head(const List* l) {
+	return l ? &l->elements[0] : NULL;
+}
+
+static inline ListCell* list_last_cell(const List* l) {
+	return &l->elements[l->length - 1];
+}
+
+static inline ListCell* list_nth_cell(const List* list, int n) {
+	return &list->elements[n];
+}
+
+static inline ListCell* lnext(const List* l, const ListCell* c) {
+	c++;
+	return (c < &l->elements[l->length]) ? (ListCell*)c : NULL;
+}
+
+#define lfirst(lc)      ((lc)->ptr_value)
+#define lfirst_int(lc)  ((lc)->int_value)
+#define lfirst_oid(lc)  ((lc)->oid_value)
+#define lfirst_xid(lc)  ((lc)->xid_value)
+#define lfirst_node(type, lc) ((type*)lfirst(lc))
+
+#define linitial(l)     lfirst(list_nth_cell(l, 0))
+#define linitial_int(l) lfirst_int(list_nth_cell(l, 0))
+#define linitial_oid(l) lfirst_oid(list_nth_cell(l, 0))
+#define lsecond(l)      lfirst(list_nth_cell(l, 1))
+#define lsecond_int(l)  lfirst_int(list_nth_cell(l, 1))
+#define lsecond_oid(l)  lfirst_oid(list_nth_cell(l, 1))
+#define lthird(l)       lfirst(list_nth_cell(l, 2))
+#define llast(l)        lfirst(list_last_cell(l))
+#define llast_int(l)    lfirst_int(list_last_cell(l))
+#define llast_oid(l)    lfirst_oid(list_last_cell(l))
+
+static inline void* list_nth(const List* list, int n) {
+	return lfirst(list_nth_cell(list, n));
+}
+
+static inline int list_nth_int(const List* list, int n) {
+	return lfirst_int(list_nth_cell(list, n));
+}
+
+static inline Oid list_nth_oid(const List* list, int n) {
+	return lfirst_oid(list_nth_cell(list, n));
+}
+
+static inline int list_cell_number(const List* l, const ListCell* c) {
+	return (int)(c - l->elements);
+}
+
+// ForEachState holds the position of a foreach loop, which allows the current cell to be deleted during the loop
+// through foreach_delete_current.
+typedef struct ForEachState {
+	const List* l;
+	int         i;
+} ForEachState;
+
+#define foreach(cell, lst) \
+	for (ForEachState cell##__state = {(lst), 0}; \
+		 (cell##__state.l != NIL && cell##__state.i < cell##__state.l->length) ? \
+		 (cell = &cell##__state.l->elements[cell##__state.i], true) : (cell = NULL, false); \
+		 cell##__state.i++)
+#define foreach_current_index(cell) (cell##__state.i)
+#define foreach_delete_current(lst, cell) \
+	(cell##__state.i--, (List*)(cell##__state.l = list_delete_cell(lst, cell)))
+#define for_each_cell(cell, lst, initcell) \
+	for (ForEachState cell##__state = {(lst), (initcell) ? list_cell_number(lst, initcell) : list_length(lst)}; \
+		 (cell##__state.l != NIL && cell##__state.i < cell##__state.l->length) ? \
+		 (cell = &cell##__state.l->elements[cell##__state.i], true) : (cell = NULL, false); \
+		 cell##__state.i++)
+
+#define list_make_ptr_cell(v) ((ListCell){.ptr_value = (v)})
+#define list_make_int_cell(v) ((ListCell){.int_value = (v)})
+#define list_make_oid_cell(v) ((ListCell){.oid_value = (v)})
+
+#define list_make1(x1) list_make1_impl(T_List, list_make_ptr_cell(x1))
+#define list_make2(x1, x2) list_make2_impl(T_List, list_make_ptr_cell(x1), list_make_ptr_cell(x2))
+#define list_make3(x1, x2, x3) \
+	list_make3_impl(T_List, list_make_ptr_cell(x1), list_make_ptr_cell(x2), list_make_ptr_cell(x3))
+#define list_make4(x1, x2, x3, x4) \
+	list_make4_impl(T_List, list_make_ptr_cell(x1), list_make_ptr_cell(x2), list_make_ptr_cell(x3), \
+					list_make_ptr_cell(x4))
+#define list_make5(x1, x2, x3, x4, x5) \
+	list_make5_impl(T_List, list_make_ptr_cell(x1), list_make_ptr_cell(x2), list_make_ptr_cell(x3), \
+					list_make_ptr_cell(x4), list_make_ptr_cell(x5))
+#define list_make1_int(x1) list_make1_impl(T_IntList, list_make_int_cell(x1))
+#define list_make2_int(x1, x2) list_make2_impl(T_IntList, list_make_int_cell(x1), list_make_int_cell(x2))
+#define list_make3_int(x1, x2, x3) \
+	list_make3_impl(T_IntList, list_make_int_cell(x1), list_make_int_cell(x2), list_make_int_cell(x3))
+#define list_make1_oid(x1) list_make1_impl(T_OidList, list_make_oid_cell(x1))
+#define list_make2_oid(x1, x2) list_make2_impl(T_OidList, list_make_oid_cell(x1), list_make_oid_cell(x2))
+#define list_make3_oid(x1, x2, x3) \
+	list_make3_impl(T_OidList, list_make_oid_cell(x1), list_make_oid_cell(x2), list_make_oid_cell(x3))
+
+typedef int (*list_sort_comparator) (const ListCell* a, const ListCell* b);
+
+List* list_make1_impl(NodeTag t, ListCell datum1);
+List* list_make2_impl(NodeTag t, ListCell datum1, ListCell datum2);
+List* list_make3_impl(NodeTag t, ListCell datum1, ListCell datum2, ListCell datum3);
+List* list_make4_impl(NodeTag t, ListCell datum1, ListCell datum2, ListCell datum3, ListCell datum4);
+List* list_make5_impl(NodeTag t, ListCell datum1, ListCell datum2, ListCell datum3, ListCell datum4, ListCell datum5);
+List* lappend(List* list, void* datum);
+List* lappend_int(List* list, int datum);
+List* lappend_oid(List* list, Oid datum);
+List* lappend_xid(List* list, TransactionId datum);
+List* lcons(void* datum, List* list);
+List* lcons_int(int datum, List* list);
+List* lcons_oid(Oid datum, List* list);
+List* list_insert_nth(List* list, int pos, void* datum);
+List* list_insert_nth_int(List* list, int pos, int datum);
+List* list_insert_nth_oid(List* list, int pos, Oid datum);
+List* list_concat(List* list1, const List* list2);
+List* list_concat_copy(const List* list1, const List* list2);
+List* list_truncate(List* list, int new_size);
+bool list_member_ptr(const List* list, const void* datum);
+bool list_member_int(const List* list, int datum);
+bool list_member_oid(const List* list, Oid datum);
+bool list_member_xid(const List* list, TransactionId datum);
+List* list_delete_ptr(List* list, void* datum);
+List* list_delete_int(List* list, int datum);
+List* list_delete_oid(List* list, Oid datum);
+List* list_delete_nth_cell(List* list, int n);
+List* list_delete_cell(List* list, ListCell* cell);
+List* list_delete_first(List* list);
+List* list_delete_last(List* list);
+List* list_delete_first_n(List* list, int n);
+List* list_append_unique_ptr(List* list, void* datum);
+List* list_append_unique_int(List* list, int datum);
+List* list_append_unique_oid(List* list, Oid datum);
+List* list_copy(const List* oldlist);
+List* list_copy_head(const List* oldlist, int len);
+List* list_copy_tail(const List* oldlist, int nskip);
+void list_free(List* list);
+void list_free_deep(List* list);
+void list_sort(List* list, list_sort_comparator cmp);
+int list_int_cmp(const ListCell* p1, const ListCell* p2);
+int list_oid_cmp(const ListCell* p1, const ListCell* p2);
+
+typedef void (*ExprContextCallbackFunction) (Datum arg);
+
+// ExprContext_CB is a callback that is run when an ExprContext is shut down.
+typedef struct ExprContext_CB {
+	struct ExprContext_CB*      next;
+	ExprContextCallbackFunction function;
+	Datum                       arg;
+} ExprContext_CB;
+
+// ExprContext is the context in which a function is evaluated. Only the memory contexts and callbacks are used by the
+// shim, but the other fields are kept so that the layout matches Postgres.
+typedef struct ExprContext {
+	NodeTag         type;
+	void*           ecxt_scantuple;
+	void*           ecxt_innertuple;
+	void*           ecxt_outertuple;
+	MemoryContext   ecxt_per_query_memory;
+	MemoryContext   ecxt_per_tuple_memory;
+	void*           ecxt_param_exec_vals;
+	void*           ecxt_param_list_info;
+	Datum*          ecxt_aggvalues;
+	bool*           ecxt_aggnulls;
+	Datum           caseValue_datum;
+	bool            caseValue_isNull;
+	Datum           domainValue_datum;
+	bool            domainValue_isNull;
+	void*           ecxt_estate;
+	ExprContext_CB* ecxt_callbacks;
+} ExprContext;
+
+// These are the modes in which a set-returning function may return its results.
+#define SFRM_ValuePerCall          0x01
+#define SFRM_Materialize           0x02
+#define SFRM_Materialize_Random    0x04
+#define SFRM_Materialize_Preferred 0x08
+
+typedef int SetFunctionReturnMode;
+
+// TypeFuncClass is the classification of a function's result type.
+typedef enum TypeFuncClass {
+	TYPEFUNC_SCALAR,
+	TYPEFUNC_COMPOSITE,
+	TYPEFUNC_COMPOSITE_DOMAIN,
+	TYPEFUNC_RECORD,
+	TYPEFUNC_OTHER
+} TypeFuncClass;
+
+// These are the flags of InitMaterializedSRF.
+#define MAT_SRF_USE_EXPECTED_DESC 0x01
+#define MAT_SRF_BLESS             0x02
+
+// ExprDoneCond is set by a set-returning function to report whether it returned a value, and whether more remain.
+typedef enum ExprDoneCond {
+	ExprSingleResult,
+	ExprMultipleResult,
+	ExprEndResult
+} ExprDoneCond;
+
+// ReturnSetInfo is given to set-returning functions through the call info's resultinfo.
+typedef struct ReturnSetInfo {
+	NodeTag                 type;
+	ExprContext*            econtext;
+	TupleDesc               expectedDesc;
+	int                     allowedModes;
+	SetFunctionReturnMode   returnMode;
+	ExprDoneCond            isDone;
+	struct Tuplestorestate* setResult;
+	TupleDesc               setDesc;
+} ReturnSetInfo;
+
+// FuncCallContext is the state of a set-returning function that returns one value per call, which persists across its
+// calls within fn_extra.
+typedef struct FuncCallContext {
+	uint64_t              call_cntr;
+	uint64_t              max_calls;
+	void*                 user_fctx;
+	struct AttInMetadata* attinmeta;
+	MemoryContext         multi_call_memory_ctx;
+	TupleDesc             tuple_desc;
+} FuncCallContext;
+
+// Tuplestorestate is a tuplestore, whose rows are kept by the Go side of the shim. By-reference values are copied into
+// the memory context that was current when the tuplestore was created, and the rows are released when it is reset.
+typedef struct Tuplestorestate {
+	MemoryContext         context;
+	int                   natts;
+	int64_t               read_pos;
+	bool                  eof_reached;
+	MemoryContextCallback release_cb;
+} Tuplestorestate;
+
+// TupleTableSlot holds a single tuple as an array of values. The shim only creates virtual slots, so all slot types
+// share the same operations.
+typedef struct TupleTableSlot {
+	NodeTag                         type;
+	uint16_t                        tts_flags;
+	int16_t                         tts_nvalid;
+	const struct TupleTableSlotOps* tts_ops;
+	TupleDesc                       tts_tupleDescriptor;
+	Datum*                          tts_values;
+	bool*                           tts_isnull;
+	MemoryContext                   tts_mcxt;
+	uint16_t                        tts_tid[3];
+	Oid                             tts_tableOid;
+} TupleTableSlot;
+
+#define TTS_FLAG_EMPTY (1 << 1)
+
+// TupleTableSlotOps are the operations of a slot type, which extensions call through inline functions such as
+// ExecClearTuple. The layout matches Postgres 15.
+typedef struct TupleTableSlotOps {
+	size_t base_slot_size;
+	void   (*init)(TupleTableSlot* slot);
+	void   (*release)(TupleTableSlot* slot);
+	void   (*clear)(TupleTableSlot* slot);
+	void   (*getsomeattrs)(TupleTableSlot* slot, int natts);
+	Datum  (*getsysattr)(TupleTableSlot* slot, int attnum, bool* isnull);
+	void   (*materialize)(TupleTableSlot* slot);
+	void   (*copyslot)(TupleTableSlot* dstslot, TupleTableSlot* srcslot);
+	void*  (*get_heap_tuple)(TupleTableSlot* slot);
+	void*  (*get_minimal_tuple)(TupleTableSlot* slot);
+	void*  (*copy_heap_tuple)(TupleTableSlot* slot);
+	void*  (*copy_minimal_tuple)(TupleTableSlot* slot);
+} TupleTableSlotOps;
+
+Tuplestorestate* tuplestore_begin_heap(bool randomAccess, bool interXact, int maxKBytes);
+void RegisterExprContextCallback(ExprContext* econtext, ExprContextCallbackFunction function, Datum arg);
+void UnregisterExprContextCallback(ExprContext* econtext, ExprContextCallbackFunction function, Datum arg);
+
+// FormData_pg_class describes a relation. The layout matches the fixed part of pg_class as of Postgres 15.
+typedef struct FormData_pg_class {
+	Oid      oid;
+	NameData relname;
+	Oid      relnamespace;
+	Oid      reltype;
+	Oid      reloftype;
+	Oid      relowner;
+	Oid      relam;
+	Oid      relfilenode;
+	Oid      reltablespace;
+	int32_t  relpages;
+	float    reltuples;
+	int32_t  relallvisible;
+	Oid      reltoastrelid;
+	bool     relhasindex;
+	bool     relisshared;
+	char     relpersistence;
+	char     relkind;
+	int16_t  relnatts;
+	int16_t  relchecks;
+	bool     relhasrules;
+	bool     relhastriggers;
+	bool     relhassubclass;
+	bool     relrowsecurity;
+	bool     relforcerowsecurity;
+	bool     relispopulated;
+	char     relreplident;
+	bool     relispartition;
+	Oid      relrewrite;
+	uint32_t relfrozenxid;
+	uint32_t relminmxid;
+} FormData_pg_class;
+
+typedef FormData_pg_class* Form_pg_class;
+
+#define RELKIND_RELATION         'r'
+#define RELPERSISTENCE_PERMANENT 'p'
+
+// RelFileNode identifies the physical storage of a relation.
+typedef struct RelFileNode {
+	Oid spcNode;
+	Oid dbNode;
+	Oid relNode;
+} RelFileNode;
+
+// RelationData describes an open relation. Only the leading fields of the Postgres 15 layout are defined, which are the
+// ones that are read by the RelationGet macros, as the remaining fields describe storage and caches that the shim
+// doesn't have.
+typedef struct RelationData {
+	RelFileNode   rd_node;
+	void*         rd_smgr;
+	int           rd_refcnt;
+	int           rd_backend;
+	bool          rd_islocaltemp;
+	bool          rd_isnailed;
+	bool          rd_isvalid;
+	bool          rd_indexvalid;
+	bool          rd_statvalid;
+	uint32_t      rd_createSubid;
+	uint32_t      rd_newRelfilenodeSubid;
+	uint32_t      rd_firstRelfilenodeSubid;
+	uint32_t      rd_droppedSubid;
+	Form_pg_class rd_rel;
+	TupleDesc     rd_att;
+	Oid           rd_id;
+} RelationData;
+
+typedef RelationData* Relation;
+
+// Trigger describes a trigger as it is defined in pg_trigger. The layout matches Postgres 15.
+typedef struct Trigger {
+	Oid      tgoid;
+	char*    tgname;
+	Oid      tgfoid;
+	int16_t  tgtype;
+	char     tgenabled;
+	bool     tgisinternal;
+	bool     tgisclone;
+	Oid      tgconstrrelid;
+	Oid      tgconstrindid;
+	Oid      tgconstraint;
+	bool     tgdeferrable;
+	bool     tginitdeferred;
+	int16_t  tgnargs;
+	int16_t  tgnattr;
+	int16_t* tgattr;
+	char**   tgargs;
+	char*    tgqual;
+	char*    tgoldtable;
+	char*    tgnewtable;
+} Trigger;
+
+typedef uint32_t TriggerEvent;
+
+// These are the bits of a TriggerEvent, which describe the operation that fired the trigger and when it was fired.
+#define TRIGGER_EVENT_INSERT     0x00
+#define TRIGGER_EVENT_DELETE     0x01
+#define TRIGGER_EVENT_UPDATE     0x02
+#define TRIGGER_EVENT_TRUNCATE   0x03
+#define TRIGGER_EVENT_OPMASK     0x03
+#define TRIGGER_EVENT_ROW        0x04
+#define TRIGGER_EVENT_BEFORE     0x08
+#define TRIGGER_EVENT_AFTER      0x00
+#define TRIGGER_EVENT_INSTEAD    0x10
+#define TRIGGER_EVENT_TIMINGMASK 0x18
+
+// TriggerData is given to trigger functions through the call info's context. The layout matches Postgres 15.
+typedef struct TriggerData {
+	NodeTag          type;
+	TriggerEvent     tg_event;
+	Relation         tg_relation;
+	HeapTuple        tg_trigtuple;
+	HeapTuple        tg_newtuple;
+	Trigger*         tg_trigger;
+	TupleTableSlot*  tg_trigslot;
+	TupleTableSlot*  tg_newslot;
+	Tuplestorestate* tg_oldtable;
+	Tuplestorestate* tg_newtable;
+	const void*      tg_updatedcols;
+} TriggerData;
+
+// AggState is the state of a single group of an aggregate, which is given to its transition and final functions through
+// the call info's context. The state is opaque to extensions, which only give it to functions such as
+// AggCheckCallContext, so it only holds what the shim needs.
+typedef struct AggState {
+	NodeTag       type;
+	// aggcontext holds the transition value, and lives until the group is finished.
+	MemoryContext aggcontext;
+	// tmpcontext is current during each call, and is reset before the next one.
+	MemoryContext tmpcontext;
+	MemoryContext savedcontext;
+	// econtext holds the callbacks that are registered through AggRegisterCallback.
+	ExprContext*  econtext;
+} AggState;
+
+// These are returned by AggCheckCallContext to report how a function is being called.
+#define AGG_CONTEXT_AGGREGATE 1
+#define AGG_CONTEXT_WINDOW    2
+
+Datum pgext_shutdown_econtext(void* arg);
+
+// PgExtWindowPartition is registered by the host to provide the rows of a window partition, which are identified by
+// their zero-based position within the partition. Each function is given the handle of the partition, and returns false
+// when the host could not provide the value, in which case the host records its own error.
+typedef struct PgExtWindowPartition {
+	bool (*get_arg)(uintptr_t handle, int argno, int64_t pos, Datum* value, bool* isnull);
+	bool (*rows_are_peers)(uintptr_t handle, int64_t pos1, int64_t pos2, bool* peers);
+	bool (*frame)(uintptr_t handle, int64_t pos, int64_t* headpos, int64_t* tailpos);
+} PgExtWindowPartition;
+
+// WindowObjectData is given to window functions through the call info's context while they're called for each row of a
+// partition. The object is opaque to extensions, which only give it to the WinGet functions, so it only holds what the
+// shim needs.
+typedef struct WindowObjectData {
+	NodeTag               type;
+	PgExtWindowPartition* partition;
+	uintptr_t             handle;
+	int                   numargs;
+	int64_t               rowcount;
+	int64_t               currentpos;
+	int64_t               markpos;
+	// frameheadpos and frametailpos are the bounds of the current row's frame, which are fetched once they're needed.
+	bool                  framevalid;
+	int64_t               frameheadpos;
+	int64_t               frametailpos;
+	void*                 localmem;
+	// partcontext lives until the partition is finished, while tmpcontext is current during each call and is reset
+	// before the next one.
+	MemoryContext         partcontext;
+	MemoryContext         tmpcontext;
+	MemoryContext         savedcontext;
+} WindowObjectData;
+
+typedef WindowObjectData* WindowObject;
+
+#define WindowObjectIsValid(winobj) ((winobj) != NULL && IsA(winobj, WindowObjectData))
+
+// These are the seek types of WinGetFuncArgInPartition and WinGetFuncArgInFrame.
+#define WINDOW_SEEK_CURRENT 0
+#define WINDOW_SEEK_HEAD    1
+#define WINDOW_SEEK_TAIL    2
+
+// These are the error codes that the SPI functions return, or set within SPI_result.
+#define SPI_ERROR_CONNECT       (-1)
+#define SPI_ERROR_COPY          (-2)
+#define SPI_ERROR_OPUNKNOWN     (-3)
+#define SPI_ERROR_UNCONNECTED   (-4)
+#define SPI_ERROR_CURSOR        (-5)
+#define SPI_ERROR_ARGUMENT      (-6)
+#define SPI_ERROR_PARAM         (-7)
+#define SPI_ERROR_TRANSACTION   (-8)
+#define SPI_ERROR_NOATTRIBUTE   (-9)
+#define SPI_ERROR_NOOUTFUNC     (-10)
+#define SPI_ERROR_TYPUNKNOWN    (-11)
+#define SPI_ERROR_REL_DUPLICATE (-12)
+#define SPI_ERROR_REL_NOT_FOUND (-13)
+
+// These are the success codes that the SPI functions return.
+#define SPI_OK_CONNECT           1
+#define SPI_OK_FINISH            2
+#define SPI_OK_FETCH             3
+#define SPI_OK_UTILITY           4
+#define SPI_OK_SELECT            5
+#define SPI_OK_SELINTO           6
+#define SPI_OK_INSERT            7
+#define SPI_OK_DELETE            8
+#define SPI_OK_UPDATE            9
+#define SPI_OK_CURSOR            10
+#define SPI_OK_INSERT_RETURNING  11
+#define SPI_OK_DELETE_RETURNING  12
+#define SPI_OK_UPDATE_RETURNING  13
+#define SPI_OK_REWRITTEN         14
+#define SPI_OK_REL_REGISTER      15
+#define SPI_OK_REL_UNREGISTER    16
+#define SPI_OK_TD_REGISTER       17
+#define SPI_OK_MERGE             18
+
+#define SPI_OPT_NONATOMIC (1 << 0)
+
+// SPITupleTable holds the rows that were returned by a query. The layout matches Postgres 15.
+typedef struct SPITupleTable {
+	TupleDesc     tupdesc;
+	HeapTuple*    vals;
+	uint64_t      numvals;
+	uint64_t      alloced;
+	MemoryContext tuptabcxt;
+	void*         next;
+	uint32_t      subid;
+} SPITupleTable;
+
+// SPIPlanPtr is a prepared query, which is opaque to extensions.
+typedef struct _SPI_plan* SPIPlanPtr;
+
+extern DLLEXPORT uint64_t       SPI_processed;
+extern DLLEXPORT SPITupleTable* SPI_tuptable;
+extern DLLEXPORT int            SPI_result;
+
+// PgExtSPIResult is filled in by the host's executor with the result of a query. The descriptor and rows are owned by
+// the host, which frees them once the shim releases the result's handle. Rows are stored one after another, with a
+// value for each attribute of the descriptor. When the query fails, message holds the error, and is freed by the shim.
+typedef struct PgExtSPIResult {
+	int       status;
+	uint64_t  processed;
+	TupleDesc tupdesc;
+	uint64_t  nrows;
+	Datum*    values;
+	bool*     isnull;
+	uintptr_t handle;
+	int       sqlerrcode;
+	char*     message;
+} PgExtSPIResult;
+
+// PgExtSPIExecutor is registered by the host to run the queries that extensions make through SPI. The output function
+// returns the text of a value, or the error when it returns false, which the shim frees in either case.
+typedef struct PgExtSPIExecutor {
+	void (*execute)(const char* query, int nargs, Oid* argtypes, Datum* values, bool* nulls, bool read_only,
+		int64_t tcount, PgExtSPIResult* result);
+	void (*release)(uintptr_t handle);
+	bool (*output)(Oid typeoid, Datum value, char** text);
+} PgExtSPIExecutor;
+
+void pgext_spi_unwind(struct PgExtSPIConnection* connection);
+void pgext_spi_free(PgExtBackendState* state);
+void pgext_release_resources(PgExtBackendState* state);
+
+HeapTuple heap_form_tuple(TupleDesc tupleDescriptor, Datum* values, bool* isnull);
+HeapTuple heap_copytuple(HeapTuple tuple);
+void heap_freetuple(HeapTuple htup);
+Datum heap_copy_tuple_as_datum(HeapTuple tuple, TupleDesc tupleDesc);
+Datum GetAttributeByNum(HeapTupleHeader tuple, int16_t attrno, bool* isNull);
+Datum GetAttributeByName(HeapTupleHeader tuple, const char* attname, bool* isNull);
+
+// PgExtFunctionLookup is registered by the host to look up functions by OID for fmgr_info. The lookup returns false if
+// no function has the given OID. Functions that the host implements in Go are given pgext_builtin_call as their
+// address, which passes each call to the host's call function. The call sets the message when it fails, which the shim
+// frees after raising it.
+typedef struct PgExtFunctionLookup {
+	bool  (*lookup)(Oid oid, FmgrInfo* finfo);
+	Datum (*call)(FunctionCallInfo fcinfo, int* sqlerrcode, char** message);
+} PgExtFunctionLookup;
+
+Datum pgext_builtin_call(FunctionCallInfo fcinfo);
+
+// PgExtTypeInfo describes a type of the catalog. Types come from the host's catalog provider, or from the built-in types
+// of the shim when there is no provider or it does not have the type. The OIDs of functions and operators are zero
+// when the type does not have them, and eq_proc is the function of the equality operator.
+typedef struct PgExtTypeInfo {
+	Oid     oid;
+	int16_t typlen;
+	bool    typbyval;
+	char    typalign;
+	char    typstorage;
+	char    typtype;
+	char    typcategory;
+	char    typdelim;
+	Oid     typcollation;
+	Oid     typelem;
+	Oid     typarray;
+	Oid     typrelid;
+	Oid     typbasetype;
+	int32_t typtypmod;
+	Oid     typinput;
+	Oid     typoutput;
+	Oid     typreceive;
+	Oid     typsend;
+	Oid     typmodout;
+	Oid     eq_opr;
+	Oid     lt_opr;
+	Oid     gt_opr;
+	Oid     eq_proc;
+	Oid     cmp_proc;
+	Oid     hash_proc;
+	char    typname[NAMEDATALEN];
+	char    nspname[NAMEDATALEN];
+} PgExtTypeInfo;
+
+// PgExtCatalogProvider is registered by the host to describe the types of its catalog. The lookup fills in the type
+// with the given OID, which the shim has zeroed, and returns false if the type does not exist.
+typedef struct PgExtCatalogProvider {
+	bool (*lookup_type)(Oid oid, PgExtTypeInfo* info);
+} PgExtCatalogProvider;
+
+bool pgext_lookup_type(Oid oid, PgExtTypeInfo* info);
+
+// PgExtAuthProvider is registered by the host to describe its roles. The name lookup writes the name of the role to the
+// buffer, which holds NAMEDATALEN bytes, and returns false if the role does not exist, while the OID lookup returns
+// InvalidOid. Without a provider, the bootstrap superuser is the only role.
+typedef struct PgExtAuthProvider {
+	bool (*is_superuser)(Oid roleid);
+	bool (*has_privs_of_role)(Oid member, Oid role);
+	bool (*role_name)(Oid roleid, char* name);
+	Oid  (*role_oid)(const char* name);
+} PgExtAuthProvider;
+
+#define BOOTSTRAP_SUPERUSERID         10
+#define SECURITY_LOCAL_USERID_CHANGE  0x0001
+#define SECURITY_RESTRICTED_OPERATION 0x0002
+#define SECURITY_NOFORCE_RLS          0x0004
+
+Oid GetUserId(void);
+Oid GetOuterUserId(void);
+Oid GetSessionUserId(void);
+Oid GetAuthenticatedUserId(void);
+void GetUserIdAndSecContext(Oid* userid, int* sec_context);
+void SetUserIdAndSecContext(Oid userid, int sec_context);
+bool InLocalUserIdChange(void);
+bool InSecurityRestrictedOperation(void);
+bool InNoForceRLSOperation(void);
+bool superuser(void);
+bool superuser_arg(Oid roleid);
+bool has_privs_of_role(Oid member, Oid role);
+char* GetUserNameFromId(Oid roleid, bool noerr);
+Oid get_role_oid(const char* rolname, bool missing_ok);
+
+// PgExtSessionInfo describes a session to the shim, which copies the names into the session's state.
+typedef struct PgExtSessionInfo {
+	int32_t     proc_pid;
+	Oid         database_id;
+	const char* database_name;
+	const char* application_name;
+} PgExtSessionInfo;
+
+void pgext_load_session_info(PgExtBackendState* state);
+void pgext_load_session_config(PgExtBackendState* state);
+int pgext_session_pid(PgExtBackendState* state);
+char* get_database_name(Oid dbid);
+Oid get_database_oid(const char* dbname, bool missing_ok);
+
+// PgExtLogger is registered by the host to receive the messages that extensions report below ERROR, such as notices and
+// warnings, along with the process ID of the session that reported them. The report is only valid during the call.
+typedef struct PgExtLogger {
+	void (*log)(const PgExtErrorData* edata, int32_t pid);
+} PgExtLogger;
+
+// PgExtTracer is registered by the host to trace calls to functions. Starting a span returns the host's handle to it,
+// which is zero when the call is not traced, given the handle of the parent span, which is zero for calls that the host
+// made. The library and function names are NULL when they cannot be found. Ending a span returns the handle of its
+// parent, and is given the error that the call raised, or NULL when it returned normally.
+typedef struct PgExtTracer {
+	uintptr_t (*start)(uintptr_t parent, void* fn, const char* library, const char* function, int nargs);
+	uintptr_t (*end)(uintptr_t span, const PgExtErrorData* edata);
+} PgExtTracer;
+
+uintptr_t pgext_trace_start(void* fn, int nargs);
+void pgext_trace_end(uintptr_t span, PgExtErrorData* edata);
+Datum pgext_trace_invoke(PGFunction fn, FunctionCallInfo fcinfo);
+
+#define TYPTYPE_BASE       'b'
+#define TYPTYPE_COMPOSITE  'c'
+#define TYPTYPE_DOMAIN     'd'
+#define TYPTYPE_ENUM       'e'
+#define TYPTYPE_MULTIRANGE 'm'
+#define TYPTYPE_PSEUDO     'p'
+#define TYPTYPE_RANGE      'r'
+
+#define TYPECACHE_EQ_OPR                   0x00001
+#define TYPECACHE_LT_OPR                   0x00002
+#define TYPECACHE_GT_OPR                   0x00004
+#define TYPECACHE_CMP_PROC                 0x00008
+#define TYPECACHE_HASH_PROC                0x00010
+#define TYPECACHE_EQ_OPR_FINFO             0x00020
+#define TYPECACHE_CMP_PROC_FINFO           0x00040
+#define TYPECACHE_HASH_PROC_FINFO          0x00080
+#define TYPECACHE_TUPDESC                  0x00100
+#define TYPECACHE_BTREE_OPFAMILY           0x00200
+#define TYPECACHE_HASH_OPFAMILY            0x00400
+#define TYPECACHE_RANGE_INFO               0x00800
+#define TYPECACHE_DOMAIN_BASE_INFO         0x01000
+#define TYPECACHE_DOMAIN_CONSTR_INFO       0x02000
+#define TYPECACHE_HASH_EXTENDED_PROC       0x04000
+#define TYPECACHE_HASH_EXTENDED_PROC_FINFO 0x08000
+#define TYPECACHE_MULTIRANGE_INFO          0x10000
+
+// TypeCacheEntry matches the layout of Postgres 15. Entries are kept per session, the same as Postgres keeps them per
+// backend, and the operator families, ranges, and domain constraints are never filled in.
+typedef struct TypeCacheEntry {
+	Oid                    type_id;
+	uint32_t               type_id_hash;
+	int16_t                typlen;
+	bool                   typbyval;
+	char                   typalign;
+	char                   typstorage;
+	char                   typtype;
+	Oid                    typrelid;
+	Oid                    typsubscript;
+	Oid                    typelem;
+	Oid                    typcollation;
+	Oid                    btree_opf;
+	Oid                    btree_opintype;
+	Oid                    hash_opf;
+	Oid                    hash_opintype;
+	Oid                    eq_opr;
+	Oid                    lt_opr;
+	Oid                    gt_opr;
+	Oid                    cmp_proc;
+	Oid                    hash_proc;
+	Oid                    hash_extended_proc;
+	FmgrInfo               eq_opr_finfo;
+	FmgrInfo               cmp_proc_finfo;
+	FmgrInfo               hash_proc_finfo;
+	FmgrInfo               hash_extended_proc_finfo;
+	TupleDesc              tupDesc;
+	uint64_t               tupDesc_identifier;
+	struct TypeCacheEntry* rngelemtype;
+	Oid                    rng_collation;
+	FmgrInfo               rng_cmp_proc_finfo;
+	FmgrInfo               rng_canonical_finfo;
+	FmgrInfo               rng_subdiff_finfo;
+	struct TypeCacheEntry* rngtype;
+	Oid                    domainBaseType;
+	int32_t                domainBaseTypmod;
+	void*                  domainData;
+	int                    flags;
+	void*                  enumData;
+	struct TypeCacheEntry* nextDomain;
+} TypeCacheEntry;
+
+typedef enum IOFuncSelector {
+	IOFunc_input,
+	IOFunc_output,
+	IOFunc_receive,
+	IOFunc_send,
+} IOFuncSelector;
+
+// AttInMetadata holds the input functions of a descriptor's attributes, which BuildTupleFromCStrings calls.
+typedef struct AttInMetadata {
+	TupleDesc tupdesc;
+	FmgrInfo* attinfuncs;
+	Oid*      attioparams;
+	int32_t*  atttypmods;
+} AttInMetadata;
+
+TypeCacheEntry* lookup_type_cache(Oid type_id, int flags);
+int16_t get_typlen(Oid typid);
+bool get_typbyval(Oid typid);
+void get_typlenbyval(Oid typid, int16_t* typlen, bool* typbyval);
+void get_typlenbyvalalign(Oid typid, int16_t* typlen, bool* typbyval, char* typalign);
+char get_typstorage(Oid typid);
+char get_typtype(Oid typid);
+Oid get_typcollation(Oid typid);
+Oid get_typ_typrelid(Oid typid);
+bool type_is_rowtype(Oid typid);
+Oid get_element_type(Oid typid);
+Oid get_array_type(Oid typid);
+Oid get_base_element_type(Oid typid);
+Oid getBaseType(Oid typid);
+Oid getBaseTypeAndTypmod(Oid typid, int32_t* typmod);
+void get_type_io_data(Oid typid, IOFuncSelector which_func, int16_t* typlen, bool* typbyval, char* typalign,
+	char* typdelim, Oid* typioparam, Oid* func);
+void getTypeInputInfo(Oid type, Oid* typInput, Oid* typIOParam);
+void getTypeOutputInfo(Oid type, Oid* typOutput, bool* typIsVarlena);
+void getTypeBinaryInputInfo(Oid type, Oid* typReceive, Oid* typIOParam);
+void getTypeBinaryOutputInfo(Oid type, Oid* typSend, bool* typIsVarlena);
+char* format_type_be(Oid type_oid);
+char* format_type_be_qualified(Oid type_oid);
+char* format_type_with_typemod(Oid type_oid, int32_t typemod);
+AttInMetadata* TupleDescGetAttInMetadata(TupleDesc tupdesc);
+HeapTuple BuildTupleFromCStrings(AttInMetadata* attinmeta, char** values);
+
+#define type_is_array(typid) (get_element_type(typid) != 0)
+
+// PgExtTranslator is registered by the host to translate the messages of extensions that were built with NLS support.
+typedef struct PgExtTranslator {
+	void        (*bind_domain)(const char* domain);
+	const char* (*translate)(const char* domain, const char* singular, const char* plural, unsigned long n, bool is_plural);
+} PgExtTranslator;
+
+const char* pgext_translate(const char* domain, const char* msgid);
+const char* pgext_translate_plural(const char* domain, const char* singular, const char* plural, unsigned long n);
+
+#define PGC_BOOL   0
+#define PGC_INT    1
+#define PGC_REAL   2
+#define PGC_STRING 3
+#define PGC_ENUM   4
+
+// config_enum_entry is an allowed value of an enum configuration variable. The list ends with an entry whose name is
+// NULL.
+struct config_enum_entry {
+	const char* name;
+	int         val;
+	bool        hidden;
+};
+
+// PgExtConfigVariable describes a configuration variable that an extension defined through one of the DefineCustom
+// functions. The boot value is given in its textual form, and the hooks are the extension's own, which the host calls
+// with the parsed value.
+typedef struct PgExtConfigVariable {
+	const char*                     name;
+	const char*                     short_desc;
+	const char*                     long_desc;
+	int                             type;
+	int                             context;
+	int                             flags;
+	void*                           value_addr;
+	const char*                     boot_value;
+	double                          min_value;
+	double                          max_value;
+	const struct config_enum_entry* options;
+	void*                           check_hook;
+	void*                           assign_hook;
+} PgExtConfigVariable;
+
+// PgExtConfigHookCall describes a call to the check or assign hook of a variable, which the host makes through
+// pgext_config_call_hook so that the errors that the hook raises are caught. The value that matches the variable's type
+// is given to the hook, and a check hook may replace it, along with the extra data that's given to the assign hook.
+// valid is set to the result of a check hook.
+typedef struct PgExtConfigHookCall {
+	int    type;
+	bool   assign;
+	void*  hook;
+	int    source;
+	bool   bool_value;
+	int    int_value;
+	double real_value;
+	char*  string_value;
+	void*  extra;
+	bool   valid;
+} PgExtConfigHookCall;
+
+// PgExtConfigRegistry is registered by the host to hold the configuration variables of all extensions. The define and
+// set functions return zero on success, or the SQLSTATE of the error along with its message, which the shim frees. A
+// NULL value given to set resets the variable. The values returned by get remain valid for the life of the process.
+// load_session is called whenever a session's state is bound or resumes from a wait, so that the host may assign the
+// values that the session has set, which other sessions may have replaced in the meantime.
+typedef struct PgExtConfigRegistry {
+	int  (*define)(const PgExtConfigVariable* variable, char** message);
+	int  (*set)(const char* name, const char* value, int context, int source, char** message);
+	bool (*get)(const char* name, const char** value);
+	void (*reserve_prefix)(const char* prefix);
+	void (*load_session)(PgExtBackendState* state);
+} PgExtConfigRegistry;
+
 #endif //PG_EXT_EXPORTS_H
@@ -0,0 +1,474 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// function_lookup is the host's lookup for functions by OID, or NULL if the host has not registered any functions.
+static PgExtFunctionLookup* function_lookup;
+
+// pgext_catch_errors calls the given function with its argument, writing its result to the given location. Returns the
+// error that the function raised, or NULL if it returned normally. Errors unwind to this point, so that they never cross
+// over the Go frames of the host. When guarded calls are enabled, crashes also unwind to this point.
+PgExtErrorData* pgext_catch_errors(Datum (*fn)(void* arg), void* arg, Datum* result) {
+	PgExtBackendState* state = pgext_backend_state();
+	sigjmp_buf* saved_exception_stack = PG_exception_stack;
+	ErrorContextCallback* saved_context_stack = error_context_stack;
+	MemoryContext saved_context = CurrentMemoryContext;
+	struct PgExtSPIConnection* saved_spi_connection = state->spi_connection;
+	int saved_num_held_lwlocks = state->num_held_lwlocks;
+	bool guarded = pgext_guarded_calls();
+	sigjmp_buf local_sigjmp_buf;
+	PgExtGuard guard = {&local_sigjmp_buf, state};
+	PgExtGuard* volatile saved_guard = NULL;
+	// The signal mask must be restored when unwinding from a crash, as the crashing signal is blocked within its handler
+	if (sigsetjmp(local_sigjmp_buf, guarded ? 1 : 0) != 0) {
+		if (guarded) {
+			pgext_guard_set(saved_guard);
+		}
+		PG_exception_stack = saved_exception_stack;
+		error_context_stack = saved_context_stack;
+		// Connections to SPI that were made during the call are closed, as the function can no longer finish them
+		pgext_spi_unwind(saved_spi_connection);
+		pgext_lwlock_unwind(state, saved_num_held_lwlocks);
+		CurrentMemoryContext = saved_context;
+		state->errordata_depth = 0;
+		*result = 0;
+		return &state->caught;
+	}
+	if (guarded) {
+		saved_guard = pgext_guard_set(&guard);
+	}
+	PG_exception_stack = &local_sigjmp_buf;
+	*result = fn(arg);
+	if (guarded) {
+		pgext_guard_set(saved_guard);
+	}
+	PG_exception_stack = saved_exception_stack;
+	error_context_stack = saved_context_stack;
+	return NULL;
+}
+
+// call_function calls the function that is referenced by the call info.
+static Datum call_function(void* arg) {
+	FunctionCallInfo fcinfo = (FunctionCallInfo)arg;
+	return ((PGFunction)fcinfo->flinfo->fn_addr)(fcinfo);
+}
+
+// pgext_fmgr_call calls the function that is referenced by the call info, writing its result to the given location.
+// Returns the error that the function raised, or NULL if it returned normally.
+DLLEXPORT PgExtErrorData* pgext_fmgr_call(FunctionCallInfo fcinfo, Datum* result) {
+	uintptr_t span = pgext_trace_start(fcinfo->flinfo->fn_addr, fcinfo->nargs);
+	PgExtErrorData* edata = pgext_catch_errors(call_function, fcinfo, result);
+	pgext_trace_end(span, edata);
+	return edata;
+}
+
+// pgext_fmgr_call_batch calls the function for each row of the batch. Returns the number of rows that were finished,
+// which is fewer than the number of rows when a guarded call crashes, as the remaining rows are not called after a
+// crash, or when an error cannot be copied, in which case the row with the error is not counted.
+DLLEXPORT uintptr_t pgext_fmgr_call_batch(PgExtBatchCall* batch) {
+	FunctionCallInfo fcinfo = batch->fcinfo;
+	int nargs = fcinfo->nargs;
+	for (int row = 0; row < batch->nrows; row++) {
+		memcpy(fcinfo->args, batch->args + (size_t)row * nargs, nargs * sizeof(NullableDatum));
+		fcinfo->isnull = false;
+		Datum result;
+		PgExtErrorData* edata = pgext_fmgr_call(fcinfo, &result);
+		batch->results[row] = result;
+		batch->isnull[row] = fcinfo->isnull;
+		if (edata != NULL) {
+			PgExtErrorData* copy = (PgExtErrorData*)malloc(sizeof(PgExtErrorData));
+			if (copy == NULL) {
+				return row;
+			}
+			memcpy(copy, edata, sizeof(PgExtErrorData));
+			batch->errors[row] = copy;
+			if (edata->signal != 0) {
+				return row + 1;
+			}
+		}
+	}
+	return batch->nrows;
+}
+
+// call_procedure calls the function that is given as the argument, which takes no arguments and returns nothing.
+static Datum call_procedure(void* arg) {
+	((void (*)(void))arg)();
+	return 0;
+}
+
+// pgext_call_procedure calls a function that takes no arguments and returns nothing, such as _PG_init. Returns the error
+// that the function raised, or NULL if it returned normally.
+DLLEXPORT PgExtErrorData* pgext_call_procedure(void (*fn)(void)) {
+	Datum result;
+	return pgext_catch_errors(call_procedure, (void*)fn, &result);
+}
+
+// init_fcinfo prepares the call info for a call with the given arguments, none of which are NULL. The call info must
+// have room for at least that many arguments.
+static void init_fcinfo(FunctionCallInfo fcinfo, FmgrInfo* flinfo, Oid collation, int nargs, const Datum* args) {
+	memset(fcinfo, 0, SizeForFunctionCallInfo(nargs));
+	fcinfo->flinfo = flinfo;
+	fcinfo->fncollation = collation;
+	fcinfo->nargs = (short)nargs;
+	for (int i = 0; i < nargs; i++) {
+		fcinfo->args[i].value = args[i];
+	}
+}
+
+// direct_function_call calls the function without any FmgrInfo, which is only valid for functions that do not use it,
+// such as most builtin functions. A NULL result raises an error.
+static Datum direct_function_call(PGFunction func, Oid collation, int nargs, const Datum* args) {
+	LOCAL_FCINFO(fcinfo, 9);
+	init_fcinfo(fcinfo, NULL, collation, nargs, args);
+	Datum result = pgext_trace_invoke(func, fcinfo);
+	if (fcinfo->isnull) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "function %p returned NULL", (void*)func);
+	}
+	return result;
+}
+
+DLLEXPORT Datum DirectFunctionCall1Coll(PGFunction func, Oid collation, Datum arg1) {
+	Datum args[1] = {arg1};
+	return direct_function_call(func, collation, 1, args);
+}
+
+DLLEXPORT Datum DirectFunctionCall2Coll(PGFunction func, Oid collation, Datum arg1, Datum arg2) {
+	Datum args[2] = {arg1, arg2};
+	return direct_function_call(func, collation, 2, args);
+}
+
+DLLEXPORT Datum DirectFunctionCall3Coll(PGFunction func, Oid collation, Datum arg1, Datum arg2, Datum arg3) {
+	Datum args[3] = {arg1, arg2, arg3};
+	return direct_function_call(func, collation, 3, args);
+}
+
+DLLEXPORT Datum DirectFunctionCall4Coll(PGFunction func, Oid collation, Datum arg1, Datum arg2, Datum arg3,
+	Datum arg4) {
+	Datum args[4] = {arg1, arg2, arg3, arg4};
+	return direct_function_call(func, collation, 4, args);
+}
+
+DLLEXPORT Datum DirectFunctionCall5Coll(PGFunction func, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5) {
+	Datum args[5] = {arg1, arg2, arg3, arg4, arg5};
+	return direct_function_call(func, collation, 5, args);
+}
+
+DLLEXPORT Datum DirectFunctionCall6Coll(PGFunction func, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5, Datum arg6) {
+	Datum args[6] = {arg1, arg2, arg3, arg4, arg5, arg6};
+	return direct_function_call(func, collation, 6, args);
+}
+
+DLLEXPORT Datum DirectFunctionCall7Coll(PGFunction func, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5, Datum arg6, Datum arg7) {
+	Datum args[7] = {arg1, arg2, arg3, arg4, arg5, arg6, arg7};
+	return direct_function_call(func, collation, 7, args);
+}
+
+DLLEXPORT Datum DirectFunctionCall8Coll(PGFunction func, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5, Datum arg6, Datum arg7, Datum arg8) {
+	Datum args[8] = {arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8};
+	return direct_function_call(func, collation, 8, args);
+}
+
+DLLEXPORT Datum DirectFunctionCall9Coll(PGFunction func, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5, Datum arg6, Datum arg7, Datum arg8, Datum arg9) {
+	Datum args[9] = {arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9};
+	return direct_function_call(func, collation, 9, args);
+}
+
+// pgext_set_function_lookup sets the host's lookup for functions by OID.
+DLLEXPORT uintptr_t pgext_set_function_lookup(PgExtFunctionLookup* lookup) {
+	function_lookup = lookup;
+	return 0;
+}
+
+DLLEXPORT void fmgr_info_cxt(Oid functionId, FmgrInfo* finfo, MemoryContext mcxt) {
+	// The lookup only describes the function, so any state from a previous use of the struct is cleared beforehand
+	memset(finfo, 0, sizeof(FmgrInfo));
+	if (function_lookup == NULL || !function_lookup->lookup(functionId, finfo) || finfo->fn_addr == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_UNDEFINED_FUNCTION, "cache lookup failed for function %u", functionId);
+		return;
+	}
+	finfo->fn_oid = functionId;
+	finfo->fn_mcxt = mcxt;
+}
+
+DLLEXPORT void fmgr_info(Oid functionId, FmgrInfo* finfo) {
+	// The current context is created on first use, and functions expect fn_mcxt to be valid for caching within fn_extra
+	fmgr_info_cxt(functionId, finfo, pgext_current_context());
+}
+
+DLLEXPORT void fmgr_info_copy(FmgrInfo* dstinfo, FmgrInfo* srcinfo, MemoryContext destcxt) {
+	memcpy(dstinfo, srcinfo, sizeof(FmgrInfo));
+	dstinfo->fn_mcxt = destcxt;
+	dstinfo->fn_extra = NULL;
+}
+
+// io_function_call calls a type's I/O function with the given arguments, returning whether it set its result to NULL.
+static Datum io_function_call(FmgrInfo* flinfo, int nargs, Datum arg1, Datum arg2, Datum arg3, bool* isnull) {
+	FunctionCallInfoBaseData fcinfo;
+	memset(&fcinfo, 0, sizeof(fcinfo));
+	fcinfo.flinfo = flinfo;
+	fcinfo.nargs = (short)nargs;
+	fcinfo.args[0].value = arg1;
+	fcinfo.args[1].value = arg2;
+	fcinfo.args[2].value = arg3;
+	Datum result = pgext_trace_invoke((PGFunction)flinfo->fn_addr, &fcinfo);
+	*isnull = fcinfo.isnull;
+	return result;
+}
+
+// Input and receive functions are only called for NULL input when they are not strict, as domains may reject NULL.
+// Otherwise, a NULL result is an error, which is the same as Postgres.
+DLLEXPORT Datum InputFunctionCall(FmgrInfo* flinfo, char* str, Oid typioparam, int32_t typmod) {
+	if (str == NULL && flinfo->fn_strict) {
+		return (Datum)0;
+	}
+	bool isnull = false;
+	Datum result = io_function_call(flinfo, 3, (Datum)str, (Datum)typioparam, (Datum)typmod, &isnull);
+	if (str == NULL ? !isnull : isnull) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "input function %u returned %s", flinfo->fn_oid,
+			isnull ? "NULL" : "non-NULL");
+	}
+	return result;
+}
+
+DLLEXPORT char* OutputFunctionCall(FmgrInfo* flinfo, Datum val) {
+	bool isnull = false;
+	Datum result = io_function_call(flinfo, 1, val, 0, 0, &isnull);
+	if (isnull) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "function %u returned NULL", flinfo->fn_oid);
+	}
+	return (char*)result;
+}
+
+DLLEXPORT Datum ReceiveFunctionCall(FmgrInfo* flinfo, StringInfo buf, Oid typioparam, int32_t typmod) {
+	if (buf == NULL && flinfo->fn_strict) {
+		return (Datum)0;
+	}
+	bool isnull = false;
+	Datum result = io_function_call(flinfo, 3, (Datum)buf, (Datum)typioparam, (Datum)typmod, &isnull);
+	if (buf == NULL ? !isnull : isnull) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "receive function %u returned %s", flinfo->fn_oid,
+			isnull ? "NULL" : "non-NULL");
+	}
+	return result;
+}
+
+DLLEXPORT bytea* SendFunctionCall(FmgrInfo* flinfo, Datum val) {
+	bool isnull = false;
+	Datum result = io_function_call(flinfo, 1, val, 0, 0, &isnull);
+	if (isnull) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "function %u returned NULL", flinfo->fn_oid);
+	}
+	return (bytea*)result;
+}
+
+DLLEXPORT Datum OidInputFunctionCall(Oid functionId, char* str, Oid typioparam, int32_t typmod) {
+	FmgrInfo flinfo;
+	fmgr_info(functionId, &flinfo);
+	return InputFunctionCall(&flinfo, str, typioparam, typmod);
+}
+
+DLLEXPORT char* OidOutputFunctionCall(Oid functionId, Datum val) {
+	FmgrInfo flinfo;
+	fmgr_info(functionId, &flinfo);
+	return OutputFunctionCall(&flinfo, val);
+}
+
+DLLEXPORT Datum OidReceiveFunctionCall(Oid functionId, StringInfo buf, Oid typioparam, int32_t typmod) {
+	FmgrInfo flinfo;
+	fmgr_info(functionId, &flinfo);
+	return ReceiveFunctionCall(&flinfo, buf, typioparam, typmod);
+}
+
+DLLEXPORT bytea* OidSendFunctionCall(Oid functionId, Datum val) {
+	FmgrInfo flinfo;
+	fmgr_info(functionId, &flinfo);
+	return SendFunctionCall(&flinfo, val);
+}
+
+// pgext_builtin_call is the address of every function that the host implements in Go, which passes the call to the
+// host. The host finds the function by the OID within the call info.
+DLLEXPORT Datum pgext_builtin_call(FunctionCallInfo fcinfo) {
+	if (function_lookup == NULL || function_lookup->call == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_FEATURE_NOT_SUPPORTED, "function %u is not implemented by the host",
+			fcinfo->flinfo->fn_oid);
+	}
+	int sqlerrcode = 0;
+	char* message = NULL;
+	Datum result = function_lookup->call(fcinfo, &sqlerrcode, &message);
+	if (message != NULL) {
+		pgext_raise_host_error(sqlerrcode, message);
+	}
+	return result;
+}
+
+// function_call calls the function through the FmgrInfo, so that the function may cache state within fn_extra across
+// calls. The arguments cannot be NULL, and a NULL result raises an error.
+static Datum function_call(FmgrInfo* flinfo, Oid collation, int nargs, const Datum* args) {
+	LOCAL_FCINFO(fcinfo, 9);
+	init_fcinfo(fcinfo, flinfo, collation, nargs, args);
+	Datum result = pgext_trace_invoke((PGFunction)flinfo->fn_addr, fcinfo);
+	if (fcinfo->isnull) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "function %u returned NULL", flinfo->fn_oid);
+	}
+	return result;
+}
+
+DLLEXPORT Datum FunctionCall0Coll(FmgrInfo* flinfo, Oid collation) {
+	return function_call(flinfo, collation, 0, NULL);
+}
+
+DLLEXPORT Datum FunctionCall1Coll(FmgrInfo* flinfo, Oid collation, Datum arg1) {
+	Datum args[1] = {arg1};
+	return function_call(flinfo, collation, 1, args);
+}
+
+DLLEXPORT Datum FunctionCall2Coll(FmgrInfo* flinfo, Oid collation, Datum arg1, Datum arg2) {
+	Datum args[2] = {arg1, arg2};
+	return function_call(flinfo, collation, 2, args);
+}
+
+DLLEXPORT Datum FunctionCall3Coll(FmgrInfo* flinfo, Oid collation, Datum arg1, Datum arg2, Datum arg3) {
+	Datum args[3] = {arg1, arg2, arg3};
+	return function_call(flinfo, collation, 3, args);
+}
+
+DLLEXPORT Datum FunctionCall4Coll(FmgrInfo* flinfo, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4) {
+	Datum args[4] = {arg1, arg2, arg3, arg4};
+	return function_call(flinfo, collation, 4, args);
+}
+
+DLLEXPORT Datum FunctionCall5Coll(FmgrInfo* flinfo, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5) {
+	Datum args[5] = {arg1, arg2, arg3, arg4, arg5};
+	return function_call(flinfo, collation, 5, args);
+}
+
+DLLEXPORT Datum FunctionCall6Coll(FmgrInfo* flinfo, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5, Datum arg6) {
+	Datum args[6] = {arg1, arg2, arg3, arg4, arg5, arg6};
+	return function_call(flinfo, collation, 6, args);
+}
+
+DLLEXPORT Datum FunctionCall7Coll(FmgrInfo* flinfo, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5, Datum arg6, Datum arg7) {
+	Datum args[7] = {arg1, arg2, arg3, arg4, arg5, arg6, arg7};
+	return function_call(flinfo, collation, 7, args);
+}
+
+DLLEXPORT Datum FunctionCall8Coll(FmgrInfo* flinfo, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5, Datum arg6, Datum arg7, Datum arg8) {
+	Datum args[8] = {arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8};
+	return function_call(flinfo, collation, 8, args);
+}
+
+DLLEXPORT Datum FunctionCall9Coll(FmgrInfo* flinfo, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5, Datum arg6, Datum arg7, Datum arg8, Datum arg9) {
+	Datum args[9] = {arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9};
+	return function_call(flinfo, collation, 9, args);
+}
+
+// The functions are looked up for each call, which is the same as Postgres, so a function cannot cache state between
+// these calls.
+DLLEXPORT Datum OidFunctionCall0Coll(Oid functionId, Oid collation) {
+	FmgrInfo flinfo;
+	fmgr_info(functionId, &flinfo);
+	return FunctionCall0Coll(&flinfo, collation);
+}
+
+DLLEXPORT Datum OidFunctionCall1Coll(Oid functionId, Oid collation, Datum arg1) {
+	FmgrInfo flinfo;
+	fmgr_info(functionId, &flinfo);
+	return FunctionCall1Coll(&flinfo, collation, arg1);
+}
+
+DLLEXPORT Datum OidFunctionCall2Coll(Oid functionId, Oid collation, Datum arg1, Datum arg2) {
+	FmgrInfo flinfo;
+	fmgr_info(functionId, &flinfo);
+	return FunctionCall2Coll(&flinfo, collation, arg1, arg2);
+}
+
+DLLEXPORT Datum OidFunctionCall3Coll(Oid functionId, Oid collation, Datum arg1, Datum arg2, Datum arg3) {
+	FmgrInfo flinfo;
+	fmgr_info(functionId, &flinfo);
+	return FunctionCall3Coll(&flinfo, collation, arg1, arg2, arg3);
+}
+
+DLLEXPORT Datum OidFunctionCall4Coll(Oid functionId, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4) {
+	FmgrInfo flinfo;
+	fmgr_info(functionId, &flinfo);
+	return FunctionCall4Coll(&flinfo, collation, arg1, arg2, arg3, arg4);
+}
+
+DLLEXPORT Datum OidFunctionCall5Coll(Oid functionId, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5) {
+	FmgrInfo flinfo;
+	fmgr_info(functionId, &flinfo);
+	return FunctionCall5Coll(&flinfo, collation, arg1, arg2, arg3, arg4, arg5);
+}
+
+DLLEXPORT Datum OidFunctionCall6Coll(Oid functionId, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5, Datum arg6) {
+	FmgrInfo flinfo;
+	fmgr_info(functionId, &flinfo);
+	return FunctionCall6Coll(&flinfo, collation, arg1, arg2, arg3, arg4, arg5, arg6);
+}
+
+DLLEXPORT Datum OidFunctionCall7Coll(Oid functionId, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5, Datum arg6, Datum arg7) {
+	FmgrInfo flinfo;
+	fmgr_info(functionId, &flinfo);
+	return FunctionCall7Coll(&flinfo, collation, arg1, arg2, arg3, arg4, arg5, arg6, arg7);
+}
+
+DLLEXPORT Datum OidFunctionCall8Coll(Oid functionId, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5, Datum arg6, Datum arg7, Datum arg8) {
+	FmgrInfo flinfo;
+	fmgr_info(functionId, &flinfo);
+	return FunctionCall8Coll(&flinfo, collation, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8);
+}
+
+DLLEXPORT Datum OidFunctionCall9Coll(Oid functionId, Oid collation, Datum arg1, Datum arg2, Datum arg3, Datum arg4,
+	Datum arg5, Datum arg6, Datum arg7, Datum arg8, Datum arg9) {
+	FmgrInfo flinfo;
+	fmgr_info(functionId, &flinfo);
+	return FunctionCall9Coll(&flinfo, collation, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9);
+}
+
+// pgext_fmgr_info_init creates the memory context of an FmgrInfo that the host reuses across calls. The context has no
+// parent, so that whatever the function caches within fn_extra is independent of any session, and lives until
+// pgext_fmgr_info_free is called.
+DLLEXPORT uintptr_t pgext_fmgr_info_init(FmgrInfo* finfo) {
+	MemoryContext context = AllocSetContextCreateInternal(NULL, "FmgrInfoContext", 0, 0, 0);
+	if (context == NULL) {
+		return 0;
+	}
+	finfo->fn_mcxt = context;
+	finfo->fn_extra = NULL;
+	return 1;
+}
+
+// pgext_fmgr_info_free frees the memory context of an FmgrInfo that was initialized by pgext_fmgr_info_init, along with
+// everything that the function allocated within it.
+DLLEXPORT uintptr_t pgext_fmgr_info_free(FmgrInfo* finfo) {
+	MemoryContextDelete((MemoryContext)finfo->fn_mcxt);
+	finfo->fn_mcxt = NULL;
+	finfo->fn_extra = NULL;
+	return 0;
+}
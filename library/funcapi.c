@@ -0,0 +1,230 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+DLLEXPORT void RegisterExprContextCallback(ExprContext* econtext, ExprContextCallbackFunction function, Datum arg) {
+	ExprContext_CB* ecxt_callback = (ExprContext_CB*)MemoryContextAllocZero(econtext->ecxt_per_query_memory,
+		sizeof(ExprContext_CB));
+	ecxt_callback->function = function;
+	ecxt_callback->arg = arg;
+	ecxt_callback->next = econtext->ecxt_callbacks;
+	econtext->ecxt_callbacks = ecxt_callback;
+}
+
+DLLEXPORT void UnregisterExprContextCallback(ExprContext* econtext, ExprContextCallbackFunction function, Datum arg) {
+	ExprContext_CB** prev_callback = &econtext->ecxt_callbacks;
+	ExprContext_CB* ecxt_callback;
+	while ((ecxt_callback = *prev_callback) != NULL) {
+		if (ecxt_callback->function == function && ecxt_callback->arg == arg) {
+			*prev_callback = ecxt_callback->next;
+			pfree(ecxt_callback);
+		} else {
+			prev_callback = &ecxt_callback->next;
+		}
+	}
+}
+
+// shutdown_MultiFuncCall frees the state of a set-returning function, which is called when the function has returned
+// all of its values, or when its caller stops reading values early.
+static void shutdown_MultiFuncCall(Datum arg) {
+	FmgrInfo* flinfo = (FmgrInfo*)arg;
+	FuncCallContext* funcctx = (FuncCallContext*)flinfo->fn_extra;
+	flinfo->fn_extra = NULL;
+	MemoryContextDelete(funcctx->multi_call_memory_ctx);
+}
+
+DLLEXPORT FuncCallContext* init_MultiFuncCall(FunctionCallInfo fcinfo) {
+	ReturnSetInfo* rsi = (ReturnSetInfo*)fcinfo->resultinfo;
+	if (rsi == NULL || !IsA(rsi, ReturnSetInfo) || (rsi->allowedModes & SFRM_ValuePerCall) == 0) {
+		pgext_raise_error(ERROR, ERRCODE_FEATURE_NOT_SUPPORTED,
+			"set-valued function called in context that cannot accept a set");
+	}
+	if (fcinfo->flinfo->fn_extra != NULL) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "init_MultiFuncCall cannot be called more than once");
+	}
+	MemoryContext multi_call_ctx = AllocSetContextCreateInternal((MemoryContext)fcinfo->flinfo->fn_mcxt,
+		"SRF multi-call context", 0, 0, 0);
+	FuncCallContext* retval = (FuncCallContext*)MemoryContextAllocZero(multi_call_ctx, sizeof(FuncCallContext));
+	retval->multi_call_memory_ctx = multi_call_ctx;
+	fcinfo->flinfo->fn_extra = retval;
+	RegisterExprContextCallback(rsi->econtext, shutdown_MultiFuncCall, (Datum)fcinfo->flinfo);
+	return retval;
+}
+
+DLLEXPORT FuncCallContext* per_MultiFuncCall(FunctionCallInfo fcinfo) {
+	return (FuncCallContext*)fcinfo->flinfo->fn_extra;
+}
+
+DLLEXPORT void end_MultiFuncCall(FunctionCallInfo fcinfo, FuncCallContext* funcctx) {
+	ReturnSetInfo* rsi = (ReturnSetInfo*)fcinfo->resultinfo;
+	UnregisterExprContextCallback(rsi->econtext, shutdown_MultiFuncCall, (Datum)fcinfo->flinfo);
+	shutdown_MultiFuncCall((Datum)fcinfo->flinfo);
+}
+
+DLLEXPORT void InitMaterializedSRF(FunctionCallInfo fcinfo, uint32_t flags) {
+	ReturnSetInfo* rsinfo = (ReturnSetInfo*)fcinfo->resultinfo;
+	if (rsinfo == NULL || !IsA(rsinfo, ReturnSetInfo)) {
+		pgext_raise_error(ERROR, ERRCODE_FEATURE_NOT_SUPPORTED,
+			"set-valued function called in context that cannot accept a set");
+	}
+	if ((rsinfo->allowedModes & SFRM_Materialize) == 0 ||
+		((flags & MAT_SRF_USE_EXPECTED_DESC) != 0 && rsinfo->expectedDesc == NULL)) {
+		pgext_raise_error(ERROR, ERRCODE_SYNTAX_ERROR, "materialize mode required, but it is not allowed in this context");
+	}
+	// We don't have a catalog to find the function's result type, so the expected descriptor is always used
+	if (rsinfo->expectedDesc == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_FEATURE_NOT_SUPPORTED,
+			"function returning a set was called without the description of its result");
+	}
+	MemoryContext oldcontext = MemoryContextSwitchTo(rsinfo->econtext->ecxt_per_query_memory);
+	TupleDesc stored_tupdesc = CreateTupleDescCopy(rsinfo->expectedDesc);
+	Tuplestorestate* tupstore = tuplestore_begin_heap((rsinfo->allowedModes & SFRM_Materialize_Random) != 0, false, 0);
+	rsinfo->returnMode = SFRM_Materialize;
+	rsinfo->setResult = tupstore;
+	rsinfo->setDesc = stored_tupdesc;
+	MemoryContextSwitchTo(oldcontext);
+}
+
+// get_call_result_type returns the result type of the function that is being called. We don't have a catalog to find
+// the function's declared result type, so the result is only known to be composite when the caller gave an expected
+// descriptor through the result info. Otherwise, the function is treated as returning an unknown record type.
+DLLEXPORT TypeFuncClass get_call_result_type(FunctionCallInfo fcinfo, Oid* resultTypeId, TupleDesc* resultTupleDesc) {
+	ReturnSetInfo* rsinfo = (ReturnSetInfo*)fcinfo->resultinfo;
+	TupleDesc expected = NULL;
+	if (rsinfo != NULL && IsA(rsinfo, ReturnSetInfo)) {
+		expected = rsinfo->expectedDesc;
+	}
+	if (resultTypeId != NULL) {
+		*resultTypeId = expected != NULL ? expected->tdtypeid : RECORDOID;
+	}
+	if (resultTupleDesc != NULL) {
+		*resultTupleDesc = expected;
+	}
+	return expected != NULL ? TYPEFUNC_COMPOSITE : TYPEFUNC_RECORD;
+}
+
+// SetSingleFuncCall is the name of InitMaterializedSRF in Postgres 15.
+DLLEXPORT void SetSingleFuncCall(FunctionCallInfo fcinfo, uint32_t flags) {
+	InitMaterializedSRF(fcinfo, flags);
+}
+
+// TupleDescGetAttInMetadata looks up the input function of each attribute of the descriptor, which come from the
+// catalog provider. Dropped attributes are skipped, as BuildTupleFromCStrings always gives them NULL.
+DLLEXPORT AttInMetadata* TupleDescGetAttInMetadata(TupleDesc tupdesc) {
+	int natts = tupdesc->natts;
+	AttInMetadata* attinmeta = (AttInMetadata*)palloc(sizeof(AttInMetadata));
+	attinmeta->tupdesc = BlessTupleDesc(tupdesc);
+	attinmeta->attinfuncs = (FmgrInfo*)palloc0(natts * sizeof(FmgrInfo));
+	attinmeta->attioparams = (Oid*)palloc0(natts * sizeof(Oid));
+	attinmeta->atttypmods = (int32_t*)palloc0(natts * sizeof(int32_t));
+	for (int i = 0; i < natts; i++) {
+		Form_pg_attribute att = TupleDescAttr(tupdesc, i);
+		if (att->attisdropped) {
+			continue;
+		}
+		Oid input;
+		getTypeInputInfo(att->atttypid, &input, &attinmeta->attioparams[i]);
+		fmgr_info(input, &attinmeta->attinfuncs[i]);
+		attinmeta->atttypmods[i] = att->atttypmod;
+	}
+	return attinmeta;
+}
+
+// BuildTupleFromCStrings forms a tuple from the text form of each attribute, where NULL pointers are NULL values.
+DLLEXPORT HeapTuple BuildTupleFromCStrings(AttInMetadata* attinmeta, char** values) {
+	TupleDesc tupdesc = attinmeta->tupdesc;
+	int natts = tupdesc->natts;
+	Datum* dvalues = (Datum*)palloc(natts * sizeof(Datum));
+	bool* nulls = (bool*)palloc(natts * sizeof(bool));
+	for (int i = 0; i < natts; i++) {
+		if (TupleDescAttr(tupdesc, i)->attisdropped) {
+			dvalues[i] = (Datum)0;
+			nulls[i] = true;
+			continue;
+		}
+		dvalues[i] = InputFunctionCall(&attinmeta->attinfuncs[i], values[i], attinmeta->attioparams[i],
+			attinmeta->atttypmods[i]);
+		nulls[i] = values[i] == NULL;
+	}
+	HeapTuple tuple = heap_form_tuple(tupdesc, dvalues, nulls);
+	pfree(dvalues);
+	pfree(nulls);
+	return tuple;
+}
+
+// pgext_srf_begin returns the result info for calling a set-returning function in any of the given modes. When natts is
+// positive, the result info holds an expected descriptor with that many attributes, which the host fills in. The
+// function's state is kept in a per-query memory context, which is freed by pgext_srf_end. Returns NULL when out of
+// memory.
+DLLEXPORT ReturnSetInfo* pgext_srf_begin(uintptr_t allowedModes, uintptr_t natts) {
+	MemoryContext per_query = AllocSetContextCreateInternal(NULL, "SRF per-query context", 0, 0, 0);
+	if (per_query == NULL) {
+		return NULL;
+	}
+	ExprContext* econtext = (ExprContext*)MemoryContextAllocExtended(per_query, sizeof(ExprContext),
+		MCXT_ALLOC_NO_OOM | MCXT_ALLOC_ZERO);
+	ReturnSetInfo* rsi = (ReturnSetInfo*)MemoryContextAllocExtended(per_query, sizeof(ReturnSetInfo),
+		MCXT_ALLOC_NO_OOM | MCXT_ALLOC_ZERO);
+	TupleDesc expected = NULL;
+	if (natts > 0) {
+		expected = (TupleDesc)MemoryContextAllocExtended(per_query, TupleDescSize(natts),
+			MCXT_ALLOC_NO_OOM | MCXT_ALLOC_ZERO);
+	}
+	if (econtext == NULL || rsi == NULL || (natts > 0 && expected == NULL)) {
+		MemoryContextDelete(per_query);
+		return NULL;
+	}
+	if (expected != NULL) {
+		expected->natts = (int)natts;
+		expected->tdtypeid = RECORDOID;
+		expected->tdtypmod = -1;
+		expected->tdrefcount = -1;
+	}
+	econtext->type = T_ExprContext;
+	econtext->ecxt_per_query_memory = per_query;
+	econtext->ecxt_per_tuple_memory = per_query;
+	rsi->type = T_ReturnSetInfo;
+	rsi->econtext = econtext;
+	rsi->expectedDesc = expected;
+	rsi->allowedModes = (int)allowedModes;
+	rsi->returnMode = SFRM_ValuePerCall;
+	rsi->isDone = ExprSingleResult;
+	return rsi;
+}
+
+// pgext_shutdown_econtext runs the shutdown callbacks of an ExprContext, in the reverse order of their registration.
+Datum pgext_shutdown_econtext(void* arg) {
+	ExprContext* econtext = (ExprContext*)arg;
+	MemoryContext oldcontext = MemoryContextSwitchTo(econtext->ecxt_per_query_memory);
+	ExprContext_CB* ecxt_callback;
+	// Each callback is removed before it is run, so that a callback that raises an error is not run again
+	while ((ecxt_callback = econtext->ecxt_callbacks) != NULL) {
+		econtext->ecxt_callbacks = ecxt_callback->next;
+		ecxt_callback->function(ecxt_callback->arg);
+		pfree(ecxt_callback);
+	}
+	MemoryContextSwitchTo(oldcontext);
+	return 0;
+}
+
+// pgext_srf_end shuts down the result info that was returned by pgext_srf_begin, which frees the state of a function
+// that stopped before returning all of its values. Returns the error that a shutdown callback raised, or NULL.
+DLLEXPORT PgExtErrorData* pgext_srf_end(ReturnSetInfo* rsi) {
+	ExprContext* econtext = rsi->econtext;
+	Datum ignored;
+	PgExtErrorData* edata = pgext_catch_errors(pgext_shutdown_econtext, econtext, &ignored);
+	MemoryContextDelete(econtext->ecxt_per_query_memory);
+	return edata;
+}
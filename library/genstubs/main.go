@@ -0,0 +1,215 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// genstubs generates stubs for Postgres functions that the shim does not yet implement. Each stub raises an error when
+// it's called, so that extensions which reference the function may still be loaded, and only fail once they use it.
+//
+// The symbols are read from a file with one symbol per line, where "#" begins a comment. Return types are found by
+// searching the given headers for each symbol's prototype. The stubs are written in C rather than Go, as raising an
+// error unwinds the stack with siglongjmp, which must never pass over Go frames.
+//
+// Usage:
+//
+//	go run ./genstubs -symbols stubs.txt -header exports.h -header /usr/include/postgresql/16/server -out stubs.c
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// headerList is a flag that may be given multiple times.
+type headerList []string
+
+// String implements the flag.Value interface.
+func (hl *headerList) String() string {
+	return strings.Join(*hl, ",")
+}
+
+// Set implements the flag.Value interface.
+func (hl *headerList) Set(value string) error {
+	*hl = append(*hl, value)
+	return nil
+}
+
+var (
+	// commentRegex matches C comments, which are removed before searching for prototypes.
+	commentRegex = regexp.MustCompile(`(?s)/\*.*?\*/|//[^\n]*`)
+	// attributeRegex matches attributes and storage keywords that may surround a prototype's return type.
+	attributeRegex = regexp.MustCompile(`\b(extern|static|inline|DLLEXPORT|PGDLLIMPORT|PGDLLEXPORT|pg_noreturn|pg_nodiscard|` +
+		`pg_attribute_\w+(\s*\([^)]*\))?|__attribute__\s*\(\([^)]*\)\))`)
+)
+
+func main() {
+	var headers headerList
+	symbolsFile := flag.String("symbols", "stubs.txt", "file that lists the symbols to generate stubs for")
+	outFile := flag.String("out", "stubs.c", "file that the stubs are written to")
+	flag.Var(&headers, "header", "header file or directory of headers to search for prototypes (may be repeated)")
+	flag.Parse()
+
+	symbols, err := readSymbols(*symbolsFile)
+	if err != nil {
+		fail(err)
+	}
+	headerContents, err := readHeaders(headers)
+	if err != nil {
+		fail(err)
+	}
+	var sb strings.Builder
+	sb.WriteString(fileHeader)
+	for _, symbol := range symbols {
+		returnType, ok := findReturnType(headerContents, symbol)
+		if !ok {
+			_, _ = fmt.Fprintf(os.Stderr, "genstubs: no prototype found for `%s`, so it returns a Datum\n", symbol)
+		}
+		sb.WriteString(stub(symbol, stubReturnType(returnType, ok)))
+	}
+	if err = os.WriteFile(*outFile, []byte(sb.String()), 0644); err != nil {
+		fail(err)
+	}
+}
+
+// fail prints the error and exits.
+func fail(err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "genstubs: %s\n", err.Error())
+	os.Exit(1)
+}
+
+// readSymbols reads the symbols from the given file, sorted and without duplicates.
+func readSymbols(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var symbols []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			symbols = append(symbols, line)
+		}
+	}
+	slices.Sort(symbols)
+	return slices.Compact(symbols), scanner.Err()
+}
+
+// readHeaders returns the contents of every header, with comments removed. Directories are searched recursively.
+func readHeaders(paths []string) ([]string, error) {
+	var contents []string
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(filePath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || (filePath != path && !strings.HasSuffix(filePath, ".h")) {
+				return nil
+			}
+			data, err := os.ReadFile(filePath)
+			if err != nil {
+				return err
+			}
+			contents = append(contents, commentRegex.ReplaceAllString(string(data), " "))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return contents, nil
+}
+
+// findReturnType searches the headers for the prototype of the given symbol, returning its return type.
+func findReturnType(headers []string, symbol string) (string, bool) {
+	prototypeRegex := regexp.MustCompile(`(?m)(^|[;}])\s*([^;{}#()]*?[\s*])` + regexp.QuoteMeta(symbol) + `\s*\(`)
+	for _, header := range headers {
+		match := prototypeRegex.FindStringSubmatch(header)
+		if match == nil {
+			continue
+		}
+		returnType := attributeRegex.ReplaceAllString(match[2], " ")
+		returnType = strings.Join(strings.Fields(returnType), " ")
+		returnType = strings.ReplaceAll(returnType, " *", "*")
+		if len(returnType) > 0 && returnType != "typedef" {
+			return returnType, true
+		}
+	}
+	return "", false
+}
+
+// stubReturnType returns the type that the stub returns in place of the prototype's return type. Stubs ignore their
+// parameters, and the types of Postgres are not defined within the shim, so only the register that the result is
+// returned in must match: pointers are returned as void pointers, floating-point types as themselves, and all other
+// types as a Datum.
+func stubReturnType(returnType string, ok bool) string {
+	switch {
+	case !ok:
+		return "Datum"
+	case returnType == "void":
+		return "void"
+	case strings.HasSuffix(returnType, "*"):
+		return "void*"
+	case returnType == "double" || returnType == "float8":
+		return "double"
+	case returnType == "float" || returnType == "float4":
+		return "float"
+	case returnType == "bool":
+		return "bool"
+	default:
+		return "Datum"
+	}
+}
+
+// stub returns the C source of the stub for the given symbol.
+func stub(symbol string, returnType string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\nDLLEXPORT %s %s(void) {\n", returnType, symbol))
+	// The call is reported to the host's Logger as well, as extensions may catch the error that follows
+	sb.WriteString(fmt.Sprintf("\tpgext_raise_error(LOG, ERRCODE_FEATURE_NOT_SUPPORTED, \"called unimplemented function \\\"%s\\\"\");\n", symbol))
+	sb.WriteString(fmt.Sprintf("\tpgext_raise_error(ERROR, ERRCODE_FEATURE_NOT_SUPPORTED, \"function \\\"%s\\\" is not supported\");\n", symbol))
+	if returnType != "void" {
+		sb.WriteString("\treturn 0;\n")
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// fileHeader is written at the top of the generated file.
+const fileHeader = `// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by genstubs. DO NOT EDIT.
+
+#include "exports.h"
+
+// These functions are referenced by extensions, but are not yet implemented. Each raises an error when it's called.
+`
@@ -0,0 +1,165 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+#if !defined(_WIN32) && !defined(_WIN64)
+#include <signal.h>
+#if defined(__GLIBC__) || defined(__APPLE__)
+#include <execinfo.h>
+#define PGEXT_HAS_BACKTRACE
+#endif
+
+// guarded_signals are the signals that are caught during guarded calls, as they're raised by invalid memory accesses and
+// other bugs within extensions.
+static const int guarded_signals[] = {SIGSEGV, SIGBUS, SIGFPE, SIGILL};
+#define GUARDED_SIGNAL_COUNT (sizeof(guarded_signals) / sizeof(guarded_signals[0]))
+
+// previous_actions are the handlers that were installed before ours, which are generally those of the Go runtime.
+static struct sigaction previous_actions[GUARDED_SIGNAL_COUNT];
+static bool handlers_installed = false;
+static volatile bool guarded_calls = false;
+// current_guard is the guard of the call that is executing on the current thread, or NULL if the call is unguarded.
+static _Thread_local PgExtGuard* current_guard = NULL;
+
+// forward_signal passes a signal that did not come from a guarded call to the handler that was installed before ours.
+static void forward_signal(int sig, siginfo_t* info, void* ucontext) {
+	for (size_t i = 0; i < GUARDED_SIGNAL_COUNT; i++) {
+		if (guarded_signals[i] != sig) {
+			continue;
+		}
+		struct sigaction* previous = &previous_actions[i];
+		if ((previous->sa_flags & SA_SIGINFO) != 0) {
+			previous->sa_sigaction(sig, info, ucontext);
+		} else if (previous->sa_handler == SIG_DFL) {
+			signal(sig, SIG_DFL);
+			raise(sig);
+		} else if (previous->sa_handler != SIG_IGN) {
+			previous->sa_handler(sig);
+		}
+		return;
+	}
+}
+
+// guard_handler unwinds back to the guarded call that crashed. Only the raw stack is recorded here, as symbolizing the
+// stack is not safe within a signal handler.
+static void guard_handler(int sig, siginfo_t* info, void* ucontext) {
+	PgExtGuard* guard = current_guard;
+	if (guard == NULL) {
+		forward_signal(sig, info, ucontext);
+		return;
+	}
+	current_guard = NULL;
+	PgExtBackendState* state = guard->state;
+	memset(&state->caught, 0, sizeof(PgExtErrorData));
+	state->caught.signal = sig;
+	state->caught.address = info->si_addr;
+#ifdef PGEXT_HAS_BACKTRACE
+	state->crash_frame_count = backtrace(state->crash_frames, PGEXT_CRASH_FRAMES);
+#else
+	state->crash_frame_count = 0;
+#endif
+	siglongjmp(*guard->jump, PGEXT_CRASH_JUMP);
+}
+
+// pgext_set_guarded_calls enables or disables guarded calls. Returns zero if guarded calls are not supported. The
+// handlers are installed once and remain installed, as the Go runtime may have installed its own handlers afterward.
+DLLEXPORT uintptr_t pgext_set_guarded_calls(uintptr_t enabled) {
+	if (enabled != 0 && !handlers_installed) {
+#ifdef PGEXT_HAS_BACKTRACE
+		// The first call to backtrace may load libgcc, which isn't safe within a signal handler, so we do it here
+		void* frame;
+		backtrace(&frame, 1);
+#endif
+		struct sigaction action;
+		memset(&action, 0, sizeof(action));
+		action.sa_sigaction = guard_handler;
+		action.sa_flags = SA_SIGINFO | SA_ONSTACK;
+		sigemptyset(&action.sa_mask);
+		for (size_t i = 0; i < GUARDED_SIGNAL_COUNT; i++) {
+			if (sigaction(guarded_signals[i], &action, &previous_actions[i]) != 0) {
+				return 0;
+			}
+		}
+		handlers_installed = true;
+	}
+	guarded_calls = enabled != 0;
+	return 1;
+}
+
+// pgext_crash_backtrace returns the symbolized stack of the most recent guarded call that crashed, with one frame per
+// line. The result is allocated by malloc, and is NULL if the stack was not recorded.
+DLLEXPORT char* pgext_crash_backtrace(void) {
+#ifdef PGEXT_HAS_BACKTRACE
+	PgExtBackendState* state = pgext_backend_state();
+	if (state->crash_frame_count <= 0) {
+		return NULL;
+	}
+	char** symbols = backtrace_symbols(state->crash_frames, state->crash_frame_count);
+	if (symbols == NULL) {
+		return NULL;
+	}
+	size_t len = 0;
+	for (int i = 0; i < state->crash_frame_count; i++) {
+		len += strlen(symbols[i]) + 1;
+	}
+	char* result = (char*)malloc(len + 1);
+	if (result != NULL) {
+		char* end = result;
+		for (int i = 0; i < state->crash_frame_count; i++) {
+			size_t symbol_len = strlen(symbols[i]);
+			memcpy(end, symbols[i], symbol_len);
+			end[symbol_len] = '\n';
+			end += symbol_len + 1;
+		}
+		*end = '\0';
+	}
+	free(symbols);
+	return result;
+#else
+	return NULL;
+#endif
+}
+
+bool pgext_guarded_calls(void) {
+	return guarded_calls;
+}
+
+// pgext_guard_set sets the guard for the current thread, returning the previous guard.
+PgExtGuard* pgext_guard_set(PgExtGuard* guard) {
+	PgExtGuard* previous = current_guard;
+	current_guard = guard;
+	return previous;
+}
+
+#else
+
+// Windows reports crashes through structured exception handling rather than signals, which we do not yet support.
+DLLEXPORT uintptr_t pgext_set_guarded_calls(uintptr_t enabled) {
+	return enabled == 0 ? 1 : 0;
+}
+
+DLLEXPORT char* pgext_crash_backtrace(void) {
+	return NULL;
+}
+
+bool pgext_guarded_calls(void) {
+	return false;
+}
+
+PgExtGuard* pgext_guard_set(PgExtGuard* guard) {
+	return NULL;
+}
+
+#endif
@@ -0,0 +1,239 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// registry is the host's configuration registry, or NULL if variables are only given their boot values.
+static PgExtConfigRegistry* registry;
+
+// pgext_set_config_registry sets the host's configuration registry. Setting NULL causes variables to keep their boot
+// values, and GetConfigOption to treat every variable as missing.
+DLLEXPORT uintptr_t pgext_set_config_registry(PgExtConfigRegistry* new_registry) {
+	registry = new_registry;
+	return 0;
+}
+
+// pgext_load_session_config lets the host assign the configuration values of the session whose state was just loaded.
+// This is called with the backend lock held.
+void pgext_load_session_config(PgExtBackendState* state) {
+	if (registry != NULL) {
+		registry->load_session(state);
+	}
+}
+
+typedef bool (*GucBoolCheckHook)(bool* newval, void** extra, int source);
+typedef bool (*GucIntCheckHook)(int* newval, void** extra, int source);
+typedef bool (*GucRealCheckHook)(double* newval, void** extra, int source);
+typedef bool (*GucStringCheckHook)(char** newval, void** extra, int source);
+typedef void (*GucBoolAssignHook)(bool newval, void* extra);
+typedef void (*GucIntAssignHook)(int newval, void* extra);
+typedef void (*GucRealAssignHook)(double newval, void* extra);
+typedef void (*GucStringAssignHook)(const char* newval, void* extra);
+
+// call_check_hook calls the check hook that's described by the call, which is given the value of the variable's type.
+static Datum call_check_hook(void* arg) {
+	PgExtConfigHookCall* call = (PgExtConfigHookCall*)arg;
+	switch (call->type) {
+	case PGC_BOOL:
+		call->valid = ((GucBoolCheckHook)call->hook)(&call->bool_value, &call->extra, call->source);
+		break;
+	case PGC_INT:
+	case PGC_ENUM:
+		call->valid = ((GucIntCheckHook)call->hook)(&call->int_value, &call->extra, call->source);
+		break;
+	case PGC_REAL:
+		call->valid = ((GucRealCheckHook)call->hook)(&call->real_value, &call->extra, call->source);
+		break;
+	case PGC_STRING:
+		call->valid = ((GucStringCheckHook)call->hook)(&call->string_value, &call->extra, call->source);
+		break;
+	}
+	return 0;
+}
+
+// call_assign_hook calls the assign hook that's described by the call, which is given the value of the variable's type.
+static Datum call_assign_hook(void* arg) {
+	PgExtConfigHookCall* call = (PgExtConfigHookCall*)arg;
+	switch (call->type) {
+	case PGC_BOOL:
+		((GucBoolAssignHook)call->hook)(call->bool_value, call->extra);
+		break;
+	case PGC_INT:
+	case PGC_ENUM:
+		((GucIntAssignHook)call->hook)(call->int_value, call->extra);
+		break;
+	case PGC_REAL:
+		((GucRealAssignHook)call->hook)(call->real_value, call->extra);
+		break;
+	case PGC_STRING:
+		((GucStringAssignHook)call->hook)(call->string_value, call->extra);
+		break;
+	}
+	return 0;
+}
+
+// pgext_config_call_hook calls the check or assign hook that's described by the call. Returns the error that the hook
+// raised, or NULL if it returned normally.
+DLLEXPORT PgExtErrorData* pgext_config_call_hook(PgExtConfigHookCall* call) {
+	Datum result;
+	return pgext_catch_errors(call->assign ? call_assign_hook : call_check_hook, call, &result);
+}
+
+// guc_raise_host_error raises the error that was given by the host, freeing its message beforehand.
+static void guc_raise_host_error(int sqlerrcode, char* message) {
+	char copy[1024];
+	snprintf(copy, sizeof(copy), "%s", message != NULL ? message : "invalid configuration parameter");
+	free(message);
+	pgext_raise_error(ERROR, sqlerrcode, "%s", copy);
+}
+
+// guc_define registers the variable with the host, whose boot value has already been written into the extension's
+// variable.
+static void guc_define(const char* name, const char* short_desc, const char* long_desc, int type, void* valueAddr,
+	const char* bootValue, double minValue, double maxValue, const struct config_enum_entry* options, int context,
+	int flags, void* check_hook, void* assign_hook) {
+	if (registry == NULL) {
+		return;
+	}
+	PgExtConfigVariable variable = {
+		.name = name,
+		.short_desc = short_desc,
+		.long_desc = long_desc,
+		.type = type,
+		.context = context,
+		.flags = flags,
+		.value_addr = valueAddr,
+		.boot_value = bootValue,
+		.min_value = minValue,
+		.max_value = maxValue,
+		.options = options,
+		.check_hook = check_hook,
+		.assign_hook = assign_hook,
+	};
+	char* message = NULL;
+	int sqlerrcode = registry->define(&variable, &message);
+	if (sqlerrcode != 0) {
+		guc_raise_host_error(sqlerrcode, message);
+	}
+}
+
+DLLEXPORT void DefineCustomBoolVariable(const char* name, const char* short_desc, const char* long_desc,
+	bool* valueAddr, bool bootValue, int context, int flags, void* check_hook, void* assign_hook, void* show_hook) {
+	*valueAddr = bootValue;
+	guc_define(name, short_desc, long_desc, PGC_BOOL, valueAddr, bootValue ? "on" : "off", 0, 0, NULL, context, flags,
+		check_hook, assign_hook);
+}
+
+DLLEXPORT void DefineCustomIntVariable(const char* name, const char* short_desc, const char* long_desc, int* valueAddr,
+	int bootValue, int minValue, int maxValue, int context, int flags, void* check_hook, void* assign_hook,
+	void* show_hook) {
+	char boot[32];
+	snprintf(boot, sizeof(boot), "%d", bootValue);
+	*valueAddr = bootValue;
+	guc_define(name, short_desc, long_desc, PGC_INT, valueAddr, boot, minValue, maxValue, NULL, context, flags,
+		check_hook, assign_hook);
+}
+
+DLLEXPORT void DefineCustomRealVariable(const char* name, const char* short_desc, const char* long_desc,
+	double* valueAddr, double bootValue, double minValue, double maxValue, int context, int flags, void* check_hook,
+	void* assign_hook, void* show_hook) {
+	char boot[64];
+	snprintf(boot, sizeof(boot), "%.15g", bootValue);
+	*valueAddr = bootValue;
+	guc_define(name, short_desc, long_desc, PGC_REAL, valueAddr, boot, minValue, maxValue, NULL, context, flags,
+		check_hook, assign_hook);
+}
+
+DLLEXPORT void DefineCustomStringVariable(const char* name, const char* short_desc, const char* long_desc,
+	char** valueAddr, const char* bootValue, int context, int flags, void* check_hook, void* assign_hook,
+	void* show_hook) {
+	*valueAddr = (char*)bootValue;
+	guc_define(name, short_desc, long_desc, PGC_STRING, valueAddr, bootValue != NULL ? bootValue : "", 0, 0, NULL,
+		context, flags, check_hook, assign_hook);
+}
+
+DLLEXPORT void DefineCustomEnumVariable(const char* name, const char* short_desc, const char* long_desc,
+	int* valueAddr, int bootValue, const struct config_enum_entry* options, int context, int flags, void* check_hook,
+	void* assign_hook, void* show_hook) {
+	const char* boot = NULL;
+	for (const struct config_enum_entry* option = options; option != NULL && option->name != NULL; option++) {
+		if (option->val == bootValue) {
+			boot = option->name;
+			break;
+		}
+	}
+	if (boot == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "invalid boot value %d for enum parameter \"%s\"", bootValue,
+			name);
+	}
+	*valueAddr = bootValue;
+	guc_define(name, short_desc, long_desc, PGC_ENUM, valueAddr, boot, 0, 0, options, context, flags, check_hook,
+		assign_hook);
+}
+
+// MarkGUCPrefixReserved reserves the prefix for the extension that calls it, which removes any placeholders beneath it
+// that the extension did not define.
+DLLEXPORT void MarkGUCPrefixReserved(const char* className) {
+	if (registry != NULL) {
+		registry->reserve_prefix(className);
+	}
+}
+
+// EmitWarningsOnPlaceholders is the name of MarkGUCPrefixReserved before Postgres 15.
+DLLEXPORT void EmitWarningsOnPlaceholders(const char* className) {
+	MarkGUCPrefixReserved(className);
+}
+
+// guc_get returns the current value of the variable, or NULL if it does not exist, in which case an error is raised
+// unless missing_ok is set.
+static const char* guc_get(const char* name, bool missing_ok) {
+	const char* value = NULL;
+	if (registry != NULL && registry->get(name, &value)) {
+		return value;
+	}
+	if (!missing_ok) {
+		pgext_raise_error(ERROR, ERRCODE_UNDEFINED_OBJECT, "unrecognized configuration parameter \"%s\"", name);
+	}
+	return NULL;
+}
+
+DLLEXPORT const char* GetConfigOption(const char* name, bool missing_ok, bool restrict_privileged) {
+	return guc_get(name, missing_ok);
+}
+
+DLLEXPORT char* GetConfigOptionByName(const char* name, const char** varname, bool missing_ok) {
+	const char* value = guc_get(name, missing_ok);
+	if (varname != NULL) {
+		*varname = value != NULL ? name : NULL;
+	}
+	if (value == NULL) {
+		return NULL;
+	}
+	size_t len = strlen(value);
+	char* copy = (char*)palloc(len + 1);
+	memcpy(copy, value, len + 1);
+	return copy;
+}
+
+// SetConfigOption sets the variable from within the given context, resetting it when the value is NULL.
+DLLEXPORT void SetConfigOption(const char* name, const char* value, int context, int source) {
+	if (registry == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_UNDEFINED_OBJECT, "unrecognized configuration parameter \"%s\"", name);
+	}
+	char* message = NULL;
+	int sqlerrcode = registry->set(name, value, context, source, &message);
+	if (sqlerrcode != 0) {
+		guc_raise_host_error(sqlerrcode, message);
+	}
+}
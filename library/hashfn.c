@@ -0,0 +1,180 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// These are Bob Jenkins' lookup3 hashes, with the same initial values as Postgres. Postgres reads whole words from keys
+// that are aligned, and bytes from those that are not, which give the same result on a little-endian machine, so the
+// bytes are always read individually here.
+
+#define rot(x, k) (((x) << (k)) | ((x) >> (32 - (k))))
+
+#define mix(a, b, c) \
+{ \
+	a -= c; a ^= rot(c, 4);  c += b; \
+	b -= a; b ^= rot(a, 6);  a += c; \
+	c -= b; c ^= rot(b, 8);  b += a; \
+	a -= c; a ^= rot(c, 16); c += b; \
+	b -= a; b ^= rot(a, 19); a += c; \
+	c -= b; c ^= rot(b, 4);  b += a; \
+}
+
+#define final(a, b, c) \
+{ \
+	c ^= b; c -= rot(b, 14); \
+	a ^= c; a -= rot(c, 11); \
+	b ^= a; b -= rot(a, 25); \
+	c ^= b; c -= rot(b, 16); \
+	a ^= c; a -= rot(c, 4);  \
+	b ^= a; b -= rot(a, 14); \
+	c ^= b; c -= rot(b, 24); \
+}
+
+// HASH_INITIAL_VALUE is the value that a, b, and c start from for a key of the given length.
+#define HASH_INITIAL_VALUE(len) ((uint32_t)(0x9e3779b9 + (uint32_t)(len) + 3923095))
+
+// read_word reads four bytes of a key as a little-endian word.
+static inline uint32_t read_word(const unsigned char* k) {
+	return k[0] + ((uint32_t)k[1] << 8) + ((uint32_t)k[2] << 16) + ((uint32_t)k[3] << 24);
+}
+
+// hash_bytes_internal mixes the key into a, b, and c, which must hold their initial values. The caller applies the
+// final mix.
+static inline void hash_bytes_internal(const unsigned char* k, uint32_t len, uint32_t* ap, uint32_t* bp, uint32_t* cp) {
+	uint32_t a = *ap;
+	uint32_t b = *bp;
+	uint32_t c = *cp;
+	while (len >= 12) {
+		a += read_word(k);
+		b += read_word(k + 4);
+		c += read_word(k + 8);
+		mix(a, b, c);
+		k += 12;
+		len -= 12;
+	}
+	// The lowest byte of c is reserved for the length, so the remaining bytes of c are shifted past it
+	switch (len) {
+	case 11:
+		c += ((uint32_t)k[10] << 24);
+		// fall through
+	case 10:
+		c += ((uint32_t)k[9] << 16);
+		// fall through
+	case 9:
+		c += ((uint32_t)k[8] << 8);
+		// fall through
+	case 8:
+		b += ((uint32_t)k[7] << 24);
+		// fall through
+	case 7:
+		b += ((uint32_t)k[6] << 16);
+		// fall through
+	case 6:
+		b += ((uint32_t)k[5] << 8);
+		// fall through
+	case 5:
+		b += k[4];
+		// fall through
+	case 4:
+		a += ((uint32_t)k[3] << 24);
+		// fall through
+	case 3:
+		a += ((uint32_t)k[2] << 16);
+		// fall through
+	case 2:
+		a += ((uint32_t)k[1] << 8);
+		// fall through
+	case 1:
+		a += k[0];
+	}
+	*ap = a;
+	*bp = b;
+	*cp = c;
+}
+
+DLLEXPORT uint32_t hash_bytes(const unsigned char* k, int keylen) {
+	uint32_t a, b, c;
+	a = b = c = HASH_INITIAL_VALUE(keylen);
+	hash_bytes_internal(k, (uint32_t)keylen, &a, &b, &c);
+	final(a, b, c);
+	return c;
+}
+
+DLLEXPORT uint64_t hash_bytes_extended(const unsigned char* k, int keylen, uint64_t seed) {
+	uint32_t a, b, c;
+	a = b = c = HASH_INITIAL_VALUE(keylen);
+	// A seed of zero gives the same result as the 32-bit hash in the low half
+	if (seed != 0) {
+		a += (uint32_t)(seed >> 32);
+		b += (uint32_t)seed;
+		mix(a, b, c);
+	}
+	hash_bytes_internal(k, (uint32_t)keylen, &a, &b, &c);
+	final(a, b, c);
+	return ((uint64_t)b << 32) | c;
+}
+
+DLLEXPORT uint32_t hash_bytes_uint32(uint32_t k) {
+	uint32_t a, b, c;
+	a = b = c = HASH_INITIAL_VALUE(sizeof(uint32_t));
+	a += k;
+	final(a, b, c);
+	return c;
+}
+
+DLLEXPORT uint64_t hash_bytes_uint32_extended(uint32_t k, uint64_t seed) {
+	uint32_t a, b, c;
+	a = b = c = HASH_INITIAL_VALUE(sizeof(uint32_t));
+	if (seed != 0) {
+		a += (uint32_t)(seed >> 32);
+		b += (uint32_t)seed;
+		mix(a, b, c);
+	}
+	a += k;
+	final(a, b, c);
+	return ((uint64_t)b << 32) | c;
+}
+
+DLLEXPORT Datum hash_any(const unsigned char* k, int keylen) {
+	return (Datum)hash_bytes(k, keylen);
+}
+
+DLLEXPORT Datum hash_any_extended(const unsigned char* k, int keylen, uint64_t seed) {
+	return (Datum)hash_bytes_extended(k, keylen, seed);
+}
+
+DLLEXPORT Datum hash_uint32(uint32_t k) {
+	return (Datum)hash_bytes_uint32(k);
+}
+
+DLLEXPORT Datum hash_uint32_extended(uint32_t k, uint64_t seed) {
+	return (Datum)hash_bytes_uint32_extended(k, seed);
+}
+
+DLLEXPORT uint32_t string_hash(const void* key, size_t keysize) {
+	// The key may not be terminated when it fills the whole key size, so it's limited to one less than the key size
+	size_t s_len = strlen((const char*)key);
+	if (s_len > keysize - 1) {
+		s_len = keysize - 1;
+	}
+	return hash_bytes((const unsigned char*)key, (int)s_len);
+}
+
+DLLEXPORT uint32_t tag_hash(const void* key, size_t keysize) {
+	return hash_bytes((const unsigned char*)key, (int)keysize);
+}
+
+DLLEXPORT uint32_t uint32_hash(const void* key, size_t keysize) {
+	return hash_bytes_uint32(*((const uint32_t*)key));
+}
@@ -0,0 +1,361 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// can_make_short returns whether a varlena with a 4-byte header may be stored with a 1-byte header instead.
+static bool can_make_short(Form_pg_attribute att, Datum value) {
+	return att->attstorage != 'p' && VARATT_IS_4B_U(value) && VARSIZE_4B(value) - VARHDRSZ + VARHDRSZ_SHORT <= 0x7F;
+}
+
+// att_data_size returns the size of the value as it is stored in a tuple.
+static size_t att_data_size(Form_pg_attribute att, Datum value) {
+	if (att->attlen > 0) {
+		return (size_t)att->attlen;
+	} else if (att->attlen == -1) {
+		if (VARATT_IS_1B_E(value)) {
+			pgext_raise_error(ERROR, ERRCODE_FEATURE_NOT_SUPPORTED, "external TOAST values cannot be stored in a tuple");
+		}
+		if (can_make_short(att, value)) {
+			return VARSIZE_4B(value) - VARHDRSZ + VARHDRSZ_SHORT;
+		}
+		return VARSIZE_ANY(value);
+	}
+	return strlen((const char*)value) + 1;
+}
+
+// att_stored_short returns whether the value is stored without alignment, which is the case for short varlenas.
+static bool att_stored_short(Form_pg_attribute att, Datum value) {
+	return att->attlen == -1 && (VARATT_IS_1B(value) || can_make_short(att, value));
+}
+
+DLLEXPORT size_t heap_compute_data_size(TupleDesc tupleDesc, Datum* values, bool* isnull) {
+	size_t data_length = 0;
+	for (int i = 0; i < tupleDesc->natts; i++) {
+		if (isnull[i]) {
+			continue;
+		}
+		Form_pg_attribute att = TupleDescAttr(tupleDesc, i);
+		if (!att_stored_short(att, values[i])) {
+			data_length = att_align(data_length, att->attalign);
+		}
+		data_length += att_data_size(att, values[i]);
+	}
+	return data_length;
+}
+
+DLLEXPORT HeapTuple heap_form_tuple(TupleDesc tupleDescriptor, Datum* values, bool* isnull) {
+	int natts = tupleDescriptor->natts;
+	bool hasnull = false;
+	for (int i = 0; i < natts; i++) {
+		if (isnull[i]) {
+			hasnull = true;
+			break;
+		}
+	}
+	size_t hoff = SizeofHeapTupleHeader + (hasnull ? BITMAPLEN(natts) : 0);
+	hoff = MAXALIGN(hoff);
+	size_t len = hoff + heap_compute_data_size(tupleDescriptor, values, isnull);
+	HeapTuple tuple = (HeapTuple)palloc0(HEAPTUPLESIZE + len);
+	HeapTupleHeader td = (HeapTupleHeader)((char*)tuple + HEAPTUPLESIZE);
+	tuple->t_len = (uint32_t)len;
+	tuple->t_self.bi_hi = 0xFFFF;
+	tuple->t_self.bi_lo = 0xFFFF;
+	tuple->t_data = td;
+	SET_VARSIZE(td, len);
+	td->t_choice.t_datum.datum_typeid = tupleDescriptor->tdtypeid;
+	td->t_choice.t_datum.datum_typmod = tupleDescriptor->tdtypmod;
+	td->t_ctid = tuple->t_self;
+	td->t_infomask2 = (uint16_t)(natts & HEAP_NATTS_MASK);
+	td->t_hoff = (uint8_t)hoff;
+	if (hasnull) {
+		td->t_infomask |= HEAP_HASNULL;
+	}
+	// The data was zeroed, so the padding that precedes aligned values is already zero, as readers of packed varlenas
+	// expect
+	char* data = (char*)td + hoff;
+	size_t offset = 0;
+	for (int i = 0; i < natts; i++) {
+		if (isnull[i]) {
+			continue;
+		}
+		if (hasnull) {
+			td->t_bits[i >> 3] |= (uint8_t)(1 << (i & 0x07));
+		}
+		Form_pg_attribute att = TupleDescAttr(tupleDescriptor, i);
+		Datum value = values[i];
+		if (att->attbyval) {
+			offset = att_align(offset, att->attalign);
+			switch (att->attlen) {
+			case 1:
+				*(uint8_t*)(data + offset) = (uint8_t)value;
+				break;
+			case 2:
+				*(uint16_t*)(data + offset) = (uint16_t)value;
+				break;
+			case 4:
+				*(uint32_t*)(data + offset) = (uint32_t)value;
+				break;
+			default:
+				*(uint64_t*)(data + offset) = (uint64_t)value;
+				break;
+			}
+			offset += (size_t)att->attlen;
+		} else if (att->attlen == -1) {
+			td->t_infomask |= HEAP_HASVARWIDTH;
+			if (VARATT_IS_1B(value)) {
+				memcpy(data + offset, (const void*)value, VARSIZE_1B(value));
+				offset += VARSIZE_1B(value);
+			} else if (can_make_short(att, value)) {
+				size_t data_size = VARSIZE_4B(value) - VARHDRSZ;
+				*(uint8_t*)(data + offset) = (uint8_t)(((data_size + VARHDRSZ_SHORT) << 1) | 0x01);
+				memcpy(data + offset + VARHDRSZ_SHORT, (const char*)value + VARHDRSZ, data_size);
+				offset += data_size + VARHDRSZ_SHORT;
+			} else {
+				offset = att_align(offset, att->attalign);
+				memcpy(data + offset, (const void*)value, VARSIZE_4B(value));
+				offset += VARSIZE_4B(value);
+			}
+		} else {
+			offset = att_align(offset, att->attalign);
+			size_t size = att_data_size(att, value);
+			memcpy(data + offset, (const void*)value, size);
+			offset += size;
+			if (att->attlen == -2) {
+				td->t_infomask |= HEAP_HASVARWIDTH;
+			}
+		}
+	}
+	return tuple;
+}
+
+DLLEXPORT void heap_deform_tuple(HeapTuple tuple, TupleDesc tupleDesc, Datum* values, bool* isnull) {
+	HeapTupleHeader td = tuple->t_data;
+	bool hasnulls = (td->t_infomask & HEAP_HASNULL) != 0;
+	int tdesc_natts = tupleDesc->natts;
+	int natts = td->t_infomask2 & HEAP_NATTS_MASK;
+	char* data = (char*)td + td->t_hoff;
+	size_t offset = 0;
+	// Attributes that were added to the descriptor after the tuple was formed are null
+	for (int i = natts; i < tdesc_natts; i++) {
+		values[i] = 0;
+		isnull[i] = true;
+	}
+	for (int i = 0; i < natts && i < tdesc_natts; i++) {
+		Form_pg_attribute att = TupleDescAttr(tupleDesc, i);
+		if (hasnulls && (td->t_bits[i >> 3] & (1 << (i & 0x07))) == 0) {
+			values[i] = 0;
+			isnull[i] = true;
+			continue;
+		}
+		isnull[i] = false;
+		// A short varlena begins with a nonzero byte, while the padding before an aligned value is always zero
+		if (att->attlen != -1 || *(uint8_t*)(data + offset) == 0) {
+			offset = att_align(offset, att->attalign);
+		}
+		char* ptr = data + offset;
+		if (att->attbyval) {
+			switch (att->attlen) {
+			case 1:
+				values[i] = (Datum)*(uint8_t*)ptr;
+				break;
+			case 2:
+				values[i] = (Datum)*(uint16_t*)ptr;
+				break;
+			case 4:
+				values[i] = (Datum)*(uint32_t*)ptr;
+				break;
+			default:
+				values[i] = (Datum)*(uint64_t*)ptr;
+				break;
+			}
+			offset += (size_t)att->attlen;
+		} else {
+			values[i] = (Datum)ptr;
+			if (att->attlen > 0) {
+				offset += (size_t)att->attlen;
+			} else if (att->attlen == -1) {
+				offset += VARSIZE_ANY(ptr);
+			} else {
+				offset += strlen(ptr) + 1;
+			}
+		}
+	}
+}
+
+// nocachegetattr is called by heap_getattr for attributes whose offsets have not been cached, which is always the case
+// for the tuples of the shim.
+DLLEXPORT Datum nocachegetattr(HeapTuple tup, int attnum, TupleDesc tupleDesc) {
+	Datum* values = (Datum*)palloc(tupleDesc->natts * sizeof(Datum));
+	bool* isnull = (bool*)palloc(tupleDesc->natts * sizeof(bool));
+	heap_deform_tuple(tup, tupleDesc, values, isnull);
+	Datum value = values[attnum - 1];
+	pfree(values);
+	pfree(isnull);
+	return value;
+}
+
+DLLEXPORT HeapTuple heap_copytuple(HeapTuple tuple) {
+	if (tuple == NULL || tuple->t_data == NULL) {
+		return NULL;
+	}
+	HeapTuple copy = (HeapTuple)palloc(HEAPTUPLESIZE + tuple->t_len);
+	*copy = *tuple;
+	copy->t_data = (HeapTupleHeader)((char*)copy + HEAPTUPLESIZE);
+	memcpy(copy->t_data, tuple->t_data, tuple->t_len);
+	return copy;
+}
+
+DLLEXPORT void heap_freetuple(HeapTuple htup) {
+	pfree(htup);
+}
+
+DLLEXPORT HeapTuple heap_modify_tuple(HeapTuple tuple, TupleDesc tupleDesc, Datum* replValues, bool* replIsnull,
+	bool* doReplace) {
+	int natts = tupleDesc->natts;
+	Datum* values = (Datum*)palloc(natts * sizeof(Datum));
+	bool* isnull = (bool*)palloc(natts * sizeof(bool));
+	heap_deform_tuple(tuple, tupleDesc, values, isnull);
+	for (int i = 0; i < natts; i++) {
+		if (doReplace[i]) {
+			values[i] = replValues[i];
+			isnull[i] = replIsnull[i];
+		}
+	}
+	HeapTuple newTuple = heap_form_tuple(tupleDesc, values, isnull);
+	pfree(values);
+	pfree(isnull);
+	newTuple->t_data->t_ctid = tuple->t_data->t_ctid;
+	newTuple->t_self = tuple->t_self;
+	newTuple->t_tableOid = tuple->t_tableOid;
+	return newTuple;
+}
+
+DLLEXPORT HeapTuple heap_modify_tuple_by_cols(HeapTuple tuple, TupleDesc tupleDesc, int nCols, int* replCols,
+	Datum* replValues, bool* replIsnull) {
+	int natts = tupleDesc->natts;
+	Datum* values = (Datum*)palloc(natts * sizeof(Datum));
+	bool* isnull = (bool*)palloc(natts * sizeof(bool));
+	heap_deform_tuple(tuple, tupleDesc, values, isnull);
+	for (int i = 0; i < nCols; i++) {
+		int attnum = replCols[i];
+		if (attnum <= 0 || attnum > natts) {
+			pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "invalid column number %d", attnum);
+		}
+		values[attnum - 1] = replValues[i];
+		isnull[attnum - 1] = replIsnull[i];
+	}
+	HeapTuple newTuple = heap_form_tuple(tupleDesc, values, isnull);
+	pfree(values);
+	pfree(isnull);
+	newTuple->t_data->t_ctid = tuple->t_data->t_ctid;
+	newTuple->t_self = tuple->t_self;
+	newTuple->t_tableOid = tuple->t_tableOid;
+	return newTuple;
+}
+
+// HeapTupleHeaderGetDatum returns the tuple as a composite Datum. HeapTupleGetDatum is a macro that calls this.
+DLLEXPORT Datum HeapTupleHeaderGetDatum(HeapTupleHeader tuple) {
+	return (Datum)tuple;
+}
+
+DLLEXPORT Datum heap_copy_tuple_as_datum(HeapTuple tuple, TupleDesc tupleDesc) {
+	HeapTupleHeader td = (HeapTupleHeader)palloc(tuple->t_len);
+	memcpy(td, tuple->t_data, tuple->t_len);
+	SET_VARSIZE(td, tuple->t_len);
+	td->t_choice.t_datum.datum_typeid = tupleDesc->tdtypeid;
+	td->t_choice.t_datum.datum_typmod = tupleDesc->tdtypmod;
+	return (Datum)td;
+}
+
+// pgext_deform_composite decodes a composite Datum using the given descriptor, which must describe at least as many
+// attributes as the arrays hold.
+DLLEXPORT uintptr_t pgext_deform_composite(HeapTupleHeader td, TupleDesc tupleDesc, Datum* values, bool* isnull) {
+	HeapTupleData tuple;
+	memset(&tuple, 0, sizeof(tuple));
+	tuple.t_len = VARSIZE_4B(td);
+	tuple.t_data = td;
+	heap_deform_tuple(&tuple, tupleDesc, values, isnull);
+	return 0;
+}
+
+// pgext_form_composite returns a composite Datum of the values, which is allocated within the current memory context.
+// Descriptors of anonymous records are blessed first, so that extensions may find the attributes of the Datum.
+DLLEXPORT Datum pgext_form_composite(TupleDesc tupleDesc, Datum* values, bool* isnull) {
+	HeapTuple tuple = heap_form_tuple(BlessTupleDesc(tupleDesc), values, isnull);
+	return HeapTupleHeaderGetDatum(tuple->t_data);
+}
+
+// get_attribute returns the attribute of the composite value at the given index, which must be within its descriptor.
+static Datum get_attribute(HeapTupleHeader td, TupleDesc tupleDesc, int attrno, bool* isNull) {
+	Datum* values = (Datum*)palloc(tupleDesc->natts * sizeof(Datum));
+	bool* isnull = (bool*)palloc(tupleDesc->natts * sizeof(bool));
+	pgext_deform_composite(td, tupleDesc, values, isnull);
+	Datum value = values[attrno - 1];
+	*isNull = isnull[attrno - 1];
+	pfree(values);
+	pfree(isnull);
+	return value;
+}
+
+// GetAttributeByNum returns the attribute of a composite argument by its number, which starts at 1. The attributes of
+// anonymous records are found through their blessed descriptor.
+DLLEXPORT Datum GetAttributeByNum(HeapTupleHeader tuple, int16_t attrno, bool* isNull) {
+	if (attrno <= 0) {
+		pgext_raise_error(ERROR, ERRCODE_INVALID_PARAMETER_VALUE, "invalid attribute number %d", attrno);
+		return 0;
+	}
+	if (isNull == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_INVALID_PARAMETER_VALUE, "a NULL isNull pointer was passed");
+		return 0;
+	}
+	if (tuple == NULL) {
+		*isNull = true;
+		return 0;
+	}
+	TupleDesc tupleDesc = lookup_rowtype_tupdesc(tuple->t_choice.t_datum.datum_typeid,
+		tuple->t_choice.t_datum.datum_typmod);
+	if (attrno > tupleDesc->natts) {
+		pgext_raise_error(ERROR, ERRCODE_UNDEFINED_COLUMN, "attribute number %d exceeds number of columns %d", attrno,
+			tupleDesc->natts);
+		return 0;
+	}
+	return get_attribute(tuple, tupleDesc, attrno, isNull);
+}
+
+// GetAttributeByName returns the attribute of a composite argument with the given name.
+DLLEXPORT Datum GetAttributeByName(HeapTupleHeader tuple, const char* attname, bool* isNull) {
+	if (attname == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_INVALID_PARAMETER_VALUE, "invalid attribute name");
+		return 0;
+	}
+	if (isNull == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_INVALID_PARAMETER_VALUE, "a NULL isNull pointer was passed");
+		return 0;
+	}
+	if (tuple == NULL) {
+		*isNull = true;
+		return 0;
+	}
+	TupleDesc tupleDesc = lookup_rowtype_tupdesc(tuple->t_choice.t_datum.datum_typeid,
+		tuple->t_choice.t_datum.datum_typmod);
+	for (int i = 0; i < tupleDesc->natts; i++) {
+		Form_pg_attribute att = TupleDescAttr(tupleDesc, i);
+		if (!att->attisdropped && strcmp(att->attname.data, attname) == 0) {
+			return get_attribute(tuple, tupleDesc, i + 1, isNull);
+		}
+	}
+	pgext_raise_error(ERROR, ERRCODE_UNDEFINED_COLUMN, "attribute \"%s\" does not exist", attname);
+	return 0;
+}
@@ -0,0 +1,98 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// InterruptPending is shared by every session, as CHECK_FOR_INTERRUPTS reads it inline, so it's set whenever any
+// session is signaled. The remaining globals belong to the session that is bound to the current thread. The pending
+// interrupts are mirrored from the session's state, which ProcessInterrupts reads instead, while the counts are saved
+// to the state whenever it's unbound.
+DLLEXPORT volatile sig_atomic_t InterruptPending = false;
+DLLEXPORT volatile sig_atomic_t QueryCancelPending = false;
+DLLEXPORT volatile sig_atomic_t ProcDiePending = false;
+DLLEXPORT volatile uint32_t InterruptHoldoffCount = 0;
+DLLEXPORT volatile uint32_t QueryCancelHoldoffCount = 0;
+DLLEXPORT volatile uint32_t CritSectionCount = 0;
+
+// Extensions that are built for Windows also check the queue of emulated signals within CHECK_FOR_INTERRUPTS. Signals
+// are queued per session instead, so this queue is always empty.
+DLLEXPORT volatile int pgwin32_signal_queue = 0;
+DLLEXPORT int pgwin32_signal_mask = 0;
+
+DLLEXPORT void pgwin32_dispatch_queued_signals(void) {
+}
+
+// StatementCancelHandler is the handler of SIGINT that every session begins with, which is how the host cancels the
+// call that a session is running.
+DLLEXPORT void StatementCancelHandler(SIGNAL_ARGS) {
+	pgext_backend_state()->query_cancel_pending = true;
+	QueryCancelPending = true;
+	__atomic_store_n(&InterruptPending, true, __ATOMIC_SEQ_CST);
+	SetLatch(MyLatch);
+}
+
+DLLEXPORT void die(SIGNAL_ARGS) {
+	pgext_backend_state()->proc_die_pending = true;
+	ProcDiePending = true;
+	__atomic_store_n(&InterruptPending, true, __ATOMIC_SEQ_CST);
+	SetLatch(MyLatch);
+}
+
+// ProcessInterrupts is called by CHECK_FOR_INTERRUPTS whenever InterruptPending is set. The signals that were sent to
+// the session are delivered first, and then a pending cancellation or termination is raised as an error, unless
+// interrupts are being held off. Sessions that were not signaled return without doing anything, as InterruptPending is
+// shared with the sessions that were.
+DLLEXPORT void ProcessInterrupts(void) {
+	if (InterruptHoldoffCount != 0 || CritSectionCount != 0) {
+		return;
+	}
+	__atomic_store_n(&InterruptPending, false, __ATOMIC_SEQ_CST);
+	pgext_deliver_signals();
+	PgExtBackendState* state = pgext_backend_state();
+	if (state->proc_die_pending) {
+		state->proc_die_pending = false;
+		state->query_cancel_pending = false;
+		ProcDiePending = false;
+		QueryCancelPending = false;
+		pgext_raise_error(FATAL, ERRCODE_ADMIN_SHUTDOWN, "terminating connection due to administrator command");
+		return;
+	}
+	if (state->query_cancel_pending && QueryCancelHoldoffCount == 0) {
+		state->query_cancel_pending = false;
+		QueryCancelPending = false;
+		pgext_raise_error(ERROR, ERRCODE_QUERY_CANCELED, "canceling statement due to user request");
+		return;
+	}
+	// Other sessions may still have signals to deliver, and a held off cancellation is processed once it's allowed
+	if (state->query_cancel_pending || pgext_signals_pending()) {
+		__atomic_store_n(&InterruptPending, true, __ATOMIC_SEQ_CST);
+	}
+}
+
+// pgext_backend_state_cancel cancels the call that the session is running, which raises an error once the call next
+// checks for interrupts. The state may be bound to another thread.
+DLLEXPORT uintptr_t pgext_backend_state_cancel(PgExtBackendState* state) {
+	return pgext_signal_backend(state, SIGINT);
+}
+
+// pgext_backend_state_clear_cancel discards a cancellation that the session's call ended before processing, as a
+// cancellation only applies to the call that it was sent during. The state must not be bound to another thread.
+DLLEXPORT uintptr_t pgext_backend_state_clear_cancel(PgExtBackendState* state) {
+	pgext_discard_signals(state, (uint64_t)1 << SIGINT);
+	state->query_cancel_pending = false;
+	if (state == pgext_backend_state()) {
+		QueryCancelPending = false;
+	}
+	return 0;
+}
@@ -0,0 +1,293 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// jsonb_in and jsonb_out convert through Go, which parses JSON with encoding/json and shares the numeric encoding. The
+// accessors below read containers in place, as extensions expect the values they return to point into the jsonb.
+extern int pgext_jsonb_parse(const char* str, varlena** result, char** message);
+extern char* pgext_jsonb_format(varlena* jsonb, char** message);
+extern int pgext_numeric_cmp(Numeric a, Numeric b);
+
+#define INTALIGN(len) (((uintptr_t)(len) + 3) & ~((uintptr_t)3))
+
+// jsonb_offset returns the offset of the child's data from the start of the container's data, which is found by
+// summing the lengths of the preceding children back to the nearest one that stores its end offset.
+static uint32_t jsonb_offset(const JsonbContainer* jc, int index) {
+	uint32_t offset = 0;
+	for (int i = index - 1; i >= 0; i--) {
+		offset += JBE_OFFLENFLD(jc->children[i]);
+		if (JBE_HAS_OFF(jc->children[i])) {
+			break;
+		}
+	}
+	return offset;
+}
+
+// jsonb_length returns the length of the child's data, including any padding that precedes it.
+static uint32_t jsonb_length(const JsonbContainer* jc, int index) {
+	JEntry entry = jc->children[index];
+	if (JBE_HAS_OFF(entry)) {
+		return JBE_OFFLENFLD(entry) - jsonb_offset(jc, index);
+	}
+	return JBE_OFFLENFLD(entry);
+}
+
+// jsonb_fill_value reads the child at the given index, whose data begins at the given offset from base_addr.
+static void jsonb_fill_value(JsonbContainer* jc, int index, char* base_addr, uint32_t offset, JsonbValue* result) {
+	JEntry entry = jc->children[index];
+	switch (entry & JENTRY_TYPEMASK) {
+	case JENTRY_ISSTRING:
+		result->type = jbvString;
+		result->val.string.val = base_addr + offset;
+		result->val.string.len = (int)jsonb_length(jc, index);
+		break;
+	case JENTRY_ISNUMERIC:
+		result->type = jbvNumeric;
+		result->val.numeric = (Numeric)(base_addr + INTALIGN(offset));
+		break;
+	case JENTRY_ISBOOL_TRUE:
+	case JENTRY_ISBOOL_FALSE:
+		result->type = jbvBool;
+		result->val.boolean = (entry & JENTRY_TYPEMASK) == JENTRY_ISBOOL_TRUE;
+		break;
+	case JENTRY_ISNULL:
+		result->type = jbvNull;
+		break;
+	default:
+		result->type = jbvBinary;
+		result->val.binary.data = (JsonbContainer*)(base_addr + INTALIGN(offset));
+		result->val.binary.len = (int)(jsonb_length(jc, index) - (INTALIGN(offset) - offset));
+		break;
+	}
+}
+
+// jsonb_scalar_equal returns whether the scalars are equal, which requires that they have the same type.
+static bool jsonb_scalar_equal(const JsonbValue* a, const JsonbValue* b) {
+	if (a->type != b->type) {
+		return false;
+	}
+	switch (a->type) {
+	case jbvNull:
+		return true;
+	case jbvString:
+		return a->val.string.len == b->val.string.len &&
+			memcmp(a->val.string.val, b->val.string.val, (size_t)a->val.string.len) == 0;
+	case jbvNumeric:
+		return pgext_numeric_cmp((Numeric)pg_detoast_datum((varlena*)a->val.numeric),
+			(Numeric)pg_detoast_datum((varlena*)b->val.numeric)) == 0;
+	case jbvBool:
+		return a->val.boolean == b->val.boolean;
+	default:
+		return false;
+	}
+}
+
+DLLEXPORT Datum jsonb_in(FunctionCallInfo fcinfo) {
+	varlena* result = NULL;
+	char* message = NULL;
+	int sqlerrcode = pgext_jsonb_parse((const char*)fcinfo->args[0].value, &result, &message);
+	if (sqlerrcode != 0) {
+		char copy[1024];
+		snprintf(copy, sizeof(copy), "%s", message != NULL ? message : "invalid input syntax for type json");
+		free(message);
+		pgext_raise_error(ERROR, sqlerrcode, "%s", copy);
+		return (Datum)0;
+	}
+	return (Datum)result;
+}
+
+DLLEXPORT Datum jsonb_out(FunctionCallInfo fcinfo) {
+	char* message = NULL;
+	char* result = pgext_jsonb_format(pg_detoast_datum((varlena*)fcinfo->args[0].value), &message);
+	if (result == NULL) {
+		char copy[1024];
+		snprintf(copy, sizeof(copy), "%s", message != NULL ? message : "invalid jsonb data");
+		free(message);
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "%s", copy);
+		return (Datum)0;
+	}
+	return (Datum)result;
+}
+
+DLLEXPORT JsonbValue* getKeyJsonValueFromContainer(JsonbContainer* container, const char* keyVal, int keyLen,
+	JsonbValue* res) {
+	if (!JsonContainerIsObject(container)) {
+		return NULL;
+	}
+	uint32_t count = JsonContainerSize(container);
+	char* base_addr = (char*)(container->children + count * 2);
+	// Keys are sorted by their length, then by their bytes
+	uint32_t low = 0;
+	uint32_t high = count;
+	while (low < high) {
+		uint32_t middle = low + (high - low) / 2;
+		uint32_t offset = jsonb_offset(container, (int)middle);
+		int length = (int)jsonb_length(container, (int)middle);
+		int difference = length != keyLen ? (length > keyLen ? 1 : -1)
+			: memcmp(base_addr + offset, keyVal, (size_t)keyLen);
+		if (difference == 0) {
+			if (res == NULL) {
+				res = (JsonbValue*)palloc(sizeof(JsonbValue));
+			}
+			int index = (int)(middle + count);
+			jsonb_fill_value(container, index, base_addr, jsonb_offset(container, index), res);
+			return res;
+		} else if (difference < 0) {
+			low = middle + 1;
+		} else {
+			high = middle;
+		}
+	}
+	return NULL;
+}
+
+DLLEXPORT JsonbValue* findJsonbValueFromContainer(JsonbContainer* container, uint32_t flags, JsonbValue* key) {
+	uint32_t count = JsonContainerSize(container);
+	if (count == 0) {
+		return NULL;
+	}
+	if ((flags & JB_FARRAY) != 0 && JsonContainerIsArray(container)) {
+		JsonbValue* result = (JsonbValue*)palloc(sizeof(JsonbValue));
+		char* base_addr = (char*)(container->children + count);
+		uint32_t offset = 0;
+		for (uint32_t i = 0; i < count; i++) {
+			jsonb_fill_value(container, (int)i, base_addr, offset, result);
+			if (key->type == result->type && jsonb_scalar_equal(key, result)) {
+				return result;
+			}
+			offset = JBE_HAS_OFF(container->children[i]) ? JBE_OFFLENFLD(container->children[i])
+				: offset + JBE_OFFLENFLD(container->children[i]);
+		}
+		pfree(result);
+	} else if ((flags & JB_FOBJECT) != 0 && JsonContainerIsObject(container) && key->type == jbvString) {
+		return getKeyJsonValueFromContainer(container, key->val.string.val, key->val.string.len, NULL);
+	}
+	return NULL;
+}
+
+DLLEXPORT JsonbValue* getIthJsonbValueFromContainer(JsonbContainer* container, uint32_t i) {
+	if (!JsonContainerIsArray(container)) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "not a jsonb array");
+		return NULL;
+	}
+	uint32_t count = JsonContainerSize(container);
+	if (i >= count) {
+		return NULL;
+	}
+	JsonbValue* result = (JsonbValue*)palloc(sizeof(JsonbValue));
+	char* base_addr = (char*)(container->children + count);
+	jsonb_fill_value(container, (int)i, base_addr, jsonb_offset(container, (int)i), result);
+	return result;
+}
+
+DLLEXPORT bool JsonbExtractScalar(JsonbContainer* jbc, JsonbValue* res) {
+	if (!JsonContainerIsArray(jbc) || !JsonContainerIsScalar(jbc)) {
+		res->type = jbvNull;
+		return false;
+	}
+	jsonb_fill_value(jbc, 0, (char*)(jbc->children + 1), 0, res);
+	return true;
+}
+
+// jsonb_iterator returns a new iterator over the container, which returns to its parent once the container ends.
+static JsonbIterator* jsonb_iterator(JsonbContainer* container, JsonbIterator* parent) {
+	JsonbIterator* it = (JsonbIterator*)palloc0(sizeof(JsonbIterator));
+	it->container = container;
+	it->parent = parent;
+	it->nElems = JsonContainerSize(container);
+	it->children = container->children;
+	if (JsonContainerIsObject(container)) {
+		it->dataProper = (char*)(it->children + it->nElems * 2);
+		it->curValueOffset = jsonb_offset(container, (int)it->nElems);
+		it->state = JBI_OBJECT_START;
+	} else {
+		it->dataProper = (char*)(it->children + it->nElems);
+		it->isScalar = JsonContainerIsScalar(container);
+		it->state = JBI_ARRAY_START;
+	}
+	return it;
+}
+
+// jsonb_iterator_advance moves the offset past the data of the given child.
+static uint32_t jsonb_iterator_advance(uint32_t offset, JEntry entry) {
+	return JBE_HAS_OFF(entry) ? JBE_OFFLENFLD(entry) : offset + JBE_OFFLENFLD(entry);
+}
+
+// jsonb_iterator_pop frees the iterator, returning its parent.
+static JsonbIterator* jsonb_iterator_pop(JsonbIterator* it) {
+	JsonbIterator* parent = it->parent;
+	pfree(it);
+	return parent;
+}
+
+DLLEXPORT JsonbIterator* JsonbIteratorInit(JsonbContainer* container) {
+	return jsonb_iterator(container, NULL);
+}
+
+DLLEXPORT JsonbIteratorToken JsonbIteratorNext(JsonbIterator** it, JsonbValue* val, bool skipNested) {
+	while (*it != NULL) {
+		JsonbIterator* cur = *it;
+		switch (cur->state) {
+		case JBI_ARRAY_START:
+			val->type = jbvArray;
+			val->val.array.nElems = (int)cur->nElems;
+			val->val.array.rawScalar = cur->isScalar;
+			val->val.array.elems = NULL;
+			cur->state = JBI_ARRAY_ELEM;
+			return WJB_BEGIN_ARRAY;
+		case JBI_ARRAY_ELEM:
+			if ((uint32_t)cur->curIndex >= cur->nElems) {
+				*it = jsonb_iterator_pop(cur);
+				return WJB_END_ARRAY;
+			}
+			jsonb_fill_value(cur->container, cur->curIndex, cur->dataProper, cur->curDataOffset, val);
+			cur->curDataOffset = jsonb_iterator_advance(cur->curDataOffset, cur->children[cur->curIndex]);
+			cur->curIndex++;
+			if (!IsAJsonbScalar(val) && !skipNested) {
+				*it = jsonb_iterator(val->val.binary.data, cur);
+				continue;
+			}
+			return WJB_ELEM;
+		case JBI_OBJECT_START:
+			val->type = jbvObject;
+			val->val.object.nPairs = (int)cur->nElems;
+			val->val.object.pairs = NULL;
+			cur->state = JBI_OBJECT_KEY;
+			return WJB_BEGIN_OBJECT;
+		case JBI_OBJECT_KEY:
+			if ((uint32_t)cur->curIndex >= cur->nElems) {
+				*it = jsonb_iterator_pop(cur);
+				return WJB_END_OBJECT;
+			}
+			jsonb_fill_value(cur->container, cur->curIndex, cur->dataProper, cur->curDataOffset, val);
+			cur->state = JBI_OBJECT_VALUE;
+			return WJB_KEY;
+		case JBI_OBJECT_VALUE: {
+			int index = cur->curIndex + (int)cur->nElems;
+			cur->state = JBI_OBJECT_KEY;
+			jsonb_fill_value(cur->container, index, cur->dataProper, cur->curValueOffset, val);
+			cur->curDataOffset = jsonb_iterator_advance(cur->curDataOffset, cur->children[cur->curIndex]);
+			cur->curValueOffset = jsonb_iterator_advance(cur->curValueOffset, cur->children[index]);
+			cur->curIndex++;
+			if (!IsAJsonbScalar(val) && !skipNested) {
+				*it = jsonb_iterator(val->val.binary.data, cur);
+				continue;
+			}
+			return WJB_VALUE;
+		}
+		}
+	}
+	return WJB_DONE;
+}
@@ -0,0 +1,439 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extension_cgo
+
+/*
+#include "exports.h"
+*/
+import "C"
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"unsafe"
+)
+
+// These describe the layout of a jsonb container, which matches jsonb.h of Postgres. A container is a header that
+// holds its flags and count, followed by an entry for each child, followed by the data of each child. Objects store
+// their keys before their values.
+const (
+	jsonbCountMask      = 0x0FFFFFFF
+	jsonbFlagScalar     = 0x10000000
+	jsonbFlagObject     = 0x20000000
+	jsonbFlagArray      = 0x40000000
+	jentryOffLenMask    = 0x0FFFFFFF
+	jentryTypeMask      = 0x70000000
+	jentryHasOff        = 0x80000000
+	jentryIsString      = 0x00000000
+	jentryIsNumeric     = 0x10000000
+	jentryIsBoolFalse   = 0x20000000
+	jentryIsBoolTrue    = 0x30000000
+	jentryIsNull        = 0x40000000
+	jentryIsContainer   = 0x50000000
+	jsonbOffsetStride   = 32
+	jsonbVarHdrSz       = 4
+	jsonbMaxNestedDepth = 6400
+)
+
+// jsonbKind is the kind of a jsonb value.
+type jsonbKind uint8
+
+const (
+	jsonbNull jsonbKind = iota
+	jsonbString
+	jsonbNumeric
+	jsonbBool
+	jsonbArray
+	jsonbObject
+)
+
+// jsonbNode is a jsonb value that has been parsed from text or decoded from a container. The keys of an object are
+// sorted and unique.
+type jsonbNode struct {
+	kind    jsonbKind
+	str     string
+	numeric numericValue
+	boolean bool
+	// elems are the elements of an array, or the values of an object.
+	elems []jsonbNode
+	keys  []string
+	// scalar is true for the array that wraps a scalar at the top level.
+	scalar bool
+}
+
+//export pgext_jsonb_parse
+func pgext_jsonb_parse(str *C.pgext_const_char, result **C.varlena, message **C.char) C.int {
+	input := C.GoString(str)
+	node, err := parseJsonb(input)
+	if err != nil {
+		var ne numericError
+		if errors.As(err, &ne) {
+			return numericErrorCode(err, message)
+		}
+		*message = C.CString(`invalid input syntax for type json: "` + input + `"`)
+		return C.ERRCODE_INVALID_TEXT_REPRESENTATION
+	}
+	data, err := encodeJsonb(node)
+	if err != nil {
+		return numericErrorCode(err, message)
+	}
+	jsonb := C.palloc(C.size_t(len(data)))
+	copy(unsafe.Slice((*byte)(jsonb), len(data)), data)
+	*result = (*C.varlena)(jsonb)
+	return 0
+}
+
+//export pgext_jsonb_format
+func pgext_jsonb_format(jsonb *C.varlena, message **C.char) *C.char {
+	size := int(*(*uint32)(unsafe.Pointer(jsonb)) >> 2)
+	data := unsafe.Slice((*byte)(unsafe.Pointer(jsonb)), size)
+	node, err := decodeJsonbContainer(data, jsonbVarHdrSz, 0)
+	if err != nil {
+		*message = C.CString(err.Error())
+		return nil
+	}
+	var sb strings.Builder
+	node.writeText(&sb)
+	str := sb.String()
+	cStr := (*C.char)(C.palloc(C.size_t(len(str) + 1)))
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(cStr)), len(str)+1), append([]byte(str), 0))
+	return cStr
+}
+
+// parseJsonb parses the text of a JSON document. Duplicate keys keep their last value, as they do in Postgres.
+func parseJsonb(input string) (jsonbNode, error) {
+	decoder := json.NewDecoder(strings.NewReader(input))
+	decoder.UseNumber()
+	node, err := parseJsonbValue(decoder, 0)
+	if err != nil {
+		return jsonbNode{}, err
+	}
+	if _, err = decoder.Token(); err != io.EOF {
+		return jsonbNode{}, errors.New("unexpected trailing data")
+	}
+	if node.kind != jsonbArray && node.kind != jsonbObject {
+		return jsonbNode{kind: jsonbArray, elems: []jsonbNode{node}, scalar: true}, nil
+	}
+	return node, nil
+}
+
+// parseJsonbValue parses the next value from the decoder.
+func parseJsonbValue(decoder *json.Decoder, depth int) (jsonbNode, error) {
+	if depth > jsonbMaxNestedDepth {
+		return jsonbNode{}, errors.New("JSON is nested too deeply")
+	}
+	token, err := decoder.Token()
+	if err != nil {
+		return jsonbNode{}, err
+	}
+	switch token := token.(type) {
+	case nil:
+		return jsonbNode{kind: jsonbNull}, nil
+	case bool:
+		return jsonbNode{kind: jsonbBool, boolean: token}, nil
+	case string:
+		if strings.IndexByte(token, 0) >= 0 {
+			return jsonbNode{}, errors.New("unsupported Unicode escape sequence")
+		}
+		return jsonbNode{kind: jsonbString, str: token}, nil
+	case json.Number:
+		value, err := parseNumeric(token.String())
+		if err != nil {
+			return jsonbNode{}, err
+		}
+		return jsonbNode{kind: jsonbNumeric, numeric: value}, nil
+	case json.Delim:
+		if token == '[' {
+			node := jsonbNode{kind: jsonbArray}
+			for decoder.More() {
+				elem, err := parseJsonbValue(decoder, depth+1)
+				if err != nil {
+					return jsonbNode{}, err
+				}
+				node.elems = append(node.elems, elem)
+			}
+			_, err = decoder.Token()
+			return node, err
+		}
+		values := make(map[string]jsonbNode)
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return jsonbNode{}, err
+			}
+			keyStr := key.(string)
+			if strings.IndexByte(keyStr, 0) >= 0 {
+				return jsonbNode{}, errors.New("unsupported Unicode escape sequence")
+			}
+			if values[keyStr], err = parseJsonbValue(decoder, depth+1); err != nil {
+				return jsonbNode{}, err
+			}
+		}
+		if _, err = decoder.Token(); err != nil {
+			return jsonbNode{}, err
+		}
+		node := jsonbNode{kind: jsonbObject}
+		node.keys = slices.SortedFunc(maps.Keys(values), compareJsonbKeys)
+		for _, key := range node.keys {
+			node.elems = append(node.elems, values[key])
+		}
+		return node, nil
+	default:
+		return jsonbNode{}, errors.New("unexpected token")
+	}
+}
+
+// compareJsonbKeys orders the keys of an object, which are sorted by length before their bytes so that lookups may
+// compare lengths first.
+func compareJsonbKeys(a string, b string) int {
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return strings.Compare(a, b)
+}
+
+// encodeJsonb returns the jsonb varlena of the node, including its header.
+func encodeJsonb(node jsonbNode) ([]byte, error) {
+	buf := make([]byte, jsonbVarHdrSz)
+	buf, err := node.appendContainer(buf)
+	if err != nil {
+		return nil, err
+	}
+	binary.LittleEndian.PutUint32(buf, uint32(len(buf))<<2)
+	return buf, nil
+}
+
+// appendContainer appends the container of an array or object to the buffer. The buffer begins at the start of the
+// varlena, as numerics and containers are aligned relative to it.
+func (node jsonbNode) appendContainer(buf []byte) ([]byte, error) {
+	count := len(node.elems)
+	header := uint32(count)
+	children := node.elems
+	if node.kind == jsonbObject {
+		header |= jsonbFlagObject
+		children = make([]jsonbNode, 0, 2*count)
+		for _, key := range node.keys {
+			children = append(children, jsonbNode{kind: jsonbString, str: key})
+		}
+		children = append(children, node.elems...)
+	} else {
+		header |= jsonbFlagArray
+		if node.scalar {
+			header |= jsonbFlagScalar
+		}
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, header)
+	entriesStart := len(buf)
+	buf = append(buf, make([]byte, 4*len(children))...)
+	totalLength := 0
+	for i, child := range children {
+		start := len(buf)
+		var entry uint32
+		switch child.kind {
+		case jsonbNull:
+			entry = jentryIsNull
+		case jsonbBool:
+			entry = jentryIsBoolFalse
+			if child.boolean {
+				entry = jentryIsBoolTrue
+			}
+		case jsonbString:
+			entry = jentryIsString
+			buf = append(buf, child.str...)
+		case jsonbNumeric:
+			entry = jentryIsNumeric
+			buf = padJsonbBuffer(buf)
+			data := child.numeric.encode()
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(numericVarHdrSz+len(data))<<2)
+			buf = append(buf, data...)
+		default:
+			entry = jentryIsContainer
+			var err error
+			if buf, err = child.appendContainer(padJsonbBuffer(buf)); err != nil {
+				return nil, err
+			}
+		}
+		totalLength += len(buf) - start
+		if totalLength > jentryOffLenMask {
+			return nil, numericError{sqlerrcode: C.ERRCODE_PROGRAM_LIMIT_EXCEEDED,
+				message: "total size of jsonb array elements exceeds the maximum of 268435455 bytes"}
+		}
+		// Every so often an entry stores its end offset rather than its length, so that offsets may be found quickly
+		if i%jsonbOffsetStride == 0 {
+			entry |= jentryHasOff | uint32(totalLength)
+		} else {
+			entry |= uint32(len(buf) - start)
+		}
+		binary.LittleEndian.PutUint32(buf[entriesStart+4*i:], entry)
+	}
+	return buf, nil
+}
+
+// padJsonbBuffer pads the buffer to a multiple of four bytes.
+func padJsonbBuffer(buf []byte) []byte {
+	for len(buf)%4 != 0 {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+// decodeJsonbContainer decodes the container at the given offset of the varlena.
+func decodeJsonbContainer(data []byte, start int, depth int) (jsonbNode, error) {
+	corrupt := errors.New("invalid jsonb data")
+	if depth > jsonbMaxNestedDepth || start+4 > len(data) {
+		return jsonbNode{}, corrupt
+	}
+	header := binary.LittleEndian.Uint32(data[start:])
+	count := int(header & jsonbCountMask)
+	node := jsonbNode{kind: jsonbArray, scalar: header&jsonbFlagScalar != 0}
+	nchildren := count
+	if header&jsonbFlagObject != 0 {
+		node.kind = jsonbObject
+		nchildren *= 2
+	}
+	entriesStart := start + 4
+	base := entriesStart + 4*nchildren
+	if base > len(data) {
+		return jsonbNode{}, corrupt
+	}
+	offset := 0
+	children := make([]jsonbNode, nchildren)
+	for i := range children {
+		entry := binary.LittleEndian.Uint32(data[entriesStart+4*i:])
+		end := int(entry & jentryOffLenMask)
+		if entry&jentryHasOff == 0 {
+			end += offset
+		}
+		if end < offset || base+end > len(data) {
+			return jsonbNode{}, corrupt
+		}
+		child := data[base+offset : base+end]
+		// Numerics and containers are preceded by the padding that aligns them
+		aligned := (offset + 3) &^ 3
+		switch entry & jentryTypeMask {
+		case jentryIsNull:
+			children[i] = jsonbNode{kind: jsonbNull}
+		case jentryIsBoolFalse, jentryIsBoolTrue:
+			children[i] = jsonbNode{kind: jsonbBool, boolean: entry&jentryTypeMask == jentryIsBoolTrue}
+		case jentryIsString:
+			children[i] = jsonbNode{kind: jsonbString, str: string(child)}
+		case jentryIsNumeric:
+			if base+aligned+numericVarHdrSz > base+end {
+				return jsonbNode{}, corrupt
+			}
+			value, err := decodeNumeric(data[base+aligned+numericVarHdrSz : base+end])
+			if err != nil {
+				return jsonbNode{}, err
+			}
+			children[i] = jsonbNode{kind: jsonbNumeric, numeric: value}
+		case jentryIsContainer:
+			var err error
+			if children[i], err = decodeJsonbContainer(data[:base+end], base+aligned, depth+1); err != nil {
+				return jsonbNode{}, err
+			}
+		default:
+			return jsonbNode{}, corrupt
+		}
+		offset = end
+	}
+	if node.kind == jsonbObject {
+		for _, key := range children[:count] {
+			node.keys = append(node.keys, key.str)
+		}
+		node.elems = children[count:]
+	} else {
+		node.elems = children
+	}
+	return node, nil
+}
+
+// writeText writes the text form of the node, which matches jsonb_out.
+func (node jsonbNode) writeText(sb *strings.Builder) {
+	switch node.kind {
+	case jsonbNull:
+		sb.WriteString("null")
+	case jsonbBool:
+		if node.boolean {
+			sb.WriteString("true")
+		} else {
+			sb.WriteString("false")
+		}
+	case jsonbString:
+		writeJSONString(sb, node.str)
+	case jsonbNumeric:
+		sb.WriteString(node.numeric.String())
+	case jsonbArray:
+		if node.scalar && len(node.elems) == 1 {
+			node.elems[0].writeText(sb)
+			return
+		}
+		sb.WriteByte('[')
+		for i, elem := range node.elems {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			elem.writeText(sb)
+		}
+		sb.WriteByte(']')
+	case jsonbObject:
+		sb.WriteByte('{')
+		for i, key := range node.keys {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			writeJSONString(sb, key)
+			sb.WriteString(": ")
+			node.elems[i].writeText(sb)
+		}
+		sb.WriteByte('}')
+	}
+}
+
+// writeJSONString writes the string as a quoted JSON string, escaping the same characters as escape_json.
+func writeJSONString(sb *strings.Builder, s string) {
+	const hexDigits = "0123456789abcdef"
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		default:
+			if c < 0x20 {
+				sb.WriteString(`\u00`)
+				sb.WriteByte(hexDigits[c>>4])
+				sb.WriteByte(hexDigits[c&0xF])
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+	}
+	sb.WriteByte('"')
+}
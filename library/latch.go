@@ -0,0 +1,138 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extension_cgo
+
+/*
+#include "exports.h"
+*/
+import "C"
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+var (
+	// latchWaiters contains the wakeups of the sessions that are waiting on each latch.
+	latchWaiters = make(map[*C.Latch][]chan struct{})
+	// sessionWakeups contains the wakeup of each session that has waited on a latch or been signaled. A wakeup holds at
+	// most one pending wake, so that a wake that arrives before the session waits is not lost.
+	sessionWakeups = make(map[*C.PgExtBackendState]chan struct{})
+	// latchMutex gates access to the waiters and wakeups, as latches are set from any session.
+	latchMutex = &sync.Mutex{}
+)
+
+// sessionWakeup returns the wakeup of the session, creating it if it does not yet exist. The latchMutex must be held.
+func sessionWakeup(state *C.PgExtBackendState) chan struct{} {
+	wakeup, ok := sessionWakeups[state]
+	if !ok {
+		wakeup = make(chan struct{}, 1)
+		sessionWakeups[state] = wakeup
+	}
+	return wakeup
+}
+
+// wake wakes the session of the wakeup, unless it already has a pending wake.
+func wake(wakeup chan struct{}) {
+	select {
+	case wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// pgext_latch_wait waits until the session is woken, either through the latch or by a signal, or until the timeout in
+// milliseconds elapses. A NULL latch only waits on signals, and a negative timeout waits indefinitely. Returns the time
+// that remains of the timeout, which is zero once it has elapsed, or -1 when there is no timeout. Wakes may be
+// spurious, so the caller checks the latch again.
+//
+//export pgext_latch_wait
+func pgext_latch_wait(state *C.PgExtBackendState, latch *C.Latch, timeout C.long) C.long {
+	start := time.Now()
+	latchMutex.Lock()
+	wakeup := sessionWakeup(state)
+	if latch != nil {
+		latchWaiters[latch] = append(latchWaiters[latch], wakeup)
+	}
+	latchMutex.Unlock()
+	defer func() {
+		if latch != nil {
+			latchMutex.Lock()
+			waiters := slices.DeleteFunc(latchWaiters[latch], func(w chan struct{}) bool { return w == wakeup })
+			if len(waiters) == 0 {
+				delete(latchWaiters, latch)
+			} else {
+				latchWaiters[latch] = waiters
+			}
+			latchMutex.Unlock()
+		}
+	}()
+	// The latch may have been set, or a signal sent, before the session was added to the waiters
+	if (latch != nil && atomic.LoadInt32((*int32)(unsafe.Pointer(&latch.is_set))) != 0) ||
+		atomic.LoadUint64((*uint64)(unsafe.Pointer(&state.pending_signals))) != 0 {
+		return remainingTimeout(start, timeout)
+	}
+	if timeout < 0 {
+		<-wakeup
+		return -1
+	}
+	timer := time.NewTimer(time.Duration(timeout) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-wakeup:
+		return remainingTimeout(start, timeout)
+	case <-timer.C:
+		return 0
+	}
+}
+
+// remainingTimeout returns the milliseconds that remain of a timeout that began at the given time.
+func remainingTimeout(start time.Time, timeout C.long) C.long {
+	if timeout < 0 {
+		return -1
+	}
+	remaining := time.Duration(timeout)*time.Millisecond - time.Since(start)
+	if remaining <= 0 {
+		return 0
+	}
+	// Rounding up ensures that a remainder of less than a millisecond is still waited on
+	return C.long((remaining + time.Millisecond - 1) / time.Millisecond)
+}
+
+//export pgext_latch_wake
+func pgext_latch_wake(latch *C.Latch) {
+	latchMutex.Lock()
+	defer latchMutex.Unlock()
+	for _, wakeup := range latchWaiters[latch] {
+		wake(wakeup)
+	}
+}
+
+//export pgext_state_wake
+func pgext_state_wake(state *C.PgExtBackendState) {
+	latchMutex.Lock()
+	defer latchMutex.Unlock()
+	wake(sessionWakeup(state))
+}
+
+// pgext_latch_forget removes the wakeup of a session that is being destroyed.
+//
+//export pgext_latch_forget
+func pgext_latch_forget(state *C.PgExtBackendState) {
+	latchMutex.Lock()
+	defer latchMutex.Unlock()
+	delete(sessionWakeups, state)
+}
@@ -0,0 +1,484 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+#include <stdlib.h>
+
+// Lists are allocated within the memory context that is current when they're created, and their cells are always
+// allocated within the same context as the header, so that a List is freed with its context no matter which context is
+// current when it grows. The functions follow Postgres, such that modifying a List may move its cells, and any function
+// that returns a List may return a different one than it was given.
+
+// LIST_HEADER_OVERHEAD is the size of the List header in cells, rounded up.
+#define LIST_HEADER_OVERHEAD ((int)((offsetof(List, initial_elements) - 1) / sizeof(ListCell) + 1))
+
+// list_next_power2 returns the smallest power of two that is at least the given size.
+static int list_next_power2(int size) {
+	int result = 1;
+	while (result < size) {
+		result <<= 1;
+	}
+	return result;
+}
+
+// new_list returns a List with the given number of cells, which are left uninitialized.
+static List* new_list(NodeTag type, int min_size) {
+	int max_size = list_next_power2(min_size + LIST_HEADER_OVERHEAD > 8 ? min_size + LIST_HEADER_OVERHEAD : 8);
+	max_size -= LIST_HEADER_OVERHEAD;
+	List* newlist = (List*)palloc(offsetof(List, initial_elements) + max_size * sizeof(ListCell));
+	newlist->type = type;
+	newlist->length = min_size;
+	newlist->max_length = max_size;
+	newlist->elements = newlist->initial_elements;
+	return newlist;
+}
+
+// enlarge_list grows the cells of the List so that it holds at least the given number of cells.
+static void enlarge_list(List* list, int min_size) {
+	int new_max_len = list_next_power2(min_size > 16 ? min_size : 16);
+	if (list->elements == list->initial_elements) {
+		list->elements = (ListCell*)MemoryContextAlloc(GetMemoryChunkContext(list), new_max_len * sizeof(ListCell));
+		memcpy(list->elements, list->initial_elements, list->length * sizeof(ListCell));
+	} else {
+		list->elements = (ListCell*)repalloc(list->elements, new_max_len * sizeof(ListCell));
+	}
+	list->max_length = new_max_len;
+}
+
+// new_tail_cell adds a cell to the end of the List, returning the new cell.
+static ListCell* new_tail_cell(List* list) {
+	if (list->length >= list->max_length) {
+		enlarge_list(list, list->length + 1);
+	}
+	list->length++;
+	return &list->elements[list->length - 1];
+}
+
+// insert_new_cell inserts a cell before the given position of the List, returning the new cell.
+static ListCell* insert_new_cell(List* list, int pos) {
+	if (list->length >= list->max_length) {
+		enlarge_list(list, list->length + 1);
+	}
+	if (pos < list->length) {
+		memmove(&list->elements[pos + 1], &list->elements[pos], (list->length - pos) * sizeof(ListCell));
+	}
+	list->length++;
+	return &list->elements[pos];
+}
+
+// check_list_type raises an error if the List exists and does not have the given tag.
+static void check_list_type(const List* list, NodeTag type) {
+	if (list != NIL && list->type != type) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "unrecognized list node type: %d", (int)list->type);
+	}
+}
+
+DLLEXPORT List* list_make1_impl(NodeTag t, ListCell datum1) {
+	List* list = new_list(t, 1);
+	list->elements[0] = datum1;
+	return list;
+}
+
+DLLEXPORT List* list_make2_impl(NodeTag t, ListCell datum1, ListCell datum2) {
+	List* list = new_list(t, 2);
+	list->elements[0] = datum1;
+	list->elements[1] = datum2;
+	return list;
+}
+
+DLLEXPORT List* list_make3_impl(NodeTag t, ListCell datum1, ListCell datum2, ListCell datum3) {
+	List* list = new_list(t, 3);
+	list->elements[0] = datum1;
+	list->elements[1] = datum2;
+	list->elements[2] = datum3;
+	return list;
+}
+
+DLLEXPORT List* list_make4_impl(NodeTag t, ListCell datum1, ListCell datum2, ListCell datum3, ListCell datum4) {
+	List* list = new_list(t, 4);
+	list->elements[0] = datum1;
+	list->elements[1] = datum2;
+	list->elements[2] = datum3;
+	list->elements[3] = datum4;
+	return list;
+}
+
+DLLEXPORT List* list_make5_impl(NodeTag t, ListCell datum1, ListCell datum2, ListCell datum3, ListCell datum4, ListCell datum5) {
+	List* list = new_list(t, 5);
+	list->elements[0] = datum1;
+	list->elements[1] = datum2;
+	list->elements[2] = datum3;
+	list->elements[3] = datum4;
+	list->elements[4] = datum5;
+	return list;
+}
+
+DLLEXPORT List* lappend(List* list, void* datum) {
+	check_list_type(list, T_List);
+	if (list == NIL) {
+		list = new_list(T_List, 1);
+	} else {
+		new_tail_cell(list);
+	}
+	llast(list) = datum;
+	return list;
+}
+
+DLLEXPORT List* lappend_int(List* list, int datum) {
+	check_list_type(list, T_IntList);
+	if (list == NIL) {
+		list = new_list(T_IntList, 1);
+	} else {
+		new_tail_cell(list);
+	}
+	llast_int(list) = datum;
+	return list;
+}
+
+DLLEXPORT List* lappend_oid(List* list, Oid datum) {
+	check_list_type(list, T_OidList);
+	if (list == NIL) {
+		list = new_list(T_OidList, 1);
+	} else {
+		new_tail_cell(list);
+	}
+	llast_oid(list) = datum;
+	return list;
+}
+
+DLLEXPORT List* lappend_xid(List* list, TransactionId datum) {
+	check_list_type(list, T_XidList);
+	if (list == NIL) {
+		list = new_list(T_XidList, 1);
+	} else {
+		new_tail_cell(list);
+	}
+	lfirst_xid(list_last_cell(list)) = datum;
+	return list;
+}
+
+DLLEXPORT List* lcons(void* datum, List* list) {
+	check_list_type(list, T_List);
+	if (list == NIL) {
+		list = new_list(T_List, 1);
+	} else {
+		insert_new_cell(list, 0);
+	}
+	linitial(list) = datum;
+	return list;
+}
+
+DLLEXPORT List* lcons_int(int datum, List* list) {
+	check_list_type(list, T_IntList);
+	if (list == NIL) {
+		list = new_list(T_IntList, 1);
+	} else {
+		insert_new_cell(list, 0);
+	}
+	linitial_int(list) = datum;
+	return list;
+}
+
+DLLEXPORT List* lcons_oid(Oid datum, List* list) {
+	check_list_type(list, T_OidList);
+	if (list == NIL) {
+		list = new_list(T_OidList, 1);
+	} else {
+		insert_new_cell(list, 0);
+	}
+	linitial_oid(list) = datum;
+	return list;
+}
+
+// insert_nth returns the List after inserting a cell at the given position, which may be the length of the List.
+static List* insert_nth(List* list, int pos, NodeTag type, ListCell datum) {
+	check_list_type(list, type);
+	if (pos < 0 || pos > list_length(list)) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "list position %d is out of range", pos);
+	}
+	if (list == NIL) {
+		list = new_list(type, 1);
+		list->elements[0] = datum;
+		return list;
+	}
+	*insert_new_cell(list, pos) = datum;
+	return list;
+}
+
+DLLEXPORT List* list_insert_nth(List* list, int pos, void* datum) {
+	return insert_nth(list, pos, T_List, list_make_ptr_cell(datum));
+}
+
+DLLEXPORT List* list_insert_nth_int(List* list, int pos, int datum) {
+	return insert_nth(list, pos, T_IntList, list_make_int_cell(datum));
+}
+
+DLLEXPORT List* list_insert_nth_oid(List* list, int pos, Oid datum) {
+	return insert_nth(list, pos, T_OidList, list_make_oid_cell(datum));
+}
+
+DLLEXPORT List* list_concat(List* list1, const List* list2) {
+	if (list1 == NIL) {
+		return list_copy(list2);
+	}
+	if (list2 == NIL) {
+		return list1;
+	}
+	check_list_type(list2, list1->type);
+	// list2 may be the same List as list1, so its length is read before list1 grows
+	int list2_length = list2->length;
+	int new_len = list1->length + list2_length;
+	if (new_len > list1->max_length) {
+		enlarge_list(list1, new_len);
+	}
+	memcpy(&list1->elements[list1->length], &list2->elements[0], list2_length * sizeof(ListCell));
+	list1->length = new_len;
+	return list1;
+}
+
+DLLEXPORT List* list_concat_copy(const List* list1, const List* list2) {
+	if (list1 == NIL) {
+		return list_copy(list2);
+	}
+	if (list2 == NIL) {
+		return list_copy(list1);
+	}
+	check_list_type(list2, list1->type);
+	List* result = new_list(list1->type, list1->length + list2->length);
+	memcpy(result->elements, list1->elements, list1->length * sizeof(ListCell));
+	memcpy(result->elements + list1->length, list2->elements, list2->length * sizeof(ListCell));
+	return result;
+}
+
+DLLEXPORT List* list_truncate(List* list, int new_size) {
+	if (new_size <= 0) {
+		return NIL;
+	}
+	if (new_size < list_length(list)) {
+		list->length = new_size;
+	}
+	return list;
+}
+
+DLLEXPORT bool list_member_ptr(const List* list, const void* datum) {
+	check_list_type(list, T_List);
+	for (int i = 0; i < list_length(list); i++) {
+		if (list->elements[i].ptr_value == datum) {
+			return true;
+		}
+	}
+	return false;
+}
+
+DLLEXPORT bool list_member_int(const List* list, int datum) {
+	check_list_type(list, T_IntList);
+	for (int i = 0; i < list_length(list); i++) {
+		if (list->elements[i].int_value == datum) {
+			return true;
+		}
+	}
+	return false;
+}
+
+DLLEXPORT bool list_member_oid(const List* list, Oid datum) {
+	check_list_type(list, T_OidList);
+	for (int i = 0; i < list_length(list); i++) {
+		if (list->elements[i].oid_value == datum) {
+			return true;
+		}
+	}
+	return false;
+}
+
+DLLEXPORT bool list_member_xid(const List* list, TransactionId datum) {
+	check_list_type(list, T_XidList);
+	for (int i = 0; i < list_length(list); i++) {
+		if (list->elements[i].xid_value == datum) {
+			return true;
+		}
+	}
+	return false;
+}
+
+DLLEXPORT List* list_delete_nth_cell(List* list, int n) {
+	if (n < 0 || n >= list_length(list)) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "list position %d is out of range", n);
+	}
+	// Deleting the last cell frees the List, as an empty List is always NIL
+	if (list->length == 1) {
+		list_free(list);
+		return NIL;
+	}
+	memmove(&list->elements[n], &list->elements[n + 1], (list->length - 1 - n) * sizeof(ListCell));
+	list->length--;
+	return list;
+}
+
+DLLEXPORT List* list_delete_cell(List* list, ListCell* cell) {
+	return list_delete_nth_cell(list, list_cell_number(list, cell));
+}
+
+DLLEXPORT List* list_delete_ptr(List* list, void* datum) {
+	check_list_type(list, T_List);
+	for (int i = 0; i < list_length(list); i++) {
+		if (list->elements[i].ptr_value == datum) {
+			return list_delete_nth_cell(list, i);
+		}
+	}
+	return list;
+}
+
+DLLEXPORT List* list_delete_int(List* list, int datum) {
+	check_list_type(list, T_IntList);
+	for (int i = 0; i < list_length(list); i++) {
+		if (list->elements[i].int_value == datum) {
+			return list_delete_nth_cell(list, i);
+		}
+	}
+	return list;
+}
+
+DLLEXPORT List* list_delete_oid(List* list, Oid datum) {
+	check_list_type(list, T_OidList);
+	for (int i = 0; i < list_length(list); i++) {
+		if (list->elements[i].oid_value == datum) {
+			return list_delete_nth_cell(list, i);
+		}
+	}
+	return list;
+}
+
+DLLEXPORT List* list_delete_first(List* list) {
+	if (list == NIL) {
+		return NIL;
+	}
+	return list_delete_nth_cell(list, 0);
+}
+
+DLLEXPORT List* list_delete_last(List* list) {
+	if (list == NIL) {
+		return NIL;
+	}
+	if (list->length <= 1) {
+		list_free(list);
+		return NIL;
+	}
+	return list_truncate(list, list->length - 1);
+}
+
+DLLEXPORT List* list_delete_first_n(List* list, int n) {
+	if (n <= 0) {
+		return list;
+	}
+	if (n >= list_length(list)) {
+		list_free(list);
+		return NIL;
+	}
+	memmove(&list->elements[0], &list->elements[n], (list->length - n) * sizeof(ListCell));
+	list->length -= n;
+	return list;
+}
+
+DLLEXPORT List* list_append_unique_ptr(List* list, void* datum) {
+	if (list_member_ptr(list, datum)) {
+		return list;
+	}
+	return lappend(list, datum);
+}
+
+DLLEXPORT List* list_append_unique_int(List* list, int datum) {
+	if (list_member_int(list, datum)) {
+		return list;
+	}
+	return lappend_int(list, datum);
+}
+
+DLLEXPORT List* list_append_unique_oid(List* list, Oid datum) {
+	if (list_member_oid(list, datum)) {
+		return list;
+	}
+	return lappend_oid(list, datum);
+}
+
+DLLEXPORT List* list_copy(const List* oldlist) {
+	if (oldlist == NIL) {
+		return NIL;
+	}
+	List* newlist = new_list(oldlist->type, oldlist->length);
+	memcpy(newlist->elements, oldlist->elements, newlist->length * sizeof(ListCell));
+	return newlist;
+}
+
+DLLEXPORT List* list_copy_head(const List* oldlist, int len) {
+	if (oldlist == NIL || len <= 0) {
+		return NIL;
+	}
+	if (len > oldlist->length) {
+		len = oldlist->length;
+	}
+	List* newlist = new_list(oldlist->type, len);
+	memcpy(newlist->elements, oldlist->elements, len * sizeof(ListCell));
+	return newlist;
+}
+
+DLLEXPORT List* list_copy_tail(const List* oldlist, int nskip) {
+	if (nskip < 0) {
+		nskip = 0;
+	}
+	if (oldlist == NIL || nskip >= oldlist->length) {
+		return NIL;
+	}
+	List* newlist = new_list(oldlist->type, oldlist->length - nskip);
+	memcpy(newlist->elements, &oldlist->elements[nskip], newlist->length * sizeof(ListCell));
+	return newlist;
+}
+
+DLLEXPORT void list_free(List* list) {
+	if (list == NIL) {
+		return;
+	}
+	if (list->elements != list->initial_elements) {
+		pfree(list->elements);
+	}
+	pfree(list);
+}
+
+DLLEXPORT void list_free_deep(List* list) {
+	check_list_type(list, T_List);
+	for (int i = 0; i < list_length(list); i++) {
+		if (list->elements[i].ptr_value != NULL) {
+			pfree(list->elements[i].ptr_value);
+		}
+	}
+	list_free(list);
+}
+
+DLLEXPORT void list_sort(List* list, list_sort_comparator cmp) {
+	if (list_length(list) > 1) {
+		qsort(list->elements, list->length, sizeof(ListCell), (int (*)(const void*, const void*))cmp);
+	}
+}
+
+DLLEXPORT int list_int_cmp(const ListCell* p1, const ListCell* p2) {
+	int v1 = lfirst_int(p1);
+	int v2 = lfirst_int(p2);
+	return (v1 > v2) - (v1 < v2);
+}
+
+DLLEXPORT int list_oid_cmp(const ListCell* p1, const ListCell* p2) {
+	Oid v1 = lfirst_oid(p1);
+	Oid v2 = lfirst_oid(p2);
+	return (v1 > v2) - (v1 < v2);
+}
@@ -0,0 +1,394 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+#include <errno.h>
+
+// These are assigned directly by extensions, as MemoryContextSwitchTo is an inline function within the Postgres headers.
+// They're swapped with the values of each session's state whenever a state is bound to a thread.
+DLLEXPORT MemoryContext TopMemoryContext = NULL;
+DLLEXPORT MemoryContext CurrentMemoryContext = NULL;
+
+// chunk_pointer returns the memory that is given to the caller for the chunk.
+static inline void* chunk_pointer(PgExtChunk* chunk) {
+	return (void*)(chunk + 1);
+}
+
+// pointer_chunk returns the chunk for memory that was returned from chunk_pointer.
+static inline PgExtChunk* pointer_chunk(void* pointer) {
+	return ((PgExtChunk*)pointer) - 1;
+}
+
+// report_oom raises an out of memory error, which only returns when there is no call to unwind.
+static void report_oom(MemoryContext context, size_t size) {
+	if (errstart(ERROR, NULL)) {
+		errcode(ERRCODE_OUT_OF_MEMORY);
+		errmsg_internal("out of memory");
+		errdetail_internal("Failed on request of size %zu in memory context \"%s\".", size, context->name);
+		errfinish(__FILE__, __LINE__, __func__);
+	}
+}
+
+// pgext_current_context returns the current memory context, creating the top context if it does not yet exist.
+MemoryContext pgext_current_context(void) {
+	if (CurrentMemoryContext == NULL) {
+		if (TopMemoryContext == NULL) {
+			TopMemoryContext = AllocSetContextCreateInternal(NULL, "TopMemoryContext", 0, 0, 0);
+		}
+		CurrentMemoryContext = TopMemoryContext;
+	}
+	return CurrentMemoryContext;
+}
+
+// link_chunk adds the chunk to the given context.
+static void link_chunk(MemoryContext context, PgExtChunk* chunk) {
+	chunk->prev = NULL;
+	chunk->next = context->chunks;
+	chunk->context = context;
+	if (context->chunks != NULL) {
+		context->chunks->prev = chunk;
+	}
+	context->chunks = chunk;
+	context->mem_allocated += chunk->size;
+}
+
+// context_alloc allocates memory that is owned by the given context.
+static void* context_alloc(MemoryContext context, size_t size, int flags) {
+	if (context == NULL) {
+		return NULL;
+	}
+	if ((flags & MCXT_ALLOC_HUGE) == 0 && size > MaxAllocSize) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "invalid memory alloc request size %zu", size);
+		return NULL;
+	}
+	PgExtChunk* chunk;
+	if ((flags & MCXT_ALLOC_ZERO) != 0) {
+		chunk = (PgExtChunk*)calloc(1, sizeof(PgExtChunk) + size);
+	} else {
+		chunk = (PgExtChunk*)malloc(sizeof(PgExtChunk) + size);
+	}
+	if (chunk == NULL) {
+		if ((flags & MCXT_ALLOC_NO_OOM) == 0) {
+			report_oom(context, size);
+		}
+		return NULL;
+	}
+	chunk->size = size;
+	link_chunk(context, chunk);
+	return chunk_pointer(chunk);
+}
+
+// unlink_chunk removes the chunk from its context.
+static void unlink_chunk(PgExtChunk* chunk) {
+	MemoryContext context = chunk->context;
+	if (chunk->prev != NULL) {
+		chunk->prev->next = chunk->next;
+	} else {
+		context->chunks = chunk->next;
+	}
+	if (chunk->next != NULL) {
+		chunk->next->prev = chunk->prev;
+	}
+	context->mem_allocated -= chunk->size;
+}
+
+// unlink_context removes the context from its parent's children.
+static void unlink_context(MemoryContext context) {
+	if (context->prevchild != NULL) {
+		context->prevchild->nextchild = context->nextchild;
+	} else if (context->parent != NULL) {
+		context->parent->firstchild = context->nextchild;
+	}
+	if (context->nextchild != NULL) {
+		context->nextchild->prevchild = context->prevchild;
+	}
+	context->parent = NULL;
+	context->prevchild = NULL;
+	context->nextchild = NULL;
+}
+
+DLLEXPORT MemoryContext AllocSetContextCreateInternal(MemoryContext parent, const char* name, size_t minContextSize,
+	size_t initBlockSize, size_t maxBlockSize) {
+	MemoryContext context = (MemoryContext)calloc(1, sizeof(MemoryContextData));
+	if (context == NULL) {
+		return NULL;
+	}
+	context->name = name;
+	MemoryContextSetParent(context, parent);
+	return context;
+}
+
+DLLEXPORT void MemoryContextSetParent(MemoryContext context, MemoryContext new_parent) {
+	if (context == NULL || context->parent == new_parent) {
+		return;
+	}
+	unlink_context(context);
+	if (new_parent != NULL) {
+		context->parent = new_parent;
+		context->nextchild = new_parent->firstchild;
+		if (new_parent->firstchild != NULL) {
+			new_parent->firstchild->prevchild = context;
+		}
+		new_parent->firstchild = context;
+	}
+}
+
+DLLEXPORT void MemoryContextSetIdentifier(MemoryContext context, const char* id) {
+	context->ident = id;
+}
+
+DLLEXPORT MemoryContext MemoryContextSwitchTo(MemoryContext context) {
+	MemoryContext old = CurrentMemoryContext;
+	CurrentMemoryContext = context;
+	return old;
+}
+
+DLLEXPORT void MemoryContextRegisterResetCallback(MemoryContext context, MemoryContextCallback* cb) {
+	cb->next = context->reset_cbs;
+	context->reset_cbs = cb;
+}
+
+DLLEXPORT void MemoryContextResetOnly(MemoryContext context) {
+	// Callbacks may register new callbacks, so we detach each one before calling it
+	while (context->reset_cbs != NULL) {
+		MemoryContextCallback* cb = context->reset_cbs;
+		context->reset_cbs = cb->next;
+		cb->func(cb->arg);
+	}
+	PgExtChunk* chunk = context->chunks;
+	while (chunk != NULL) {
+		PgExtChunk* next = chunk->next;
+		free(chunk);
+		chunk = next;
+	}
+	context->chunks = NULL;
+	context->mem_allocated = 0;
+}
+
+DLLEXPORT void MemoryContextDeleteChildren(MemoryContext context) {
+	while (context->firstchild != NULL) {
+		MemoryContextDelete(context->firstchild);
+	}
+}
+
+DLLEXPORT void MemoryContextReset(MemoryContext context) {
+	MemoryContextDeleteChildren(context);
+	MemoryContextResetOnly(context);
+}
+
+DLLEXPORT void MemoryContextDelete(MemoryContext context) {
+	if (context == NULL) {
+		return;
+	}
+	MemoryContextDeleteChildren(context);
+	MemoryContextResetOnly(context);
+	// Deleting the current context is an error in Postgres, but we'd rather not leave a dangling pointer behind
+	if (CurrentMemoryContext == context) {
+		CurrentMemoryContext = context->parent;
+	}
+	if (TopMemoryContext == context) {
+		TopMemoryContext = NULL;
+	}
+	unlink_context(context);
+	free(context);
+}
+
+DLLEXPORT size_t MemoryContextMemAllocated(MemoryContext context, bool recurse) {
+	size_t total = context->mem_allocated;
+	if (recurse) {
+		for (MemoryContext child = context->firstchild; child != NULL; child = child->nextchild) {
+			total += MemoryContextMemAllocated(child, true);
+		}
+	}
+	return total;
+}
+
+DLLEXPORT void* MemoryContextAlloc(MemoryContext context, size_t size) {
+	return context_alloc(context, size, 0);
+}
+
+DLLEXPORT void* MemoryContextAllocZero(MemoryContext context, size_t size) {
+	return context_alloc(context, size, MCXT_ALLOC_ZERO);
+}
+
+DLLEXPORT void* MemoryContextAllocZeroAligned(MemoryContext context, size_t size) {
+	return context_alloc(context, size, MCXT_ALLOC_ZERO);
+}
+
+DLLEXPORT void* MemoryContextAllocExtended(MemoryContext context, size_t size, int flags) {
+	return context_alloc(context, size, flags);
+}
+
+DLLEXPORT void* MemoryContextAllocHuge(MemoryContext context, size_t size) {
+	return context_alloc(context, size, MCXT_ALLOC_HUGE);
+}
+
+DLLEXPORT void* palloc(size_t size) {
+	return context_alloc(pgext_current_context(), size, 0);
+}
+
+DLLEXPORT void* palloc0(size_t size) {
+	return context_alloc(pgext_current_context(), size, MCXT_ALLOC_ZERO);
+}
+
+DLLEXPORT void* palloc_extended(size_t size, int flags) {
+	return context_alloc(pgext_current_context(), size, flags);
+}
+
+DLLEXPORT void pfree(void* pointer) {
+	if (pointer == NULL) {
+		return;
+	}
+	PgExtChunk* chunk = pointer_chunk(pointer);
+	unlink_chunk(chunk);
+	free(chunk);
+}
+
+DLLEXPORT void* repalloc(void* pointer, size_t size) {
+	PgExtChunk* chunk = pointer_chunk(pointer);
+	MemoryContext context = chunk->context;
+	if (size > MaxAllocSize) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "invalid memory alloc request size %zu", size);
+		return NULL;
+	}
+	unlink_chunk(chunk);
+	PgExtChunk* moved = (PgExtChunk*)realloc(chunk, sizeof(PgExtChunk) + size);
+	if (moved == NULL) {
+		// The original chunk is untouched when it cannot be moved, so it still belongs to the context
+		link_chunk(context, chunk);
+		report_oom(context, size);
+		return NULL;
+	}
+	moved->size = size;
+	link_chunk(context, moved);
+	return chunk_pointer(moved);
+}
+
+DLLEXPORT char* MemoryContextStrdup(MemoryContext context, const char* string) {
+	size_t len = strlen(string) + 1;
+	char* copy = (char*)context_alloc(context, len, 0);
+	if (copy != NULL) {
+		memcpy(copy, string, len);
+	}
+	return copy;
+}
+
+DLLEXPORT char* pstrdup(const char* in) {
+	return MemoryContextStrdup(pgext_current_context(), in);
+}
+
+// pnstrdup copies at most len bytes of the string, stopping early at a terminator, and always terminates the copy.
+DLLEXPORT char* pnstrdup(const char* in, size_t len) {
+	len = strnlen(in, len);
+	char* out = (char*)palloc(len + 1);
+	if (out != NULL) {
+		memcpy(out, in, len);
+		out[len] = '\0';
+	}
+	return out;
+}
+
+// pchomp copies the string without its trailing newlines.
+DLLEXPORT char* pchomp(const char* in) {
+	size_t n = strlen(in);
+	while (n > 0 && in[n - 1] == '\n') {
+		n--;
+	}
+	return pnstrdup(in, n);
+}
+
+// pvsnprintf formats into the buffer, returning the number of bytes that were written when they fit. Otherwise, this
+// returns the size of the buffer that is needed, including the terminator, which is always larger than len.
+DLLEXPORT size_t pvsnprintf(char* buf, size_t len, const char* fmt, va_list args) {
+	int nprinted = vsnprintf(buf, len, fmt, args);
+	if (nprinted < 0) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "vsnprintf failed with format string \"%s\"", fmt);
+		return 0;
+	}
+	if ((size_t)nprinted < len) {
+		return (size_t)nprinted;
+	}
+	if ((size_t)nprinted >= MaxAllocSize - 1) {
+		pgext_raise_error(ERROR, ERRCODE_OUT_OF_MEMORY, "out of memory");
+		return 0;
+	}
+	return (size_t)nprinted + 1;
+}
+
+DLLEXPORT char* psprintf(const char* fmt, ...) {
+	// The format may use %m, so errno is restored before each attempt
+	int save_errno = errno;
+	size_t len = 128;
+	for (;;) {
+		char* result = (char*)palloc(len);
+		if (result == NULL) {
+			return NULL;
+		}
+		va_list args;
+		errno = save_errno;
+		va_start(args, fmt);
+		size_t needed = pvsnprintf(result, len, fmt, args);
+		va_end(args);
+		if (needed < len) {
+			return result;
+		}
+		pfree(result);
+		if (needed == 0) {
+			return NULL;
+		}
+		len = needed;
+	}
+}
+
+DLLEXPORT MemoryContext GetMemoryChunkContext(void* pointer) {
+	return pointer_chunk(pointer)->context;
+}
+
+DLLEXPORT size_t GetMemoryChunkSpace(void* pointer) {
+	return sizeof(PgExtChunk) + pointer_chunk(pointer)->size;
+}
+
+// pgext_pfree frees memory that was allocated by palloc, for hosts that must free the results of calls.
+DLLEXPORT uintptr_t pgext_pfree(void* pointer) {
+	pfree(pointer);
+	return 0;
+}
+
+// pgext_memory_allocated returns the number of bytes that are allocated within the current session's memory contexts.
+DLLEXPORT uintptr_t pgext_memory_allocated(void) {
+	if (TopMemoryContext == NULL) {
+		return 0;
+	}
+	return (uintptr_t)MemoryContextMemAllocated(TopMemoryContext, true);
+}
+
+// pgext_call_context_begin creates a memory context for a single call, and makes it the current context. It is a child
+// of the current context, so that memory contexts that are created during the call are also freed when it ends.
+DLLEXPORT MemoryContext pgext_call_context_begin(void) {
+	MemoryContext context = AllocSetContextCreateInternal(pgext_current_context(), "CallContext", 0, 0, 0);
+	if (context != NULL) {
+		CurrentMemoryContext = context;
+	}
+	return context;
+}
+
+// pgext_call_context_end deletes a context that was returned from pgext_call_context_begin, which frees everything that
+// was allocated during the call. The context that was current before the call is restored, even if the call switched
+// to another context without switching back.
+DLLEXPORT uintptr_t pgext_call_context_end(MemoryContext context) {
+	CurrentMemoryContext = context->parent;
+	MemoryContextDelete(context);
+	return 0;
+}
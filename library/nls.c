@@ -0,0 +1,52 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// translator is the host's translator, or NULL if messages should be passed through untranslated.
+static PgExtTranslator* translator;
+
+// pgext_set_translator sets the host's translator. Setting NULL passes messages through untranslated.
+DLLEXPORT uintptr_t pgext_set_translator(PgExtTranslator* new_translator) {
+	translator = new_translator;
+	return 0;
+}
+
+// pgext_translate returns the translation of the given message within the given domain.
+const char* pgext_translate(const char* domain, const char* msgid) {
+	if (translator == NULL || msgid == NULL) {
+		return msgid;
+	}
+	const char* translated = translator->translate(domain, msgid, NULL, 1, false);
+	return translated != NULL ? translated : msgid;
+}
+
+// pgext_translate_plural returns the translation of the message within the given domain, choosing the plural form that
+// matches n.
+const char* pgext_translate_plural(const char* domain, const char* singular, const char* plural, unsigned long n) {
+	if (translator == NULL) {
+		return n == 1 ? singular : plural;
+	}
+	const char* translated = translator->translate(domain, singular, plural, n, true);
+	if (translated == NULL) {
+		return n == 1 ? singular : plural;
+	}
+	return translated;
+}
+
+DLLEXPORT void pg_bindtextdomain(const char* domain) {
+	if (translator != NULL && domain != NULL) {
+		translator->bind_domain(domain);
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// These are written in Go, which returns errors rather than raising them, as raising an error would unwind through the
+// Go stack. Errors are returned as their SQLSTATE along with a message, which the caller frees.
+extern int pgext_numeric_parse(const char* str, int32_t typmod, Numeric* result, char** message);
+extern char* pgext_numeric_format(Numeric num, char** message);
+extern Numeric pgext_numeric_from_int64(int64_t v);
+extern Numeric pgext_numeric_from_float8(double v);
+extern int pgext_numeric_to_int64(Numeric num, int bits, int64_t* result, char** message);
+extern int pgext_numeric_to_float8(Numeric num, double* result, char** message);
+extern int pgext_numeric_special(Numeric num);
+
+#define NUMERIC_NAN  0xC000
+#define NUMERIC_PINF 0xD000
+#define NUMERIC_NINF 0xF000
+
+// numeric_raise_error raises the error that was returned from Go, freeing its message beforehand.
+static void numeric_raise_error(int sqlerrcode, char* message) {
+	char copy[1024];
+	snprintf(copy, sizeof(copy), "%s", message != NULL ? message : "invalid numeric value");
+	free(message);
+	pgext_raise_error(ERROR, sqlerrcode, "%s", copy);
+}
+
+// numeric_arg returns the argument as a numeric with a 4-byte header, as the Go code only reads that layout.
+static Numeric numeric_arg(FunctionCallInfo fcinfo, int n) {
+	return (Numeric)pg_detoast_datum((varlena*)fcinfo->args[n].value);
+}
+
+// numeric_to_integer converts the numeric to an integer of the given number of bits, raising an error if it does not
+// fit.
+static int64_t numeric_to_integer(Numeric num, int bits) {
+	int64_t result = 0;
+	char* message = NULL;
+	int sqlerrcode = pgext_numeric_to_int64(num, bits, &result, &message);
+	if (sqlerrcode != 0) {
+		numeric_raise_error(sqlerrcode, message);
+	}
+	return result;
+}
+
+DLLEXPORT Datum numeric_in(FunctionCallInfo fcinfo) {
+	const char* str = (const char*)fcinfo->args[0].value;
+	int32_t typmod = fcinfo->nargs >= 3 ? (int32_t)fcinfo->args[2].value : -1;
+	Numeric result = NULL;
+	char* message = NULL;
+	int sqlerrcode = pgext_numeric_parse(str, typmod, &result, &message);
+	if (sqlerrcode != 0) {
+		numeric_raise_error(sqlerrcode, message);
+		return (Datum)0;
+	}
+	return (Datum)result;
+}
+
+DLLEXPORT Datum numeric_out(FunctionCallInfo fcinfo) {
+	char* message = NULL;
+	char* result = pgext_numeric_format(numeric_arg(fcinfo, 0), &message);
+	if (result == NULL) {
+		numeric_raise_error(ERRCODE_INTERNAL_ERROR, message);
+		return (Datum)0;
+	}
+	return (Datum)result;
+}
+
+DLLEXPORT Datum int4_numeric(FunctionCallInfo fcinfo) {
+	return (Datum)pgext_numeric_from_int64((int32_t)fcinfo->args[0].value);
+}
+
+DLLEXPORT Datum int8_numeric(FunctionCallInfo fcinfo) {
+	return (Datum)pgext_numeric_from_int64((int64_t)fcinfo->args[0].value);
+}
+
+DLLEXPORT Datum float8_numeric(FunctionCallInfo fcinfo) {
+	double v;
+	memcpy(&v, &fcinfo->args[0].value, sizeof(double));
+	return (Datum)pgext_numeric_from_float8(v);
+}
+
+DLLEXPORT Datum numeric_int4(FunctionCallInfo fcinfo) {
+	return (Datum)(int64_t)(int32_t)numeric_to_integer(numeric_arg(fcinfo, 0), 32);
+}
+
+DLLEXPORT Datum numeric_int8(FunctionCallInfo fcinfo) {
+	return (Datum)numeric_to_integer(numeric_arg(fcinfo, 0), 64);
+}
+
+DLLEXPORT Datum numeric_float8(FunctionCallInfo fcinfo) {
+	double result = 0;
+	char* message = NULL;
+	int sqlerrcode = pgext_numeric_to_float8(numeric_arg(fcinfo, 0), &result, &message);
+	if (sqlerrcode != 0) {
+		numeric_raise_error(sqlerrcode, message);
+		return (Datum)0;
+	}
+	Datum datum;
+	memcpy(&datum, &result, sizeof(double));
+	return datum;
+}
+
+DLLEXPORT Numeric int64_to_numeric(int64_t v) {
+	return pgext_numeric_from_int64(v);
+}
+
+DLLEXPORT bool numeric_is_nan(Numeric num) {
+	return pgext_numeric_special((Numeric)pg_detoast_datum((varlena*)num)) == NUMERIC_NAN;
+}
+
+DLLEXPORT bool numeric_is_inf(Numeric num) {
+	int special = pgext_numeric_special((Numeric)pg_detoast_datum((varlena*)num));
+	return special == NUMERIC_PINF || special == NUMERIC_NINF;
+}
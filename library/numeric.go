@@ -0,0 +1,522 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extension_cgo
+
+/*
+#include "exports.h"
+*/
+import "C"
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// These describe the layout of a numeric, which matches numeric.c of Postgres. A numeric is a varlena whose digits are
+// stored in base 10000, and whose header is either short or long depending on the weight and display scale.
+const (
+	numericNBase            = 10000
+	numericDecDigits        = 4
+	numericSignMask         = 0xC000
+	numericPos              = 0x0000
+	numericNeg              = 0x4000
+	numericShort            = 0x8000
+	numericSpecial          = 0xC000
+	numericExtSignMask      = 0xF000
+	numericNaN              = 0xC000
+	numericPInf             = 0xD000
+	numericNInf             = 0xF000
+	numericDScaleMask       = 0x3FFF
+	numericShortSignMask    = 0x2000
+	numericShortDScaleMask  = 0x1F80
+	numericShortDScaleShift = 7
+	numericShortDScaleMax   = 0x3F
+	numericShortWeightSign  = 0x0040
+	numericShortWeightMask  = 0x003F
+	numericShortWeightMax   = 63
+	numericShortWeightMin   = -64
+	numericWeightMax        = 0x7FFF
+	numericMaxResultScale   = 2000
+	numericVarHdrSz         = 4
+)
+
+// numericValue is a numeric as a signed coefficient and a display scale, such that its value is the coefficient divided
+// by 10 to the power of the scale. Special values have no coefficient.
+type numericValue struct {
+	coefficient *big.Int
+	scale       int
+	special     uint16
+}
+
+// numericError is an error that is raised as the given SQLSTATE.
+type numericError struct {
+	sqlerrcode C.int
+	message    string
+}
+
+var _ error = numericError{}
+
+// Error implements the error interface.
+func (ne numericError) Error() string {
+	return ne.message
+}
+
+//export pgext_numeric_parse
+func pgext_numeric_parse(str *C.pgext_const_char, typmod C.int32_t, result *C.Numeric, message **C.char) C.int {
+	value, err := parseNumeric(C.GoString(str))
+	if err == nil {
+		value, err = value.applyTypmod(int32(typmod))
+	}
+	if err != nil {
+		return numericErrorCode(err, message)
+	}
+	*result = value.palloc()
+	return 0
+}
+
+//export pgext_numeric_format
+func pgext_numeric_format(num C.Numeric, message **C.char) *C.char {
+	value, err := decodeNumeric(numericBytes(num))
+	if err != nil {
+		numericErrorCode(err, message)
+		return nil
+	}
+	str := value.String()
+	cStr := (*C.char)(C.palloc(C.size_t(len(str) + 1)))
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(cStr)), len(str)+1), append([]byte(str), 0))
+	return cStr
+}
+
+//export pgext_numeric_from_int64
+func pgext_numeric_from_int64(v C.int64_t) C.Numeric {
+	return numericValue{coefficient: big.NewInt(int64(v))}.palloc()
+}
+
+//export pgext_numeric_from_float8
+func pgext_numeric_from_float8(v C.double) C.Numeric {
+	f := float64(v)
+	switch {
+	case math.IsNaN(f):
+		return numericValue{special: numericNaN}.palloc()
+	case math.IsInf(f, 1):
+		return numericValue{special: numericPInf}.palloc()
+	case math.IsInf(f, -1):
+		return numericValue{special: numericNInf}.palloc()
+	}
+	// Postgres converts through the shortest text that keeps DBL_DIG digits, which the parser always accepts
+	value, _ := parseNumeric(strconv.FormatFloat(f, 'g', 15, 64))
+	return value.palloc()
+}
+
+//export pgext_numeric_to_int64
+func pgext_numeric_to_int64(num C.Numeric, bits C.int, result *C.int64_t, message **C.char) C.int {
+	value, err := decodeNumeric(numericBytes(num))
+	if err == nil {
+		var v int64
+		if v, err = value.int64(int(bits)); err == nil {
+			*result = C.int64_t(v)
+			return 0
+		}
+	}
+	return numericErrorCode(err, message)
+}
+
+//export pgext_numeric_to_float8
+func pgext_numeric_to_float8(num C.Numeric, result *C.double, message **C.char) C.int {
+	value, err := decodeNumeric(numericBytes(num))
+	if err == nil {
+		var f float64
+		if f, err = value.float64(); err == nil {
+			*result = C.double(f)
+			return 0
+		}
+	}
+	return numericErrorCode(err, message)
+}
+
+//export pgext_numeric_special
+func pgext_numeric_special(num C.Numeric) C.int {
+	header := numericBytes(num)
+	if len(header) < 2 {
+		return 0
+	}
+	if flags := binary.LittleEndian.Uint16(header); flags&numericSignMask == numericSpecial {
+		return C.int(flags & numericExtSignMask)
+	}
+	return 0
+}
+
+//export pgext_numeric_cmp
+func pgext_numeric_cmp(a C.Numeric, b C.Numeric) C.int {
+	left, errLeft := decodeNumeric(numericBytes(a))
+	right, errRight := decodeNumeric(numericBytes(b))
+	if errLeft != nil || errRight != nil {
+		return -2
+	}
+	return C.int(left.compare(right))
+}
+
+// numericBytes returns the bytes that follow the 4-byte header of the numeric, which has already been detoasted.
+func numericBytes(num C.Numeric) []byte {
+	size := *(*uint32)(unsafe.Pointer(num)) >> 2
+	if size < numericVarHdrSz {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Add(unsafe.Pointer(num), numericVarHdrSz)), int(size)-numericVarHdrSz)
+}
+
+// numericErrorCode sets the message to a copy of the error's message, which the caller frees, and returns its SQLSTATE.
+func numericErrorCode(err error, message **C.char) C.int {
+	*message = C.CString(err.Error())
+	var ne numericError
+	if errors.As(err, &ne) {
+		return ne.sqlerrcode
+	}
+	return C.ERRCODE_INTERNAL_ERROR
+}
+
+// parseNumeric parses the text form of a numeric, which is the same as numeric_in accepts.
+func parseNumeric(str string) (numericValue, error) {
+	s := strings.TrimSpace(str)
+	switch strings.ToLower(s) {
+	case "nan":
+		return numericValue{special: numericNaN}, nil
+	case "infinity", "+infinity", "inf", "+inf":
+		return numericValue{special: numericPInf}, nil
+	case "-infinity", "-inf":
+		return numericValue{special: numericNInf}, nil
+	}
+	invalid := numericError{sqlerrcode: C.ERRCODE_INVALID_TEXT_REPRESENTATION,
+		message: `invalid input syntax for type numeric: "` + str + `"`}
+	negative := false
+	if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+	mantissa, exponent, hasExponent := s, "", false
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mantissa, exponent, hasExponent = s[:i], s[i+1:], true
+	}
+	var digits strings.Builder
+	scale := 0
+	seenDigit, seenPoint := false, false
+	for i := 0; i < len(mantissa); i++ {
+		switch c := mantissa[i]; {
+		case c >= '0' && c <= '9':
+			digits.WriteByte(c)
+			seenDigit = true
+			if seenPoint {
+				scale++
+			}
+		case c == '.' && !seenPoint:
+			seenPoint = true
+		default:
+			return numericValue{}, invalid
+		}
+	}
+	if !seenDigit {
+		return numericValue{}, invalid
+	}
+	if hasExponent {
+		exp, err := strconv.Atoi(exponent)
+		if err != nil || exp > 2*numericMaxResultScale || exp < -2*numericMaxResultScale {
+			return numericValue{}, invalid
+		}
+		scale -= exp
+	}
+	coefficient, _ := new(big.Int).SetString(digits.String(), 10)
+	if scale < 0 {
+		coefficient.Mul(coefficient, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-scale)), nil))
+		scale = 0
+	}
+	if negative {
+		coefficient.Neg(coefficient)
+	}
+	integerDigits := len(new(big.Int).Abs(coefficient).Text(10)) - scale
+	if scale > numericDScaleMask || integerDigits > (numericWeightMax+1)*numericDecDigits {
+		return numericValue{}, numericError{sqlerrcode: C.ERRCODE_NUMERIC_VALUE_OUT_OF_RANGE,
+			message: "value overflows numeric format"}
+	}
+	return numericValue{coefficient: coefficient, scale: scale}, nil
+}
+
+// applyTypmod rounds the value to the scale of the typmod, and returns an error if it does not fit within the
+// precision. A typmod of -1 leaves the value unchanged.
+func (value numericValue) applyTypmod(typmod int32) (numericValue, error) {
+	if typmod < numericVarHdrSz || value.special == numericNaN {
+		return value, nil
+	}
+	typmod -= numericVarHdrSz
+	precision := int(typmod>>16) & 0xFFFF
+	// The scale is an 11-bit signed integer, as Postgres 15 and later allow negative scales
+	scale := int((typmod&0x7FF)^0x400) - 0x400
+	if value.special != 0 {
+		return numericValue{}, numericError{sqlerrcode: C.ERRCODE_NUMERIC_VALUE_OUT_OF_RANGE,
+			message: "numeric field overflow"}
+	}
+	value = value.round(scale)
+	if value.coefficient.Sign() != 0 {
+		integerDigits := len(new(big.Int).Abs(value.coefficient).Text(10)) - value.scale
+		if integerDigits > precision-scale {
+			return numericValue{}, numericError{sqlerrcode: C.ERRCODE_NUMERIC_VALUE_OUT_OF_RANGE,
+				message: "numeric field overflow"}
+		}
+	}
+	return value, nil
+}
+
+// round rounds the value to the given number of digits after the decimal point, with ties rounding away from zero.
+// Negative scales round to the left of the decimal point, while the display scale never becomes negative.
+func (value numericValue) round(scale int) numericValue {
+	if value.special != 0 || scale >= value.scale {
+		if value.special == 0 && scale > value.scale {
+			shift := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale-value.scale)), nil)
+			return numericValue{coefficient: new(big.Int).Mul(value.coefficient, shift), scale: scale}
+		}
+		return value
+	}
+	divisor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(value.scale-scale)), nil)
+	quotient, remainder := new(big.Int).QuoRem(value.coefficient, divisor, new(big.Int))
+	if remainder.Abs(remainder).Lsh(remainder, 1).Cmp(divisor) >= 0 {
+		if value.coefficient.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	if scale < 0 {
+		quotient.Mul(quotient, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-scale)), nil))
+		scale = 0
+	}
+	return numericValue{coefficient: quotient, scale: scale}
+}
+
+// int64 returns the value rounded to an integer, which must fit within an integer of the given number of bits.
+func (value numericValue) int64(bits int) (int64, error) {
+	typeName := map[int]string{16: "smallint", 32: "integer", 64: "bigint"}[bits]
+	switch value.special {
+	case numericNaN:
+		return 0, numericError{sqlerrcode: C.ERRCODE_FEATURE_NOT_SUPPORTED, message: "cannot convert NaN to " + typeName}
+	case numericPInf, numericNInf:
+		return 0, numericError{sqlerrcode: C.ERRCODE_FEATURE_NOT_SUPPORTED,
+			message: "cannot convert infinity to " + typeName}
+	}
+	rounded := value.round(0).coefficient
+	if !rounded.IsInt64() || rounded.Int64() < -1<<(bits-1) || rounded.Int64() > 1<<(bits-1)-1 {
+		return 0, numericError{sqlerrcode: C.ERRCODE_NUMERIC_VALUE_OUT_OF_RANGE, message: typeName + " out of range"}
+	}
+	return rounded.Int64(), nil
+}
+
+// float64 returns the nearest float8 to the value.
+func (value numericValue) float64() (float64, error) {
+	switch value.special {
+	case numericNaN:
+		return math.NaN(), nil
+	case numericPInf:
+		return math.Inf(1), nil
+	case numericNInf:
+		return math.Inf(-1), nil
+	}
+	str := value.String()
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, numericError{sqlerrcode: C.ERRCODE_NUMERIC_VALUE_OUT_OF_RANGE,
+			message: `"` + str + `" is out of range for type double precision`}
+	}
+	return f, nil
+}
+
+// compare returns -1, 0, or 1 depending on whether the value is less than, equal to, or greater than the other. As in
+// Postgres, NaN is equal to itself and greater than all other values, including infinity.
+func (value numericValue) compare(other numericValue) int {
+	rank := func(v numericValue) int {
+		switch v.special {
+		case numericNInf:
+			return 0
+		case numericPInf:
+			return 2
+		case numericNaN:
+			return 3
+		default:
+			return 1
+		}
+	}
+	if leftRank, rightRank := rank(value), rank(other); leftRank != rightRank || leftRank != 1 {
+		return max(-1, min(1, leftRank-rightRank))
+	}
+	scale := max(value.scale, other.scale)
+	return value.round(scale).coefficient.Cmp(other.round(scale).coefficient)
+}
+
+// String returns the text form of the value, which is the same as numeric_out returns.
+func (value numericValue) String() string {
+	switch value.special {
+	case numericNaN:
+		return "NaN"
+	case numericPInf:
+		return "Infinity"
+	case numericNInf:
+		return "-Infinity"
+	}
+	digits := new(big.Int).Abs(value.coefficient).Text(10)
+	if len(digits) <= value.scale {
+		digits = strings.Repeat("0", value.scale-len(digits)+1) + digits
+	}
+	var sb strings.Builder
+	if value.coefficient.Sign() < 0 {
+		sb.WriteByte('-')
+	}
+	sb.WriteString(digits[:len(digits)-value.scale])
+	if value.scale > 0 {
+		sb.WriteByte('.')
+		sb.WriteString(digits[len(digits)-value.scale:])
+	}
+	return sb.String()
+}
+
+// encode returns the bytes of the value that follow its varlena header.
+func (value numericValue) encode() []byte {
+	if value.special != 0 {
+		return binary.LittleEndian.AppendUint16(nil, value.special)
+	}
+	// The digits before and after the decimal point are each padded out to whole base-10000 digits
+	decimal := new(big.Int).Abs(value.coefficient).Text(10)
+	if len(decimal) <= value.scale {
+		decimal = strings.Repeat("0", value.scale-len(decimal)+1) + decimal
+	}
+	integer, fraction := decimal[:len(decimal)-value.scale], decimal[len(decimal)-value.scale:]
+	if pad := len(integer) % numericDecDigits; pad != 0 {
+		integer = strings.Repeat("0", numericDecDigits-pad) + integer
+	}
+	if pad := len(fraction) % numericDecDigits; pad != 0 {
+		fraction += strings.Repeat("0", numericDecDigits-pad)
+	}
+	weight := len(integer)/numericDecDigits - 1
+	all := integer + fraction
+	digits := make([]uint16, 0, len(all)/numericDecDigits)
+	for i := 0; i < len(all); i += numericDecDigits {
+		digit, _ := strconv.Atoi(all[i : i+numericDecDigits])
+		digits = append(digits, uint16(digit))
+	}
+	for len(digits) > 0 && digits[0] == 0 {
+		digits = digits[1:]
+		weight--
+	}
+	for len(digits) > 0 && digits[len(digits)-1] == 0 {
+		digits = digits[:len(digits)-1]
+	}
+	negative := value.coefficient.Sign() < 0
+	if len(digits) == 0 {
+		weight = 0
+		negative = false
+	}
+	var data []byte
+	if value.scale <= numericShortDScaleMax && weight >= numericShortWeightMin && weight <= numericShortWeightMax {
+		header := uint16(numericShort) | uint16(value.scale<<numericShortDScaleShift)&numericShortDScaleMask
+		if negative {
+			header |= numericShortSignMask
+		}
+		if weight < 0 {
+			header |= numericShortWeightSign
+		}
+		header |= uint16(weight) & numericShortWeightMask
+		data = binary.LittleEndian.AppendUint16(data, header)
+	} else {
+		header := uint16(numericPos) | uint16(value.scale)&numericDScaleMask
+		if negative {
+			header = uint16(numericNeg) | uint16(value.scale)&numericDScaleMask
+		}
+		data = binary.LittleEndian.AppendUint16(data, header)
+		data = binary.LittleEndian.AppendUint16(data, uint16(int16(weight)))
+	}
+	for _, digit := range digits {
+		data = binary.LittleEndian.AppendUint16(data, digit)
+	}
+	return data
+}
+
+// palloc returns a copy of the encoded value that is allocated within the current memory context.
+func (value numericValue) palloc() C.Numeric {
+	data := value.encode()
+	size := numericVarHdrSz + len(data)
+	num := C.palloc(C.size_t(size))
+	*(*uint32)(num) = uint32(size) << 2
+	copy(unsafe.Slice((*byte)(unsafe.Add(num, numericVarHdrSz)), len(data)), data)
+	return C.Numeric(num)
+}
+
+// decodeNumeric decodes the bytes of a numeric that follow its varlena header.
+func decodeNumeric(data []byte) (numericValue, error) {
+	corrupt := numericError{sqlerrcode: C.ERRCODE_INTERNAL_ERROR, message: "invalid numeric data"}
+	if len(data) < 2 {
+		return numericValue{}, corrupt
+	}
+	header := binary.LittleEndian.Uint16(data)
+	var negative bool
+	var weight, scale int
+	switch header & numericSignMask {
+	case numericSpecial:
+		switch header & numericExtSignMask {
+		case numericNaN, numericPInf, numericNInf:
+			return numericValue{special: header & numericExtSignMask}, nil
+		default:
+			return numericValue{}, corrupt
+		}
+	case numericShort:
+		negative = header&numericShortSignMask != 0
+		scale = int(header&numericShortDScaleMask) >> numericShortDScaleShift
+		weight = int(header & numericShortWeightMask)
+		if header&numericShortWeightSign != 0 {
+			weight -= numericShortWeightMask + 1
+		}
+		data = data[2:]
+	default:
+		if len(data) < 4 {
+			return numericValue{}, corrupt
+		}
+		negative = header&numericSignMask == numericNeg
+		scale = int(header & numericDScaleMask)
+		weight = int(int16(binary.LittleEndian.Uint16(data[2:])))
+		data = data[4:]
+	}
+	if len(data)%2 != 0 {
+		return numericValue{}, corrupt
+	}
+	// The coefficient is the sum of each digit scaled to its position, after which it's scaled to the display scale
+	coefficient := new(big.Int)
+	nbase := big.NewInt(numericNBase)
+	ndigits := len(data) / 2
+	for i := 0; i < ndigits; i++ {
+		coefficient.Mul(coefficient, nbase)
+		coefficient.Add(coefficient, big.NewInt(int64(binary.LittleEndian.Uint16(data[2*i:]))))
+	}
+	// The last digit is in position weight-ndigits+1, so the digits represent coefficient * 10^(4*(weight-ndigits+1))
+	exponent := numericDecDigits*(weight-ndigits+1) + scale
+	ten := big.NewInt(10)
+	if exponent >= 0 {
+		coefficient.Mul(coefficient, new(big.Int).Exp(ten, big.NewInt(int64(exponent)), nil))
+	} else {
+		coefficient.Quo(coefficient, new(big.Int).Exp(ten, big.NewInt(int64(-exponent)), nil))
+	}
+	if negative {
+		coefficient.Neg(coefficient)
+	}
+	return numericValue{coefficient: coefficient, scale: scale}, nil
+}
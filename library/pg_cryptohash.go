@@ -30,9 +30,14 @@ typedef enum
 typedef struct pg_cryptohash_ctx {
 	pg_cryptohash_type hashType;
 } pg_cryptohash_ctx;
+
+typedef struct pg_hmac_ctx {
+	pg_cryptohash_type hashType;
+} pg_hmac_ctx;
 */
 import "C"
 import (
+	"crypto/hmac"
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
@@ -42,28 +47,36 @@ import (
 	"unsafe"
 )
 
-var pg_cryptohash_store sync.Map
+var (
+	pg_cryptohash_store sync.Map
+	pg_hmac_store       sync.Map
+	// noCryptoError is the error message of every context, as the hashes themselves never fail.
+	noCryptoError = (*C.pgext_const_char)(C.CString(""))
+)
 
-//export pg_cryptohash_create
-func pg_cryptohash_create(typ C.pg_cryptohash_type) *C.pg_cryptohash_ctx {
-	ctx := (*C.pg_cryptohash_ctx)(C.malloc(C.size_t(unsafe.Sizeof(C.pg_cryptohash_ctx{}))))
-	ctx.hashType = typ
-	ctxPtr := uintptr(unsafe.Pointer(ctx))
+// cryptohashConstructor returns the constructor of the hash with the given type, which is MD5 for unknown types.
+func cryptohashConstructor(typ C.pg_cryptohash_type) func() hash.Hash {
 	switch typ {
-	case 1:
-		pg_cryptohash_store.Store(ctxPtr, sha1.New())
+	case C.PG_SHA1:
+		return sha1.New
 	case C.PG_SHA224:
-		pg_cryptohash_store.Store(ctxPtr, sha512.New512_224())
+		return sha256.New224
 	case C.PG_SHA256:
-		pg_cryptohash_store.Store(ctxPtr, sha256.New())
+		return sha256.New
 	case C.PG_SHA384:
-		pg_cryptohash_store.Store(ctxPtr, sha512.New384())
+		return sha512.New384
 	case C.PG_SHA512:
-		pg_cryptohash_store.Store(ctxPtr, sha512.New())
+		return sha512.New
 	default:
-		// Default to MD5
-		pg_cryptohash_store.Store(ctxPtr, md5.New())
+		return md5.New
 	}
+}
+
+//export pg_cryptohash_create
+func pg_cryptohash_create(typ C.pg_cryptohash_type) *C.pg_cryptohash_ctx {
+	ctx := (*C.pg_cryptohash_ctx)(C.malloc(C.size_t(unsafe.Sizeof(C.pg_cryptohash_ctx{}))))
+	ctx.hashType = typ
+	pg_cryptohash_store.Store(uintptr(unsafe.Pointer(ctx)), cryptohashConstructor(typ)())
 	return ctx
 }
 
@@ -128,5 +141,75 @@ func pg_cryptohash_free(ctx *C.pg_cryptohash_ctx) {
 
 //export pg_cryptohash_error
 func pg_cryptohash_error(ctx *C.pg_cryptohash_ctx) *C.pgext_const_char {
-	return C.CString("")
+	return noCryptoError
+}
+
+//export pg_hmac_create
+func pg_hmac_create(typ C.pg_cryptohash_type) *C.pg_hmac_ctx {
+	ctx := (*C.pg_hmac_ctx)(C.malloc(C.size_t(unsafe.Sizeof(C.pg_hmac_ctx{}))))
+	ctx.hashType = typ
+	return ctx
+}
+
+//export pg_hmac_init
+func pg_hmac_init(ctx *C.pg_hmac_ctx, key *C.pgext_const_uint8, len C.size_t) C.int {
+	if ctx == nil {
+		return -1
+	}
+	var keySlice []byte
+	if len > 0 {
+		keySlice = C.GoBytes(unsafe.Pointer(key), C.int(len))
+	}
+	pg_hmac_store.Store(uintptr(unsafe.Pointer(ctx)), hmac.New(cryptohashConstructor(ctx.hashType), keySlice))
+	return 0
+}
+
+//export pg_hmac_update
+func pg_hmac_update(ctx *C.pg_hmac_ctx, data *C.pgext_const_uint8, len C.size_t) C.int {
+	if ctx == nil {
+		return -1
+	}
+	if len == 0 {
+		return 0
+	}
+	storedHashAny, ok := pg_hmac_store.Load(uintptr(unsafe.Pointer(ctx)))
+	if !ok {
+		return -1
+	}
+	dataSlice := unsafe.Slice((*byte)(unsafe.Pointer(data)), int(len))
+	if _, err := storedHashAny.(hash.Hash).Write(dataSlice); err != nil {
+		return 1
+	}
+	return 0
+}
+
+//export pg_hmac_final
+func pg_hmac_final(ctx *C.pg_hmac_ctx, dest *C.uint8_t, destLen C.size_t) C.int {
+	if ctx == nil {
+		return -1
+	}
+	storedHashAny, ok := pg_hmac_store.Load(uintptr(unsafe.Pointer(ctx)))
+	if !ok {
+		return -1
+	}
+	sum := storedHashAny.(hash.Hash).Sum(nil)
+	destSlice := unsafe.Slice((*byte)(unsafe.Pointer(dest)), int(destLen))
+	if len(sum) > len(destSlice) {
+		return -1
+	}
+	copy(destSlice, sum)
+	return 0
+}
+
+//export pg_hmac_free
+func pg_hmac_free(ctx *C.pg_hmac_ctx) {
+	if ctx != nil {
+		pg_hmac_store.Delete(uintptr(unsafe.Pointer(ctx)))
+		C.free(unsafe.Pointer(ctx))
+	}
+}
+
+//export pg_hmac_error
+func pg_hmac_error(ctx *C.pg_hmac_ctx) *C.pgext_const_char {
+	return noCryptoError
 }
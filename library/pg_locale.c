@@ -0,0 +1,305 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+#include <ctype.h>
+
+extern pg_locale_t pgext_locale_lookup(Oid collid, bool deterministic);
+extern char* pgext_unicode_case(int kind, const char* str, size_t len, size_t* result_len);
+
+// collation_provider is the host's collation provider, or NULL if only the built-in collations exist.
+static PgExtCollationProvider* collation_provider;
+
+// pgext_set_collation_provider sets the host's collation provider. Setting NULL leaves only the default, C, and POSIX
+// collations.
+DLLEXPORT uintptr_t pgext_set_collation_provider(PgExtCollationProvider* provider) {
+	collation_provider = provider;
+	return 0;
+}
+
+// describe_collation writes the details of the collation, raising an error if it does not exist. C and POSIX are
+// always bytewise, regardless of the provider.
+static bool describe_collation(Oid collid, bool* collate_is_c, bool* ctype_is_c, bool* deterministic) {
+	*collate_is_c = true;
+	*ctype_is_c = true;
+	*deterministic = true;
+	if (collid == C_COLLATION_OID || collid == POSIX_COLLATION_OID) {
+		return true;
+	}
+	if (collation_provider != NULL) {
+		if (collation_provider->describe(collid, collate_is_c, ctype_is_c, deterministic)) {
+			return true;
+		}
+	} else if (collid == DEFAULT_COLLATION_OID) {
+		*ctype_is_c = false;
+		return true;
+	}
+	pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "cache lookup failed for collation %u", collid);
+	return false;
+}
+
+// check_collation_set raises an error when the collation could not be determined by the caller, which happens when
+// the arguments of a function have conflicting collations.
+static bool check_collation_set(Oid collid, const char* func_name) {
+	if (OidIsValid(collid)) {
+		return true;
+	}
+	if (errstart(ERROR, NULL)) {
+		errcode(ERRCODE_INDETERMINATE_COLLATION);
+		if (func_name != NULL) {
+			errmsg_internal("could not determine which collation to use for %s function", func_name);
+		} else {
+			errmsg_internal("could not determine which collation to use for string comparison");
+		}
+		errhint("Use the COLLATE clause to set the collation explicitly.");
+		errfinish(__FILE__, __LINE__, __func__);
+	}
+	return false;
+}
+
+DLLEXPORT bool lc_collate_is_c(Oid collation) {
+	if (!OidIsValid(collation)) {
+		return false;
+	}
+	bool collate_is_c, ctype_is_c, deterministic;
+	if (!describe_collation(collation, &collate_is_c, &ctype_is_c, &deterministic)) {
+		return false;
+	}
+	return collate_is_c;
+}
+
+DLLEXPORT bool lc_ctype_is_c(Oid collation) {
+	if (!OidIsValid(collation)) {
+		return false;
+	}
+	bool collate_is_c, ctype_is_c, deterministic;
+	if (!describe_collation(collation, &collate_is_c, &ctype_is_c, &deterministic)) {
+		return false;
+	}
+	return ctype_is_c;
+}
+
+// pg_newlocale_from_collation returns NULL for the default collation, the same as Postgres 15 and earlier, which
+// extensions take to mean the database's locale. The locales of other collations live for the life of the process.
+DLLEXPORT pg_locale_t pg_newlocale_from_collation(Oid collid) {
+	if (collid == DEFAULT_COLLATION_OID) {
+		return NULL;
+	}
+	if (!OidIsValid(collid)) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "cache lookup failed for collation %u", collid);
+		return NULL;
+	}
+	bool collate_is_c, ctype_is_c, deterministic;
+	if (!describe_collation(collid, &collate_is_c, &ctype_is_c, &deterministic)) {
+		return NULL;
+	}
+	return pgext_locale_lookup(collid, deterministic);
+}
+
+DLLEXPORT unsigned char pg_ascii_toupper(unsigned char ch) {
+	if (ch >= 'a' && ch <= 'z') {
+		ch += 'A' - 'a';
+	}
+	return ch;
+}
+
+DLLEXPORT unsigned char pg_ascii_tolower(unsigned char ch) {
+	if (ch >= 'A' && ch <= 'Z') {
+		ch += 'a' - 'A';
+	}
+	return ch;
+}
+
+// pg_toupper folds ASCII letters, along with the letters of single-byte encodings. Bytes with the high bit set are
+// left alone in multibyte encodings, as they're only part of a character.
+DLLEXPORT unsigned char pg_toupper(unsigned char ch) {
+	if (IS_HIGHBIT_SET(ch)) {
+		return pg_database_encoding_max_length() == 1 ? (unsigned char)toupper(ch) : ch;
+	}
+	return pg_ascii_toupper(ch);
+}
+
+DLLEXPORT unsigned char pg_tolower(unsigned char ch) {
+	if (IS_HIGHBIT_SET(ch)) {
+		return pg_database_encoding_max_length() == 1 ? (unsigned char)tolower(ch) : ch;
+	}
+	return pg_ascii_tolower(ch);
+}
+
+DLLEXPORT int pg_strcasecmp(const char* s1, const char* s2) {
+	for (;;) {
+		unsigned char ch1 = (unsigned char)*s1++;
+		unsigned char ch2 = (unsigned char)*s2++;
+		if (ch1 != ch2) {
+			ch1 = pg_tolower(ch1);
+			ch2 = pg_tolower(ch2);
+			if (ch1 != ch2) {
+				return (int)ch1 - (int)ch2;
+			}
+		}
+		if (ch1 == 0) {
+			return 0;
+		}
+	}
+}
+
+DLLEXPORT int pg_strncasecmp(const char* s1, const char* s2, size_t n) {
+	for (; n > 0; n--) {
+		unsigned char ch1 = (unsigned char)*s1++;
+		unsigned char ch2 = (unsigned char)*s2++;
+		if (ch1 != ch2) {
+			ch1 = pg_tolower(ch1);
+			ch2 = pg_tolower(ch2);
+			if (ch1 != ch2) {
+				return (int)ch1 - (int)ch2;
+			}
+		}
+		if (ch1 == 0) {
+			return 0;
+		}
+	}
+	return 0;
+}
+
+static inline bool ascii_isalnum(unsigned char ch) {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9');
+}
+
+DLLEXPORT char* asc_tolower(const char* buff, size_t nbytes) {
+	if (buff == NULL) {
+		return NULL;
+	}
+	char* result = pnstrdup(buff, nbytes);
+	if (result == NULL) {
+		return NULL;
+	}
+	for (char* p = result; *p; p++) {
+		*p = (char)pg_ascii_tolower((unsigned char)*p);
+	}
+	return result;
+}
+
+DLLEXPORT char* asc_toupper(const char* buff, size_t nbytes) {
+	if (buff == NULL) {
+		return NULL;
+	}
+	char* result = pnstrdup(buff, nbytes);
+	if (result == NULL) {
+		return NULL;
+	}
+	for (char* p = result; *p; p++) {
+		*p = (char)pg_ascii_toupper((unsigned char)*p);
+	}
+	return result;
+}
+
+DLLEXPORT char* asc_initcap(const char* buff, size_t nbytes) {
+	if (buff == NULL) {
+		return NULL;
+	}
+	char* result = pnstrdup(buff, nbytes);
+	if (result == NULL) {
+		return NULL;
+	}
+	bool wasalnum = false;
+	for (char* p = result; *p; p++) {
+		if (wasalnum) {
+			*p = (char)pg_ascii_tolower((unsigned char)*p);
+		} else {
+			*p = (char)pg_ascii_toupper((unsigned char)*p);
+		}
+		wasalnum = ascii_isalnum((unsigned char)*p);
+	}
+	return result;
+}
+
+// change_case implements str_tolower, str_toupper, and str_initcap. Collations with a C ctype only change the case of
+// ASCII letters, while the rest are changed by the provider, or according to Unicode when there is no provider.
+static char* change_case(const char* buff, size_t nbytes, Oid collid, int kind, const char* func_name) {
+	if (buff == NULL) {
+		return NULL;
+	}
+	bool collate_is_c, ctype_is_c, deterministic;
+	if (!check_collation_set(collid, func_name) ||
+		!describe_collation(collid, &collate_is_c, &ctype_is_c, &deterministic)) {
+		return NULL;
+	}
+	if (ctype_is_c) {
+		switch (kind) {
+		case PGEXT_CASE_LOWER:
+			return asc_tolower(buff, nbytes);
+		case PGEXT_CASE_UPPER:
+			return asc_toupper(buff, nbytes);
+		default:
+			return asc_initcap(buff, nbytes);
+		}
+	}
+	size_t result_len = 0;
+	char* converted;
+	if (collation_provider != NULL) {
+		int sqlerrcode = 0;
+		char* message = NULL;
+		converted = collation_provider->change_case(collid, kind, buff, nbytes, &result_len, &sqlerrcode, &message);
+		if (message != NULL) {
+			free(converted);
+			pgext_raise_host_error(sqlerrcode, message);
+			return NULL;
+		}
+	} else {
+		converted = pgext_unicode_case(kind, buff, nbytes, &result_len);
+	}
+	char* result = pnstrdup(converted != NULL ? converted : "", converted != NULL ? result_len : 0);
+	free(converted);
+	return result;
+}
+
+DLLEXPORT char* str_tolower(const char* buff, size_t nbytes, Oid collid) {
+	return change_case(buff, nbytes, collid, PGEXT_CASE_LOWER, "lower()");
+}
+
+DLLEXPORT char* str_toupper(const char* buff, size_t nbytes, Oid collid) {
+	return change_case(buff, nbytes, collid, PGEXT_CASE_UPPER, "upper()");
+}
+
+DLLEXPORT char* str_initcap(const char* buff, size_t nbytes, Oid collid) {
+	return change_case(buff, nbytes, collid, PGEXT_CASE_INITCAP, "initcap()");
+}
+
+// varstr_cmp compares two strings under the collation. Strings that the collation considers equal are still ordered
+// bytewise when the collation is deterministic, so that only identical strings are equal.
+DLLEXPORT int varstr_cmp(const char* arg1, int len1, const char* arg2, int len2, Oid collid) {
+	bool collate_is_c, ctype_is_c, deterministic;
+	if (!check_collation_set(collid, NULL) || !describe_collation(collid, &collate_is_c, &ctype_is_c, &deterministic)) {
+		return 0;
+	}
+	int result = memcmp(arg1, arg2, len1 < len2 ? len1 : len2);
+	if (result == 0 && len1 != len2) {
+		result = len1 < len2 ? -1 : 1;
+	}
+	if (collate_is_c || result == 0 || collation_provider == NULL) {
+		return result;
+	}
+	int sqlerrcode = 0;
+	char* message = NULL;
+	int collated = collation_provider->compare(collid, arg1, (size_t)len1, arg2, (size_t)len2, &sqlerrcode, &message);
+	if (message != NULL) {
+		pgext_raise_host_error(sqlerrcode, message);
+		return 0;
+	}
+	if (collated == 0 && deterministic) {
+		return result;
+	}
+	return collated;
+}
@@ -0,0 +1,120 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extension_cgo
+
+/*
+#include "exports.h"
+*/
+import "C"
+import (
+	"sync"
+	"unicode"
+	"unicode/utf8"
+	"unsafe"
+)
+
+var (
+	// locales contains the locale of every collation that an extension has opened, which are never freed.
+	locales = make(map[C.Oid]C.pg_locale_t)
+	// localesMutex gates access to the locales, as extensions may open them from any session.
+	localesMutex = &sync.Mutex{}
+)
+
+//export pgext_locale_lookup
+func pgext_locale_lookup(collid C.Oid, deterministic C.bool) C.pg_locale_t {
+	localesMutex.Lock()
+	defer localesMutex.Unlock()
+	if locale, ok := locales[collid]; ok {
+		return locale
+	}
+	locale := (C.pg_locale_t)(C.calloc(1, C.sizeof_pg_locale_struct))
+	locale.provider = C.COLLPROVIDER_LIBC
+	locale.deterministic = deterministic
+	locales[collid] = locale
+	return locale
+}
+
+//export pgext_unicode_case
+func pgext_unicode_case(kind C.int, str *C.char, length C.size_t, resultLen *C.size_t) *C.char {
+	var input []byte
+	if length > 0 {
+		input = unsafe.Slice((*byte)(unsafe.Pointer(str)), int(length))
+	}
+	output := unicodeCase(kind, input, C.GetDatabaseEncoding() == C.PG_UTF8)
+	result := (*C.char)(C.malloc(C.size_t(len(output) + 1)))
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(result)), len(output)), output)
+	*resultLen = C.size_t(len(output))
+	return result
+}
+
+// unicodeCase changes the case of the input according to Unicode. Bytes that are not valid UTF-8 are kept as they are.
+// Only ASCII letters are changed when the input is not UTF-8, as the bytes of other encodings do not map onto runes.
+func unicodeCase(kind C.int, input []byte, isUTF8 bool) []byte {
+	output := make([]byte, 0, len(input))
+	wasAlnum := false
+	for len(input) > 0 {
+		r, size := rune(input[0]), 1
+		if r >= utf8.RuneSelf {
+			if isUTF8 {
+				r, size = utf8.DecodeRune(input)
+			}
+			if !isUTF8 || (r == utf8.RuneError && size == 1) {
+				output = append(output, input[0])
+				input = input[1:]
+				wasAlnum = false
+				continue
+			}
+		}
+		switch kind {
+		case C.PGEXT_CASE_LOWER:
+			r = unicode.ToLower(r)
+		case C.PGEXT_CASE_UPPER:
+			r = unicode.ToUpper(r)
+		default:
+			if wasAlnum {
+				r = unicode.ToLower(r)
+			} else {
+				r = unicode.ToTitle(r)
+			}
+			wasAlnum = unicode.IsLetter(r) || unicode.IsDigit(r)
+		}
+		output = utf8.AppendRune(output, r)
+		input = input[size:]
+	}
+	return output
+}
+
+//export pgext_unicode_isclass
+func pgext_unicode_isclass(ptr *C.char, length C.int, charClass C.int) C.bool {
+	if C.GetDatabaseEncoding() != C.PG_UTF8 || length <= 0 {
+		return false
+	}
+	r, size := utf8.DecodeRune(unsafe.Slice((*byte)(unsafe.Pointer(ptr)), int(length)))
+	if r == utf8.RuneError && size <= 1 {
+		return false
+	}
+	switch charClass {
+	case C.PGEXT_CHAR_DIGIT:
+		return C.bool(unicode.IsDigit(r))
+	case C.PGEXT_CHAR_SPACE:
+		return C.bool(unicode.IsSpace(r))
+	case C.PGEXT_CHAR_ALPHA:
+		return C.bool(unicode.IsLetter(r))
+	case C.PGEXT_CHAR_ALNUM:
+		return C.bool(unicode.IsLetter(r) || unicode.IsDigit(r))
+	default:
+		return C.bool(unicode.IsGraphic(r))
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extension_cgo
+
+/*
+#include "exports.h"
+*/
+import "C"
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+var (
+	// recordTypes contains the descriptors of every blessed record type, indexed by their typmod.
+	recordTypes []*C.TupleDescData
+	// recordTypmods maps the attributes of each blessed record type to its typmod.
+	recordTypmods = make(map[string]C.int32_t)
+	// recordTypesMutex gates access to the record types, as extensions may bless descriptors from any session.
+	recordTypesMutex = &sync.RWMutex{}
+)
+
+//export pgext_record_register
+func pgext_record_register(tupdesc *C.TupleDescData) C.int32_t {
+	key := recordTypeKey(tupdesc)
+	recordTypesMutex.Lock()
+	defer recordTypesMutex.Unlock()
+	if typmod, ok := recordTypmods[key]; ok {
+		C.free(unsafe.Pointer(tupdesc))
+		return typmod
+	}
+	typmod := C.int32_t(len(recordTypes))
+	tupdesc.tdtypmod = typmod
+	recordTypes = append(recordTypes, tupdesc)
+	recordTypmods[key] = typmod
+	return typmod
+}
+
+//export pgext_record_lookup
+func pgext_record_lookup(typmod C.int32_t) *C.TupleDescData {
+	recordTypesMutex.RLock()
+	defer recordTypesMutex.RUnlock()
+	if typmod < 0 || int(typmod) >= len(recordTypes) {
+		return nil
+	}
+	return recordTypes[typmod]
+}
+
+// recordTypeKey returns a key that is equal for descriptors with the same attribute names, types, and collations.
+func recordTypeKey(tupdesc *C.TupleDescData) string {
+	attrs := unsafe.Slice(&tupdesc.attrs[0], int(tupdesc.natts))
+	sb := strings.Builder{}
+	for _, att := range attrs {
+		fmt.Fprintf(&sb, "%s\x00%d,%d,%d;", C.GoString(&att.attname.data[0]), att.atttypid, att.atttypmod, att.attcollation)
+	}
+	return sb.String()
+}
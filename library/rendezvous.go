@@ -0,0 +1,53 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extension_cgo
+
+/*
+#include "exports.h"
+*/
+import "C"
+import (
+	"sync"
+	"unsafe"
+)
+
+var (
+	// rendezvousVariables contains every rendezvous variable by name. Each variable is a pointer that was allocated with
+	// calloc, so that its address stays the same for the life of the process.
+	rendezvousVariables = make(map[string]*unsafe.Pointer)
+	// rendezvousMutex gates access to the variables, as libraries may be loaded from any session.
+	rendezvousMutex = &sync.Mutex{}
+)
+
+// find_rendezvous_variable returns the variable with the given name, creating it as NULL if it does not yet exist.
+// Libraries find each other through these, such as a procedural language and the modules that transform its types,
+// so the variables are shared by every library and session in the process. Postgres truncates names to fit within
+// NAMEDATALEN, so the same is done here.
+//
+//export find_rendezvous_variable
+func find_rendezvous_variable(varName *C.char) *unsafe.Pointer {
+	name := C.GoString(varName)
+	if len(name) >= C.NAMEDATALEN {
+		name = name[:C.NAMEDATALEN-1]
+	}
+	rendezvousMutex.Lock()
+	defer rendezvousMutex.Unlock()
+	if variable, ok := rendezvousVariables[name]; ok {
+		return variable
+	}
+	variable := (*unsafe.Pointer)(C.calloc(1, C.size_t(unsafe.Sizeof(unsafe.Pointer(nil)))))
+	rendezvousVariables[name] = variable
+	return variable
+}
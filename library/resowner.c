@@ -0,0 +1,90 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+DLLEXPORT ResourceOwner CurrentResourceOwner = NULL;
+
+// ResourceReleaseCallbackItem is a callback that was registered by an extension.
+typedef struct ResourceReleaseCallbackItem {
+	struct ResourceReleaseCallbackItem* next;
+	ResourceReleaseCallback             callback;
+	void*                               arg;
+} ResourceReleaseCallbackItem;
+
+// release_callbacks are shared by every session, the same as they're shared by every transaction of a Postgres backend.
+static ResourceReleaseCallbackItem* release_callbacks = NULL;
+// release_callbacks_lock is held while the list of callbacks is changed, as sessions run on many threads.
+static volatile bool release_callbacks_lock = false;
+
+static void lock_release_callbacks(void) {
+	while (__atomic_test_and_set(&release_callbacks_lock, __ATOMIC_ACQUIRE)) {
+	}
+}
+
+static void unlock_release_callbacks(void) {
+	__atomic_clear(&release_callbacks_lock, __ATOMIC_RELEASE);
+}
+
+DLLEXPORT void RegisterResourceReleaseCallback(ResourceReleaseCallback callback, void* arg) {
+	ResourceReleaseCallbackItem* item = (ResourceReleaseCallbackItem*)malloc(sizeof(ResourceReleaseCallbackItem));
+	if (item == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_OUT_OF_MEMORY, "out of memory");
+		return;
+	}
+	item->callback = callback;
+	item->arg = arg;
+	lock_release_callbacks();
+	item->next = release_callbacks;
+	release_callbacks = item;
+	unlock_release_callbacks();
+}
+
+DLLEXPORT void UnregisterResourceReleaseCallback(ResourceReleaseCallback callback, void* arg) {
+	lock_release_callbacks();
+	ResourceReleaseCallbackItem* prev = NULL;
+	for (ResourceReleaseCallbackItem* item = release_callbacks; item != NULL; prev = item, item = item->next) {
+		if (item->callback == callback && item->arg == arg) {
+			if (prev != NULL) {
+				prev->next = item->next;
+			} else {
+				release_callbacks = item->next;
+			}
+			free(item);
+			break;
+		}
+	}
+	unlock_release_callbacks();
+}
+
+// pgext_release_resources calls every release callback on behalf of the session, which is bound to the current thread
+// for the duration. This is treated as the end of an aborted transaction, so that extensions free what they had
+// tracked for the session without warning about leaks.
+void pgext_release_resources(PgExtBackendState* state) {
+	lock_release_callbacks();
+	ResourceReleaseCallbackItem* items = release_callbacks;
+	unlock_release_callbacks();
+	if (items == NULL) {
+		return;
+	}
+	// The lock is not held while the callbacks are called, as they may register or unregister callbacks themselves.
+	// Items are only added to the head of the list, so the walk sees the callbacks as of the time it began.
+	PgExtBackendState* previous = pgext_backend_state_bind(state);
+	for (int phase = RESOURCE_RELEASE_BEFORE_LOCKS; phase <= RESOURCE_RELEASE_AFTER_LOCKS; phase++) {
+		for (ResourceReleaseCallbackItem* item = items; item != NULL; item = item->next) {
+			item->callback((ResourceReleasePhase)phase, false, true, item->arg);
+		}
+	}
+	pgext_backend_state_bind(previous);
+}
@@ -0,0 +1,48 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+DLLEXPORT char* downcase_truncate_identifier(const char* ident, int len, bool warn) {
+	return downcase_identifier(ident, len, warn, true);
+}
+
+DLLEXPORT char* downcase_identifier(const char* ident, int len, bool warn, bool truncate) {
+	char* result = (char*)palloc(len + 1);
+	if (result == NULL) {
+		return NULL;
+	}
+	for (int i = 0; i < len; i++) {
+		result[i] = (char)pg_tolower((unsigned char)ident[i]);
+	}
+	result[len] = '\0';
+	if (len >= NAMEDATALEN && truncate) {
+		truncate_identifier(result, len, warn);
+	}
+	return result;
+}
+
+// truncate_identifier truncates the identifier in place, without splitting a multibyte character.
+DLLEXPORT void truncate_identifier(char* ident, int len, bool warn) {
+	if (len < NAMEDATALEN) {
+		return;
+	}
+	len = pg_mbcliplen(ident, len, NAMEDATALEN - 1);
+	if (warn && errstart(NOTICE, NULL)) {
+		errcode(ERRCODE_NAME_TOO_LONG);
+		errmsg_internal("identifier \"%s\" will be truncated to \"%.*s\"", ident, len, ident);
+		errfinish(__FILE__, __LINE__, __func__);
+	}
+	ident[len] = '\0';
+}
@@ -0,0 +1,87 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+#if defined(_WIN32) || defined(_WIN64)
+#include <process.h>
+#define getpid _getpid
+#else
+#include <unistd.h>
+#endif
+
+// These describe the session that is bound to the current thread, and are loaded from its state whenever it's bound.
+DLLEXPORT int MyProcPid = 0;
+DLLEXPORT Oid MyDatabaseId = InvalidOid;
+DLLEXPORT char* application_name = "";
+
+// pgext_backend_state_set_info sets the details of the session, as the host does once a connection has started, and
+// again whenever they change. Names are truncated to fit within NAMEDATALEN, the same as Postgres.
+DLLEXPORT uintptr_t pgext_backend_state_set_info(PgExtBackendState* state, PgExtSessionInfo* info) {
+	state->proc_pid = info->proc_pid;
+	state->database_id = info->database_id;
+	snprintf(state->database_name, NAMEDATALEN, "%s", info->database_name != NULL ? info->database_name : "");
+	snprintf(state->application_name, NAMEDATALEN, "%s", info->application_name != NULL ? info->application_name : "");
+	return 0;
+}
+
+// pgext_load_session_info loads the globals that describe the session from its state. These are never changed by
+// extensions, so there is nothing to save when the state is unbound.
+void pgext_load_session_info(PgExtBackendState* state) {
+	MyProcPid = pgext_session_pid(state);
+	MyDatabaseId = state->database_id;
+	application_name = state->application_name;
+}
+
+// pgext_session_pid returns the process ID of the session, which is the ID of the host's process when the host has not
+// given one.
+int pgext_session_pid(PgExtBackendState* state) {
+	return state->proc_pid != 0 ? state->proc_pid : (int)getpid();
+}
+
+// session_database_name returns the name of the session's database, which is "postgres" when the host has not named
+// it.
+static const char* session_database_name(void) {
+	PgExtBackendState* state = pgext_backend_state();
+	return state->database_name[0] != '\0' ? state->database_name : "postgres";
+}
+
+// get_database_name returns the name of the database with the given OID, or NULL if it does not exist. The host does
+// not describe its other databases, so only the session's own database exists.
+DLLEXPORT char* get_database_name(Oid dbid) {
+	if (dbid != pgext_backend_state()->database_id) {
+		return NULL;
+	}
+	return pstrdup(session_database_name());
+}
+
+DLLEXPORT Oid get_database_oid(const char* dbname, bool missing_ok) {
+	if (strcmp(dbname, session_database_name()) == 0) {
+		return pgext_backend_state()->database_id;
+	}
+	if (!missing_ok) {
+		pgext_raise_error(ERROR, ERRCODE_UNDEFINED_DATABASE, "database \"%s\" does not exist", dbname);
+	}
+	return InvalidOid;
+}
+
+DLLEXPORT Datum current_database(FunctionCallInfo fcinfo) {
+	NameData* name = (NameData*)palloc0(sizeof(NameData));
+	strncpy(name->data, session_database_name(), NAMEDATALEN - 1);
+	return (Datum)name;
+}
+
+DLLEXPORT Datum pg_backend_pid(FunctionCallInfo fcinfo) {
+	return (Datum)(uint32_t)MyProcPid;
+}
@@ -0,0 +1,271 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// These are implemented in shmem.go, which holds the shared structs and tranches, and the locks that back each LWLock.
+extern void* pgext_shmem_init_struct(char* name, size_t* size, bool* found);
+extern bool pgext_lwlock_request(char* name, int num_locks);
+extern LWLockPadded* pgext_lwlock_tranche(char* name);
+extern int pgext_lwlock_new_tranche_id(void);
+extern void pgext_lwlock_register_tranche(int id, char* name);
+extern char* pgext_lwlock_tranche_name(int id);
+extern bool pgext_lwlock_acquire(LWLock* lock, LWLockMode mode, bool wait);
+extern void pgext_lwlock_release(LWLock* lock, LWLockMode mode);
+extern void pgext_lwlock_reset(LWLock* lock);
+
+DLLEXPORT shmem_request_hook_type shmem_request_hook = NULL;
+DLLEXPORT shmem_startup_hook_type shmem_startup_hook = NULL;
+// process_shmem_requests_in_progress is only set while the host runs the request hooks, which is the only time that
+// shared memory and named tranches may be requested, other than the _PG_init of a shared preload library, which is
+// where extensions that predate the request hook make their requests.
+DLLEXPORT bool process_shmem_requests_in_progress = false;
+
+// main_lwlocks are the locks that Postgres allocates for itself, which extensions reference through MainLWLockArray.
+static LWLockPadded main_lwlocks[NUM_FIXED_LWLOCKS];
+DLLEXPORT LWLockPadded* MainLWLockArray = main_lwlocks;
+
+// requests_allowed returns whether shared memory and named tranches may be requested.
+static bool requests_allowed(void) {
+	return process_shmem_requests_in_progress || process_shared_preload_libraries_in_progress;
+}
+
+// call_request_hook calls the request hook, which extensions chain to the hook of every library before them.
+static Datum call_request_hook(void* arg) {
+	if (shmem_request_hook != NULL) {
+		shmem_request_hook();
+	}
+	return 0;
+}
+
+// call_startup_hook calls the startup hook, which extensions chain to the hook of every library before them.
+static Datum call_startup_hook(void* arg) {
+	if (shmem_startup_hook != NULL) {
+		shmem_startup_hook();
+	}
+	return 0;
+}
+
+// pgext_shmem_initialize runs the request hooks, followed by the startup hooks, the same as the postmaster does once
+// every shared preload library has been loaded. The host only calls this once. Returns the error that a hook raised, or
+// NULL if every hook returned normally.
+DLLEXPORT PgExtErrorData* pgext_shmem_initialize(void) {
+	Datum result;
+	process_shmem_requests_in_progress = true;
+	PgExtErrorData* edata = pgext_catch_errors(call_request_hook, NULL, &result);
+	process_shmem_requests_in_progress = false;
+	if (edata != NULL) {
+		return edata;
+	}
+	return pgext_catch_errors(call_startup_hook, NULL, &result);
+}
+
+// RequestAddinShmemSpace is accepted without reserving anything, as each shared struct is allocated when it's first
+// initialized.
+DLLEXPORT void RequestAddinShmemSpace(size_t size) {
+	if (!requests_allowed()) {
+		pgext_raise_error(FATAL, ERRCODE_OBJECT_NOT_IN_PREREQUISITE_STATE,
+			"cannot request additional shared memory outside shmem_request_hook");
+	}
+}
+
+DLLEXPORT void* ShmemAllocNoError(size_t size) {
+	return calloc(1, size > 0 ? size : 1);
+}
+
+DLLEXPORT void* ShmemAlloc(size_t size) {
+	void* ptr = ShmemAllocNoError(size);
+	if (ptr == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_OUT_OF_MEMORY, "out of shared memory (%zu bytes requested)", size);
+	}
+	return ptr;
+}
+
+DLLEXPORT void* ShmemInitStruct(const char* name, size_t size, bool* foundPtr) {
+	size_t existing_size = size;
+	void* ptr = pgext_shmem_init_struct((char*)name, &existing_size, foundPtr);
+	if (ptr == NULL) {
+		if (*foundPtr) {
+			pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR,
+				"ShmemIndex entry size is wrong for data structure \"%s\": expected %zu, actual %zu", name, size,
+				existing_size);
+		} else {
+			pgext_raise_error(ERROR, ERRCODE_OUT_OF_MEMORY,
+				"not enough shared memory for data structure \"%s\" (%zu bytes requested)", name, size);
+		}
+	}
+	return ptr;
+}
+
+DLLEXPORT void RequestNamedLWLockTranche(const char* tranche_name, int num_lwlocks) {
+	if (!requests_allowed()) {
+		pgext_raise_error(FATAL, ERRCODE_OBJECT_NOT_IN_PREREQUISITE_STATE,
+			"cannot request additional LWLocks outside shmem_request_hook");
+		return;
+	}
+	if (!pgext_lwlock_request((char*)tranche_name, num_lwlocks)) {
+		pgext_raise_error(FATAL, ERRCODE_OUT_OF_MEMORY, "out of shared memory");
+	}
+}
+
+DLLEXPORT LWLockPadded* GetNamedLWLockTranche(const char* tranche_name) {
+	LWLockPadded* locks = pgext_lwlock_tranche((char*)tranche_name);
+	if (locks == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "requested tranche is not registered");
+	}
+	return locks;
+}
+
+DLLEXPORT int LWLockNewTrancheId(void) {
+	return pgext_lwlock_new_tranche_id();
+}
+
+DLLEXPORT void LWLockRegisterTranche(int tranche_id, const char* tranche_name) {
+	pgext_lwlock_register_tranche(tranche_id, (char*)tranche_name);
+}
+
+DLLEXPORT void LWLockInitialize(LWLock* lock, int tranche_id) {
+	pgext_lwlock_reset(lock);
+	memset(lock, 0, sizeof(LWLock));
+	lock->tranche = (uint16_t)tranche_id;
+}
+
+// raise_lwlock_error raises an error about the lock, which names the lock's tranche.
+static void raise_lwlock_error(LWLock* lock, const char* fmt) {
+	char* name = pgext_lwlock_tranche_name(lock->tranche);
+	char tranche[NAMEDATALEN];
+	snprintf(tranche, sizeof(tranche), "%s", name != NULL ? name : "extension");
+	free(name);
+	pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, fmt, tranche);
+}
+
+// remember_lwlock records that the current session holds the lock. The caller has already checked that there's room.
+static void remember_lwlock(PgExtBackendState* state, LWLock* lock, LWLockMode mode) {
+	state->held_lwlocks[state->num_held_lwlocks].lock = lock;
+	state->held_lwlocks[state->num_held_lwlocks].mode = mode;
+	state->num_held_lwlocks++;
+}
+
+// check_lwlock_room raises an error when the session cannot hold any more locks, returning whether it can.
+static bool check_lwlock_room(PgExtBackendState* state) {
+	if (state->num_held_lwlocks >= PGEXT_MAX_SIMUL_LWLOCKS) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "too many LWLocks taken");
+		return false;
+	}
+	return true;
+}
+
+// wait_lwlock blocks until the lock is acquired. The globals of the session are suspended while it waits, so that
+// other sessions may bind their own.
+static void wait_lwlock(LWLock* lock, LWLockMode mode) {
+	pgext_backend_state_suspend();
+	pgext_lwlock_acquire(lock, mode, true);
+	pgext_backend_state_resume();
+}
+
+// LWLockAcquire returns whether the lock was acquired without waiting.
+DLLEXPORT bool LWLockAcquire(LWLock* lock, LWLockMode mode) {
+	PgExtBackendState* state = pgext_backend_state();
+	if (!check_lwlock_room(state)) {
+		return false;
+	}
+	bool immediate = pgext_lwlock_acquire(lock, mode, false);
+	if (!immediate) {
+		wait_lwlock(lock, mode);
+	}
+	remember_lwlock(state, lock, mode);
+	return immediate;
+}
+
+DLLEXPORT bool LWLockConditionalAcquire(LWLock* lock, LWLockMode mode) {
+	PgExtBackendState* state = pgext_backend_state();
+	if (!check_lwlock_room(state)) {
+		return false;
+	}
+	if (!pgext_lwlock_acquire(lock, mode, false)) {
+		return false;
+	}
+	remember_lwlock(state, lock, mode);
+	return true;
+}
+
+// LWLockAcquireOrWait acquires the lock if it's free, and otherwise waits until it's free without acquiring it, which
+// returns false.
+DLLEXPORT bool LWLockAcquireOrWait(LWLock* lock, LWLockMode mode) {
+	PgExtBackendState* state = pgext_backend_state();
+	if (!check_lwlock_room(state)) {
+		return false;
+	}
+	if (pgext_lwlock_acquire(lock, mode, false)) {
+		remember_lwlock(state, lock, mode);
+		return true;
+	}
+	wait_lwlock(lock, mode);
+	pgext_lwlock_release(lock, mode);
+	return false;
+}
+
+DLLEXPORT void LWLockRelease(LWLock* lock) {
+	PgExtBackendState* state = pgext_backend_state();
+	// Locks are usually released in the reverse order that they were acquired, so we search from the end
+	int i = state->num_held_lwlocks - 1;
+	for (; i >= 0; i--) {
+		if (state->held_lwlocks[i].lock == lock) {
+			break;
+		}
+	}
+	if (i < 0) {
+		raise_lwlock_error(lock, "lock %s is not held");
+		return;
+	}
+	LWLockMode mode = state->held_lwlocks[i].mode;
+	state->num_held_lwlocks--;
+	memmove(&state->held_lwlocks[i], &state->held_lwlocks[i + 1],
+		(state->num_held_lwlocks - i) * sizeof(PgExtHeldLWLock));
+	pgext_lwlock_release(lock, mode);
+}
+
+// pgext_lwlock_unwind releases every lock of the session that was acquired after it held the given number of locks,
+// which is how Postgres releases the locks of a transaction that is aborted by an error.
+void pgext_lwlock_unwind(PgExtBackendState* state, int num_held) {
+	while (state->num_held_lwlocks > num_held) {
+		state->num_held_lwlocks--;
+		PgExtHeldLWLock* held = &state->held_lwlocks[state->num_held_lwlocks];
+		pgext_lwlock_release(held->lock, held->mode);
+	}
+}
+
+DLLEXPORT void LWLockReleaseAll(void) {
+	pgext_lwlock_unwind(pgext_backend_state(), 0);
+}
+
+DLLEXPORT bool LWLockHeldByMe(LWLock* lock) {
+	PgExtBackendState* state = pgext_backend_state();
+	for (int i = 0; i < state->num_held_lwlocks; i++) {
+		if (state->held_lwlocks[i].lock == lock) {
+			return true;
+		}
+	}
+	return false;
+}
+
+DLLEXPORT bool LWLockHeldByMeInMode(LWLock* lock, LWLockMode mode) {
+	PgExtBackendState* state = pgext_backend_state();
+	for (int i = 0; i < state->num_held_lwlocks; i++) {
+		if (state->held_lwlocks[i].lock == lock && state->held_lwlocks[i].mode == mode) {
+			return true;
+		}
+	}
+	return false;
+}
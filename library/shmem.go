@@ -0,0 +1,203 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extension_cgo
+
+/*
+#include "exports.h"
+*/
+import "C"
+import (
+	"sync"
+	"unsafe"
+)
+
+// shmemStruct is a struct that was allocated through ShmemInitStruct.
+type shmemStruct struct {
+	ptr  unsafe.Pointer
+	size C.size_t
+}
+
+// lwlockTranche is a tranche of locks that was requested through RequestNamedLWLockTranche.
+type lwlockTranche struct {
+	id    C.int
+	locks *C.LWLockPadded
+}
+
+var (
+	// shmemIndex contains every struct that was allocated through ShmemInitStruct, keyed by name. Shared memory is
+	// never freed, the same as in Postgres, where it lasts for as long as the server does.
+	shmemIndex = make(map[string]shmemStruct)
+	// lwlockTranches contains the tranches that were requested through RequestNamedLWLockTranche, keyed by name.
+	lwlockTranches = make(map[string]lwlockTranche)
+	// lwlockTrancheNames contains the name of every tranche, keyed by their ID.
+	lwlockTrancheNames = make(map[C.int]string)
+	// nextLWLockTrancheID is the ID of the next tranche that is created.
+	nextLWLockTrancheID C.int = C.LWTRANCHE_FIRST_USER_DEFINED
+	// shmemMutex gates access to the structs and tranches, as they're shared by every session.
+	shmemMutex = &sync.Mutex{}
+	// lwlocks contains the lock that backs each LWLock, which is created when the LWLock is first acquired. The
+	// LWLocks themselves live in C memory, which Go memory may not be embedded in.
+	lwlocks = make(map[*C.LWLock]*sync.RWMutex)
+	// lwlocksMutex gates access to the backing locks.
+	lwlocksMutex = &sync.Mutex{}
+)
+
+// pgext_shmem_init_struct returns the struct with the given name, allocating it with the given size if it does not yet
+// exist, and setting found to whether it existed. Returns NULL when the struct exists with a different size, which is
+// then written to size, or when it cannot be allocated.
+//
+//export pgext_shmem_init_struct
+func pgext_shmem_init_struct(cname *C.char, size *C.size_t, found *C.bool) unsafe.Pointer {
+	name := C.GoString(cname)
+	if len(name) >= C.SHMEM_INDEX_KEYSIZE {
+		name = name[:C.SHMEM_INDEX_KEYSIZE-1]
+	}
+	shmemMutex.Lock()
+	defer shmemMutex.Unlock()
+	if existing, ok := shmemIndex[name]; ok {
+		*found = true
+		if existing.size != *size {
+			*size = existing.size
+			return nil
+		}
+		return existing.ptr
+	}
+	*found = false
+	ptr := C.calloc(1, max(*size, 1))
+	if ptr != nil {
+		shmemIndex[name] = shmemStruct{ptr: ptr, size: *size}
+	}
+	return ptr
+}
+
+// pgext_lwlock_request allocates the named tranche with the given number of locks. A tranche that was already
+// requested is kept as it is, as Postgres only ever finds the first tranche with a name.
+//
+//export pgext_lwlock_request
+func pgext_lwlock_request(cname *C.char, numLocks C.int) C.bool {
+	name := C.GoString(cname)
+	shmemMutex.Lock()
+	defer shmemMutex.Unlock()
+	if _, ok := lwlockTranches[name]; ok {
+		return true
+	}
+	locks := (*C.LWLockPadded)(C.calloc(C.size_t(max(numLocks, 1)), C.sizeof_LWLockPadded))
+	if locks == nil {
+		return false
+	}
+	id := nextLWLockTrancheID
+	nextLWLockTrancheID++
+	padded := unsafe.Slice(locks, int(numLocks))
+	for i := range padded {
+		(*C.LWLock)(unsafe.Pointer(&padded[i])).tranche = C.uint16_t(id)
+	}
+	lwlockTranches[name] = lwlockTranche{id: id, locks: locks}
+	lwlockTrancheNames[id] = name
+	return true
+}
+
+// pgext_lwlock_tranche returns the locks of the named tranche, or NULL if it was never requested.
+//
+//export pgext_lwlock_tranche
+func pgext_lwlock_tranche(cname *C.char) *C.LWLockPadded {
+	shmemMutex.Lock()
+	defer shmemMutex.Unlock()
+	return lwlockTranches[C.GoString(cname)].locks
+}
+
+//export pgext_lwlock_new_tranche_id
+func pgext_lwlock_new_tranche_id() C.int {
+	shmemMutex.Lock()
+	defer shmemMutex.Unlock()
+	id := nextLWLockTrancheID
+	nextLWLockTrancheID++
+	return id
+}
+
+//export pgext_lwlock_register_tranche
+func pgext_lwlock_register_tranche(id C.int, cname *C.char) {
+	shmemMutex.Lock()
+	defer shmemMutex.Unlock()
+	lwlockTrancheNames[id] = C.GoString(cname)
+}
+
+// pgext_lwlock_tranche_name returns the name of the tranche, allocated with malloc, which is "extension" for tranches
+// that were never named, the same as Postgres.
+//
+//export pgext_lwlock_tranche_name
+func pgext_lwlock_tranche_name(id C.int) *C.char {
+	shmemMutex.Lock()
+	name, ok := lwlockTrancheNames[id]
+	shmemMutex.Unlock()
+	if !ok {
+		if id < C.LWTRANCHE_FIRST_USER_DEFINED {
+			name = "main"
+		} else {
+			name = "extension"
+		}
+	}
+	return C.CString(name)
+}
+
+// backingLWLock returns the lock that backs the LWLock, creating it if it does not yet exist.
+func backingLWLock(lock *C.LWLock) *sync.RWMutex {
+	lwlocksMutex.Lock()
+	defer lwlocksMutex.Unlock()
+	backing, ok := lwlocks[lock]
+	if !ok {
+		backing = &sync.RWMutex{}
+		lwlocks[lock] = backing
+	}
+	return backing
+}
+
+// pgext_lwlock_acquire acquires the lock in the given mode. When wait is false, this returns whether the lock was
+// acquired without waiting, and otherwise blocks until the lock is acquired.
+//
+//export pgext_lwlock_acquire
+func pgext_lwlock_acquire(lock *C.LWLock, mode C.LWLockMode, wait C.bool) C.bool {
+	backing := backingLWLock(lock)
+	switch {
+	case mode == C.LW_SHARED && bool(wait):
+		backing.RLock()
+		return true
+	case mode == C.LW_SHARED:
+		return C.bool(backing.TryRLock())
+	case bool(wait):
+		backing.Lock()
+		return true
+	default:
+		return C.bool(backing.TryLock())
+	}
+}
+
+//export pgext_lwlock_release
+func pgext_lwlock_release(lock *C.LWLock, mode C.LWLockMode) {
+	backing := backingLWLock(lock)
+	if mode == C.LW_SHARED {
+		backing.RUnlock()
+	} else {
+		backing.Unlock()
+	}
+}
+
+// pgext_lwlock_reset forgets the backing lock of an LWLock that is being initialized, which must not be held.
+//
+//export pgext_lwlock_reset
+func pgext_lwlock_reset(lock *C.LWLock) {
+	lwlocksMutex.Lock()
+	defer lwlocksMutex.Unlock()
+	delete(lwlocks, lock)
+}
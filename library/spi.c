@@ -0,0 +1,559 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// These are the results of the most recent query, which extensions read directly. SPI_result is also set by the SPI
+// functions that cannot report an error through their return value.
+DLLEXPORT uint64_t SPI_processed = 0;
+DLLEXPORT SPITupleTable* SPI_tuptable = NULL;
+DLLEXPORT int SPI_result = 0;
+
+// _SPI_PLAN_MAGIC identifies valid plans, so that plans that have been freed are caught.
+#define _SPI_PLAN_MAGIC 569278163
+
+// PgExtSPIConnection is a single level of connection to SPI. Extensions may connect while already connected, such as
+// when a function that uses SPI is called by a query that was run through SPI.
+typedef struct PgExtSPIConnection {
+	// procCxt is current while connected, and holds the results of queries. execCxt holds the temporary allocations of
+	// each query, and is reset once the query has finished.
+	MemoryContext              procCxt;
+	MemoryContext              execCxt;
+	// savedcxt is the context that was current before connecting, which SPI_palloc allocates within.
+	MemoryContext              savedcxt;
+	// These are the values of the SPI globals before connecting, which are restored once disconnected.
+	uint64_t                   outer_processed;
+	SPITupleTable*             outer_tuptable;
+	int                        outer_result;
+	struct PgExtSPIConnection* prev;
+} PgExtSPIConnection;
+
+// _SPI_plan is a prepared query. Queries are planned by the host's executor each time that they're run, so the plan
+// only holds the query along with the types of its parameters.
+typedef struct _SPI_plan {
+	int           magic;
+	bool          saved;
+	char*         query;
+	int           nargs;
+	Oid*          argtypes;
+	MemoryContext plancxt;
+} _SPI_plan;
+
+// executor is the host's executor, or NULL if extensions may not run queries.
+static PgExtSPIExecutor* executor;
+
+// pgext_set_spi_executor sets the host's executor. Setting NULL causes queries to raise an error.
+DLLEXPORT uintptr_t pgext_set_spi_executor(PgExtSPIExecutor* new_executor) {
+	executor = new_executor;
+	return 0;
+}
+
+// spi_current returns the innermost connection of the current session, or NULL if it is not connected.
+static PgExtSPIConnection* spi_current(void) {
+	return pgext_backend_state()->spi_connection;
+}
+
+// spi_disconnect closes the innermost connection, restoring the memory context and globals from before it was made.
+static void spi_disconnect(PgExtBackendState* state) {
+	PgExtSPIConnection* connection = state->spi_connection;
+	CurrentMemoryContext = connection->savedcxt;
+	SPI_processed = connection->outer_processed;
+	SPI_tuptable = connection->outer_tuptable;
+	SPI_result = connection->outer_result;
+	state->spi_connection = connection->prev;
+	MemoryContextDelete(connection->execCxt);
+	MemoryContextDelete(connection->procCxt);
+	free(connection);
+}
+
+// pgext_spi_unwind closes every connection of the current session that was made after the given connection, which is
+// how Postgres cleans up after an error aborts the transaction.
+void pgext_spi_unwind(struct PgExtSPIConnection* connection) {
+	PgExtBackendState* state = pgext_backend_state();
+	while (state->spi_connection != NULL && state->spi_connection != connection) {
+		spi_disconnect(state);
+	}
+}
+
+// pgext_spi_free frees every connection of the given session, which is being destroyed.
+void pgext_spi_free(PgExtBackendState* state) {
+	while (state->spi_connection != NULL) {
+		PgExtSPIConnection* connection = state->spi_connection;
+		state->spi_connection = connection->prev;
+		MemoryContextDelete(connection->execCxt);
+		MemoryContextDelete(connection->procCxt);
+		free(connection);
+	}
+}
+
+// spi_strdup returns a copy of the string in the current memory context.
+static char* spi_strdup(const char* str) {
+	size_t len = strlen(str) + 1;
+	char* copy = (char*)palloc(len);
+	memcpy(copy, str, len);
+	return copy;
+}
+
+// spi_valid_attnum returns whether the attribute number refers to a user attribute of the descriptor. System
+// attributes are not supported, as the tuples of the shim do not have them.
+static bool spi_valid_attnum(TupleDesc tupdesc, int fnumber) {
+	return fnumber > 0 && fnumber <= tupdesc->natts;
+}
+
+DLLEXPORT int SPI_fnumber(TupleDesc tupdesc, const char* fname) {
+	for (int i = 0; i < tupdesc->natts; i++) {
+		Form_pg_attribute att = TupleDescAttr(tupdesc, i);
+		if (!att->attisdropped && strcmp(att->attname.data, fname) == 0) {
+			return att->attnum;
+		}
+	}
+	return SPI_ERROR_NOATTRIBUTE;
+}
+
+DLLEXPORT char* SPI_fname(TupleDesc tupdesc, int fnumber) {
+	SPI_result = 0;
+	if (!spi_valid_attnum(tupdesc, fnumber)) {
+		SPI_result = SPI_ERROR_NOATTRIBUTE;
+		return NULL;
+	}
+	return spi_strdup(TupleDescAttr(tupdesc, fnumber - 1)->attname.data);
+}
+
+DLLEXPORT Oid SPI_gettypeid(TupleDesc tupdesc, int fnumber) {
+	SPI_result = 0;
+	if (!spi_valid_attnum(tupdesc, fnumber)) {
+		SPI_result = SPI_ERROR_NOATTRIBUTE;
+		return 0;
+	}
+	return TupleDescAttr(tupdesc, fnumber - 1)->atttypid;
+}
+
+DLLEXPORT Datum SPI_getbinval(HeapTuple tuple, TupleDesc tupdesc, int fnumber, bool* isnull) {
+	SPI_result = 0;
+	if (!spi_valid_attnum(tupdesc, fnumber)) {
+		SPI_result = SPI_ERROR_NOATTRIBUTE;
+		*isnull = true;
+		return 0;
+	}
+	Datum* values = (Datum*)palloc(tupdesc->natts * sizeof(Datum));
+	bool* nulls = (bool*)palloc(tupdesc->natts * sizeof(bool));
+	heap_deform_tuple(tuple, tupdesc, values, nulls);
+	Datum value = values[fnumber - 1];
+	*isnull = nulls[fnumber - 1];
+	pfree(values);
+	pfree(nulls);
+	return value;
+}
+
+DLLEXPORT char* SPI_getrelname(Relation rel) {
+	return spi_strdup(rel->rd_rel->relname.data);
+}
+
+// SPI_connect_ext connects to SPI. Every query is run by the host's executor, so the nonatomic option has no effect.
+DLLEXPORT int SPI_connect_ext(int options) {
+	PgExtBackendState* state = pgext_backend_state();
+	// The current context is fetched first, as it also creates the top context when neither exists yet
+	MemoryContext savedcxt = pgext_current_context();
+	PgExtSPIConnection* connection = (PgExtSPIConnection*)calloc(1, sizeof(PgExtSPIConnection));
+	if (connection == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_OUT_OF_MEMORY, "out of memory");
+		return SPI_ERROR_CONNECT;
+	}
+	connection->procCxt = AllocSetContextCreateInternal(TopMemoryContext, "SPI Proc", 0, 0, 0);
+	connection->execCxt = AllocSetContextCreateInternal(TopMemoryContext, "SPI Exec", 0, 0, 0);
+	if (connection->procCxt == NULL || connection->execCxt == NULL) {
+		MemoryContextDelete(connection->procCxt);
+		MemoryContextDelete(connection->execCxt);
+		free(connection);
+		pgext_raise_error(ERROR, ERRCODE_OUT_OF_MEMORY, "out of memory");
+		return SPI_ERROR_CONNECT;
+	}
+	connection->savedcxt = savedcxt;
+	CurrentMemoryContext = connection->procCxt;
+	connection->outer_processed = SPI_processed;
+	connection->outer_tuptable = SPI_tuptable;
+	connection->outer_result = SPI_result;
+	connection->prev = state->spi_connection;
+	state->spi_connection = connection;
+	SPI_processed = 0;
+	SPI_tuptable = NULL;
+	SPI_result = 0;
+	return SPI_OK_CONNECT;
+}
+
+DLLEXPORT int SPI_connect(void) {
+	return SPI_connect_ext(0);
+}
+
+DLLEXPORT int SPI_finish(void) {
+	PgExtBackendState* state = pgext_backend_state();
+	if (state->spi_connection == NULL) {
+		return SPI_ERROR_UNCONNECTED;
+	}
+	spi_disconnect(state);
+	return SPI_OK_FINISH;
+}
+
+// spi_tuptable returns a table that holds a copy of the rows of the result, which is allocated within the connection's
+// memory context.
+static SPITupleTable* spi_tuptable(PgExtSPIConnection* connection, PgExtSPIResult* result) {
+	MemoryContext tuptabcxt = AllocSetContextCreateInternal(connection->procCxt, "SPI TupTable", 0, 0, 0);
+	if (tuptabcxt == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_OUT_OF_MEMORY, "out of memory");
+	}
+	MemoryContext oldcxt = MemoryContextSwitchTo(tuptabcxt);
+	SPITupleTable* tuptable = (SPITupleTable*)palloc0(sizeof(SPITupleTable));
+	tuptable->tuptabcxt = tuptabcxt;
+	tuptable->tupdesc = CreateTupleDescCopy(result->tupdesc);
+	tuptable->alloced = result->nrows > 0 ? result->nrows : 1;
+	tuptable->vals = (HeapTuple*)palloc(tuptable->alloced * sizeof(HeapTuple));
+	int natts = result->tupdesc->natts;
+	for (uint64_t i = 0; i < result->nrows; i++) {
+		tuptable->vals[i] = heap_form_tuple(tuptable->tupdesc, result->values + i * natts, result->isnull + i * natts);
+		tuptable->numvals++;
+	}
+	MemoryContextSwitchTo(oldcxt);
+	return tuptable;
+}
+
+// spi_execute runs the query through the host's executor. The Nulls array uses 'n' for each parameter that is NULL,
+// and may be NULL when no parameter is NULL.
+static int spi_execute(const char* src, int nargs, Oid* argtypes, Datum* Values, const char* Nulls, bool read_only,
+	long tcount) {
+	PgExtSPIConnection* connection = spi_current();
+	if (connection == NULL) {
+		return SPI_ERROR_UNCONNECTED;
+	}
+	if (src == NULL || nargs < 0 || (nargs > 0 && (argtypes == NULL || Values == NULL)) || tcount < 0) {
+		return SPI_ERROR_ARGUMENT;
+	}
+	if (executor == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_FEATURE_NOT_SUPPORTED,
+			"cannot run SPI queries as the host has not registered an executor");
+	}
+	SPI_processed = 0;
+	SPI_tuptable = NULL;
+	SPI_result = 0;
+	bool* nulls = (bool*)MemoryContextAllocZero(connection->execCxt, (nargs > 0 ? nargs : 1) * sizeof(bool));
+	for (int i = 0; i < nargs; i++) {
+		nulls[i] = Nulls != NULL && Nulls[i] == 'n';
+	}
+	PgExtSPIResult result;
+	memset(&result, 0, sizeof(result));
+	executor->execute(src, nargs, argtypes, Values, nulls, read_only, (int64_t)tcount, &result);
+	MemoryContextReset(connection->execCxt);
+	if (result.message != NULL) {
+		if (result.handle != 0) {
+			executor->release(result.handle);
+		}
+		pgext_raise_host_error(result.sqlerrcode, result.message);
+	}
+	SPITupleTable* tuptable = NULL;
+	if (result.tupdesc != NULL) {
+		// The result must be released even when its rows cannot be copied, so the error is caught and then rethrown
+		sigjmp_buf* saved_exception_stack = PG_exception_stack;
+		ErrorContextCallback* saved_context_stack = error_context_stack;
+		sigjmp_buf local_sigjmp_buf;
+		if (sigsetjmp(local_sigjmp_buf, 0) != 0) {
+			PG_exception_stack = saved_exception_stack;
+			error_context_stack = saved_context_stack;
+			executor->release(result.handle);
+			pg_re_throw();
+		}
+		PG_exception_stack = &local_sigjmp_buf;
+		tuptable = spi_tuptable(connection, &result);
+		PG_exception_stack = saved_exception_stack;
+	}
+	executor->release(result.handle);
+	SPI_processed = result.processed;
+	SPI_tuptable = tuptable;
+	return result.status;
+}
+
+DLLEXPORT int SPI_execute(const char* src, bool read_only, long tcount) {
+	return spi_execute(src, 0, NULL, NULL, NULL, read_only, tcount);
+}
+
+DLLEXPORT int SPI_exec(const char* src, long tcount) {
+	return spi_execute(src, 0, NULL, NULL, NULL, false, tcount);
+}
+
+DLLEXPORT int SPI_execute_with_args(const char* src, int nargs, Oid* argtypes, Datum* Values, const char* Nulls,
+	bool read_only, long tcount) {
+	return spi_execute(src, nargs, argtypes, Values, Nulls, read_only, tcount);
+}
+
+// spi_make_plan returns a plan for the query within a new memory context, which is a child of the given context.
+static SPIPlanPtr spi_make_plan(MemoryContext parent, const char* src, int nargs, Oid* argtypes) {
+	MemoryContext plancxt = AllocSetContextCreateInternal(parent, "SPI Plan", 0, 0, 0);
+	if (plancxt == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_OUT_OF_MEMORY, "out of memory");
+	}
+	MemoryContext oldcxt = MemoryContextSwitchTo(plancxt);
+	SPIPlanPtr plan = (SPIPlanPtr)palloc0(sizeof(_SPI_plan));
+	plan->magic = _SPI_PLAN_MAGIC;
+	plan->plancxt = plancxt;
+	plan->query = spi_strdup(src);
+	plan->nargs = nargs;
+	if (nargs > 0) {
+		plan->argtypes = (Oid*)palloc(nargs * sizeof(Oid));
+		memcpy(plan->argtypes, argtypes, nargs * sizeof(Oid));
+	}
+	MemoryContextSwitchTo(oldcxt);
+	return plan;
+}
+
+// spi_valid_plan returns whether the plan was returned by SPI_prepare and has not been freed.
+static bool spi_valid_plan(SPIPlanPtr plan) {
+	return plan != NULL && plan->magic == _SPI_PLAN_MAGIC;
+}
+
+DLLEXPORT SPIPlanPtr SPI_prepare(const char* src, int nargs, Oid* argtypes) {
+	PgExtSPIConnection* connection = spi_current();
+	if (connection == NULL) {
+		SPI_result = SPI_ERROR_UNCONNECTED;
+		return NULL;
+	}
+	if (src == NULL || nargs < 0 || (nargs > 0 && argtypes == NULL)) {
+		SPI_result = SPI_ERROR_ARGUMENT;
+		return NULL;
+	}
+	SPI_result = 0;
+	return spi_make_plan(connection->procCxt, src, nargs, argtypes);
+}
+
+// SPI_keepplan moves the plan out of the connection's memory context, so that it lives until it is freed.
+DLLEXPORT int SPI_keepplan(SPIPlanPtr plan) {
+	if (!spi_valid_plan(plan) || plan->saved) {
+		return SPI_ERROR_ARGUMENT;
+	}
+	MemoryContextSetParent(plan->plancxt, TopMemoryContext);
+	plan->saved = true;
+	return 0;
+}
+
+DLLEXPORT SPIPlanPtr SPI_saveplan(SPIPlanPtr plan) {
+	if (!spi_valid_plan(plan)) {
+		SPI_result = SPI_ERROR_ARGUMENT;
+		return NULL;
+	}
+	if (spi_current() == NULL) {
+		SPI_result = SPI_ERROR_UNCONNECTED;
+		return NULL;
+	}
+	SPIPlanPtr copy = spi_make_plan(TopMemoryContext, plan->query, plan->nargs, plan->argtypes);
+	copy->saved = true;
+	SPI_result = 0;
+	return copy;
+}
+
+DLLEXPORT int SPI_freeplan(SPIPlanPtr plan) {
+	if (!spi_valid_plan(plan)) {
+		return SPI_ERROR_ARGUMENT;
+	}
+	plan->magic = 0;
+	MemoryContextDelete(plan->plancxt);
+	return 0;
+}
+
+DLLEXPORT int SPI_execute_plan(SPIPlanPtr plan, Datum* Values, const char* Nulls, bool read_only, long tcount) {
+	if (!spi_valid_plan(plan) || tcount < 0) {
+		return SPI_ERROR_ARGUMENT;
+	}
+	if (plan->nargs > 0 && Values == NULL) {
+		return SPI_ERROR_PARAM;
+	}
+	return spi_execute(plan->query, plan->nargs, plan->argtypes, Values, Nulls, read_only, tcount);
+}
+
+DLLEXPORT int SPI_execp(SPIPlanPtr plan, Datum* Values, const char* Nulls, long tcount) {
+	return SPI_execute_plan(plan, Values, Nulls, false, tcount);
+}
+
+DLLEXPORT int SPI_getargcount(SPIPlanPtr plan) {
+	if (!spi_valid_plan(plan)) {
+		SPI_result = SPI_ERROR_ARGUMENT;
+		return -1;
+	}
+	return plan->nargs;
+}
+
+DLLEXPORT Oid SPI_getargtypeid(SPIPlanPtr plan, int argIndex) {
+	if (!spi_valid_plan(plan) || argIndex < 0 || argIndex >= plan->nargs) {
+		SPI_result = SPI_ERROR_ARGUMENT;
+		return 0;
+	}
+	return plan->argtypes[argIndex];
+}
+
+// SPI_getvalue returns the text of the attribute's value, which the host's executor produces.
+DLLEXPORT char* SPI_getvalue(HeapTuple tuple, TupleDesc tupdesc, int fnumber) {
+	SPI_result = 0;
+	if (!spi_valid_attnum(tupdesc, fnumber)) {
+		SPI_result = SPI_ERROR_NOATTRIBUTE;
+		return NULL;
+	}
+	bool isnull;
+	Datum value = SPI_getbinval(tuple, tupdesc, fnumber, &isnull);
+	if (isnull) {
+		return NULL;
+	}
+	if (executor == NULL) {
+		SPI_result = SPI_ERROR_NOOUTFUNC;
+		return NULL;
+	}
+	char* text = NULL;
+	if (!executor->output(TupleDescAttr(tupdesc, fnumber - 1)->atttypid, value, &text)) {
+		pgext_raise_host_error(0, text);
+	}
+	char* copy = spi_strdup(text);
+	free(text);
+	return copy;
+}
+
+DLLEXPORT void* SPI_palloc(size_t size) {
+	PgExtSPIConnection* connection = spi_current();
+	if (connection == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "SPI_palloc called while not connected to SPI");
+	}
+	return MemoryContextAlloc(connection->savedcxt, size);
+}
+
+DLLEXPORT void* SPI_repalloc(void* pointer, size_t size) {
+	return repalloc(pointer, size);
+}
+
+DLLEXPORT void SPI_pfree(void* pointer) {
+	pfree(pointer);
+}
+
+// SPI_copytuple copies the tuple into the memory context that was current before connecting, so that it survives
+// SPI_finish.
+DLLEXPORT HeapTuple SPI_copytuple(HeapTuple tuple) {
+	PgExtSPIConnection* connection = spi_current();
+	if (tuple == NULL) {
+		SPI_result = SPI_ERROR_ARGUMENT;
+		return NULL;
+	}
+	if (connection == NULL) {
+		SPI_result = SPI_ERROR_UNCONNECTED;
+		return NULL;
+	}
+	MemoryContext oldcxt = MemoryContextSwitchTo(connection->savedcxt);
+	HeapTuple copy = heap_copytuple(tuple);
+	MemoryContextSwitchTo(oldcxt);
+	return copy;
+}
+
+// SPI_returntuple returns the tuple as a composite Datum within the memory context that was current before connecting.
+DLLEXPORT HeapTupleHeader SPI_returntuple(HeapTuple tuple, TupleDesc tupdesc) {
+	PgExtSPIConnection* connection = spi_current();
+	if (tuple == NULL || tupdesc == NULL) {
+		SPI_result = SPI_ERROR_ARGUMENT;
+		return NULL;
+	}
+	if (connection == NULL) {
+		SPI_result = SPI_ERROR_UNCONNECTED;
+		return NULL;
+	}
+	if (tupdesc->tdtypeid == RECORDOID && tupdesc->tdtypmod < 0) {
+		BlessTupleDesc(tupdesc);
+	}
+	MemoryContext oldcxt = MemoryContextSwitchTo(connection->savedcxt);
+	HeapTupleHeader header = (HeapTupleHeader)heap_copy_tuple_as_datum(tuple, tupdesc);
+	MemoryContextSwitchTo(oldcxt);
+	return header;
+}
+
+DLLEXPORT void SPI_freetuple(HeapTuple tuple) {
+	heap_freetuple(tuple);
+}
+
+DLLEXPORT void SPI_freetuptable(SPITupleTable* tuptable) {
+	if (tuptable == NULL) {
+		return;
+	}
+	if (tuptable == SPI_tuptable) {
+		SPI_tuptable = NULL;
+	}
+	MemoryContextDelete(tuptable->tuptabcxt);
+}
+
+DLLEXPORT const char* SPI_result_code_string(int code) {
+	static char buf[64];
+	switch (code) {
+	case SPI_ERROR_CONNECT:
+		return "SPI_ERROR_CONNECT";
+	case SPI_ERROR_COPY:
+		return "SPI_ERROR_COPY";
+	case SPI_ERROR_OPUNKNOWN:
+		return "SPI_ERROR_OPUNKNOWN";
+	case SPI_ERROR_UNCONNECTED:
+		return "SPI_ERROR_UNCONNECTED";
+	case SPI_ERROR_ARGUMENT:
+		return "SPI_ERROR_ARGUMENT";
+	case SPI_ERROR_PARAM:
+		return "SPI_ERROR_PARAM";
+	case SPI_ERROR_TRANSACTION:
+		return "SPI_ERROR_TRANSACTION";
+	case SPI_ERROR_NOATTRIBUTE:
+		return "SPI_ERROR_NOATTRIBUTE";
+	case SPI_ERROR_NOOUTFUNC:
+		return "SPI_ERROR_NOOUTFUNC";
+	case SPI_ERROR_TYPUNKNOWN:
+		return "SPI_ERROR_TYPUNKNOWN";
+	case SPI_ERROR_REL_DUPLICATE:
+		return "SPI_ERROR_REL_DUPLICATE";
+	case SPI_ERROR_REL_NOT_FOUND:
+		return "SPI_ERROR_REL_NOT_FOUND";
+	case SPI_OK_CONNECT:
+		return "SPI_OK_CONNECT";
+	case SPI_OK_FINISH:
+		return "SPI_OK_FINISH";
+	case SPI_OK_FETCH:
+		return "SPI_OK_FETCH";
+	case SPI_OK_UTILITY:
+		return "SPI_OK_UTILITY";
+	case SPI_OK_SELECT:
+		return "SPI_OK_SELECT";
+	case SPI_OK_SELINTO:
+		return "SPI_OK_SELINTO";
+	case SPI_OK_INSERT:
+		return "SPI_OK_INSERT";
+	case SPI_OK_DELETE:
+		return "SPI_OK_DELETE";
+	case SPI_OK_UPDATE:
+		return "SPI_OK_UPDATE";
+	case SPI_OK_CURSOR:
+		return "SPI_OK_CURSOR";
+	case SPI_OK_INSERT_RETURNING:
+		return "SPI_OK_INSERT_RETURNING";
+	case SPI_OK_DELETE_RETURNING:
+		return "SPI_OK_DELETE_RETURNING";
+	case SPI_OK_UPDATE_RETURNING:
+		return "SPI_OK_UPDATE_RETURNING";
+	case SPI_OK_REWRITTEN:
+		return "SPI_OK_REWRITTEN";
+	case SPI_OK_REL_REGISTER:
+		return "SPI_OK_REL_REGISTER";
+	case SPI_OK_REL_UNREGISTER:
+		return "SPI_OK_REL_UNREGISTER";
+	case SPI_OK_TD_REGISTER:
+		return "SPI_OK_TD_REGISTER";
+	case SPI_OK_MERGE:
+		return "SPI_OK_MERGE";
+	}
+	snprintf(buf, sizeof(buf), "Unrecognized SPI code %d", code);
+	return buf;
+}
@@ -0,0 +1,158 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+#include <errno.h>
+
+// The buffer of a StringInfo is allocated within the memory context that is current when it's initialized, and grows
+// within that same context, as repalloc keeps the context of the chunk that it's given.
+
+DLLEXPORT StringInfo makeStringInfo(void) {
+	StringInfo str = (StringInfo)palloc(sizeof(StringInfoData));
+	initStringInfo(str);
+	return str;
+}
+
+DLLEXPORT void initStringInfo(StringInfo str) {
+	int size = 1024;
+	str->data = (char*)palloc(size);
+	str->maxlen = size;
+	resetStringInfo(str);
+}
+
+DLLEXPORT void resetStringInfo(StringInfo str) {
+	str->data[0] = '\0';
+	str->len = 0;
+	str->cursor = 0;
+}
+
+// enlargeStringInfo makes room for at least needed more bytes, along with the terminator. The buffer doubles in size
+// so that appending a byte at a time does not reallocate for each byte.
+DLLEXPORT void enlargeStringInfo(StringInfo str, int needed) {
+	if (needed < 0) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "invalid string enlargement request size: %d", needed);
+		return;
+	}
+	if ((size_t)needed >= MaxAllocSize - (size_t)str->len) {
+		if (errstart(ERROR, NULL)) {
+			errcode(ERRCODE_PROGRAM_LIMIT_EXCEEDED);
+			errmsg_internal("out of memory");
+			errdetail_internal("Cannot enlarge string buffer containing %d bytes by %d more bytes.", str->len, needed);
+			errfinish(__FILE__, __LINE__, __func__);
+		}
+		return;
+	}
+	needed += str->len + 1;
+	if (needed <= str->maxlen) {
+		return;
+	}
+	int newlen = 2 * str->maxlen;
+	while (needed > newlen) {
+		newlen = 2 * newlen;
+	}
+	if (newlen > (int)MaxAllocSize) {
+		newlen = (int)MaxAllocSize;
+	}
+	char* data = (char*)repalloc(str->data, newlen);
+	if (data == NULL) {
+		return;
+	}
+	str->data = data;
+	str->maxlen = newlen;
+}
+
+// appendStringInfoVA formats onto the end of the buffer, returning zero when it fit. Otherwise, this returns the number
+// of bytes that are needed, and the buffer is left unchanged, as the arguments cannot be reused once they're consumed.
+DLLEXPORT int appendStringInfoVA(StringInfo str, const char* fmt, va_list args) {
+	int avail = str->maxlen - str->len;
+	if (avail < 16) {
+		return 32;
+	}
+	size_t nprinted = pvsnprintf(str->data + str->len, (size_t)avail, fmt, args);
+	if (nprinted < (size_t)avail) {
+		str->len += (int)nprinted;
+		return 0;
+	}
+	str->data[str->len] = '\0';
+	return (int)nprinted;
+}
+
+DLLEXPORT void appendStringInfo(StringInfo str, const char* fmt, ...) {
+	// The format may use %m, so errno is restored before each attempt
+	int save_errno = errno;
+	for (;;) {
+		va_list args;
+		errno = save_errno;
+		va_start(args, fmt);
+		int needed = appendStringInfoVA(str, fmt, args);
+		va_end(args);
+		if (needed == 0) {
+			return;
+		}
+		int before = str->maxlen;
+		enlargeStringInfo(str, needed);
+		if (str->maxlen == before) {
+			// The buffer could not grow, and the error that was raised had no call to unwind
+			return;
+		}
+	}
+}
+
+DLLEXPORT void appendStringInfoString(StringInfo str, const char* s) {
+	appendBinaryStringInfo(str, s, (int)strlen(s));
+}
+
+DLLEXPORT void appendStringInfoChar(StringInfo str, char ch) {
+	if (str->len + 1 >= str->maxlen) {
+		enlargeStringInfo(str, 1);
+		if (str->len + 1 >= str->maxlen) {
+			return;
+		}
+	}
+	str->data[str->len] = ch;
+	str->len++;
+	str->data[str->len] = '\0';
+}
+
+DLLEXPORT void appendStringInfoSpaces(StringInfo str, int count) {
+	if (count <= 0) {
+		return;
+	}
+	enlargeStringInfo(str, count);
+	if (str->len + count >= str->maxlen) {
+		return;
+	}
+	memset(&str->data[str->len], ' ', count);
+	str->len += count;
+	str->data[str->len] = '\0';
+}
+
+// appendBinaryStringInfo appends the bytes, which may contain terminators, and keeps the buffer terminated.
+DLLEXPORT void appendBinaryStringInfo(StringInfo str, const void* data, int datalen) {
+	appendBinaryStringInfoNT(str, data, datalen);
+	if (str->len < str->maxlen) {
+		str->data[str->len] = '\0';
+	}
+}
+
+// appendBinaryStringInfoNT appends the bytes without terminating the buffer, for callers that append more right away.
+DLLEXPORT void appendBinaryStringInfoNT(StringInfo str, const void* data, int datalen) {
+	enlargeStringInfo(str, datalen);
+	if (str->len + datalen >= str->maxlen) {
+		return;
+	}
+	memcpy(str->data + str->len, data, datalen);
+	str->len += datalen;
+}
@@ -0,0 +1,39 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extension_cgo
+
+/*
+#include "exports.h"
+*/
+import "C"
+import (
+	"crypto/rand"
+	"unsafe"
+)
+
+// pg_strong_random fills the buffer from the operating system's secure random source, which is what Postgres uses when
+// it's built without OpenSSL.
+//
+//export pg_strong_random
+func pg_strong_random(buf unsafe.Pointer, length C.size_t) C.bool {
+	if length == 0 {
+		return true
+	}
+	_, err := rand.Read(unsafe.Slice((*byte)(buf), int(length)))
+	return err == nil
+}
+
+//export pg_strong_random_init
+func pg_strong_random_init() {}
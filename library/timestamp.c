@@ -0,0 +1,85 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// These are written in Go so that they may use its time package. Like the numeric functions, parse errors are returned
+// to be raised here.
+extern int64_t pgext_current_timestamp(void);
+extern int pgext_timestamp_parse(const char* str, bool with_time_zone, int64_t* result, char** message);
+extern char* pgext_timestamp_format(int64_t ts, bool with_time_zone);
+
+// timestamp_parse parses the text form of a timestamp, raising an error if it's malformed.
+static Datum timestamp_parse(FunctionCallInfo fcinfo, bool with_time_zone) {
+	int64_t result = 0;
+	char* message = NULL;
+	int sqlerrcode = pgext_timestamp_parse((const char*)fcinfo->args[0].value, with_time_zone, &result, &message);
+	if (sqlerrcode != 0) {
+		char copy[1024];
+		snprintf(copy, sizeof(copy), "%s", message != NULL ? message : "invalid timestamp");
+		free(message);
+		pgext_raise_error(ERROR, sqlerrcode, "%s", copy);
+		return (Datum)0;
+	}
+	return (Datum)result;
+}
+
+DLLEXPORT TimestampTz GetCurrentTimestamp(void) {
+	return pgext_current_timestamp();
+}
+
+DLLEXPORT pg_time_t timestamptz_to_time_t(TimestampTz t) {
+	return (pg_time_t)(t / USECS_PER_SEC + (int64_t)(POSTGRES_EPOCH_JDATE - UNIX_EPOCH_JDATE) * SECS_PER_DAY);
+}
+
+DLLEXPORT TimestampTz time_t_to_timestamptz(pg_time_t tm) {
+	return (TimestampTz)((tm - (int64_t)(POSTGRES_EPOCH_JDATE - UNIX_EPOCH_JDATE) * SECS_PER_DAY) * USECS_PER_SEC);
+}
+
+// Postgres returns a static buffer, which is replaced by a copy within the current memory context so that sessions on
+// different threads do not overwrite each other's results.
+DLLEXPORT const char* timestamptz_to_str(TimestampTz t) {
+	return pgext_timestamp_format(t, true);
+}
+
+DLLEXPORT void TimestampDifference(TimestampTz start_time, TimestampTz stop_time, long* secs, int* microsecs) {
+	TimestampTz diff = stop_time - start_time;
+	if (diff <= 0) {
+		*secs = 0;
+		*microsecs = 0;
+	} else {
+		*secs = (long)(diff / USECS_PER_SEC);
+		*microsecs = (int)(diff % USECS_PER_SEC);
+	}
+}
+
+DLLEXPORT bool TimestampDifferenceExceeds(TimestampTz start_time, TimestampTz stop_time, int msec) {
+	return stop_time - start_time >= (TimestampTz)msec * 1000;
+}
+
+DLLEXPORT Datum timestamp_in(FunctionCallInfo fcinfo) {
+	return timestamp_parse(fcinfo, false);
+}
+
+DLLEXPORT Datum timestamp_out(FunctionCallInfo fcinfo) {
+	return (Datum)pgext_timestamp_format((int64_t)fcinfo->args[0].value, false);
+}
+
+DLLEXPORT Datum timestamptz_in(FunctionCallInfo fcinfo) {
+	return timestamp_parse(fcinfo, true);
+}
+
+DLLEXPORT Datum timestamptz_out(FunctionCallInfo fcinfo) {
+	return (Datum)pgext_timestamp_format((int64_t)fcinfo->args[0].value, true);
+}
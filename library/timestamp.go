@@ -0,0 +1,152 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extension_cgo
+
+/*
+#include "exports.h"
+*/
+import "C"
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+const (
+	// postgresEpochMicros is the Unix time of midnight on January 1, 2000 UTC in microseconds, which is the epoch of
+	// Postgres timestamps.
+	postgresEpochMicros = 946684800000000
+	// timestampNoBegin and timestampNoEnd are the timestamps of -infinity and infinity.
+	timestampNoBegin = math.MinInt64
+	timestampNoEnd   = math.MaxInt64
+)
+
+// timestampLayouts are the layouts of the timestamps that are accepted by timestamp_in and timestamptz_in, which cover
+// the ISO 8601 forms that Postgres outputs and commonly receives. Fractional seconds are accepted after the seconds of
+// every layout.
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05Z07",
+	"2006-01-02 15:04:05 Z07:00",
+	"2006-01-02 15:04:05 Z07",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05Z07",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04Z07:00",
+	"2006-01-02 15:04Z07",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+//export pgext_current_timestamp
+func pgext_current_timestamp() C.int64_t {
+	return C.int64_t(time.Now().UnixMicro() - postgresEpochMicros)
+}
+
+//export pgext_timestamp_parse
+func pgext_timestamp_parse(str *C.pgext_const_char, withTimeZone C.bool, result *C.int64_t, message **C.char) C.int {
+	input := C.GoString(str)
+	ts, ok := parseTimestamp(input, bool(withTimeZone))
+	if !ok {
+		typeName := "timestamp without time zone"
+		if withTimeZone {
+			typeName = "timestamp with time zone"
+		}
+		*message = C.CString(`invalid input syntax for type ` + typeName + `: "` + input + `"`)
+		return C.ERRCODE_INVALID_DATETIME_FORMAT
+	}
+	*result = C.int64_t(ts)
+	return 0
+}
+
+//export pgext_timestamp_format
+func pgext_timestamp_format(ts C.int64_t, withTimeZone C.bool) *C.char {
+	str := formatTimestamp(int64(ts), bool(withTimeZone))
+	cStr := (*C.char)(C.palloc(C.size_t(len(str) + 1)))
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(cStr)), len(str)+1), append([]byte(str), 0))
+	return cStr
+}
+
+// parseTimestamp parses the text form of a timestamp, returning microseconds since the Postgres epoch. Timestamps with
+// a time zone are converted to UTC, while timestamps without one keep their wall clock time and ignore any offset.
+func parseTimestamp(input string, withTimeZone bool) (int64, bool) {
+	s := strings.TrimSpace(input)
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	var t time.Time
+	switch strings.ToLower(s) {
+	case "infinity", "+infinity":
+		return timestampNoEnd, true
+	case "-infinity":
+		return timestampNoBegin, true
+	case "epoch":
+		t = time.Unix(0, 0).UTC()
+	case "now":
+		t = now
+	case "today":
+		t = today
+	case "tomorrow":
+		t = today.AddDate(0, 0, 1)
+	case "yesterday":
+		t = today.AddDate(0, 0, -1)
+	default:
+		parsed := false
+		for _, layout := range timestampLayouts {
+			var err error
+			if t, err = time.Parse(layout, s); err == nil {
+				parsed = true
+				break
+			}
+		}
+		if !parsed {
+			return 0, false
+		}
+		if !withTimeZone {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+		}
+	}
+	return t.Round(time.Microsecond).UnixMicro() - postgresEpochMicros, true
+}
+
+// formatTimestamp returns the text form of the timestamp in the ISO date style, which is the same as timestamp_out and
+// timestamptz_out return. Timestamps with a time zone are written in UTC.
+func formatTimestamp(ts int64, withTimeZone bool) string {
+	switch ts {
+	case timestampNoBegin:
+		return "-infinity"
+	case timestampNoEnd:
+		return "infinity"
+	}
+	t := time.UnixMicro(ts + postgresEpochMicros).UTC()
+	var sb strings.Builder
+	year, bc := t.Year(), false
+	if year <= 0 {
+		year, bc = 1-year, true
+	}
+	fmt.Fprintf(&sb, "%04d-%02d-%02d %02d:%02d:%02d", year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second())
+	if micros := t.Nanosecond() / 1000; micros != 0 {
+		sb.WriteString(strings.TrimRight(fmt.Sprintf(".%06d", micros), "0"))
+	}
+	if withTimeZone {
+		sb.WriteString("+00")
+	}
+	if bc {
+		sb.WriteString(" BC")
+	}
+	return sb.String()
+}
@@ -0,0 +1,106 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#if !defined(_WIN32) && !defined(_WIN64)
+// dladdr is an extension of glibc, which is only declared when requested
+#ifndef _GNU_SOURCE
+#define _GNU_SOURCE
+#endif
+#include <dlfcn.h>
+#define PGEXT_HAS_DLADDR
+#endif
+
+#include "exports.h"
+
+// tracer is the host's tracer, which is kept after tracing is disabled so that the spans that were started beforehand
+// may still end. Calls are only traced while tracing is set.
+static PgExtTracer* tracer;
+static volatile bool tracing;
+
+// pgext_set_tracer sets the host's tracer. Setting NULL stops tracing calls.
+DLLEXPORT uintptr_t pgext_set_tracer(PgExtTracer* new_tracer) {
+	if (new_tracer != NULL) {
+		tracer = new_tracer;
+	}
+	tracing = new_tracer != NULL;
+	return 0;
+}
+
+// describe_function finds the file of the library that contains the function, along with the function's name, either
+// of which is NULL when it cannot be found.
+static void describe_function(void* fn, const char** library, const char** function) {
+	*library = NULL;
+	*function = NULL;
+#ifdef PGEXT_HAS_DLADDR
+	Dl_info info;
+	if (dladdr(fn, &info) != 0) {
+		*library = info.dli_fname;
+		// The nearest symbol is only the function's own when it begins at the function's address
+		if (info.dli_saddr == fn) {
+			*function = info.dli_sname;
+		}
+	}
+#endif
+}
+
+// pgext_trace_start starts a span for a call to the function with the given number of arguments, as a child of the
+// span of the call that the session is running. Returns zero when calls are not traced.
+uintptr_t pgext_trace_start(void* fn, int nargs) {
+	if (!tracing) {
+		return 0;
+	}
+	PgExtBackendState* state = pgext_backend_state();
+	const char* library;
+	const char* function;
+	describe_function(fn, &library, &function);
+	uintptr_t span = tracer->start(state->trace_span, fn, library, function, nargs);
+	if (span != 0) {
+		state->trace_span = span;
+	}
+	return span;
+}
+
+// pgext_trace_end ends a span that was returned by pgext_trace_start, with the error that the call raised, or NULL when
+// the call returned normally. The span's parent becomes the span of the call that the session is running again.
+void pgext_trace_end(uintptr_t span, PgExtErrorData* edata) {
+	if (span == 0) {
+		return;
+	}
+	pgext_backend_state()->trace_span = tracer->end(span, edata);
+}
+
+// pgext_trace_invoke calls the function with the call info within a span, which is how calls that functions make to
+// one another through the fmgr are traced, such as those through DirectFunctionCall. An error that the function raises
+// ends the span before the error continues to unwind.
+Datum pgext_trace_invoke(PGFunction fn, FunctionCallInfo fcinfo) {
+	uintptr_t span = pgext_trace_start((void*)fn, fcinfo->nargs);
+	if (span == 0) {
+		return (*fn)(fcinfo);
+	}
+	PgExtBackendState* state = pgext_backend_state();
+	sigjmp_buf* saved_exception_stack = PG_exception_stack;
+	sigjmp_buf local_sigjmp_buf;
+	if (sigsetjmp(local_sigjmp_buf, 0) != 0) {
+		PG_exception_stack = saved_exception_stack;
+		pgext_trace_end(span, &state->caught);
+		pg_re_throw();
+		// Without a call to unwind, the error is kept until the host takes it
+		return 0;
+	}
+	PG_exception_stack = &local_sigjmp_buf;
+	Datum result = (*fn)(fcinfo);
+	PG_exception_stack = saved_exception_stack;
+	pgext_trace_end(span, NULL);
+	return result;
+}
@@ -0,0 +1,86 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// pgext_trigger_begin returns the trigger data for calling a trigger function on a relation with the given number of
+// attributes, which the host fills in. Everything is allocated within the current memory context, as the tuples that a
+// trigger function returns may be those of the trigger data. Returns NULL when out of memory.
+DLLEXPORT TriggerData* pgext_trigger_begin(uintptr_t event, uintptr_t natts) {
+	int flags = MCXT_ALLOC_NO_OOM | MCXT_ALLOC_ZERO;
+	TriggerData* trigdata = (TriggerData*)palloc_extended(sizeof(TriggerData), flags);
+	Relation relation = (Relation)palloc_extended(sizeof(RelationData), flags);
+	Form_pg_class form = (Form_pg_class)palloc_extended(sizeof(FormData_pg_class), flags);
+	TupleDesc desc = (TupleDesc)palloc_extended(TupleDescSize(natts), flags);
+	Trigger* trigger = (Trigger*)palloc_extended(sizeof(Trigger), flags);
+	if (trigdata == NULL || relation == NULL || form == NULL || desc == NULL || trigger == NULL) {
+		pfree(trigdata);
+		pfree(relation);
+		pfree(form);
+		pfree(desc);
+		pfree(trigger);
+		return NULL;
+	}
+	desc->natts = (int)natts;
+	desc->tdtypeid = RECORDOID;
+	desc->tdtypmod = -1;
+	desc->tdrefcount = -1;
+	form->relkind = RELKIND_RELATION;
+	form->relpersistence = RELPERSISTENCE_PERMANENT;
+	form->relnatts = (int16_t)natts;
+	form->relhastriggers = true;
+	form->relispopulated = true;
+	relation->rd_refcnt = 1;
+	relation->rd_isvalid = true;
+	relation->rd_rel = form;
+	relation->rd_att = desc;
+	trigger->tgenabled = 'O';
+	trigdata->type = T_TriggerData;
+	trigdata->tg_event = (TriggerEvent)event;
+	trigdata->tg_relation = relation;
+	trigdata->tg_trigger = trigger;
+	return trigdata;
+}
+
+// PgExtTriggerTuple holds the arguments of form_trigger_tuple.
+typedef struct PgExtTriggerTuple {
+	TriggerData* trigdata;
+	Datum*       values;
+	bool*        isnull;
+} PgExtTriggerTuple;
+
+static Datum form_trigger_tuple(void* arg) {
+	PgExtTriggerTuple* args = (PgExtTriggerTuple*)arg;
+	HeapTuple tuple = heap_form_tuple(args->trigdata->tg_relation->rd_att, args->values, args->isnull);
+	tuple->t_tableOid = args->trigdata->tg_relation->rd_id;
+	return (Datum)tuple;
+}
+
+// pgext_trigger_tuple forms a tuple of the relation from the given values, which becomes the trigger data's new tuple
+// when newtuple is nonzero, and its trigger tuple otherwise. Returns the error that was raised while forming the tuple,
+// or NULL.
+DLLEXPORT PgExtErrorData* pgext_trigger_tuple(TriggerData* trigdata, uintptr_t newtuple, Datum* values, bool* isnull) {
+	PgExtTriggerTuple args = {trigdata, values, isnull};
+	Datum tuple;
+	PgExtErrorData* edata = pgext_catch_errors(form_trigger_tuple, &args, &tuple);
+	if (edata != NULL) {
+		return edata;
+	}
+	if (newtuple != 0) {
+		trigdata->tg_newtuple = (HeapTuple)tuple;
+	} else {
+		trigdata->tg_trigtuple = (HeapTuple)tuple;
+	}
+	return NULL;
+}
@@ -0,0 +1,175 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+extern bool pgext_unicode_isclass(const char* ptr, int len, int char_class);
+
+// share_path is the share directory of the Postgres installation, which is empty until the host sets it.
+static char share_path[MAXPGPATH];
+
+// pgext_set_share_path sets the share directory, which holds the files that text search dictionaries read. Returns 1 if
+// the directory was set, or 0 if the path is too long.
+DLLEXPORT uintptr_t pgext_set_share_path(const char* path) {
+	if (strlen(path) >= MAXPGPATH) {
+		return 0;
+	}
+	snprintf(share_path, sizeof(share_path), "%s", path);
+	return 1;
+}
+
+DLLEXPORT void get_share_path(const char* my_exec_path, char* ret_path) {
+	snprintf(ret_path, MAXPGPATH, "%s", share_path);
+}
+
+// char_is_class returns whether the character at ptr is within the class. Single-byte characters, along with every
+// character when the ctype is C, are classified the same as the C locale.
+static bool char_is_class(const char* ptr, int char_class) {
+	int clen = pg_mblen(ptr);
+	if (clen > 1 && !lc_ctype_is_c(DEFAULT_COLLATION_OID)) {
+		return pgext_unicode_isclass(ptr, clen, char_class);
+	}
+	unsigned char ch = TOUCHAR(ptr);
+	bool is_digit = ch >= '0' && ch <= '9';
+	bool is_alpha = (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z');
+	switch (char_class) {
+	case PGEXT_CHAR_DIGIT:
+		return is_digit;
+	case PGEXT_CHAR_SPACE:
+		return ch == ' ' || (ch >= '\t' && ch <= '\r');
+	case PGEXT_CHAR_ALPHA:
+		return is_alpha;
+	case PGEXT_CHAR_ALNUM:
+		return is_alpha || is_digit;
+	default:
+		return ch >= 0x20 && ch < 0x7f;
+	}
+}
+
+DLLEXPORT int t_isdigit(const char* ptr) {
+	return char_is_class(ptr, PGEXT_CHAR_DIGIT);
+}
+
+DLLEXPORT int t_isspace(const char* ptr) {
+	return char_is_class(ptr, PGEXT_CHAR_SPACE);
+}
+
+DLLEXPORT int t_isalpha(const char* ptr) {
+	return char_is_class(ptr, PGEXT_CHAR_ALPHA);
+}
+
+DLLEXPORT int t_isalnum(const char* ptr) {
+	return char_is_class(ptr, PGEXT_CHAR_ALNUM);
+}
+
+DLLEXPORT int t_isprint(const char* ptr) {
+	return char_is_class(ptr, PGEXT_CHAR_PRINT);
+}
+
+DLLEXPORT char* lowerstr(const char* str) {
+	return lowerstr_with_len(str, (int)strlen(str));
+}
+
+// lowerstr_with_len lowers the string under the default collation, which is how Postgres lowers the words of text
+// search documents.
+DLLEXPORT char* lowerstr_with_len(const char* str, int len) {
+	if (len == 0) {
+		return pstrdup("");
+	}
+	return str_tolower(str, (size_t)len, DEFAULT_COLLATION_OID);
+}
+
+DLLEXPORT char* get_tsearch_config_filename(const char* basename, const char* extension) {
+	// The name is limited to the same characters as Postgres, so that it cannot refer to files outside of the directory
+	if (strspn(basename, "abcdefghijklmnopqrstuvwxyz0123456789_") != strlen(basename)) {
+		pgext_raise_error(ERROR, ERRCODE_INVALID_PARAMETER_VALUE, "invalid text search configuration file name \"%s\"",
+			basename);
+		return NULL;
+	}
+	if (share_path[0] == '\0') {
+		pgext_raise_error(ERROR, ERRCODE_FEATURE_NOT_SUPPORTED,
+			"cannot find text search configuration file \"%s.%s\", as the share directory has not been set", basename,
+			extension);
+		return NULL;
+	}
+	return psprintf("%s/tsearch_data/%s.%s", share_path, basename, extension);
+}
+
+// tsearch_readline_callback adds the line that is being read to the context of errors.
+static void tsearch_readline_callback(void* arg) {
+	tsearch_readline_state* stp = (tsearch_readline_state*)arg;
+	if (stp->curline != NULL) {
+		errcontext_msg("line %d of configuration file \"%s\": \"%s\"", stp->lineno, stp->filename, stp->curline);
+	} else {
+		errcontext_msg("line %d of configuration file \"%s\"", stp->lineno, stp->filename);
+	}
+}
+
+// read_line replaces the contents of the buffer with the next line of the file, including its newline. Returns false
+// once the file has no more lines.
+static bool read_line(FILE* fp, StringInfo buf) {
+	char chunk[256];
+	resetStringInfo(buf);
+	while (fgets(chunk, sizeof(chunk), fp) != NULL) {
+		appendStringInfoString(buf, chunk);
+		if (buf->len > 0 && buf->data[buf->len - 1] == '\n') {
+			return true;
+		}
+	}
+	return buf->len > 0;
+}
+
+// tsearch_readline_begin opens the file for reading. Unlike Postgres, the shim does not track the files that are open,
+// so the file is leaked if an error unwinds past the caller before tsearch_readline_end.
+DLLEXPORT bool tsearch_readline_begin(tsearch_readline_state* stp, const char* filename) {
+	if ((stp->fp = fopen(filename, "r")) == NULL) {
+		return false;
+	}
+	stp->filename = filename;
+	stp->lineno = 0;
+	initStringInfo(&stp->buf);
+	stp->curline = NULL;
+	stp->cb.callback = tsearch_readline_callback;
+	stp->cb.arg = (void*)stp;
+	stp->cb.previous = error_context_stack;
+	error_context_stack = &stp->cb;
+	return true;
+}
+
+// tsearch_readline returns the next line of the file converted to the database encoding, which the caller may free.
+// Configuration files are always UTF8, regardless of the database encoding.
+DLLEXPORT char* tsearch_readline(tsearch_readline_state* stp) {
+	stp->lineno++;
+	stp->curline = NULL;
+	if (!read_line(stp->fp, &stp->buf)) {
+		return NULL;
+	}
+	stp->curline = stp->buf.data;
+	pg_verify_mbstr(PG_UTF8, stp->buf.data, stp->buf.len, false);
+	char* recoded = pg_any_to_server(stp->buf.data, stp->buf.len, PG_UTF8);
+	if (recoded == stp->buf.data) {
+		recoded = pstrdup(recoded);
+	}
+	return recoded;
+}
+
+DLLEXPORT void tsearch_readline_end(tsearch_readline_state* stp) {
+	if (stp->buf.data != NULL) {
+		pfree(stp->buf.data);
+		stp->buf.data = NULL;
+	}
+	stp->curline = NULL;
+	fclose(stp->fp);
+	error_context_stack = stp->cb.previous;
+}
@@ -0,0 +1,114 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// These are implemented in record_types.go, which holds the descriptors of every blessed record type.
+extern int32_t pgext_record_register(TupleDesc tupdesc);
+extern TupleDesc pgext_record_lookup(int32_t typmod);
+
+DLLEXPORT TupleDesc CreateTemplateTupleDesc(int natts) {
+	TupleDesc desc = (TupleDesc)palloc0(TupleDescSize(natts));
+	desc->natts = natts;
+	desc->tdtypeid = RECORDOID;
+	desc->tdtypmod = -1;
+	desc->tdrefcount = -1;
+	return desc;
+}
+
+DLLEXPORT TupleDesc CreateTupleDescCopy(TupleDesc tupdesc) {
+	TupleDesc desc = CreateTemplateTupleDesc(tupdesc->natts);
+	memcpy(desc->attrs, tupdesc->attrs, tupdesc->natts * sizeof(FormData_pg_attribute));
+	// Postgres does not copy constraints or defaults, so we clear the flags that refer to them
+	for (int i = 0; i < desc->natts; i++) {
+		Form_pg_attribute att = TupleDescAttr(desc, i);
+		att->attnotnull = false;
+		att->atthasdef = false;
+		att->atthasmissing = false;
+		att->attidentity = '\0';
+		att->attgenerated = '\0';
+	}
+	desc->tdtypeid = tupdesc->tdtypeid;
+	desc->tdtypmod = tupdesc->tdtypmod;
+	return desc;
+}
+
+DLLEXPORT void FreeTupleDesc(TupleDesc tupdesc) {
+	pfree(tupdesc);
+}
+
+DLLEXPORT void TupleDescInitEntry(TupleDesc desc, int16_t attributeNumber, const char* attributeName, Oid oidtypeid,
+	int32_t typmod, int attdim) {
+	PgExtTypeInfo type;
+	if (!pgext_lookup_type(oidtypeid, &type)) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "cache lookup failed for type %u", oidtypeid);
+		return;
+	}
+	Form_pg_attribute att = TupleDescAttr(desc, attributeNumber - 1);
+	memset(att, 0, sizeof(FormData_pg_attribute));
+	if (attributeName != NULL) {
+		strncpy(att->attname.data, attributeName, NAMEDATALEN - 1);
+	}
+	att->attstattarget = -1;
+	att->attcacheoff = -1;
+	att->atttypmod = typmod;
+	att->attnum = attributeNumber;
+	att->attndims = attdim;
+	att->attislocal = true;
+	att->atttypid = oidtypeid;
+	att->attlen = type.typlen;
+	att->attbyval = type.typbyval;
+	att->attalign = type.typalign;
+	att->attstorage = type.typstorage;
+	att->attcollation = type.typcollation;
+}
+
+// BlessTupleDesc registers the descriptor of an anonymous record type, so that Datums of the record type may be decoded
+// by looking up their typmod. Descriptors with the same attributes share a typmod.
+DLLEXPORT TupleDesc BlessTupleDesc(TupleDesc tupdesc) {
+	if (tupdesc->tdtypeid == RECORDOID && tupdesc->tdtypmod < 0) {
+		// The registered descriptor is never freed, as Datums of the record type may outlive the given descriptor
+		TupleDesc registered = (TupleDesc)malloc(TupleDescSize(tupdesc->natts));
+		if (registered == NULL) {
+			pgext_raise_error(ERROR, ERRCODE_OUT_OF_MEMORY, "out of memory");
+		}
+		memcpy(registered, tupdesc, TupleDescSize(tupdesc->natts));
+		registered->tdrefcount = -1;
+		registered->constr = NULL;
+		tupdesc->tdtypmod = pgext_record_register(registered);
+	}
+	return tupdesc;
+}
+
+DLLEXPORT TupleDesc lookup_rowtype_tupdesc(Oid type_id, int32_t typmod) {
+	TupleDesc tupdesc = type_id == RECORDOID && typmod >= 0 ? pgext_record_lookup(typmod) : NULL;
+	if (tupdesc == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_FEATURE_NOT_SUPPORTED, "type %u with typmod %d is not a registered record type",
+			type_id, typmod);
+	}
+	return tupdesc;
+}
+
+DLLEXPORT TupleDesc lookup_rowtype_tupdesc_copy(Oid type_id, int32_t typmod) {
+	return CreateTupleDescCopy(lookup_rowtype_tupdesc(type_id, typmod));
+}
+
+// DecrTupleDescRefCount is called through ReleaseTupleDesc for reference-counted descriptors. Registered descriptors
+// are never freed, so they are not reference-counted.
+DLLEXPORT void DecrTupleDescRefCount(TupleDesc tupdesc) {}
+
+// pgext_record_tupdesc returns the descriptor of the record type with the given typmod, or NULL if it wasn't blessed.
+DLLEXPORT TupleDesc pgext_record_tupdesc(uintptr_t typmod) {
+	return pgext_record_lookup((int32_t)typmod);
+}
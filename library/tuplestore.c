@@ -0,0 +1,205 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// These are implemented in tuplestore.go, which holds the rows of every tuplestore.
+extern void pgext_tuplestore_append(Tuplestorestate* state, Datum* values, bool* isnull, int natts);
+extern bool pgext_tuplestore_fetch(Tuplestorestate* state, int64_t index, Datum* values, bool* isnull, int natts);
+extern int64_t pgext_tuplestore_count(Tuplestorestate* state);
+extern void pgext_tuplestore_clear(Tuplestorestate* state);
+
+// release_tuplestore releases the rows of a tuplestore when its memory context is reset, as the copied values of its
+// rows are freed along with the context.
+static void release_tuplestore(void* arg) {
+	pgext_tuplestore_clear((Tuplestorestate*)arg);
+}
+
+// copy_datum copies a by-reference value into the given memory context, so that it outlives the caller's memory.
+static Datum copy_datum(MemoryContext context, Form_pg_attribute att, Datum value) {
+	if (att->attbyval) {
+		return value;
+	}
+	size_t size;
+	if (att->attlen > 0) {
+		size = (size_t)att->attlen;
+	} else if (att->attlen == -1) {
+		if (VARATT_IS_1B_E(value)) {
+			pgext_raise_error(ERROR, ERRCODE_FEATURE_NOT_SUPPORTED, "external TOAST values cannot be stored in a tuplestore");
+		}
+		size = VARSIZE_ANY(value);
+	} else {
+		size = strlen((const char*)value) + 1;
+	}
+	void* copy = MemoryContextAlloc(context, size);
+	memcpy(copy, (const void*)value, size);
+	return (Datum)copy;
+}
+
+DLLEXPORT Tuplestorestate* tuplestore_begin_heap(bool randomAccess, bool interXact, int maxKBytes) {
+	Tuplestorestate* state = (Tuplestorestate*)palloc0(sizeof(Tuplestorestate));
+	state->context = GetMemoryChunkContext(state);
+	state->release_cb.func = release_tuplestore;
+	state->release_cb.arg = state;
+	MemoryContextRegisterResetCallback(state->context, &state->release_cb);
+	return state;
+}
+
+DLLEXPORT void tuplestore_putvalues(Tuplestorestate* state, TupleDesc tdesc, Datum* values, bool* isnull) {
+	Datum* copies = (Datum*)palloc0((tdesc->natts + 1) * sizeof(Datum));
+	for (int i = 0; i < tdesc->natts; i++) {
+		if (!isnull[i]) {
+			copies[i] = copy_datum(state->context, TupleDescAttr(tdesc, i), values[i]);
+		}
+	}
+	state->natts = tdesc->natts;
+	pgext_tuplestore_append(state, copies, isnull, tdesc->natts);
+	pfree(copies);
+}
+
+DLLEXPORT int64_t tuplestore_tuple_count(Tuplestorestate* state) {
+	return pgext_tuplestore_count(state);
+}
+
+DLLEXPORT bool tuplestore_ateof(Tuplestorestate* state) {
+	return state->eof_reached;
+}
+
+DLLEXPORT void tuplestore_rescan(Tuplestorestate* state) {
+	state->read_pos = 0;
+	state->eof_reached = false;
+}
+
+DLLEXPORT void tuplestore_clear(Tuplestorestate* state) {
+	pgext_tuplestore_clear(state);
+	tuplestore_rescan(state);
+}
+
+// tuplestore_end releases the rows of the tuplestore. The tuplestore itself is freed along with its memory context, as
+// its reset callback is still registered.
+DLLEXPORT void tuplestore_end(Tuplestorestate* state) {
+	tuplestore_clear(state);
+}
+
+// tuplestore_gettupleslot reads the next row in the given direction into the slot. As in Postgres, reading backward
+// returns the row before the one that was last returned, or the last row once the end has been reached. Values always
+// point into the tuplestore's memory, so copy has no effect.
+DLLEXPORT bool tuplestore_gettupleslot(Tuplestorestate* state, bool forward, bool copy, TupleTableSlot* slot) {
+	int64_t count = pgext_tuplestore_count(state);
+	int64_t index;
+	if (forward) {
+		if (state->read_pos >= count) {
+			state->eof_reached = true;
+			slot->tts_ops->clear(slot);
+			return false;
+		}
+		index = state->read_pos++;
+	} else if (state->eof_reached && count > 0) {
+		state->eof_reached = false;
+		state->read_pos = count;
+		index = count - 1;
+	} else {
+		if (state->read_pos < 2) {
+			state->read_pos = 0;
+			slot->tts_ops->clear(slot);
+			return false;
+		}
+		index = state->read_pos - 2;
+		state->read_pos--;
+	}
+	int natts = slot->tts_tupleDescriptor->natts;
+	for (int i = 0; i < natts; i++) {
+		slot->tts_values[i] = 0;
+		slot->tts_isnull[i] = true;
+	}
+	pgext_tuplestore_fetch(state, index, slot->tts_values, slot->tts_isnull, natts);
+	slot->tts_nvalid = (int16_t)natts;
+	slot->tts_flags &= ~TTS_FLAG_EMPTY;
+	return true;
+}
+
+// pgext_tuplestore_row copies a row of the tuplestore into the given arrays, which must hold as many values as the
+// tuplestore's rows. Returns 0 if the row does not exist.
+DLLEXPORT uintptr_t pgext_tuplestore_row(Tuplestorestate* state, uintptr_t index, Datum* values, bool* isnull) {
+	return pgext_tuplestore_fetch(state, (int64_t)index, values, isnull, state->natts) ? 1 : 0;
+}
+
+static void tts_virtual_noop(TupleTableSlot* slot) {}
+
+static void tts_virtual_clear(TupleTableSlot* slot) {
+	slot->tts_nvalid = 0;
+	slot->tts_flags |= TTS_FLAG_EMPTY;
+}
+
+static void tts_virtual_getsomeattrs(TupleTableSlot* slot, int natts) {
+	pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "getsomeattrs is not required for virtual tuple table slots");
+}
+
+static Datum tts_virtual_getsysattr(TupleTableSlot* slot, int attnum, bool* isnull) {
+	pgext_raise_error(ERROR, ERRCODE_FEATURE_NOT_SUPPORTED, "virtual tuple table slot does not have system attributes");
+	return 0;
+}
+
+static void tts_virtual_copyslot(TupleTableSlot* dstslot, TupleTableSlot* srcslot) {
+	int natts = srcslot->tts_tupleDescriptor->natts;
+	memcpy(dstslot->tts_values, srcslot->tts_values, natts * sizeof(Datum));
+	memcpy(dstslot->tts_isnull, srcslot->tts_isnull, natts * sizeof(bool));
+	dstslot->tts_nvalid = (int16_t)natts;
+	dstslot->tts_flags &= ~TTS_FLAG_EMPTY;
+}
+
+static void* tts_virtual_tuple(TupleTableSlot* slot) {
+	pgext_raise_error(ERROR, ERRCODE_FEATURE_NOT_SUPPORTED, "tuple table slots cannot be converted to tuples");
+	return NULL;
+}
+
+// The shim only creates virtual slots, so every slot type uses the same operations.
+#define PGEXT_VIRTUAL_SLOT_OPS { \
+	sizeof(TupleTableSlot), tts_virtual_noop, tts_virtual_noop, tts_virtual_clear, tts_virtual_getsomeattrs, \
+	tts_virtual_getsysattr, tts_virtual_noop, tts_virtual_copyslot, tts_virtual_tuple, tts_virtual_tuple, \
+	tts_virtual_tuple, tts_virtual_tuple }
+
+DLLEXPORT const TupleTableSlotOps TTSOpsVirtual = PGEXT_VIRTUAL_SLOT_OPS;
+DLLEXPORT const TupleTableSlotOps TTSOpsHeapTuple = PGEXT_VIRTUAL_SLOT_OPS;
+DLLEXPORT const TupleTableSlotOps TTSOpsMinimalTuple = PGEXT_VIRTUAL_SLOT_OPS;
+DLLEXPORT const TupleTableSlotOps TTSOpsBufferHeapTuple = PGEXT_VIRTUAL_SLOT_OPS;
+
+DLLEXPORT TupleTableSlot* MakeTupleTableSlot(TupleDesc tupleDesc, const TupleTableSlotOps* tts_ops) {
+	int natts = tupleDesc != NULL ? tupleDesc->natts : 0;
+	TupleTableSlot* slot = (TupleTableSlot*)palloc0(sizeof(TupleTableSlot));
+	slot->type = T_TupleTableSlot;
+	slot->tts_flags = TTS_FLAG_EMPTY;
+	slot->tts_ops = tts_ops;
+	slot->tts_tupleDescriptor = tupleDesc;
+	slot->tts_mcxt = CurrentMemoryContext;
+	slot->tts_values = (Datum*)palloc0((natts + 1) * sizeof(Datum));
+	slot->tts_isnull = (bool*)palloc0((natts + 1) * sizeof(bool));
+	return slot;
+}
+
+DLLEXPORT TupleTableSlot* MakeSingleTupleSlot(TupleDesc tupdesc, const TupleTableSlotOps* tts_ops) {
+	return MakeTupleTableSlot(tupdesc, tts_ops);
+}
+
+DLLEXPORT void ExecDropSingleTupleTableSlot(TupleTableSlot* slot) {
+	pfree(slot->tts_values);
+	pfree(slot->tts_isnull);
+	pfree(slot);
+}
+
+DLLEXPORT TupleTableSlot* ExecStoreVirtualTuple(TupleTableSlot* slot) {
+	slot->tts_nvalid = (int16_t)slot->tts_tupleDescriptor->natts;
+	slot->tts_flags &= ~TTS_FLAG_EMPTY;
+	return slot;
+}
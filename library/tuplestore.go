@@ -0,0 +1,73 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extension_cgo
+
+/*
+#include "exports.h"
+*/
+import "C"
+import (
+	"slices"
+	"sync"
+	"unsafe"
+)
+
+// tuplestoreRows contains the rows of every tuplestore, keyed by the address of the tuplestore. A tuplestore belongs to
+// a single session, so its rows are not guarded beyond the map itself.
+var tuplestoreRows sync.Map
+
+// tuplestoreData contains the rows of a tuplestore. By-reference values point to copies that are owned by the
+// tuplestore's memory context.
+type tuplestoreData struct {
+	values [][]C.Datum
+	isnull [][]C.bool
+}
+
+//export pgext_tuplestore_append
+func pgext_tuplestore_append(state *C.Tuplestorestate, values *C.Datum, isnull *C.bool, natts C.int) {
+	data, _ := tuplestoreRows.LoadOrStore(uintptr(unsafe.Pointer(state)), &tuplestoreData{})
+	rows := data.(*tuplestoreData)
+	rows.values = append(rows.values, slices.Clone(unsafe.Slice(values, int(natts))))
+	rows.isnull = append(rows.isnull, slices.Clone(unsafe.Slice(isnull, int(natts))))
+}
+
+//export pgext_tuplestore_fetch
+func pgext_tuplestore_fetch(state *C.Tuplestorestate, index C.int64_t, values *C.Datum, isnull *C.bool, natts C.int) C.bool {
+	data, ok := tuplestoreRows.Load(uintptr(unsafe.Pointer(state)))
+	if !ok {
+		return false
+	}
+	rows := data.(*tuplestoreData)
+	if index < 0 || int(index) >= len(rows.values) {
+		return false
+	}
+	copy(unsafe.Slice(values, int(natts)), rows.values[index])
+	copy(unsafe.Slice(isnull, int(natts)), rows.isnull[index])
+	return true
+}
+
+//export pgext_tuplestore_count
+func pgext_tuplestore_count(state *C.Tuplestorestate) C.int64_t {
+	data, ok := tuplestoreRows.Load(uintptr(unsafe.Pointer(state)))
+	if !ok {
+		return 0
+	}
+	return C.int64_t(len(data.(*tuplestoreData).values))
+}
+
+//export pgext_tuplestore_clear
+func pgext_tuplestore_clear(state *C.Tuplestorestate) {
+	tuplestoreRows.Delete(uintptr(unsafe.Pointer(state)))
+}
@@ -0,0 +1,103 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// uuid_hex_digit returns the value of the hexadecimal digit, or -1 if the character is not one.
+static int uuid_hex_digit(char c) {
+	if (c >= '0' && c <= '9') {
+		return c - '0';
+	} else if (c >= 'a' && c <= 'f') {
+		return c - 'a' + 10;
+	} else if (c >= 'A' && c <= 'F') {
+		return c - 'A' + 10;
+	}
+	return -1;
+}
+
+// uuid_parse parses the text form of a uuid, returning false if it's malformed. The digits may be wrapped in braces,
+// and a hyphen may follow any group of four digits, which are the same forms that Postgres accepts.
+static bool uuid_parse(const char* str, pg_uuid_t* uuid) {
+	const char* src = str;
+	bool braces = false;
+	if (*src == '{') {
+		braces = true;
+		src++;
+	}
+	for (int i = 0; i < UUID_LEN; i++) {
+		int hi = uuid_hex_digit(src[0]);
+		int lo = hi < 0 ? -1 : uuid_hex_digit(src[1]);
+		if (lo < 0) {
+			return false;
+		}
+		uuid->data[i] = (unsigned char)((hi << 4) | lo);
+		src += 2;
+		if (i % 2 == 1 && i < UUID_LEN - 1 && *src == '-') {
+			src++;
+		}
+	}
+	if (braces) {
+		if (*src != '}') {
+			return false;
+		}
+		src++;
+	}
+	return *src == '\0';
+}
+
+DLLEXPORT Datum uuid_in(FunctionCallInfo fcinfo) {
+	const char* str = (const char*)fcinfo->args[0].value;
+	pg_uuid_t* uuid = (pg_uuid_t*)palloc(sizeof(pg_uuid_t));
+	if (!uuid_parse(str, uuid)) {
+		pfree(uuid);
+		pgext_raise_error(ERROR, ERRCODE_INVALID_TEXT_REPRESENTATION, "invalid input syntax for type %s: \"%s\"", "uuid",
+			str);
+		return (Datum)0;
+	}
+	return UUIDPGetDatum(uuid);
+}
+
+DLLEXPORT Datum uuid_out(FunctionCallInfo fcinfo) {
+	static const char hex_chars[] = "0123456789abcdef";
+	pg_uuid_t* uuid = DatumGetUUIDP(fcinfo->args[0].value);
+	// 32 digits, 4 hyphens, and the terminator
+	char* result = (char*)palloc(2 * UUID_LEN + 5);
+	char* dst = result;
+	for (int i = 0; i < UUID_LEN; i++) {
+		// Hyphens separate the groups of 8, 4, 4, 4, and 12 digits
+		if (i == 4 || i == 6 || i == 8 || i == 10) {
+			*dst++ = '-';
+		}
+		*dst++ = hex_chars[uuid->data[i] >> 4];
+		*dst++ = hex_chars[uuid->data[i] & 0x0F];
+	}
+	*dst = '\0';
+	return (Datum)result;
+}
+
+DLLEXPORT Datum gen_random_uuid(FunctionCallInfo fcinfo) {
+	pg_uuid_t* uuid = (pg_uuid_t*)palloc(sizeof(pg_uuid_t));
+	if (uuid == NULL) {
+		return (Datum)0;
+	}
+	if (!pg_strong_random(uuid->data, UUID_LEN)) {
+		pfree(uuid);
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "could not generate random values");
+		return (Datum)0;
+	}
+	// Version 4, with the variant from RFC 4122
+	uuid->data[6] = (uuid->data[6] & 0x0f) | 0x40;
+	uuid->data[8] = (uuid->data[8] & 0x3f) | 0x80;
+	return UUIDPGetDatum(uuid);
+}
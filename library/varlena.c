@@ -0,0 +1,401 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// toast_fetcher is the host's fetcher for values within TOAST tables, or NULL if the host does not store any.
+static PgExtToastFetcher* toast_fetcher;
+
+// pgext_set_toast_fetcher sets the host's fetcher for values within TOAST tables.
+DLLEXPORT uintptr_t pgext_set_toast_fetcher(PgExtToastFetcher* fetcher) {
+	toast_fetcher = fetcher;
+	return 0;
+}
+
+// toast_fetch_datum returns the value that the TOAST pointer refers to, fetched through the host. The value keeps its
+// compression, so it's returned as a compressed varlena if it was compressed.
+static varlena* toast_fetch_datum(varlena* attr) {
+	varatt_external toast_pointer;
+	memcpy(&toast_pointer, VARDATA_EXTERNAL(attr), sizeof(toast_pointer));
+	if (toast_fetcher == NULL || toast_fetcher->fetch == NULL) {
+		pgext_raise_error(ERROR, ERRCODE_FEATURE_NOT_SUPPORTED,
+			"cannot fetch TOAST value %u in relation %u, as the host does not store TOAST values",
+			toast_pointer.va_valueid, toast_pointer.va_toastrelid);
+		return NULL;
+	}
+	int32_t attrsize = (int32_t)VARATT_EXTERNAL_GET_EXTSIZE(toast_pointer);
+	varlena* result = (varlena*)palloc(attrsize + VARHDRSZ);
+	if (result == NULL) {
+		return NULL;
+	}
+	if (VARATT_EXTERNAL_IS_COMPRESSED(toast_pointer)) {
+		SET_VARSIZE_COMPRESSED(result, attrsize + VARHDRSZ);
+	} else {
+		SET_VARSIZE(result, attrsize + VARHDRSZ);
+	}
+	int sqlerrcode = 0;
+	char* message = NULL;
+	toast_fetcher->fetch(toast_pointer.va_toastrelid, toast_pointer.va_valueid, ((char*)result) + VARHDRSZ, attrsize,
+		&sqlerrcode, &message);
+	if (message != NULL) {
+		pfree(result);
+		pgext_raise_host_error(sqlerrcode, message);
+		return NULL;
+	}
+	return result;
+}
+
+DLLEXPORT int32_t pglz_decompress(const char* source, int32_t slen, char* dest, int32_t rawsize, bool check_complete) {
+	const unsigned char* sp = (const unsigned char*)source;
+	const unsigned char* srcend = sp + slen;
+	unsigned char* dp = (unsigned char*)dest;
+	unsigned char* destend = dp + rawsize;
+	while (sp < srcend && dp < destend) {
+		// Each control byte describes the next 8 items, where a set bit is a reference back into the output, and a clear
+		// bit is a literal byte
+		unsigned char ctrl = *sp++;
+		for (int ctrlc = 0; ctrlc < 8 && sp < srcend && dp < destend; ctrlc++) {
+			if (ctrl & 1) {
+				if (sp + 1 >= srcend) {
+					return -1;
+				}
+				int32_t len = (sp[0] & 0x0f) + 3;
+				int32_t off = ((sp[0] & 0xf0) << 4) | sp[1];
+				sp += 2;
+				if (len == 18) {
+					if (sp >= srcend) {
+						return -1;
+					}
+					len += *sp++;
+				}
+				if (off == 0 || off > (dp - (unsigned char*)dest)) {
+					return -1;
+				}
+				if (len > destend - dp) {
+					len = (int32_t)(destend - dp);
+				}
+				// The reference may overlap the bytes that it produces, so it's copied in steps that do not overlap
+				while (off < len) {
+					memcpy(dp, dp - off, off);
+					len -= off;
+					dp += off;
+					off += off;
+				}
+				memcpy(dp, dp - off, len);
+				dp += len;
+			} else {
+				*dp++ = *sp++;
+			}
+			ctrl >>= 1;
+		}
+	}
+	if (check_complete && (dp != destend || sp != srcend)) {
+		return -1;
+	}
+	return (int32_t)((char*)dp - dest);
+}
+
+// pgext_lz4_decompress decompresses an LZ4 block, which is how Postgres stores values that were compressed with lz4.
+// Returns the number of bytes that were written, or -1 if the block is corrupt.
+DLLEXPORT int32_t pgext_lz4_decompress(const char* source, int32_t slen, char* dest, int32_t rawsize) {
+	const unsigned char* ip = (const unsigned char*)source;
+	const unsigned char* iend = ip + slen;
+	unsigned char* op = (unsigned char*)dest;
+	unsigned char* oend = op + rawsize;
+	while (ip < iend) {
+		unsigned char token = *ip++;
+		size_t length = token >> 4;
+		if (length == 15) {
+			unsigned char b;
+			do {
+				if (ip >= iend) {
+					return -1;
+				}
+				b = *ip++;
+				length += b;
+			} while (b == 255);
+		}
+		if (length > (size_t)(iend - ip) || length > (size_t)(oend - op)) {
+			return -1;
+		}
+		memcpy(op, ip, length);
+		ip += length;
+		op += length;
+		// The last sequence only has literals
+		if (ip >= iend) {
+			break;
+		}
+		if (iend - ip < 2) {
+			return -1;
+		}
+		size_t offset = ip[0] | ((size_t)ip[1] << 8);
+		ip += 2;
+		if (offset == 0 || offset > (size_t)(op - (unsigned char*)dest)) {
+			return -1;
+		}
+		length = token & 0x0f;
+		if (length == 15) {
+			unsigned char b;
+			do {
+				if (ip >= iend) {
+					return -1;
+				}
+				b = *ip++;
+				length += b;
+			} while (b == 255);
+		}
+		length += 4;
+		if (length > (size_t)(oend - op)) {
+			return -1;
+		}
+		// The match may overlap the bytes that it produces, so it's copied a byte at a time
+		const unsigned char* match = op - offset;
+		for (size_t i = 0; i < length; i++) {
+			op[i] = match[i];
+		}
+		op += length;
+	}
+	return (int32_t)((char*)op - dest);
+}
+
+// toast_decompress_datum returns the decompressed form of the compressed varlena.
+static varlena* toast_decompress_datum(varlena* attr) {
+	int32_t rawsize = (int32_t)VARDATA_COMPRESSED_GET_EXTSIZE(attr);
+	const char* source = ((const char*)attr) + VARHDRSZ_COMPRESSED;
+	int32_t slen = (int32_t)VARSIZE_4B(attr) - VARHDRSZ_COMPRESSED;
+	varlena* result = (varlena*)palloc(rawsize + VARHDRSZ);
+	if (result == NULL) {
+		return NULL;
+	}
+	int32_t decompressed;
+	switch (VARDATA_COMPRESSED_GET_COMPRESS_METHOD(attr)) {
+	case TOAST_PGLZ_COMPRESSION_ID:
+		decompressed = pglz_decompress(source, slen, ((char*)result) + VARHDRSZ, rawsize, true);
+		if (decompressed < 0) {
+			pgext_raise_error(ERROR, ERRCODE_DATA_CORRUPTED, "compressed pglz data is corrupt");
+			return NULL;
+		}
+		break;
+	case TOAST_LZ4_COMPRESSION_ID:
+		decompressed = pgext_lz4_decompress(source, slen, ((char*)result) + VARHDRSZ, rawsize);
+		if (decompressed < 0) {
+			pgext_raise_error(ERROR, ERRCODE_DATA_CORRUPTED, "compressed lz4 data is corrupt");
+			return NULL;
+		}
+		break;
+	default:
+		pgext_raise_error(ERROR, ERRCODE_DATA_CORRUPTED, "invalid compression method id %d",
+			(int)VARDATA_COMPRESSED_GET_COMPRESS_METHOD(attr));
+		return NULL;
+	}
+	SET_VARSIZE(result, decompressed + VARHDRSZ);
+	return result;
+}
+
+// copy_varlena returns a copy of the varlena, which must not be external.
+static varlena* copy_varlena(const varlena* attr) {
+	size_t len = VARSIZE_ANY(attr);
+	varlena* result = (varlena*)palloc(len);
+	if (result != NULL) {
+		memcpy(result, attr, len);
+	}
+	return result;
+}
+
+// external_indirect_pointer returns the varlena that the indirect pointer refers to.
+static varlena* external_indirect_pointer(const varlena* attr) {
+	varatt_indirect redirect;
+	memcpy(&redirect, VARDATA_EXTERNAL(attr), sizeof(redirect));
+	return redirect.pointer;
+}
+
+// check_external_tag raises an error for external values that are neither on disk nor indirect, which are the
+// expanded objects that the shim does not support.
+static void check_external_tag(const varlena* attr) {
+	uint8_t tag = VARTAG_EXTERNAL(attr);
+	if (tag != VARTAG_ONDISK && tag != VARTAG_INDIRECT) {
+		pgext_raise_error(ERROR, ERRCODE_FEATURE_NOT_SUPPORTED, "cannot access an expanded value with tag %u", tag);
+	}
+}
+
+DLLEXPORT varlena* detoast_external_attr(varlena* attr) {
+	if (VARATT_IS_EXTERNAL_ONDISK(attr)) {
+		return toast_fetch_datum(attr);
+	}
+	if (VARATT_IS_EXTERNAL_INDIRECT(attr)) {
+		varlena* pointer = external_indirect_pointer(attr);
+		// The pointer may not refer to another external value, but it may refer to a compressed one, which is kept
+		if (VARATT_IS_EXTERNAL(pointer)) {
+			pgext_raise_error(ERROR, ERRCODE_DATA_CORRUPTED, "indirect TOAST pointer refers to an external value");
+			return NULL;
+		}
+		return copy_varlena(pointer);
+	}
+	if (VARATT_IS_EXTERNAL(attr)) {
+		check_external_tag(attr);
+	}
+	return attr;
+}
+
+DLLEXPORT varlena* detoast_attr(varlena* attr) {
+	if (VARATT_IS_EXTERNAL_ONDISK(attr)) {
+		varlena* fetched = toast_fetch_datum(attr);
+		if (fetched != NULL && VARATT_IS_COMPRESSED(fetched)) {
+			varlena* decompressed = toast_decompress_datum(fetched);
+			pfree(fetched);
+			return decompressed;
+		}
+		return fetched;
+	}
+	if (VARATT_IS_EXTERNAL_INDIRECT(attr)) {
+		varlena* pointer = external_indirect_pointer(attr);
+		varlena* result = detoast_attr(pointer);
+		// The caller owns the result, so a plain value that the pointer refers to must be copied
+		if (result == pointer) {
+			return copy_varlena(pointer);
+		}
+		return result;
+	}
+	if (VARATT_IS_EXTERNAL(attr)) {
+		check_external_tag(attr);
+		return attr;
+	}
+	if (VARATT_IS_COMPRESSED(attr)) {
+		return toast_decompress_datum(attr);
+	}
+	if (VARATT_IS_SHORT(attr)) {
+		// Callers expect a 4-byte header, so packed values are expanded into a copy
+		size_t data_len = VARSIZE_1B(attr) - VARHDRSZ_SHORT;
+		varlena* result = (varlena*)palloc(data_len + VARHDRSZ);
+		if (result == NULL) {
+			return NULL;
+		}
+		SET_VARSIZE(result, data_len + VARHDRSZ);
+		memcpy(((char*)result) + VARHDRSZ, ((char*)attr) + VARHDRSZ_SHORT, data_len);
+		return result;
+	}
+	return attr;
+}
+
+DLLEXPORT varlena* detoast_attr_slice(varlena* attr, int32_t sliceoffset, int32_t slicelength) {
+	if (sliceoffset < 0) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "invalid sliceoffset: %d", sliceoffset);
+		return NULL;
+	}
+	// A negative length, or one that overflows, takes the rest of the value
+	int64_t slicelimit = slicelength < 0 ? -1 : (int64_t)sliceoffset + slicelength;
+	varlena* detoasted = attr;
+	if (VARATT_IS_EXTENDED(attr) && !VARATT_IS_SHORT(attr)) {
+		detoasted = detoast_attr(attr);
+	}
+	if (detoasted == NULL) {
+		return NULL;
+	}
+	const char* attrdata = VARDATA_ANY(detoasted);
+	int32_t attrsize = (int32_t)VARSIZE_ANY_EXHDR(detoasted);
+	if (sliceoffset >= attrsize) {
+		sliceoffset = 0;
+		slicelength = 0;
+	} else if (slicelimit < 0 || slicelimit > attrsize) {
+		slicelength = attrsize - sliceoffset;
+	}
+	varlena* result = (varlena*)palloc(slicelength + VARHDRSZ);
+	if (result == NULL) {
+		return NULL;
+	}
+	SET_VARSIZE(result, slicelength + VARHDRSZ);
+	memcpy(((char*)result) + VARHDRSZ, attrdata + sliceoffset, slicelength);
+	if (detoasted != attr) {
+		pfree(detoasted);
+	}
+	return result;
+}
+
+DLLEXPORT varlena* pg_detoast_datum(varlena* datum) {
+	if (VARATT_IS_EXTENDED(datum)) {
+		return detoast_attr(datum);
+	}
+	return datum;
+}
+
+DLLEXPORT varlena* pg_detoast_datum_copy(varlena* datum) {
+	if (VARATT_IS_EXTENDED(datum)) {
+		return detoast_attr(datum);
+	}
+	return copy_varlena(datum);
+}
+
+DLLEXPORT varlena* pg_detoast_datum_slice(varlena* datum, int32_t first, int32_t count) {
+	return detoast_attr_slice(datum, first, count);
+}
+
+DLLEXPORT varlena* pg_detoast_datum_packed(varlena* datum) {
+	// Packed values may be read through the macros that accept either header, so only the other forms are detoasted
+	if (VARATT_IS_COMPRESSED(datum) || VARATT_IS_EXTERNAL(datum)) {
+		return detoast_attr(datum);
+	}
+	return datum;
+}
+
+DLLEXPORT text* cstring_to_text_with_len(const char* s, int len) {
+	text* result = (text*)palloc(len + VARHDRSZ);
+	if (result == NULL) {
+		return NULL;
+	}
+	SET_VARSIZE(result, len + VARHDRSZ);
+	memcpy(((char*)result) + VARHDRSZ, s, len);
+	return result;
+}
+
+DLLEXPORT text* cstring_to_text(const char* s) {
+	return cstring_to_text_with_len(s, (int)strlen(s));
+}
+
+DLLEXPORT char* text_to_cstring(const text* t) {
+	text* tunpacked = pg_detoast_datum_packed((text*)t);
+	if (tunpacked == NULL) {
+		return NULL;
+	}
+	size_t len = VARSIZE_ANY_EXHDR(tunpacked);
+	char* result = (char*)palloc(len + 1);
+	if (result == NULL) {
+		return NULL;
+	}
+	memcpy(result, VARDATA_ANY(tunpacked), len);
+	result[len] = '\0';
+	if (tunpacked != t) {
+		pfree(tunpacked);
+	}
+	return result;
+}
+
+DLLEXPORT void text_to_cstring_buffer(const text* src, char* dst, size_t dst_len) {
+	if (dst_len == 0) {
+		return;
+	}
+	text* srcunpacked = pg_detoast_datum_packed((text*)src);
+	if (srcunpacked == NULL) {
+		dst[0] = '\0';
+		return;
+	}
+	size_t src_len = VARSIZE_ANY_EXHDR(srcunpacked);
+	if (src_len >= dst_len) {
+		src_len = dst_len - 1;
+	}
+	memcpy(dst, VARDATA_ANY(srcunpacked), src_len);
+	dst[src_len] = '\0';
+	if (srcunpacked != src) {
+		pfree(srcunpacked);
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This header declares the subset of the Postgres ABI that extensions may use when they're compiled to WebAssembly,
+// which the host runs within wazero. Modules are built as WASI reactors, for example:
+//
+//     clang --target=wasm32-wasi -mexec-model=reactor -O2 -o my_ext.wasm my_ext.c
+//
+// Datum is 64 bits wide, unlike a native wasm32 build of Postgres, so that the same types are passed by value as on the
+// 64-bit hosts. Pointers are offsets into the module's own memory, so only types that are passed by value may be given
+// to or returned from the host. Modules are not given an FmgrInfo, and cannot return sets or composites.
+
+#ifndef PG_EXT_WASM_H
+#define PG_EXT_WASM_H
+
+#include <stdarg.h>
+#include <stdbool.h>
+#include <stdint.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+typedef uint64_t Datum;
+typedef unsigned int Oid;
+
+typedef struct NullableDatum {
+	Datum value;
+	bool isnull;
+} NullableDatum;
+
+typedef struct FunctionCallInfoBaseData {
+	void* flinfo;
+	void* context;
+	void* resultinfo;
+	Oid fncollation;
+	bool isnull;
+	short nargs;
+	NullableDatum args[];
+} FunctionCallInfoBaseData;
+
+typedef FunctionCallInfoBaseData* FunctionCallInfo;
+
+typedef struct Pg_magic_struct {
+	int len;
+	int version;
+	int funcmaxargs;
+	int indexmaxkeys;
+	int namedatalen;
+	int float8byval;
+	char abi_extra[32];
+} Pg_magic_struct;
+
+typedef struct Pg_finfo_record {
+	int api_version;
+} Pg_finfo_record;
+
+#define PGEXT_WASM_EXPORT(name) __attribute__((export_name(name)))
+
+// PG_MODULE_MAGIC defines the magic block, along with the allocator that the host uses for the call info of each call.
+#define PG_MODULE_MAGIC \
+PGEXT_WASM_EXPORT("Pg_magic_func") const Pg_magic_struct* Pg_magic_func(void) { \
+	static const Pg_magic_struct data = {sizeof(Pg_magic_struct), 1500, 100, 32, 64, 1, "PostgreSQL"}; \
+	return &data; \
+} \
+PGEXT_WASM_EXPORT("pgext_alloc") void* pgext_alloc(size_t size) { \
+	return malloc(size); \
+} \
+PGEXT_WASM_EXPORT("pgext_free") void pgext_free(void* ptr) { \
+	free(ptr); \
+} \
+extern int no_such_variable
+
+#define PG_FUNCTION_INFO_V1(funcname) \
+Datum funcname(FunctionCallInfo fcinfo) PGEXT_WASM_EXPORT(#funcname); \
+PGEXT_WASM_EXPORT("pg_finfo_" #funcname) const Pg_finfo_record* pg_finfo_##funcname(void) { \
+	static const Pg_finfo_record my_finfo = {1}; \
+	return &my_finfo; \
+} \
+extern int no_such_variable
+
+#define PG_FUNCTION_ARGS FunctionCallInfo fcinfo
+#define PG_NARGS() (fcinfo->nargs)
+#define PG_ARGISNULL(n) (fcinfo->args[n].isnull)
+#define PG_GETARG_DATUM(n) (fcinfo->args[n].value)
+#define PG_GETARG_BOOL(n) ((bool)PG_GETARG_DATUM(n))
+#define PG_GETARG_INT16(n) ((int16_t)PG_GETARG_DATUM(n))
+#define PG_GETARG_INT32(n) ((int32_t)PG_GETARG_DATUM(n))
+#define PG_GETARG_INT64(n) ((int64_t)PG_GETARG_DATUM(n))
+#define PG_GETARG_OID(n) ((Oid)PG_GETARG_DATUM(n))
+#define PG_GET_COLLATION() (fcinfo->fncollation)
+
+static inline float pgext_datum_to_float4(Datum d) {
+	union { int32_t i; float f; } u = {.i = (int32_t)d};
+	return u.f;
+}
+
+static inline double pgext_datum_to_float8(Datum d) {
+	union { int64_t i; double f; } u = {.i = (int64_t)d};
+	return u.f;
+}
+
+static inline Datum pgext_float4_to_datum(float f) {
+	union { int32_t i; float f; } u = {.f = f};
+	return (Datum)(uint32_t)u.i;
+}
+
+static inline Datum pgext_float8_to_datum(double f) {
+	union { int64_t i; double f; } u = {.f = f};
+	return (Datum)u.i;
+}
+
+#define PG_GETARG_FLOAT4(n) pgext_datum_to_float4(PG_GETARG_DATUM(n))
+#define PG_GETARG_FLOAT8(n) pgext_datum_to_float8(PG_GETARG_DATUM(n))
+
+#define PG_RETURN_DATUM(x) return (x)
+#define PG_RETURN_NULL() do { fcinfo->isnull = true; return (Datum)0; } while (0)
+#define PG_RETURN_VOID() return (Datum)0
+#define PG_RETURN_BOOL(x) return (Datum)((x) ? 1 : 0)
+#define PG_RETURN_INT16(x) return (Datum)(uint16_t)(x)
+#define PG_RETURN_INT32(x) return (Datum)(uint32_t)(x)
+#define PG_RETURN_INT64(x) return (Datum)(x)
+#define PG_RETURN_OID(x) return (Datum)(x)
+#define PG_RETURN_FLOAT4(x) return pgext_float4_to_datum(x)
+#define PG_RETURN_FLOAT8(x) return pgext_float8_to_datum(x)
+
+#define DEBUG1  14
+#define LOG     15
+#define INFO    17
+#define NOTICE  18
+#define WARNING 19
+#define ERROR   21
+#define FATAL   22
+#define PANIC   23
+
+#define PGSIXBIT(ch) (((ch) - '0') & 0x3F)
+#define MAKE_SQLSTATE(ch1, ch2, ch3, ch4, ch5) \
+	(PGSIXBIT(ch1) + (PGSIXBIT(ch2) << 6) + (PGSIXBIT(ch3) << 12) + (PGSIXBIT(ch4) << 18) + (PGSIXBIT(ch5) << 24))
+#define ERRCODE_DIVISION_BY_ZERO MAKE_SQLSTATE('2', '2', '0', '1', '2')
+#define ERRCODE_INVALID_PARAMETER_VALUE MAKE_SQLSTATE('2', '2', '0', '2', '3')
+#define ERRCODE_NUMERIC_VALUE_OUT_OF_RANGE MAKE_SQLSTATE('2', '2', '0', '0', '3')
+#define ERRCODE_INTERNAL_ERROR MAKE_SQLSTATE('X', 'X', '0', '0', '0')
+
+// pgext_host_raise_error is implemented by the host. Messages at ERROR or higher end the call, so it does not return.
+__attribute__((import_module("env"), import_name("pgext_raise_error")))
+void pgext_host_raise_error(int elevel, int sqlerrcode, const char* message, size_t length);
+
+static inline void pgext_wasm_report(int elevel, int sqlerrcode, const char* fmt, ...) {
+	char message[1024];
+	va_list args;
+	va_start(args, fmt);
+	int length = vsnprintf(message, sizeof(message), fmt, args);
+	va_end(args);
+	if (length < 0) {
+		length = 0;
+	} else if (length >= (int)sizeof(message)) {
+		length = sizeof(message) - 1;
+	}
+	pgext_host_raise_error(elevel, sqlerrcode, message, (size_t)length);
+}
+
+#define elog(elevel, ...) pgext_wasm_report(elevel, ERRCODE_INTERNAL_ERROR, __VA_ARGS__)
+#define pgext_raise_error(elevel, sqlerrcode, ...) pgext_wasm_report(elevel, sqlerrcode, __VA_ARGS__)
+
+#endif // PG_EXT_WASM_H
@@ -0,0 +1,221 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "exports.h"
+
+// window_object returns the window object, raising an error if the function is not being called as a window function.
+static WindowObject window_object(WindowObject winobj) {
+	if (!WindowObjectIsValid(winobj)) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "window function called in non-window context");
+	}
+	return winobj;
+}
+
+// window_arg returns the argument of the window function for the row at the given position, which must be within the
+// partition.
+static Datum window_arg(WindowObject winobj, int argno, int64_t pos, bool* isnull) {
+	if (argno < 0 || argno >= winobj->numargs) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "invalid window function argument number: %d", argno);
+	}
+	if (pos < winobj->markpos) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "cannot fetch row before WindowObject's mark position");
+	}
+	Datum value = 0;
+	bool valueIsNull = true;
+	if (!winobj->partition->get_arg(winobj->handle, argno, pos, &value, &valueIsNull)) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "could not fetch argument %d of window row %lld", argno,
+			(long long)pos);
+	}
+	*isnull = valueIsNull;
+	return valueIsNull ? 0 : value;
+}
+
+// window_out_of_range reports that a requested row does not exist.
+static Datum window_out_of_range(bool* isnull, bool* isout) {
+	if (isout != NULL) {
+		*isout = true;
+	}
+	*isnull = true;
+	return 0;
+}
+
+// window_frame fetches the frame of the current row from the host, if it has not already been fetched.
+static void window_frame(WindowObject winobj) {
+	if (winobj->framevalid) {
+		return;
+	}
+	int64_t headpos = 0;
+	int64_t tailpos = 0;
+	if (!winobj->partition->frame(winobj->handle, winobj->currentpos, &headpos, &tailpos)) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "could not fetch the frame of window row %lld",
+			(long long)winobj->currentpos);
+	}
+	winobj->frameheadpos = headpos < 0 ? 0 : headpos;
+	winobj->frametailpos = tailpos > winobj->rowcount ? winobj->rowcount : tailpos;
+	winobj->framevalid = true;
+}
+
+DLLEXPORT void* WinGetPartitionLocalMemory(WindowObject winobj, size_t sz) {
+	winobj = window_object(winobj);
+	if (winobj->localmem == NULL) {
+		winobj->localmem = MemoryContextAllocZero(winobj->partcontext, sz);
+	}
+	return winobj->localmem;
+}
+
+DLLEXPORT int64_t WinGetCurrentPosition(WindowObject winobj) {
+	return window_object(winobj)->currentpos;
+}
+
+DLLEXPORT int64_t WinGetPartitionRowCount(WindowObject winobj) {
+	return window_object(winobj)->rowcount;
+}
+
+// WinSetMarkPosition declares that rows before the mark will no longer be fetched. Every row of the partition remains
+// available from the host, so the mark is only used to enforce the contract.
+DLLEXPORT void WinSetMarkPosition(WindowObject winobj, int64_t markpos) {
+	winobj = window_object(winobj);
+	if (markpos < winobj->markpos) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "cannot move WindowObject's mark position backward");
+	}
+	winobj->markpos = markpos;
+}
+
+DLLEXPORT bool WinRowsArePeers(WindowObject winobj, int64_t pos1, int64_t pos2) {
+	winobj = window_object(winobj);
+	if (pos1 == pos2) {
+		return true;
+	}
+	bool peers = false;
+	if (!winobj->partition->rows_are_peers(winobj->handle, pos1, pos2, &peers)) {
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "could not compare window rows %lld and %lld",
+			(long long)pos1, (long long)pos2);
+	}
+	return peers;
+}
+
+DLLEXPORT Datum WinGetFuncArgInPartition(WindowObject winobj, int argno, int relpos, int seektype, bool set_mark,
+	bool* isnull, bool* isout) {
+	winobj = window_object(winobj);
+	int64_t abs_pos;
+	switch (seektype) {
+	case WINDOW_SEEK_CURRENT:
+		abs_pos = winobj->currentpos + relpos;
+		break;
+	case WINDOW_SEEK_HEAD:
+		abs_pos = relpos;
+		break;
+	case WINDOW_SEEK_TAIL:
+		abs_pos = winobj->rowcount - 1 + relpos;
+		break;
+	default:
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "unrecognized window seek type: %d", seektype);
+		return 0;
+	}
+	if (abs_pos < 0 || abs_pos >= winobj->rowcount) {
+		return window_out_of_range(isnull, isout);
+	}
+	if (isout != NULL) {
+		*isout = false;
+	}
+	if (set_mark) {
+		WinSetMarkPosition(winobj, abs_pos);
+	}
+	return window_arg(winobj, argno, abs_pos, isnull);
+}
+
+DLLEXPORT Datum WinGetFuncArgInFrame(WindowObject winobj, int argno, int relpos, int seektype, bool set_mark,
+	bool* isnull, bool* isout) {
+	winobj = window_object(winobj);
+	window_frame(winobj);
+	int64_t abs_pos;
+	switch (seektype) {
+	case WINDOW_SEEK_CURRENT:
+		abs_pos = winobj->currentpos + relpos;
+		break;
+	case WINDOW_SEEK_HEAD:
+		abs_pos = winobj->frameheadpos + relpos;
+		break;
+	case WINDOW_SEEK_TAIL:
+		abs_pos = winobj->frametailpos - 1 + relpos;
+		break;
+	default:
+		pgext_raise_error(ERROR, ERRCODE_INTERNAL_ERROR, "unrecognized window seek type: %d", seektype);
+		return 0;
+	}
+	if (abs_pos < winobj->frameheadpos || abs_pos >= winobj->frametailpos) {
+		return window_out_of_range(isnull, isout);
+	}
+	if (isout != NULL) {
+		*isout = false;
+	}
+	if (set_mark) {
+		WinSetMarkPosition(winobj, abs_pos);
+	}
+	return window_arg(winobj, argno, abs_pos, isnull);
+}
+
+DLLEXPORT Datum WinGetFuncArgCurrent(WindowObject winobj, int argno, bool* isnull) {
+	winobj = window_object(winobj);
+	return window_arg(winobj, argno, winobj->currentpos, isnull);
+}
+
+// pgext_window_begin returns the window object of a new partition with the given number of rows, whose memory contexts
+// are freed by pgext_window_end. The handle is given to each function of the partition. Returns NULL when out of
+// memory.
+DLLEXPORT WindowObject pgext_window_begin(PgExtWindowPartition* partition, uintptr_t handle, uintptr_t numargs,
+	uintptr_t rowcount) {
+	MemoryContext partcontext = AllocSetContextCreateInternal(NULL, "WindowPartitionContext", 0, 0, 0);
+	if (partcontext == NULL) {
+		return NULL;
+	}
+	MemoryContext tmpcontext = AllocSetContextCreateInternal(partcontext, "WindowTupleContext", 0, 0, 0);
+	WindowObject winobj = (WindowObject)MemoryContextAllocExtended(partcontext, sizeof(WindowObjectData),
+		MCXT_ALLOC_NO_OOM | MCXT_ALLOC_ZERO);
+	if (tmpcontext == NULL || winobj == NULL) {
+		MemoryContextDelete(partcontext);
+		return NULL;
+	}
+	winobj->type = T_WindowObjectData;
+	winobj->partition = partition;
+	winobj->handle = handle;
+	winobj->numargs = (int)numargs;
+	winobj->rowcount = (int64_t)rowcount;
+	winobj->partcontext = partcontext;
+	winobj->tmpcontext = tmpcontext;
+	return winobj;
+}
+
+// pgext_window_row_begin makes the row at the given position current, and resets the temporary memory context of the
+// partition, which frees everything that the previous call allocated, before making it current for the next call.
+DLLEXPORT uintptr_t pgext_window_row_begin(WindowObject winobj, uintptr_t pos) {
+	winobj->currentpos = (int64_t)pos;
+	winobj->framevalid = false;
+	MemoryContextReset(winobj->tmpcontext);
+	winobj->savedcontext = MemoryContextSwitchTo(winobj->tmpcontext);
+	return 0;
+}
+
+// pgext_window_row_end restores the memory context that was current before pgext_window_row_begin.
+DLLEXPORT uintptr_t pgext_window_row_end(WindowObject winobj) {
+	CurrentMemoryContext = winobj->savedcontext;
+	winobj->savedcontext = NULL;
+	return 0;
+}
+
+// pgext_window_end frees the memory contexts of the partition, along with the window object.
+DLLEXPORT uintptr_t pgext_window_end(WindowObject winobj) {
+	MemoryContextDelete(winobj->partcontext);
+	return 0;
+}
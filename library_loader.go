@@ -12,18 +12,54 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package pg_extension
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+	"unsafe"
 )
 
 // Library is a fully-loaded extension library.
 type Library struct {
+	path     string
 	magic    PgMagicStruct
 	funcs    map[string]Function
 	internal InternalLoadedLibrary
+	// accounting tracks the resources consumed by the library.
+	accounting resourceAccounting
+	// metrics tracks the calls to each of the library's functions while function metrics are enabled.
+	metrics functionMetricsTable
+	// local is true when the library was opened with WithLocalSymbols, in which case its symbols are not available to
+	// other libraries.
+	local bool
+	// dispatcher runs every call into the library when it was opened with WithThreadAffinity, and is nil otherwise.
+	dispatcher *threadDispatcher
+	// wasm runs the library when it was compiled to WebAssembly, and is nil for native libraries.
+	wasm *wasmModule
+	// initialized is true when the library's _PG_init was called, in which case its _PG_fini is called when it's closed.
+	initialized bool
+	// sharedPreload is true when the library was opened with WithSharedPreload.
+	sharedPreload bool
+	// refs is the number of times that the library has been loaded without being closed. The library is only unloaded
+	// once every reference has been closed.
+	refs int
+	// stale is set once the library's file has changed while it was loaded, after which it's no longer shared with later
+	// loads of the same path.
+	stale bool
+	// copyPath is the temporary copy of the file that the library was opened from, which is empty when it was opened
+	// from its own path.
+	copyPath string
 }
 
 // InternalLoadedLibrary is an interface that is implemented by the specific platform to handle library operations.
@@ -38,9 +74,35 @@ type Function struct {
 	Ptr        uintptr
 	Args       []int
 	APIVersion int
+	Volatility Volatility
+	// Strict is true for functions that are declared STRICT, which return NULL without being called when any argument is
+	// NULL.
+	Strict bool
 	// TODO: return type?
+	// library is the library that the function belongs to.
+	library *Library
+	// timeout is how long each call may run before it's canceled, which is zero for calls without a timeout.
+	timeout time.Duration
 }
 
+// functionAttributes are the attributes of a function that are declared by its SQL definitions.
+type functionAttributes struct {
+	volatility Volatility
+	strict     bool
+	// referenceType is the first of the function's argument and return types that may be passed by reference, which is
+	// empty when all of them are passed by value (see wasmReferenceType).
+	referenceType string
+}
+
+// Volatility is the volatility classification of a function, which determines whether its results may be reused.
+type Volatility uint8
+
+const (
+	VolatilityVolatile Volatility = iota
+	VolatilityStable
+	VolatilityImmutable
+)
+
 // PgFunctionInfo is a stand-in for the C struct that reports the function information.
 type PgFunctionInfo struct {
 	APIVersion int32
@@ -48,72 +110,572 @@ type PgFunctionInfo struct {
 
 // PgMagicStruct is a stand-in for the C struct that reports the information of the library.
 type PgMagicStruct struct {
-	Len          int32
+	Len int32
+	// Version is the major version of Postgres that the library was built for, multiplied by 100.
 	Version      int32
 	FuncMaxArgs  int32
 	IndexMaxKeys int32
 	NameDataLen  int32
-	Float4ByVal  int32
 	Float8ByVal  int32
+	// ABIExtra identifies the ABI of the server, which is "PostgreSQL" for builds that have not modified it.
+	ABIExtra [32]byte
 }
 
+const (
+	// magicIndexMaxKeys is the INDEX_MAX_KEYS that the shim emulates.
+	magicIndexMaxKeys = 32
+	// magicNameDataLen is the NAMEDATALEN that the shim emulates.
+	magicNameDataLen = 64
+	// magicABIExtra is the ABI identifier that the shim emulates.
+	magicABIExtra = "PostgreSQL"
+)
+
 var (
-	// loadedLibraries contains all of the loaded libraries.
+	// loadedLibraries contains all of the loaded libraries, keyed by their resolved path, so that a library is shared by
+	// everything that loads it.
 	// TODO: need to close all of these before the program ends
 	loadedLibraries = make(map[string]*Library)
 	// loadedLibrariesMutex gates access to the cached libraries.
 	loadedLibrariesMutex = &sync.Mutex{}
+	// staleLibraries contains the number of stale libraries that are still open, keyed by their path.
+	staleLibraries = make(map[string]int)
+	// skippedLibraryInits contains the names of the libraries whose _PG_init should not be called.
+	skippedLibraryInits = make(map[string]struct{})
+	// supportedMajorVersions are the major versions of Postgres whose libraries may be loaded. The call info, memory
+	// contexts, and every other struct that is shared with extensions follow the layouts of these versions, so libraries
+	// that were built for any other version are rejected rather than being loaded with layouts that they don't expect.
+	supportedMajorVersions = []int{15}
+	shimCallProcedure      = newShimProc("pgext_call_procedure")
 )
 
-// LoadLibrary loads the library of the extension, along with preloading all of the functions given.
-func LoadLibrary(path string, funcNames []string) (*Library, error) {
+// SetSkipLibraryInit sets whether the _PG_init of the library with the given name is skipped, where the name is the
+// library's file name without its directory or extension, such as "pg_stat_statements". This is meant for libraries
+// whose initialization requires server facilities that are not emulated, such as shared memory, and only applies to
+// libraries that are loaded afterward. A library whose _PG_init is skipped does not have its _PG_fini called either.
+func SetSkipLibraryInit(name string, skip bool) {
+	loadedLibrariesMutex.Lock()
+	defer loadedLibrariesMutex.Unlock()
+	if skip {
+		skippedLibraryInits[name] = struct{}{}
+	} else {
+		delete(skippedLibraryInits, name)
+	}
+}
+
+// LoadLibrary loads the library of the extension, along with preloading all of the functions given. A library that is
+// already loaded, including through another path to the same file, is shared rather than loaded again, and the given
+// functions are added to it. Each call adds a reference to the library, which must be released through Close. The
+// options only apply when the library is first opened, and the options from SetLibraryOptions are used when none are
+// given. Libraries whose file ends with .wasm were compiled to WebAssembly against library/wasm/pg_wasm.h, and are run
+// within wazero rather than being opened by the dynamic loader, in which case the options do not apply. Their functions
+// may only take and return types that are passed by value, which is checked for the functions that an extension loads
+// (see ExtensionFiles.LoadLibraries), but cannot be checked for those that are given here.
+func LoadLibrary(path string, funcNames []string, options ...LibraryOption) (*Library, error) {
+	return loadLibrary(path, funcNames, nil, options)
+}
+
+// loadLibrary is the same as LoadLibrary, except that each function is assigned its attributes from the given map.
+// Functions that are missing from the map are volatile and not strict.
+func loadLibrary(path string, funcNames []string, attributes map[string]functionAttributes,
+	options []LibraryOption) (*Library, error) {
+	// Libraries are initialized through the shim while the library mutex is held, so the backend lock is taken first
+	// (see lockBackend)
+	unlock := lockBackend()
+	defer unlock()
 	loadedLibrariesMutex.Lock()
 	defer loadedLibrariesMutex.Unlock()
 
+	path = resolveLibraryPath(path)
 	if lib, ok := loadedLibraries[path]; ok {
+		if err := lib.addFunctions(funcNames, attributes); err != nil {
+			return nil, err
+		}
+		lib.refs++
 		return lib, nil
 	}
-	internalLib, err := loadLibraryInternal(path)
+	verified, err := verifyLibrary(path)
 	if err != nil {
 		return nil, err
 	}
-	magicPtr, err := internalLib.Lookup("Pg_magic_func")
+	if isWasmLibrary(path) {
+		return loadWasmLibrary(path, funcNames, attributes)
+	}
+	// The dynamic loader reports libraries of another architecture with cryptic messages such as "wrong ELF class", if
+	// it reports them at all, so we check the architecture ourselves
+	if arch, err := libraryArchitecture(path); err == nil && len(arch) > 0 && arch != runtime.GOARCH {
+		return nil, &LoadError{
+			Kind: ErrIncompatibleLibrary,
+			File: path,
+			Err:  fmt.Errorf("library was built for %s, but this process is %s", arch, runtime.GOARCH),
+		}
+	}
+	// Libraries that were built against another C library fail with messages about a missing libc.so.6 or missing symbol
+	// versions, which don't explain that the library must be rebuilt, so this is also checked beforehand
+	if err := checkLibraryLibc(path); err != nil {
+		return nil, err
+	}
+	// The dynamic loader only reports the first missing symbol, if it reports any at all before the symbol is called, so
+	// we find every missing symbol beforehand. Libraries that cannot be scanned are left for the loader to reject.
+	loadedPaths := globalLibraryPaths()
+	if missing, err := missingSymbols(path, loadedPaths); err == nil && len(missing) > 0 {
+		return nil, &LoadError{
+			Kind:    ErrMissingSymbol,
+			File:    path,
+			Symbols: missing,
+		}
+	}
+	// Extensions may define configuration variables and register background workers as soon as they're loaded
+	if err := registerConfigRegistry(); err != nil {
+		return nil, err
+	}
+	if err := registerBackgroundWorkerManager(); err != nil {
+		return nil, err
+	}
+	opts := newLibraryOptions(path, options)
+	// The dynamic loader hands back the image that's already open for a path, so a library whose older version is still
+	// open is loaded from a copy
+	openPath := path
+	if staleLibraries[path] > 0 {
+		if openPath, err = copyLibraryFile(path, verified); err != nil {
+			return nil, err
+		}
+		opts.dllDirectories = append(opts.dllDirectories, filepath.Dir(path))
+	}
+	internalLib, err := loadLibraryInternal(openPath, opts)
 	if err != nil {
+		removeLibraryCopy(path, openPath)
 		return nil, err
 	}
-	// We don't free the magic struct since it's a pointer to static memory
-	magicStructDatum, isNotNull := CallFmgrFunction(magicPtr)
-	if !isNotNull {
-		return nil, fmt.Errorf("unable to find magic function for `%s`", path)
+	lib, err := newLibrary(path, internalLib, funcNames, attributes)
+	if err != nil {
+		_ = internalLib.Close()
+		removeLibraryCopy(path, openPath)
+		return nil, err
+	}
+	if openPath != path {
+		lib.copyPath = openPath
+	}
+	lib.local = opts.local
+	lib.sharedPreload = opts.sharedPreload
+	if opts.threadAffinity {
+		lib.dispatcher = newThreadDispatcher()
+	}
+	if err = lib.init(); err != nil {
+		if lib.dispatcher != nil {
+			lib.dispatcher.close()
+		}
+		return nil, err
+	}
+	lib.refs = 1
+	loadedLibraries[path] = lib
+	return lib, nil
+}
+
+// newLibrary validates the magic block of the library that was just loaded, and then looks up the given functions.
+func newLibrary(path string, internalLib InternalLoadedLibrary, funcNames []string,
+	attributes map[string]functionAttributes) (*Library, error) {
+	var magicStruct PgMagicStruct
+	var err error
+	if wasm, ok := internalLib.(*wasmModule); ok {
+		magicStruct, err = wasm.readMagic(path)
+	} else {
+		magicStruct, err = readMagic(path, internalLib)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = validateMagic(magicStruct); err != nil {
+		return nil, &LoadError{
+			Kind: ErrIncompatibleMagic,
+			File: path,
+			Err:  err,
+		}
 	}
-	magicStruct := *(FromDatum[PgMagicStruct](magicStructDatum))
 	lib := &Library{
+		path:     path,
 		magic:    magicStruct,
 		funcs:    make(map[string]Function),
 		internal: internalLib,
 	}
+	lib.wasm, _ = internalLib.(*wasmModule)
+	if err = lib.addFunctions(funcNames, attributes); err != nil {
+		return nil, err
+	}
+	return lib, nil
+}
+
+// readMagic returns the magic block of the native library that was just loaded.
+func readMagic(path string, internalLib InternalLoadedLibrary) (PgMagicStruct, error) {
+	magicPtr, err := internalLib.Lookup("Pg_magic_func")
+	if err != nil {
+		return PgMagicStruct{}, &LoadError{
+			Kind: ErrIncompatibleMagic,
+			File: path,
+			Err:  errors.New("missing magic block"),
+		}
+	}
+	// We don't free the magic struct since it's a pointer to static memory
+	magicStructDatum, isNotNull, err := CallFmgrFunction(magicPtr)
+	if err != nil {
+		return PgMagicStruct{}, err
+	}
+	if !isNotNull {
+		return PgMagicStruct{}, &LoadError{
+			Kind: ErrIncompatibleMagic,
+			File: path,
+			Err:  errors.New("missing magic block"),
+		}
+	}
+	// The length is checked before anything else, as the rest of the struct may not exist
+	if magicLen := *(FromDatum[int32](magicStructDatum)); magicLen != int32(unsafe.Sizeof(PgMagicStruct{})) {
+		return PgMagicStruct{}, &LoadError{
+			Kind: ErrIncompatibleMagic,
+			File: path,
+			Err:  fmt.Errorf("magic block mismatch: magic block has length %d", magicLen),
+		}
+	}
+	return *(FromDatum[PgMagicStruct](magicStructDatum)), nil
+}
+
+// resolveLibraryPath returns the absolute path of the library with all symbolic links resolved, so that every path to
+// the same file shares a single library. The path is returned as-is if it cannot be resolved, in which case the
+// dynamic loader reports the failure.
+func resolveLibraryPath(path string) string {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if resolved, err := filepath.EvalSymlinks(absPath); err == nil {
+		return resolved
+	}
+	return absPath
+}
+
+// addFunctions looks up each of the given functions that the library has not already loaded, assigning each its
+// attributes from the given map. Functions that were already loaded keep their attributes. The library's functions are
+// replaced rather than modified, as they may be read by other users of the library. This expects the library mutex to
+// be held.
+func (lib *Library) addFunctions(funcNames []string, attributes map[string]functionAttributes) error {
+	var funcs map[string]Function
 	for _, funcName := range funcNames {
-		finfoPtr, err := internalLib.Lookup(fmt.Sprintf("pg_finfo_%s", funcName))
-		if err != nil {
-			return nil, err
+		if referenceType := attributes[funcName].referenceType; lib.wasm != nil && len(referenceType) > 0 {
+			return &LoadError{
+				Kind:   ErrUnsupportedFunction,
+				File:   lib.path,
+				Symbol: funcName,
+				Err: fmt.Errorf("type `%s` may be passed by reference, but functions within a WebAssembly module may "+
+					"only take and return types that are passed by value", referenceType),
+			}
 		}
-		// We don't free finfo since it's a pointer to static memory
-		finfoDatum, isNotNull := CallFmgrFunction(finfoPtr)
-		apiVersion := 0
-		if isNotNull {
-			apiVersion = int(FromDatum[PgFunctionInfo](finfoDatum).APIVersion)
+		if _, ok := lib.funcs[funcName]; ok {
+			continue
 		}
-		funcPtr, err := internalLib.Lookup(funcName)
+		funcPtr, err := lib.internal.Lookup(funcName)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		apiVersion, err := functionAPIVersion(lib.internal, lib.path, funcName)
+		if err != nil {
+			return err
+		}
+		if funcs == nil {
+			funcs = maps.Clone(lib.funcs)
 		}
-		lib.funcs[funcName] = Function{
+		funcs[funcName] = Function{
 			Name:       funcName,
 			Ptr:        funcPtr,
 			Args:       nil,
 			APIVersion: apiVersion,
+			Volatility: attributes[funcName].volatility,
+			Strict:     attributes[funcName].strict,
+			library:    lib,
 		}
 	}
-	loadedLibraries[path] = lib
-	return lib, nil
+	if funcs != nil {
+		lib.funcs = funcs
+	}
+	return nil
+}
+
+// functionAPIVersion returns the calling convention of the function, which is reported by its pg_finfo_ function.
+// Functions that do not use the V1 calling convention cannot be called, so they are rejected here rather than crashing
+// when they're called.
+func functionAPIVersion(internalLib InternalLoadedLibrary, path string, funcName string) (int, error) {
+	finfoPtr, err := internalLib.Lookup(fmt.Sprintf("pg_finfo_%s", funcName))
+	if err != nil {
+		return 0, &LoadError{
+			Kind:   ErrUnsupportedFunction,
+			File:   path,
+			Symbol: funcName,
+			Err: fmt.Errorf("could not find function information for function \"%s\"; SQL-callable functions need an "+
+				"accompanying PG_FUNCTION_INFO_V1(%s)", funcName, funcName),
+		}
+	}
+	apiVersion := 0
+	if wasm, ok := internalLib.(*wasmModule); ok {
+		if apiVersion, err = wasm.readFunctionAPIVersion(finfoPtr); err != nil {
+			return 0, err
+		}
+	} else {
+		// We don't free finfo since it's a pointer to static memory
+		finfoDatum, isNotNull, err := CallFmgrFunction(finfoPtr)
+		if err != nil {
+			return 0, err
+		}
+		if isNotNull {
+			apiVersion = int(FromDatum[PgFunctionInfo](finfoDatum).APIVersion)
+		}
+	}
+	if apiVersion != 1 {
+		return 0, &LoadError{
+			Kind:   ErrUnsupportedFunction,
+			File:   path,
+			Symbol: funcName,
+			Err:    fmt.Errorf(`unrecognized API version %d reported by info function "pg_finfo_%s"`, apiVersion, funcName),
+		}
+	}
+	return apiVersion, nil
+}
+
+// validateMagic returns an error if the library's magic block does not match the ABI that the shim emulates, using the
+// same messages as Postgres.
+func validateMagic(magic PgMagicStruct) error {
+	if magic.Version%100 != 0 || !slices.Contains(supportedMajorVersions, int(magic.Version/100)) {
+		return fmt.Errorf("version mismatch: server is version %s, library is version %d",
+			strings.Join(majorVersionNames(supportedMajorVersions), " or "), magic.Version/100)
+	}
+	if magic.FuncMaxArgs != FuncMaxArgs {
+		return fmt.Errorf("magic block mismatch: server has FUNC_MAX_ARGS = %d, library has %d", FuncMaxArgs,
+			magic.FuncMaxArgs)
+	}
+	if magic.IndexMaxKeys != magicIndexMaxKeys {
+		return fmt.Errorf("magic block mismatch: server has INDEX_MAX_KEYS = %d, library has %d", magicIndexMaxKeys,
+			magic.IndexMaxKeys)
+	}
+	if magic.NameDataLen != magicNameDataLen {
+		return fmt.Errorf("magic block mismatch: server has NAMEDATALEN = %d, library has %d", magicNameDataLen,
+			magic.NameDataLen)
+	}
+	// Float8 is passed by value whenever Datum is 64 bits wide
+	float8ByVal := int32(0)
+	if unsafe.Sizeof(Datum(0)) == 8 {
+		float8ByVal = 1
+	}
+	if magic.Float8ByVal != float8ByVal {
+		return fmt.Errorf("magic block mismatch: server has FLOAT8PASSBYVAL = %t, library has %t", float8ByVal != 0,
+			magic.Float8ByVal != 0)
+	}
+	abiExtra, _, _ := bytes.Cut(magic.ABIExtra[:], []byte{0})
+	if string(abiExtra) != magicABIExtra {
+		return fmt.Errorf(`magic block mismatch: server has ABI "%s", library has "%s"`, magicABIExtra, abiExtra)
+	}
+	return nil
+}
+
+// majorVersionNames returns the given major versions as strings.
+func majorVersionNames(versions []int) []string {
+	names := make([]string, len(versions))
+	for i, version := range versions {
+		names[i] = strconv.Itoa(version)
+	}
+	return names
+}
+
+// init calls the library's _PG_init, if it has one and it is not skipped. A library whose initialization fails is not
+// unloaded, as it may have already registered variables or callbacks that reference it, which matches Postgres.
+func (lib *Library) init() error {
+	if _, ok := skippedLibraryInits[libraryName(lib.path)]; ok {
+		return nil
+	}
+	initPtr, err := lib.internal.Lookup("_PG_init")
+	if err != nil {
+		// Libraries are not required to have an initialization function
+		lib.initialized = true
+		return nil
+	}
+	if lib.sharedPreload && lib.wasm == nil {
+		if _, err = shimSetSharedPreload.Call(1); err != nil {
+			return err
+		}
+		defer shimSetSharedPreload.MustCall(0)
+	}
+	if err = lib.callProcedure(initPtr); err != nil {
+		return &LoadError{
+			Kind: ErrInitFailed,
+			File: lib.path,
+			Err:  err,
+		}
+	}
+	lib.initialized = true
+	return nil
+}
+
+// run runs the function on the library's dedicated thread when it was opened with WithThreadAffinity, and on the
+// current thread otherwise.
+func (lib *Library) run(f func()) {
+	if lib.dispatcher == nil {
+		f()
+		return
+	}
+	lib.dispatcher.run(f)
+}
+
+// callProcedure calls a function of the library that takes no arguments and returns nothing, which is called on the
+// library's dedicated thread when it has one.
+func (lib *Library) callProcedure(fn uintptr) (err error) {
+	if lib.wasm != nil {
+		return lib.wasm.callProcedure(fn)
+	}
+	lib.run(func() {
+		err = callProcedure(fn)
+	})
+	return err
+}
+
+// callProcedure calls a function of a library that takes no arguments and returns nothing, returning the error that it
+// raised as a PostgresError, or as a CrashError if it crashed.
+func callProcedure(fn uintptr) error {
+	// The error that the call raised belongs to the state of the current thread, so we must remain on the same thread
+	// until it has been read
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	edata, err := shimCallProcedure.Call(fn)
+	if err != nil {
+		return err
+	}
+	if edata != 0 {
+		return newCallError(edata)
+	}
+	return nil
+}
+
+// Path returns the resolved path of the library's file.
+func (lib *Library) Path() string {
+	return lib.path
+}
+
+// Magic returns the magic block of the library, which describes the build of Postgres that it was built against.
+func (lib *Library) Magic() PgMagicStruct {
+	return lib.magic
+}
+
+// Function returns the function with the given symbol, which must have been given when the library was loaded (or
+// referenced by the SQL files of the extension that loaded it). Returns false if the function was not loaded.
+func (lib *Library) Function(name string) (Function, bool) {
+	loadedLibrariesMutex.Lock()
+	defer loadedLibrariesMutex.Unlock()
+	fn, ok := lib.funcs[name]
+	return fn, ok
+}
+
+// FunctionNames returns the symbols of every function that has been loaded from the library, sorted by name.
+func (lib *Library) FunctionNames() []string {
+	loadedLibrariesMutex.Lock()
+	defer loadedLibrariesMutex.Unlock()
+	return slices.Sorted(maps.Keys(lib.funcs))
+}
+
+// Close releases a reference to the library, which must be called once for each time that the library was loaded. The
+// library is unloaded once its last reference has been released, which invalidates all result caches, and no functions
+// from the library may be called afterward. The library's _PG_fini is called beforehand if its _PG_init was called, and
+// any error that it raises is returned.
+func (lib *Library) Close() error {
+	unlock := lockBackend()
+	defer unlock()
+	loadedLibrariesMutex.Lock()
+	defer loadedLibrariesMutex.Unlock()
+
+	if lib.refs <= 0 {
+		return nil
+	}
+	lib.refs--
+	if lib.refs > 0 {
+		return nil
+	}
+	if loadedLibraries[lib.path] == lib {
+		delete(loadedLibraries, lib.path)
+	}
+	if lib.stale {
+		if staleLibraries[lib.path]--; staleLibraries[lib.path] <= 0 {
+			delete(staleLibraries, lib.path)
+		}
+	}
+	libraryEpoch.Add(1)
+	// Workers would otherwise continue to run the library's code after it has been unloaded
+	stopLibraryBackgroundWorkers(lib.path)
+	var finiErr error
+	if lib.initialized {
+		lib.initialized = false
+		if finiPtr, err := lib.internal.Lookup("_PG_fini"); err == nil {
+			finiErr = lib.callProcedure(finiPtr)
+		}
+	}
+	if lib.dispatcher != nil {
+		lib.dispatcher.close()
+	}
+	closeErr := lib.internal.Close()
+	if len(lib.copyPath) > 0 {
+		removeLibraryCopy(lib.path, lib.copyPath)
+	}
+	return errors.Join(finiErr, closeErr)
+}
+
+// invalidateLibraries marks the loaded libraries at the given paths as stale, as their files have changed. Stale
+// libraries remain usable through the references that were already returned, while later loads open the file again.
+// Returns the paths of the libraries that were invalidated, sorted.
+func invalidateLibraries(paths []string) []string {
+	loadedLibrariesMutex.Lock()
+	defer loadedLibrariesMutex.Unlock()
+	var invalidated []string
+	for _, path := range paths {
+		path = resolveLibraryPath(path)
+		lib, ok := loadedLibraries[path]
+		if !ok {
+			continue
+		}
+		delete(loadedLibraries, path)
+		lib.stale = true
+		staleLibraries[path]++
+		invalidated = append(invalidated, path)
+	}
+	if len(invalidated) > 0 {
+		// Results that were cached for the stale libraries' functions must not be returned for the new ones
+		libraryEpoch.Add(1)
+	}
+	slices.Sort(invalidated)
+	return invalidated
+}
+
+// copyLibraryFile writes the library at the given path into a new temporary directory, keeping its file name, and
+// returns the path of the copy. The given contents are written when they're not nil, so that a library that was
+// verified is copied from the same contents that the verifier saw, rather than from a file that may have since changed.
+func copyLibraryFile(path string, contents []byte) (string, error) {
+	if contents == nil {
+		var err error
+		if contents, err = os.ReadFile(path); err != nil {
+			return "", &LoadError{
+				Kind: ErrLibraryNotFound,
+				File: path,
+				Err:  err,
+			}
+		}
+	}
+	dir, err := os.MkdirTemp("", "pg_extension-")
+	if err != nil {
+		return "", err
+	}
+	copyPath := filepath.Join(dir, filepath.Base(path))
+	if err = os.WriteFile(copyPath, contents, 0700); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+	return copyPath, nil
+}
+
+// removeLibraryCopy removes the temporary copy of the library at the given path, along with its directory. Nothing is
+// removed when the copy path is the library's own path.
+func removeLibraryCopy(path string, copyPath string) {
+	if copyPath != path {
+		_ = os.RemoveAll(filepath.Dir(copyPath))
+	}
 }
@@ -14,7 +14,7 @@
 
 //go:build darwin
 
-package main
+package pg_extension
 
 /*
 #cgo LDFLAGS: -ldl
@@ -24,28 +24,64 @@ package main
 import "C"
 
 import (
+	"errors"
 	"fmt"
-	"sync"
+	"os"
 	"unsafe"
 )
 
-// darwinLib is the Linux-specific implementation of InternalLoadedLibrary.
+// sharedLibrarySuffix is the file suffix that Postgres uses for extension libraries on MacOS. Versions before Postgres 16
+// used ".so" instead, which is still accepted when no ".dylib" exists.
+const sharedLibrarySuffix = ".dylib"
+
+// darwinLib is the MacOS-specific implementation of InternalLoadedLibrary.
 type darwinLib struct {
 	path   string
 	handle unsafe.Pointer
 }
 
 var _ InternalLoadedLibrary = (*darwinLib)(nil)
-var preloadStub sync.Once
 
-// loadLibraryInternal handles the loading of an extension's SO.
-func loadLibraryInternal(path string) (InternalLoadedLibrary, error) {
+// loadShimInternal returns the pg_extension library. Unlike the other platforms, the library is imported directly into
+// the binary, so this returns a handle to the binary itself.
+func loadShimInternal() (InternalLoadedLibrary, error) {
+	handle := C.dlopen(nil, C.RTLD_LAZY|C.RTLD_GLOBAL)
+	if handle == nil {
+		return nil, fmt.Errorf("cannot open the current binary\n%s", C.GoString(C.dlerror()))
+	}
+	return &darwinLib{
+		path:   "",
+		handle: handle,
+	}, nil
+}
+
+// shimLibraryPath returns the path of the binary, as the pg_extension library is imported directly into it.
+func shimLibraryPath() (string, error) {
+	return os.Executable()
+}
+
+// loadLibraryInternal handles the loading of an extension's SO. Deep binding is ignored, since libraries are linked with
+// two-level namespaces that already bind their references to the library that defines them.
+func loadLibraryInternal(path string, opts libraryOptions) (InternalLoadedLibrary, error) {
+	// The shim is already part of the binary, but we still load it first to match the other platforms
+	if _, err := loadShim(); err != nil {
+		return nil, err
+	}
+
 	pathC := C.CString(path)
 	defer C.free(unsafe.Pointer(pathC))
 
-	handle := C.dlopen(pathC, C.RTLD_LAZY|C.RTLD_GLOBAL)
+	flags := C.int(C.RTLD_LAZY | C.RTLD_GLOBAL)
+	if opts.local {
+		flags = C.RTLD_LAZY | C.RTLD_LOCAL
+	}
+	handle := C.dlopen(pathC, flags)
 	if handle == nil {
-		return nil, fmt.Errorf("error while loading extension `%s`\n%s", path, C.GoString(C.dlerror()))
+		return nil, &LoadError{
+			Kind: ErrLibraryNotFound,
+			File: path,
+			Err:  errors.New(C.GoString(C.dlerror())),
+		}
 	}
 	return &darwinLib{
 		path:   path,
@@ -60,7 +96,11 @@ func (u *darwinLib) Lookup(sym string) (uintptr, error) {
 
 	ptr := C.dlsym(u.handle, symC)
 	if ptr == nil {
-		return 0, fmt.Errorf("symbol %s not found", sym)
+		return 0, &LoadError{
+			Kind:   ErrMissingSymbol,
+			File:   u.path,
+			Symbol: sym,
+		}
 	}
 	return uintptr(ptr), nil
 }
@@ -14,23 +14,30 @@
 
 //go:build linux
 
-package main
+package pg_extension
 
 /*
 #cgo LDFLAGS: -ldl
+#define _GNU_SOURCE
 #include <dlfcn.h>
+#ifndef RTLD_DEEPBIND
+#define RTLD_DEEPBIND 0
+#endif
 #include <stdlib.h>
 */
 import "C"
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"runtime"
-	"sync"
 	"unsafe"
 )
 
+// sharedLibrarySuffix is the file suffix that Postgres uses for extension libraries on Linux.
+const sharedLibrarySuffix = ".so"
+
 // unixLib is the Linux-specific implementation of InternalLoadedLibrary.
 type unixLib struct {
 	path   string
@@ -38,29 +45,91 @@ type unixLib struct {
 }
 
 var _ InternalLoadedLibrary = (*unixLib)(nil)
-var preloadStub sync.Once
 
-// loadLibraryInternal handles the loading of an extension's SO.
-func loadLibraryInternal(path string) (InternalLoadedLibrary, error) {
-	preloadStub.Do(func() {
-		_, currentFileLocation, _, ok := runtime.Caller(0)
-		if !ok || len(currentFileLocation) == 0 {
-			panic("cannot find the directory where this file exists")
+// loadShimInternal loads the pg_extension library, which provides the Postgres functions that extensions import. It is
+// loaded globally so that the extensions may resolve their imports against it.
+func loadShimInternal() (InternalLoadedLibrary, error) {
+	libraryStr, err := shimLibraryPath()
+	if err != nil {
+		return nil, err
+	}
+	if err = checkLibraryLibc(libraryStr); err != nil {
+		return nil, err
+	}
+	libraryStrC := C.CString(libraryStr)
+	defer C.free(unsafe.Pointer(libraryStrC))
+	handle := C.dlopen(libraryStrC, dlopenFlags(libraryOptions{}))
+	if handle == nil {
+		return nil, &LoadError{
+			Kind: ErrLibraryNotFound,
+			File: libraryStr,
+			Err:  errors.New(C.GoString(C.dlerror())),
 		}
-		libraryStr := filepath.Join(filepath.Dir(currentFileLocation), "output", "pg_extension.so")
-		libraryStrC := C.CString(libraryStr)
-		defer C.free(unsafe.Pointer(libraryStrC))
-		if C.dlopen(libraryStrC, C.RTLD_LAZY|C.RTLD_GLOBAL) == nil {
-			panic("cannot find the pg_extension library")
+	}
+	return &unixLib{
+		path:   libraryStr,
+		handle: handle,
+	}, nil
+}
+
+// dlopenFlags returns the flags that libraries are opened with. Libraries are opened globally by default so that
+// extensions may resolve their imports against the shim, along with any libraries that they depend on. Symbols are bound
+// lazily with glibc, but musl does not support lazy binding and rejects libraries with missing symbols when they're
+// opened, so they are bound immediately there to match. Missing symbols are reported beforehand either way (see
+// missingSymbols).
+func dlopenFlags(opts libraryOptions) C.int {
+	flags := C.int(C.RTLD_LAZY)
+	if hostLibc() == libcMusl {
+		flags = C.RTLD_NOW
+	}
+	if opts.local {
+		flags |= C.RTLD_LOCAL
+	} else {
+		flags |= C.RTLD_GLOBAL
+	}
+	if opts.deepBind {
+		flags |= C.RTLD_DEEPBIND
+	}
+	return flags
+}
+
+// shimLibraryPath returns the path of the pg_extension library. The library that was embedded for this platform is
+// preferred, followed by the library that was built next to the source.
+func shimLibraryPath() (string, error) {
+	if embeddedPath, err := embeddedShimPath("pg_extension.so"); err != nil || len(embeddedPath) > 0 {
+		return embeddedPath, err
+	}
+	_, currentFileLocation, _, ok := runtime.Caller(0)
+	if !ok || len(currentFileLocation) == 0 {
+		return "", fmt.Errorf("cannot find the directory where this file exists")
+	}
+	return filepath.Join(filepath.Dir(currentFileLocation), "output", "pg_extension.so"), nil
+}
+
+// loadLibraryInternal handles the loading of an extension's SO.
+func loadLibraryInternal(path string, opts libraryOptions) (InternalLoadedLibrary, error) {
+	if _, err := loadShim(); err != nil {
+		return nil, err
+	}
+	// musl does not define RTLD_DEEPBIND, and silently ignoring it would leave the library's symbols shadowed
+	if opts.deepBind && (C.RTLD_DEEPBIND == 0 || hostLibc() == libcMusl) {
+		return nil, &LoadError{
+			Kind: ErrIncompatibleLibrary,
+			File: path,
+			Err:  errors.New("deep binding is only supported with glibc"),
 		}
-	})
+	}
 
 	pathC := C.CString(path)
 	defer C.free(unsafe.Pointer(pathC))
 
-	handle := C.dlopen(pathC, C.RTLD_LAZY|C.RTLD_GLOBAL)
+	handle := C.dlopen(pathC, dlopenFlags(opts))
 	if handle == nil {
-		return nil, fmt.Errorf("error while loading extension `%s`\n%s", path, C.GoString(C.dlerror()))
+		return nil, &LoadError{
+			Kind: ErrLibraryNotFound,
+			File: path,
+			Err:  errors.New(C.GoString(C.dlerror())),
+		}
 	}
 	return &unixLib{
 		path:   path,
@@ -75,7 +144,11 @@ func (u *unixLib) Lookup(sym string) (uintptr, error) {
 
 	ptr := C.dlsym(u.handle, symC)
 	if ptr == nil {
-		return 0, fmt.Errorf("symbol %s not found", sym)
+		return 0, &LoadError{
+			Kind:   ErrMissingSymbol,
+			File:   u.path,
+			Symbol: sym,
+		}
 	}
 	return uintptr(ptr), nil
 }
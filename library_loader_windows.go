@@ -14,65 +14,198 @@
 
 //go:build windows
 
-package main
+package pg_extension
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"runtime"
-	"sync"
 	"syscall"
 	"unsafe"
 )
 
+// sharedLibrarySuffix is the file suffix that Postgres uses for extension libraries on Windows.
+const sharedLibrarySuffix = ".dll"
+
+const (
+	// loadLibrarySearchDllLoadDir searches the directory of the DLL that is being loaded for its dependencies.
+	loadLibrarySearchDllLoadDir = 0x00000100
+	// loadLibrarySearchDefaultDirs searches the directory of the executable, the directories that were added through
+	// AddDllDirectory, and System32.
+	loadLibrarySearchDefaultDirs = 0x00001000
+)
+
+var (
+	kernel32               = syscall.NewLazyDLL("kernel32.dll")
+	procLoadLibraryExW     = kernel32.NewProc("LoadLibraryExW")
+	procAddDllDirectory    = kernel32.NewProc("AddDllDirectory")
+	procRemoveDllDirectory = kernel32.NewProc("RemoveDllDirectory")
+)
+
 // winLib is the Windows-specific implementation of InternalLoadedLibrary.
-type winLib struct{ dll syscall.Handle }
+type winLib struct {
+	path string
+	dll  syscall.Handle
+	// symbolNames maps the names that symbols are looked up by to the names that they're exported under (see
+	// peSymbolNames). Symbols that are missing from the map, including every symbol when the export directory could not
+	// be read, are looked up by their given name.
+	symbolNames map[string]string
+}
 
 var _ InternalLoadedLibrary = (*winLib)(nil)
-var addPGBinDir = &sync.Once{}
-
-// loadLibraryInternal handles the loading of an extension's DLL.
-func loadLibraryInternal(path string) (InternalLoadedLibrary, error) {
-	addPGBinDir.Do(func() {
-		_, currentFileLocation, _, ok := runtime.Caller(0)
-		if !ok || len(currentFileLocation) == 0 {
-			panic("cannot find the directory where this file exists")
+
+// loadShimInternal loads the pg_extension library, which provides the Postgres functions that extensions import. The
+// library's directory is also added to the DLL search path for the life of the process, so that the postgres.exe
+// forwarder may be found by every extension.
+func loadShimInternal() (InternalLoadedLibrary, error) {
+	dllPath, err := shimLibraryPath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = addDllDirectory(filepath.Dir(dllPath)); err != nil {
+		return nil, &LoadError{
+			Kind: ErrLibraryNotFound,
+			File: dllPath,
+			Err:  err,
+		}
+	}
+	d, err := syscall.LoadLibrary(dllPath)
+	if err != nil {
+		return nil, &LoadError{
+			Kind: ErrLibraryNotFound,
+			File: dllPath,
+			Err:  err,
+		}
+	}
+	return newWinLib(dllPath, d), nil
+}
+
+// shimLibraryPath returns the path of the pg_extension library. The library that was embedded for this platform is
+// preferred, followed by the library that was built next to the source.
+func shimLibraryPath() (string, error) {
+	if embeddedPath, err := embeddedShimPath("pg_extension.dll"); err != nil || len(embeddedPath) > 0 {
+		return embeddedPath, err
+	}
+	_, currentFileLocation, _, ok := runtime.Caller(0)
+	if !ok || len(currentFileLocation) == 0 {
+		return "", fmt.Errorf("cannot find the directory where this file exists")
+	}
+	return filepath.Join(filepath.Dir(currentFileLocation), "output", "pg_extension.dll"), nil
+}
+
+// loadLibraryInternal handles the loading of an extension's DLL. Its dependencies are searched for within its own
+// directory, the directories from WithDllDirectories, and the default directories, which include the shim's directory
+// (see loadShimInternal). The directories from WithDllDirectories are removed from the search path once the DLL has
+// loaded. The other options are ignored, since a DLL's imports are always bound to the DLL that they were linked
+// against.
+func loadLibraryInternal(path string, opts libraryOptions) (InternalLoadedLibrary, error) {
+	if _, err := loadShim(); err != nil {
+		return nil, err
+	}
+	// LOAD_LIBRARY_SEARCH_DLL_LOAD_DIR requires an absolute path
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	var cookies []uintptr
+	defer func() {
+		for _, cookie := range cookies {
+			_, _, _ = procRemoveDllDirectory.Call(cookie)
 		}
-		dllDir := filepath.Join(filepath.Dir(currentFileLocation), "output")
-		dirPtr, err := syscall.UTF16PtrFromString(dllDir)
+	}()
+	for _, dir := range opts.dllDirectories {
+		cookie, err := addDllDirectory(dir)
 		if err != nil {
-			panic(err)
+			return nil, &LoadError{
+				Kind: ErrLibraryNotFound,
+				File: path,
+				Err:  err,
+			}
 		}
-		_, _, _ = syscall.MustLoadDLL("kernel32.dll").MustFindProc("SetDllDirectoryW").Call(uintptr(unsafe.Pointer(dirPtr)))
-		_, _ = syscall.LoadLibrary(filepath.Join(dllDir, "pg_extension.dll"))
-	})
-	d, err := syscall.LoadLibrary(path)
+		cookies = append(cookies, cookie)
+	}
+	pathPtr, err := syscall.UTF16PtrFromString(absPath)
 	if err != nil {
 		return nil, err
 	}
-	return &winLib{dll: d}, nil
+	d, _, callErr := procLoadLibraryExW.Call(uintptr(unsafe.Pointer(pathPtr)), 0,
+		loadLibrarySearchDllLoadDir|loadLibrarySearchDefaultDirs)
+	if d == 0 {
+		err = callErr
+		// Windows only reports that a module is missing without naming it, which may be any of the DLL's dependencies
+		var errno syscall.Errno
+		if errors.As(callErr, &errno) && errno == syscall.ERROR_MOD_NOT_FOUND {
+			if missing, ok := findMissingDLL(absPath, dllSearchDirectories(absPath, opts)); ok {
+				err = fmt.Errorf("%w: `%s` depends on `%s`, which is not within the DLL search path",
+					callErr, missing.requiredBy, missing.name)
+			}
+		}
+		return nil, &LoadError{
+			Kind: ErrLibraryNotFound,
+			File: path,
+			Err:  err,
+		}
+	}
+	return newWinLib(path, syscall.Handle(d)), nil
+}
+
+// addDllDirectory adds the directory to the DLL search path, returning the cookie that removes it.
+func addDllDirectory(dir string) (uintptr, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return 0, err
+	}
+	dirPtr, err := syscall.UTF16PtrFromString(absDir)
+	if err != nil {
+		return 0, err
+	}
+	cookie, _, callErr := procAddDllDirectory.Call(uintptr(unsafe.Pointer(dirPtr)))
+	if cookie == 0 {
+		return 0, fmt.Errorf("cannot add `%s` to the DLL search path: %w", absDir, callErr)
+	}
+	return cookie, nil
+}
+
+// dllSearchDirectories returns the directories that are searched for the dependencies of the DLL at the given path, in
+// the order that they're searched.
+func dllSearchDirectories(path string, opts libraryOptions) []string {
+	dirs := []string{filepath.Dir(path)}
+	if exePath, err := os.Executable(); err == nil {
+		dirs = append(dirs, filepath.Dir(exePath))
+	}
+	if shimPath, err := shimLibraryPath(); err == nil {
+		dirs = append(dirs, filepath.Dir(shimPath))
+	}
+	dirs = append(dirs, opts.dllDirectories...)
+	if systemRoot := os.Getenv("SystemRoot"); len(systemRoot) > 0 {
+		dirs = append(dirs, filepath.Join(systemRoot, "System32"))
+	}
+	return dirs
+}
+
+// newWinLib returns a winLib for the DLL that was just loaded, reading its export directory so that its symbols may be
+// found regardless of how their names were decorated.
+func newWinLib(path string, dll syscall.Handle) *winLib {
+	symbolNames, _ := peSymbolNames(path)
+	return &winLib{path: path, dll: dll, symbolNames: symbolNames}
 }
 
 // Lookup implements the interface InternalLoadedLibrary.
 func (w *winLib) Lookup(sym string) (uintptr, error) {
-	candidates := []string{
-		sym,
-		"_" + sym,
-		sym + "@0",
-		"_" + sym + "@0",
+	name := sym
+	if exportedName, ok := w.symbolNames[sym]; ok {
+		name = exportedName
 	}
-	for bytes := 4; bytes <= 64; bytes += 4 {
-		candidates = append(candidates,
-			fmt.Sprintf("%s@%d", sym, bytes),
-			fmt.Sprintf("_%s@%d", sym, bytes))
+	if p, err := syscall.GetProcAddress(w.dll, name); err == nil {
+		return p, nil
 	}
-
-	for _, name := range candidates {
-		if p, err := syscall.GetProcAddress(w.dll, name); err == nil {
-			return p, nil
-		}
+	return 0, &LoadError{
+		Kind:   ErrMissingSymbol,
+		File:   w.path,
+		Symbol: sym,
 	}
-	return 0, fmt.Errorf("symbol %s not found", sym)
 }
 
 // Close implements the interface InternalLoadedLibrary.
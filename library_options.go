@@ -0,0 +1,134 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// LibraryOption changes how a library is opened by the dynamic loader.
+type LibraryOption func(*libraryOptions)
+
+// libraryOptions are the options that a library is opened with.
+type libraryOptions struct {
+	// local is set when the library's symbols are not made available to the libraries that are loaded after it.
+	local bool
+	// deepBind is set when the library's references to its own symbols are bound to itself, rather than to the symbols of
+	// the same name that were loaded before it.
+	deepBind bool
+	// dllDirectories are searched for the DLLs that the library depends on.
+	dllDirectories []string
+	// threadAffinity is set when every call into the library is made from a single, dedicated thread.
+	threadAffinity bool
+	// sharedPreload is set when the library is initialized as though it were listed in shared_preload_libraries.
+	sharedPreload bool
+}
+
+// configuredLibraryOptions contains the options that were set through SetLibraryOptions, keyed by the library's name.
+// Access is gated by loadedLibrariesMutex.
+var configuredLibraryOptions = make(map[string][]LibraryOption)
+
+// WithLocalSymbols opens the library with RTLD_LOCAL, so that its symbols do not satisfy the references of libraries that
+// are loaded after it. Libraries are otherwise opened with RTLD_GLOBAL, which lets the symbols of one extension shadow
+// those of another with the same name. A library that is opened this way may still reference the Postgres functions of
+// the shim, but libraries that link against its symbols, such as the transforms of another extension, cannot be loaded.
+// Separate link-map namespaces (dlmopen) are not supported, since a library within another namespace cannot reference
+// the shim, and the shim cannot be loaded a second time as it contains the Go runtime. This has no effect on Windows,
+// where a library's imports are always bound to the library that they were linked against.
+func WithLocalSymbols() LibraryOption {
+	return func(opts *libraryOptions) {
+		opts.local = true
+	}
+}
+
+// WithDeepBind opens the library with RTLD_DEEPBIND, so that the library's references to its own symbols are bound to
+// itself even when a library that was loaded earlier has a symbol of the same name. This is only supported on Linux
+// with glibc, as loading fails with ErrIncompatibleLibrary on musl. It has no effect on macOS and Windows, where a
+// library's references are already bound to the library that they were linked against.
+func WithDeepBind() LibraryOption {
+	return func(opts *libraryOptions) {
+		opts.deepBind = true
+	}
+}
+
+// WithDllDirectories adds directories to the search path for the DLLs that the library depends on, such as the GEOS and
+// PROJ libraries of PostGIS, which are often within the bin directory of a Postgres installation. The directories are
+// only searched while the library is loaded, so libraries that are loaded for other extensions do not find them. The
+// library's own directory, the directory of the shim, the directory of the executable, and System32 are always
+// searched, while the directories within PATH are not. This has no effect on platforms other than Windows.
+func WithDllDirectories(dirs ...string) LibraryOption {
+	return func(opts *libraryOptions) {
+		opts.dllDirectories = append(opts.dllDirectories, dirs...)
+	}
+}
+
+// WithThreadAffinity makes every call into the library from a single, dedicated OS thread, one call at a time, including
+// the calls to _PG_init and _PG_fini. Postgres runs each backend with a single thread, so most extensions keep their
+// state in static variables without any locking, and some keep it in thread-local variables that must be seen by every
+// call. Such libraries must be loaded with this option when they're called by multiple goroutines. Libraries are
+// otherwise called concurrently from whichever thread makes the call, which should only be relied upon for libraries
+// that are known to be thread-safe. Each call is handed to the library's thread and back, which adds the cost of two
+// goroutine handoffs to every call.
+func WithThreadAffinity() LibraryOption {
+	return func(opts *libraryOptions) {
+		opts.threadAffinity = true
+	}
+}
+
+// WithSharedPreload initializes the library as though it were listed in shared_preload_libraries, which extensions such
+// as pg_cron require before they register their background workers (see StartBackgroundWorkers). Extensions check
+// process_shared_preload_libraries_in_progress within _PG_init, which is only set for libraries that are loaded with
+// this option. Some extensions also request shared memory when it's set, so this should only be given to the libraries
+// that the host would have preloaded.
+func WithSharedPreload() LibraryOption {
+	return func(opts *libraryOptions) {
+		opts.sharedPreload = true
+	}
+}
+
+// SetLibraryOptions sets the options of the library with the given name, where the name is the library's file name
+// without its directory or extension, such as "postgis-3". These apply whenever the library is loaded without options
+// of its own, which includes the libraries that are loaded for an extension. Setting no options restores the defaults.
+// A library is only opened once however many times it's loaded, so this only applies to libraries that are loaded
+// afterward.
+func SetLibraryOptions(name string, options ...LibraryOption) {
+	loadedLibrariesMutex.Lock()
+	defer loadedLibrariesMutex.Unlock()
+	if len(options) == 0 {
+		delete(configuredLibraryOptions, name)
+	} else {
+		configuredLibraryOptions[name] = slices.Clone(options)
+	}
+}
+
+// newLibraryOptions returns the options of the library at the given path. The options that were given take precedence
+// over those that were set through SetLibraryOptions. The loadedLibrariesMutex must be held by the caller.
+func newLibraryOptions(path string, options []LibraryOption) libraryOptions {
+	if len(options) == 0 {
+		options = configuredLibraryOptions[libraryName(path)]
+	}
+	var opts libraryOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	return opts
+}
+
+// libraryName returns the name of the library at the given path, which is its file name without its extension.
+func libraryName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
@@ -0,0 +1,98 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// LibrarySymbolKind classifies a symbol that a library exports, according to the conventions that Postgres uses.
+type LibrarySymbolKind uint8
+
+const (
+	// LibrarySymbolOther is a symbol that does not follow any of the conventions, such as a global variable or a
+	// function that is only called from C.
+	LibrarySymbolOther LibrarySymbolKind = iota
+	// LibrarySymbolMagic is Pg_magic_func, which reports the version of Postgres that the library was built for.
+	LibrarySymbolMagic
+	// LibrarySymbolInit is _PG_init, which is called once the library has been loaded.
+	LibrarySymbolInit
+	// LibrarySymbolFini is _PG_fini, which is called before the library is unloaded.
+	LibrarySymbolFini
+	// LibrarySymbolFunctionInfo is a "pg_finfo_" record, which declares the function of the same name (without the
+	// prefix) as a version-1 function.
+	LibrarySymbolFunctionInfo
+	// LibrarySymbolFunction is a function that has a "pg_finfo_" record, which may be called from SQL.
+	LibrarySymbolFunction
+)
+
+// LibrarySymbol is a symbol that is exported by a library.
+type LibrarySymbol struct {
+	Name string
+	Kind LibrarySymbolKind
+}
+
+// Symbols returns every symbol that the library exports, sorted by name. Unlike the functions that are found through
+// an extension's SQL files, this includes every function that may be called from SQL, as those have a "pg_finfo_"
+// record. The symbols are read from the library's file rather than from memory, so they're those of the ELF dynamic
+// symbol table, the Mach-O symbol table, or the PE export directory.
+func (lib *Library) Symbols() ([]LibrarySymbol, error) {
+	exports, err := readExports(lib.path)
+	if err != nil {
+		return nil, err
+	}
+	symbols := make([]LibrarySymbol, 0, len(exports))
+	for _, name := range slices.Sorted(maps.Keys(exports)) {
+		symbol := LibrarySymbol{Name: name}
+		switch {
+		case name == "Pg_magic_func":
+			symbol.Kind = LibrarySymbolMagic
+		case name == "_PG_init":
+			symbol.Kind = LibrarySymbolInit
+		case name == "_PG_fini":
+			symbol.Kind = LibrarySymbolFini
+		case strings.HasPrefix(name, "pg_finfo_"):
+			symbol.Kind = LibrarySymbolFunctionInfo
+		default:
+			if _, ok := exports["pg_finfo_"+name]; ok {
+				symbol.Kind = LibrarySymbolFunction
+			}
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols, nil
+}
+
+// LibraryHooks returns the Postgres hooks that the library at the given path references, such as "ExecutorStart_hook",
+// sorted by name. Hooks are the global variables of Postgres whose names end with "_hook", which libraries generally
+// replace within _PG_init. The shim does not provide hooks, so this lets hosts explain why such a library cannot be
+// loaded, as MissingSymbols would report these among the other symbols. Libraries that do not record which of their
+// imports come from Postgres, such as Mach-O libraries that use a flat namespace, do not report any hooks.
+func LibraryHooks(path string) ([]string, error) {
+	symbols, err := readLibrarySymbols(path)
+	if err != nil {
+		return nil, err
+	}
+	var hooks []string
+	for _, name := range symbols.imports {
+		if strings.HasSuffix(name, "_hook") {
+			hooks = append(hooks, name)
+		}
+	}
+	slices.Sort(hooks)
+	return slices.Compact(hooks), nil
+}
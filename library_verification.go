@@ -0,0 +1,175 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LibraryVerifier decides whether a library may be loaded, based on the contents of its file.
+type LibraryVerifier interface {
+	// VerifyLibrary returns an error if the library at the given path, whose file has the given contents, must not be
+	// loaded.
+	VerifyLibrary(path string, contents []byte) error
+}
+
+// libraryVerifier is the verifier that was set through SetLibraryVerifier, which is nil when every library may be
+// loaded. Access is gated by loadedLibrariesMutex.
+var libraryVerifier LibraryVerifier
+
+// SetLibraryVerifier sets the verifier that every library must pass before it's loaded, including libraries that were
+// compiled to WebAssembly and those that are loaded by sandbox workers. A library that fails is rejected with a
+// LoadError of ErrLibraryNotTrusted, and is never opened, so none of its code runs. Setting nil allows every library,
+// which is the default. This only applies to libraries that are loaded afterward, as those that are already loaded
+// remain so.
+//
+// The file is read before the dynamic loader opens it again by its path, so the directories that libraries are loaded
+// from must not be writable by anyone who is not trusted.
+func SetLibraryVerifier(verifier LibraryVerifier) {
+	loadedLibrariesMutex.Lock()
+	defer loadedLibrariesMutex.Unlock()
+	libraryVerifier = verifier
+}
+
+// verifyLibrary checks the library at the given path with the verifier, if one has been set, returning the contents
+// that were verified. The contents are nil when there's no verifier. This expects the library mutex to be held.
+func verifyLibrary(path string) ([]byte, error) {
+	if libraryVerifier == nil {
+		return nil, nil
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &LoadError{
+			Kind: ErrLibraryNotFound,
+			File: path,
+			Err:  err,
+		}
+	}
+	if err = libraryVerifier.VerifyLibrary(path, contents); err != nil {
+		return nil, &LoadError{
+			Kind: ErrLibraryNotTrusted,
+			File: path,
+			Err:  err,
+		}
+	}
+	return contents, nil
+}
+
+// verifyLibraries checks each library at the given paths with the verifier, if one has been set. This is used for the
+// libraries that are loaded by another process, such as a sandbox worker, which cannot run the verifier itself.
+func verifyLibraries(paths []string) error {
+	loadedLibrariesMutex.Lock()
+	defer loadedLibrariesMutex.Unlock()
+	for _, path := range paths {
+		if _, err := verifyLibrary(resolveLibraryPath(path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChecksumAllowlist is a LibraryVerifier that only allows the libraries whose SHA-256 checksum is within the list.
+type ChecksumAllowlist struct {
+	checksums map[[sha256.Size]byte]struct{}
+}
+
+var _ LibraryVerifier = (*ChecksumAllowlist)(nil)
+
+// NewChecksumAllowlist returns an allowlist of the given SHA-256 checksums, which are written in hexadecimal, such as
+// the output of sha256sum.
+func NewChecksumAllowlist(checksums ...string) (*ChecksumAllowlist, error) {
+	allowlist := &ChecksumAllowlist{checksums: make(map[[sha256.Size]byte]struct{}, len(checksums))}
+	for _, checksum := range checksums {
+		decoded, err := hex.DecodeString(strings.TrimSpace(checksum))
+		if err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("`%s` is not a SHA-256 checksum", checksum)
+		}
+		allowlist.checksums[[sha256.Size]byte(decoded)] = struct{}{}
+	}
+	return allowlist, nil
+}
+
+// VerifyLibrary implements the interface LibraryVerifier.
+func (allowlist *ChecksumAllowlist) VerifyLibrary(path string, contents []byte) error {
+	checksum := sha256.Sum256(contents)
+	if _, ok := allowlist.checksums[checksum]; !ok {
+		return fmt.Errorf("checksum %s is not within the allowlist", hex.EncodeToString(checksum[:]))
+	}
+	return nil
+}
+
+// SignatureVerifier is a LibraryVerifier that only allows the libraries that were signed by one of its Ed25519 keys.
+// Each signature is detached, within a file next to the library that has the same name with .sig appended, such as
+// uuid-ossp.so.sig. The file holds either the 64 bytes of the signature, or the signature encoded in base64.
+type SignatureVerifier struct {
+	PublicKeys []ed25519.PublicKey
+}
+
+var _ LibraryVerifier = SignatureVerifier{}
+
+// VerifyLibrary implements the interface LibraryVerifier.
+func (verifier SignatureVerifier) VerifyLibrary(path string, contents []byte) error {
+	sigPath := path + ".sig"
+	signature, err := os.ReadFile(sigPath)
+	if err != nil {
+		return fmt.Errorf("could not read signature: %w", err)
+	}
+	if len(signature) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+		if err != nil || len(decoded) != ed25519.SignatureSize {
+			return fmt.Errorf("`%s` does not contain an Ed25519 signature", sigPath)
+		}
+		signature = decoded
+	}
+	for _, key := range verifier.PublicKeys {
+		if len(key) == ed25519.PublicKeySize && ed25519.Verify(key, contents, signature) {
+			return nil
+		}
+	}
+	return errors.New("library was not signed by a trusted key")
+}
+
+// AnyLibraryVerifier returns a LibraryVerifier that allows the libraries that are allowed by any of the given
+// verifiers, such as a library that is either within a ChecksumAllowlist or signed for a SignatureVerifier.
+func AnyLibraryVerifier(verifiers ...LibraryVerifier) LibraryVerifier {
+	return anyLibraryVerifier(verifiers)
+}
+
+// anyLibraryVerifier is returned by AnyLibraryVerifier.
+type anyLibraryVerifier []LibraryVerifier
+
+// VerifyLibrary implements the interface LibraryVerifier.
+func (verifiers anyLibraryVerifier) VerifyLibrary(path string, contents []byte) error {
+	errs := make([]error, 0, len(verifiers))
+	for _, verifier := range verifiers {
+		err := verifier.VerifyLibrary(path, contents)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return errors.New("no verifier allows the library")
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,236 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrLibraryNotFound is the cause of a LoadError when a library could not be found or opened.
+	ErrLibraryNotFound = errors.New("library not found")
+	// ErrMissingSymbol is the cause of a LoadError when a library does not export a function that is referenced by its
+	// extension.
+	ErrMissingSymbol = errors.New("missing symbol")
+	// ErrIncompatibleMagic is the cause of a LoadError when a library's magic block is missing or does not match the
+	// supported version of Postgres.
+	ErrIncompatibleMagic = errors.New("incompatible magic block")
+	// ErrControlParse is the cause of a LoadError when an extension's control file is invalid.
+	ErrControlParse = errors.New("invalid control file")
+	// ErrUnsupportedFunction is the cause of a LoadError when a function does not use the V1 calling convention, which
+	// is the only convention that may be called.
+	ErrUnsupportedFunction = errors.New("unsupported calling convention")
+	// ErrMissingPrerequisite is the cause of a LoadError when an extension requires other extensions that are not
+	// available.
+	ErrMissingPrerequisite = errors.New("missing prerequisite")
+	// ErrDependencyCycle is the cause of a LoadError when extensions require each other.
+	ErrDependencyCycle = errors.New("dependency cycle")
+	// ErrInitFailed is the cause of a LoadError when a library's _PG_init raises an error or crashes.
+	ErrInitFailed = errors.New("library initialization failed")
+	// ErrExtensionNotAvailable is the cause of a LoadError when an extension's control file cannot be found.
+	ErrExtensionNotAvailable = errors.New("extension not available")
+	// ErrLibraryNotTrusted is the cause of a LoadError when a library is rejected by the verifier that was set through
+	// SetLibraryVerifier.
+	ErrLibraryNotTrusted = errors.New("library not trusted")
+	// ErrIncompatibleLibrary is the cause of a LoadError when a library exists but cannot be loaded by this process, such
+	// as a library that was built for another architecture.
+	ErrIncompatibleLibrary = errors.New("incompatible library")
+)
+
+// LoadError is returned when an extension or its library fails to load. The cause may be checked by using errors.Is
+// with ErrLibraryNotFound, ErrMissingSymbol, ErrIncompatibleMagic, ErrUnsupportedFunction, ErrControlParse,
+// ErrMissingPrerequisite, ErrDependencyCycle, ErrInitFailed, ErrExtensionNotAvailable, ErrLibraryNotTrusted, or
+// ErrIncompatibleLibrary.
+type LoadError struct {
+	// Kind is the cause of the failure, which is one of the Err variables.
+	Kind error
+	// Extension is the name of the extension that was being loaded, if known.
+	Extension string
+	// File is the library or control file that failed to load.
+	File string
+	// Symbol is the symbol that could not be found for ErrMissingSymbol, or the function for ErrUnsupportedFunction.
+	Symbol string
+	// Symbols are the Postgres symbols that the library references but the shim does not export, for ErrMissingSymbol
+	// when the failure was found by scanning the library before it was loaded.
+	Symbols []string
+	// Prerequisites are the required extensions that are not available for ErrMissingPrerequisite, or the extensions
+	// that form the cycle for ErrDependencyCycle, beginning and ending with the same extension.
+	Prerequisites []string
+	// Err is the underlying error, such as the message from the dynamic loader.
+	Err error
+}
+
+// Error implements the error interface.
+func (le *LoadError) Error() string {
+	var msg string
+	switch le.Kind {
+	case ErrLibraryNotFound:
+		msg = fmt.Sprintf(`could not load library "%s"`, le.File)
+	case ErrMissingSymbol:
+		if len(le.Symbols) > 0 {
+			msg = fmt.Sprintf(`library "%s" references symbols that are not provided: %s`, le.File,
+				strings.Join(le.Symbols, ", "))
+		} else {
+			msg = fmt.Sprintf(`could not find function "%s" in file "%s"`, le.Symbol, le.File)
+		}
+	case ErrIncompatibleMagic:
+		msg = fmt.Sprintf(`incompatible library "%s"`, le.File)
+	case ErrUnsupportedFunction:
+		msg = fmt.Sprintf(`cannot call function "%s" in file "%s"`, le.Symbol, le.File)
+	case ErrMissingPrerequisite:
+		if len(le.Prerequisites) == 1 {
+			msg = fmt.Sprintf(`required extension "%s" is not installed`, le.Prerequisites[0])
+		} else {
+			msg = fmt.Sprintf(`required extensions are not installed: %s`, strings.Join(le.Prerequisites, ", "))
+		}
+	case ErrDependencyCycle:
+		msg = fmt.Sprintf(`cyclic dependency detected between extensions: %s`, strings.Join(le.Prerequisites, " -> "))
+	case ErrInitFailed:
+		msg = fmt.Sprintf(`could not initialize library "%s"`, le.File)
+	case ErrExtensionNotAvailable:
+		msg = fmt.Sprintf(`extension "%s" is not available`, le.Extension)
+	case ErrLibraryNotTrusted:
+		msg = fmt.Sprintf(`library "%s" is not trusted`, le.File)
+	case ErrIncompatibleLibrary:
+		msg = fmt.Sprintf(`library "%s" cannot be loaded by this process`, le.File)
+	case ErrControlParse:
+		// Control file errors already describe the file, so they're used as-is
+		if le.Err != nil {
+			msg = le.Err.Error()
+		} else {
+			msg = fmt.Sprintf(`invalid control file "%s"`, le.File)
+		}
+	default:
+		msg = fmt.Sprintf(`could not load "%s"`, le.File)
+	}
+	if le.Err != nil && le.Kind != ErrControlParse {
+		msg = fmt.Sprintf("%s: %s", msg, le.Err.Error())
+	}
+	if len(le.Extension) > 0 && le.Kind != ErrExtensionNotAvailable {
+		msg = fmt.Sprintf("extension `%s`: %s", le.Extension, msg)
+	}
+	return msg
+}
+
+// Unwrap returns the kind of failure along with the underlying error.
+func (le *LoadError) Unwrap() []error {
+	if le.Err == nil {
+		return []error{le.Kind}
+	}
+	return []error{le.Kind, le.Err}
+}
+
+// SQLState returns the SQLSTATE that Postgres reports for the failure.
+func (le *LoadError) SQLState() string {
+	switch le.Kind {
+	case ErrLibraryNotFound:
+		// undefined_file
+		return "58P01"
+	case ErrMissingSymbol, ErrUnsupportedFunction:
+		// undefined_function
+		return "42883"
+	case ErrControlParse:
+		// syntax_error
+		return "42601"
+	case ErrMissingPrerequisite:
+		// undefined_object
+		return "42704"
+	case ErrDependencyCycle:
+		// invalid_recursion
+		return "42P19"
+	case ErrInitFailed:
+		// The error that was raised by _PG_init is reported as-is
+		var pgErr PostgresError
+		if errors.As(le.Err, &pgErr) && len(pgErr.Code) == 5 {
+			return pgErr.Code
+		}
+		return "XX000"
+	case ErrExtensionNotAvailable:
+		// feature_not_supported
+		return "0A000"
+	case ErrLibraryNotTrusted:
+		// insufficient_privilege
+		return "42501"
+	default:
+		// internal_error
+		return "XX000"
+	}
+}
+
+// LoadReport is returned by LoadExtensions and LoadExtensionsWithOptions when some extensions or directories could not
+// be loaded, alongside every extension that loaded successfully. Each failure only affects its own extension or
+// directory.
+type LoadReport struct {
+	// Failures are sorted by directory and then extension.
+	Failures []LoadFailure
+}
+
+// LoadFailure is a single extension or directory that could not be loaded.
+type LoadFailure struct {
+	// Extension is the extension that failed to load, which is empty when an entire directory could not be read.
+	Extension string
+	// Dir is the directory that the extension's files are in, which is empty for bundled extensions.
+	Dir string
+	Err error
+}
+
+// Error implements the error interface.
+func (report *LoadReport) Error() string {
+	messages := make([]string, len(report.Failures))
+	for i, failure := range report.Failures {
+		messages[i] = failure.Error()
+	}
+	if len(messages) == 1 {
+		return messages[0]
+	}
+	return fmt.Sprintf("%d failures while loading extensions: %s", len(messages), strings.Join(messages, "; "))
+}
+
+// Unwrap returns the error of each failure.
+func (report *LoadReport) Unwrap() []error {
+	errs := make([]error, len(report.Failures))
+	for i, failure := range report.Failures {
+		errs[i] = failure.Err
+	}
+	return errs
+}
+
+// Error implements the error interface.
+func (failure LoadFailure) Error() string {
+	if len(failure.Extension) == 0 {
+		if len(failure.Dir) == 0 {
+			return fmt.Sprintf("cannot read bundled extensions: %s", failure.Err.Error())
+		}
+		return fmt.Sprintf("cannot read directory `%s`: %s", failure.Dir, failure.Err.Error())
+	}
+	// LoadErrors already name their extension
+	var loadErr *LoadError
+	if errors.As(failure.Err, &loadErr) && loadErr.Extension == failure.Extension {
+		return failure.Err.Error()
+	}
+	return fmt.Sprintf("extension `%s`: %s", failure.Extension, failure.Err.Error())
+}
+
+// withExtension sets the extension of the error if it is a LoadError that does not yet have one. All other errors are
+// returned as-is.
+func withExtension(err error, extension string) error {
+	var loadErr *LoadError
+	if errors.As(err, &loadErr) && len(loadErr.Extension) == 0 {
+		loadErr.Extension = extension
+	}
+	return err
+}
@@ -0,0 +1,168 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+
+extern void pgextHostLog(PgExtErrorData* edata, int32_t pid);
+
+static inline PgExtLogger* NewHostLogger() {
+	PgExtLogger* logger = (PgExtLogger*)malloc(sizeof(PgExtLogger));
+	logger->log = (void (*)(const PgExtErrorData*, int32_t))pgextHostLog;
+	return logger;
+}
+*/
+import "C"
+import (
+	"fmt"
+	"os"
+	"sync"
+	"unsafe"
+)
+
+// LogLevel is the level of a message that an extension reports through ereport or elog, using the values of Postgres.
+// Levels are ordered the same as client_min_messages, so LOG is below NOTICE.
+type LogLevel int32
+
+const (
+	LogLevelDebug5  LogLevel = C.DEBUG5
+	LogLevelDebug4  LogLevel = C.DEBUG4
+	LogLevelDebug3  LogLevel = C.DEBUG3
+	LogLevelDebug2  LogLevel = C.DEBUG2
+	LogLevelDebug1  LogLevel = C.DEBUG1
+	LogLevelLog     LogLevel = C.LOG
+	LogLevelInfo    LogLevel = C.INFO
+	LogLevelNotice  LogLevel = C.NOTICE
+	LogLevelWarning LogLevel = C.WARNING
+)
+
+// LogMessage is a message that an extension reported below ERROR, which does not interrupt the call that reported it.
+type LogMessage struct {
+	Level LogLevel
+	// Code is the five-character SQLSTATE of the message, which is 01000 for warnings and 00000 otherwise unless the
+	// extension gave its own.
+	Code    string
+	Message string
+	Detail  string
+	Hint    string
+	Context string
+	// ProcessID identifies the session that reported the message, and is the ProcessID that was given to
+	// SetSessionInfo, or the ID of the host's process if none was given.
+	ProcessID int32
+}
+
+// Logger receives the messages that extensions report below ERROR, such as notices, warnings, and server log messages.
+// Hosts forward these to the client connection of the session that reported them, or to their own logs, depending on
+// the level. Log is called on the thread of the call that reported the message, before the call continues, so it
+// should return quickly and must not call into extensions.
+type Logger interface {
+	Log(message LogMessage)
+}
+
+var (
+	// currentLogger receives messages, or is nil if messages are written to stderr.
+	currentLogger Logger
+	// loggerMinLevel is the lowest level of the messages that are reported, which is LOG without a logger.
+	loggerMinLevel = LogLevelLog
+	// loggerMutex gates access to the logger and its level.
+	loggerMutex = &sync.RWMutex{}
+	// hostLogger is the C struct that forwards to the Go logger.
+	hostLogger = sync.OnceValue(func() *C.PgExtLogger {
+		return C.NewHostLogger()
+	})
+	shimSetLogger = newShimProc("pgext_set_logger")
+)
+
+// SetLogger sets the logger that receives the messages that extensions report at or above the given level. Messages
+// below the level are skipped before the extension formats them, so hosts that discard debug messages should not ask
+// for them. Setting nil writes messages of LOG and above to stderr, which is the default.
+func SetLogger(logger Logger, minLevel LogLevel) error {
+	loggerMutex.Lock()
+	currentLogger = logger
+	loggerMinLevel = minLevel
+	if logger == nil {
+		loggerMinLevel = LogLevelLog
+	}
+	loggerMutex.Unlock()
+	var loggerPtr uintptr
+	if logger != nil {
+		loggerPtr = uintptr(unsafe.Pointer(hostLogger()))
+	}
+	_, err := shimSetLogger.Call(loggerPtr, uintptr(minLevel))
+	return err
+}
+
+// currentLoggerLevel returns whether a logger has been set, along with the lowest level of the messages that are
+// reported.
+func currentLoggerLevel() (bool, LogLevel) {
+	loggerMutex.RLock()
+	defer loggerMutex.RUnlock()
+	return currentLogger != nil, loggerMinLevel
+}
+
+// logMessage reports a message that did not pass through the shim, such as one from a WebAssembly module or a sandbox
+// worker, in the same way as the shim reports those of native libraries.
+func logMessage(message LogMessage) {
+	loggerMutex.RLock()
+	logger, minLevel := currentLogger, loggerMinLevel
+	loggerMutex.RUnlock()
+	if message.Level < minLevel {
+		return
+	}
+	if logger == nil {
+		fmt.Fprintf(os.Stderr, "Postgres %s: %s\n", message.Level, message.Message)
+		return
+	}
+	logger.Log(message)
+}
+
+// String returns the name of the level, as Postgres displays it.
+func (level LogLevel) String() string {
+	switch {
+	case level >= LogLevelWarning:
+		return "WARNING"
+	case level >= LogLevelNotice:
+		return "NOTICE"
+	case level >= LogLevelInfo:
+		return "INFO"
+	case level >= LogLevelLog:
+		return "LOG"
+	case level >= LogLevelDebug5:
+		return fmt.Sprintf("DEBUG%d", LogLevelDebug1-level+1)
+	default:
+		return fmt.Sprintf("level %d", int32(level))
+	}
+}
+
+//export pgextHostLog
+func pgextHostLog(edata *C.PgExtErrorData, pid C.int32_t) {
+	loggerMutex.RLock()
+	logger := currentLogger
+	loggerMutex.RUnlock()
+	if logger == nil {
+		return
+	}
+	logger.Log(LogMessage{
+		Level:     LogLevel(edata.elevel),
+		Code:      decodeSQLState(int(edata.sqlerrcode)),
+		Message:   C.GoString(&edata.message[0]),
+		Detail:    C.GoString(&edata.detail[0]),
+		Hint:      C.GoString(&edata.hint[0]),
+		Context:   C.GoString(&edata.context[0]),
+		ProcessID: int32(pid),
+	})
+}
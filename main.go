@@ -1,48 +0,0 @@
-// Copyright 2025 Dolthub, Inc.
-//
-// Licensed under the Apache License, Version 2.0 (the "License");
-// you may not use this file except in compliance with the License.
-// You may obtain a copy of the License at
-//
-//     http://www.apache.org/licenses/LICENSE-2.0
-//
-// Unless required by applicable law or agreed to in writing, software
-// distributed under the License is distributed on an "AS IS" BASIS,
-// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-// See the License for the specific language governing permissions and
-// limitations under the License.
-
-package main
-
-import "C"
-import (
-	"fmt"
-	"os"
-	"unsafe"
-)
-
-func main() {
-	extensionFiles, err := LoadExtensions()
-	if err != nil {
-		fmt.Printf("%s\n", err.Error())
-		os.Exit(1)
-	}
-	lib, err := extensionFiles["uuid-ossp"].LoadLibrary()
-	if err != nil {
-		fmt.Printf("%s\n", err.Error())
-		os.Exit(1)
-	}
-	defer func() {
-		_ = lib.internal.Close()
-	}()
-	fmt.Printf("Pg_magic_func:\n  version=%d  maxArgs=%d  nameDataLen=%d\n",
-		lib.magic.Version, lib.magic.FuncMaxArgs, lib.magic.NameDataLen)
-	datum, isNotNull := CallFmgrFunction(lib.funcs["uuid_generate_v4"].Ptr)
-	if isNotNull {
-		val := C.GoString((*C.char)(unsafe.Pointer(datum)))
-		FreeDatum(datum)
-		fmt.Printf("uuid_generate_v4:\n  %v\n", val)
-	} else {
-		fmt.Printf("uuid_generate_v4:\n  null\n")
-	}
-}
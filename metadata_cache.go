@@ -0,0 +1,223 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// metadataCacheVersion is the version of the cache's format, along with the parsers that produce its contents. This
+// must be incremented whenever either changes, so that caches from older versions are discarded.
+const metadataCacheVersion = 1
+
+// metadataCacheFile is the contents of the metadata cache. Entries are keyed by the path of the file that they were
+// parsed from.
+type metadataCacheFile struct {
+	Version  int
+	Controls map[string]cachedControl
+	Scripts  map[string]cachedScript
+}
+
+// fileStamp identifies the contents of a file by its size and modification time.
+type fileStamp struct {
+	Size    int64
+	ModTime int64
+}
+
+// cachedControl is a parsed control file.
+type cachedControl struct {
+	Stamp   fileStamp
+	Control Control
+}
+
+// cachedScript contains the functions that are created by a SQL file, before their libraries are resolved.
+type cachedScript struct {
+	Stamp     fileStamp
+	Functions []FunctionDefinition
+}
+
+var (
+	// metadataCachePath is the location of the cache file. Caching is disabled when this is empty.
+	metadataCachePath = filepath.Join(cacheDirectory(), "metadata.gob")
+	// metadataCache is the cache that has been read from metadataCachePath, or nil if it has not been read yet.
+	metadataCache *metadataCacheFile
+	// metadataCacheDirty is set when the cache has entries that have not been written.
+	metadataCacheDirty bool
+	// metadataCacheMutex gates access to the metadata cache.
+	metadataCacheMutex = &sync.Mutex{}
+)
+
+// SetMetadataCacheFile sets the file that caches the parsed control and SQL files of extensions, so that they're only
+// parsed again once they change. Only the files of extensions that come from the local filesystem are cached, and a
+// file is parsed again whenever its size or modification time changes. Setting an empty path disables the cache. The
+// cache is within the user's cache directory by default.
+func SetMetadataCacheFile(path string) {
+	metadataCacheMutex.Lock()
+	defer metadataCacheMutex.Unlock()
+	metadataCachePath = path
+	metadataCache = nil
+	metadataCacheDirty = false
+}
+
+// cacheStamp returns the key and stamp of the extension's file within the metadata cache. Returns false if the file
+// cannot be cached.
+func (extFile *ExtensionFiles) cacheStamp(fileName string) (string, fileStamp, bool) {
+	if len(extFile.ControlFileDir) == 0 {
+		return "", fileStamp{}, false
+	}
+	info, err := fs.Stat(extFile.ControlFS, fileName)
+	if err != nil {
+		return "", fileStamp{}, false
+	}
+	key := filepath.Join(extFile.ControlFileDir, filepath.FromSlash(fileName))
+	return key, fileStamp{Size: info.Size(), ModTime: info.ModTime().UnixNano()}, true
+}
+
+// cachedControlFile returns the parsed control file from the cache, if it has not changed since it was cached.
+func cachedControlFile(key string, stamp fileStamp) (*Control, bool) {
+	metadataCacheMutex.Lock()
+	defer metadataCacheMutex.Unlock()
+	cache := readMetadataCache()
+	if cache == nil {
+		return nil, false
+	}
+	entry, ok := cache.Controls[key]
+	if !ok || entry.Stamp != stamp {
+		return nil, false
+	}
+	control := entry.Control
+	control.Requires = slices.Clone(control.Requires)
+	control.NoRelocate = slices.Clone(control.NoRelocate)
+	return &control, true
+}
+
+// cacheControlFile adds the parsed control file to the cache.
+func cacheControlFile(key string, stamp fileStamp, control *Control) {
+	metadataCacheMutex.Lock()
+	defer metadataCacheMutex.Unlock()
+	if cache := readMetadataCache(); cache != nil {
+		cache.Controls[key] = cachedControl{Stamp: stamp, Control: *control}
+		metadataCacheDirty = true
+	}
+}
+
+// cachedScriptFunctions returns the functions that are created by the SQL file from the cache, if it has not changed
+// since it was cached. Each call returns new definitions, which the caller may modify.
+func cachedScriptFunctions(key string, stamp fileStamp) ([]*FunctionDefinition, bool) {
+	metadataCacheMutex.Lock()
+	defer metadataCacheMutex.Unlock()
+	cache := readMetadataCache()
+	if cache == nil {
+		return nil, false
+	}
+	entry, ok := cache.Scripts[key]
+	if !ok || entry.Stamp != stamp {
+		return nil, false
+	}
+	definitions := make([]*FunctionDefinition, len(entry.Functions))
+	for i := range entry.Functions {
+		definitions[i] = entry.Functions[i].clone()
+	}
+	return definitions, true
+}
+
+// cacheScriptFunctions adds the functions that are created by the SQL file to the cache.
+func cacheScriptFunctions(key string, stamp fileStamp, definitions []*FunctionDefinition) {
+	metadataCacheMutex.Lock()
+	defer metadataCacheMutex.Unlock()
+	if cache := readMetadataCache(); cache != nil {
+		functions := make([]FunctionDefinition, len(definitions))
+		for i, definition := range definitions {
+			functions[i] = *definition.clone()
+		}
+		cache.Scripts[key] = cachedScript{Stamp: stamp, Functions: functions}
+		metadataCacheDirty = true
+	}
+}
+
+// flushMetadataCache writes the cache to its file if it has new entries. Failures are ignored, since the cache only
+// avoids parsing files again.
+func flushMetadataCache() {
+	metadataCacheMutex.Lock()
+	defer metadataCacheMutex.Unlock()
+	if !metadataCacheDirty || metadataCache == nil || len(metadataCachePath) == 0 {
+		return
+	}
+	metadataCacheDirty = false
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(metadataCache); err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(metadataCachePath), 0755); err != nil {
+		return
+	}
+	// We write to a temporary file first so that a concurrent process never reads a partially-written cache
+	tempFile, err := os.CreateTemp(filepath.Dir(metadataCachePath), filepath.Base(metadataCachePath)+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tempFile.Write(buffer.Bytes())
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFile.Name(), metadataCachePath)
+	}
+	if err != nil {
+		_ = os.Remove(tempFile.Name())
+	}
+}
+
+// readMetadataCache returns the cache, reading it from its file the first time that it's needed. A cache that is
+// missing, unreadable, or from another version is replaced by an empty cache. Returns nil when caching is disabled. The
+// mutex must be held by the caller.
+func readMetadataCache() *metadataCacheFile {
+	if len(metadataCachePath) == 0 {
+		return nil
+	}
+	if metadataCache != nil {
+		return metadataCache
+	}
+	cache := &metadataCacheFile{}
+	if data, err := os.ReadFile(metadataCachePath); err == nil {
+		if err = gob.NewDecoder(bytes.NewReader(data)).Decode(cache); err != nil ||
+			cache.Version != metadataCacheVersion {
+			cache = &metadataCacheFile{}
+		}
+	}
+	cache.Version = metadataCacheVersion
+	if cache.Controls == nil {
+		cache.Controls = make(map[string]cachedControl)
+	}
+	if cache.Scripts == nil {
+		cache.Scripts = make(map[string]cachedScript)
+	}
+	metadataCache = cache
+	return cache
+}
+
+// clone returns a copy of the definition that does not share any slices with the original.
+func (fn *FunctionDefinition) clone() *FunctionDefinition {
+	newFn := *fn
+	newFn.Parameters = slices.Clone(fn.Parameters)
+	newFn.ReturnsTable = slices.Clone(fn.ReturnsTable)
+	return &newFn
+}
@@ -0,0 +1,109 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"fmt"
+	"math/big"
+)
+
+var (
+	shimNumericIn  = newShimProc("numeric_in")
+	shimNumericOut = newShimProc("numeric_out")
+)
+
+// NumericToDatum returns a numeric Datum of the given decimal text, which is parsed the same as numeric_in, including
+// exponents and the special values NaN, Infinity, and -Infinity. The Datum is allocated within the current memory
+// context.
+func NumericToDatum(s string) (Datum, error) {
+	fn, err := shimNumericIn.addr()
+	if err != nil {
+		return 0, err
+	}
+	str, err := CStringToDatum(s)
+	if err != nil {
+		return 0, err
+	}
+	defer FreeDatum(str)
+	// The typmod of -1 keeps the value's own scale
+	typmod := NewNullableDatum(Int32ToDatum(-1))
+	result, _, err := CallFmgrFunction(fn, NewNullableDatum(str), NewNullableDatum(0), typmod)
+	return result, err
+}
+
+// DatumToNumeric returns the decimal text of the numeric that the Datum points to, which is formatted the same as
+// numeric_out.
+func DatumToNumeric(d Datum) (string, error) {
+	if d == 0 {
+		return "", fmt.Errorf("cannot read a numeric from a null pointer")
+	}
+	fn, err := shimNumericOut.addr()
+	if err != nil {
+		return "", err
+	}
+	result, _, err := CallFmgrFunction(fn, NewNullableDatum(d))
+	if err != nil {
+		return "", err
+	}
+	defer FreeDatum(result)
+	return DatumToCString(result)
+}
+
+// RatToNumericDatum returns a numeric Datum of the value, rounded to the given number of digits after the decimal point
+// with ties rounding away from zero. The Datum is allocated within the current memory context.
+func RatToNumericDatum(r *big.Rat, scale int) (Datum, error) {
+	if scale < 0 {
+		return 0, fmt.Errorf("numeric scale %d must be between 0 and 16383", scale)
+	}
+	return NumericToDatum(r.FloatString(scale))
+}
+
+// DatumToNumericRat returns the value of the numeric that the Datum points to. Returns an error for NaN and the
+// infinities, which a big.Rat cannot hold.
+func DatumToNumericRat(d Datum) (*big.Rat, error) {
+	s, err := DatumToNumeric(d)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("cannot convert numeric %s to a rational number", s)
+	}
+	return r, nil
+}
+
+// exactDecimalScale returns the number of digits after the decimal point that represent the value exactly. Returns
+// false if the value has no exact decimal representation, such as one third.
+func exactDecimalScale(r *big.Rat) (int, bool) {
+	denominator := new(big.Int).Set(r.Denom())
+	twos, fives := 0, 0
+	for _, factor := range []int64{2, 5} {
+		divisor := big.NewInt(factor)
+		remainder := new(big.Int)
+		for {
+			quotient, _ := new(big.Int).QuoRem(denominator, divisor, remainder)
+			if remainder.Sign() != 0 {
+				break
+			}
+			denominator = quotient
+			if factor == 2 {
+				twos++
+			} else {
+				fives++
+			}
+		}
+	}
+	return max(twos, fives), denominator.Cmp(big.NewInt(1)) == 0
+}
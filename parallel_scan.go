@@ -0,0 +1,95 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"maps"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// scanParallelism is the maximum number of goroutines that scan and parse extension files, or zero to use GOMAXPROCS.
+var scanParallelism atomic.Int64
+
+// SetScanParallelism sets the maximum number of goroutines that scan directories and parse the files of extensions.
+// Setting one causes all files to be handled serially, while zero or less uses GOMAXPROCS, which is the default.
+func SetScanParallelism(workers int) {
+	scanParallelism.Store(int64(max(workers, 0)))
+}
+
+// scanWorkers returns the maximum number of goroutines that scan and parse extension files.
+func scanWorkers() int {
+	if workers := int(scanParallelism.Load()); workers > 0 {
+		return workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// ExtensionScan is the result of parsing the control and SQL files of a single extension.
+type ExtensionScan struct {
+	Extension *ExtensionFiles
+	Control   *Control
+	// Functions are the functions that are created by the extension's SQL files, in the order that they're created.
+	Functions []*FunctionDefinition
+	// Err is set when the extension's files could not be parsed, in which case Control and Functions may be nil.
+	Err error
+}
+
+// ScanExtensions parses the control and SQL files of every given extension concurrently (see SetScanParallelism),
+// returning the results sorted by extension name. A failure to parse one extension does not affect the others, so each
+// result must have its Err checked. This is meant for hosts that parse every extension at startup, which also fills the
+// metadata cache (see SetMetadataCacheFile).
+func ScanExtensions(extensions map[string]*ExtensionFiles) []ExtensionScan {
+	defer flushMetadataCache()
+	names := slices.Sorted(maps.Keys(extensions))
+	scans := make([]ExtensionScan, len(names))
+	forEachParallel(len(names), scanWorkers(), func(i int) {
+		extFile := extensions[names[i]]
+		scan := ExtensionScan{Extension: extFile}
+		scan.Control, scan.Err = extFile.loadControl()
+		if scan.Err == nil {
+			// Extensions are already parsed concurrently, so each extension's files are parsed serially
+			scan.Functions, scan.Err = extFile.loadSQLFunctionDefinitions(1)
+		}
+		scans[i] = scan
+	})
+	return scans
+}
+
+// forEachParallel calls fn with every index from zero up to n, using at most the given number of goroutines. Indexes
+// are handed out in order, and this returns once every call has returned.
+func forEachParallel(n int, workers int, fn func(i int)) {
+	workers = min(workers, n)
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for range workers {
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1) - 1); i < n; i = int(next.Add(1) - 1) {
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,96 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import "strings"
+
+// PostgresError is an error that was raised by an extension through ereport or elog. Its fields mirror the fields of a
+// Postgres error report.
+type PostgresError struct {
+	// Severity is the level of the error, such as ERROR or FATAL.
+	Severity string
+	// Code is the five-character SQLSTATE of the error.
+	Code     string
+	Message  string
+	Detail   string
+	Hint     string
+	Context  string
+	File     string
+	Line     int
+	Function string
+}
+
+// Error implements the error interface.
+func (pe PostgresError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(pe.Severity)
+	sb.WriteString(": ")
+	sb.WriteString(pe.Message)
+	if len(pe.Detail) > 0 {
+		sb.WriteString("\nDETAIL: ")
+		sb.WriteString(pe.Detail)
+	}
+	if len(pe.Hint) > 0 {
+		sb.WriteString("\nHINT: ")
+		sb.WriteString(pe.Hint)
+	}
+	if len(pe.Context) > 0 {
+		sb.WriteString("\nCONTEXT: ")
+		sb.WriteString(pe.Context)
+	}
+	return sb.String()
+}
+
+// decodeSQLState converts an error code that was created by MAKE_SQLSTATE into its five-character form.
+func decodeSQLState(sqlerrcode int) string {
+	code := make([]byte, 5)
+	for i := range code {
+		code[i] = byte((sqlerrcode>>(6*i))&0x3F) + '0'
+	}
+	return string(code)
+}
+
+// encodeSQLState converts a five-character SQLSTATE into the form that is created by MAKE_SQLSTATE. Returns zero if the
+// SQLSTATE is malformed.
+func encodeSQLState(code string) int {
+	if len(code) != 5 {
+		return 0
+	}
+	var sqlerrcode int
+	for i := 0; i < len(code); i++ {
+		sqlerrcode |= int((code[i]-'0')&0x3F) << (6 * i)
+	}
+	return sqlerrcode
+}
+
+// elevelName returns the name of the given error level.
+func elevelName(elevel int) string {
+	switch {
+	case elevel >= 23:
+		return "PANIC"
+	case elevel >= 22:
+		return "FATAL"
+	case elevel >= 21:
+		return "ERROR"
+	case elevel >= 19:
+		return "WARNING"
+	case elevel >= 18:
+		return "NOTICE"
+	case elevel >= 17:
+		return "INFO"
+	default:
+		return "LOG"
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// debianLibraryRoot contains a directory for each major version of Postgres that is installed on Debian-family
+	// systems, each with the libraries in its "lib" subdirectory.
+	debianLibraryRoot = "/usr/lib/postgresql"
+	// debianShareRoot contains a directory for each major version of Postgres that is installed on Debian-family
+	// systems, each with the control files in its "extension" subdirectory.
+	debianShareRoot = "/usr/share/postgresql"
+)
+
+// PostgresInstallation is a single version of Postgres that is installed alongside others, as Debian-family systems do.
+type PostgresInstallation struct {
+	// Version is the name of the installation's directory, such as "16" or "9.6".
+	Version string
+	// MajorVersion is the major version of the installation, which is 9 for "9.6".
+	MajorVersion int
+	LibraryDir   string
+	ExtensionDir string
+}
+
+// PostgresInstallations returns the versions of Postgres that are installed side by side, with the newest first. Only
+// versions that have both a library directory and an extension directory are returned. This is empty when the system
+// does not use such a layout.
+func PostgresInstallations() ([]PostgresInstallation, error) {
+	entries, err := os.ReadDir(debianLibraryRoot)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var installations []PostgresInstallation
+	for _, entry := range entries {
+		majorVersion, err := strconv.Atoi(strings.SplitN(entry.Name(), ".", 2)[0])
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		installation := PostgresInstallation{
+			Version:      entry.Name(),
+			MajorVersion: majorVersion,
+			LibraryDir:   filepath.Join(debianLibraryRoot, entry.Name(), "lib"),
+			ExtensionDir: filepath.Join(debianShareRoot, entry.Name(), "extension"),
+		}
+		if isDir(installation.LibraryDir) && isDir(installation.ExtensionDir) {
+			installations = append(installations, installation)
+		}
+	}
+	slices.SortFunc(installations, func(a, b PostgresInstallation) int {
+		return compareVersionedPaths(b.Version, a.Version)
+	})
+	return installations, nil
+}
+
+// FindPostgresInstallation returns the installed version of Postgres with the given major version, or the newest
+// installed version when the major version is zero.
+func FindPostgresInstallation(majorVersion int) (PostgresInstallation, error) {
+	installations, err := PostgresInstallations()
+	if err != nil {
+		return PostgresInstallation{}, err
+	}
+	for _, installation := range installations {
+		if majorVersion == 0 || installation.MajorVersion == majorVersion {
+			return installation, nil
+		}
+	}
+	if majorVersion == 0 {
+		return PostgresInstallation{}, fmt.Errorf("cannot find any versions of Postgres within `%s`", debianLibraryRoot)
+	}
+	return PostgresInstallation{}, fmt.Errorf("cannot find Postgres %d within `%s`", majorVersion, debianLibraryRoot)
+}
+
+// isDir returns whether the path is a directory.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
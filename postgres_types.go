@@ -0,0 +1,139 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"strings"
+)
+
+// PostgresType is a built-in type of Postgres, which is identified by its OID.
+type PostgresType struct {
+	// Name is the name of the type within pg_type, such as "int4" for integer.
+	Name string
+	OID  uint32
+	// Storage describes how values of the type are stored, which mirrors the typbyval and typlen columns of pg_type.
+	Storage ResultType
+}
+
+// IsArray returns whether the type is an array type.
+func (typ PostgresType) IsArray() bool {
+	return strings.HasPrefix(typ.Name, "_")
+}
+
+// postgresTypes are the built-in types that extensions commonly use, keyed by their name within pg_type.
+var postgresTypes = map[string]PostgresType{
+	"bool":        {Name: "bool", OID: 16, Storage: ResultTypeByValue},
+	"bytea":       {Name: "bytea", OID: 17, Storage: ResultTypeVarlena},
+	"char":        {Name: "char", OID: 18, Storage: ResultTypeByValue},
+	"name":        {Name: "name", OID: 19, Storage: ResultType{Length: 64}},
+	"int8":        {Name: "int8", OID: 20, Storage: ResultTypeByValue},
+	"int2":        {Name: "int2", OID: 21, Storage: ResultTypeByValue},
+	"int4":        {Name: "int4", OID: 23, Storage: ResultTypeByValue},
+	"regproc":     {Name: "regproc", OID: 24, Storage: ResultTypeByValue},
+	"text":        {Name: "text", OID: 25, Storage: ResultTypeVarlena},
+	"oid":         {Name: "oid", OID: 26, Storage: ResultTypeByValue},
+	"json":        {Name: "json", OID: 114, Storage: ResultTypeVarlena},
+	"xml":         {Name: "xml", OID: 142, Storage: ResultTypeVarlena},
+	"float4":      {Name: "float4", OID: 700, Storage: ResultTypeByValue},
+	"float8":      {Name: "float8", OID: 701, Storage: ResultTypeByValue},
+	"bpchar":      {Name: "bpchar", OID: 1042, Storage: ResultTypeVarlena},
+	"varchar":     {Name: "varchar", OID: 1043, Storage: ResultTypeVarlena},
+	"date":        {Name: "date", OID: 1082, Storage: ResultTypeByValue},
+	"time":        {Name: "time", OID: 1083, Storage: ResultTypeByValue},
+	"timestamp":   {Name: "timestamp", OID: 1114, Storage: ResultTypeByValue},
+	"timestamptz": {Name: "timestamptz", OID: 1184, Storage: ResultTypeByValue},
+	"interval":    {Name: "interval", OID: 1186, Storage: ResultType{Length: 16}},
+	"numeric":     {Name: "numeric", OID: 1700, Storage: ResultTypeVarlena},
+	"regclass":    {Name: "regclass", OID: 2205, Storage: ResultTypeByValue},
+	"regtype":     {Name: "regtype", OID: 2206, Storage: ResultTypeByValue},
+	"record":      {Name: "record", OID: 2249, Storage: ResultTypeVarlena},
+	"cstring":     {Name: "cstring", OID: 2275, Storage: ResultTypeCString},
+	"internal":    {Name: "internal", OID: 2281, Storage: ResultTypeByValue},
+	"void":        {Name: "void", OID: 2278, Storage: ResultTypeByValue},
+	"trigger":     {Name: "trigger", OID: 2279, Storage: ResultTypeByValue},
+	"anyelement":  {Name: "anyelement", OID: 2283, Storage: ResultTypeByValue},
+	"uuid":        {Name: "uuid", OID: 2950, Storage: ResultType{Length: 16}},
+	"jsonb":       {Name: "jsonb", OID: 3802, Storage: ResultTypeVarlena},
+	"_bool":       {Name: "_bool", OID: 1000, Storage: ResultTypeVarlena},
+	"_bytea":      {Name: "_bytea", OID: 1001, Storage: ResultTypeVarlena},
+	"_int2":       {Name: "_int2", OID: 1005, Storage: ResultTypeVarlena},
+	"_int4":       {Name: "_int4", OID: 1007, Storage: ResultTypeVarlena},
+	"_text":       {Name: "_text", OID: 1009, Storage: ResultTypeVarlena},
+	"_varchar":    {Name: "_varchar", OID: 1015, Storage: ResultTypeVarlena},
+	"_int8":       {Name: "_int8", OID: 1016, Storage: ResultTypeVarlena},
+	"_float4":     {Name: "_float4", OID: 1021, Storage: ResultTypeVarlena},
+	"_float8":     {Name: "_float8", OID: 1022, Storage: ResultTypeVarlena},
+	"_oid":        {Name: "_oid", OID: 1028, Storage: ResultTypeVarlena},
+	"_numeric":    {Name: "_numeric", OID: 1231, Storage: ResultTypeVarlena},
+	"_uuid":       {Name: "_uuid", OID: 2951, Storage: ResultTypeVarlena},
+	"_jsonb":      {Name: "_jsonb", OID: 3807, Storage: ResultTypeVarlena},
+}
+
+// postgresTypeAliases are the names that SQL accepts for built-in types, mapped to their names within pg_type.
+var postgresTypeAliases = map[string]string{
+	"boolean":                     "bool",
+	"smallint":                    "int2",
+	"integer":                     "int4",
+	"int":                         "int4",
+	"bigint":                      "int8",
+	"real":                        "float4",
+	"float":                       "float8",
+	"double precision":            "float8",
+	"decimal":                     "numeric",
+	"character":                   "bpchar",
+	"char":                        "bpchar",
+	"character varying":           "varchar",
+	"time without time zone":      "time",
+	"timestamp without time zone": "timestamp",
+	"timestamp with time zone":    "timestamptz",
+}
+
+// LookupPostgresType returns the built-in type with the given name, as it's written within a function's signature.
+// This accepts the names that SQL accepts, such as "integer" and "double precision", along with names that are
+// qualified by pg_catalog, and ignores type modifiers such as the length of "character varying(10)". Arrays are written
+// with a trailing "[]". Returns false for types that are not built in, including types that extensions create.
+func LookupPostgresType(name string) (PostgresType, bool) {
+	name = strings.TrimSpace(name)
+	isArray := false
+	for strings.HasSuffix(name, "[]") {
+		name = strings.TrimSpace(strings.TrimSuffix(name, "[]"))
+		isArray = true
+	}
+	if strings.HasPrefix(name, "_") {
+		name = name[1:]
+		isArray = true
+	}
+	// The quoted "char" is the single-byte type, while an unquoted char is an alias of bpchar
+	if strings.TrimPrefix(name, "pg_catalog.") == `"char"` {
+		name = "char"
+	} else {
+		name = strings.ToLower(strings.ReplaceAll(name, `"`, ""))
+		name = strings.TrimPrefix(name, "pg_catalog.")
+		if start := strings.IndexByte(name, '('); start >= 0 {
+			if end := strings.IndexByte(name[start:], ')'); end >= 0 {
+				name = name[:start] + name[start+end+1:]
+			}
+		}
+		name = strings.Join(strings.Fields(name), " ")
+		if alias, ok := postgresTypeAliases[name]; ok {
+			name = alias
+		}
+	}
+	if isArray {
+		name = "_" + name
+	}
+	typ, ok := postgresTypes[name]
+	return typ, ok
+}
@@ -0,0 +1,247 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// ExtensionObject is an object that was created by an extension's scripts.
+type ExtensionObject struct {
+	// Kind is the kind of object, such as FUNCTION or OPERATOR CLASS.
+	Kind   string
+	Schema string
+	Name   string
+	// Signature distinguishes the object from others with the same name, such as the argument types of a function. This
+	// is empty for objects that are identified by their name alone.
+	Signature string
+}
+
+// sqlSchemaObjectKinds are the kinds of objects that belong to a schema, and therefore may be moved by SET SCHEMA.
+var sqlSchemaObjectKinds = map[string]struct{}{
+	"AGGREGATE":                 {},
+	"COLLATION":                 {},
+	"CONVERSION":                {},
+	"DOMAIN":                    {},
+	"FOREIGN TABLE":             {},
+	"FUNCTION":                  {},
+	"MATERIALIZED VIEW":         {},
+	"OPERATOR":                  {},
+	"OPERATOR CLASS":            {},
+	"OPERATOR FAMILY":           {},
+	"PROCEDURE":                 {},
+	"ROUTINE":                   {},
+	"SEQUENCE":                  {},
+	"STATISTICS":                {},
+	"TABLE":                     {},
+	"TEXT SEARCH CONFIGURATION": {},
+	"TEXT SEARCH DICTIONARY":    {},
+	"TEXT SEARCH PARSER":        {},
+	"TEXT SEARCH TEMPLATE":      {},
+	"TYPE":                      {},
+	"VIEW":                      {},
+}
+
+// PrepareScripts returns the extension's scripts, ready to be executed by the host in order to install the extension
+// into the given schema. Each script sets the search path to the target schema, has its psql guard removed, and has
+// MODULE_PATHNAME replaced. For extensions that are not relocatable, @extschema@ is also replaced. Extensions that
+// declare a fixed schema must be installed into that schema (an empty schema selects it), and the first script creates
+// the schema if it does not yet exist. The scripts are expected to be executed within a single transaction.
+func (extFile *ExtensionFiles) PrepareScripts(schema string) ([]string, error) {
+	control, schema, createSchema, err := extFile.installSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	quotedSchema := quoteSQLIdentifier(schema)
+	scripts := make([]string, len(extFile.SQLFileNames))
+	for i, sqlFileName := range extFile.SQLFileNames {
+		data, err := fs.ReadFile(extFile.ControlFS, sqlFileName)
+		if err != nil {
+			return nil, err
+		}
+		script := prepareScriptText(string(data), control, schema)
+		var sb strings.Builder
+		if createSchema && i == 0 {
+			sb.WriteString(fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s;\n", quotedSchema))
+		}
+		sb.WriteString(fmt.Sprintf("SET LOCAL search_path TO %s;\n", quotedSchema))
+		sb.WriteString(script)
+		scripts[i] = sb.String()
+	}
+	return scripts, nil
+}
+
+// SetSchema implements ALTER EXTENSION ... SET SCHEMA. It returns the statements that move each of the given objects
+// from the old schema to the new schema, and rewrites the schema of each object. Objects that do not belong to a schema
+// are ignored.
+func (extFile *ExtensionFiles) SetSchema(objects []ExtensionObject, oldSchema string, newSchema string) ([]string, error) {
+	control, err := extFile.LoadControl()
+	if err != nil {
+		return nil, err
+	}
+	if !control.Relocatable {
+		return nil, fmt.Errorf(`extension "%s" does not support SET SCHEMA`, extFile.Name)
+	}
+	if oldSchema == newSchema {
+		return nil, nil
+	}
+	var statements []string
+	for i := range objects {
+		obj := &objects[i]
+		if _, ok := sqlSchemaObjectKinds[obj.Kind]; !ok {
+			continue
+		}
+		if obj.Schema != oldSchema {
+			return nil, fmt.Errorf(`extension "%s" does not support SET SCHEMA because %s %s is not in the extension's schema "%s"`,
+				extFile.Name, strings.ToLower(obj.Kind), obj.Name, oldSchema)
+		}
+		var name string
+		if obj.Kind == "OPERATOR" {
+			name = quoteSQLIdentifier(obj.Schema) + "." + obj.Name
+		} else {
+			name = quoteSQLIdentifier(obj.Schema) + "." + quoteSQLIdentifier(obj.Name)
+		}
+		if len(obj.Signature) > 0 {
+			name += " " + obj.Signature
+		}
+		statements = append(statements, fmt.Sprintf("ALTER %s %s SET SCHEMA %s;", obj.Kind, name, quoteSQLIdentifier(newSchema)))
+		obj.Schema = newSchema
+	}
+	return statements, nil
+}
+
+// Objects returns the objects that are created by the plan's statements. Objects that are not schema-qualified are
+// assigned the given schema.
+func (plan ScriptPlan) Objects(schema string) []ExtensionObject {
+	var objects []ExtensionObject
+	for _, stmt := range plan.Statements {
+		if stmt.Action != "CREATE" || len(stmt.ObjectKind) == 0 {
+			continue
+		}
+		_, _, _, next := classifySQLStatement(stmt.tokens)
+		obj := ExtensionObject{
+			Kind: stmt.ObjectKind,
+			Name: stmt.ObjectName,
+		}
+		if _, ok := sqlSchemaObjectKinds[stmt.ObjectKind]; ok {
+			obj.Schema = schema
+			if stmt.ObjectKind == "OPERATOR" {
+				if dotIdx := strings.LastIndexByte(obj.Name, '.'); dotIdx != -1 {
+					obj.Schema = obj.Name[:dotIdx]
+					obj.Name = obj.Name[dotIdx+1:]
+				}
+			} else {
+				// We parse the name again so that we're not confused by quoted names that contain periods
+				if parts := sqlNamePartsBefore(stmt.tokens, next); len(parts) > 1 {
+					obj.Schema = parts[len(parts)-2]
+					obj.Name = parts[len(parts)-1]
+				}
+			}
+		}
+		obj.Signature = sqlObjectSignature(stmt.ObjectKind, stmt.tokens, next)
+		objects = append(objects, obj)
+	}
+	return objects
+}
+
+// sqlNamePartsBefore returns the parts of the qualified name that ends immediately before the given token index.
+func sqlNamePartsBefore(tokens []sqlToken, end int) []string {
+	var parts []string
+	for i := end - 1; i >= 0; i -= 2 {
+		if tokens[i].Kind != sqlTokenIdentifier && tokens[i].Kind != sqlTokenQuotedIdentifier {
+			break
+		}
+		parts = append([]string{tokens[i].Value()}, parts...)
+		if i == 0 || !tokens[i-1].IsPunctuation(".") {
+			break
+		}
+	}
+	return parts
+}
+
+// sqlObjectSignature returns the signature of the object that is being created, which starts at the given token index.
+func sqlObjectSignature(kind string, tokens []sqlToken, i int) string {
+	switch kind {
+	case "FUNCTION", "PROCEDURE", "AGGREGATE":
+		return "(" + sqlArgumentTypes(tokens, i) + ")"
+	case "OPERATOR":
+		left, right := "NONE", "NONE"
+		for j := i; j+2 < len(tokens); j++ {
+			if !tokens[j+1].IsOperator("=") {
+				continue
+			}
+			if tokens[j].IsKeyword("leftarg") {
+				left = tokens[j+2].Text
+			} else if tokens[j].IsKeyword("rightarg") {
+				right = tokens[j+2].Text
+			}
+		}
+		return fmt.Sprintf("(%s, %s)", left, right)
+	case "OPERATOR CLASS", "OPERATOR FAMILY":
+		for j := i; j+1 < len(tokens); j++ {
+			if tokens[j].IsKeyword("using") {
+				return "USING " + tokens[j+1].Text
+			}
+		}
+	}
+	return ""
+}
+
+// sqlArgumentTypes returns the argument list that starts at the given token index, with any default values removed.
+func sqlArgumentTypes(tokens []sqlToken, i int) string {
+	if i >= len(tokens) || !tokens[i].IsPunctuation("(") {
+		return ""
+	}
+	var args []string
+	var current []string
+	depth := 0
+	inDefault := false
+	for j := i; j < len(tokens); j++ {
+		token := tokens[j]
+		switch {
+		case token.IsPunctuation("("):
+			depth++
+			if depth == 1 {
+				continue
+			}
+		case token.IsPunctuation(")"):
+			depth--
+			if depth == 0 {
+				if len(current) > 0 {
+					args = append(args, strings.Join(current, " "))
+				}
+				return strings.Join(args, ", ")
+			}
+		case depth == 1 && token.IsPunctuation(","):
+			args = append(args, strings.Join(current, " "))
+			current = nil
+			inDefault = false
+			continue
+		case depth == 1 && (token.IsKeyword("default") || token.IsOperator("=")):
+			inDefault = true
+		}
+		if !inDefault {
+			current = append(current, token.Text)
+		}
+	}
+	return strings.Join(args, ", ")
+}
+
+// quoteSQLIdentifier quotes the given identifier.
+func quoteSQLIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
@@ -0,0 +1,160 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// ResourceUsage is a snapshot of the resources that have been consumed by a library. Hosts may use this to enforce
+// quotas, or to display extension activity.
+type ResourceUsage struct {
+	Calls           uint64
+	CPUTime         time.Duration
+	PallocBytes     int64
+	PeakPallocBytes int64
+	// BackgroundWorkers is the number of the library's background workers that are currently running.
+	BackgroundWorkers int64
+}
+
+// resourceAccounting tracks the resources that have been consumed by a library. All fields are updated atomically, so
+// they may be modified from any thread.
+type resourceAccounting struct {
+	calls             atomic.Uint64
+	cpuNanos          atomic.Int64
+	pallocBytes       atomic.Int64
+	peakPallocBytes   atomic.Int64
+	backgroundWorkers atomic.Int64
+}
+
+// shimMemoryAllocated returns the number of bytes that are allocated within the memory contexts of the current session.
+var shimMemoryAllocated = newShimProc("pgext_memory_allocated")
+
+// ResourceUsage returns the resources that have been consumed by the library.
+func (lib *Library) ResourceUsage() ResourceUsage {
+	return lib.accounting.snapshot()
+}
+
+// AllResourceUsage returns the resources that have been consumed by every loaded library, keyed by the library's path.
+func AllResourceUsage() map[string]ResourceUsage {
+	loadedLibrariesMutex.Lock()
+	defer loadedLibrariesMutex.Unlock()
+	usage := make(map[string]ResourceUsage, len(loadedLibraries))
+	for path, lib := range loadedLibraries {
+		usage[path] = lib.accounting.snapshot()
+	}
+	return usage
+}
+
+// Call calls the function, recording the call against the resource usage of its library. STRICT functions return NULL
+// without being called when any argument is NULL.
+func (f Function) Call(args ...NullableDatum) (result Datum, isNotNull bool, err error) {
+	return f.CallColl(0, args...)
+}
+
+// CallColl is the same as Call, except that the function is called with the given collation.
+func (f Function) CallColl(collation uint32, args ...NullableDatum) (result Datum, isNotNull bool, err error) {
+	result, isNull, _, err := f.call(collation, args...)
+	if err != nil {
+		return 0, false, err
+	}
+	return result, !isNull && result != 0, nil
+}
+
+// call calls the function, recording the call against the resource usage of its library. Returns the number of bytes
+// that the call left allocated.
+func (f Function) call(collation uint32, args ...NullableDatum) (result Datum, isNull bool, allocated int64, err error) {
+	return f.callWithInfo(nil, callNodes{}, collation, args...)
+}
+
+// callWithInfo is the same as call, except that the function is called with the given FmgrInfo and nodes. A nil
+// FmgrInfo calls the function with a temporary FmgrInfo, which cannot be given any nodes.
+func (f Function) callWithInfo(fi *fmgrInfo, nodes callNodes, collation uint32,
+	args ...NullableDatum) (result Datum, isNull bool, allocated int64, err error) {
+	if f.Strict && hasNullArg(args) {
+		return 0, true, 0, nil
+	}
+	// callFn calls the function through the FmgrInfo, if one was given
+	callFn := func() (Datum, bool, error) {
+		if fi == nil {
+			return callFmgrFunction(f.Ptr, collation, args...)
+		}
+		return callFmgrInfo(fi, nodes, collation, args...)
+	}
+	if f.library == nil {
+		result, isNull, err = callWithTimeout(f.timeout, callFn)
+		return result, isNull, 0, err
+	}
+	metricsStart := f.library.metrics.start()
+	if f.library.wasm != nil {
+		// Modules allocate within their own memory rather than through the shim, so only the CPU time is recorded
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		start := threadCPUTime()
+		result, isNull, err = f.library.wasm.call(f, collation, nodes, args)
+		f.library.accounting.recordCall(threadCPUTime() - start)
+		f.library.metrics.record(f.Name, metricsStart, 0, err)
+		return result, isNull, 0, err
+	}
+	f.library.run(func() {
+		// CPU time is measured per thread, so we must remain on the same thread for the duration of the call
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		startAllocated, _ := shimMemoryAllocated.Call()
+		start := threadCPUTime()
+		result, isNull, err = callWithTimeout(f.timeout, callFn)
+		f.library.accounting.recordCall(threadCPUTime() - start)
+		endAllocated, _ := shimMemoryAllocated.Call()
+		allocated = int64(endAllocated) - int64(startAllocated)
+		f.library.accounting.addPallocBytes(allocated)
+	})
+	f.library.metrics.record(f.Name, metricsStart, allocated, err)
+	return result, isNull, allocated, err
+}
+
+// snapshot returns the current values of the accounting.
+func (ra *resourceAccounting) snapshot() ResourceUsage {
+	return ResourceUsage{
+		Calls:             ra.calls.Load(),
+		CPUTime:           time.Duration(ra.cpuNanos.Load()),
+		PallocBytes:       ra.pallocBytes.Load(),
+		PeakPallocBytes:   ra.peakPallocBytes.Load(),
+		BackgroundWorkers: ra.backgroundWorkers.Load(),
+	}
+}
+
+// recordCall records a single call that consumed the given CPU time.
+func (ra *resourceAccounting) recordCall(cpuTime time.Duration) {
+	ra.calls.Add(1)
+	ra.cpuNanos.Add(int64(max(cpuTime, 0)))
+}
+
+// addPallocBytes adjusts the number of allocated bytes, updating the peak if it has been exceeded.
+func (ra *resourceAccounting) addPallocBytes(delta int64) {
+	current := ra.pallocBytes.Add(delta)
+	for {
+		peak := ra.peakPallocBytes.Load()
+		if current <= peak || ra.peakPallocBytes.CompareAndSwap(peak, current) {
+			return
+		}
+	}
+}
+
+// addBackgroundWorkers adjusts the number of running background workers.
+func (ra *resourceAccounting) addBackgroundWorkers(delta int64) {
+	ra.backgroundWorkers.Add(delta)
+}
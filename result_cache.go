@@ -0,0 +1,182 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// libraryEpoch is incremented whenever a library is closed, which invalidates every result cache since function
+// pointers may be reused by a newly-loaded library.
+var libraryEpoch atomic.Uint64
+
+// ResultCache caches the results of IMMUTABLE functions, keyed by the function, its collation, and its arguments.
+// Arguments that are stored by reference are compared by their contents, which are read according to their type, so
+// equal values produce cache hits wherever they're stored. Calls are made within their own memory context, the same as
+// Function.CallScoped, and results are copied into memory that the cache owns, which is released once they're evicted.
+// Each caller receives its own copy of a cached result. A cache may be used per-session or shared, as it is safe for
+// concurrent use.
+type ResultCache struct {
+	mutex      sync.Mutex
+	maxEntries int
+	epoch      uint64
+	entries    map[resultCacheKey]*list.Element
+	lru        *list.List
+}
+
+// resultCacheKey is the key for a cached result.
+type resultCacheKey struct {
+	fn        uintptr
+	collation uint32
+	args      string
+}
+
+// resultCacheEntry is a cached result.
+type resultCacheEntry struct {
+	key    resultCacheKey
+	result CallResult
+}
+
+// NewResultCache returns a new ResultCache that holds, at most, the given number of results. Once full, the least
+// recently used result is evicted.
+func NewResultCache(maxEntries int) *ResultCache {
+	return &ResultCache{
+		maxEntries: max(maxEntries, 1),
+		epoch:      libraryEpoch.Load(),
+		entries:    make(map[resultCacheKey]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// Call calls the given function through the cache. The argument types describe how each argument is stored, so that
+// their contents may be compared, and the result type describes how the result is copied. Functions that are not
+// IMMUTABLE are always called directly, and errors are never cached.
+func (c *ResultCache) Call(fn Function, resultType ResultType, argTypes []ResultType, args ...NullableDatum) (CallResult, error) {
+	return c.CallColl(fn, 0, resultType, argTypes, args...)
+}
+
+// CallColl is the same as Call, except that the function is called with the given collation, which is part of the key
+// of its cached result.
+func (c *ResultCache) CallColl(fn Function, collation uint32, resultType ResultType, argTypes []ResultType, args ...NullableDatum) (CallResult, error) {
+	if fn.Volatility != VolatilityImmutable {
+		return fn.CallScopedColl(collation, resultType, args...)
+	}
+	encodedArgs, err := encodeResultCacheArgs(argTypes, args)
+	if err != nil {
+		return CallResult{}, err
+	}
+	key := resultCacheKey{
+		fn:        fn.Ptr,
+		collation: collation,
+		args:      encodedArgs,
+	}
+	c.mutex.Lock()
+	c.checkEpoch()
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		entry := elem.Value.(*resultCacheEntry)
+		c.mutex.Unlock()
+		return entry.result.clone(), nil
+	}
+	// We don't hold the lock while calling the function, as it may take an arbitrary amount of time
+	epoch := c.epoch
+	c.mutex.Unlock()
+
+	result, err := fn.CallScopedColl(collation, resultType, args...)
+	if err != nil {
+		return CallResult{}, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.checkEpoch()
+	if c.epoch != epoch {
+		return result, nil
+	}
+	if _, ok := c.entries[key]; !ok {
+		c.entries[key] = c.lru.PushFront(&resultCacheEntry{
+			key:    key,
+			result: result.clone(),
+		})
+		for c.lru.Len() > c.maxEntries {
+			oldest := c.lru.Back()
+			c.lru.Remove(oldest)
+			delete(c.entries, oldest.Value.(*resultCacheEntry).key)
+		}
+	}
+	return result, nil
+}
+
+// Len returns the number of cached results.
+func (c *ResultCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.checkEpoch()
+	return c.lru.Len()
+}
+
+// Clear removes all cached results.
+func (c *ResultCache) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.clear()
+}
+
+// checkEpoch clears the cache if a library has been closed since the cache was last used. This expects the mutex to be
+// held.
+func (c *ResultCache) checkEpoch() {
+	if epoch := libraryEpoch.Load(); epoch != c.epoch {
+		c.clear()
+		c.epoch = epoch
+	}
+}
+
+// clear removes all cached results. This expects the mutex to be held.
+func (c *ResultCache) clear() {
+	clear(c.entries)
+	c.lru.Init()
+}
+
+// encodeResultCacheArgs encodes the arguments into a string that may be used as a map key. Arguments that are stored by
+// reference are encoded by their contents, which are read according to their type.
+func encodeResultCacheArgs(argTypes []ResultType, args []NullableDatum) (string, error) {
+	if len(argTypes) != len(args) {
+		return "", fmt.Errorf("result cache was given %d argument types for %d arguments", len(argTypes), len(args))
+	}
+	buf := make([]byte, 0, len(args)*9)
+	for i, arg := range args {
+		contents, err := copyCallResult(arg.Value, arg.IsNull, argTypes[i])
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case contents.IsNull:
+			buf = append(buf, 1)
+		case argTypes[i].ByValue:
+			buf = append(buf, 0)
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(contents.Value))
+		default:
+			// The length prefix keeps the boundaries between arguments unambiguous
+			buf = append(buf, 2)
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(len(contents.Data)))
+			buf = append(buf, contents.Data...)
+		}
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,498 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"bufio"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// sandboxWorkerEnv is set within the environment of a sandbox worker, which is the host's own executable.
+const sandboxWorkerEnv = "PG_EXTENSION_SANDBOX_WORKER"
+
+// ProviderOption changes how a FunctionProvider loads and calls the functions of an extension.
+type ProviderOption func(*providerOptions)
+
+// providerOptions are the options that a FunctionProvider is created with.
+type providerOptions struct {
+	// sandbox is set when the extension is loaded within a worker process.
+	sandbox bool
+	// callTimeout is how long each call may run, which is zero for calls without a timeout.
+	callTimeout time.Duration
+	// policy decides whether the extension may be loaded, which is nil when every extension may be loaded.
+	policy ExtensionPolicy
+}
+
+// WithSandbox loads the extension within a worker process rather than the host, and proxies each call to the worker
+// over a pair of pipes. An extension that crashes the worker, or that reads or writes memory that it should not, cannot
+// reach the memory of the host, and the worker is started again on the next call. Calls are made one at a time, and
+// each one is slower than calling the function directly, so this is meant for extensions that are not trusted. Messages
+// that the extension reports within the worker are given to the host's Logger once the call that reported them returns.
+//
+// The worker runs the host's own executable, so the host must call RunSandboxWorker at the start of main, after
+// registering any bundled extensions. Extensions that are not on the local filesystem must be bundled, as the worker
+// finds them by name. This is not supported on Windows.
+func WithSandbox() ProviderOption {
+	return func(opts *providerOptions) {
+		opts.sandbox = true
+	}
+}
+
+// WithCallTimeout limits how long each call to a function of the extension may run, returning a TimeoutError from
+// calls that run longer. Calls within a sandbox worker are stopped by killing the worker, which is started again on the
+// next call. Other calls are canceled the same as BackendState.Cancel, so a function only stops once it next checks
+// for interrupts, and one that never checks runs until it returns. Calls into WebAssembly modules are not limited.
+func WithCallTimeout(timeout time.Duration) ProviderOption {
+	return func(opts *providerOptions) {
+		opts.callTimeout = timeout
+	}
+}
+
+// WithExtensionPolicy rejects the extension, without loading any of its libraries, when the given policy does not allow
+// the user to create it. The policy should describe the user of the session that is creating the provider.
+func WithExtensionPolicy(policy ExtensionPolicy) ProviderOption {
+	return func(opts *providerOptions) {
+		opts.policy = policy
+	}
+}
+
+// RunSandboxWorker runs the process as a sandbox worker when it was started as one by WithSandbox, serving calls until
+// the provider is closed, and then exits. This returns immediately within all other processes.
+func RunSandboxWorker() {
+	if os.Getenv(sandboxWorkerEnv) != "1" {
+		return
+	}
+	requests := os.NewFile(3, "sandbox-requests")
+	responses := os.NewFile(4, "sandbox-responses")
+	if err := serveSandbox(requests, responses); err != nil {
+		fmt.Fprintf(os.Stderr, "pg_extension sandbox worker: %s\n", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// sandboxOpen is the first request that is sent to a worker, which identifies the extension to load.
+type sandboxOpen struct {
+	Name           string
+	ControlFileDir string
+	// LibraryDirs are searched in order for the extension's libraries.
+	LibraryDirs []string
+	Logging     sandboxLogging
+}
+
+// sandboxOpened is the response to a sandboxOpen.
+type sandboxOpened struct {
+	// Unsupported contains an error for each C function of the extension, in the order that they're created, which is
+	// empty for the functions that may be called.
+	Unsupported []string
+	Err         string
+	Messages    []LogMessage
+}
+
+// sandboxCall is a request to call a function, which is identified by its position among the C functions.
+type sandboxCall struct {
+	Function int
+	Args     []any
+	Logging  sandboxLogging
+}
+
+// sandboxResult is the response to a sandboxCall.
+type sandboxResult struct {
+	Value any
+	Err   *sandboxError
+	// Messages are those that the extension reported below ERROR while handling the request, which the host gives to
+	// its own Logger.
+	Messages []LogMessage
+}
+
+// sandboxLogging is the host's logging at the time of a request, which the worker follows, so that messages that the
+// host would skip are skipped before they're formatted. Without a logger, the worker writes messages to its stderr,
+// which is the host's own.
+type sandboxLogging struct {
+	Enabled  bool
+	MinLevel LogLevel
+}
+
+// sandboxLogger collects the messages that are reported within a worker, which are sent to the host along with the
+// response to the current request.
+type sandboxLogger struct {
+	mutex    sync.Mutex
+	messages []LogMessage
+}
+
+// sandboxError is an error that was returned by a call within a worker. Only one of its fields is set.
+type sandboxError struct {
+	Postgres *PostgresError
+	Crash    *CrashError
+	Message  string
+}
+
+func init() {
+	// Values are sent as interfaces, so every type that Call accepts or returns, other than the basic types, is registered
+	gob.Register(new(big.Rat))
+	gob.Register(time.Time{})
+	gob.Register(Interval{})
+	gob.Register(json.RawMessage{})
+}
+
+// sandboxWorker is a worker process that has loaded an extension. The process is started on first use, and again after
+// it has exited.
+type sandboxWorker struct {
+	open sandboxOpen
+	// libraryPaths are the libraries that the worker loads, which the host verifies before each start, as the worker
+	// cannot run the host's verifier.
+	libraryPaths []string
+	// timeout is how long each call may run before the worker is killed, which is zero for calls without a timeout.
+	timeout time.Duration
+	// mutex gates access to the process, and serializes calls.
+	mutex     sync.Mutex
+	cmd       *exec.Cmd
+	requests  *os.File
+	writer    *bufio.Writer
+	encoder   *gob.Encoder
+	decoder   *gob.Decoder
+	exited    chan struct{}
+	exitError error
+	closed    bool
+}
+
+// newSandboxWorker starts a worker for the extension, returning the errors of its C functions that cannot be provided.
+func newSandboxWorker(extFile *ExtensionFiles, timeout time.Duration) (*sandboxWorker, []string, error) {
+	if runtime.GOOS == "windows" {
+		return nil, nil, fmt.Errorf("extension `%s` cannot be sandboxed on Windows", extFile.Name)
+	}
+	open := sandboxOpen{Name: extFile.Name, ControlFileDir: extFile.ControlFileDir}
+	if len(open.ControlFileDir) > 0 {
+		if dirs, ok := extFile.LibraryFS.(dirListFS); ok {
+			open.LibraryDirs = dirs
+		} else if len(extFile.LibraryFileDir) > 0 {
+			open.LibraryDirs = []string{extFile.LibraryFileDir}
+		}
+	}
+	libraryPaths, err := extFile.cLibraryPaths()
+	if err != nil {
+		return nil, nil, err
+	}
+	worker := &sandboxWorker{open: open, libraryPaths: libraryPaths, timeout: timeout}
+	worker.mutex.Lock()
+	defer worker.mutex.Unlock()
+	unsupported, err := worker.start()
+	if err != nil {
+		return nil, nil, err
+	}
+	return worker, unsupported, nil
+}
+
+// start starts the worker process, and loads the extension within it. This expects the mutex to be held.
+func (w *sandboxWorker) start() ([]string, error) {
+	if err := verifyLibraries(w.libraryPaths); err != nil {
+		return nil, withExtension(err, w.open.Name)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	requestReader, requestWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	responseReader, responseWriter, err := os.Pipe()
+	if err != nil {
+		_ = requestReader.Close()
+		_ = requestWriter.Close()
+		return nil, err
+	}
+	cmd := exec.Command(executable)
+	cmd.Env = append(os.Environ(), sandboxWorkerEnv+"=1")
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{requestReader, responseWriter}
+	err = cmd.Start()
+	// The worker has its own copies of these ends, so that the pipes report EOF once either process exits
+	_ = requestReader.Close()
+	_ = responseWriter.Close()
+	if err != nil {
+		_ = requestWriter.Close()
+		_ = responseReader.Close()
+		return nil, fmt.Errorf("could not start the sandbox worker of `%s`: %w", w.open.Name, err)
+	}
+	w.cmd = cmd
+	w.requests = requestWriter
+	w.writer = bufio.NewWriter(requestWriter)
+	w.encoder = gob.NewEncoder(w.writer)
+	w.decoder = gob.NewDecoder(bufio.NewReader(responseReader))
+	w.exited = make(chan struct{})
+	go func() {
+		w.exitError = cmd.Wait()
+		_ = responseReader.Close()
+		close(w.exited)
+	}()
+
+	open := w.open
+	open.Logging.Enabled, open.Logging.MinLevel = currentLoggerLevel()
+	var opened sandboxOpened
+	if err = w.exchange(open, &opened); err != nil {
+		return nil, err
+	}
+	for _, message := range opened.Messages {
+		logMessage(message)
+	}
+	if len(opened.Err) > 0 {
+		w.stop()
+		return nil, errors.New(opened.Err)
+	}
+	return opened.Unsupported, nil
+}
+
+// exchange sends the request to the worker and reads its response. When either fails, the worker is stopped, and an
+// error is returned that describes how it exited. This expects the mutex to be held.
+func (w *sandboxWorker) exchange(request any, response any) error {
+	err := w.encoder.Encode(request)
+	if err == nil {
+		err = w.writer.Flush()
+	}
+	if err == nil {
+		if err = w.decoder.Decode(response); err == nil {
+			return nil
+		}
+	}
+	w.stop()
+	if w.exitError != nil {
+		return fmt.Errorf("sandbox worker of `%s` exited: %w", w.open.Name, w.exitError)
+	}
+	return fmt.Errorf("sandbox worker of `%s` failed: %w", w.open.Name, err)
+}
+
+// stop closes the worker's requests, which ends the worker, and waits until it has exited. A worker that does not exit
+// on its own, such as one that is stuck within a call, is killed. This expects the mutex to be held.
+func (w *sandboxWorker) stop() {
+	if w.cmd == nil {
+		return
+	}
+	_ = w.requests.Close()
+	select {
+	case <-w.exited:
+	case <-time.After(5 * time.Second):
+		_ = w.cmd.Process.Kill()
+		<-w.exited
+	}
+	w.cmd = nil
+}
+
+// call calls the function at the given position within the worker, starting the worker if it's not running. A call
+// that runs longer than the timeout kills the worker.
+func (w *sandboxWorker) call(function int, args []any) (any, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return nil, fmt.Errorf("sandbox worker of `%s` has been closed", w.open.Name)
+	}
+	if w.cmd == nil {
+		if _, err := w.start(); err != nil {
+			return nil, err
+		}
+	}
+	var timer *time.Timer
+	if w.timeout > 0 {
+		process := w.cmd.Process
+		timer = time.AfterFunc(w.timeout, func() {
+			_ = process.Kill()
+		})
+	}
+	call := sandboxCall{Function: function, Args: args}
+	call.Logging.Enabled, call.Logging.MinLevel = currentLoggerLevel()
+	var result sandboxResult
+	err := w.exchange(call, &result)
+	for _, message := range result.Messages {
+		logMessage(message)
+	}
+	if timer != nil && !timer.Stop() {
+		// The worker was killed even if it responded in the meantime, so it's started again on the next call
+		w.stop()
+		return nil, TimeoutError{Timeout: w.timeout}
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case result.Err == nil:
+		return result.Value, nil
+	case result.Err.Postgres != nil:
+		return nil, *result.Err.Postgres
+	case result.Err.Crash != nil:
+		return nil, *result.Err.Crash
+	default:
+		return nil, errors.New(result.Err.Message)
+	}
+}
+
+// close stops the worker. It may not be used afterward.
+func (w *sandboxWorker) close() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.closed = true
+	w.stop()
+}
+
+// serveSandbox loads the extension that is named by the first request, and then calls its functions for each of the
+// following requests, until the requests are closed.
+func serveSandbox(requests io.Reader, responses io.Writer) error {
+	writer := bufio.NewWriter(responses)
+	encoder := gob.NewEncoder(writer)
+	decoder := gob.NewDecoder(bufio.NewReader(requests))
+	send := func(response any) error {
+		if err := encoder.Encode(response); err != nil {
+			return err
+		}
+		return writer.Flush()
+	}
+
+	logger := &sandboxLogger{}
+	var logging sandboxLogging
+	// follow sets the worker's logging to match the host's, which only calls into the shim when the host's has changed
+	follow := func(hostLogging sandboxLogging) error {
+		if hostLogging == logging {
+			return nil
+		}
+		logging = hostLogging
+		if !logging.Enabled {
+			return SetLogger(nil, 0)
+		}
+		return SetLogger(logger, logging.MinLevel)
+	}
+
+	var open sandboxOpen
+	if err := decoder.Decode(&open); err != nil {
+		return err
+	}
+	if err := follow(open.Logging); err != nil {
+		return err
+	}
+	functions, libs, opened := openSandboxedExtension(open)
+	defer func() {
+		for _, lib := range libs {
+			_ = lib.Close()
+		}
+	}()
+	opened.Messages = logger.take()
+	if err := send(opened); err != nil {
+		return err
+	}
+	for {
+		var call sandboxCall
+		if err := decoder.Decode(&call); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := follow(call.Logging); err != nil {
+			return err
+		}
+		var result sandboxResult
+		if call.Function < 0 || call.Function >= len(functions) || functions[call.Function] == nil {
+			result.Err = &sandboxError{Message: fmt.Sprintf("function %d cannot be called", call.Function)}
+		} else if value, err := functions[call.Function].Call(call.Args...); err != nil {
+			result.Err = newSandboxError(err)
+		} else {
+			result.Value = value
+		}
+		result.Messages = logger.take()
+		if err := send(result); err != nil {
+			return err
+		}
+	}
+}
+
+// Log implements the interface Logger.
+func (l *sandboxLogger) Log(message LogMessage) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.messages = append(l.messages, message)
+}
+
+// take returns the messages that have been collected since the last call, and removes them.
+func (l *sandboxLogger) take() []LogMessage {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	messages := l.messages
+	l.messages = nil
+	return messages
+}
+
+// openSandboxedExtension loads the libraries of the extension within the worker, returning its C functions in the
+// order that they're created. Functions that cannot be provided are nil, and their errors are within the response.
+func openSandboxedExtension(open sandboxOpen) ([]*ProvidedFunction, []*Library, sandboxOpened) {
+	var extFile *ExtensionFiles
+	var err error
+	if len(open.ControlFileDir) > 0 {
+		extFile, err = LoadExtensionFS(open.Name, os.DirFS(open.ControlFileDir), dirListFS(open.LibraryDirs))
+	} else {
+		extFile, err = loadBundledExtension(open.Name)
+	}
+	if err != nil {
+		return nil, nil, sandboxOpened{Err: err.Error()}
+	}
+	definitions, err := extFile.LoadSQLFunctionDefinitions()
+	if err != nil {
+		return nil, nil, sandboxOpened{Err: err.Error()}
+	}
+	libs, err := extFile.LoadLibraries()
+	if err != nil {
+		return nil, nil, sandboxOpened{Err: err.Error()}
+	}
+	var functions []*ProvidedFunction
+	var libList []*Library
+	var opened sandboxOpened
+	for _, lib := range libs {
+		libList = append(libList, lib)
+	}
+	for _, definition := range definitions {
+		if definition.Language != "c" {
+			continue
+		}
+		fn, err := newProvidedFunction(definition, libs[definition.Library])
+		if err != nil {
+			opened.Unsupported = append(opened.Unsupported, err.Error())
+		} else {
+			opened.Unsupported = append(opened.Unsupported, "")
+		}
+		functions = append(functions, fn)
+	}
+	return functions, libList, opened
+}
+
+// newSandboxError returns the error that is sent to the host for an error that was returned by a call.
+func newSandboxError(err error) *sandboxError {
+	var postgresErr PostgresError
+	var crashErr CrashError
+	switch {
+	case errors.As(err, &postgresErr):
+		return &sandboxError{Postgres: &postgresErr}
+	case errors.As(err, &crashErr):
+		return &sandboxError{Crash: &crashErr}
+	default:
+		return &sandboxError{Message: err.Error()}
+	}
+}
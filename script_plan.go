@@ -0,0 +1,323 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// PlannedStatement is a single statement that would be executed by an extension's scripts, along with the object that
+// it creates or modifies.
+type PlannedStatement struct {
+	// Script is the name of the SQL file that the statement came from.
+	Script string
+	// Action is the leading command of the statement, such as CREATE, ALTER, or COMMENT.
+	Action string
+	// ObjectKind is the kind of object that the statement acts on, such as FUNCTION or OPERATOR CLASS. This is empty
+	// for statements that do not act on an object.
+	ObjectKind string
+	// ObjectName is the name of the object that the statement acts on, which may be schema-qualified.
+	ObjectName string
+	// Statement is the full text of the statement.
+	Statement string
+	// Supported is whether the host is able to execute the statement.
+	Supported bool
+	// tokens are the tokens of the statement.
+	tokens []sqlToken
+}
+
+// ScriptPlan is the ordered list of statements that would be executed by an extension's scripts.
+type ScriptPlan struct {
+	Statements []PlannedStatement
+}
+
+// sqlObjectKinds are the kinds of objects that statements may act on. Kinds that are made up of multiple words must come
+// before any kinds that they begin with.
+var sqlObjectKinds = [][]string{
+	{"operator", "class"},
+	{"operator", "family"},
+	{"text", "search", "configuration"},
+	{"text", "search", "dictionary"},
+	{"text", "search", "parser"},
+	{"text", "search", "template"},
+	{"foreign", "data", "wrapper"},
+	{"foreign", "table"},
+	{"materialized", "view"},
+	{"event", "trigger"},
+	{"access", "method"},
+	{"user", "mapping"},
+	{"default", "privileges"},
+	{"large", "object"},
+	{"aggregate"},
+	{"cast"},
+	{"collation"},
+	{"conversion"},
+	{"domain"},
+	{"extension"},
+	{"function"},
+	{"index"},
+	{"language"},
+	{"operator"},
+	{"policy"},
+	{"procedure"},
+	{"publication"},
+	{"role"},
+	{"routine"},
+	{"rule"},
+	{"schema"},
+	{"sequence"},
+	{"server"},
+	{"statistics"},
+	{"subscription"},
+	{"table"},
+	{"transform"},
+	{"trigger"},
+	{"type"},
+	{"view"},
+}
+
+// sqlCreateModifiers are the keywords that may appear between CREATE and the object kind.
+var sqlCreateModifiers = map[string]struct{}{
+	"constraint": {},
+	"global":     {},
+	"local":      {},
+	"procedural": {},
+	"recursive":  {},
+	"temp":       {},
+	"temporary":  {},
+	"trusted":    {},
+	"unique":     {},
+	"unlogged":   {},
+}
+
+// sqlStandaloneActions are the actions that are recognized even though they do not act on a specific kind of object.
+var sqlStandaloneActions = map[string]struct{}{
+	"DELETE": {},
+	"DO":     {},
+	"GRANT":  {},
+	"INSERT": {},
+	"RESET":  {},
+	"REVOKE": {},
+	"SELECT": {},
+	"SET":    {},
+	"UPDATE": {},
+}
+
+// PlanScripts processes the extension's scripts without executing them, returning the statements that would be
+// executed in the order that they would be executed. The given function determines whether the host supports each
+// statement. If it is nil, then every recognized statement is considered supported.
+// psql meta-commands are omitted, as they're never executed by CREATE EXTENSION.
+func (extFile *ExtensionFiles) PlanScripts(isSupported func(PlannedStatement) bool) (ScriptPlan, error) {
+	var plan ScriptPlan
+	for _, sqlFileName := range extFile.SQLFileNames {
+		data, err := fs.ReadFile(extFile.ControlFS, sqlFileName)
+		if err != nil {
+			return ScriptPlan{}, err
+		}
+		for _, stmt := range splitSQLStatements(string(data)) {
+			if stmt.Tokens[0].Kind == sqlTokenMetaCommand {
+				continue
+			}
+			action, kind, name, _ := classifySQLStatement(stmt.Tokens)
+			planned := PlannedStatement{
+				Script:     sqlFileName,
+				Action:     action,
+				ObjectKind: kind,
+				ObjectName: name,
+				Statement:  stmt.Text,
+				tokens:     stmt.Tokens,
+			}
+			if isSupported != nil {
+				planned.Supported = isSupported(planned)
+			} else {
+				_, isStandalone := sqlStandaloneActions[action]
+				planned.Supported = len(kind) > 0 || isStandalone
+			}
+			plan.Statements = append(plan.Statements, planned)
+		}
+	}
+	return plan, nil
+}
+
+// Unsupported returns all statements in the plan that the host does not support.
+func (plan ScriptPlan) Unsupported() []PlannedStatement {
+	var unsupported []PlannedStatement
+	for _, stmt := range plan.Statements {
+		if !stmt.Supported {
+			unsupported = append(unsupported, stmt)
+		}
+	}
+	return unsupported
+}
+
+// String returns a human-readable summary of the statement.
+func (stmt PlannedStatement) String() string {
+	var sb strings.Builder
+	sb.WriteString(stmt.Action)
+	if len(stmt.ObjectKind) > 0 {
+		sb.WriteString(" ")
+		sb.WriteString(stmt.ObjectKind)
+	}
+	if len(stmt.ObjectName) > 0 {
+		sb.WriteString(" ")
+		sb.WriteString(stmt.ObjectName)
+	}
+	return fmt.Sprintf("%s (%s)", sb.String(), stmt.Script)
+}
+
+// classifySQLStatement returns the action, object kind, and object name for the statement with the given tokens, along
+// with the index of the token after the name. The action and object kind are returned in uppercase.
+func classifySQLStatement(tokens []sqlToken) (action string, kind string, name string, next int) {
+	if len(tokens) == 0 {
+		return "", "", "", 0
+	}
+	action = strings.ToUpper(tokens[0].Value())
+	i := 1
+	switch action {
+	case "CREATE":
+		if i+1 < len(tokens) && tokens[i].IsKeyword("or") && tokens[i+1].IsKeyword("replace") {
+			i += 2
+		}
+		for i < len(tokens) {
+			if _, ok := sqlCreateModifiers[tokens[i].Value()]; !ok || tokens[i].Kind != sqlTokenIdentifier {
+				break
+			}
+			i++
+		}
+	case "ALTER", "DROP":
+	case "COMMENT", "SECURITY":
+		// COMMENT ON and SECURITY LABEL [FOR provider] ON
+		for i < len(tokens) && !tokens[i].IsKeyword("on") {
+			i++
+		}
+		i++
+	default:
+		return action, "", "", 1
+	}
+	kind, i = matchSQLObjectKind(tokens, i)
+	if len(kind) == 0 {
+		return action, "", "", i
+	}
+	// Skip any existence checks and options that may precede the name
+	for i < len(tokens) && (tokens[i].IsKeyword("if") || tokens[i].IsKeyword("not") || tokens[i].IsKeyword("exists") ||
+		tokens[i].IsKeyword("concurrently")) {
+		i++
+	}
+	switch kind {
+	case "CAST":
+		name = sqlParenthesizedText(tokens, i)
+		next = i
+	case "OPERATOR":
+		name, next = parseSQLOperatorName(tokens, i)
+	case "INDEX":
+		// Indexes may be unnamed, in which case the name is generated from the table
+		next = i
+		if i < len(tokens) && !tokens[i].IsKeyword("on") {
+			name, next = parseSQLQualifiedName(tokens, i)
+		}
+	default:
+		name, next = parseSQLQualifiedName(tokens, i)
+	}
+	return action, kind, name, next
+}
+
+// matchSQLObjectKind matches the object kind that starts at the given token index, returning the kind in uppercase and
+// the index of the token after the kind. Returns an empty string if the tokens do not match a kind.
+func matchSQLObjectKind(tokens []sqlToken, i int) (string, int) {
+	for _, kindWords := range sqlObjectKinds {
+		if i+len(kindWords) > len(tokens) {
+			continue
+		}
+		matched := true
+		for j, word := range kindWords {
+			if !tokens[i+j].IsKeyword(word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return strings.ToUpper(strings.Join(kindWords, " ")), i + len(kindWords)
+		}
+	}
+	return "", i
+}
+
+// parseSQLQualifiedName parses the possibly schema-qualified name that starts at the given token index, returning the
+// name and the index of the token after the name.
+func parseSQLQualifiedName(tokens []sqlToken, i int) (string, int) {
+	parts, next := parseSQLQualifiedNameParts(tokens, i)
+	return strings.Join(parts, "."), next
+}
+
+// parseSQLQualifiedNameParts is the same as parseSQLQualifiedName, except that it returns each part of the name
+// separately.
+func parseSQLQualifiedNameParts(tokens []sqlToken, i int) ([]string, int) {
+	var parts []string
+	for i < len(tokens) {
+		if tokens[i].Kind != sqlTokenIdentifier && tokens[i].Kind != sqlTokenQuotedIdentifier {
+			break
+		}
+		parts = append(parts, tokens[i].Value())
+		i++
+		if i+1 < len(tokens) && tokens[i].IsPunctuation(".") {
+			i++
+			continue
+		}
+		break
+	}
+	return parts, i
+}
+
+// parseSQLOperatorName parses the possibly schema-qualified operator name that starts at the given token index,
+// returning the name and the index of the token after the name.
+func parseSQLOperatorName(tokens []sqlToken, i int) (string, int) {
+	prefix := ""
+	if i+2 < len(tokens) && (tokens[i].Kind == sqlTokenIdentifier || tokens[i].Kind == sqlTokenQuotedIdentifier) &&
+		tokens[i+1].IsPunctuation(".") {
+		prefix = tokens[i].Value() + "."
+		i += 2
+	}
+	if i < len(tokens) && tokens[i].Kind == sqlTokenOperator {
+		return prefix + tokens[i].Text, i + 1
+	}
+	return "", i
+}
+
+// sqlParenthesizedText returns the source text between the parentheses that start at the given token index.
+func sqlParenthesizedText(tokens []sqlToken, i int) string {
+	if i >= len(tokens) || !tokens[i].IsPunctuation("(") {
+		return ""
+	}
+	depth := 0
+	var parts []string
+	for j := i; j < len(tokens); j++ {
+		switch {
+		case tokens[j].IsPunctuation("("):
+			depth++
+			if depth == 1 {
+				continue
+			}
+		case tokens[j].IsPunctuation(")"):
+			depth--
+			if depth == 0 {
+				return strings.Join(parts, " ")
+			}
+		}
+		parts = append(parts, tokens[j].Text)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,324 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+*/
+import "C"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// Session is the state of a single session (connection) of the host. Postgres runs each session within its own
+// process, so extensions keep their memory contexts, configuration values, and the state that they cache within
+// fn_extra as though it were global. A Session scopes all of these to itself, so that one session's use of an extension
+// is not seen by another, and frees them once it's closed. All calls that are made on behalf of a session should be
+// made within its Run.
+//
+// Extensions keep their state in C globals, which every session shares, so only one session runs at a time (see
+// BackendState.Run). Runs of different sessions may be started from any number of goroutines, but each waits for the
+// backend lock, which a run only yields while its session waits, such as on a latch or an LWLock. Extensions read their
+// configuration variables from C globals as well, so the values that a session sets are assigned to the variables
+// whenever the session takes the lock, replacing those of the session that ran before it.
+type Session struct {
+	backend *BackendState
+	// mutex gates access to the handles.
+	mutex sync.Mutex
+	// handles contains the call handles of the session, keyed by the function and OID that they call.
+	handles map[sessionHandleKey]*FmgrInfo
+	// The following are gated by sessionConfigMutex.
+	// config contains the values that the session has set, keyed by the variable's lowercase name.
+	config map[string]string
+	closed bool
+}
+
+// sessionHandleKey identifies a call handle of a session.
+type sessionHandleKey struct {
+	ptr uintptr
+	oid uint32
+}
+
+// savedConfigValue is the host-wide value of a variable that has been replaced by a session's value.
+type savedConfigValue struct {
+	value  string
+	source ConfigSourceKind
+}
+
+var (
+	// sessionConfigMutex gates access to the assigned session, along with the configuration of every session. The
+	// assigned session is only changed while the backend lock is held as well, which is acquired beforehand.
+	sessionConfigMutex = &sync.Mutex{}
+	// sessions contains every open session, keyed by the handle of its backend state, so that its values may be
+	// assigned when the shim loads its state.
+	sessions = &sync.Map{}
+	// assignedSession is the session whose values are assigned to the variables of extensions, or nil when every
+	// variable has its host-wide value. The assignment remains after the session's run has ended, until a run with
+	// other values takes the backend lock.
+	assignedSession *Session
+	// savedConfigValues are the host-wide values of the variables that the assigned session has replaced, keyed by the
+	// variable's lowercase name.
+	savedConfigValues = make(map[string]savedConfigValue)
+)
+
+// NewSession returns a new Session. Close must be called once the session has ended.
+func NewSession() (*Session, error) {
+	backend, err := NewBackendState()
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{
+		backend: backend,
+		handles: make(map[sessionHandleKey]*FmgrInfo),
+		config:  make(map[string]string),
+	}
+	sessions.Store(backend.handle, s)
+	return s, nil
+}
+
+// Run runs the function on behalf of the session. The session's backend state is bound to the thread, and its
+// configuration values are assigned, for the duration of the function, which waits until no other session is running.
+func (s *Session) Run(f func()) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	return s.backend.Run(f)
+}
+
+// RunContext is the same as Run, except that the run is canceled once the context is done, the same as
+// BackendState.RunContext.
+func (s *Session) RunContext(ctx context.Context, f func()) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	return s.backend.RunContext(ctx, f)
+}
+
+// Cancel cancels the run of the session that is in progress, the same as BackendState.Cancel.
+func (s *Session) Cancel() error {
+	return s.backend.Cancel()
+}
+
+// FmgrInfo returns the session's call handle for the function, which reports the given OID to the function. The handle
+// is created on first use, and is closed along with the session. Functions cache state within their handle, such as
+// the prepared keys of pgcrypto, so each session has its own.
+func (s *Session) FmgrInfo(fn Function, oid uint32) (*FmgrInfo, error) {
+	unlock := lockBackend()
+	defer unlock()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.handles == nil {
+		return nil, fmt.Errorf("session has been closed")
+	}
+	key := sessionHandleKey{ptr: fn.Ptr, oid: oid}
+	if handle, ok := s.handles[key]; ok {
+		return handle, nil
+	}
+	handle, err := NewFmgrInfo(fn, oid)
+	if err != nil {
+		return nil, err
+	}
+	s.handles[key] = handle
+	return handle, nil
+}
+
+// SetUserID sets the role that the session runs as, the same as BackendState.SetUserID.
+func (s *Session) SetUserID(roleID uint32) error {
+	return s.backend.SetUserID(roleID)
+}
+
+// SetSessionInfo sets the details of the session that extensions see, the same as BackendState.SetSessionInfo.
+func (s *Session) SetSessionInfo(info SessionInfo) error {
+	return s.backend.SetSessionInfo(info)
+}
+
+// SetConfigVariable sets the variable for this session only, as SET does. This is otherwise the same as the
+// SetConfigVariable function, except that a variable that has not been defined is only given to this session once it
+// is defined.
+func (s *Session) SetConfigVariable(name string, value string, context ConfigContext) error {
+	return s.setConfigVariable(name, &value, context)
+}
+
+// ResetConfigVariable removes the value that the session set for the variable, so that it has its host-wide value
+// again, as RESET does.
+func (s *Session) ResetConfigVariable(name string, context ConfigContext) error {
+	return s.setConfigVariable(name, nil, context)
+}
+
+// ShowConfigVariable returns the value of the variable for this session, as SHOW does.
+func (s *Session) ShowConfigVariable(name string) (string, error) {
+	sessionConfigMutex.Lock()
+	defer sessionConfigMutex.Unlock()
+	lowerName := strings.ToLower(name)
+	if value, ok := s.config[lowerName]; ok {
+		return value, nil
+	}
+	return showHostConfigVariable(name)
+}
+
+// Close removes the session's configuration values, closes its call handles, and then frees its backend state. The
+// session may not be used afterward.
+func (s *Session) Close() error {
+	unlock := lockBackend()
+	sessionConfigMutex.Lock()
+	if s.closed {
+		sessionConfigMutex.Unlock()
+		unlock()
+		return nil
+	}
+	s.closed = true
+	sessions.Delete(s.backend.handle)
+	if assignedSession == s {
+		assignSessionConfig(nil)
+	}
+	clear(s.config)
+	sessionConfigMutex.Unlock()
+
+	s.mutex.Lock()
+	var errs []error
+	for _, handle := range s.handles {
+		errs = append(errs, handle.Close())
+	}
+	s.handles = nil
+	s.mutex.Unlock()
+	unlock()
+	errs = append(errs, s.backend.Close())
+	return errors.Join(errs...)
+}
+
+// configKey returns the session whose values this session's runs depend on, which is nil when the session has not set
+// any values, as such runs only depend on the host-wide values. This expects sessionConfigMutex to be held.
+func (s *Session) configKey() *Session {
+	if len(s.config) == 0 {
+		return nil
+	}
+	return s
+}
+
+// checkOpen returns an error if the session has been closed.
+func (s *Session) checkOpen() error {
+	sessionConfigMutex.Lock()
+	defer sessionConfigMutex.Unlock()
+	if s.closed {
+		return fmt.Errorf("session has been closed")
+	}
+	return nil
+}
+
+// setConfigVariable sets the session's value for the variable, or removes it when the value is nil. The session's
+// values are assigned beforehand, so that the check and assign hooks of the variable see the values of the session.
+// This takes the backend lock, so the host may set variables from within the session's own run on the same goroutine,
+// while a call from any other goroutine waits until no session is running.
+func (s *Session) setConfigVariable(name string, value *string, context ConfigContext) error {
+	unlock := lockBackend()
+	defer unlock()
+	sessionConfigMutex.Lock()
+	defer sessionConfigMutex.Unlock()
+	if s.closed {
+		return fmt.Errorf("session has been closed")
+	}
+	if assignedSession != s {
+		assignSessionConfig(s)
+	}
+
+	configVariablesMutex.Lock()
+	defer configVariablesMutex.Unlock()
+	lowerName := strings.ToLower(name)
+	v, ok := configVariables[lowerName]
+	if !ok {
+		// Variables that have not been defined are kept by the session alone, rather than as host-wide placeholders
+		if value == nil {
+			delete(s.config, lowerName)
+			return nil
+		}
+		if err := checkConfigPlaceholderName(name); err != nil {
+			return err
+		}
+		s.config[lowerName] = *value
+		return nil
+	}
+	if err := v.checkContext(context); err != nil {
+		return err
+	}
+	saved, ok := savedConfigValues[lowerName]
+	if !ok {
+		saved = savedConfigValue{value: v.current, source: v.source}
+	}
+	if value == nil {
+		delete(s.config, lowerName)
+		delete(savedConfigValues, lowerName)
+		return v.assign(saved.value, saved.source)
+	}
+	if err := v.assign(*value, ConfigSourceKindSession); err != nil {
+		return err
+	}
+	savedConfigValues[lowerName] = saved
+	s.config[lowerName] = v.current
+	return nil
+}
+
+// assignSessionConfig gives every variable that the assigned session replaced its host-wide value again, and then
+// assigns the values of the given session, which may be nil. Values that are no longer valid, such as when a check
+// hook has changed its mind, are removed from the session. This expects the backend lock and sessionConfigMutex to be
+// held.
+func assignSessionConfig(session *Session) {
+	configVariablesMutex.Lock()
+	defer configVariablesMutex.Unlock()
+	for name, saved := range savedConfigValues {
+		if v, ok := configVariables[name]; ok {
+			_ = v.assign(saved.value, saved.source)
+		}
+	}
+	clear(savedConfigValues)
+	assignedSession = session
+	if session == nil {
+		return
+	}
+	for name, value := range session.config {
+		v, ok := configVariables[name]
+		if !ok {
+			continue
+		}
+		saved := savedConfigValue{value: v.current, source: v.source}
+		if err := v.assign(value, ConfigSourceKindSession); err != nil {
+			delete(session.config, name)
+			continue
+		}
+		savedConfigValues[name] = saved
+	}
+}
+
+//export pgextHostConfigLoadSession
+func pgextHostConfigLoadSession(state *C.PgExtBackendState) {
+	// States that do not belong to a session, such as those of background workers, see the host-wide values
+	var key *Session
+	if value, ok := sessions.Load(uintptr(unsafe.Pointer(state))); ok {
+		key = value.(*Session)
+	}
+	sessionConfigMutex.Lock()
+	defer sessionConfigMutex.Unlock()
+	if key != nil {
+		key = key.configKey()
+	}
+	if assignedSession != key {
+		assignSessionConfig(key)
+	}
+}
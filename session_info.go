@@ -0,0 +1,61 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+// SessionInfo describes a session to extensions, which read it through MyProcPid, MyDatabaseId, application_name,
+// current_database, and pg_backend_pid. Logging and auditing extensions embed these in their output.
+type SessionInfo struct {
+	// ProcessID identifies the session the same as a backend's process ID would, and should be unique among the
+	// host's sessions. The ID of the host's process is used when this is zero.
+	ProcessID int32
+	// DatabaseID is the OID of the session's database, and DatabaseName is its name, which is "postgres" when empty.
+	DatabaseID   uint32
+	DatabaseName string
+	// ApplicationName is the application_name that the client gave.
+	ApplicationName string
+}
+
+var shimBackendStateSetInfo = newShimProc("pgext_backend_state_set_info")
+
+// SetSessionInfo sets the details of the session that extensions see. This is called once the session has started,
+// and again whenever its details change, such as when the client sets application_name. Names are truncated to 63
+// bytes, the same as Postgres. This may not be called from within Run.
+func (bs *BackendState) SetSessionInfo(info SessionInfo) error {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	if bs.handle == 0 {
+		return fmt.Errorf("backend state has been closed")
+	}
+	cInfo := Malloc[C.PgExtSessionInfo]()
+	defer Free(cInfo)
+	cInfo.proc_pid = C.int32_t(info.ProcessID)
+	cInfo.database_id = C.Oid(info.DatabaseID)
+	cInfo.database_name = C.CString(info.DatabaseName)
+	defer Free(cInfo.database_name)
+	cInfo.application_name = C.CString(info.ApplicationName)
+	defer Free(cInfo.application_name)
+	_, err := shimBackendStateSetInfo.Call(bs.handle, uintptr(unsafe.Pointer(cInfo)))
+	return err
+}
@@ -0,0 +1,232 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+
+static inline void SetFmgrInfoAddr(FmgrInfo* finfo, uintptr_t fn) {
+	finfo->fn_addr = (void*)fn;
+}
+
+static inline ReturnSetInfo* ToReturnSetInfo(uintptr_t rsi) {
+	return (ReturnSetInfo*)rsi;
+}
+*/
+import "C"
+import (
+	"errors"
+	"iter"
+	"unsafe"
+)
+
+var (
+	shimSrfBegin      = newShimProc("pgext_srf_begin")
+	shimSrfEnd        = newShimProc("pgext_srf_end")
+	shimTuplestoreRow = newShimProc("pgext_tuplestore_row")
+)
+
+// Column describes a column of the rows that are returned by a set-returning function.
+type Column struct {
+	Name    string
+	TypeOID uint32
+	TypMod  int32
+	// Len is the length of the type in bytes, or -1 for varlena types and -2 for null-terminated strings.
+	Len   int16
+	ByVal bool
+	// Align is the alignment of the type, using the same characters as Postgres: 'c', 's', 'i', or 'd'.
+	Align byte
+}
+
+// CallFmgrSetFunction calls the given set-returning function, which returns its values one per call. The function is
+// called each time that the sequence advances, so stopping early skips the remaining calls. If the function raises an
+// error, then it is the last element of the sequence.
+func CallFmgrSetFunction(fn uintptr, args ...NullableDatum) iter.Seq2[NullableDatum, error] {
+	return CallFmgrSetFunctionColl(fn, 0, args...)
+}
+
+// CallFmgrSetFunctionColl is the same as CallFmgrSetFunction, except that the function is called with the given
+// collation.
+func CallFmgrSetFunctionColl(fn uintptr, collation uint32, args ...NullableDatum) iter.Seq2[NullableDatum, error] {
+	return firstColumn(callSetFunction(fn, nil, func(fi *C.FmgrInfo, rsi *C.ReturnSetInfo) (Datum, bool, error) {
+		return callFmgrInfo(fi, callNodes{resultInfo: rsi}, collation, args...)
+	}))
+}
+
+// CallFmgrTableFunction calls the given set-returning function, whose rows have the given columns. The function may
+// either return its values one per call, or materialize all of its rows into a tuplestore. By-reference values remain
+// valid until the sequence ends.
+func CallFmgrTableFunction(fn uintptr, columns []Column, args ...NullableDatum) iter.Seq2[[]NullableDatum, error] {
+	return CallFmgrTableFunctionColl(fn, 0, columns, args...)
+}
+
+// CallFmgrTableFunctionColl is the same as CallFmgrTableFunction, except that the function is called with the given
+// collation.
+func CallFmgrTableFunctionColl(fn uintptr, collation uint32, columns []Column,
+	args ...NullableDatum) iter.Seq2[[]NullableDatum, error] {
+	return callSetFunction(fn, columns, func(fi *C.FmgrInfo, rsi *C.ReturnSetInfo) (Datum, bool, error) {
+		return callFmgrInfo(fi, callNodes{resultInfo: rsi}, collation, args...)
+	})
+}
+
+// CallSet calls the set-returning function, recording each call against the resource usage of its library. STRICT
+// functions return an empty set without being called when any argument is NULL.
+func (f Function) CallSet(args ...NullableDatum) iter.Seq2[NullableDatum, error] {
+	return f.CallSetColl(0, args...)
+}
+
+// CallSetColl is the same as CallSet, except that the function is called with the given collation.
+func (f Function) CallSetColl(collation uint32, args ...NullableDatum) iter.Seq2[NullableDatum, error] {
+	return firstColumn(f.CallTableColl(collation, nil, args...))
+}
+
+// CallTable is the same as CallSet, except that the rows have the given columns, and may be materialized by the
+// function as they are for CallFmgrTableFunction.
+func (f Function) CallTable(columns []Column, args ...NullableDatum) iter.Seq2[[]NullableDatum, error] {
+	return f.CallTableColl(0, columns, args...)
+}
+
+// CallTableColl is the same as CallTable, except that the function is called with the given collation.
+func (f Function) CallTableColl(collation uint32, columns []Column,
+	args ...NullableDatum) iter.Seq2[[]NullableDatum, error] {
+	if f.Strict && hasNullArg(args) {
+		return func(yield func([]NullableDatum, error) bool) {}
+	}
+	return callSetFunction(f.Ptr, columns, func(fi *C.FmgrInfo, rsi *C.ReturnSetInfo) (Datum, bool, error) {
+		result, isNull, _, err := f.callWithInfo(fi, callNodes{resultInfo: rsi}, collation, args...)
+		return result, isNull, err
+	})
+}
+
+// setFunctionCall makes a single call to a set-returning function, using the FmgrInfo and result info that persist
+// across all of its calls.
+type setFunctionCall func(fi *C.FmgrInfo, rsi *C.ReturnSetInfo) (result Datum, isNull bool, err error)
+
+// firstColumn returns a sequence of the first value of each row.
+func firstColumn(rows iter.Seq2[[]NullableDatum, error]) iter.Seq2[NullableDatum, error] {
+	return func(yield func(NullableDatum, error) bool) {
+		for row, err := range rows {
+			var value NullableDatum
+			if len(row) > 0 {
+				value = row[0]
+			}
+			if !yield(value, err) {
+				return
+			}
+		}
+	}
+}
+
+// callSetFunction returns the sequence of rows of the set-returning function, which is called using the given call.
+// Functions may only materialize their rows when columns are given, as the columns are given to the function as its
+// expected result.
+func callSetFunction(fn uintptr, columns []Column, call setFunctionCall) iter.Seq2[[]NullableDatum, error] {
+	return func(yield func([]NullableDatum, error) bool) {
+		fi := Malloc[C.FmgrInfo]()
+		defer Free(fi)
+		ZeroMemory(fi)
+		C.SetFmgrInfoAddr(fi, C.uintptr_t(fn))
+		fi.fn_retset = true
+		allowedModes := uintptr(C.SFRM_ValuePerCall)
+		if len(columns) > 0 {
+			allowedModes |= C.SFRM_Materialize
+		}
+		rsiPtr, err := shimSrfBegin.Call(allowedModes, uintptr(len(columns)))
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		if rsiPtr == 0 {
+			yield(nil, errors.New("out of memory while calling a set-returning function"))
+			return
+		}
+		rsi := C.ToReturnSetInfo(C.uintptr_t(rsiPtr))
+		initTupleDesc(rsi.expectedDesc, columns)
+		// stopped is true when the sequence has ended early, either by the caller or by an error
+		stopped := func() bool {
+			for {
+				rsi.isDone = C.ExprSingleResult
+				result, isNull, err := call(fi, rsi)
+				if err != nil {
+					yield(nil, err)
+					return true
+				}
+				if rsi.returnMode == C.SFRM_Materialize {
+					if allowedModes&C.SFRM_Materialize == 0 {
+						yield(nil, errors.New("set-returning functions must return their values one per call"))
+						return true
+					}
+					return readTuplestore(rsi.setResult, yield)
+				}
+				switch rsi.isDone {
+				case C.ExprEndResult:
+					return false
+				case C.ExprSingleResult:
+					// Functions that do not follow the protocol return a single value
+					return !yield([]NullableDatum{{Value: result, IsNull: isNull}}, nil)
+				default:
+					if !yield([]NullableDatum{{Value: result, IsNull: isNull}}, nil) {
+						return true
+					}
+				}
+			}
+		}()
+		// Ending the call frees the state of functions that did not return all of their values
+		edata, err := shimSrfEnd.Call(rsiPtr)
+		if stopped {
+			return
+		}
+		if err != nil {
+			yield(nil, err)
+		} else if edata != 0 {
+			yield(nil, newCallError(edata))
+		}
+	}
+}
+
+// readTuplestore yields every row of the tuplestore that was materialized by a set-returning function. Returns true if
+// the caller stopped early.
+func readTuplestore(state *C.Tuplestorestate, yield func([]NullableDatum, error) bool) bool {
+	// A function that returns an empty set may leave the tuplestore unset
+	if state == nil {
+		return false
+	}
+	natts := max(int(state.natts), 1)
+	values := (*C.Datum)(C.calloc(C.size_t(natts), C.size_t(unsafe.Sizeof(C.Datum(0)))))
+	defer Free(values)
+	isnull := (*C.bool)(C.calloc(C.size_t(natts), C.size_t(unsafe.Sizeof(C.bool(false)))))
+	defer Free(isnull)
+	valuesSlice := unsafe.Slice(values, natts)
+	isnullSlice := unsafe.Slice(isnull, natts)
+	for index := uintptr(0); ; index++ {
+		ok, err := shimTuplestoreRow.Call(uintptr(unsafe.Pointer(state)), index, uintptr(unsafe.Pointer(values)),
+			uintptr(unsafe.Pointer(isnull)))
+		if err != nil {
+			yield(nil, err)
+			return true
+		}
+		if ok == 0 {
+			return false
+		}
+		row := make([]NullableDatum, state.natts)
+		for i := range row {
+			row[i] = NullableDatum{Value: Datum(valuesSlice[i]), IsNull: bool(isnullSlice[i])}
+		}
+		if !yield(row, nil) {
+			return true
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+var shimSetSharePath = newShimProc("pgext_set_share_path")
+
+// SetShareDirectory sets the share directory of the Postgres installation, which is the directory that pg_config
+// reports through --sharedir. Text search dictionaries, such as unaccent, read their rules from the tsearch_data
+// directory within it. Dictionaries fail to find their files until this is set.
+func SetShareDirectory(dir string) error {
+	cDir := C.CString(dir)
+	defer C.free(unsafe.Pointer(cDir))
+	result, err := shimSetSharePath.Call(uintptr(unsafe.Pointer(cDir)))
+	if err != nil {
+		return err
+	}
+	if result == 0 {
+		return fmt.Errorf("share directory `%s` is too long", dir)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+var (
+	// sharedMemoryInitialized is set once InitializeSharedMemory has been called, as the hooks only run once.
+	sharedMemoryInitialized bool
+	sharedMemoryMutex       = &sync.Mutex{}
+	shimShmemInitialize     = newShimProc("pgext_shmem_initialize")
+)
+
+// InitializeSharedMemory runs the shmem_request_hook and then the shmem_startup_hook that extensions installed, which
+// the host calls once every library has been loaded with WithSharedPreload, and before StartBackgroundWorkers, the
+// same as the postmaster does before it starts any backend. Shared memory is an ordinary allocation of the process, so
+// every session and background worker sees the same structs, and the LWLocks within them are backed by Go locks.
+// Extensions request their memory and named LWLock tranches from the request hook, or from _PG_init for extensions
+// that predate it, and create their structs from the startup hook. Libraries that are loaded afterward may still
+// create structs through ShmemInitStruct, but their hooks are never run. Returns the error that a hook raised, or an
+// error if this was already called.
+func InitializeSharedMemory() error {
+	sharedMemoryMutex.Lock()
+	defer sharedMemoryMutex.Unlock()
+	if sharedMemoryInitialized {
+		return fmt.Errorf("shared memory has already been initialized")
+	}
+	sharedMemoryInitialized = true
+	// The current memory context belongs to the state of the current thread, so we must remain on the same thread until
+	// the hooks have returned
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	edata, err := shimShmemInitialize.Call()
+	if err != nil {
+		return err
+	}
+	if edata != 0 {
+		return newCallError(edata)
+	}
+	return nil
+}
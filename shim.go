@@ -0,0 +1,164 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#include <stdint.h>
+
+typedef uintptr_t (*ShimProc0) (void);
+typedef uintptr_t (*ShimProc1) (uintptr_t);
+typedef uintptr_t (*ShimProc2) (uintptr_t, uintptr_t);
+typedef uintptr_t (*ShimProc3) (uintptr_t, uintptr_t, uintptr_t);
+typedef uintptr_t (*ShimProc4) (uintptr_t, uintptr_t, uintptr_t, uintptr_t);
+
+static inline uintptr_t CallShimProcArgs(uintptr_t fn, int nargs, uintptr_t a1, uintptr_t a2, uintptr_t a3,
+	uintptr_t a4) {
+	switch (nargs) {
+	case 0: return ((ShimProc0)(void *)fn)();
+	case 1: return ((ShimProc1)(void *)fn)(a1);
+	case 2: return ((ShimProc2)(void *)fn)(a1, a2);
+	case 3: return ((ShimProc3)(void *)fn)(a1, a2, a3);
+	default: return ((ShimProc4)(void *)fn)(a1, a2, a3, a4);
+	}
+}
+
+// CallShimProc calls the shim function, writing any error that it raised to edata. The error is taken on the same
+// thread as the call, and any error that was left over from before the call is discarded. The backend lock is held for
+// the duration of the call when lock and unlock are given, which is reentrant for a thread that already holds it.
+static inline uintptr_t CallShimProc(uintptr_t fn, uintptr_t take_error, uintptr_t lock, uintptr_t unlock, int nargs,
+	uintptr_t a1, uintptr_t a2, uintptr_t a3, uintptr_t a4, uintptr_t* edata) {
+	if (lock != 0) {
+		((ShimProc0)(void *)lock)();
+	}
+	((ShimProc0)(void *)take_error)();
+	uintptr_t result = CallShimProcArgs(fn, nargs, a1, a2, a3, a4);
+	*edata = ((ShimProc0)(void *)take_error)();
+	if (unlock != 0) {
+		((ShimProc0)(void *)unlock)();
+	}
+	return result;
+}
+*/
+import "C"
+import (
+	"fmt"
+	"sync"
+)
+
+// shimProc is a function within the pg_extension shim library that is used by the host to control the shim. Such
+// functions are prefixed with "pgext_", and only take and return pointer-sized integers, so that they may all be called
+// through the same trampoline. Every call holds the backend lock, as the shim and the extensions that it calls access
+// globals that every thread shares, unless the function was created with newUnlockedShimProc.
+type shimProc struct {
+	name string
+	once sync.Once
+	ptr  uintptr
+	err  error
+	// unlocked is set for functions that are called without the backend lock.
+	unlocked bool
+}
+
+// loadShim loads the shim library, which is only done once.
+var loadShim = sync.OnceValues(loadShimInternal)
+
+// newShimProc returns a shimProc for the function with the given name. The function is resolved on first use.
+func newShimProc(name string) *shimProc {
+	return &shimProc{name: name}
+}
+
+// newUnlockedShimProc is the same as newShimProc, except that the function is called without the backend lock. This is
+// only for functions that don't access the globals that the lock protects, and that must be callable while another
+// thread holds it, such as those that cancel a call that's in progress.
+func newUnlockedShimProc(name string) *shimProc {
+	return &shimProc{name: name, unlocked: true}
+}
+
+// shimTakeError returns the error that the shim raised outside of any call, which is checked after every shim call.
+var shimTakeError = newShimProc("pgext_take_error")
+
+// Call calls the shim function with the given arguments. Errors that the function raises are returned as a
+// PostgresError.
+func (p *shimProc) Call(args ...uintptr) (uintptr, error) {
+	result, edata, err := p.call(args...)
+	if err != nil {
+		return 0, err
+	}
+	if edata != 0 {
+		return result, newCallError(edata)
+	}
+	return result, nil
+}
+
+// call calls the shim function with the given arguments, returning the error data of any error that it raised.
+func (p *shimProc) call(args ...uintptr) (result uintptr, edata uintptr, err error) {
+	if _, err = p.addr(); err != nil {
+		return 0, 0, err
+	}
+	takeError, err := shimTakeError.addr()
+	if err != nil {
+		return 0, 0, err
+	}
+	var lock, unlock uintptr
+	if !p.unlocked {
+		if lock, err = shimBackendLockAcquire.addr(); err != nil {
+			return 0, 0, err
+		}
+		if unlock, err = shimBackendLockRelease.addr(); err != nil {
+			return 0, 0, err
+		}
+	}
+	if len(args) > 4 {
+		return 0, 0, fmt.Errorf("shim function `%s` was called with too many arguments", p.name)
+	}
+	var cArgs [4]C.uintptr_t
+	for i, arg := range args {
+		cArgs[i] = C.uintptr_t(arg)
+	}
+	var cEdata C.uintptr_t
+	ret := C.CallShimProc(C.uintptr_t(p.ptr), C.uintptr_t(takeError), C.uintptr_t(lock), C.uintptr_t(unlock),
+		C.int(len(args)), cArgs[0], cArgs[1], cArgs[2], cArgs[3], &cEdata)
+	return uintptr(ret), uintptr(cEdata), nil
+}
+
+// addr returns the address of the shim function, resolving it on first use. This is also used for the built-in
+// functions of Postgres that the shim implements, such as numeric_in, which are called through the function manager
+// rather than the trampoline.
+func (p *shimProc) addr() (uintptr, error) {
+	p.once.Do(func() {
+		shim, err := loadShim()
+		if err != nil {
+			p.err = err
+			return
+		}
+		addr, err := shim.Lookup(p.name)
+		if err != nil {
+			p.err = fmt.Errorf("the pg_extension library does not export `%s`", p.name)
+			return
+		}
+		p.ptr = addr
+	})
+	return p.ptr, p.err
+}
+
+// MustCall is the same as Call, except that it panics if the shim function cannot be found. This should only be used
+// for functions that are called after the shim is known to have been loaded. Errors that the function raises are
+// discarded, as these functions only release or switch state, and the host has nowhere to report them.
+func (p *shimProc) MustCall(args ...uintptr) uintptr {
+	ret, _, err := p.call(args...)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
@@ -0,0 +1,50 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"embed"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+)
+
+// embeddedShims contains the shim library of each platform, within a directory named after the platform (such as
+// "linux_amd64"). Linux platforms that use musl rather than glibc have their own directory (such as "linux_amd64_musl").
+// See shim/README.md for how the libraries are added.
+//
+//go:embed shim
+var embeddedShims embed.FS
+
+// embeddedShimPath extracts the shim library that was embedded for the current platform, and returns the path of the
+// extracted library. Returns an empty path if no library was embedded for the platform.
+func embeddedShimPath(fileName string) (string, error) {
+	platform := runtime.GOOS + "_" + runtime.GOARCH
+	if hostLibc() == libcMusl {
+		platform += "_musl"
+	}
+	platformFS, err := fs.Sub(embeddedShims, "shim/"+platform)
+	if err != nil {
+		return "", err
+	}
+	if _, err = fs.Stat(platformFS, fileName); err != nil {
+		return "", nil
+	}
+	libDir, err := extractLibrary(platformFS, fileName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(libDir, fileName), nil
+}
@@ -0,0 +1,271 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+
+extern void pgextHostSPIExecute(char* query, int nargs, uint32_t* argtypes, Datum* values, bool* nulls, bool read_only,
+	int64_t tcount, PgExtSPIResult* result);
+extern void pgextHostSPIRelease(uintptr_t handle);
+extern bool pgextHostSPIOutput(uint32_t typeoid, Datum value, char** text);
+
+static inline PgExtSPIExecutor* NewHostSPIExecutor() {
+	PgExtSPIExecutor* executor = (PgExtSPIExecutor*)malloc(sizeof(PgExtSPIExecutor));
+	executor->execute = (void (*)(const char*, int, Oid*, Datum*, bool*, bool, int64_t, PgExtSPIResult*))
+		pgextHostSPIExecute;
+	executor->release = pgextHostSPIRelease;
+	executor->output = (bool (*)(Oid, Datum, char**))pgextHostSPIOutput;
+	return executor;
+}
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"runtime/cgo"
+	"sync"
+	"unsafe"
+)
+
+// SPIExecutor runs the queries that extensions make through SPI, such as with SPI_execute. Extensions expect their
+// queries to run within the session and transaction of the query that called them, which the executor is responsible
+// for.
+type SPIExecutor interface {
+	// Execute runs the query with the given arguments, which the query references as $1, $2, and so on. When readOnly is
+	// set, the query must not modify any data. Limit is the maximum number of rows that should be returned, or zero for
+	// no limit. Errors are raised within the extension, keeping the SQLSTATE of a PostgresError.
+	Execute(query string, args []SPIArg, readOnly bool, limit int64) (SPIResult, error)
+	// Output returns the text of a value of the given type, which extensions retrieve through SPI_getvalue.
+	Output(typeOID uint32, value Datum) (string, error)
+}
+
+// SPIStatus describes the kind of query that was run through SPI, which is returned to the extension.
+type SPIStatus int
+
+const (
+	SPIStatusUtility         SPIStatus = C.SPI_OK_UTILITY
+	SPIStatusSelect          SPIStatus = C.SPI_OK_SELECT
+	SPIStatusSelectInto      SPIStatus = C.SPI_OK_SELINTO
+	SPIStatusInsert          SPIStatus = C.SPI_OK_INSERT
+	SPIStatusDelete          SPIStatus = C.SPI_OK_DELETE
+	SPIStatusUpdate          SPIStatus = C.SPI_OK_UPDATE
+	SPIStatusInsertReturning SPIStatus = C.SPI_OK_INSERT_RETURNING
+	SPIStatusDeleteReturning SPIStatus = C.SPI_OK_DELETE_RETURNING
+	SPIStatusUpdateReturning SPIStatus = C.SPI_OK_UPDATE_RETURNING
+	SPIStatusMerge           SPIStatus = C.SPI_OK_MERGE
+)
+
+// SPIArg is an argument of a query that was run through SPI.
+type SPIArg struct {
+	TypeOID uint32
+	Value   NullableDatum
+}
+
+// SPIResult is the result of a query that was run through SPI.
+type SPIResult struct {
+	// Status is the kind of query that was run. When zero, it's SPIStatusSelect for queries that return rows, and
+	// SPIStatusUtility otherwise.
+	Status SPIStatus
+	// Processed is the number of rows that the query processed. When zero, it's the number of rows that were returned.
+	Processed uint64
+	// Columns describe the rows that the query returned, which is nil for queries that do not return rows.
+	Columns []Column
+	// Rows are the rows that the query returned, with a value for each column. The rows are copied into the memory of
+	// the extension, so by-reference values only need to remain valid until Release is called.
+	Rows [][]NullableDatum
+	// Release is called once the rows have been copied, and may be nil.
+	Release func()
+}
+
+// spiPending is a result that has been given to the shim, whose memory is freed once the shim releases it.
+type spiPending struct {
+	desc    *C.TupleDescData
+	numRows int
+	// values and isnull hold each row one after another, with a value for each column.
+	values  *C.Datum
+	isnull  *C.bool
+	release func()
+}
+
+var (
+	// currentSPIExecutor is the executor that runs the queries of extensions, or nil if extensions may not run queries.
+	currentSPIExecutor SPIExecutor
+	// spiExecutorMutex gates access to the executor.
+	spiExecutorMutex = &sync.RWMutex{}
+	// hostSPIExecutor is the C struct that forwards to the Go executor.
+	hostSPIExecutor = sync.OnceValue(func() *C.PgExtSPIExecutor {
+		return C.NewHostSPIExecutor()
+	})
+	shimSetSPIExecutor = newShimProc("pgext_set_spi_executor")
+)
+
+// SetSPIExecutor sets the executor that runs the queries that all extensions make through SPI. Setting nil causes such
+// queries to raise an error, which is the default.
+func SetSPIExecutor(executor SPIExecutor) error {
+	spiExecutorMutex.Lock()
+	currentSPIExecutor = executor
+	spiExecutorMutex.Unlock()
+	var executorPtr uintptr
+	if executor != nil {
+		executorPtr = uintptr(unsafe.Pointer(hostSPIExecutor()))
+	}
+	_, err := shimSetSPIExecutor.Call(executorPtr)
+	return err
+}
+
+// spiExecutor returns the current executor, or an error if none has been set.
+func spiExecutor() (SPIExecutor, error) {
+	spiExecutorMutex.RLock()
+	defer spiExecutorMutex.RUnlock()
+	if currentSPIExecutor == nil {
+		return nil, errors.New("cannot run SPI queries as the host has not registered an executor")
+	}
+	return currentSPIExecutor, nil
+}
+
+// newSPIPending copies the result into memory that the shim can read, returning at most limit rows when limit is
+// positive.
+func newSPIPending(res SPIResult, limit int64) (*spiPending, error) {
+	if res.Columns == nil {
+		if len(res.Rows) > 0 {
+			return nil, errors.New("SPI query returned rows without describing their columns")
+		}
+		return &spiPending{release: res.Release}, nil
+	}
+	rows := res.Rows
+	if limit > 0 && int64(len(rows)) > limit {
+		rows = rows[:limit]
+	}
+	numColumns := len(res.Columns)
+	for _, row := range rows {
+		if len(row) != numColumns {
+			return nil, fmt.Errorf("SPI query returned a row with %d values but has %d columns", len(row), numColumns)
+		}
+	}
+	pending := &spiPending{numRows: len(rows), release: res.Release}
+	pending.desc = newTupleDesc(res.Columns)
+	numValues := max(len(rows)*numColumns, 1)
+	pending.values = (*C.Datum)(C.calloc(C.size_t(numValues), C.size_t(unsafe.Sizeof(C.Datum(0)))))
+	pending.isnull = (*C.bool)(C.calloc(C.size_t(numValues), C.size_t(unsafe.Sizeof(C.bool(false)))))
+	if pending.desc == nil || pending.values == nil || pending.isnull == nil {
+		pending.release = nil
+		pending.free()
+		return nil, errors.New("out of memory while returning the result of an SPI query")
+	}
+	valuesSlice := unsafe.Slice(pending.values, numValues)
+	isnullSlice := unsafe.Slice(pending.isnull, numValues)
+	for i, row := range rows {
+		for j, value := range row {
+			valuesSlice[i*numColumns+j] = C.Datum(value.Value)
+			isnullSlice[i*numColumns+j] = C.bool(value.IsNull)
+		}
+	}
+	return pending, nil
+}
+
+// free frees the memory of the result, and then calls its release function.
+func (pending *spiPending) free() {
+	Free(pending.desc)
+	Free(pending.values)
+	Free(pending.isnull)
+	if pending.release != nil {
+		pending.release()
+	}
+}
+
+// setSPIError sets the error of the result, which the shim raises within the extension.
+func setSPIError(result *C.PgExtSPIResult, err error) {
+	message := err.Error()
+	var pgErr PostgresError
+	if errors.As(err, &pgErr) {
+		message = pgErr.Message
+		result.sqlerrcode = C.int(encodeSQLState(pgErr.Code))
+	}
+	result.message = C.CString(message)
+}
+
+//export pgextHostSPIExecute
+func pgextHostSPIExecute(query *C.char, nargs C.int, argtypes *C.uint32_t, values *C.Datum, nulls *C.bool,
+	readOnly C.bool, tcount C.int64_t, result *C.PgExtSPIResult) {
+	executor, err := spiExecutor()
+	if err != nil {
+		setSPIError(result, err)
+		return
+	}
+	args := make([]SPIArg, int(nargs))
+	if len(args) > 0 {
+		argtypesSlice := unsafe.Slice(argtypes, len(args))
+		valuesSlice := unsafe.Slice(values, len(args))
+		nullsSlice := unsafe.Slice(nulls, len(args))
+		for i := range args {
+			args[i] = SPIArg{
+				TypeOID: uint32(argtypesSlice[i]),
+				Value:   NullableDatum{Value: Datum(valuesSlice[i]), IsNull: bool(nullsSlice[i])},
+			}
+		}
+	}
+	res, err := executor.Execute(C.GoString(query), args, bool(readOnly), int64(tcount))
+	if err != nil {
+		setSPIError(result, err)
+		return
+	}
+	pending, err := newSPIPending(res, int64(tcount))
+	if err != nil {
+		if res.Release != nil {
+			res.Release()
+		}
+		setSPIError(result, err)
+		return
+	}
+	if res.Status == 0 {
+		res.Status = SPIStatusUtility
+		if res.Columns != nil {
+			res.Status = SPIStatusSelect
+		}
+	}
+	if res.Processed == 0 {
+		res.Processed = uint64(pending.numRows)
+	}
+	result.status = C.int(res.Status)
+	result.processed = C.uint64_t(res.Processed)
+	result.tupdesc = pending.desc
+	result.nrows = C.uint64_t(pending.numRows)
+	result.values = pending.values
+	result.isnull = pending.isnull
+	result.handle = C.uintptr_t(cgo.NewHandle(pending))
+}
+
+//export pgextHostSPIRelease
+func pgextHostSPIRelease(handle C.uintptr_t) {
+	h := cgo.Handle(handle)
+	h.Value().(*spiPending).free()
+	h.Delete()
+}
+
+//export pgextHostSPIOutput
+func pgextHostSPIOutput(typeOID C.uint32_t, value C.Datum, text **C.char) C.bool {
+	executor, err := spiExecutor()
+	if err == nil {
+		var str string
+		if str, err = executor.Output(uint32(typeOID), Datum(value)); err == nil {
+			*text = C.CString(str)
+			return true
+		}
+	}
+	*text = C.CString(err.Error())
+	return false
+}
@@ -0,0 +1,216 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AggregateDefinition is an aggregate that is created by an extension's SQL files through CREATE AGGREGATE.
+type AggregateDefinition struct {
+	Schema string
+	Name   string
+	// Parameters are the aggregated arguments. For ordered-set aggregates, these are the arguments that follow ORDER BY.
+	// This is empty for aggregates that take `*`.
+	Parameters []FunctionParameter
+	// DirectParameters are the arguments of an ordered-set aggregate that precede ORDER BY, which are only evaluated once
+	// per group.
+	DirectParameters []FunctionParameter
+	OrderedSet       bool
+	Hypothetical     bool
+	// SFunc is the transition function, which is called with the state and the arguments of each row.
+	SFunc string
+	// SType is the type of the state.
+	SType string
+	// FinalFunc is the function that computes the result from the state, which is empty when the state is the result.
+	FinalFunc string
+	// FinalFuncExtra is true when the final function is given a NULL for each argument after the state.
+	FinalFuncExtra bool
+	CombineFunc    string
+	SerialFunc     string
+	DeserialFunc   string
+	// InitCond is the text representation of the initial state, which is only set when HasInitCond is true. Without an
+	// initial condition, the state begins as NULL.
+	InitCond    string
+	HasInitCond bool
+	SortOp      string
+	// Parallel is the parallel safety of the aggregate, which is one of "safe", "restricted", or "unsafe".
+	Parallel string
+	// Script is the name of the SQL file that created the aggregate.
+	Script string
+}
+
+// LoadSQLAggregateDefinitions loads the definitions of all aggregates that are created by the extension's SQL files, in
+// the order that they're created.
+func (extFile *ExtensionFiles) LoadSQLAggregateDefinitions() ([]*AggregateDefinition, error) {
+	var definitions []*AggregateDefinition
+	err := extFile.forEachSQLStatement(func(sqlFileName string, tokens []sqlToken) error {
+		agg, err := parseSQLCreateAggregate(tokens)
+		if agg != nil {
+			agg.Script = sqlFileName
+			definitions = append(definitions, agg)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return definitions, nil
+}
+
+// InputTypes returns the types of the arguments that are given to the transition function after the state, which
+// excludes the direct arguments of ordered-set aggregates.
+func (agg *AggregateDefinition) InputTypes() []string {
+	types := make([]string, len(agg.Parameters))
+	for i, param := range agg.Parameters {
+		types[i] = param.Type
+	}
+	return types
+}
+
+// parseSQLCreateAggregate parses the given statement tokens as a CREATE AGGREGATE statement. Returns nil if the
+// statement is not a CREATE AGGREGATE statement. Both the current syntax and the old syntax, which gives the input type
+// through BASETYPE, are supported.
+func parseSQLCreateAggregate(tokens []sqlToken) (*AggregateDefinition, error) {
+	action, kind, _, i := classifySQLStatement(tokens)
+	if action != "CREATE" || kind != "AGGREGATE" {
+		return nil, nil
+	}
+	nameParts := sqlNamePartsBefore(tokens, i)
+	if len(nameParts) == 0 {
+		return nil, fmt.Errorf("invalid CREATE AGGREGATE: missing aggregate name")
+	}
+	agg := &AggregateDefinition{
+		Name:     nameParts[len(nameParts)-1],
+		Parallel: "unsafe",
+	}
+	if len(nameParts) > 1 {
+		agg.Schema = nameParts[len(nameParts)-2]
+	}
+	firstEnd := skipSQLParenthesized(tokens, i)
+	if firstEnd == -1 || firstEnd == i {
+		return nil, fmt.Errorf("invalid CREATE AGGREGATE `%s`: malformed argument list", agg.Name)
+	}
+	optionsStart, optionsEnd := i, firstEnd
+	oldSyntax := true
+	if secondEnd := skipSQLParenthesized(tokens, firstEnd); secondEnd != firstEnd {
+		if secondEnd == -1 {
+			return nil, fmt.Errorf("invalid CREATE AGGREGATE `%s`: malformed options", agg.Name)
+		}
+		agg.parseArguments(tokens[i+1 : firstEnd-1])
+		optionsStart, optionsEnd = firstEnd, secondEnd
+		oldSyntax = false
+	}
+	for _, option := range splitSQLTopLevel(tokens[optionsStart+1:optionsEnd-1], ",") {
+		if len(option) == 0 {
+			continue
+		}
+		name := strings.ToLower(option[0].Value())
+		var value []sqlToken
+		if len(option) > 2 && option[1].IsOperator("=") {
+			value = option[2:]
+		}
+		if err := agg.setOption(name, value, oldSyntax); err != nil {
+			return nil, err
+		}
+	}
+	if len(agg.SFunc) == 0 || len(agg.SType) == 0 {
+		return nil, fmt.Errorf("invalid CREATE AGGREGATE `%s`: SFUNC and STYPE are required", agg.Name)
+	}
+	return agg, nil
+}
+
+// parseArguments parses the tokens between the parentheses of the argument list of an aggregate.
+func (agg *AggregateDefinition) parseArguments(tokens []sqlToken) {
+	if len(tokens) == 1 && tokens[0].IsOperator("*") {
+		return
+	}
+	depth := 0
+	for i, token := range tokens {
+		switch {
+		case token.IsPunctuation("(") || token.IsPunctuation("["):
+			depth++
+		case token.IsPunctuation(")") || token.IsPunctuation("]"):
+			depth--
+		case depth == 0 && token.IsKeyword("order") && i+1 < len(tokens) && tokens[i+1].IsKeyword("by"):
+			agg.OrderedSet = true
+			agg.DirectParameters = parseSQLFunctionParameters(tokens[:i])
+			agg.Parameters = parseSQLFunctionParameters(tokens[i+2:])
+			return
+		}
+	}
+	agg.Parameters = parseSQLFunctionParameters(tokens)
+}
+
+// setOption sets the option of the aggregate with the given name to the given value tokens, which are empty for options
+// that do not take a value.
+func (agg *AggregateDefinition) setOption(name string, value []sqlToken, oldSyntax bool) error {
+	if len(value) == 0 {
+		switch name {
+		case "finalfunc_extra":
+			agg.FinalFuncExtra = true
+		case "hypothetical":
+			agg.Hypothetical = true
+		}
+		return nil
+	}
+	switch name {
+	case "basetype":
+		if !oldSyntax {
+			return fmt.Errorf("invalid CREATE AGGREGATE `%s`: BASETYPE is only allowed in the old syntax", agg.Name)
+		}
+		// The old syntax uses a base type of ANY for aggregates that take `*`
+		if baseType := sqlAggregateOptionText(value); !strings.EqualFold(baseType, "any") {
+			agg.Parameters = []FunctionParameter{{Mode: "in", Type: baseType}}
+		}
+	case "sfunc":
+		agg.SFunc = sqlAggregateFunctionName(value)
+	case "stype":
+		agg.SType = sqlTokensText(value)
+	case "finalfunc":
+		agg.FinalFunc = sqlAggregateFunctionName(value)
+	case "combinefunc":
+		agg.CombineFunc = sqlAggregateFunctionName(value)
+	case "serialfunc":
+		agg.SerialFunc = sqlAggregateFunctionName(value)
+	case "deserialfunc":
+		agg.DeserialFunc = sqlAggregateFunctionName(value)
+	case "initcond":
+		agg.InitCond = sqlAggregateOptionText(value)
+		agg.HasInitCond = true
+	case "sortop":
+		agg.SortOp = sqlTokensText(value)
+	case "parallel":
+		agg.Parallel = strings.ToLower(sqlAggregateOptionText(value))
+	}
+	return nil
+}
+
+// sqlAggregateFunctionName returns the possibly schema-qualified function name of an aggregate option.
+func sqlAggregateFunctionName(value []sqlToken) string {
+	if name, next := parseSQLQualifiedName(value, 0); next == len(value) {
+		return name
+	}
+	return sqlAggregateOptionText(value)
+}
+
+// sqlAggregateOptionText returns the text of an aggregate option, which is unquoted when it is a single string.
+func sqlAggregateOptionText(value []sqlToken) string {
+	if len(value) == 1 {
+		return value[0].Value()
+	}
+	return sqlTokensText(value)
+}
@@ -0,0 +1,115 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"fmt"
+)
+
+// CastDefinition is a cast that is created by an extension's SQL files through CREATE CAST.
+type CastDefinition struct {
+	SourceType string
+	TargetType string
+	// Method is how the cast converts its value, which is "function" when it calls Function, "binary" when the types
+	// are binary-coercible (WITHOUT FUNCTION), or "inout" when it goes through the types' I/O functions (WITH INOUT).
+	Method string
+	// Function is the possibly schema-qualified function that performs the cast, which is only set for the "function"
+	// method. FunctionArgumentTypes are nil when the function's arguments were not given.
+	Function              string
+	FunctionArgumentTypes []string
+	// Context is where the cast may be applied, which is one of "explicit", "assignment", or "implicit".
+	Context string
+	// Script is the name of the SQL file that created the cast.
+	Script string
+}
+
+// LoadSQLCastDefinitions loads the definitions of all casts that are created by the extension's SQL files, in the order
+// that they're created.
+func (extFile *ExtensionFiles) LoadSQLCastDefinitions() ([]*CastDefinition, error) {
+	var definitions []*CastDefinition
+	err := extFile.forEachSQLStatement(func(sqlFileName string, tokens []sqlToken) error {
+		cast, err := parseSQLCreateCast(tokens)
+		if cast != nil {
+			cast.Script = sqlFileName
+			definitions = append(definitions, cast)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return definitions, nil
+}
+
+// parseSQLCreateCast parses the given statement tokens as a CREATE CAST statement. Returns nil if the statement is not a
+// CREATE CAST statement.
+func parseSQLCreateCast(tokens []sqlToken) (*CastDefinition, error) {
+	action, kind, name, i := classifySQLStatement(tokens)
+	if action != "CREATE" || kind != "CAST" {
+		return nil, nil
+	}
+	end := skipSQLParenthesized(tokens, i)
+	if end == -1 || end == i {
+		return nil, fmt.Errorf("invalid CREATE CAST: malformed source and target types")
+	}
+	cast := &CastDefinition{Context: "explicit"}
+	inner := tokens[i+1 : end-1]
+	for j, token := range inner {
+		if token.IsKeyword("as") {
+			cast.SourceType = sqlTokensText(inner[:j])
+			cast.TargetType = sqlTokensText(inner[j+1:])
+			break
+		}
+	}
+	if len(cast.SourceType) == 0 || len(cast.TargetType) == 0 {
+		return nil, fmt.Errorf("invalid CREATE CAST (%s): malformed source and target types", name)
+	}
+	i = end
+	switch {
+	case i+1 < len(tokens) && tokens[i].IsKeyword("without") && tokens[i+1].IsKeyword("function"):
+		cast.Method = "binary"
+		i += 2
+	case i+1 < len(tokens) && tokens[i].IsKeyword("with") && tokens[i+1].IsKeyword("inout"):
+		cast.Method = "inout"
+		i += 2
+	case i+1 < len(tokens) && tokens[i].IsKeyword("with") && tokens[i+1].IsKeyword("function"):
+		cast.Method = "function"
+		cast.Function, i = parseSQLQualifiedName(tokens, i+2)
+		if len(cast.Function) == 0 {
+			return nil, fmt.Errorf("invalid CREATE CAST (%s): missing function name", name)
+		}
+		if argsEnd := skipSQLParenthesized(tokens, i); argsEnd != i {
+			if argsEnd == -1 {
+				return nil, fmt.Errorf("invalid CREATE CAST (%s): malformed argument list", name)
+			}
+			cast.FunctionArgumentTypes = []string{}
+			for _, param := range parseSQLFunctionParameters(tokens[i+1 : argsEnd-1]) {
+				cast.FunctionArgumentTypes = append(cast.FunctionArgumentTypes, param.Type)
+			}
+			i = argsEnd
+		}
+	default:
+		return nil, fmt.Errorf("invalid CREATE CAST (%s): missing WITH FUNCTION, WITHOUT FUNCTION, or WITH INOUT", name)
+	}
+	if i+1 < len(tokens) && tokens[i].IsKeyword("as") {
+		switch {
+		case tokens[i+1].IsKeyword("assignment"):
+			cast.Context = "assignment"
+		case tokens[i+1].IsKeyword("implicit"):
+			cast.Context = "implicit"
+		}
+	}
+	return cast, nil
+}
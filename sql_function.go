@@ -0,0 +1,481 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// FunctionDefinition is a function that is created by an extension's SQL files through CREATE FUNCTION.
+type FunctionDefinition struct {
+	Schema     string
+	Name       string
+	Parameters []FunctionParameter
+	// ReturnType is the type that the function returns. For functions that return a set, this is the type of each row.
+	// This is empty for functions that return TABLE, as the columns are in ReturnsTable instead.
+	ReturnType string
+	// ReturnsSet is true for functions that return SETOF or TABLE.
+	ReturnsSet bool
+	// ReturnsTable contains the columns of a function that returns TABLE.
+	ReturnsTable []FunctionParameter
+	Language     string
+	// ObjFile is the library that contains a C function, which is the first string of the AS clause.
+	ObjFile string
+	// LinkSymbol is the symbol of a C function within the library, which is the second string of the AS clause. This is
+	// empty when the symbol is the same as the function name.
+	LinkSymbol string
+	// Library is the library that contains a C function, which is resolved from ObjFile. This is a file name within the
+	// extension's library filesystem, or an absolute path for libraries outside of it.
+	Library string
+	// Definition is the body of a function that is not written in C.
+	Definition      string
+	Strict          bool
+	Volatility      Volatility
+	Leakproof       bool
+	SecurityDefiner bool
+	Window          bool
+	// Parallel is the parallel safety of the function, which is one of "safe", "restricted", or "unsafe".
+	Parallel string
+	// Script is the name of the SQL file that created the function.
+	Script string
+}
+
+// FunctionParameter is a single parameter of a function, or a column of a function that returns TABLE.
+type FunctionParameter struct {
+	// Mode is one of "in", "out", "inout", or "variadic".
+	Mode string
+	// Name is empty for unnamed parameters.
+	Name string
+	Type string
+	// Default is the text of the default expression, which is empty when there is no default.
+	Default string
+}
+
+// sqlFunctionOptionKeywords are the keywords that begin an option of CREATE FUNCTION, and therefore end a return type.
+var sqlFunctionOptionKeywords = map[string]struct{}{
+	"as":        {},
+	"begin":     {},
+	"called":    {},
+	"cost":      {},
+	"external":  {},
+	"immutable": {},
+	"language":  {},
+	"leakproof": {},
+	"not":       {},
+	"parallel":  {},
+	"return":    {},
+	"returns":   {},
+	"rows":      {},
+	"security":  {},
+	"set":       {},
+	"stable":    {},
+	"strict":    {},
+	"support":   {},
+	"transform": {},
+	"volatile":  {},
+	"window":    {},
+}
+
+// sqlMultiWordTypeStarts are the pairs of words that begin a type name. A parameter that starts with one of these pairs
+// is unnamed, since the first word would otherwise be mistaken for the parameter's name.
+var sqlMultiWordTypeStarts = map[[2]string]struct{}{
+	{"bit", "varying"}:        {},
+	{"char", "varying"}:       {},
+	{"character", "varying"}:  {},
+	{"double", "precision"}:   {},
+	{"national", "char"}:      {},
+	{"national", "character"}: {},
+	{"nchar", "varying"}:      {},
+	{"time", "with"}:          {},
+	{"time", "without"}:       {},
+	{"timestamp", "with"}:     {},
+	{"timestamp", "without"}:  {},
+}
+
+// LoadSQLFunctionDefinitions loads the definitions of all functions that are created by the extension's SQL files, in
+// the order that they're created. The SQL files are parsed concurrently (see SetScanParallelism).
+func (extFile *ExtensionFiles) LoadSQLFunctionDefinitions() ([]*FunctionDefinition, error) {
+	defer flushMetadataCache()
+	return extFile.loadSQLFunctionDefinitions(scanWorkers())
+}
+
+// loadSQLFunctionDefinitions loads the definitions of all functions that are created by the extension's SQL files,
+// parsing the files with the given number of goroutines. This does not write the metadata cache.
+func (extFile *ExtensionFiles) loadSQLFunctionDefinitions(workers int) ([]*FunctionDefinition, error) {
+	control, err := extFile.loadControl()
+	if err != nil {
+		return nil, err
+	}
+	scripts, err := extFile.loadAllScriptFunctions(workers)
+	if err != nil {
+		return nil, err
+	}
+	var definitions []*FunctionDefinition
+	for _, fns := range scripts {
+		for _, fn := range fns {
+			if fn.Language == "c" {
+				fn.Library = extFile.resolveObjFile(fn.ObjFile, control)
+			}
+			definitions = append(definitions, fn)
+		}
+	}
+	return definitions, nil
+}
+
+// loadAllScriptFunctions returns the functions that are created by each of the extension's SQL files, in the same order
+// as the files. The files are parsed with the given number of goroutines, and the error of the earliest file that
+// fails is returned.
+func (extFile *ExtensionFiles) loadAllScriptFunctions(workers int) ([][]*FunctionDefinition, error) {
+	scripts := make([][]*FunctionDefinition, len(extFile.SQLFileNames))
+	errs := make([]error, len(extFile.SQLFileNames))
+	forEachParallel(len(extFile.SQLFileNames), workers, func(i int) {
+		scripts[i], errs[i] = extFile.loadScriptFunctions(extFile.SQLFileNames[i])
+	})
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return scripts, nil
+}
+
+// loadScriptFunctions returns the functions that are created by the SQL file, without resolving their libraries. The
+// functions are taken from the metadata cache when the file has not changed.
+func (extFile *ExtensionFiles) loadScriptFunctions(sqlFileName string) ([]*FunctionDefinition, error) {
+	cacheKey, stamp, cacheable := extFile.cacheStamp(sqlFileName)
+	if cacheable {
+		if definitions, ok := cachedScriptFunctions(cacheKey, stamp); ok {
+			return definitions, nil
+		}
+	}
+	data, err := fs.ReadFile(extFile.ControlFS, sqlFileName)
+	if err != nil {
+		return nil, err
+	}
+	var definitions []*FunctionDefinition
+	for _, stmt := range splitSQLStatements(string(data)) {
+		fn, err := parseSQLCreateFunction(stmt.Tokens)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", sqlFileName, err.Error())
+		}
+		if fn != nil {
+			fn.Script = sqlFileName
+			definitions = append(definitions, fn)
+		}
+	}
+	if cacheable {
+		cacheScriptFunctions(cacheKey, stamp, definitions)
+	}
+	return definitions, nil
+}
+
+// Symbol returns the symbol of a C function within its library.
+func (fn *FunctionDefinition) Symbol() string {
+	if len(fn.LinkSymbol) > 0 {
+		return fn.LinkSymbol
+	}
+	return fn.Name
+}
+
+// InputTypes returns the types of the arguments that are given when calling the function, which excludes OUT
+// parameters.
+func (fn *FunctionDefinition) InputTypes() []string {
+	var types []string
+	for _, param := range fn.Parameters {
+		if param.Mode != "out" {
+			types = append(types, param.Type)
+		}
+	}
+	return types
+}
+
+// parseSQLCreateFunction parses the given statement tokens as a CREATE FUNCTION statement. Returns nil if the statement
+// is not a CREATE FUNCTION statement.
+func parseSQLCreateFunction(tokens []sqlToken) (*FunctionDefinition, error) {
+	action, kind, _, i := classifySQLStatement(tokens)
+	if action != "CREATE" || kind != "FUNCTION" {
+		return nil, nil
+	}
+	nameParts := sqlNamePartsBefore(tokens, i)
+	if len(nameParts) == 0 {
+		return nil, fmt.Errorf("invalid CREATE FUNCTION: missing function name")
+	}
+	fn := &FunctionDefinition{
+		Name:       nameParts[len(nameParts)-1],
+		Volatility: VolatilityVolatile,
+		Parallel:   "unsafe",
+	}
+	if len(nameParts) > 1 {
+		fn.Schema = nameParts[len(nameParts)-2]
+	}
+	argsEnd := skipSQLParenthesized(tokens, i)
+	if argsEnd == -1 || argsEnd == i {
+		return nil, fmt.Errorf("invalid CREATE FUNCTION `%s`: malformed argument list", fn.Name)
+	}
+	fn.Parameters = parseSQLFunctionParameters(tokens[i+1 : argsEnd-1])
+	i = argsEnd
+	for i < len(tokens) {
+		token := tokens[i]
+		switch {
+		case token.IsKeyword("returns") && i+1 < len(tokens) && tokens[i+1].IsKeyword("null"):
+			// RETURNS NULL ON NULL INPUT
+			fn.Strict = true
+			i += 5
+		case token.IsKeyword("returns") && i+1 < len(tokens) && tokens[i+1].IsKeyword("table"):
+			tableEnd := skipSQLParenthesized(tokens, i+2)
+			if tableEnd == -1 || tableEnd == i+2 {
+				return nil, fmt.Errorf("invalid CREATE FUNCTION `%s`: malformed RETURNS TABLE", fn.Name)
+			}
+			fn.ReturnsSet = true
+			fn.ReturnsTable = parseSQLFunctionParameters(tokens[i+3 : tableEnd-1])
+			i = tableEnd
+		case token.IsKeyword("returns"):
+			i++
+			if i < len(tokens) && tokens[i].IsKeyword("setof") {
+				fn.ReturnsSet = true
+				i++
+			}
+			typeEnd := i
+			for typeEnd < len(tokens) && !isSQLFunctionOptionStart(tokens, typeEnd) {
+				typeEnd++
+			}
+			if typeEnd == i {
+				return nil, fmt.Errorf("invalid CREATE FUNCTION `%s`: missing return type", fn.Name)
+			}
+			fn.ReturnType = sqlTokensText(tokens[i:typeEnd])
+			i = typeEnd
+		case token.IsKeyword("called"):
+			// CALLED ON NULL INPUT
+			fn.Strict = false
+			i += 4
+		case token.IsKeyword("strict"):
+			fn.Strict = true
+			i++
+		case token.IsKeyword("immutable"):
+			fn.Volatility = VolatilityImmutable
+			i++
+		case token.IsKeyword("stable"):
+			fn.Volatility = VolatilityStable
+			i++
+		case token.IsKeyword("volatile"):
+			fn.Volatility = VolatilityVolatile
+			i++
+		case token.IsKeyword("leakproof"):
+			fn.Leakproof = true
+			i++
+		case token.IsKeyword("not") && i+1 < len(tokens) && tokens[i+1].IsKeyword("leakproof"):
+			fn.Leakproof = false
+			i += 2
+		case token.IsKeyword("window"):
+			fn.Window = true
+			i++
+		case token.IsKeyword("security") && i+1 < len(tokens):
+			fn.SecurityDefiner = tokens[i+1].IsKeyword("definer")
+			i += 2
+		case token.IsKeyword("parallel") && i+1 < len(tokens):
+			fn.Parallel = strings.ToLower(tokens[i+1].Value())
+			i += 2
+		case token.IsKeyword("language"):
+			if i+1 >= len(tokens) {
+				return nil, fmt.Errorf("invalid CREATE FUNCTION `%s`: missing language", fn.Name)
+			}
+			fn.Language = strings.ToLower(tokens[i+1].Value())
+			i += 2
+		case token.IsKeyword("as"):
+			if i+1 >= len(tokens) || !isSQLStringToken(tokens[i+1]) {
+				return nil, fmt.Errorf("invalid CREATE FUNCTION `%s`: AS must be followed by a string", fn.Name)
+			}
+			first := tokens[i+1].Value()
+			i += 2
+			if i+1 < len(tokens) && tokens[i].IsPunctuation(",") && isSQLStringToken(tokens[i+1]) {
+				fn.ObjFile = first
+				fn.LinkSymbol = tokens[i+1].Value()
+				i += 2
+			} else {
+				fn.ObjFile = first
+				fn.Definition = first
+			}
+		case token.IsKeyword("return") || (token.IsKeyword("begin") && i+1 < len(tokens) && tokens[i+1].IsKeyword("atomic")):
+			// SQL-standard function bodies are always last, and may contain anything, so we stop here
+			fn.Definition = sqlTokensText(tokens[i:])
+			i = len(tokens)
+		case token.IsKeyword("with") && i+1 < len(tokens) && tokens[i+1].IsPunctuation("("):
+			// The deprecated WITH (attributes) clause
+			withEnd := skipSQLParenthesized(tokens, i+1)
+			if withEnd == -1 {
+				return nil, fmt.Errorf("invalid CREATE FUNCTION `%s`: malformed WITH clause", fn.Name)
+			}
+			for _, attr := range tokens[i+2 : withEnd-1] {
+				if attr.IsKeyword("isstrict") {
+					fn.Strict = true
+				} else if attr.IsKeyword("iscachable") {
+					fn.Volatility = VolatilityImmutable
+				}
+			}
+			i = withEnd
+		case token.IsPunctuation("("):
+			if i = skipSQLParenthesized(tokens, i); i == -1 {
+				return nil, fmt.Errorf("invalid CREATE FUNCTION `%s`: unbalanced parentheses", fn.Name)
+			}
+		default:
+			i++
+		}
+	}
+	if fn.Language == "c" {
+		fn.Definition = ""
+	} else {
+		fn.ObjFile = ""
+	}
+	// Functions with OUT parameters may omit the return type, which is then derived from those parameters
+	if len(fn.ReturnType) == 0 && len(fn.ReturnsTable) == 0 {
+		var outTypes []string
+		for _, param := range fn.Parameters {
+			if param.Mode == "out" || param.Mode == "inout" {
+				outTypes = append(outTypes, param.Type)
+			}
+		}
+		if len(outTypes) == 1 {
+			fn.ReturnType = outTypes[0]
+		} else if len(outTypes) > 1 {
+			fn.ReturnType = "record"
+		}
+	}
+	return fn, nil
+}
+
+// parseSQLFunctionParameters parses the tokens between the parentheses of a function's parameter list.
+func parseSQLFunctionParameters(tokens []sqlToken) []FunctionParameter {
+	var params []FunctionParameter
+	for _, paramTokens := range splitSQLTopLevel(tokens, ",") {
+		if len(paramTokens) == 0 {
+			continue
+		}
+		param := FunctionParameter{Mode: "in"}
+		for i, token := range paramTokens {
+			if token.IsKeyword("default") || token.IsOperator("=") {
+				param.Default = sqlTokensText(paramTokens[i+1:])
+				paramTokens = paramTokens[:i]
+				break
+			}
+		}
+		if len(paramTokens) > 1 {
+			if first := paramTokens[0]; first.IsKeyword("in") || first.IsKeyword("out") || first.IsKeyword("inout") ||
+				first.IsKeyword("variadic") {
+				param.Mode = first.Value()
+				paramTokens = paramTokens[1:]
+			}
+		}
+		if len(paramTokens) > 1 && isSQLParameterName(paramTokens) {
+			param.Name = paramTokens[0].Value()
+			paramTokens = paramTokens[1:]
+		}
+		param.Type = sqlTokensText(paramTokens)
+		params = append(params, param)
+	}
+	return params
+}
+
+// isSQLParameterName returns whether the first of the given parameter tokens is the parameter's name, rather than the
+// start of its type.
+func isSQLParameterName(tokens []sqlToken) bool {
+	first, second := tokens[0], tokens[1]
+	if first.Kind == sqlTokenQuotedIdentifier {
+		return true
+	}
+	if first.Kind != sqlTokenIdentifier {
+		return false
+	}
+	// Type names may be followed by modifiers, array bounds, or a qualified name
+	if second.Kind != sqlTokenIdentifier && second.Kind != sqlTokenQuotedIdentifier {
+		return false
+	}
+	_, isTypeStart := sqlMultiWordTypeStarts[[2]string{first.Value(), second.Value()}]
+	return !isTypeStart
+}
+
+// isSQLFunctionOptionStart returns whether the token at the given index begins an option of CREATE FUNCTION.
+func isSQLFunctionOptionStart(tokens []sqlToken, i int) bool {
+	token := tokens[i]
+	if token.IsKeyword("with") {
+		return i+1 < len(tokens) && tokens[i+1].IsPunctuation("(")
+	}
+	if token.Kind != sqlTokenIdentifier {
+		return false
+	}
+	_, ok := sqlFunctionOptionKeywords[token.Value()]
+	return ok
+}
+
+// splitSQLTopLevel splits the tokens at the given punctuation, ignoring any that are nested within parentheses or
+// brackets.
+func splitSQLTopLevel(tokens []sqlToken, separator string) [][]sqlToken {
+	var groups [][]sqlToken
+	depth := 0
+	start := 0
+	for i, token := range tokens {
+		switch {
+		case token.IsPunctuation("(") || token.IsPunctuation("["):
+			depth++
+		case token.IsPunctuation(")") || token.IsPunctuation("]"):
+			depth--
+		case depth == 0 && token.IsPunctuation(separator):
+			groups = append(groups, tokens[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(tokens) {
+		groups = append(groups, tokens[start:])
+	}
+	return groups
+}
+
+// sqlTokensText returns the source text of the given tokens, with whitespace collapsed to single spaces.
+func sqlTokensText(tokens []sqlToken) string {
+	var sb strings.Builder
+	for i, token := range tokens {
+		if i > 0 && tokens[i-1].End < token.Start {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(token.Text)
+	}
+	return sb.String()
+}
+
+// skipSQLParenthesized returns the index of the token after the parenthesized group that starts at the given index.
+// Returns -1 if the group is not terminated, or the given index if it does not start a group.
+func skipSQLParenthesized(tokens []sqlToken, i int) int {
+	if i >= len(tokens) || !tokens[i].IsPunctuation("(") {
+		return i
+	}
+	depth := 0
+	for ; i < len(tokens); i++ {
+		if tokens[i].IsPunctuation("(") {
+			depth++
+		} else if tokens[i].IsPunctuation(")") {
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// isSQLStringToken returns whether the token is a string of either form.
+func isSQLStringToken(token sqlToken) bool {
+	return token.Kind == sqlTokenString || token.Kind == sqlTokenDollarString
+}
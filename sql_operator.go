@@ -0,0 +1,440 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// OperatorDefinition is an operator that is created by an extension's SQL files through CREATE OPERATOR.
+type OperatorDefinition struct {
+	Schema string
+	// Name is the symbol of the operator, such as `->` or `%`.
+	Name string
+	// LeftType is empty for prefix operators, which only take a right argument.
+	LeftType  string
+	RightType string
+	// Function is the function that implements the operator, which is given through either FUNCTION or PROCEDURE.
+	Function string
+	// Commutator and Negator are the names of the related operators, with any OPERATOR() wrapping removed.
+	Commutator string
+	Negator    string
+	// Restrict and Join are the selectivity estimators of the operator.
+	Restrict string
+	Join     string
+	Hashes   bool
+	Merges   bool
+	// Script is the name of the SQL file that created the operator.
+	Script string
+}
+
+// OperatorClassDefinition is an operator class that is created by an extension's SQL files through CREATE OPERATOR
+// CLASS, which describes how an index method may use the operators of a type.
+type OperatorClassDefinition struct {
+	Schema string
+	Name   string
+	// Default is whether the class is the default for its type and index method.
+	Default bool
+	Type    string
+	// Method is the index method of the class, such as btree or gist.
+	Method string
+	// Family is the operator family that the class belongs to. When it's empty, the class belongs to a family of the
+	// same name, which is created along with the class if it does not exist.
+	Family    string
+	Operators []OperatorClassOperator
+	Functions []OperatorClassFunction
+	// Storage is the type that is stored within the index, which is empty when it's the same as Type.
+	Storage string
+	// Script is the name of the SQL file that created the operator class.
+	Script string
+}
+
+// OperatorFamilyDefinition is an operator family that is created by an extension's SQL files through CREATE OPERATOR
+// FAMILY. Members that are added through ALTER OPERATOR FAMILY are included in the family.
+type OperatorFamilyDefinition struct {
+	Schema    string
+	Name      string
+	Method    string
+	Operators []OperatorClassOperator
+	Functions []OperatorClassFunction
+	// Script is the name of the SQL file that created the operator family.
+	Script string
+}
+
+// OperatorClassOperator is an operator that is a member of an operator class or family.
+type OperatorClassOperator struct {
+	// Strategy is the strategy number that the index method assigns to the operator.
+	Strategy int
+	Name     string
+	// LeftType and RightType are only set when they're given, as they otherwise default to the class's type.
+	LeftType  string
+	RightType string
+	// OrderByFamily is set for ordering operators (FOR ORDER BY), and is the btree family that sorts their results.
+	OrderByFamily string
+}
+
+// OperatorClassFunction is a support function that is a member of an operator class or family.
+type OperatorClassFunction struct {
+	// Support is the support number that the index method assigns to the function.
+	Support int
+	// LeftType and RightType are only set when they're given, as they otherwise default to the class's type.
+	LeftType      string
+	RightType     string
+	Name          string
+	ArgumentTypes []string
+}
+
+// LoadSQLOperatorDefinitions loads the definitions of all operators that are created by the extension's SQL files, in
+// the order that they're created.
+func (extFile *ExtensionFiles) LoadSQLOperatorDefinitions() ([]*OperatorDefinition, error) {
+	var definitions []*OperatorDefinition
+	err := extFile.forEachSQLStatement(func(sqlFileName string, tokens []sqlToken) error {
+		op, err := parseSQLCreateOperator(tokens)
+		if op != nil {
+			op.Script = sqlFileName
+			definitions = append(definitions, op)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return definitions, nil
+}
+
+// LoadSQLOperatorClassDefinitions loads the definitions of all operator classes and operator families that are created
+// by the extension's SQL files, in the order that they're created.
+func (extFile *ExtensionFiles) LoadSQLOperatorClassDefinitions() ([]*OperatorClassDefinition,
+	[]*OperatorFamilyDefinition, error) {
+	var classes []*OperatorClassDefinition
+	var families []*OperatorFamilyDefinition
+	err := extFile.forEachSQLStatement(func(sqlFileName string, tokens []sqlToken) error {
+		action, kind, _, _ := classifySQLStatement(tokens)
+		switch {
+		case action == "CREATE" && kind == "OPERATOR CLASS":
+			class, err := parseSQLCreateOperatorClass(tokens)
+			if err != nil {
+				return err
+			}
+			class.Script = sqlFileName
+			classes = append(classes, class)
+		case action == "CREATE" && kind == "OPERATOR FAMILY":
+			family, _, err := parseSQLOperatorFamilyHeader(tokens)
+			if err != nil {
+				return err
+			}
+			family.Script = sqlFileName
+			families = append(families, family)
+		case action == "ALTER" && kind == "OPERATOR FAMILY":
+			return addSQLOperatorFamilyMembers(tokens, families)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return classes, families, nil
+}
+
+// forEachSQLStatement calls the given function with the tokens of each statement of the extension's SQL files, in
+// order. Errors that are returned by the function are prefixed with the name of the file.
+func (extFile *ExtensionFiles) forEachSQLStatement(fn func(sqlFileName string, tokens []sqlToken) error) error {
+	for _, sqlFileName := range extFile.SQLFileNames {
+		data, err := fs.ReadFile(extFile.ControlFS, sqlFileName)
+		if err != nil {
+			return err
+		}
+		for _, stmt := range splitSQLStatements(string(data)) {
+			if err = fn(sqlFileName, stmt.Tokens); err != nil {
+				return fmt.Errorf("%s: %s", sqlFileName, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// parseSQLCreateOperator parses the given statement tokens as a CREATE OPERATOR statement. Returns nil if the statement
+// is not a CREATE OPERATOR statement.
+func parseSQLCreateOperator(tokens []sqlToken) (*OperatorDefinition, error) {
+	action, kind, name, i := classifySQLStatement(tokens)
+	if action != "CREATE" || kind != "OPERATOR" {
+		return nil, nil
+	}
+	if len(name) == 0 {
+		return nil, fmt.Errorf("invalid CREATE OPERATOR: missing operator name")
+	}
+	op := &OperatorDefinition{Name: name}
+	if dot := strings.LastIndexByte(name, '.'); dot != -1 {
+		op.Schema, op.Name = name[:dot], name[dot+1:]
+	}
+	end := skipSQLParenthesized(tokens, i)
+	if end == -1 || end == i {
+		return nil, fmt.Errorf("invalid CREATE OPERATOR `%s`: malformed options", op.Name)
+	}
+	for _, option := range splitSQLTopLevel(tokens[i+1:end-1], ",") {
+		if len(option) == 0 {
+			continue
+		}
+		value := sqlOperatorOptionValue(option)
+		switch strings.ToLower(option[0].Value()) {
+		case "function", "procedure":
+			op.Function = sqlAggregateFunctionName(value)
+		case "leftarg":
+			op.LeftType = sqlTokensText(value)
+		case "rightarg":
+			op.RightType = sqlTokensText(value)
+		case "commutator":
+			op.Commutator = sqlOperatorReference(value)
+		case "negator":
+			op.Negator = sqlOperatorReference(value)
+		case "restrict":
+			op.Restrict = sqlAggregateFunctionName(value)
+		case "join":
+			op.Join = sqlAggregateFunctionName(value)
+		case "hashes":
+			op.Hashes = true
+		case "merges":
+			op.Merges = true
+		}
+	}
+	if len(op.Function) == 0 {
+		return nil, fmt.Errorf("invalid CREATE OPERATOR `%s`: FUNCTION is required", op.Name)
+	}
+	if len(op.RightType) == 0 {
+		return nil, fmt.Errorf("invalid CREATE OPERATOR `%s`: RIGHTARG is required", op.Name)
+	}
+	return op, nil
+}
+
+// sqlOperatorOptionValue returns the value tokens of an option of CREATE OPERATOR. Operators may immediately follow the
+// equals sign, in which case both were scanned as a single operator token that is split apart here.
+func sqlOperatorOptionValue(option []sqlToken) []sqlToken {
+	if len(option) < 2 || option[1].Kind != sqlTokenOperator || !strings.HasPrefix(option[1].Text, "=") {
+		return nil
+	}
+	if option[1].Text == "=" {
+		return option[2:]
+	}
+	operator := option[1]
+	operator.Text = operator.Text[1:]
+	operator.Start++
+	return append([]sqlToken{operator}, option[2:]...)
+}
+
+// sqlOperatorReference returns the name of the operator that is referenced by an option, which may be quoted as a
+// string, or wrapped within OPERATOR() to give its schema.
+func sqlOperatorReference(value []sqlToken) string {
+	if len(value) == 1 && isSQLStringToken(value[0]) {
+		return value[0].Value()
+	}
+	if len(value) > 2 && value[0].IsKeyword("operator") && value[1].IsPunctuation("(") &&
+		value[len(value)-1].IsPunctuation(")") {
+		value = value[2 : len(value)-1]
+	}
+	var sb strings.Builder
+	for _, token := range value {
+		sb.WriteString(token.Text)
+	}
+	return sb.String()
+}
+
+// parseSQLCreateOperatorClass parses the given statement tokens, which must be a CREATE OPERATOR CLASS statement.
+func parseSQLCreateOperatorClass(tokens []sqlToken) (*OperatorClassDefinition, error) {
+	_, _, _, i := classifySQLStatement(tokens)
+	nameParts := sqlNamePartsBefore(tokens, i)
+	if len(nameParts) == 0 {
+		return nil, fmt.Errorf("invalid CREATE OPERATOR CLASS: missing class name")
+	}
+	class := &OperatorClassDefinition{Name: nameParts[len(nameParts)-1]}
+	if len(nameParts) > 1 {
+		class.Schema = nameParts[len(nameParts)-2]
+	}
+	if i < len(tokens) && tokens[i].IsKeyword("default") {
+		class.Default = true
+		i++
+	}
+	if i+1 >= len(tokens) || !tokens[i].IsKeyword("for") || !tokens[i+1].IsKeyword("type") {
+		return nil, fmt.Errorf("invalid CREATE OPERATOR CLASS `%s`: missing FOR TYPE", class.Name)
+	}
+	typeStart := i + 2
+	i = typeStart
+	for i < len(tokens) && !tokens[i].IsKeyword("using") {
+		i++
+	}
+	if i == typeStart || i+1 >= len(tokens) {
+		return nil, fmt.Errorf("invalid CREATE OPERATOR CLASS `%s`: missing USING", class.Name)
+	}
+	class.Type = sqlTokensText(tokens[typeStart:i])
+	class.Method = tokens[i+1].Value()
+	i += 2
+	if i < len(tokens) && tokens[i].IsKeyword("family") {
+		class.Family, i = parseSQLQualifiedName(tokens, i+1)
+	}
+	if i >= len(tokens) || !tokens[i].IsKeyword("as") {
+		return nil, fmt.Errorf("invalid CREATE OPERATOR CLASS `%s`: missing AS", class.Name)
+	}
+	for _, item := range splitSQLTopLevel(tokens[i+1:], ",") {
+		switch {
+		case len(item) == 0:
+		case item[0].IsKeyword("storage"):
+			class.Storage = sqlTokensText(item[1:])
+		default:
+			op, fn, err := parseSQLOperatorClassMember(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CREATE OPERATOR CLASS `%s`: %s", class.Name, err.Error())
+			}
+			if op != nil {
+				class.Operators = append(class.Operators, *op)
+			} else {
+				class.Functions = append(class.Functions, *fn)
+			}
+		}
+	}
+	return class, nil
+}
+
+// parseSQLOperatorFamilyHeader parses the name and index method of a CREATE or ALTER OPERATOR FAMILY statement,
+// returning the index of the token after the index method.
+func parseSQLOperatorFamilyHeader(tokens []sqlToken) (*OperatorFamilyDefinition, int, error) {
+	_, _, _, i := classifySQLStatement(tokens)
+	nameParts := sqlNamePartsBefore(tokens, i)
+	if len(nameParts) == 0 {
+		return nil, 0, fmt.Errorf("invalid OPERATOR FAMILY: missing family name")
+	}
+	family := &OperatorFamilyDefinition{Name: nameParts[len(nameParts)-1]}
+	if len(nameParts) > 1 {
+		family.Schema = nameParts[len(nameParts)-2]
+	}
+	if i+1 >= len(tokens) || !tokens[i].IsKeyword("using") {
+		return nil, 0, fmt.Errorf("invalid OPERATOR FAMILY `%s`: missing USING", family.Name)
+	}
+	family.Method = tokens[i+1].Value()
+	return family, i + 2, nil
+}
+
+// addSQLOperatorFamilyMembers adds the members of an ALTER OPERATOR FAMILY ... ADD statement to the matching family.
+// Statements that drop members, or that alter families which were not created by the extension, are ignored.
+func addSQLOperatorFamilyMembers(tokens []sqlToken, families []*OperatorFamilyDefinition) error {
+	header, i, err := parseSQLOperatorFamilyHeader(tokens)
+	if err != nil {
+		return err
+	}
+	if i >= len(tokens) || !tokens[i].IsKeyword("add") {
+		return nil
+	}
+	var family *OperatorFamilyDefinition
+	for _, existing := range families {
+		if existing.Name == header.Name && existing.Method == header.Method &&
+			(len(header.Schema) == 0 || existing.Schema == header.Schema) {
+			family = existing
+		}
+	}
+	if family == nil {
+		return nil
+	}
+	for _, item := range splitSQLTopLevel(tokens[i+1:], ",") {
+		if len(item) == 0 {
+			continue
+		}
+		op, fn, err := parseSQLOperatorClassMember(item)
+		if err != nil {
+			return fmt.Errorf("invalid ALTER OPERATOR FAMILY `%s`: %s", family.Name, err.Error())
+		}
+		if op != nil {
+			family.Operators = append(family.Operators, *op)
+		} else {
+			family.Functions = append(family.Functions, *fn)
+		}
+	}
+	return nil
+}
+
+// parseSQLOperatorClassMember parses an OPERATOR or FUNCTION item of an operator class or family, returning whichever
+// one the item is.
+func parseSQLOperatorClassMember(item []sqlToken) (*OperatorClassOperator, *OperatorClassFunction, error) {
+	if len(item) < 3 || item[1].Kind != sqlTokenNumber {
+		return nil, nil, fmt.Errorf("malformed item `%s`", sqlTokensText(item))
+	}
+	number, err := strconv.Atoi(item[1].Text)
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed item `%s`", sqlTokensText(item))
+	}
+	switch {
+	case item[0].IsKeyword("operator"):
+		op := &OperatorClassOperator{Strategy: number}
+		name, i := parseSQLOperatorName(item, 2)
+		if len(name) == 0 {
+			return nil, nil, fmt.Errorf("malformed item `%s`", sqlTokensText(item))
+		}
+		op.Name = name
+		if end := skipSQLParenthesized(item, i); end != i {
+			if end == -1 {
+				return nil, nil, fmt.Errorf("malformed item `%s`", sqlTokensText(item))
+			}
+			op.LeftType, op.RightType = sqlOperatorClassTypes(item[i+1 : end-1])
+			i = end
+		}
+		if i+3 < len(item) && item[i].IsKeyword("for") && item[i+1].IsKeyword("order") && item[i+2].IsKeyword("by") {
+			op.OrderByFamily, _ = parseSQLQualifiedName(item, i+3)
+		}
+		return op, nil, nil
+	case item[0].IsKeyword("function"):
+		fn := &OperatorClassFunction{Support: number}
+		i := 2
+		if end := skipSQLParenthesized(item, i); end != i {
+			if end == -1 {
+				return nil, nil, fmt.Errorf("malformed item `%s`", sqlTokensText(item))
+			}
+			fn.LeftType, fn.RightType = sqlOperatorClassTypes(item[i+1 : end-1])
+			i = end
+		}
+		fn.Name, i = parseSQLQualifiedName(item, i)
+		if len(fn.Name) == 0 {
+			return nil, nil, fmt.Errorf("malformed item `%s`", sqlTokensText(item))
+		}
+		if end := skipSQLParenthesized(item, i); end != i && end != -1 {
+			fn.ArgumentTypes = []string{}
+			for _, param := range parseSQLFunctionParameters(item[i+1 : end-1]) {
+				fn.ArgumentTypes = append(fn.ArgumentTypes, param.Type)
+			}
+		}
+		return nil, fn, nil
+	default:
+		return nil, nil, fmt.Errorf("malformed item `%s`", sqlTokensText(item))
+	}
+}
+
+// sqlOperatorClassTypes returns the types within the parentheses that may follow an operator or function of an
+// operator class. A single type is used for both sides, and NONE is returned as an empty string.
+func sqlOperatorClassTypes(tokens []sqlToken) (left string, right string) {
+	types := splitSQLTopLevel(tokens, ",")
+	typeName := func(typeTokens []sqlToken) string {
+		if len(typeTokens) == 1 && typeTokens[0].IsKeyword("none") {
+			return ""
+		}
+		return sqlTokensText(typeTokens)
+	}
+	switch len(types) {
+	case 0:
+		return "", ""
+	case 1:
+		return typeName(types[0]), typeName(types[0])
+	default:
+		return typeName(types[0]), typeName(types[1])
+	}
+}
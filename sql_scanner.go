@@ -0,0 +1,387 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"strings"
+)
+
+// sqlTokenKind is the kind of token that was scanned from a SQL script.
+type sqlTokenKind uint8
+
+const (
+	sqlTokenIdentifier sqlTokenKind = iota
+	sqlTokenQuotedIdentifier
+	sqlTokenString
+	sqlTokenDollarString
+	sqlTokenNumber
+	sqlTokenParameter
+	sqlTokenOperator
+	sqlTokenPunctuation
+	sqlTokenMetaCommand
+)
+
+// sqlToken is a single token from a SQL script. Comments and whitespace are not represented as tokens.
+type sqlToken struct {
+	Kind sqlTokenKind
+	// Text is the token exactly as it appears in the source, including any quotes.
+	Text string
+	// Start is the byte offset of the token within the source.
+	Start int
+	// End is the byte offset immediately after the token within the source.
+	End int
+}
+
+// sqlStatement is a single statement from a SQL script.
+type sqlStatement struct {
+	// Text is the statement as it appears in the source, excluding the terminating semicolon.
+	Text   string
+	Tokens []sqlToken
+}
+
+// sqlOperatorChars are the characters that may make up an operator.
+const sqlOperatorChars = "+-*/<>=~!@#%^&|`?"
+
+// Value returns the value of the token. Identifiers are folded to lowercase, quoted identifiers have their quotes
+// removed, and strings have their quotes and escapes removed. All other tokens return their text.
+func (t sqlToken) Value() string {
+	switch t.Kind {
+	case sqlTokenIdentifier:
+		return strings.ToLower(t.Text)
+	case sqlTokenQuotedIdentifier:
+		text := t.Text
+		if text[0] != '"' {
+			// Unicode escapes are not processed, so we only remove the U& prefix
+			text = text[2:]
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], `""`, `"`)
+	case sqlTokenString:
+		return unquoteSQLString(t.Text)
+	case sqlTokenDollarString:
+		tagEnd := strings.IndexByte(t.Text[1:], '$') + 2
+		return t.Text[tagEnd : len(t.Text)-tagEnd]
+	default:
+		return t.Text
+	}
+}
+
+// IsKeyword returns whether the token is the given keyword, which must be given in lowercase.
+func (t sqlToken) IsKeyword(keyword string) bool {
+	return t.Kind == sqlTokenIdentifier && strings.EqualFold(t.Text, keyword)
+}
+
+// IsPunctuation returns whether the token is the given punctuation.
+func (t sqlToken) IsPunctuation(punctuation string) bool {
+	return t.Kind == sqlTokenPunctuation && t.Text == punctuation
+}
+
+// IsOperator returns whether the token is the given operator.
+func (t sqlToken) IsOperator(operator string) bool {
+	return t.Kind == sqlTokenOperator && t.Text == operator
+}
+
+// tokenizeSQL splits the given SQL into tokens.
+func tokenizeSQL(sql string) []sqlToken {
+	var tokens []sqlToken
+	atLineStart := true
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		start := i
+		switch {
+		case c == '\n':
+			atLineStart = true
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			i++
+			continue
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			i = scanUntil(sql, i, "\n")
+			continue
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			i = scanBlockComment(sql, i)
+			continue
+		case c == '\\' && atLineStart:
+			// psql meta-commands consume the remainder of the line
+			i = scanUntil(sql, i, "\n")
+			tokens = append(tokens, sqlToken{Kind: sqlTokenMetaCommand, Text: strings.TrimSpace(sql[start:i]), Start: start, End: i})
+			continue
+		case c == '\'':
+			i = scanQuoted(sql, i, '\'', false)
+			tokens = append(tokens, sqlToken{Kind: sqlTokenString, Text: sql[start:i], Start: start, End: i})
+		case (c == 'e' || c == 'E') && i+1 < len(sql) && sql[i+1] == '\'':
+			i = scanQuoted(sql, i+1, '\'', true)
+			tokens = append(tokens, sqlToken{Kind: sqlTokenString, Text: sql[start:i], Start: start, End: i})
+		case (c == 'b' || c == 'B' || c == 'x' || c == 'X' || c == 'n' || c == 'N') && i+1 < len(sql) && sql[i+1] == '\'':
+			i = scanQuoted(sql, i+1, '\'', false)
+			tokens = append(tokens, sqlToken{Kind: sqlTokenString, Text: sql[start:i], Start: start, End: i})
+		case (c == 'u' || c == 'U') && strings.HasPrefix(sql[i+1:], "&'"):
+			i = scanQuoted(sql, i+2, '\'', false)
+			tokens = append(tokens, sqlToken{Kind: sqlTokenString, Text: sql[start:i], Start: start, End: i})
+		case (c == 'u' || c == 'U') && strings.HasPrefix(sql[i+1:], `&"`):
+			i = scanQuoted(sql, i+2, '"', false)
+			tokens = append(tokens, sqlToken{Kind: sqlTokenQuotedIdentifier, Text: sql[start:i], Start: start, End: i})
+		case c == '"':
+			i = scanQuoted(sql, i, '"', false)
+			tokens = append(tokens, sqlToken{Kind: sqlTokenQuotedIdentifier, Text: sql[start:i], Start: start, End: i})
+		case c == '$':
+			if i+1 < len(sql) && isSQLDigit(sql[i+1]) {
+				i++
+				for i < len(sql) && isSQLDigit(sql[i]) {
+					i++
+				}
+				tokens = append(tokens, sqlToken{Kind: sqlTokenParameter, Text: sql[start:i], Start: start, End: i})
+			} else if tag, ok := scanDollarTag(sql, i); ok {
+				i = scanUntil(sql, i+len(tag), tag)
+				if i < len(sql) {
+					i += len(tag)
+				}
+				tokens = append(tokens, sqlToken{Kind: sqlTokenDollarString, Text: sql[start:i], Start: start, End: i})
+			} else {
+				i++
+				tokens = append(tokens, sqlToken{Kind: sqlTokenPunctuation, Text: sql[start:i], Start: start, End: i})
+			}
+		case isSQLIdentifierStart(c):
+			for i < len(sql) && isSQLIdentifierPart(sql[i]) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{Kind: sqlTokenIdentifier, Text: sql[start:i], Start: start, End: i})
+		case isSQLDigit(c) || (c == '.' && i+1 < len(sql) && isSQLDigit(sql[i+1])):
+			i = scanNumber(sql, i)
+			tokens = append(tokens, sqlToken{Kind: sqlTokenNumber, Text: sql[start:i], Start: start, End: i})
+		case c == ':' && i+1 < len(sql) && sql[i+1] == ':':
+			i += 2
+			tokens = append(tokens, sqlToken{Kind: sqlTokenPunctuation, Text: sql[start:i], Start: start, End: i})
+		case strings.IndexByte("(),;[]:.", c) != -1:
+			i++
+			tokens = append(tokens, sqlToken{Kind: sqlTokenPunctuation, Text: sql[start:i], Start: start, End: i})
+		case strings.IndexByte(sqlOperatorChars, c) != -1:
+			for i < len(sql) && strings.IndexByte(sqlOperatorChars, sql[i]) != -1 {
+				// Comments may immediately follow an operator, in which case they're not a part of it
+				if i > start && (strings.HasPrefix(sql[i:], "--") || strings.HasPrefix(sql[i:], "/*")) {
+					break
+				}
+				i++
+			}
+			tokens = append(tokens, sqlToken{Kind: sqlTokenOperator, Text: sql[start:i], Start: start, End: i})
+		default:
+			i++
+			tokens = append(tokens, sqlToken{Kind: sqlTokenPunctuation, Text: sql[start:i], Start: start, End: i})
+		}
+		atLineStart = false
+	}
+	return tokens
+}
+
+// splitSQLStatements splits the given SQL script into its statements. Empty statements are omitted, and each psql
+// meta-command is returned as its own statement.
+func splitSQLStatements(sql string) []sqlStatement {
+	var statements []sqlStatement
+	tokens := tokenizeSQL(sql)
+	stmtStart := 0
+	// atomicDepth tracks the nesting of BEGIN ATOMIC ... END function bodies, which contain semicolons
+	atomicDepth := 0
+	flush := func(end int) {
+		if end > stmtStart {
+			stmtTokens := tokens[stmtStart:end]
+			statements = append(statements, sqlStatement{
+				Text:   sql[stmtTokens[0].Start:stmtTokens[len(stmtTokens)-1].End],
+				Tokens: stmtTokens,
+			})
+		}
+	}
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch {
+		case token.Kind == sqlTokenMetaCommand:
+			if i == stmtStart {
+				flush(i + 1)
+				stmtStart = i + 1
+			} else {
+				// The meta-command appears in the middle of a statement, so we emit it without interrupting the statement
+				statements = append(statements, sqlStatement{Text: token.Text, Tokens: tokens[i : i+1]})
+				tokens = append(tokens[:i:i], tokens[i+1:]...)
+				i--
+			}
+		case token.IsKeyword("atomic") && i > stmtStart && tokens[i-1].IsKeyword("begin"):
+			atomicDepth++
+		case atomicDepth > 0 && (token.IsKeyword("case") || (token.IsKeyword("begin") && i+1 < len(tokens) && !tokens[i+1].IsKeyword("atomic"))):
+			atomicDepth++
+		case atomicDepth > 0 && token.IsKeyword("end"):
+			atomicDepth--
+		case atomicDepth == 0 && token.IsPunctuation(";"):
+			flush(i)
+			stmtStart = i + 1
+		}
+	}
+	flush(len(tokens))
+	return statements
+}
+
+// unquoteSQLString returns the contents of a string token, with escapes processed.
+func unquoteSQLString(text string) string {
+	escapes := false
+	switch {
+	case text[0] == 'e' || text[0] == 'E':
+		escapes = true
+		text = text[1:]
+	case text[0] == 'u' || text[0] == 'U':
+		text = text[2:]
+	case text[0] != '\'':
+		text = text[1:]
+	}
+	if len(text) < 2 {
+		return ""
+	}
+	text = text[1 : len(text)-1]
+	if !escapes {
+		return strings.ReplaceAll(text, "''", "'")
+	}
+	var sb strings.Builder
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c == '\'' && i+1 < len(text) && text[i+1] == '\'' {
+			sb.WriteByte('\'')
+			i++
+			continue
+		}
+		if c != '\\' || i+1 >= len(text) {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		switch text[i] {
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		default:
+			sb.WriteByte(text[i])
+		}
+	}
+	return sb.String()
+}
+
+// scanUntil returns the offset of the next occurrence of the given terminator, or the length of the source if it's not
+// found.
+func scanUntil(sql string, i int, terminator string) int {
+	idx := strings.Index(sql[i:], terminator)
+	if idx == -1 {
+		return len(sql)
+	}
+	return i + idx
+}
+
+// scanBlockComment returns the offset immediately after the block comment that starts at the given offset. Block
+// comments may be nested.
+func scanBlockComment(sql string, i int) int {
+	depth := 0
+	for i < len(sql) {
+		switch {
+		case strings.HasPrefix(sql[i:], "/*"):
+			depth++
+			i += 2
+		case strings.HasPrefix(sql[i:], "*/"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return i
+}
+
+// scanQuoted returns the offset immediately after the quoted section that starts at the given offset. A doubled quote
+// character is treated as an escaped quote, and backslashes escape the next character if backslashEscapes is true.
+func scanQuoted(sql string, i int, quote byte, backslashEscapes bool) int {
+	for i++; i < len(sql); i++ {
+		switch {
+		case backslashEscapes && sql[i] == '\\':
+			i++
+		case sql[i] == quote:
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i++
+			} else {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// scanDollarTag returns the dollar-quote tag (including both dollar signs) that starts at the given offset.
+func scanDollarTag(sql string, i int) (string, bool) {
+	end := i + 1
+	for end < len(sql) && sql[end] != '$' {
+		if !isSQLIdentifierPart(sql[end]) {
+			return "", false
+		}
+		end++
+	}
+	if end >= len(sql) || (end > i+1 && isSQLDigit(sql[i+1])) {
+		return "", false
+	}
+	return sql[i : end+1], true
+}
+
+// scanNumber returns the offset immediately after the number that starts at the given offset.
+func scanNumber(sql string, i int) int {
+	for i < len(sql) && (isSQLDigit(sql[i]) || sql[i] == '_') {
+		i++
+	}
+	if i < len(sql) && sql[i] == '.' && !strings.HasPrefix(sql[i:], "..") {
+		i++
+		for i < len(sql) && isSQLDigit(sql[i]) {
+			i++
+		}
+	}
+	if i < len(sql) && (sql[i] == 'e' || sql[i] == 'E') {
+		j := i + 1
+		if j < len(sql) && (sql[j] == '+' || sql[j] == '-') {
+			j++
+		}
+		if j < len(sql) && isSQLDigit(sql[j]) {
+			i = j
+			for i < len(sql) && isSQLDigit(sql[i]) {
+				i++
+			}
+		}
+	}
+	return i
+}
+
+// isSQLDigit returns whether the character is a digit.
+func isSQLDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isSQLIdentifierStart returns whether the character may start an identifier.
+func isSQLIdentifierStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c >= 0x80
+}
+
+// isSQLIdentifierPart returns whether the character may appear in an identifier after the first character.
+func isSQLIdentifierPart(c byte) bool {
+	return isSQLIdentifierStart(c) || isSQLDigit(c) || c == '$'
+}
@@ -0,0 +1,297 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"slices"
+	"testing"
+)
+
+// testToken is the kind and text of a scanned token, ignoring its offsets.
+type testToken struct {
+	Kind sqlTokenKind
+	Text string
+}
+
+func TestTokenizeSQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		expected []testToken
+	}{
+		{
+			name: "simple statement",
+			sql:  "SELECT a, 1.5 FROM t;",
+			expected: []testToken{
+				{sqlTokenIdentifier, "SELECT"},
+				{sqlTokenIdentifier, "a"},
+				{sqlTokenPunctuation, ","},
+				{sqlTokenNumber, "1.5"},
+				{sqlTokenIdentifier, "FROM"},
+				{sqlTokenIdentifier, "t"},
+				{sqlTokenPunctuation, ";"},
+			},
+		},
+		{
+			name: "dollar quote",
+			sql:  "AS $$ SELECT 1; $$;",
+			expected: []testToken{
+				{sqlTokenIdentifier, "AS"},
+				{sqlTokenDollarString, "$$ SELECT 1; $$"},
+				{sqlTokenPunctuation, ";"},
+			},
+		},
+		{
+			name: "tagged dollar quote containing another",
+			sql:  "DO $do$ BEGIN EXECUTE $$ SELECT ';' $$; END $do$",
+			expected: []testToken{
+				{sqlTokenIdentifier, "DO"},
+				{sqlTokenDollarString, "$do$ BEGIN EXECUTE $$ SELECT ';' $$; END $do$"},
+			},
+		},
+		{
+			name: "unterminated dollar quote",
+			sql:  "SELECT $$ never ends;",
+			expected: []testToken{
+				{sqlTokenIdentifier, "SELECT"},
+				{sqlTokenDollarString, "$$ never ends;"},
+			},
+		},
+		{
+			name: "positional parameter",
+			sql:  "$1 + $23",
+			expected: []testToken{
+				{sqlTokenParameter, "$1"},
+				{sqlTokenOperator, "+"},
+				{sqlTokenParameter, "$23"},
+			},
+		},
+		{
+			name: "doubled quote",
+			sql:  "'it''s';",
+			expected: []testToken{
+				{sqlTokenString, "'it''s'"},
+				{sqlTokenPunctuation, ";"},
+			},
+		},
+		{
+			name: "E string with escaped quote",
+			sql:  `E'it\'s;' e'\\';`,
+			expected: []testToken{
+				{sqlTokenString, `E'it\'s;'`},
+				{sqlTokenString, `e'\\'`},
+				{sqlTokenPunctuation, ";"},
+			},
+		},
+		{
+			name: "backslash ends a standard string",
+			sql:  `'a\' b`,
+			expected: []testToken{
+				{sqlTokenString, `'a\'`},
+				{sqlTokenIdentifier, "b"},
+			},
+		},
+		{
+			name: "quoted identifiers",
+			sql:  `"My ""Table""" U&"d\0061t\+000061"`,
+			expected: []testToken{
+				{sqlTokenQuotedIdentifier, `"My ""Table"""`},
+				{sqlTokenQuotedIdentifier, `U&"d\0061t\+000061"`},
+			},
+		},
+		{
+			name: "nested block comments",
+			sql:  "a /* outer /* inner; */ still a comment; */ b",
+			expected: []testToken{
+				{sqlTokenIdentifier, "a"},
+				{sqlTokenIdentifier, "b"},
+			},
+		},
+		{
+			name: "line comments",
+			sql:  "a -- comment; b\nc",
+			expected: []testToken{
+				{sqlTokenIdentifier, "a"},
+				{sqlTokenIdentifier, "c"},
+			},
+		},
+		{
+			name: "comment after an operator",
+			sql:  "1 +-- comment\n2 */* comment */3",
+			expected: []testToken{
+				{sqlTokenNumber, "1"},
+				{sqlTokenOperator, "+"},
+				{sqlTokenNumber, "2"},
+				{sqlTokenOperator, "*"},
+				{sqlTokenNumber, "3"},
+			},
+		},
+		{
+			name: "cast",
+			sql:  "'1'::int4",
+			expected: []testToken{
+				{sqlTokenString, "'1'"},
+				{sqlTokenPunctuation, "::"},
+				{sqlTokenIdentifier, "int4"},
+			},
+		},
+		{
+			name: "psql guard",
+			sql:  "\\echo Use \"CREATE EXTENSION ext\" to load this file. \\quit\nCREATE",
+			expected: []testToken{
+				{sqlTokenMetaCommand, `\echo Use "CREATE EXTENSION ext" to load this file. \quit`},
+				{sqlTokenIdentifier, "CREATE"},
+			},
+		},
+		{
+			name: "backslash within a line",
+			sql:  "a \\b",
+			expected: []testToken{
+				{sqlTokenIdentifier, "a"},
+				{sqlTokenPunctuation, "\\"},
+				{sqlTokenIdentifier, "b"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var actual []testToken
+			for _, token := range tokenizeSQL(test.sql) {
+				if test.sql[token.Start:token.End] != token.Text && token.Kind != sqlTokenMetaCommand {
+					t.Errorf("token %q does not match its offsets %d:%d", token.Text, token.Start, token.End)
+				}
+				actual = append(actual, testToken{Kind: token.Kind, Text: token.Text})
+			}
+			if !slices.Equal(actual, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSQLTokenValue(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		{sql: "MixedCase", expected: "mixedcase"},
+		{sql: `"MixedCase"`, expected: "MixedCase"},
+		{sql: `"a ""b"""`, expected: `a "b"`},
+		{sql: "'it''s'", expected: "it's"},
+		{sql: `E'a\tb\'c'`, expected: "a\tb'c"},
+		{sql: "$$body$$", expected: "body"},
+		{sql: "$fn$ $$inner$$ $fn$", expected: " $$inner$$ "},
+	}
+	for _, test := range tests {
+		t.Run(test.sql, func(t *testing.T) {
+			tokens := tokenizeSQL(test.sql)
+			if len(tokens) != 1 {
+				t.Fatalf("expected 1 token, got %d", len(tokens))
+			}
+			if actual := tokens[0].Value(); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSplitSQLStatements(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		expected []string
+	}{
+		{
+			name:     "simple statements",
+			sql:      "CREATE TABLE t (a int);\nSELECT 1;",
+			expected: []string{"CREATE TABLE t (a int)", "SELECT 1"},
+		},
+		{
+			name:     "missing final semicolon",
+			sql:      "SELECT 1; SELECT 2",
+			expected: []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			name:     "empty statements",
+			sql:      ";; SELECT 1;;\n;",
+			expected: []string{"SELECT 1"},
+		},
+		{
+			name: "dollar quoted body",
+			sql:  "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql; SELECT 2;",
+			expected: []string{
+				"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql",
+				"SELECT 2",
+			},
+		},
+		{
+			name: "nested dollar quotes",
+			sql:  "DO $outer$ BEGIN EXECUTE $inner$ SELECT 1; $inner$; END $outer$; SELECT 2;",
+			expected: []string{
+				"DO $outer$ BEGIN EXECUTE $inner$ SELECT 1; $inner$; END $outer$",
+				"SELECT 2",
+			},
+		},
+		{
+			name: "BEGIN ATOMIC body",
+			sql: "CREATE FUNCTION f() RETURNS int LANGUAGE sql BEGIN ATOMIC SELECT 1; SELECT 2; END;\n" +
+				"SELECT 3;",
+			expected: []string{
+				"CREATE FUNCTION f() RETURNS int LANGUAGE sql BEGIN ATOMIC SELECT 1; SELECT 2; END",
+				"SELECT 3",
+			},
+		},
+		{
+			name: "BEGIN ATOMIC body containing CASE",
+			sql: "CREATE PROCEDURE p() BEGIN ATOMIC SELECT CASE WHEN true THEN 1 ELSE 2 END; SELECT 3; END;\n" +
+				"SELECT 4;",
+			expected: []string{
+				"CREATE PROCEDURE p() BEGIN ATOMIC SELECT CASE WHEN true THEN 1 ELSE 2 END; SELECT 3; END",
+				"SELECT 4",
+			},
+		},
+		{
+			name:     "E string containing a semicolon",
+			sql:      `SELECT E'a\';b'; SELECT 'c;';`,
+			expected: []string{`SELECT E'a\';b'`, `SELECT 'c;'`},
+		},
+		{
+			name:     "comments containing semicolons",
+			sql:      "SELECT 1 /* ; /* ; */ ; */; -- ;\nSELECT 2;",
+			expected: []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			name: "psql guard",
+			sql: "-- complain if script is sourced in psql\n" +
+				"\\echo Use \"CREATE EXTENSION ext\" to load this file. \\quit\n" +
+				"CREATE FUNCTION f() RETURNS int AS 'MODULE_PATHNAME' LANGUAGE c;\n",
+			expected: []string{
+				`\echo Use "CREATE EXTENSION ext" to load this file. \quit`,
+				"CREATE FUNCTION f() RETURNS int AS 'MODULE_PATHNAME' LANGUAGE c",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var actual []string
+			for _, statement := range splitSQLStatements(test.sql) {
+				actual = append(actual, statement.Text)
+			}
+			if !slices.Equal(actual, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,350 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TypeKind is the kind of type that is created by CREATE TYPE.
+type TypeKind uint8
+
+const (
+	// TypeKindShell is a placeholder for a base type, which allows the type's I/O functions to be created before the
+	// type is defined.
+	TypeKindShell TypeKind = iota
+	TypeKindBase
+	TypeKindComposite
+	TypeKindEnum
+	TypeKindRange
+)
+
+// TypeDefinition is a type that is created by an extension's SQL files through CREATE TYPE. Only the fields that apply
+// to the type's kind are set.
+type TypeDefinition struct {
+	Schema string
+	Name   string
+	Kind   TypeKind
+	// Input and Output are the functions that convert a base type from and to its text form, which are required.
+	Input  string
+	Output string
+	// Receive and Send are the functions that convert a base type from and to its binary form, which are optional.
+	Receive   string
+	Send      string
+	TypmodIn  string
+	TypmodOut string
+	Analyze   string
+	Subscript string
+	// InternalLength is the length of a base type in bytes, or -1 when its length is variable.
+	InternalLength int
+	PassedByValue  bool
+	// Alignment is the alignment of a base type, which is one of "char", "int2", "int4", or "double".
+	Alignment string
+	// Storage is the TOAST strategy of a base type, which is one of "plain", "external", "extended", or "main".
+	Storage string
+	// Like is the type whose length, alignment, storage, and whether it's passed by value are copied, which overrides
+	// those fields when it is set.
+	Like     string
+	Category string
+	// Preferred is whether the type is the preferred type of its category for implicit casts.
+	Preferred bool
+	// Default is the text of the default value, which is only set when HasDefault is true.
+	Default    string
+	HasDefault bool
+	// Element is the element type of a base type that is an array of fixed-length elements, such as point.
+	Element    string
+	Delimiter  string
+	Collatable bool
+	// Attributes are the columns of a composite type.
+	Attributes []TypeAttribute
+	// Labels are the values of an enum type, in their sort order.
+	Labels []string
+	// Subtype is the type of the bounds of a range type. The other range options are empty unless they are given.
+	Subtype            string
+	SubtypeOpClass     string
+	Collation          string
+	Canonical          string
+	SubtypeDiff        string
+	MultirangeTypeName string
+	// Script is the name of the SQL file that created the type.
+	Script string
+}
+
+// TypeAttribute is a single column of a composite type.
+type TypeAttribute struct {
+	Name      string
+	Type      string
+	Collation string
+}
+
+// LoadSQLTypeDefinitions loads the definitions of all types that are created by the extension's SQL files, in the order
+// that they're created. A base type is usually preceded by a shell type of the same name, which is returned as its own
+// definition.
+func (extFile *ExtensionFiles) LoadSQLTypeDefinitions() ([]*TypeDefinition, error) {
+	var definitions []*TypeDefinition
+	err := extFile.forEachSQLStatement(func(sqlFileName string, tokens []sqlToken) error {
+		typ, err := parseSQLCreateType(tokens)
+		if typ != nil {
+			typ.Script = sqlFileName
+			definitions = append(definitions, typ)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return definitions, nil
+}
+
+// parseSQLCreateType parses the given statement tokens as a CREATE TYPE statement. Returns nil if the statement is not
+// a CREATE TYPE statement.
+func parseSQLCreateType(tokens []sqlToken) (*TypeDefinition, error) {
+	action, kind, _, i := classifySQLStatement(tokens)
+	if action != "CREATE" || kind != "TYPE" {
+		return nil, nil
+	}
+	nameParts := sqlNamePartsBefore(tokens, i)
+	if len(nameParts) == 0 {
+		return nil, fmt.Errorf("invalid CREATE TYPE: missing type name")
+	}
+	typ := &TypeDefinition{Name: nameParts[len(nameParts)-1]}
+	if len(nameParts) > 1 {
+		typ.Schema = nameParts[len(nameParts)-2]
+	}
+	var err error
+	switch {
+	case i == len(tokens):
+		typ.Kind = TypeKindShell
+	case tokens[i].IsPunctuation("("):
+		typ.Kind = TypeKindBase
+		err = typ.parseBaseOptions(tokens, i)
+	case tokens[i].IsKeyword("as") && i+1 < len(tokens) && tokens[i+1].IsPunctuation("("):
+		typ.Kind = TypeKindComposite
+		err = typ.parseAttributes(tokens, i+1)
+	case tokens[i].IsKeyword("as") && i+1 < len(tokens) && tokens[i+1].IsKeyword("enum"):
+		typ.Kind = TypeKindEnum
+		err = typ.parseLabels(tokens, i+2)
+	case tokens[i].IsKeyword("as") && i+1 < len(tokens) && tokens[i+1].IsKeyword("range"):
+		typ.Kind = TypeKindRange
+		err = typ.parseRangeOptions(tokens, i+2)
+	default:
+		return nil, fmt.Errorf("invalid CREATE TYPE `%s`: unrecognized definition", typ.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return typ, nil
+}
+
+// parseBaseOptions parses the parenthesized options of a base type, which start at the given index.
+func (typ *TypeDefinition) parseBaseOptions(tokens []sqlToken, i int) error {
+	options, err := sqlTypeOptions(tokens, i, typ.Name)
+	if err != nil {
+		return err
+	}
+	typ.InternalLength = -1
+	typ.Alignment = "int4"
+	typ.Storage = "plain"
+	typ.Category = "U"
+	typ.Delimiter = ","
+	for _, option := range options {
+		if err = typ.setBaseOption(option.name, option.value); err != nil {
+			return err
+		}
+	}
+	if len(typ.Input) == 0 || len(typ.Output) == 0 {
+		return fmt.Errorf("invalid CREATE TYPE `%s`: INPUT and OUTPUT are required", typ.Name)
+	}
+	return nil
+}
+
+// setBaseOption sets the option of the base type with the given name to the given value tokens, which are empty for
+// options that do not take a value.
+func (typ *TypeDefinition) setBaseOption(name string, value []sqlToken) error {
+	switch name {
+	case "input":
+		typ.Input = sqlAggregateFunctionName(value)
+	case "output":
+		typ.Output = sqlAggregateFunctionName(value)
+	case "receive":
+		typ.Receive = sqlAggregateFunctionName(value)
+	case "send":
+		typ.Send = sqlAggregateFunctionName(value)
+	case "typmod_in":
+		typ.TypmodIn = sqlAggregateFunctionName(value)
+	case "typmod_out":
+		typ.TypmodOut = sqlAggregateFunctionName(value)
+	case "analyze":
+		typ.Analyze = sqlAggregateFunctionName(value)
+	case "subscript":
+		typ.Subscript = sqlAggregateFunctionName(value)
+	case "internallength":
+		text := sqlAggregateOptionText(value)
+		if strings.EqualFold(text, "variable") {
+			typ.InternalLength = -1
+			break
+		}
+		length, err := strconv.Atoi(text)
+		if err != nil || length < -1 {
+			return fmt.Errorf("invalid CREATE TYPE `%s`: invalid INTERNALLENGTH `%s`", typ.Name, text)
+		}
+		typ.InternalLength = length
+	case "passedbyvalue":
+		typ.PassedByValue = sqlTypeBoolOption(value)
+	case "alignment":
+		alignment := strings.TrimPrefix(strings.ToLower(sqlAggregateOptionText(value)), "pg_catalog.")
+		switch alignment {
+		case "char", "int2", "int4", "double":
+			typ.Alignment = alignment
+		default:
+			return fmt.Errorf("invalid CREATE TYPE `%s`: alignment `%s` not recognized", typ.Name, alignment)
+		}
+	case "storage":
+		storage := strings.ToLower(sqlAggregateOptionText(value))
+		switch storage {
+		case "plain", "external", "extended", "main":
+			typ.Storage = storage
+		default:
+			return fmt.Errorf("invalid CREATE TYPE `%s`: storage `%s` not recognized", typ.Name, storage)
+		}
+	case "like":
+		typ.Like = sqlTokensText(value)
+	case "category":
+		typ.Category = sqlAggregateOptionText(value)
+	case "preferred":
+		typ.Preferred = sqlTypeBoolOption(value)
+	case "default":
+		typ.Default = sqlAggregateOptionText(value)
+		typ.HasDefault = true
+	case "element":
+		typ.Element = sqlTokensText(value)
+	case "delimiter":
+		typ.Delimiter = sqlAggregateOptionText(value)
+	case "collatable":
+		typ.Collatable = sqlTypeBoolOption(value)
+	}
+	return nil
+}
+
+// parseAttributes parses the parenthesized attributes of a composite type, which start at the given index.
+func (typ *TypeDefinition) parseAttributes(tokens []sqlToken, i int) error {
+	end := skipSQLParenthesized(tokens, i)
+	if end == -1 || end != len(tokens) {
+		return fmt.Errorf("invalid CREATE TYPE `%s`: malformed attribute list", typ.Name)
+	}
+	for _, attrTokens := range splitSQLTopLevel(tokens[i+1:end-1], ",") {
+		if len(attrTokens) < 2 {
+			return fmt.Errorf("invalid CREATE TYPE `%s`: malformed attribute", typ.Name)
+		}
+		attr := TypeAttribute{Name: attrTokens[0].Value()}
+		attrTokens = attrTokens[1:]
+		for j, token := range attrTokens {
+			if token.IsKeyword("collate") {
+				attr.Collation, _ = parseSQLQualifiedName(attrTokens, j+1)
+				attrTokens = attrTokens[:j]
+				break
+			}
+		}
+		attr.Type = sqlTokensText(attrTokens)
+		typ.Attributes = append(typ.Attributes, attr)
+	}
+	return nil
+}
+
+// parseLabels parses the parenthesized labels of an enum type, which start at the given index.
+func (typ *TypeDefinition) parseLabels(tokens []sqlToken, i int) error {
+	end := skipSQLParenthesized(tokens, i)
+	if end == -1 || end == i || end != len(tokens) {
+		return fmt.Errorf("invalid CREATE TYPE `%s`: malformed enum labels", typ.Name)
+	}
+	typ.Labels = []string{}
+	for _, labelTokens := range splitSQLTopLevel(tokens[i+1:end-1], ",") {
+		if len(labelTokens) != 1 || !isSQLStringToken(labelTokens[0]) {
+			return fmt.Errorf("invalid CREATE TYPE `%s`: enum labels must be strings", typ.Name)
+		}
+		typ.Labels = append(typ.Labels, labelTokens[0].Value())
+	}
+	return nil
+}
+
+// parseRangeOptions parses the parenthesized options of a range type, which start at the given index.
+func (typ *TypeDefinition) parseRangeOptions(tokens []sqlToken, i int) error {
+	options, err := sqlTypeOptions(tokens, i, typ.Name)
+	if err != nil {
+		return err
+	}
+	for _, option := range options {
+		switch option.name {
+		case "subtype":
+			typ.Subtype = sqlTokensText(option.value)
+		case "subtype_opclass":
+			typ.SubtypeOpClass = sqlTokensText(option.value)
+		case "collation":
+			typ.Collation = sqlTokensText(option.value)
+		case "canonical":
+			typ.Canonical = sqlAggregateFunctionName(option.value)
+		case "subtype_diff":
+			typ.SubtypeDiff = sqlAggregateFunctionName(option.value)
+		case "multirange_type_name":
+			typ.MultirangeTypeName = sqlAggregateFunctionName(option.value)
+		}
+	}
+	if len(typ.Subtype) == 0 {
+		return fmt.Errorf("invalid CREATE TYPE `%s`: SUBTYPE is required", typ.Name)
+	}
+	return nil
+}
+
+// sqlTypeOption is a single option of CREATE TYPE, whose name is in lowercase.
+type sqlTypeOption struct {
+	name  string
+	value []sqlToken
+}
+
+// sqlTypeOptions returns the options within the parentheses that start at the given index, which must end the
+// statement.
+func sqlTypeOptions(tokens []sqlToken, i int, typeName string) ([]sqlTypeOption, error) {
+	end := skipSQLParenthesized(tokens, i)
+	if end == -1 || end == i || end != len(tokens) {
+		return nil, fmt.Errorf("invalid CREATE TYPE `%s`: malformed options", typeName)
+	}
+	var options []sqlTypeOption
+	for _, option := range splitSQLTopLevel(tokens[i+1:end-1], ",") {
+		if len(option) == 0 {
+			continue
+		}
+		var value []sqlToken
+		if len(option) > 2 && option[1].IsOperator("=") {
+			value = option[2:]
+		}
+		options = append(options, sqlTypeOption{name: strings.ToLower(option[0].Value()), value: value})
+	}
+	return options, nil
+}
+
+// sqlTypeBoolOption returns the value of a boolean option of CREATE TYPE, which is true when no value is given.
+func sqlTypeBoolOption(value []sqlToken) bool {
+	if len(value) == 0 {
+		return true
+	}
+	switch strings.ToLower(sqlAggregateOptionText(value)) {
+	case "false", "off", "no", "0":
+		return false
+	default:
+		return true
+	}
+}
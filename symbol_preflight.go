@@ -0,0 +1,582 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// librarySymbols contains the symbols of a library that are relevant to the preflight scan.
+type librarySymbols struct {
+	// imports are the symbols that the library expects Postgres to provide.
+	imports []string
+	// needed are the libraries that the library depends on, which may provide some of the imports.
+	needed []string
+	// providerPath is the file that must provide the imports, which is set for formats where imports name their
+	// library. Otherwise, the imports are checked against the shim.
+	providerPath string
+}
+
+var (
+	// symbolExports caches the exports of each file that provides symbols, keyed by the file's path.
+	symbolExports      = make(map[string]map[string]struct{})
+	symbolExportsMutex = &sync.Mutex{}
+	// librarySearchPaths are the directories that are searched for the dependencies of a library on Linux.
+	librarySearchPaths = []string{
+		"/lib64",
+		"/usr/lib64",
+		"/lib",
+		"/usr/lib",
+		"/usr/local/lib",
+		"/lib/*-linux-gnu",
+		"/usr/lib/*-linux-gnu",
+	}
+)
+
+// MissingSymbols returns the Postgres symbols that the library at the given path references, but which the shim does not
+// export. Such libraries would fail to load, or would crash once the missing symbol is called. Symbols that are
+// provided by the library's own dependencies, or by libraries that have already been loaded, are not reported.
+func MissingSymbols(path string) ([]string, error) {
+	loadedLibrariesMutex.Lock()
+	loadedPaths := globalLibraryPaths()
+	loadedLibrariesMutex.Unlock()
+	return missingSymbols(path, loadedPaths)
+}
+
+// globalLibraryPaths returns the paths of the loaded libraries whose symbols are available to other libraries. The
+// loadedLibrariesMutex must be held by the caller.
+func globalLibraryPaths() []string {
+	loadedPaths := make([]string, 0, len(loadedLibraries))
+	for loadedPath, lib := range loadedLibraries {
+		if !lib.local {
+			loadedPaths = append(loadedPaths, loadedPath)
+		}
+	}
+	return loadedPaths
+}
+
+// missingSymbols is the same as MissingSymbols, except that the loaded libraries are given by the caller.
+func missingSymbols(path string, loadedPaths []string) ([]string, error) {
+	symbols, err := readLibrarySymbols(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(symbols.imports) == 0 {
+		return nil, nil
+	}
+	// The shim must be readable, while all other providers are only used to avoid reporting symbols that they provide
+	requiredPath := symbols.providerPath
+	optionalPaths := slices.Clone(loadedPaths)
+	if len(requiredPath) == 0 {
+		if requiredPath, err = shimLibraryPath(); err != nil {
+			return nil, err
+		}
+		for _, needed := range symbols.needed {
+			if neededPath := findNeededLibrary(needed, filepath.Dir(path)); len(neededPath) > 0 {
+				optionalPaths = append(optionalPaths, neededPath)
+			}
+		}
+	}
+	required, err := cachedExports(requiredPath)
+	if err != nil {
+		return nil, err
+	}
+	providers := []map[string]struct{}{required}
+	for _, optionalPath := range optionalPaths {
+		if exports, err := cachedExports(optionalPath); err == nil {
+			providers = append(providers, exports)
+		}
+	}
+	var missing []string
+	for _, symbol := range symbols.imports {
+		provided := false
+		for _, exports := range providers {
+			if _, ok := exports[symbol]; ok {
+				provided = true
+				break
+			}
+		}
+		if !provided {
+			missing = append(missing, symbol)
+		}
+	}
+	slices.Sort(missing)
+	return slices.Compact(missing), nil
+}
+
+// cachedExports returns the exports of the file at the given path, reading them if they have not yet been cached.
+func cachedExports(path string) (map[string]struct{}, error) {
+	symbolExportsMutex.Lock()
+	defer symbolExportsMutex.Unlock()
+	if exports, ok := symbolExports[path]; ok {
+		return exports, nil
+	}
+	exports, err := readExports(path)
+	if err != nil {
+		return nil, err
+	}
+	symbolExports[path] = exports
+	return exports, nil
+}
+
+// findNeededLibrary returns the path of a library that is named as a dependency of another library, or an empty string
+// if it cannot be found.
+func findNeededLibrary(needed string, libraryDir string) string {
+	if filepath.IsAbs(needed) {
+		return needed
+	}
+	dirs := []string{libraryDir}
+	dirs = append(dirs, filepath.SplitList(os.Getenv("LD_LIBRARY_PATH"))...)
+	for _, searchPath := range librarySearchPaths {
+		matches, _ := filepath.Glob(searchPath)
+		dirs = append(dirs, matches...)
+	}
+	for _, dir := range dirs {
+		candidate := filepath.Join(dir, needed)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// libraryFormat returns the object file format of the file at the given path, which is "elf", "macho", or "pe".
+func libraryFormat(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	if _, err = f.Read(magic); err != nil {
+		return "", err
+	}
+	switch {
+	case bytes.Equal(magic, []byte(elf.ELFMAG)):
+		return "elf", nil
+	case bytes.HasPrefix(magic, []byte("MZ")):
+		return "pe", nil
+	}
+	switch binary.BigEndian.Uint32(magic) {
+	case macho.Magic32, macho.Magic64, macho.MagicFat, 0xcefaedfe, 0xcffaedfe:
+		return "macho", nil
+	}
+	return "", fmt.Errorf("`%s` is not an ELF, Mach-O, or PE file", path)
+}
+
+// libraryArchitecture returns the architecture that the library at the given path was built for, using the same names
+// as GOARCH. Returns an empty string for architectures that are not recognized. Universal Mach-O binaries return the
+// current architecture when they contain it.
+func libraryArchitecture(path string) (string, error) {
+	format, err := libraryFormat(path)
+	if err != nil {
+		return "", err
+	}
+	switch format {
+	case "elf":
+		f, err := elf.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		switch f.Machine {
+		case elf.EM_X86_64:
+			return "amd64", nil
+		case elf.EM_AARCH64:
+			return "arm64", nil
+		case elf.EM_386:
+			return "386", nil
+		case elf.EM_ARM:
+			return "arm", nil
+		}
+		return "", nil
+	case "macho":
+		if fat, err := macho.OpenFat(path); err == nil {
+			defer fat.Close()
+			for _, arch := range fat.Arches {
+				if machOArchitecture(arch.Cpu) == runtime.GOARCH {
+					return runtime.GOARCH, nil
+				}
+			}
+			if len(fat.Arches) > 0 {
+				return machOArchitecture(fat.Arches[0].Cpu), nil
+			}
+			return "", nil
+		}
+		f, err := macho.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		return machOArchitecture(f.Cpu), nil
+	default:
+		f, err := pe.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		switch f.Machine {
+		case pe.IMAGE_FILE_MACHINE_AMD64:
+			return "amd64", nil
+		case pe.IMAGE_FILE_MACHINE_ARM64:
+			return "arm64", nil
+		case pe.IMAGE_FILE_MACHINE_I386:
+			return "386", nil
+		}
+		return "", nil
+	}
+}
+
+// machOArchitecture returns the GOARCH name of a Mach-O CPU type, or an empty string if it is not recognized.
+func machOArchitecture(cpu macho.Cpu) string {
+	switch cpu {
+	case macho.CpuAmd64:
+		return "amd64"
+	case macho.CpuArm64:
+		return "arm64"
+	case macho.Cpu386:
+		return "386"
+	case macho.CpuArm:
+		return "arm"
+	}
+	return ""
+}
+
+// readLibrarySymbols returns the symbols of the library at the given path.
+func readLibrarySymbols(path string) (*librarySymbols, error) {
+	format, err := libraryFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "elf":
+		return readELFSymbols(path)
+	case "macho":
+		return readMachOSymbols(path)
+	default:
+		return readPESymbols(path)
+	}
+}
+
+// readExports returns the symbols that are exported by the file at the given path.
+func readExports(path string) (map[string]struct{}, error) {
+	format, err := libraryFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "elf":
+		return readELFExports(path)
+	case "macho":
+		return readMachOExports(path)
+	default:
+		return readPEExports(path)
+	}
+}
+
+// readELFSymbols returns the symbols of an ELF library. Imports that carry a symbol version belong to a versioned
+// dependency such as libc, so only unversioned imports are expected from Postgres.
+func readELFSymbols(path string) (*librarySymbols, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dynamicSymbols, err := f.DynamicSymbols()
+	if err != nil {
+		return nil, err
+	}
+	needed, err := f.ImportedLibraries()
+	if err != nil {
+		return nil, err
+	}
+	symbols := &librarySymbols{needed: needed}
+	for _, symbol := range dynamicSymbols {
+		if symbol.Section != elf.SHN_UNDEF || len(symbol.Name) == 0 || len(symbol.Library) > 0 {
+			continue
+		}
+		// Weak imports are allowed to remain unresolved
+		if elf.ST_BIND(symbol.Info) == elf.STB_WEAK {
+			continue
+		}
+		symbols.imports = append(symbols.imports, symbol.Name)
+	}
+	return symbols, nil
+}
+
+// readELFExports returns the symbols that are exported by an ELF file.
+func readELFExports(path string) (map[string]struct{}, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dynamicSymbols, err := f.DynamicSymbols()
+	if err != nil {
+		return nil, err
+	}
+	exports := make(map[string]struct{})
+	for _, symbol := range dynamicSymbols {
+		if symbol.Section == elf.SHN_UNDEF || len(symbol.Name) == 0 {
+			continue
+		}
+		if bind := elf.ST_BIND(symbol.Info); bind == elf.STB_GLOBAL || bind == elf.STB_WEAK {
+			exports[symbol.Name] = struct{}{}
+		}
+	}
+	return exports, nil
+}
+
+const (
+	// machOTypeMask is the mask of a Mach-O symbol's type.
+	machOTypeMask = 0x0e
+	// machOExternal is set on Mach-O symbols that are visible outside of their file.
+	machOExternal = 0x01
+	// machOUndefined is the type of Mach-O symbols that are defined in another file.
+	machOUndefined = 0x00
+	// machOSection is the type of Mach-O symbols that are defined in a section of the file.
+	machOSection = 0x0e
+	// machODynamicLookupOrdinal is the library ordinal of symbols that are resolved through the flat namespace, which
+	// is used by libraries that are linked with "-undefined dynamic_lookup".
+	machODynamicLookupOrdinal = 0xfe
+	// machOExecutableOrdinal is the library ordinal of symbols that are resolved against the loading executable, which
+	// is used by libraries that are linked with "-bundle_loader".
+	machOExecutableOrdinal = 0xff
+)
+
+// openMachO opens the Mach-O file at the given path. For universal binaries, the file of the current architecture is
+// returned.
+func openMachO(path string) (*macho.File, func() error, error) {
+	if fat, err := macho.OpenFat(path); err == nil {
+		for _, arch := range fat.Arches {
+			if machOArchitecture(arch.Cpu) == runtime.GOARCH {
+				return arch.File, fat.Close, nil
+			}
+		}
+		_ = fat.Close()
+		return nil, nil, fmt.Errorf("`%s` does not contain a library for %s", path, runtime.GOARCH)
+	}
+	f, err := macho.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+// readMachOSymbols returns the symbols of a Mach-O library. Imports are bound to a specific library through their
+// ordinal, so only those that are bound to the executable or resolved dynamically are expected from Postgres.
+func readMachOSymbols(path string) (*librarySymbols, error) {
+	f, closeFile, err := openMachO(path)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile()
+	symbols := &librarySymbols{}
+	// Libraries that use a flat namespace do not record where their imports come from, so they cannot be checked
+	if f.Symtab == nil || f.Flags&macho.FlagTwoLevel == 0 {
+		return symbols, nil
+	}
+	for _, symbol := range f.Symtab.Syms {
+		if symbol.Type&machOExternal == 0 || symbol.Type&machOTypeMask != machOUndefined {
+			continue
+		}
+		if ordinal := symbol.Desc >> 8; ordinal == machODynamicLookupOrdinal || ordinal == machOExecutableOrdinal {
+			symbols.imports = append(symbols.imports, strings.TrimPrefix(symbol.Name, "_"))
+		}
+	}
+	return symbols, nil
+}
+
+// readMachOExports returns the symbols that are exported by a Mach-O file.
+func readMachOExports(path string) (map[string]struct{}, error) {
+	f, closeFile, err := openMachO(path)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile()
+	exports := make(map[string]struct{})
+	if f.Symtab == nil {
+		return exports, nil
+	}
+	for _, symbol := range f.Symtab.Syms {
+		if symbol.Type&machOExternal != 0 && symbol.Type&machOTypeMask == machOSection {
+			exports[strings.TrimPrefix(symbol.Name, "_")] = struct{}{}
+		}
+	}
+	return exports, nil
+}
+
+// readPESymbols returns the symbols of a PE library. Imports name the DLL that they come from, so only those from
+// postgres.exe are expected from Postgres, which are provided by the forwarder that sits beside the shim.
+func readPESymbols(path string) (*librarySymbols, error) {
+	f, err := pe.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	imported, err := f.ImportedSymbols()
+	if err != nil {
+		return nil, err
+	}
+	shimPath, err := shimLibraryPath()
+	if err != nil {
+		return nil, err
+	}
+	symbols := &librarySymbols{providerPath: filepath.Join(filepath.Dir(shimPath), "postgres.exe")}
+	for _, symbol := range imported {
+		name, dll, ok := strings.Cut(symbol, ":")
+		if ok && strings.EqualFold(dll, "postgres.exe") {
+			symbols.imports = append(symbols.imports, name)
+		}
+	}
+	return symbols, nil
+}
+
+// readPEExports returns the symbols that are exported by a PE file, which are listed within its export directory.
+func readPEExports(path string) (map[string]struct{}, error) {
+	names, err := readPEExportNames(path)
+	if err != nil {
+		return nil, err
+	}
+	exports := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		exports[name] = struct{}{}
+	}
+	return exports, nil
+}
+
+// readPEExportNames returns the names of the symbols that are exported by a PE file, in the order that they're listed
+// within its export directory.
+func readPEExportNames(path string) ([]string, error) {
+	f, err := pe.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var exportDir pe.DataDirectory
+	switch header := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if header.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_EXPORT {
+			exportDir = header.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXPORT]
+		}
+	case *pe.OptionalHeader64:
+		if header.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_EXPORT {
+			exportDir = header.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXPORT]
+		}
+	}
+	if exportDir.VirtualAddress == 0 {
+		return nil, nil
+	}
+	// readRVA returns the data of the file starting at the given relative virtual address
+	readRVA := func(rva uint32) ([]byte, error) {
+		for _, section := range f.Sections {
+			if rva >= section.VirtualAddress && rva < section.VirtualAddress+section.VirtualSize {
+				data, err := section.Data()
+				if err != nil {
+					return nil, err
+				}
+				offset := rva - section.VirtualAddress
+				if offset >= uint32(len(data)) {
+					break
+				}
+				return data[offset:], nil
+			}
+		}
+		return nil, fmt.Errorf("`%s` has an invalid export directory", path)
+	}
+	dir, err := readRVA(exportDir.VirtualAddress)
+	if err != nil {
+		return nil, err
+	}
+	if len(dir) < 40 {
+		return nil, fmt.Errorf("`%s` has an invalid export directory", path)
+	}
+	numberOfNames := binary.LittleEndian.Uint32(dir[24:])
+	names, err := readRVA(binary.LittleEndian.Uint32(dir[32:]))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(names)) < uint64(numberOfNames)*4 {
+		return nil, fmt.Errorf("`%s` has an invalid export directory", path)
+	}
+	exports := make([]string, 0, numberOfNames)
+	for i := uint32(0); i < numberOfNames; i++ {
+		name, err := readRVA(binary.LittleEndian.Uint32(names[i*4:]))
+		if err != nil {
+			return nil, err
+		}
+		if end := bytes.IndexByte(name, 0); end >= 0 {
+			name = name[:end]
+		}
+		exports = append(exports, string(name))
+	}
+	return exports, nil
+}
+
+// peSymbolNames maps the names of a PE file's exports to the names that they're exported under. Exports are mapped by
+// their own name, and 32-bit files also map them by their undecorated name, so that "_PG_init" finds an export named
+// "__PG_init" and "pg_finfo_func" finds "_pg_finfo_func@0". Exports whose own name matches take precedence over those
+// whose undecorated name matches.
+func peSymbolNames(path string) (map[string]string, error) {
+	names, err := readPEExportNames(path)
+	if err != nil {
+		return nil, err
+	}
+	symbolNames := make(map[string]string, len(names))
+	for _, name := range names {
+		symbolNames[name] = name
+	}
+	// Only 32-bit x86 decorates the names of C functions, so an underscore elsewhere is part of the name
+	if arch, err := libraryArchitecture(path); err != nil || arch != "386" {
+		return symbolNames, nil
+	}
+	for _, name := range names {
+		undecorated := undecoratePESymbol(name)
+		if _, ok := symbolNames[undecorated]; !ok {
+			symbolNames[undecorated] = name
+		}
+	}
+	return symbolNames, nil
+}
+
+// undecoratePESymbol removes the decorations that 32-bit x86 compilers add to the names of C functions, which are a
+// leading underscore for cdecl and stdcall, a leading "@" for fastcall, and a trailing "@" with the size of the
+// arguments for stdcall and fastcall. C++ names, which begin with "?", are returned as given.
+func undecoratePESymbol(name string) string {
+	if strings.HasPrefix(name, "?") {
+		return name
+	}
+	if at := strings.LastIndexByte(name, '@'); at > 0 && at < len(name)-1 {
+		if _, err := strconv.ParseUint(name[at+1:], 10, 32); err == nil {
+			name = name[:at]
+		}
+	}
+	if strings.HasPrefix(name, "_") || strings.HasPrefix(name, "@") {
+		name = name[1:]
+	}
+	return name
+}
@@ -0,0 +1,107 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#include <stdint.h>
+
+// dispatcher_id identifies the dispatcher that owns the current thread, and is zero for all other threads.
+static _Thread_local uintptr_t dispatcher_id;
+
+static inline void SetDispatcherID(uintptr_t id) {
+	dispatcher_id = id;
+}
+
+static inline uintptr_t DispatcherID(void) {
+	return dispatcher_id;
+}
+*/
+import "C"
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// threadDispatcher runs every call into a library on a single, dedicated OS thread, one call at a time. Extensions are
+// written for a backend process with a single thread, so those that keep state within static or thread-local variables
+// may only be called this way.
+type threadDispatcher struct {
+	id   uintptr
+	jobs chan func()
+}
+
+// lastDispatcherID is the ID of the most recently created dispatcher.
+var lastDispatcherID atomic.Uintptr
+
+// newThreadDispatcher returns a dispatcher whose thread has started. The dispatcher must be closed once the library is
+// no longer used.
+func newThreadDispatcher() *threadDispatcher {
+	d := &threadDispatcher{
+		id:   lastDispatcherID.Add(1),
+		jobs: make(chan func()),
+	}
+	started := make(chan struct{})
+	go d.loop(started)
+	<-started
+	return d
+}
+
+// loop runs the jobs of the dispatcher until it's closed. The thread is never unlocked, so that it exits along with the
+// goroutine rather than being reused for other goroutines.
+func (d *threadDispatcher) loop(started chan struct{}) {
+	runtime.LockOSThread()
+	C.SetDispatcherID(C.uintptr_t(d.id))
+	close(started)
+	for job := range d.jobs {
+		job()
+	}
+}
+
+// run runs the function on the dispatcher's thread, waiting until it has returned. The backend state that is bound to
+// the calling thread is bound to the dispatcher's thread for the duration of the function, and the calling thread's
+// holds of the backend lock are released in the meantime, so that the dispatcher's thread may take it. Functions that
+// are run from the dispatcher's own thread, such as when an extension calls back into the host, which then calls the
+// same library, are run immediately, as the thread is already busy with the outer call.
+func (d *threadDispatcher) run(f func()) {
+	if uintptr(C.DispatcherID()) == d.id {
+		f()
+		return
+	}
+	// The state is moved from this thread to the dispatcher's thread, so we must remain on the same thread until it has
+	// been moved back
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	state := shimBackendStateBind.MustCall(0)
+	defer shimBackendStateBind.MustCall(state)
+	depth := shimBackendLockYield.MustCall()
+	defer shimBackendLockResume.MustCall(depth)
+	done := make(chan any, 1)
+	d.jobs <- func() {
+		defer func() {
+			done <- recover()
+		}()
+		previous := shimBackendStateBind.MustCall(state)
+		defer shimBackendStateBind.MustCall(previous)
+		f()
+	}
+	if r := <-done; r != nil {
+		panic(r)
+	}
+}
+
+// close stops the dispatcher's thread once its current job has finished. The dispatcher may not be used afterward.
+func (d *threadDispatcher) close() {
+	close(d.jobs)
+}
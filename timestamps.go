@@ -0,0 +1,141 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// TimestampNegativeInfinity is the Datum of a timestamp or timestamptz of -infinity.
+	TimestampNegativeInfinity = Datum(1 << 63)
+	// TimestampInfinity is the Datum of a timestamp or timestamptz of infinity.
+	TimestampInfinity = Datum(math.MaxInt64)
+	// DateNegativeInfinity is the Datum of a date of -infinity.
+	DateNegativeInfinity = Datum(0xFFFFFFFF80000000)
+	// DateInfinity is the Datum of a date of infinity.
+	DateInfinity = Datum(math.MaxInt32)
+)
+
+const (
+	// postgresEpochMicros is the Unix time of midnight on January 1, 2000 UTC in microseconds, which is the epoch of
+	// both timestamps and dates.
+	postgresEpochMicros = 946684800000000
+	// microsPerDay is the number of microseconds in a day.
+	microsPerDay = 86400000000
+	// minTimestampMicros and maxTimestampMicros bound the timestamps that Postgres accepts, which are 4714-11-24 BC and
+	// 294277-01-01 (exclusive), relative to the Postgres epoch.
+	minTimestampMicros = -211813488000000000
+	maxTimestampMicros = 9223371331200000000
+	// intervalSize is the size of an interval, which is passed by reference.
+	intervalSize = 16
+)
+
+// Interval is the value of an interval. Months and days are kept apart from the time, as their lengths vary.
+type Interval struct {
+	Microseconds int64
+	Days         int32
+	Months       int32
+}
+
+// The conversions in this file use the wall clock time of timestamps without a time zone, and the absolute time of
+// timestamps with one. Times are truncated to microseconds, which is the precision of Postgres. Infinite timestamps and
+// dates cannot be represented by time.Time, so converting them returns an error, and callers may compare against the
+// infinity Datums beforehand.
+
+// DatumToTimestampTz returns the timestamptz that is stored within the Datum, in UTC.
+func DatumToTimestampTz(d Datum) (time.Time, error) {
+	if d == TimestampInfinity || d == TimestampNegativeInfinity {
+		return time.Time{}, fmt.Errorf("cannot convert an infinite timestamp to a time")
+	}
+	return time.UnixMicro(int64(d) + postgresEpochMicros).UTC(), nil
+}
+
+// TimestampTzToDatum returns a Datum that stores the time as a timestamptz.
+func TimestampTzToDatum(t time.Time) (Datum, error) {
+	return timestampMicros(t.UTC())
+}
+
+// DatumToTimestamp returns the timestamp that is stored within the Datum. The time is in UTC, which only serves to hold
+// the wall clock time.
+func DatumToTimestamp(d Datum) (time.Time, error) {
+	return DatumToTimestampTz(d)
+}
+
+// TimestampToDatum returns a Datum that stores the wall clock time of the time as a timestamp, ignoring its location.
+func TimestampToDatum(t time.Time) (Datum, error) {
+	return timestampMicros(time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(),
+		time.UTC))
+}
+
+// DatumToDate returns midnight UTC of the date that is stored within the Datum.
+func DatumToDate(d Datum) (time.Time, error) {
+	if days := int32(d); days == math.MaxInt32 || days == math.MinInt32 {
+		return time.Time{}, fmt.Errorf("cannot convert an infinite date to a time")
+	}
+	return time.Date(2000, time.January, 1+int(DatumToInt32(d)), 0, 0, 0, 0, time.UTC), nil
+}
+
+// DateToDatum returns a Datum that stores the date of the time, within the time's location.
+func DateToDatum(t time.Time) (Datum, error) {
+	micros, err := timestampMicros(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		return 0, err
+	}
+	return Int32ToDatum(int32(int64(micros) / microsPerDay)), nil
+}
+
+// DatumToInterval returns the interval that the Datum points to.
+func DatumToInterval(d Datum) (Interval, error) {
+	if d == 0 {
+		return Interval{}, fmt.Errorf("cannot read an interval from a null pointer")
+	}
+	return decodeInterval(copyFromDatum(d, intervalSize)), nil
+}
+
+// IntervalToDatum returns an interval Datum of the value, which is allocated within the current memory context.
+func IntervalToDatum(v Interval) (Datum, error) {
+	data := make([]byte, 0, intervalSize)
+	data = binary.LittleEndian.AppendUint64(data, uint64(v.Microseconds))
+	data = binary.LittleEndian.AppendUint32(data, uint32(v.Days))
+	data = binary.LittleEndian.AppendUint32(data, uint32(v.Months))
+	return pallocCopy(data)
+}
+
+// decodeInterval decodes the bytes of an interval.
+func decodeInterval(data []byte) Interval {
+	return Interval{
+		Microseconds: int64(binary.LittleEndian.Uint64(data)),
+		Days:         int32(binary.LittleEndian.Uint32(data[8:])),
+		Months:       int32(binary.LittleEndian.Uint32(data[12:])),
+	}
+}
+
+// timestampMicros returns the time as microseconds since the Postgres epoch, which must be within the range of
+// timestamps.
+func timestampMicros(t time.Time) (Datum, error) {
+	// UnixMicro overflows outside of roughly 290,000 years, so the range is checked by year first
+	if t.Year() < -4713 || t.Year() > 294276 {
+		return 0, fmt.Errorf("timestamp out of range: %s", t.Format(time.RFC3339))
+	}
+	micros := t.UnixMicro() - postgresEpochMicros
+	if micros < minTimestampMicros || micros >= maxTimestampMicros {
+		return 0, fmt.Errorf("timestamp out of range: %s", t.Format(time.RFC3339))
+	}
+	return Datum(micros), nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+
+extern void pgextHostFetchToast(uint32_t toastrelid, uint32_t valueid, char* dest, int32_t size, int* sqlerrcode,
+	char** message);
+
+static inline PgExtToastFetcher* NewHostToastFetcher() {
+	PgExtToastFetcher* fetcher = (PgExtToastFetcher*)malloc(sizeof(PgExtToastFetcher));
+	fetcher->fetch = (void (*)(Oid, Oid, char*, int32_t, int*, char**))pgextHostFetchToast;
+	return fetcher;
+}
+*/
+import "C"
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// ToastFetcher fetches the values that the host's storage layer has moved out of line into TOAST tables. Extensions
+// are given TOAST pointers to such values (see ToastPointer), which are fetched through the host once the extension
+// detoasts them.
+type ToastFetcher interface {
+	// FetchToast returns the bytes of the value with the given ID, within the TOAST table with the given OID, exactly as
+	// they were stored. A compressed value is returned compressed, beginning with the 4 bytes that hold its
+	// decompressed size and compression method. The length must match the stored size within the TOAST pointer.
+	FetchToast(toastRelID uint32, valueID uint32) ([]byte, error)
+}
+
+// ToastCompression is the method that compressed a value that was stored in a TOAST table.
+type ToastCompression uint8
+
+const (
+	ToastCompressionPglz ToastCompression = 0
+	ToastCompressionLZ4  ToastCompression = 1
+)
+
+// ToastPointer refers to a value that was stored within a TOAST table.
+type ToastPointer struct {
+	// RawSize is the size of the value once it has been detoasted, excluding its header.
+	RawSize int
+	// StoredSize is the number of bytes that were stored, which is the same as RawSize for values that were not
+	// compressed. For compressed values, this includes the 4 bytes that precede the compressed data.
+	StoredSize int
+	// Compression is the method that compressed the value, which is ignored for values that were not compressed.
+	Compression ToastCompression
+	ValueID     uint32
+	ToastRelID  uint32
+}
+
+// vartagOnDisk is the tag of a TOAST pointer to a value within a TOAST table.
+const vartagOnDisk = 18
+
+var (
+	// currentToastFetcher fetches values for extensions, or is nil if the host does not store TOAST values.
+	currentToastFetcher ToastFetcher
+	// toastFetcherMutex gates access to the fetcher.
+	toastFetcherMutex = &sync.RWMutex{}
+	// hostToastFetcher is the C struct that forwards to the Go fetcher.
+	hostToastFetcher = sync.OnceValue(func() *C.PgExtToastFetcher {
+		return C.NewHostToastFetcher()
+	})
+	shimSetToastFetcher = newShimProc("pgext_set_toast_fetcher")
+)
+
+// SetToastFetcher sets the fetcher that is used when extensions detoast values that were stored in TOAST tables.
+// Setting nil causes such values to raise an error when they're detoasted, which is the default.
+func SetToastFetcher(fetcher ToastFetcher) error {
+	toastFetcherMutex.Lock()
+	currentToastFetcher = fetcher
+	toastFetcherMutex.Unlock()
+	var fetcherPtr uintptr
+	if fetcher != nil {
+		fetcherPtr = uintptr(unsafe.Pointer(hostToastFetcher()))
+	}
+	_, err := shimSetToastFetcher.Call(fetcherPtr)
+	return err
+}
+
+// Varlena returns the TOAST pointer in its varlena form, which may be given to extensions in place of the value.
+func (pointer ToastPointer) Varlena() ([]byte, error) {
+	if pointer.RawSize < 0 || pointer.RawSize > maxVarlenaSize-varHdrSz {
+		return nil, fmt.Errorf("TOAST value size `%d` is out of range", pointer.RawSize)
+	}
+	if pointer.StoredSize < 0 || pointer.StoredSize > pointer.RawSize {
+		return nil, fmt.Errorf("TOAST stored size `%d` is out of range", pointer.StoredSize)
+	}
+	extInfo := uint32(pointer.StoredSize)
+	if pointer.StoredSize < pointer.RawSize {
+		extInfo |= uint32(pointer.Compression) << 30
+	}
+	varlena := make([]byte, 2+16)
+	varlena[0] = 0x01
+	varlena[1] = vartagOnDisk
+	binary.LittleEndian.PutUint32(varlena[2:], uint32(pointer.RawSize+varHdrSz))
+	binary.LittleEndian.PutUint32(varlena[6:], extInfo)
+	binary.LittleEndian.PutUint32(varlena[10:], pointer.ValueID)
+	binary.LittleEndian.PutUint32(varlena[14:], pointer.ToastRelID)
+	return varlena, nil
+}
+
+//export pgextHostFetchToast
+func pgextHostFetchToast(toastRelID C.uint32_t, valueID C.uint32_t, dest *C.char, size C.int32_t, sqlerrcode *C.int, message **C.char) {
+	toastFetcherMutex.RLock()
+	fetcher := currentToastFetcher
+	toastFetcherMutex.RUnlock()
+	if fetcher == nil {
+		*message = C.CString(fmt.Sprintf("cannot fetch TOAST value %d in relation %d", valueID, toastRelID))
+		return
+	}
+	data, err := fetcher.FetchToast(uint32(toastRelID), uint32(valueID))
+	if err != nil {
+		var pgErr PostgresError
+		if errors.As(err, &pgErr) {
+			*sqlerrcode = C.int(encodeSQLState(pgErr.Code))
+			*message = C.CString(pgErr.Message)
+		} else {
+			*message = C.CString(err.Error())
+		}
+		return
+	}
+	if len(data) != int(size) {
+		// data_corrupted
+		*sqlerrcode = C.int(encodeSQLState("XX001"))
+		*message = C.CString(fmt.Sprintf("TOAST value %d in relation %d has %d bytes, but %d were expected",
+			valueID, toastRelID, len(data), size))
+		return
+	}
+	if len(data) > 0 {
+		copy(unsafe.Slice((*byte)(unsafe.Pointer(dest)), len(data)), data)
+	}
+}
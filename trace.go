@@ -0,0 +1,165 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+
+extern uintptr_t pgextHostTraceStart(uintptr_t parent, void* fn, char* library, char* function, int nargs);
+extern uintptr_t pgextHostTraceEnd(uintptr_t span, PgExtErrorData* edata);
+
+static inline PgExtTracer* NewHostTracer() {
+	PgExtTracer* tracer = (PgExtTracer*)malloc(sizeof(PgExtTracer));
+	tracer->start = (uintptr_t (*)(uintptr_t, void*, const char*, const char*, int))pgextHostTraceStart;
+	tracer->end = (uintptr_t (*)(uintptr_t, const PgExtErrorData*))pgextHostTraceEnd;
+	return tracer;
+}
+*/
+import "C"
+import (
+	"fmt"
+	"runtime/cgo"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Tracer starts a span for each call to an extension's function, which lets hosts that use OpenTelemetry, or a similar
+// library, trace the calls that their queries make. Calls that functions make to one another through the fmgr, such as
+// through DirectFunctionCall, are given the span of the calling function as their parent, so the spans of a call form
+// the tree of the functions that it called. Spans are started and ended on the thread of the call, so StartSpan and
+// End should return quickly, and must not call into extensions.
+type Tracer interface {
+	// StartSpan starts the span of a call. The parent is the span of the function that made the call, and is nil for
+	// the calls that the host made.
+	StartSpan(parent Span, call TracedCall) Span
+}
+
+// Span is the span of a single call, which was started by a Tracer.
+type Span interface {
+	// End ends the span once the call has returned, with the error that the call raised, or nil if it returned normally.
+	End(duration time.Duration, err error)
+}
+
+// TracedCall describes a call that a span was started for.
+type TracedCall struct {
+	// Extension is the name of the library that contains the function, such as "postgis-3", which is the name of the
+	// shim for the functions that the shim provides. This is empty when the library cannot be found, such as on
+	// Windows.
+	Extension string
+	// Function is the name of the function's symbol, or its address when the symbol cannot be found, such as for
+	// functions that are not exported.
+	Function string
+	NumArgs  int
+}
+
+// tracedSpan is a span along with the state that's needed to end it. The host's handle to a span is a cgo.Handle of a
+// tracedSpan.
+type tracedSpan struct {
+	span   Span
+	start  time.Time
+	parent cgo.Handle
+	// root is the span of the outermost call, which tracks the spans of its nested calls that have not ended, as a
+	// crash unwinds past nested calls without ending their spans. Nested calls are made on the same thread as the
+	// outermost call, one at a time, so these are never accessed concurrently.
+	root *tracedSpan
+	open map[cgo.Handle]*tracedSpan
+}
+
+var (
+	// currentTracer starts the spans of calls, or is nil if calls are not traced.
+	currentTracer Tracer
+	// tracerMutex gates access to the tracer.
+	tracerMutex = &sync.RWMutex{}
+	// hostTracer is the C struct that forwards to the Go tracer.
+	hostTracer = sync.OnceValue(func() *C.PgExtTracer {
+		return C.NewHostTracer()
+	})
+	shimSetTracer = newShimProc("pgext_set_tracer")
+)
+
+// SetTracer sets the tracer that starts a span for each call to an extension's function, including the calls that
+// functions make to one another. Setting nil stops tracing calls, which is the default. Calls into WebAssembly modules,
+// and those within a sandbox worker, are not traced.
+func SetTracer(tracer Tracer) error {
+	tracerMutex.Lock()
+	currentTracer = tracer
+	tracerMutex.Unlock()
+	var tracerPtr uintptr
+	if tracer != nil {
+		tracerPtr = uintptr(unsafe.Pointer(hostTracer()))
+	}
+	_, err := shimSetTracer.Call(tracerPtr)
+	return err
+}
+
+//export pgextHostTraceStart
+func pgextHostTraceStart(parent C.uintptr_t, fn unsafe.Pointer, library *C.char, function *C.char, nargs C.int) C.uintptr_t {
+	tracerMutex.RLock()
+	tracer := currentTracer
+	tracerMutex.RUnlock()
+	if tracer == nil {
+		return 0
+	}
+	call := TracedCall{NumArgs: int(nargs)}
+	if library != nil {
+		call.Extension = libraryName(C.GoString(library))
+	}
+	if function != nil {
+		call.Function = C.GoString(function)
+	} else {
+		call.Function = fmt.Sprintf("0x%x", uintptr(fn))
+	}
+	ts := &tracedSpan{parent: cgo.Handle(parent)}
+	var parentSpan Span
+	if parent != 0 {
+		parentTS := cgo.Handle(parent).Value().(*tracedSpan)
+		parentSpan = parentTS.span
+		ts.root = parentTS.root
+	} else {
+		ts.root = ts
+		ts.open = make(map[cgo.Handle]*tracedSpan)
+	}
+	ts.span = tracer.StartSpan(parentSpan, call)
+	ts.start = time.Now()
+	handle := cgo.NewHandle(ts)
+	if ts.root != ts {
+		ts.root.open[handle] = ts
+	}
+	return C.uintptr_t(handle)
+}
+
+//export pgextHostTraceEnd
+func pgextHostTraceEnd(span C.uintptr_t, edata *C.PgExtErrorData) C.uintptr_t {
+	handle := cgo.Handle(span)
+	ts := handle.Value().(*tracedSpan)
+	var err error
+	if edata != nil {
+		err = newCallError(uintptr(unsafe.Pointer(edata)))
+	}
+	if ts.root == ts {
+		// Nested spans that are still open were unwound past by a crash, so they end along with the outermost call
+		for openHandle, open := range ts.open {
+			open.span.End(time.Since(open.start), err)
+			openHandle.Delete()
+		}
+	} else {
+		delete(ts.root.open, handle)
+	}
+	ts.span.End(time.Since(ts.start), err)
+	handle.Delete()
+	return C.uintptr_t(ts.parent)
+}
@@ -0,0 +1,110 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+
+extern void pgextHostBindDomain(char* domain);
+extern char* pgextHostTranslate(char* domain, char* singular, char* plural, unsigned long n, bool is_plural);
+
+static inline PgExtTranslator* NewHostTranslator() {
+	PgExtTranslator* translator = (PgExtTranslator*)malloc(sizeof(PgExtTranslator));
+	translator->bind_domain = (void (*)(const char*))pgextHostBindDomain;
+	translator->translate = (const char* (*)(const char*, const char*, const char*, unsigned long, bool))pgextHostTranslate;
+	return translator;
+}
+*/
+import "C"
+import (
+	"sync"
+	"unsafe"
+)
+
+// Translator translates the messages of extensions that were built with NLS support. Extensions use their own message
+// domain, which is generally the name of the extension followed by its major version.
+type Translator interface {
+	// BindTextDomain is called when an extension declares its message domain.
+	BindTextDomain(domain string)
+	// Translate returns the translation of the given message. If the message should not be translated, then it should be
+	// returned as-is.
+	Translate(domain string, msgid string) string
+	// TranslatePlural returns the translation of the message that is appropriate for n. If the message should not be
+	// translated, then singular should be returned when n is 1, and plural otherwise.
+	TranslatePlural(domain string, singular string, plural string, n uint64) string
+}
+
+var (
+	// currentTranslator is the translator that is being used by extensions, or nil if messages are passed through.
+	currentTranslator Translator
+	// translations contains all translated messages, which must remain allocated as extensions may retain them.
+	translations = make(map[string]*C.char)
+	// translatorMutex gates access to the translator and its translations.
+	translatorMutex = &sync.RWMutex{}
+	// hostTranslator is the C struct that forwards to the Go translator.
+	hostTranslator = sync.OnceValue(func() *C.PgExtTranslator {
+		return C.NewHostTranslator()
+	})
+	shimSetTranslator = newShimProc("pgext_set_translator")
+)
+
+// SetTranslator sets the translator that is used for the messages of all extensions. Setting nil passes messages through
+// untranslated, which is the default.
+func SetTranslator(translator Translator) error {
+	translatorMutex.Lock()
+	currentTranslator = translator
+	translatorMutex.Unlock()
+	var translatorPtr uintptr
+	if translator != nil {
+		translatorPtr = uintptr(unsafe.Pointer(hostTranslator()))
+	}
+	_, err := shimSetTranslator.Call(translatorPtr)
+	return err
+}
+
+//export pgextHostBindDomain
+func pgextHostBindDomain(domain *C.char) {
+	translatorMutex.RLock()
+	translator := currentTranslator
+	translatorMutex.RUnlock()
+	if translator != nil {
+		translator.BindTextDomain(C.GoString(domain))
+	}
+}
+
+//export pgextHostTranslate
+func pgextHostTranslate(domain *C.char, singular *C.char, plural *C.char, n C.ulong, isPlural C.bool) *C.char {
+	translatorMutex.RLock()
+	translator := currentTranslator
+	translatorMutex.RUnlock()
+	if translator == nil {
+		return nil
+	}
+	var translated string
+	if isPlural {
+		translated = translator.TranslatePlural(C.GoString(domain), C.GoString(singular), C.GoString(plural), uint64(n))
+	} else {
+		translated = translator.Translate(C.GoString(domain), C.GoString(singular))
+	}
+	translatorMutex.Lock()
+	defer translatorMutex.Unlock()
+	if cTranslated, ok := translations[translated]; ok {
+		return cTranslated
+	}
+	cTranslated := C.CString(translated)
+	translations[translated] = cTranslated
+	return cTranslated
+}
@@ -0,0 +1,270 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+
+static inline void SetFmgrInfoAddr(FmgrInfo* finfo, uintptr_t fn) {
+	finfo->fn_addr = (void*)fn;
+}
+
+static inline TriggerData* ToTriggerData(uintptr_t trigdata) {
+	return (TriggerData*)trigdata;
+}
+
+static inline uintptr_t TriggerTupleHeader(uintptr_t tuple) {
+	return (uintptr_t)((HeapTuple)tuple)->t_data;
+}
+
+static inline void SetRelationName(Relation relation, const char* name) {
+	strncpy(relation->rd_rel->relname.data, name, NAMEDATALEN - 1);
+}
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// TriggerEvent describes the operation that fired a trigger, along with when the trigger was fired. Events are formed
+// by combining an operation with a timing, such as TriggerEventBefore | TriggerEventRow | TriggerEventUpdate.
+type TriggerEvent uint32
+
+const (
+	TriggerEventInsert    TriggerEvent = C.TRIGGER_EVENT_INSERT
+	TriggerEventDelete    TriggerEvent = C.TRIGGER_EVENT_DELETE
+	TriggerEventUpdate    TriggerEvent = C.TRIGGER_EVENT_UPDATE
+	TriggerEventTruncate  TriggerEvent = C.TRIGGER_EVENT_TRUNCATE
+	TriggerEventRow       TriggerEvent = C.TRIGGER_EVENT_ROW
+	TriggerEventBefore    TriggerEvent = C.TRIGGER_EVENT_BEFORE
+	TriggerEventAfter     TriggerEvent = C.TRIGGER_EVENT_AFTER
+	TriggerEventInsteadOf TriggerEvent = C.TRIGGER_EVENT_INSTEAD
+)
+
+// Operation returns the operation that fired the trigger.
+func (e TriggerEvent) Operation() TriggerEvent {
+	return e & C.TRIGGER_EVENT_OPMASK
+}
+
+// IsRow returns whether the trigger is fired once per row, rather than once per statement.
+func (e TriggerEvent) IsRow() bool {
+	return e&TriggerEventRow != 0
+}
+
+// TriggerRelation describes the relation that a trigger was fired on.
+type TriggerRelation struct {
+	OID       uint32
+	Name      string
+	Namespace uint32
+	Columns   []Column
+}
+
+// TriggerData describes the event that a trigger function is called for, which mirrors the TriggerData that Postgres
+// gives to trigger functions.
+type TriggerData struct {
+	Event    TriggerEvent
+	Relation TriggerRelation
+	// TriggerName and TriggerOID identify the trigger that is calling the function.
+	TriggerName string
+	TriggerOID  uint32
+	// Args are the arguments that were given to the function when the trigger was created.
+	Args []string
+	// OldRow is the row that is being updated or deleted, while NewRow is the row that is being inserted or the updated
+	// version of a row. Only row-level triggers are given rows.
+	OldRow []NullableDatum
+	NewRow []NullableDatum
+}
+
+var (
+	shimTriggerBegin = newShimProc("pgext_trigger_begin")
+	shimTriggerTuple = newShimProc("pgext_trigger_tuple")
+)
+
+// CallFmgrTrigger calls the given trigger function, returning the row that it returned. BEFORE row-level triggers
+// return the row that should be stored, or NULL to skip the operation for the row, while the results of other triggers
+// are ignored by Postgres. By-reference values point into the returned row, which is allocated within the current
+// memory context.
+func CallFmgrTrigger(fn uintptr, data TriggerData) (row []NullableDatum, isNotNull bool, err error) {
+	return callTrigger(fn, data, func(fi *C.FmgrInfo, nodes callNodes) (Datum, bool, error) {
+		return callFmgrInfo(fi, nodes, 0)
+	})
+}
+
+// CallTrigger is the same as CallFmgrTrigger, except that the call is recorded against the resource usage of the
+// function's library.
+func (f Function) CallTrigger(data TriggerData) (row []NullableDatum, isNotNull bool, err error) {
+	return callTrigger(f.Ptr, data, func(fi *C.FmgrInfo, nodes callNodes) (Datum, bool, error) {
+		fillFmgrInfo(fi, f)
+		result, isNull, _, err := f.callWithInfo(fi, nodes, 0)
+		return result, isNull, err
+	})
+}
+
+// callTrigger makes the given call with the trigger data in the call info's context, and decodes the returned row.
+func callTrigger(fn uintptr, data TriggerData,
+	call func(fi *C.FmgrInfo, nodes callNodes) (Datum, bool, error)) ([]NullableDatum, bool, error) {
+	if err := data.validate(); err != nil {
+		return nil, false, err
+	}
+	columns := data.Relation.Columns
+	trigPtr, err := shimTriggerBegin.Call(uintptr(data.Event), uintptr(len(columns)))
+	if err != nil {
+		return nil, false, err
+	}
+	if trigPtr == 0 {
+		return nil, false, errors.New("out of memory while calling a trigger function")
+	}
+	trigdata := C.ToTriggerData(C.uintptr_t(trigPtr))
+	relation := trigdata.tg_relation
+	initTupleDesc(relation.rd_att, columns)
+	relation.rd_id = C.Oid(data.Relation.OID)
+	relation.rd_rel.oid = C.Oid(data.Relation.OID)
+	relation.rd_rel.relnamespace = C.Oid(data.Relation.Namespace)
+	relName := C.CString(data.Relation.Name)
+	C.SetRelationName(relation, relName)
+	Free(relName)
+	// The trigger's strings are owned by the host, as they're only needed for the duration of the call
+	trigger := trigdata.tg_trigger
+	trigger.tgoid = C.Oid(data.TriggerOID)
+	trigger.tgname = C.CString(data.TriggerName)
+	defer Free(trigger.tgname)
+	trigger.tgtype = C.int16_t(data.Event.triggerType())
+	trigger.tgnargs = C.int16_t(len(data.Args))
+	if len(data.Args) > 0 {
+		trigger.tgargs = (**C.char)(C.calloc(C.size_t(len(data.Args)), C.size_t(unsafe.Sizeof((*C.char)(nil)))))
+		tgargs := unsafe.Slice(trigger.tgargs, len(data.Args))
+		for i, arg := range data.Args {
+			tgargs[i] = C.CString(arg)
+		}
+		defer func() {
+			for _, arg := range tgargs {
+				Free(arg)
+			}
+			Free(trigger.tgargs)
+		}()
+	}
+	if data.Event.IsRow() {
+		// The trigger tuple is the row that the operation acts on, which is the new row for inserts and the old row
+		// otherwise
+		trigRow := data.OldRow
+		if data.Event.Operation() == TriggerEventInsert {
+			trigRow = data.NewRow
+		}
+		if err = setTriggerTuple(trigdata, false, trigRow); err != nil {
+			return nil, false, err
+		}
+		if data.Event.Operation() == TriggerEventUpdate {
+			if err = setTriggerTuple(trigdata, true, data.NewRow); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+	fi := Malloc[C.FmgrInfo]()
+	defer Free(fi)
+	ZeroMemory(fi)
+	C.SetFmgrInfoAddr(fi, C.uintptr_t(fn))
+	result, isNull, err := call(fi, callNodes{context: unsafe.Pointer(trigdata)})
+	if err != nil {
+		return nil, false, err
+	}
+	if isNull || result == 0 {
+		return nil, false, nil
+	}
+	if len(columns) == 0 {
+		return []NullableDatum{}, true, nil
+	}
+	row, err := DecodeComposite(Datum(C.TriggerTupleHeader(C.uintptr_t(result))), columns)
+	return row, err == nil, err
+}
+
+// setTriggerTuple forms a tuple from the row, which becomes either the new tuple or the trigger tuple of the data.
+func setTriggerTuple(trigdata *C.TriggerData, newTuple bool, row []NullableDatum) error {
+	natts := max(len(row), 1)
+	values := (*C.Datum)(C.calloc(C.size_t(natts), C.size_t(unsafe.Sizeof(C.Datum(0)))))
+	defer Free(values)
+	isnull := (*C.bool)(C.calloc(C.size_t(natts), C.size_t(unsafe.Sizeof(C.bool(false)))))
+	defer Free(isnull)
+	valuesSlice := unsafe.Slice(values, natts)
+	isnullSlice := unsafe.Slice(isnull, natts)
+	for i, value := range row {
+		valuesSlice[i] = C.Datum(value.Value)
+		isnullSlice[i] = C.bool(value.IsNull)
+	}
+	var which uintptr
+	if newTuple {
+		which = 1
+	}
+	edata, err := shimTriggerTuple.Call(uintptr(unsafe.Pointer(trigdata)), which, uintptr(unsafe.Pointer(values)),
+		uintptr(unsafe.Pointer(isnull)))
+	if err != nil {
+		return err
+	}
+	if edata != 0 {
+		return newCallError(edata)
+	}
+	return nil
+}
+
+// validate returns an error if the trigger data does not have the rows that its event requires.
+func (data TriggerData) validate() error {
+	operation := data.Event.Operation()
+	if !data.Event.IsRow() {
+		if data.OldRow != nil || data.NewRow != nil {
+			return errors.New("statement-level triggers are not given rows")
+		}
+		return nil
+	}
+	if operation == TriggerEventTruncate {
+		return errors.New("TRUNCATE triggers cannot be row-level")
+	}
+	numColumns := len(data.Relation.Columns)
+	if operation != TriggerEventInsert && len(data.OldRow) != numColumns {
+		return fmt.Errorf("trigger on `%s` expected an old row with %d values but received %d",
+			data.Relation.Name, numColumns, len(data.OldRow))
+	}
+	if operation != TriggerEventDelete && len(data.NewRow) != numColumns {
+		return fmt.Errorf("trigger on `%s` expected a new row with %d values but received %d",
+			data.Relation.Name, numColumns, len(data.NewRow))
+	}
+	return nil
+}
+
+// triggerType returns the tgtype of a trigger that fires for the event, which is how pg_trigger records the event.
+func (e TriggerEvent) triggerType() int16 {
+	var tgtype int16
+	if e.IsRow() {
+		tgtype |= 1 << 0
+	}
+	switch e & C.TRIGGER_EVENT_TIMINGMASK {
+	case TriggerEventBefore:
+		tgtype |= 1 << 1
+	case TriggerEventInsteadOf:
+		tgtype |= 1 << 6
+	}
+	switch e.Operation() {
+	case TriggerEventInsert:
+		tgtype |= 1 << 2
+	case TriggerEventDelete:
+		tgtype |= 1 << 3
+	case TriggerEventUpdate:
+		tgtype |= 1 << 4
+	case TriggerEventTruncate:
+		tgtype |= 1 << 5
+	}
+	return tgtype
+}
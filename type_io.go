@@ -0,0 +1,207 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+
+static inline void SetStringInfoData(StringInfoData* buf, uintptr_t data, int len) {
+	buf->data = (char*)data;
+	buf->len = len;
+	buf->maxlen = len + 1;
+	buf->cursor = 0;
+}
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+)
+
+// TypeIOFunctions are the I/O functions of a type. Receive and Send may be left as zero values, as some types do not
+// have a binary form.
+type TypeIOFunctions struct {
+	Input   Function
+	Output  Function
+	Receive Function
+	Send    Function
+}
+
+// TypeIO converts the values of a type between Datums and their text and binary forms by calling the type's I/O
+// functions, which allows the host to store and display types that are defined by extensions. Each function is called
+// through its own FmgrInfo, so that any state it caches is kept between calls. Datums that are returned are allocated
+// within the current memory context.
+type TypeIO struct {
+	typeOID uint32
+	input   *FmgrInfo
+	output  *FmgrInfo
+	receive *FmgrInfo
+	send    *FmgrInfo
+}
+
+// LookupTypeIOFunctions returns the I/O functions with the given names from the library. The names of the receive and
+// send functions may be empty for types without a binary form.
+func LookupTypeIOFunctions(lib *Library, input, output, receive, send string) (TypeIOFunctions, error) {
+	var fns TypeIOFunctions
+	for _, lookup := range []struct {
+		name     string
+		fn       *Function
+		required bool
+	}{
+		{input, &fns.Input, true},
+		{output, &fns.Output, true},
+		{receive, &fns.Receive, false},
+		{send, &fns.Send, false},
+	} {
+		if lookup.name == "" && !lookup.required {
+			continue
+		}
+		fn, ok := lib.Function(lookup.name)
+		if !ok {
+			return TypeIOFunctions{}, fmt.Errorf("library `%s` does not contain the function `%s`", lib.path, lookup.name)
+		}
+		*lookup.fn = fn
+	}
+	return fns, nil
+}
+
+// NewTypeIO returns a TypeIO that calls the given functions for the type with the given OID, which is passed to the
+// input and receive functions as their type parameter. The TypeIO must be closed once it is no longer needed.
+func NewTypeIO(typeOID uint32, fns TypeIOFunctions) (_ *TypeIO, err error) {
+	if fns.Input.Ptr == 0 || fns.Output.Ptr == 0 {
+		return nil, fmt.Errorf("a type must have both an input and an output function")
+	}
+	tio := &TypeIO{typeOID: typeOID}
+	defer func() {
+		if err != nil {
+			_ = tio.Close()
+		}
+	}()
+	for _, handle := range []struct {
+		fn   Function
+		info **FmgrInfo
+	}{
+		{fns.Input, &tio.input},
+		{fns.Output, &tio.output},
+		{fns.Receive, &tio.receive},
+		{fns.Send, &tio.send},
+	} {
+		if handle.fn.Ptr == 0 {
+			continue
+		}
+		if *handle.info, err = NewFmgrInfo(handle.fn, 0); err != nil {
+			return nil, err
+		}
+	}
+	return tio, nil
+}
+
+// HasBinary returns whether the type has both a receive and a send function.
+func (tio *TypeIO) HasBinary() bool {
+	return tio.receive != nil && tio.send != nil
+}
+
+// Input returns the Datum of the text form of a value, which is the same as InputFunctionCall. The typmod is -1 when the
+// type has no modifier.
+func (tio *TypeIO) Input(text string, typmod int32) (Datum, error) {
+	str, err := CStringToDatum(text)
+	if err != nil {
+		return 0, err
+	}
+	defer FreeDatum(str)
+	result, isNotNull, err := tio.input.Call(NewNullableDatum(str), NewNullableDatum(OidToDatum(tio.typeOID)),
+		NewNullableDatum(Int32ToDatum(typmod)))
+	if err != nil {
+		return 0, err
+	}
+	if !isNotNull {
+		return 0, fmt.Errorf("input function `%s` returned NULL", tio.input.Function().Name)
+	}
+	return result, nil
+}
+
+// Output returns the text form of the value that is stored within the Datum, which is the same as OutputFunctionCall.
+func (tio *TypeIO) Output(d Datum) (string, error) {
+	result, isNotNull, err := tio.output.Call(NewNullableDatum(d))
+	if err != nil {
+		return "", err
+	}
+	if !isNotNull {
+		return "", fmt.Errorf("output function `%s` returned NULL", tio.output.Function().Name)
+	}
+	defer FreeDatum(result)
+	return DatumToCString(result)
+}
+
+// Receive returns the Datum of the binary form of a value, which is the same as ReceiveFunctionCall. Returns an error if
+// the receive function does not consume all of the data, as the data is not of the type's binary format.
+func (tio *TypeIO) Receive(data []byte, typmod int32) (Datum, error) {
+	if tio.receive == nil {
+		return 0, fmt.Errorf("type %d does not have a receive function", tio.typeOID)
+	}
+	// The data is always followed by a terminator, which receive functions may rely on when reading strings
+	buf, err := pallocCopy(append(data[:len(data):len(data)], 0))
+	if err != nil {
+		return 0, err
+	}
+	defer FreeDatum(buf)
+	info := Malloc[C.StringInfoData]()
+	defer Free(info)
+	C.SetStringInfoData(info, C.uintptr_t(buf), C.int(len(data)))
+	result, isNotNull, err := tio.receive.Call(NewNullableDatum(ToDatum(info)),
+		NewNullableDatum(OidToDatum(tio.typeOID)), NewNullableDatum(Int32ToDatum(typmod)))
+	if err != nil {
+		return 0, err
+	}
+	if !isNotNull {
+		return 0, fmt.Errorf("receive function `%s` returned NULL", tio.receive.Function().Name)
+	}
+	if info.cursor != info.len {
+		return 0, PostgresError{
+			Severity: "ERROR",
+			Code:     "22P03",
+			Message:  "incorrect binary data format",
+		}
+	}
+	return result, nil
+}
+
+// Send returns the binary form of the value that is stored within the Datum, which is the same as SendFunctionCall.
+func (tio *TypeIO) Send(d Datum) ([]byte, error) {
+	if tio.send == nil {
+		return nil, fmt.Errorf("type %d does not have a send function", tio.typeOID)
+	}
+	result, isNotNull, err := tio.send.Call(NewNullableDatum(d))
+	if err != nil {
+		return nil, err
+	}
+	if !isNotNull {
+		return nil, fmt.Errorf("send function `%s` returned NULL", tio.send.Function().Name)
+	}
+	defer FreeDatum(result)
+	return DatumVarlenaData(result)
+}
+
+// Close closes the handles of the type's I/O functions.
+func (tio *TypeIO) Close() error {
+	var errs []error
+	for _, info := range []*FmgrInfo{tio.input, tio.output, tio.receive, tio.send} {
+		if info != nil {
+			errs = append(errs, info.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package pg_extension
 
 /*
 #cgo CFLAGS: "-I${SRCDIR}/library"
@@ -55,8 +55,11 @@ func Free[T any](val *T) {
 	C.free(unsafe.Pointer(val))
 }
 
-// FreeDatum frees the given Datum. Care should be exercised as datums may refer to static memory, and attempting to
-// free static memory will result in a crash.
+// shimPfree frees memory that was allocated by palloc.
+var shimPfree = newShimProc("pgext_pfree")
+
+// FreeDatum frees the given Datum, which must have been allocated by palloc. Care should be exercised as datums may
+// refer to static memory, and attempting to free static memory will result in a crash.
 func FreeDatum(val Datum) {
-	C.free(unsafe.Pointer(val))
+	shimPfree.MustCall(uintptr(val))
 }
@@ -0,0 +1,155 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#include <stdint.h>
+#include <string.h>
+
+static inline size_t DatumCStringLen(uintptr_t d) {
+	return strlen((const char*)d);
+}
+
+static inline void CopyFromDatum(uintptr_t d, void* dst, size_t n) {
+	memcpy(dst, (const void*)d, n);
+}
+
+static inline void CopyToDatum(uintptr_t d, const void* src, size_t n) {
+	memcpy((void*)d, src, n);
+}
+*/
+import "C"
+import (
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+)
+
+const (
+	// varHdrSz is the size of a 4-byte varlena header.
+	varHdrSz = 4
+	// varHdrSzShort is the size of a 1-byte varlena header, which is used by packed values.
+	varHdrSzShort = 1
+	// maxVarlenaSize is the largest size that may be stored within a varlena header.
+	maxVarlenaSize = 0x3FFFFFFF
+)
+
+// shimPalloc allocates memory within the current memory context.
+var shimPalloc = newShimProc("palloc")
+
+// NewVarlena returns a varlena with a 4-byte header that contains the given data. Such varlenas are the in-memory format
+// of text, bytea, and all other variable-length types.
+func NewVarlena(data []byte) []byte {
+	varlena := make([]byte, varHdrSz+len(data))
+	binary.LittleEndian.PutUint32(varlena, uint32(len(varlena))<<2)
+	copy(varlena[varHdrSz:], data)
+	return varlena
+}
+
+// VarlenaData returns the data of the given varlena, which may have either a 1-byte or 4-byte header.
+func VarlenaData(varlena []byte) ([]byte, error) {
+	size, headerSize, err := varlenaHeader(varlena)
+	if err != nil {
+		return nil, err
+	}
+	if size > len(varlena) {
+		return nil, fmt.Errorf("varlena header reports %d bytes, but only %d exist", size, len(varlena))
+	}
+	return varlena[headerSize:size], nil
+}
+
+// VarlenaDatum returns a varlena containing the given data, which is allocated within the current memory context so
+// that it may be given to an extension. The Datum may be freed with FreeDatum.
+func VarlenaDatum(data []byte) (Datum, error) {
+	if len(data)+varHdrSz > maxVarlenaSize {
+		return 0, fmt.Errorf("invalid memory alloc request size %d", len(data)+varHdrSz)
+	}
+	return pallocCopy(NewVarlena(data))
+}
+
+// pallocCopy returns a copy of the given data, which is allocated within the current memory context. The data must not
+// be empty.
+func pallocCopy(data []byte) (Datum, error) {
+	ptr, err := shimPalloc.Call(uintptr(len(data)))
+	if err != nil {
+		return 0, err
+	}
+	if ptr == 0 {
+		return 0, fmt.Errorf("out of memory")
+	}
+	C.CopyToDatum(C.uintptr_t(ptr), unsafe.Pointer(&data[0]), C.size_t(len(data)))
+	return Datum(ptr), nil
+}
+
+// TextDatum returns a text Datum containing the given string, which is allocated within the current memory context.
+func TextDatum(s string) (Datum, error) {
+	return VarlenaDatum([]byte(s))
+}
+
+// DatumVarlenaData returns a copy of the data of the varlena that the Datum points to.
+func DatumVarlenaData(d Datum) ([]byte, error) {
+	if d == 0 {
+		return nil, fmt.Errorf("cannot read a varlena from a null pointer")
+	}
+	size, err := datumVarSize(d)
+	if err != nil {
+		return nil, err
+	}
+	return VarlenaData(copyFromDatum(d, size))
+}
+
+// varlenaHeader decodes the header of the given varlena, returning the total size and the size of the header.
+func varlenaHeader(varlena []byte) (size int, headerSize int, err error) {
+	if len(varlena) == 0 {
+		return 0, 0, fmt.Errorf("varlena is empty")
+	}
+	switch first := varlena[0]; {
+	case first == 0x01:
+		return 0, 0, fmt.Errorf("cannot access an external TOAST value")
+	case first&0x01 == 0x01:
+		return int(first >> 1), varHdrSzShort, nil
+	case first&0x03 == 0x02:
+		return 0, 0, fmt.Errorf("cannot access a compressed TOAST value")
+	case len(varlena) < varHdrSz:
+		return 0, 0, fmt.Errorf("varlena header is truncated")
+	default:
+		return int((binary.LittleEndian.Uint32(varlena) >> 2) & maxVarlenaSize), varHdrSz, nil
+	}
+}
+
+// datumVarSize returns the total size of the varlena that the Datum points to, including its header.
+func datumVarSize(d Datum) (int, error) {
+	// We only read the full 4-byte header when the first byte indicates one, so that we never read past a short value
+	header := copyFromDatum(d, 1)
+	if header[0]&0x01 == 0 {
+		header = copyFromDatum(d, varHdrSz)
+	}
+	size, _, err := varlenaHeader(header)
+	return size, err
+}
+
+// datumCStringLen returns the length of the C string that the Datum points to, excluding its terminator.
+func datumCStringLen(d Datum) int {
+	return int(C.DatumCStringLen(C.uintptr_t(d)))
+}
+
+// copyFromDatum returns a copy of the given number of bytes that the Datum points to.
+func copyFromDatum(d Datum, length int) []byte {
+	data := make([]byte, length)
+	if length > 0 {
+		C.CopyFromDatum(C.uintptr_t(d), unsafe.Pointer(&data[0]), C.size_t(length))
+	}
+	return data
+}
@@ -0,0 +1,404 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// wasmLibrarySuffix is the file suffix of libraries that were compiled to WebAssembly.
+const wasmLibrarySuffix = ".wasm"
+
+// The layout of the call info within the memory of a module. Modules are built for wasm32 with the header in
+// library/wasm, which declares Datum as 64 bits wide so that the same types are passed by value as on 64-bit hosts.
+const (
+	wasmCallInfoCollation = 12
+	wasmCallInfoIsNull    = 16
+	wasmCallInfoNumArgs   = 18
+	wasmCallInfoArgs      = 24
+	wasmNullableDatumSize = 16
+)
+
+// wasmModule is a library that was compiled to WebAssembly, which runs within wazero rather than being loaded by the
+// dynamic loader. A module can only read and write its own memory, so a misbehaving extension cannot reach the memory
+// of the host, and the same module runs on every platform. Modules must export the following, which the header in
+// library/wasm defines through PG_MODULE_MAGIC:
+//
+//   - Pg_magic_func, and a pg_finfo_ function for each SQL-callable function, which return pointers to static memory
+//     as they do within native libraries.
+//   - pgext_alloc and pgext_free, which allocate and free the call info of each call.
+//
+// Errors are raised by calling the imported env.pgext_raise_error, which ends the call when the level is ERROR or
+// higher. Modules may also import WASI, which is given the host's standard output and error.
+type wasmModule struct {
+	runtime wazero.Runtime
+	module  api.Module
+	alloc   api.Function
+	free    api.Function
+	// mutex serializes calls, as the module's memory and globals are shared by every call.
+	mutex sync.Mutex
+	// exports contains the functions that have been looked up, keyed by their address, which is an address within the
+	// host that identifies the function rather than one that may be called.
+	exports map[uintptr]*wasmExport
+	// addresses contains the address of each function that has been looked up, keyed by the function's name.
+	addresses map[string]uintptr
+	// raised is the error that was raised by the current call.
+	raised *PostgresError
+}
+
+// wasmExport is a function that is exported by a module.
+type wasmExport struct {
+	name string
+	fn   api.Function
+}
+
+// errWasmRaised is panicked by env.pgext_raise_error to end the current call.
+var errWasmRaised = errors.New("error raised by WebAssembly module")
+
+var _ InternalLoadedLibrary = (*wasmModule)(nil)
+
+// isWasmLibrary returns whether the library at the given path was compiled to WebAssembly.
+func isWasmLibrary(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), wasmLibrarySuffix)
+}
+
+// loadWasmLibrary loads a library that was compiled to WebAssembly, in the same way as loadLibrary loads a native
+// library. This expects the library mutex to be held.
+func loadWasmLibrary(path string, funcNames []string, attributes map[string]functionAttributes) (*Library, error) {
+	module, err := loadWasmModule(path)
+	if err != nil {
+		return nil, err
+	}
+	lib, err := newLibrary(path, module, funcNames, attributes)
+	if err != nil {
+		_ = module.Close()
+		return nil, err
+	}
+	if err = lib.init(); err != nil {
+		return nil, err
+	}
+	lib.refs = 1
+	loadedLibraries[path] = lib
+	return lib, nil
+}
+
+// wasmReferenceType returns the first of the function's argument and return types that cannot be given to or returned
+// from a WebAssembly module, which is empty when there are none. A Datum of a type that's passed by reference is a
+// pointer into the host, which the module would read as an offset into its own memory, and the same goes for a pointer
+// that the module returns. Types that are not built in, along with internal and anyelement, may be pointers as well.
+func wasmReferenceType(definition *FunctionDefinition) string {
+	typeNames := []string{definition.ReturnType}
+	for _, param := range definition.Parameters {
+		if param.Mode != "out" {
+			typeNames = append(typeNames, param.Type)
+		}
+	}
+	for _, typeName := range typeNames {
+		if len(typeName) == 0 {
+			continue
+		}
+		typ, ok := LookupPostgresType(typeName)
+		if !ok || !typ.Storage.ByValue || typ.Name == "internal" || typ.Name == "anyelement" {
+			return typeName
+		}
+	}
+	return ""
+}
+
+// loadWasmModule compiles and instantiates the module at the given path, calling its _initialize if it has one.
+func loadWasmModule(path string) (*wasmModule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &LoadError{
+			Kind: ErrLibraryNotFound,
+			File: path,
+			Err:  err,
+		}
+	}
+	ctx := context.Background()
+	m := &wasmModule{
+		runtime:   wazero.NewRuntime(ctx),
+		exports:   make(map[uintptr]*wasmExport),
+		addresses: make(map[string]uintptr),
+	}
+	if err = m.instantiate(ctx, data); err != nil {
+		_ = m.runtime.Close(ctx)
+		return nil, &LoadError{
+			Kind: ErrLibraryNotFound,
+			File: path,
+			Err:  err,
+		}
+	}
+	return m, nil
+}
+
+// instantiate instantiates the module from its binary, along with the modules that it imports.
+func (m *wasmModule) instantiate(ctx context.Context, data []byte) error {
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, m.runtime); err != nil {
+		return err
+	}
+	_, err := m.runtime.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(m.raiseError).Export("pgext_raise_error").
+		Instantiate(ctx)
+	if err != nil {
+		return err
+	}
+	compiled, err := m.runtime.CompileModule(ctx, data)
+	if err != nil {
+		return err
+	}
+	config := wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize").
+		WithStdout(os.Stdout).
+		WithStderr(os.Stderr)
+	if m.module, err = m.runtime.InstantiateModule(ctx, compiled, config); err != nil {
+		return err
+	}
+	if m.module.Memory() == nil {
+		return errors.New("module does not export its memory")
+	}
+	m.alloc = m.module.ExportedFunction("pgext_alloc")
+	m.free = m.module.ExportedFunction("pgext_free")
+	if m.alloc == nil || m.free == nil {
+		return errors.New("module does not export pgext_alloc and pgext_free")
+	}
+	return nil
+}
+
+// Lookup implements the interface InternalLoadedLibrary.
+func (m *wasmModule) Lookup(sym string) (uintptr, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if addr, ok := m.addresses[sym]; ok {
+		return addr, nil
+	}
+	fn := m.module.ExportedFunction(sym)
+	if fn == nil {
+		return 0, fmt.Errorf("module does not export `%s`", sym)
+	}
+	export := &wasmExport{name: sym, fn: fn}
+	addr := uintptr(unsafe.Pointer(export))
+	m.exports[addr] = export
+	m.addresses[sym] = addr
+	return addr, nil
+}
+
+// Close implements the interface InternalLoadedLibrary.
+func (m *wasmModule) Close() error {
+	return m.runtime.Close(context.Background())
+}
+
+// export returns the function at the given address, which was returned by Lookup.
+func (m *wasmModule) export(addr uintptr) (*wasmExport, error) {
+	export, ok := m.exports[addr]
+	if !ok {
+		return nil, fmt.Errorf("address %#x is not a function of the module", addr)
+	}
+	return export, nil
+}
+
+// readMagic returns the magic block of the module.
+func (m *wasmModule) readMagic(path string) (PgMagicStruct, error) {
+	magic, err := m.magic()
+	if err != nil {
+		return magic, &LoadError{
+			Kind: ErrIncompatibleMagic,
+			File: path,
+			Err:  err,
+		}
+	}
+	return magic, nil
+}
+
+// magic reads the magic block that is returned by the module's Pg_magic_func.
+func (m *wasmModule) magic() (PgMagicStruct, error) {
+	var magic PgMagicStruct
+	addr, err := m.Lookup("Pg_magic_func")
+	if err != nil {
+		return magic, errors.New("missing magic block")
+	}
+	data, err := m.readStatic(addr, int(unsafe.Sizeof(magic)))
+	if err != nil {
+		return magic, err
+	}
+	// The length is checked before anything else, as the rest of the struct may not exist
+	if magicLen := int32(binary.LittleEndian.Uint32(data)); magicLen != int32(unsafe.Sizeof(magic)) {
+		return magic, fmt.Errorf("magic block mismatch: magic block has length %d", magicLen)
+	}
+	err = binary.Read(bytes.NewReader(data), binary.LittleEndian, &magic)
+	return magic, err
+}
+
+// readFunctionAPIVersion returns the API version that is reported by the pg_finfo_ function at the given address.
+func (m *wasmModule) readFunctionAPIVersion(finfoAddr uintptr) (int, error) {
+	data, err := m.readStatic(finfoAddr, 4)
+	if err != nil {
+		return 0, err
+	}
+	return int(int32(binary.LittleEndian.Uint32(data))), nil
+}
+
+// readStatic calls the function at the given address, which takes no arguments and returns a pointer to static memory,
+// and returns the given number of bytes from that pointer. Returns an error if the pointer is NULL.
+func (m *wasmModule) readStatic(addr uintptr, size int) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	export, err := m.export(addr)
+	if err != nil {
+		return nil, err
+	}
+	results, err := m.invoke(export)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) != 1 || results[0] == 0 {
+		return nil, fmt.Errorf("`%s` did not return a pointer", export.name)
+	}
+	data, ok := m.module.Memory().Read(uint32(results[0]), uint32(size))
+	if !ok {
+		return nil, fmt.Errorf("`%s` returned a pointer outside of the module's memory", export.name)
+	}
+	return bytes.Clone(data), nil
+}
+
+// callProcedure calls the function at the given address, which takes no arguments and returns nothing, such as
+// _PG_init.
+func (m *wasmModule) callProcedure(addr uintptr) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	export, err := m.export(addr)
+	if err != nil {
+		return err
+	}
+	_, err = m.invoke(export)
+	return err
+}
+
+// call calls the function with the given arguments, which must be passed by value, as a pointer within the host has no
+// meaning within the module. Pointers that the function returns are likewise within the module's memory. Calls are not
+// given an FmgrInfo, so functions cannot keep state within fn_extra, and cannot return sets or composites.
+func (m *wasmModule) call(f Function, collation uint32, nodes callNodes, args []NullableDatum) (Datum, bool, error) {
+	if nodes != (callNodes{}) {
+		return 0, false, fmt.Errorf("function `%s` is within a WebAssembly module, which cannot return sets, "+
+			"composites, or triggers", f.Name)
+	}
+	if len(args) > FuncMaxArgs {
+		return 0, false, fmt.Errorf("function `%s` was given %d arguments, but at most %d are allowed",
+			f.Name, len(args), FuncMaxArgs)
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	export, err := m.export(f.Ptr)
+	if err != nil {
+		return 0, false, err
+	}
+	definition := export.fn.Definition()
+	if !slices.Equal(definition.ParamTypes(), []api.ValueType{api.ValueTypeI32}) ||
+		!slices.Equal(definition.ResultTypes(), []api.ValueType{api.ValueTypeI64}) {
+		return 0, false, fmt.Errorf("function `%s` does not take a call info and return a Datum", f.Name)
+	}
+
+	ctx := context.Background()
+	size := wasmCallInfoArgs + wasmNullableDatumSize*len(args)
+	allocated, err := m.alloc.Call(ctx, uint64(size))
+	if err != nil || allocated[0] == 0 {
+		return 0, false, fmt.Errorf("could not allocate the call info of function `%s`", f.Name)
+	}
+	fcinfo := uint32(allocated[0])
+	defer func() {
+		_, _ = m.free.Call(ctx, uint64(fcinfo))
+	}()
+	callInfo := make([]byte, size)
+	binary.LittleEndian.PutUint32(callInfo[wasmCallInfoCollation:], collation)
+	binary.LittleEndian.PutUint16(callInfo[wasmCallInfoNumArgs:], uint16(len(args)))
+	for i, arg := range args {
+		offset := wasmCallInfoArgs + wasmNullableDatumSize*i
+		binary.LittleEndian.PutUint64(callInfo[offset:], uint64(arg.Value))
+		if arg.IsNull {
+			callInfo[offset+8] = 1
+		}
+	}
+	memory := m.module.Memory()
+	if !memory.Write(fcinfo, callInfo) {
+		return 0, false, fmt.Errorf("could not write the call info of function `%s`", f.Name)
+	}
+	results, err := m.invoke(export, uint64(fcinfo))
+	if err != nil {
+		return 0, false, err
+	}
+	isNull, _ := memory.ReadByte(fcinfo + wasmCallInfoIsNull)
+	return Datum(results[0]), isNull != 0, nil
+}
+
+// invoke calls the exported function, returning the error that it raised as a PostgresError. Traps, such as reading
+// outside of the module's memory, are returned as other errors. This expects the mutex to be held.
+func (m *wasmModule) invoke(export *wasmExport, params ...uint64) ([]uint64, error) {
+	m.raised = nil
+	results, err := export.fn.Call(context.Background(), params...)
+	if raised := m.raised; raised != nil {
+		m.raised = nil
+		return nil, *raised
+	}
+	if err != nil {
+		return nil, fmt.Errorf("WebAssembly function `%s` failed: %w", export.name, err)
+	}
+	return results, nil
+}
+
+// raiseError implements env.pgext_raise_error, which reports a message at the given level. Messages below ERROR are
+// given to the host's Logger in the same way as those of native libraries, while all others end the current call.
+func (m *wasmModule) raiseError(_ context.Context, module api.Module, elevel int32, sqlerrcode int32, message uint32,
+	length uint32) {
+	text, ok := module.Memory().Read(message, length)
+	if !ok {
+		text = []byte("message is outside of the module's memory")
+	}
+	if elevel < 21 {
+		code := "00000"
+		if sqlerrcode != 0 {
+			code = decodeSQLState(int(sqlerrcode))
+		} else if LogLevel(elevel) >= LogLevelWarning {
+			code = "01000"
+		}
+		logMessage(LogMessage{
+			Level:     LogLevel(elevel),
+			Code:      code,
+			Message:   string(text),
+			ProcessID: int32(os.Getpid()),
+		})
+		return
+	}
+	m.raised = &PostgresError{
+		Severity: elevelName(int(elevel)),
+		Code:     decodeSQLState(int(sqlerrcode)),
+		Message:  string(text),
+	}
+	panic(errWasmRaised)
+}
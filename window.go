@@ -0,0 +1,219 @@
+// Copyright 2025 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg_extension
+
+/*
+#cgo CFLAGS: "-I${SRCDIR}/library"
+#include "exports.h"
+
+extern bool pgextHostWindowArg(uintptr_t handle, int argno, int64_t pos, Datum* value, bool* isnull);
+extern bool pgextHostWindowPeers(uintptr_t handle, int64_t pos1, int64_t pos2, bool* peers);
+extern bool pgextHostWindowFrame(uintptr_t handle, int64_t pos, int64_t* headpos, int64_t* tailpos);
+
+static inline PgExtWindowPartition* NewHostWindowPartition() {
+	PgExtWindowPartition* partition = (PgExtWindowPartition*)malloc(sizeof(PgExtWindowPartition));
+	partition->get_arg = pgextHostWindowArg;
+	partition->rows_are_peers = pgextHostWindowPeers;
+	partition->frame = pgextHostWindowFrame;
+	return partition;
+}
+
+static inline WindowObject ToWindowObject(uintptr_t winobj) {
+	return (WindowObject)winobj;
+}
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"runtime"
+	"runtime/cgo"
+	"sync"
+	"unsafe"
+)
+
+// WindowPartition provides the rows of a single window partition to a window function. Rows are identified by their
+// zero-based position within the partition, which follows the window's ORDER BY.
+type WindowPartition interface {
+	// RowCount returns the number of rows in the partition.
+	RowCount() int64
+	// Arg returns the argument of the window function with the given zero-based index, which is evaluated for the row at
+	// the given position. By-reference values must remain valid until the partition has been finished.
+	Arg(argno int, row int64) (NullableDatum, error)
+	// RowsArePeers returns whether the rows at the given positions are peers, meaning that they are equal according to
+	// the window's ORDER BY. All rows are peers when the window has no ORDER BY.
+	RowsArePeers(row1 int64, row2 int64) (bool, error)
+	// Frame returns the window frame of the row at the given position, as the position of the frame's first row and the
+	// position that follows its last row.
+	Frame(row int64) (head int64, tail int64, err error)
+}
+
+// windowCall is the state of a single partition, which the shim reaches through its handle.
+type windowCall struct {
+	partition WindowPartition
+	// err is the error that the partition returned during the current call, which takes the place of the error that the
+	// shim raises in response.
+	err error
+}
+
+var (
+	// hostWindowPartition is the C struct that forwards to the partition of each call.
+	hostWindowPartition = sync.OnceValue(func() *C.PgExtWindowPartition {
+		return C.NewHostWindowPartition()
+	})
+	shimWindowBegin    = newShimProc("pgext_window_begin")
+	shimWindowRowBegin = newShimProc("pgext_window_row_begin")
+	shimWindowRowEnd   = newShimProc("pgext_window_row_end")
+	shimWindowEnd      = newShimProc("pgext_window_end")
+)
+
+// CallFmgrWindowFunction calls the given window function once for each row of the partition, in order, which takes
+// the given number of arguments. Window functions fetch their arguments from the partition rather than being given
+// them, so that they may look at other rows. The function is called each time that the sequence advances, and
+// by-reference results remain valid until the sequence advances again. If the function raises an error, then it is the
+// last element of the sequence.
+func CallFmgrWindowFunction(fn uintptr, numArgs int, partition WindowPartition) iter.Seq2[NullableDatum, error] {
+	return callWindowFunction(Function{Ptr: fn}, numArgs, partition, func(fi *C.FmgrInfo, nodes callNodes,
+		args []NullableDatum) (Datum, bool, error) {
+		return callFmgrInfo(fi, nodes, 0, args...)
+	})
+}
+
+// CallWindow is the same as CallFmgrWindowFunction, except that each call is recorded against the resource usage of
+// the function's library.
+func (f Function) CallWindow(numArgs int, partition WindowPartition) iter.Seq2[NullableDatum, error] {
+	return callWindowFunction(f, numArgs, partition, func(fi *C.FmgrInfo, nodes callNodes,
+		args []NullableDatum) (Datum, bool, error) {
+		// Window functions are given NULL for each argument, so STRICT does not apply to them
+		fn := f
+		fn.Strict = false
+		result, isNull, _, err := fn.callWithInfo(fi, nodes, 0, args...)
+		return result, isNull, err
+	})
+}
+
+// callWindowFunction returns the sequence of results of the window function over the partition, which is called using
+// the given call.
+func callWindowFunction(fn Function, numArgs int, partition WindowPartition,
+	call func(fi *C.FmgrInfo, nodes callNodes, args []NullableDatum) (Datum, bool, error),
+) iter.Seq2[NullableDatum, error] {
+	return func(yield func(NullableDatum, error) bool) {
+		if numArgs < 0 || numArgs > FuncMaxArgs {
+			yield(NullableDatum{}, fmt.Errorf("window functions must take between 0 and %d arguments", FuncMaxArgs))
+			return
+		}
+		rowCount := partition.RowCount()
+		if rowCount <= 0 {
+			return
+		}
+		state := &windowCall{partition: partition}
+		handle := cgo.NewHandle(state)
+		defer handle.Delete()
+		winPtr, err := shimWindowBegin.Call(uintptr(unsafe.Pointer(hostWindowPartition())), uintptr(handle),
+			uintptr(numArgs), uintptr(rowCount))
+		if err != nil {
+			yield(NullableDatum{}, err)
+			return
+		}
+		if winPtr == 0 {
+			yield(NullableDatum{}, errors.New("out of memory while calling a window function"))
+			return
+		}
+		defer shimWindowEnd.MustCall(winPtr)
+		winobj := C.ToWindowObject(C.uintptr_t(winPtr))
+		// The FmgrInfo persists for the whole partition, so that the function may cache state within it
+		fi := Malloc[C.FmgrInfo]()
+		defer Free(fi)
+		ZeroMemory(fi)
+		fillFmgrInfo(fi, fn)
+		fi.fn_nargs = C.short(numArgs)
+		fi.fn_mcxt = unsafe.Pointer(winobj.partcontext)
+		args := make([]NullableDatum, numArgs)
+		for i := range args {
+			args[i] = NullableDatum{IsNull: true}
+		}
+		for pos := int64(0); pos < rowCount; pos++ {
+			result, isNull, err := callWindowRow(winPtr, pos, state, func() (Datum, bool, error) {
+				return call(fi, callNodes{context: unsafe.Pointer(winobj)}, args)
+			})
+			if err != nil {
+				yield(NullableDatum{}, err)
+				return
+			}
+			if !yield(NullableDatum{Value: result, IsNull: isNull}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// callWindowRow makes the call for the row at the given position, within the partition's temporary memory context. An
+// error that the partition returned during the call is preferred over the error that the function raised.
+func callWindowRow(winPtr uintptr, pos int64, state *windowCall,
+	call func() (Datum, bool, error)) (Datum, bool, error) {
+	// The current memory context belongs to the state of the current thread, so we must remain on the same thread until
+	// the call has ended
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if _, err := shimWindowRowBegin.Call(winPtr, uintptr(pos)); err != nil {
+		return 0, false, err
+	}
+	defer shimWindowRowEnd.MustCall(winPtr)
+	state.err = nil
+	result, isNull, err := call()
+	if err != nil && state.err != nil {
+		return 0, false, state.err
+	}
+	return result, isNull, err
+}
+
+//export pgextHostWindowArg
+func pgextHostWindowArg(handle C.uintptr_t, argno C.int, pos C.int64_t, value *C.Datum, isnull *C.bool) C.bool {
+	state := cgo.Handle(handle).Value().(*windowCall)
+	arg, err := state.partition.Arg(int(argno), int64(pos))
+	if err != nil {
+		state.err = err
+		return false
+	}
+	*value = C.Datum(arg.Value)
+	*isnull = C.bool(arg.IsNull)
+	return true
+}
+
+//export pgextHostWindowPeers
+func pgextHostWindowPeers(handle C.uintptr_t, pos1 C.int64_t, pos2 C.int64_t, peers *C.bool) C.bool {
+	state := cgo.Handle(handle).Value().(*windowCall)
+	arePeers, err := state.partition.RowsArePeers(int64(pos1), int64(pos2))
+	if err != nil {
+		state.err = err
+		return false
+	}
+	*peers = C.bool(arePeers)
+	return true
+}
+
+//export pgextHostWindowFrame
+func pgextHostWindowFrame(handle C.uintptr_t, pos C.int64_t, headpos *C.int64_t, tailpos *C.int64_t) C.bool {
+	state := cgo.Handle(handle).Value().(*windowCall)
+	head, tail, err := state.partition.Frame(int64(pos))
+	if err != nil {
+		state.err = err
+		return false
+	}
+	*headpos = C.int64_t(head)
+	*tailpos = C.int64_t(tail)
+	return true
+}